@@ -12,23 +12,90 @@
 #include <string>
 #include <vector>
 #include <memory>
+#include <map>
+#include <set>
 #include <unordered_map>
+#include <sstream>
+#include <functional>
 
 namespace hybrid_transpiler {
 namespace ffi {
 
+/**
+ * @brief Data flow of a parameter passed by non-const reference
+ */
+enum class ParamDirection {
+    In,     // Only read by the callee (every parameter not passed by non-const reference)
+    Out,    // Only written by the callee; an extra Go result
+    InOut   // Read, then written by the callee; a Go pointer
+};
+
 /**
  * @brief Represents a function parameter for FFI
  */
+/**
+ * @brief Values a numeric parameter is documented to take, from a Doxygen
+ *        @param sentence ("Must be between 0 and 9") or FFIOptions::constraints
+ */
+struct FFIConstraint {
+    std::string text;           // Normalized phrasing echoed in docs ("between 0 and 9"); "" if none
+    std::string min;            // Lower bound as a literal ("" if unbounded)
+    std::string max;            // Upper bound as a literal ("" if unbounded)
+    bool min_exclusive = false;
+    bool max_exclusive = false;
+    std::vector<std::string> values;  // Allowed values of "one of 1, 2 or 4" (min and max then unset)
+    std::string skipped;        // Phrasing not checked because it is ambiguous, and why ("" if none)
+};
+
 struct FFIParameter {
     std::string name;
     std::string cpp_type;      // Original C++ type
     std::string c_type;        // C-compatible type
     std::string rust_type;     // Rust FFI type
     std::string go_type;       // Go FFI type (cgo)
-    bool is_pointer;
-    bool is_const;
-    bool is_reference;
+    bool is_pointer = false;
+    bool is_const = false;
+    bool is_reference = false;
+    bool is_enum = false;      // cpp_type is an enum passed as its underlying c_type
+    ParamDirection direction = ParamDirection::In;  // Scalars by non-const reference cross as c_type pointers,
+                                                    // writable mirrored structs as Go values
+    std::string default_source;  // Default argument as written ("" if none)
+    std::string default_value;   // default_source with names qualified (Widget::DEFAULT_WIDTH);
+                                 // "" if it refers to anything but literals, constants and enumerators
+    FFIConstraint constraint;    // Values the Go wrapper lets through to C++
+};
+
+/**
+ * @brief Callback-driven visitation: a function pointer called with each
+ *        element plus the void* context passed alongside it
+ *
+ * The Go binding takes a func instead; a collecting method gathers the
+ * elements into a slice through the same callback.
+ */
+struct FFIVisitor {
+    std::string callback;       // Function pointer parameter ("" if not a visitor)
+    std::string context;        // void* parameter the callback gets back
+    FFIParameter element;       // What the callback is called with; c_type void* for class handles
+    bool stops = false;         // The callback returns bool and false stops the visit
+    FFIParameter result;        // Enum the callback returns, which C++ acts on (no cpp_type if not an enum)
+    std::string panic_result;   // What C++ gets once the Go func panics: true, false or an enumerator
+                                // (// @panic_result <value>); "" for false or the first enumerator
+    std::string collect;        // Name of the collecting method ("" for none)
+    std::string clone;          // Member copying a class element for it ("" copy-constructs)
+    std::string free_receiver;  // Type of the first parameter of a free function bound as a
+                                // method of that class ("" for members)
+    std::string policy;         // Functor listed for a template's callable policy (// @instantiate),
+                                // which the callback stands in for; "" for a plain visitor
+};
+
+/**
+ * @brief Log callback installed by a setter marked // @logger, which Go routes to log/slog
+ */
+struct FFILogger {
+    std::string callback;       // Function pointer parameter ("" if not a logger setter)
+    std::string context;        // void* parameter passed along with it ("" if none)
+    std::string callback_type;  // C++ type of the callback, whose arguments the shim adapts
+    FFIParameter level;         // The enum level the callback is called with
 };
 
 /**
@@ -41,12 +108,39 @@ struct FFIFunction {
     std::string return_type;    // Original C++ return type
     std::string c_return_type;  // C-compatible return type
     std::vector<FFIParameter> parameters;
-    bool is_method;             // true if member function
-    bool is_static;             // true if static member function
-    bool is_const;              // true if const member function
+    bool is_method = false;     // true if member function
+    bool is_static = false;     // true if static member function
+    bool is_const = false;      // true if const member function
+    bool is_constructor = false; // true if constructor (shim returns a new handle)
+    bool is_destructor = false;  // true if destructor (shim deletes the handle)
     std::string class_name;     // Class name if member function
-    bool is_virtual;            // true if virtual function
-    bool can_use_ffi;           // true if FFI-compatible
+    std::string cpp_namespace;  // Enclosing namespace ("engine::net"), "" at global scope
+    bool is_virtual = false;    // true if virtual function
+    bool direct = false;        // Shim calling Class::method non-virtually, for objects of exactly that class
+    std::string field_name;     // Field read/written by a synthesized accessor
+    bool atomic = false;        // The accessor loads or stores a std::atomic field
+    bool subscript = false;     // The accessor reads or writes an element through operator[]
+    bool unvalidated = false;   // Parameter constraints are documented but not checked
+                                // (// @novalidate or FFIOptions::unvalidated), for hot paths
+    bool returns_enum = false;  // return_type is an enum returned as c_return_type
+    bool returns_status = false; // Integer result is a status code, 0 on success (// @status)
+    bool printf_format = false;  // Last parameter is a printf format followed by ... (dropped)
+    bool main_thread_only = false;  // Must run on the main thread (GUI/event-loop APIs)
+    bool signal_unsafe = false;     // Changes signal handling in ways that conflict with the Go runtime
+    std::string iteration;      // Step of a synthesized iteration shim: begin, has_next, next, get or delete
+    bool cached = false;        // Short, rarely changing string result Go may cache (// @cached)
+    bool throws = false;        // C++ exceptions become a Go error (// @throws)
+    std::string factory;        // Class a static factory returns by T* ("" if not a factory)
+    bool borrowed = false;      // The factory keeps ownership of what it returns (// @borrowed)
+    bool interface_factory = false;  // The factory's class is abstract, so a nullptr result is a Go error
+    std::string feature;        // Macro of the innermost #ifdef/#if defined() around it ("" if none)
+    std::vector<std::string> targets; // Target triples declaring it this way, when the platform
+                                      // configurations disagree (empty: every target)
+    std::string array_size;     // Parameter holding the length of a std::unique_ptr<T[]> result
+    FFIVisitor visitor;         // Callback parameter driven by a Go func (visit/for_each)
+    FFILogger logger;           // Log callback routed to a *slog.Logger (// @logger)
+    std::string doc;            // Doxygen comment text, without comment markers
+    bool can_use_ffi = true;    // true if FFI-compatible
     std::string reason;         // Reason if not FFI-compatible
 };
 
@@ -55,14 +149,404 @@ struct FFIFunction {
  */
 struct FFIClass {
     std::string name;
+    std::string cpp_namespace;  // Enclosing namespace ("engine::net"), "" at global scope
+    std::string symbol_stem;    // Shim symbols of its members start <stem>_ ("" for the legacy <Class>_)
     std::vector<FFIFunction> methods;
     std::vector<FFIFunction> static_methods;
     std::vector<FFIParameter> fields;
-    bool has_virtual_functions;
-    bool is_polymorphic;
-    bool is_abstract;
-    size_t size;                // Size in bytes
-    size_t alignment;           // Alignment requirement
+    bool has_virtual_functions = false;
+    bool is_polymorphic = false;
+    bool is_abstract = false;
+    bool is_mirrored = false;   // Plain data struct mirrored by value in Go
+    bool borrowed = false;      // Some // @borrowed factory returns it, so wrappers may not own their object
+    std::string pool_reset;     // Method recycling an instance for a Go object pool ("" if not poolable)
+    bool devirtualize = false;  // Objects Go constructs call its virtual methods directly (// @devirtualize)
+    FFIParameter iterator_element; // What *begin() yields, for classes iterated from Go (no cpp_type if not)
+    bool iterator_const = false;   // begin() and end() are const members
+    std::string feature;        // Macro of the innermost #ifdef/#if defined() around it ("" if none)
+    std::vector<std::string> targets; // Target triples declaring it, when not all do (empty: every target)
+    std::map<std::string, std::vector<FFIParameter>> target_fields; // Data members per target triple when the
+                                                                    // configurations disagree; fields then holds
+                                                                    // those common to all of them
+    std::vector<std::string> base_classes;
+    std::string doc;            // Doxygen comment text, without comment markers
+    size_t size = 0;            // Size in bytes
+    size_t alignment = 0;       // Alignment requirement
+};
+
+/**
+ * @brief Represents an enum exposed through FFI as its underlying integer type
+ */
+struct FFIEnum {
+    std::string name;
+    std::string underlying_type = "int";
+    std::vector<std::pair<std::string, std::string>> enumerators;  // name, value ("" if implicit)
+    std::string doc;
+    std::string name_function;   // Free function naming its values (// @go:enum-names func=<name>)
+};
+
+/**
+ * @brief A static constexpr member or namespace-scope constant
+ */
+struct FFIConstant {
+    std::string name;      // Qualified by its class (Widget::DEFAULT_WIDTH)
+    std::string cpp_type;
+    std::string c_type;
+    std::string value;     // Initializer with names qualified like FFIParameter::default_value
+};
+
+/**
+ * @brief Everything a C++ source exposes through FFI
+ */
+struct FFIModule {
+    std::vector<FFIFunction> functions;
+    std::vector<FFIClass> classes;
+    std::vector<FFIEnum> enums;
+    std::vector<FFIConstant> constants;
+    std::vector<std::string> modules;   // Named modules whose interface units declared some of it
+};
+
+/**
+ * @brief C++ ABI family used for class layout
+ */
+enum class ABIKind {
+    Itanium,    // GCC/Clang everywhere, including MinGW
+    MSVC        // Microsoft Visual C++ (and clang-cl)
+};
+
+/**
+ * @brief Layout-relevant properties of a target, derived from its triple
+ */
+struct ABIProfile {
+    std::string triple;
+    ABIKind kind = ABIKind::Itanium;
+    size_t pointer_size = 8;
+    size_t long_size = 8;
+    size_t int64_alignment = 8;   // 4 for long long/double members on i386 System V
+    size_t bool_size = 1;         // 4 on 32-bit PowerPC Darwin
+    std::string go_os;            // GOOS, empty if unknown
+    std::string go_arch;          // GOARCH, empty if unknown
+
+    /**
+     * @brief Build a profile from a target triple such as x86_64-pc-windows-msvc
+     * @throws std::invalid_argument if the triple has no architecture and OS
+     */
+    static ABIProfile fromTriple(const std::string& triple);
+
+    /**
+     * @brief Go build constraint selecting this target, e.g. "windows && amd64"
+     * @return Empty if GOOS or GOARCH is unknown
+     */
+    std::string goBuildConstraint() const;
+
+    /**
+     * @brief Preprocessor condition that holds when compiling for this target
+     */
+    std::string preprocessorCondition() const;
+
+    /**
+     * @brief Platform macros a compiler for this target predefines, e.g.
+     *        _WIN32 and _M_X64 for x86_64-pc-windows-msvc
+     */
+    std::vector<std::string> predefinedMacros() const;
+
+    /**
+     * @brief Whether a macro is predefined by some target, so a header's
+     *        #ifdef on it selects a platform configuration
+     */
+    static bool isPlatformMacro(const std::string& macro);
+};
+
+/**
+ * @brief Placement of one data member in a struct layout
+ */
+struct FieldLayout {
+    std::string name;
+    std::string c_type;
+    size_t offset = 0;
+    size_t size = 0;
+};
+
+/**
+ * @brief Computed layout of a mirrored struct under one ABI profile
+ */
+struct StructLayout {
+    bool valid = false;         // false if some member type has no known layout
+    std::string reason;         // Why the layout is invalid
+    size_t size = 0;            // sizeof, including tail padding
+    size_t data_size = 0;       // End of the last member (Itanium dsize)
+    size_t alignment = 1;
+    bool is_empty = false;      // No data members in the class or its bases
+    std::vector<FieldLayout> fields;   // Inherited members first
+};
+
+/**
+ * @brief What to do with a mirrored struct whose layout differs between targets
+ */
+enum class LayoutMismatchPolicy {
+    Split,      // One Go file per target, selected by build constraints
+    Accessors   // Demote to an opaque handle with getter/setter shims
+};
+
+/**
+ * @brief How a generated Go identifier that collides with another is renamed
+ */
+enum class RenameRule {
+    Suffix,     // Time -> TimeCpp, sync -> syncCpp
+    Prefix      // Time -> CppTime, sync -> cppSync
+};
+
+/**
+ * @brief What Go bindings do with functions annotated // @signal_unsafe
+ */
+enum class SignalUnsafePolicy {
+    BuildTag,   // Bind them in a separate file built only with an opt-in tag
+    Exclude     // Leave them out of the Go bindings
+};
+
+/**
+ * @brief What Go bindings do when a C++ exception escapes a function not
+ *        annotated // @throws
+ */
+enum class ExceptionBehavior {
+    Abort,      // Let it reach the extern "C" boundary, which terminates the process
+    Panic       // Catch it in the shim and panic in Go with its what() message
+};
+
+/**
+ * @brief What Go wrappers do with an argument outside its documented constraint
+ */
+enum class ValidationFailure {
+    Error,      // Return a *ValidationError (constructors, which have no error result, panic)
+    Panic       // Panic with the *ValidationError
+};
+
+/**
+ * @brief Part of a library compiled only when its feature macro is defined
+ */
+struct FFIFeature {
+    std::string tag;            // Go build tag; "" lower-cases the macro without WITH_ (MYLIB_WITH_X -> mylib_x)
+    std::string cgo_cflags;     // #cgo CFLAGS of the feature's Go file
+    std::string cgo_ldflags;    // #cgo LDFLAGS of the feature's Go file, e.g. a library only it needs
+};
+
+/**
+ * @brief Status codes after which a class's C++ handle must not be used again
+ */
+struct HandleInvalidation {
+    std::vector<std::string> errors;  // Codes returned by its @status methods: C++ constants or integers
+    std::string factory;              // Static method returning a fresh handle ("Session::open"),
+                                      // bound as Reconnect; "" for none
+    bool delete_invalidated = false;  // The destructor is safe on an invalidated object, so Delete
+                                      // and Reconnect free it; otherwise it is leaked
+};
+
+/**
+ * @brief Types an untyped method result points to, told apart by a tag
+ */
+struct TaggedPayload {
+    std::string tag;    // Method of the same class returning the tag, an enum or integer ("type")
+    std::string size;   // Method returning the payload's size in bytes; "" for none
+    std::map<std::string, std::string> types;  // Tag (enumerator or integer) -> C++ type it points to
+};
+
+/**
+ * @brief Options controlling generated bindings and shims
+ */
+struct FFIOptions {
+    std::string package_name;           // Go package name (defaults to library name)
+    std::string include_dir = "../include";  // Relative to the generated package
+    std::string lib_dir = "../lib";          // Relative to the generated package
+
+    // Windows: import shim symbols from a .dll/.lib pair via __declspec(dllimport)
+    bool windows_dll_import = false;
+
+    // User text inserted verbatim at fixed anchors of the generated files
+    std::string cgo_prologue;   // cgo preamble, after #cgo lines and before #includes
+    std::string cgo_epilogue;   // cgo preamble, after the shim declarations
+    std::string shim_prologue;  // shim source, before its #includes
+
+    // Targets whose C++ ABI mirrored structs must match
+    std::vector<std::string> target_triples = {"x86_64-unknown-linux-gnu", "x86_64-pc-windows-msvc"};
+    LayoutMismatchPolicy layout_mismatch = LayoutMismatchPolicy::Split;
+
+    // Named modules ("engine") the shim imports after its includes, for APIs
+    // declared in C++20 module interface units; FFIGenerator adds those of
+    // the units it analyzes. A project declaring its whole API in modules
+    // turns library_header off, and the shim includes no <library>.h
+    std::vector<std::string> modules;
+    bool library_header = true;
+
+    // Functions that may replace signal handlers the Go runtime relies on
+    SignalUnsafePolicy signal_unsafe_policy = SignalUnsafePolicy::BuildTag;
+    std::string signal_unsafe_tag = "hybrid_signal_unsafe";
+
+    // Functions, typically a library's init and teardown, that install their
+    // own signal handlers, keyed by qualified name ("mylib_init"). Their shim
+    // restores the handlers the Go runtime installed once they return, except
+    // for the listed signals ("SIGPIPE"), whose library handler runs first
+    // and then chains to Go's. Ignored on Windows
+    std::map<std::string, std::vector<std::string>> preserve_signals;
+
+    // Optional features, keyed by the macro guarding their declarations
+    // (#ifdef MYLIB_WITH_ENCRYPTION). Their shims compile only when the macro
+    // is defined and their Go bindings go to <library>_<tag>.go, built only
+    // with the feature's tag
+    std::map<std::string, FFIFeature> features;
+
+    // Go wrappers panic on enum arguments that are not declared enumerators
+    bool validate_enums = false;
+
+    // Functions annotated // @throws always return C++ exceptions as a Go
+    // error; Panic also wraps every other shim in try/catch
+    ExceptionBehavior default_exception_behavior = ExceptionBehavior::Abort;
+
+    // Functions whose output parameters are returned together as one Go struct:
+    // qualified name ("getImageInfo", "Image::info") -> struct name, where ""
+    // names it after the function (<Function>Result)
+    std::map<std::string, std::string> result_structs;
+
+    // Also emit bindings.h, the shim's extern "C" functions for non-Go consumers
+    bool bindings_header = false;
+
+    // Declare the shims in generated_decls.h next to the Go files, which every
+    // cgo preamble #includes, instead of inline in each preamble
+    bool decls_header = false;
+
+    // Class wrappers embed a sync.Mutex that every method and Delete hold
+    // around the C++ call, so one object may be shared between goroutines
+    bool thread_safe = false;
+
+    // Methods annotated // @cached return their string from a per-object
+    // cache, cleared by the matching setter or InvalidateCache. Changes made
+    // on the C++ side go unseen, so this is opt-in
+    bool cached_strings = false;
+
+    // The At and SetAt accessors of a class with operator[] panic on an index
+    // outside [0, Size()) instead of passing it to C++; classes without a
+    // size() method stay unchecked
+    bool bounds_check = false;
+
+    // Numeric arguments are checked against the constraints their Doxygen
+    // @param text states unambiguously ("Must be between 0 and 9", "at least
+    // 1", "one of 8, 16 or 32") before the call crosses into C++. Overrides
+    // go by qualified function and parameter name ("Codec::setLevel" ->
+    // "level" -> "between 0 and 9"), where "" drops a documented constraint;
+    // unvalidated functions keep theirs in the docs only
+    ValidationFailure validation_failure = ValidationFailure::Error;
+    std::map<std::string, std::map<std::string, std::string>> constraints;
+    std::set<std::string> unvalidated;
+
+    // Classes whose handle a status code invalidates, keyed by class name.
+    // The wrapper stops calling C++ once it sees one and returns
+    // ErrHandleInvalidated until Reconnect replaces the handle
+    std::map<std::string, HandleInvalidation> handle_invalidation;
+
+    // void* results whose type a tag tells, keyed by qualified method name
+    // ("Event::payload"). The raw method is bound unexported and the Go
+    // method of its name returns the typed value as any, or RawPayload with
+    // ErrUnknownPayloadTag for tags with no type
+    std::map<std::string, TaggedPayload> tagged_payloads;
+
+    // String arguments of up to this many bytes are copied into a buffer on
+    // the Go stack instead of the C heap. Their shims are declared #cgo
+    // noescape and nocallback, which needs Go 1.24; visitors, which call
+    // back into Go, always allocate. 0 always allocates
+    size_t small_string_size = 0;
+
+    // generateGoFiles also writes the <library>_*_test.go benchmarks of
+    // pools, devirtualized calls, small strings and scratch arenas. They
+    // measure the bindings rather than test the package that vendors them,
+    // so this is opt-in
+    bool benchmarks = false;
+
+    // Functions taking two or more string arguments copy them into one C
+    // allocation per call, released by a single defer however the call
+    // ends, rather than a C.CString and a free for each. Functions whose
+    // strings take the small_string_size stack buffer are left to it
+    bool scratch_arena = false;
+
+    // Top-level Go identifiers that collide with a package the bindings may
+    // import, a predeclared identifier, the support code or an identifier
+    // declared before them are renamed with collision_affix; generateReport
+    // lists every rename
+    RenameRule collision_rule = RenameRule::Suffix;
+    std::string collision_affix = "Cpp";
+
+    // Shim symbols are <prefix>_<namespace>_<Class>_<function>, leaving out
+    // the parts a binding lacks, with :: in namespaces as _ and _<n> after
+    // the n-th (from 0) of overloads bound under one name:
+    // hyb_engine_Session_connect_1. Constructors are "new", destructors
+    // "delete". The prefix defaults to the library name, so no shim takes
+    // a name the wrapped library uses for its own C functions.
+    // legacy_symbol_names keeps the <Class>_<method> and <library>_<function>
+    // names of earlier releases. Either way, generating two shims with one
+    // symbol is an error rather than a link failure
+    std::string symbol_prefix;
+    bool legacy_symbol_names = false;
+};
+
+/**
+ * @brief Struct layout engine for mirrored structs
+ *
+ * Reproduces the Itanium and MSVC record layout rules that matter for
+ * plain data: scalar size/alignment per target, empty-base optimization
+ * (MSVC only applies it to the first empty base) and reuse of a non-POD
+ * base's tail padding (Itanium only).
+ */
+class LayoutEngine {
+public:
+    /**
+     * @param classes All known classes, used to resolve base classes
+     */
+    explicit LayoutEngine(const std::vector<FFIClass>& classes) : classes_(classes) {}
+
+    /**
+     * @brief Lay out a class under an ABI profile
+     * @param cls Class descriptor (fields, or target_fields of the profile's
+     *        triple, and base_classes)
+     * @param profile Target ABI profile
+     * @return Layout with inherited members flattened in
+     */
+    StructLayout layout(const FFIClass& cls, const ABIProfile& profile) const;
+
+    /**
+     * @brief Check that two layouts place every member identically
+     */
+    static bool sameLayout(const StructLayout& a, const StructLayout& b);
+
+    /**
+     * @brief Build the profiles for the configured target triples
+     */
+    static std::vector<ABIProfile> profiles(const FFIOptions& options);
+
+    /**
+     * @brief Check whether a class exists in the platform configuration of a target
+     */
+    static bool isDeclared(const FFIClass& cls, const ABIProfile& profile);
+
+    /**
+     * @brief Check whether one Go mirror fits every configured target
+     * @return true if all targets declare the struct with the same members
+     *         and agree on its layout
+     */
+    bool isPortable(const FFIClass& cls, const std::vector<ABIProfile>& profiles) const;
+
+    /**
+     * @brief Apply the layout mismatch policy to mirrored structs
+     * @param classes Class descriptors
+     * @param options Target triples and mismatch policy
+     * @return Classes where mirrors that cannot be kept are demoted to
+     *         accessor-only handles (is_mirrored cleared, accessors added)
+     */
+    static std::vector<FFIClass> resolveMirrors(const std::vector<FFIClass>& classes, const FFIOptions& options);
+
+private:
+    std::vector<FFIClass> classes_;
+
+    const FFIClass* findClass(const std::string& name) const;
+    std::vector<FFIParameter> memberFields(const FFIClass& cls) const;
+    bool isSplittable(const FFIClass& cls, const std::vector<ABIProfile>& profiles) const;
 };
 
 /**
@@ -73,9 +557,49 @@ struct FFIClass {
  */
 class FFIAnalyzer {
 public:
-    FFIAnalyzer() = default;
+    FFIAnalyzer() { initializeTypeMappings(); }
+    explicit FFIAnalyzer(const FFIOptions& options) : options_(options) { initializeTypeMappings(); }
     ~FFIAnalyzer() = default;
 
+    /**
+     * @brief Collect the functions, classes and enums of a C++ source
+     * @param cpp_source C++ source code
+     * @param library_name Default prefix of the shim symbols (see
+     *        FFIOptions::symbol_prefix); extern "C" functions keep their own name
+     * @return Public API with C types resolved and Doxygen comments attached;
+     *         members that cannot cross the C ABI have can_use_ffi cleared.
+     *         Structs preceded by a // @mirror comment are mirrored by value;
+     *         classes marked // @poolable [method] get a Go object pool that
+     *         recycles instances with reset() or the named method.
+     *         Functions annotated // @main_thread_only or // @signal_unsafe
+     *         are flagged for gating in the Go bindings. Scalars passed by
+     *         non-const reference are out parameters when documented
+     *         @param[out] or named out/out_x/x_out, unless listed by
+     *         // @inout [name...]; all others are in/out. The same rules
+     *         apply to mirrored structs passed by non-const pointer or
+     *         reference. // @status marks an integer result as a status
+     *         code that the Go bindings turn into an error. A trailing
+     *         `const char* format, ...` is bound printf-style: Go formats
+     *         and the shim passes the result through "%s". A
+     *         std::unique_ptr<T[]> result of scalars takes its length from
+     *         the parameter named by // @array_size <name>, or else named
+     *         size, length or count; passed by reference, it is an output.
+     *         Declarations
+     *         inside #ifdef X, #if X or #if defined(X) record X as their
+     *         feature; members of such a class inherit it. C++20 module
+     *         interface units (export module engine;) may follow the
+     *         headers: only their exported declarations are bound, and
+     *         FFIModule::modules names the modules the shim must import.
+     *         #if blocks testing platform macros (_WIN32, __linux__, ...)
+     *         are resolved for each of FFIOptions::target_triples: what
+     *         the configurations declare alike is bound once, classes and
+     *         functions they disagree on carry the triples declaring them
+     *         in targets, and mirrored structs whose members differ get
+     *         target_fields.
+     * @throws std::invalid_argument if the configurations declare an enum differently
+     */
+    FFIModule analyzeSource(const std::string& cpp_source, const std::string& library_name);
+
     /**
      * @brief Analyze a C++ function for FFI compatibility
      * @param function_decl Function declaration to analyze
@@ -130,6 +654,16 @@ private:
      * @brief Initialize type mapping tables
      */
     void initializeTypeMappings();
+
+    FFIParameter analyzeType(const std::string& cpp_type, const FFIModule& module);
+
+    /**
+     * @brief Name the shims of a module under FFIOptions::symbol_prefix
+     * @throws std::invalid_argument if the prefix is not a C identifier
+     */
+    void assignSymbols(FFIModule& module, const std::string& library_name) const;
+
+    FFIOptions options_;
 };
 
 /**
@@ -181,6 +715,7 @@ public:
 class GoFFIGenerator {
 public:
     GoFFIGenerator() = default;
+    explicit GoFFIGenerator(const FFIOptions& options) : options_(options) {}
     ~GoFFIGenerator() = default;
 
     /**
@@ -209,13 +744,359 @@ public:
      * @param functions List of FFI functions
      * @param classes List of FFI classes
      * @param library_name Name of the C++ library
+     * @param enums Enums declared as Go types with an IsValid method; enum
+     *        parameters and results of other enums stay plain integers
+     * @param constants Constants default arguments may refer to; those that
+     *        are referenced become Go constants
      * @return Complete Go package code
      */
     std::string generatePackage(
         const std::vector<FFIFunction>& functions,
+        const std::vector<FFIClass>& classes,
+        const std::string& library_name,
+        const std::vector<FFIEnum>& enums = {},
+        const std::vector<FFIConstant>& constants = {}
+    );
+
+    /**
+     * @brief Generate the cgo preamble (#cgo directives and C declarations)
+     * @param functions List of FFI functions
+     * @param classes List of FFI classes
+     * @param library_name Name of the C++ library
+     * @param body Go code below the preamble; the shims it passes small
+     *        strings to are declared #cgo noescape and nocallback
+     * @return Preamble text placed in the comment above import "C"
+     */
+    std::string generatePreamble(
+        const std::vector<FFIFunction>& functions,
+        const std::vector<FFIClass>& classes,
+        const std::string& library_name,
+        const std::string& body = ""
+    );
+
+    /**
+     * @brief Generate generated_decls.h, the C declarations the cgo preambles
+     *        include under FFIOptions::decls_header
+     * @param functions List of FFI functions
+     * @param classes List of FFI classes
+     * @param library_name Name of the C++ library
+     * @return Header text, written next to the Go files generated with the
+     *         same options; "" without decls_header
+     */
+    std::string generateDeclsHeader(
+        const std::vector<FFIFunction>& functions,
+        const std::vector<FFIClass>& classes,
+        const std::string& library_name
+    );
+
+    /**
+     * @brief Generate per-target Go files for mirrored structs whose layout
+     *        differs between the configured targets
+     * @param classes List of FFI classes
+     * @param library_name Name of the C++ library
+     * @return File name -> content; empty if every mirror is portable
+     */
+    std::map<std::string, std::string> generateLayoutFiles(
+        const std::vector<FFIClass>& classes,
+        const std::string& library_name
+    );
+
+    /**
+     * @brief Generate the wrappers of signal-unsafe functions
+     * @param functions List of FFI functions
+     * @param classes List of FFI classes
+     * @param library_name Name of the C++ library
+     * @return Content of <library>_signal_unsafe.go, built only with the
+     *         signal_unsafe_tag build tag; empty if there is nothing to gate
+     *         or the policy excludes them
+     * @param enums Enums declared by the package (see generatePackage)
+     * @param constants Constants declared by the package (see generatePackage)
+     */
+    std::string generateSignalUnsafeFile(
+        const std::vector<FFIFunction>& functions,
+        const std::vector<FFIClass>& classes,
+        const std::string& library_name,
+        const std::vector<FFIEnum>& enums = {},
+        const std::vector<FFIConstant>& constants = {}
+    );
+
+    /**
+     * @brief Generate the wrappers of optional features
+     * @param functions List of FFI functions
+     * @param classes List of FFI classes
+     * @param library_name Name of the C++ library
+     * @param enums Enums declared by the package (see generatePackage)
+     * @param constants Constants declared by the package (see generatePackage)
+     * @return File name -> content: <library>_<tag>.go (<tag>.go if the tag
+     *         starts with <library>_) per configured feature, built only with
+     *         its tag and adding it to Features(), and <...>_signal_unsafe.go
+     *         for its signal-unsafe functions
+     * @throws std::invalid_argument if a feature's tag is not a Go build tag
+     */
+    std::map<std::string, std::string> generateFeatureFiles(
+        const std::vector<FFIFunction>& functions,
+        const std::vector<FFIClass>& classes,
+        const std::string& library_name,
+        const std::vector<FFIEnum>& enums = {},
+        const std::vector<FFIConstant>& constants = {}
+    );
+
+    /**
+     * @brief Generate the bindings of what only some targets declare, or
+     *        declare differently (FFIFunction::targets, FFIClass::targets)
+     * @param functions List of FFI functions
+     * @param classes List of FFI classes
+     * @param library_name Name of the C++ library
+     * @param enums Enums declared by the package (see generatePackage)
+     * @param constants Constants declared by the package (see generatePackage)
+     * @return File name -> content: <library>_<goos>_<goarch>.go per build
+     *         constraint of the target triples that binds anything
+     * @throws std::invalid_argument if targets sharing a build constraint
+     *         disagree, or something per target is also signal-unsafe or
+     *         part of an optional feature
+     */
+    std::map<std::string, std::string> generateTargetFiles(
+        const std::vector<FFIFunction>& functions,
+        const std::vector<FFIClass>& classes,
+        const std::string& library_name,
+        const std::vector<FFIEnum>& enums = {},
+        const std::vector<FFIConstant>& constants = {}
+    );
+
+    /**
+     * @brief Get the Go build tag of a configured feature
+     * @param macro Key of FFIOptions::features
+     * @return FFIFeature::tag, or the tag derived from the macro
+     * @throws std::invalid_argument if the macro is not an identifier or the
+     *         tag is not a Go build tag
+     */
+    std::string featureTag(const std::string& macro) const;
+
+    /**
+     * @brief Summarize what the bindings restrict or leave out
+     * @param functions List of FFI functions
+     * @param classes List of FFI classes
+     * @param library_name Name of the C++ library
+     * @param enums Enums declared by the package (see generatePackage)
+     * @param constants Constants declared by the package (see generatePackage)
+     * @return Plain-text report listing main-thread-only and signal-unsafe
+     *         functions, everything that could not be bound, with reasons,
+     *         default arguments the Go bindings require, the number of
+     *         symbols of each optional feature, what is bound per target
+     *         and the shim symbol of every binding
+     */
+    std::string generateReport(
+        const std::vector<FFIFunction>& functions,
+        const std::vector<FFIClass>& classes,
+        const std::string& library_name,
+        const std::vector<FFIEnum>& enums = {},
+        const std::vector<FFIConstant>& constants = {}
+    );
+
+    /**
+     * @brief Generate benchmarks comparing pooled objects against new/delete
+     * @param classes List of FFI classes
+     * @param library_name Name of the C++ library
+     * @return Content of <library>_pool_test.go; empty if no poolable class
+     *         has a default constructor
+     */
+    std::string generatePoolBenchmarks(
+        const std::vector<FFIClass>& classes,
+        const std::string& library_name
+    );
+
+    /**
+     * @brief Generate benchmarks comparing virtual against direct calls
+     * @param classes List of FFI classes
+     * @param library_name Name of the C++ library
+     * @return Content of <library>_devirtualize_test.go, timing each
+     *         argument-free virtual method of a // @devirtualize class both
+     *         ways; empty if there is none or the class lacks a default
+     *         constructor
+     */
+    std::string generateDevirtualizeBenchmarks(
         const std::vector<FFIClass>& classes,
         const std::string& library_name
     );
+
+    /**
+     * @brief Generate benchmarks comparing the small-string stack buffer
+     *        against C heap copies
+     * @param functions List of FFI functions
+     * @param classes List of FFI classes
+     * @param library_name Name of the C++ library
+     * @return Content of <library>_small_strings_test.go, converting a
+     *         string of small_string_size bytes both ways; empty unless
+     *         small_string_size is set and a bound function takes a string
+     */
+    std::string generateSmallStringBenchmarks(
+        const std::vector<FFIFunction>& functions,
+        const std::vector<FFIClass>& classes,
+        const std::string& library_name
+    );
+
+    /**
+     * @brief Generate benchmarks comparing the scratch arena against a C
+     *        allocation per string
+     * @param functions List of FFI functions
+     * @param classes List of FFI classes
+     * @param library_name Name of the C++ library
+     * @return Content of <library>_scratch_arena_test.go, copying three
+     *         strings both ways; empty unless scratch_arena is set and a
+     *         bound function copies its strings into an arena
+     */
+    std::string generateScratchArenaBenchmarks(
+        const std::vector<FFIFunction>& functions,
+        const std::vector<FFIClass>& classes,
+        const std::string& library_name
+    );
+
+    /**
+     * @brief Generate the package's machine-readable table of its bindings
+     * @param functions List of FFI functions
+     * @param classes List of FFI classes
+     * @param library_name Name of the C++ library
+     * @param enums Enums declared alongside
+     * @param constants Constants default arguments may refer to
+     * @return Content of mappings.go: a Mapping per bound C++ function with
+     *         its C shim, Go name, parameter and result ownership, error
+     *         convention and threading, returned by Mappings()
+     */
+    std::string generateMappings(
+        const std::vector<FFIFunction>& functions,
+        const std::vector<FFIClass>& classes,
+        const std::string& library_name,
+        const std::vector<FFIEnum>& enums = {},
+        const std::vector<FFIConstant>& constants = {}
+    );
+
+private:
+    FFIOptions options_;
+    std::vector<FFIEnum> enums_;    // Enums declared by the package being generated
+    std::set<std::string> mirrors_; // Structs it mirrors by value, after layout resolution
+    bool logs_ = false;             // It binds a // @logger setter, so any call may log into Go
+    std::vector<FFIConstant> constants_;  // Constants default arguments may refer to
+    std::map<std::string, std::string> identifiers_;  // Declared entity -> Go identifier, after renames
+    std::vector<std::string> renames_;                // Collision renames, as generateReport lists them
+
+    void resolveNames(const std::vector<FFIFunction>& functions, const std::vector<FFIClass>& classes);
+    std::string identifier(const std::string& entity, const std::string& name) const;
+    std::string typeName(const std::string& cpp_name) const;
+    std::string wrapperName(const FFIFunction& func) const;
+    std::string constructorName(const FFIClass& cls, size_t index) const;
+    std::string methodName(const FFIFunction& method) const;
+    std::string rawPayloadName(const FFIFunction& method) const;
+    std::string cacheField(const FFIFunction& method) const;
+    std::string poolName(const FFIClass& cls) const;
+    std::string enumeratorName(const std::string& ffi_enum, const std::string& enumerator) const;
+    std::set<std::string> referencedConstants(const std::vector<FFIFunction>& functions,
+                                              const std::vector<FFIClass>& classes, bool rules = true) const;
+    std::string invalidatingStatus(const std::string& class_name) const;
+    void validateHandleInvalidation(const std::vector<FFIClass>& classes);
+    static std::string scalarPayloadType(const std::string& cpp_type);
+    const TaggedPayload* taggedPayload(const FFIFunction& func) const;
+    bool lendsHandles(const FFIClass& cls) const;
+    void validateTaggedPayloads(const std::vector<FFIClass>& classes);
+
+    std::string generateEnum(const FFIEnum& ffi_enum, const std::vector<FFIFunction>& functions);
+    std::string enumNamer(const FFIEnum& ffi_enum, const std::vector<FFIFunction>& functions);
+    std::string enumGoType(const std::string& cpp_type) const;
+    std::string generateMirror(const FFIClass& cls, const StructLayout& layout, const std::string& targets);
+    std::string generateConstructor(const FFIClass& cls, const FFIFunction& ctor, size_t index);
+    std::string generateErrorCodeSupport();
+    std::string generateUnexpectedErrorSupport();
+    std::string generateStatusSupport();
+    std::string generateValidationSupport();
+    std::string generateFactorySupport();
+    std::string generateExceptionSupport(bool panics);
+    std::string generateHandleSupport();
+    std::string generateReconnect(const FFIClass& cls);
+    std::string generateBitsetSupport(size_t width);
+    std::string generateDestructor(const FFIClass& cls);
+    std::string generatePool(const FFIClass& cls);
+    std::string generateIteration(const FFIClass& cls);
+    std::string visitorElementType(const FFIFunction& func) const;
+    std::string visitorDoc(const FFIFunction& func, const std::string& recv) const;
+    std::string visitorFallback(const FFIFunction& func) const;
+    std::string collectorName(const FFIFunction& func) const;
+    std::string generateCollector(const FFIFunction& func, const std::string& go_name,
+                                  const std::string& receiver, const std::string& callee);
+    std::string generateVisitorSupport(const std::string& library_name);
+    std::string generateLogger(const FFIFunction& func);
+    std::string generateLoggerSupport(const FFIFunction& func, const std::string& library_name);
+    std::string generateTimeSupport();
+    std::string generatePayload(const FFIClass& cls, const FFIFunction& method);
+    std::string generatePayloadSupport(bool sized);
+    bool passesSmallStrings(const FFIFunction& func) const;
+    bool bindsSmallStrings(const std::vector<FFIFunction>& functions, const std::vector<FFIClass>& classes) const;
+    std::vector<size_t> arenaStrings(const FFIFunction& func) const;
+    bool bindsScratchArena(const std::vector<FFIFunction>& functions, const std::vector<FFIClass>& classes) const;
+    std::string generateScratchArenaSupport();
+    std::string generateSmallStringDirectives(const std::vector<FFIFunction>& functions,
+                                              const std::vector<FFIClass>& classes, const std::string& body) const;
+    std::string generateSmallStringSupport();
+    std::string generateDeclarations(const std::vector<FFIFunction>& functions, const std::vector<FFIClass>& classes,
+                                     const std::string& library_name, bool visitor_export,
+                                     const std::function<bool(const FFIFunction&)>& declared);
+    std::string generateCachedMethod(const FFIClass& cls, const FFIFunction& method);
+    std::string generateStringCacheSupport();
+    bool isCached(const FFIFunction& func);
+    std::vector<FFIFunction> cachedMethods(const FFIClass& cls);
+    std::vector<std::string> clearedCaches(const FFIClass& cls, const FFIFunction& method);
+    std::string generateMethod(const FFIClass& cls, const FFIFunction& method);
+    std::string generateMainThreadDispatch(const FFIFunction& func, const std::string& direct_call);
+    std::string generateMainThreadSupport(const std::string& library_name);
+    std::string generateConstants(const std::vector<FFIFunction>& functions,
+                                  const std::vector<FFIClass>& classes);
+    std::string generateDefaultVariant(const FFIFunction& func, const std::string& go_name,
+                                       const std::string& receiver, const std::string& callee);
+    std::string generateGatedBody(const std::vector<FFIFunction>& functions,
+                                  const std::vector<FFIClass>& classes,
+                                  const std::string& feature, bool signal_unsafe);
+    std::string generateGatedFile(const std::vector<FFIFunction>& functions,
+                                  const std::vector<FFIClass>& classes, const std::string& library_name,
+                                  const std::string& feature, bool signal_unsafe);
+    std::string generateFeatureSupport();
+    std::string configuredFeature(const std::string& macro) const;
+    std::vector<std::pair<std::string, std::vector<std::string>>> targetGroups() const;
+    std::string targetConstraint(const std::vector<std::string>& targets) const;
+    std::string generateTargetBody(const std::vector<FFIFunction>& functions,
+                                   const std::vector<FFIClass>& classes,
+                                   const std::pair<std::string, std::vector<std::string>>& group);
+    std::string generateCall(const FFIFunction& func, const std::string& receiver);
+    std::string generateMethodCall(const FFIClass& cls, const FFIFunction& method, const std::string& recv);
+    std::string marshalCall(const FFIFunction& func, const std::string& receiver,
+                            std::stringstream& prelude);
+
+    std::string argumentName(const FFIFunction& func, size_t index) const;
+    std::string goParameterList(const FFIFunction& func);
+    std::string goParameterType(const FFIFunction& func, const FFIParameter& param);
+    std::string goArgumentNames(const FFIFunction& func);
+    std::string goReturnType(const FFIFunction& func);
+    std::vector<std::string> goResultTypes(const FFIFunction& func);
+    std::string goSignature(const FFIFunction& func);
+    ParamDirection direction(const FFIParameter& param) const;
+    bool isOutput(const FFIFunction& func, const FFIParameter& param) const;
+    bool returnsCommaOk(const FFIFunction& func) const;
+    bool returnsException(const FFIFunction& func) const;
+    bool returnsValidationError(const FFIFunction& func) const;
+    bool returnsFactoryError(const FFIFunction& func) const;
+    std::string validationGuard(const FFIFunction& func);
+    std::string validationDoc(const FFIFunction& func) const;
+    std::string goZero(const std::string& go_type) const;
+    std::string referencedGoType(const FFIParameter& param) const;
+    std::string resultStructName(const FFIFunction& func) const;
+    std::string resultStructBase(const FFIFunction& func) const;
+    std::string goConstantName(const std::string& name) const;
+    std::string goDefault(const FFIParameter& param, int depth = 1) const;
+    size_t firstDefault(const FFIFunction& func) const;
+    std::string generateResultStruct(const FFIFunction& func);
+
+    static std::string goName(const std::string& name);
+    static std::string goParamName(const std::string& name);
+    static std::string goType(const std::string& c_type);
+    static std::string cgoType(const std::string& c_type);
+    static std::string cgoPointer(const std::string& c_type);
 };
 
 /**
@@ -227,21 +1108,174 @@ public:
 class CWrapperGenerator {
 public:
     CWrapperGenerator() = default;
+    explicit CWrapperGenerator(const FFIOptions& options) : options_(options) {}
     ~CWrapperGenerator() = default;
 
+    /**
+     * @brief Generate the C prototype of a shim function
+     * @param func FFI function descriptor
+     * @param linkage Macro prefix for <LIB>_API/<LIB>_CALL decoration (empty for none)
+     * @param type_prefix Prefix for typed handles and enums, e.g. "mylib" declares
+     *        self as mylib_Calculator*; empty for the void* handles of the shim
+     * @return Declaration shared by the C headers and the cgo preamble, followed
+     *         for a std::unique_ptr<T[]> result by that of its delete[] shim
+     */
+    std::string generateDeclaration(const FFIFunction& func, const std::string& linkage = "",
+                                    const std::string& type_prefix = "");
+
+    /**
+     * @brief Get the extern "C" symbol name of a shim function
+     * @param func FFI function descriptor
+     * @return func.c_name if set, otherwise ClassName_method
+     */
+    static std::string shimName(const FFIFunction& func);
+
+    /**
+     * @brief Get the C return type of a shim function
+     * @param func FFI function descriptor
+     * @return C return type (void* for constructors)
+     */
+    static std::string shimReturnType(const FFIFunction& func);
+
+    /**
+     * @brief Get the Windows import/export macro prefix for a library
+     * @param library_name Name of the library
+     * @return Upper-cased identifier, e.g. MYLIB for "mylib"
+     */
+    static std::string macroPrefix(const std::string& library_name);
+
+    /**
+     * @brief Guard code so it only compiles for some targets
+     * @param code Shim code or declarations
+     * @param targets Target triples (FFIFunction::targets); none leaves code as is
+     * @return code within #if of the targets' preprocessor conditions
+     */
+    static std::string targetGuarded(const std::string& code, const std::vector<std::string>& targets);
+
     /**
      * @brief Generate C wrapper for a C++ function
      * @param func FFI function descriptor
+     * @param linkage Macro prefix for <LIB>_CALL decoration (empty for none)
      * @return C wrapper code
      */
-    std::string generateFunctionWrapper(const FFIFunction& func);
+    std::string generateFunctionWrapper(const FFIFunction& func, const std::string& linkage = "");
 
     /**
      * @brief Generate C wrapper for a C++ class
      * @param cls FFI class descriptor
+     * @param linkage Macro prefix for <LIB>_CALL decoration (empty for none)
      * @return C wrapper code
      */
-    std::string generateClassWrapper(const FFIClass& cls);
+    std::string generateClassWrapper(const FFIClass& cls, const std::string& linkage = "");
+
+    /**
+     * @brief Check for the asio-style trailing std::error_code& out-parameter
+     * @param func FFI function descriptor
+     * @return true if the last parameter is a std::error_code&
+     */
+    static bool hasErrorCodeOut(const FFIFunction& func);
+
+    /**
+     * @brief Detect a reference-to-fixed-array return type such as int (&)[4]
+     * @param func FFI function descriptor (c_return_type, if set, is the element's C type)
+     * @param element_c_type Receives the element's C type when non-null
+     * @return Array extent, or 0 if the function does not return an array reference
+     */
+    static size_t arrayReturnExtent(const FFIFunction& func, std::string* element_c_type = nullptr);
+
+    /**
+     * @brief Detect a std::bitset<N> passed by value or const reference
+     * @param cpp_type C++ type spelling
+     * @return N, or 0 if the type is not a bitset. Up to 64 bits cross the
+     *         boundary as one uint64_t, wider sets as an array of ceil(N/64)
+     *         uint64_t words with bit i in word i/64.
+     */
+    static size_t bitsetWidth(const std::string& cpp_type);
+
+    /**
+     * @brief Detect a std::expected<T, E> return type
+     * @param func FFI function descriptor (c_return_type, if set, is T's C type)
+     * @param value_type Receives T when non-null
+     * @param error_type Receives E (std::string or an enum) when non-null
+     * @return true if the function returns std::expected
+     */
+    static bool expectedTypes(const FFIFunction& func, std::string* value_type = nullptr,
+                              std::string* error_type = nullptr);
+
+    /**
+     * @brief Detect a std::unique_ptr<T[]> return type
+     * @param func FFI function descriptor
+     * @param element_type Receives T when non-null
+     * @return true if the function returns an owned array; its shim returns
+     *         the released T* and arrayDeleterName(func) delete[]s it
+     */
+    static bool uniqueArrayReturn(const FFIFunction& func, std::string* element_type = nullptr);
+
+    /**
+     * @brief Get the name of the shim that delete[]s a std::unique_ptr<T[]> result
+     * @param func FFI function descriptor
+     * @return <shim>_delete_array
+     */
+    static std::string arrayDeleterName(const FFIFunction& func);
+
+    /**
+     * @brief Check whether a visitor's elements are copied for its collecting method
+     * @param func FFI function descriptor
+     * @return true for visitors of class elements with a collect name; the
+     *         shim named by visitorCopyName(func) then copies one element
+     */
+    static bool copiesVisitorElements(const FFIFunction& func);
+
+    /**
+     * @brief Get the name of the shim copying a visited class element
+     * @param func FFI function descriptor
+     * @return <shim>_copy_element
+     */
+    static std::string visitorCopyName(const FFIFunction& func);
+
+    /**
+     * @brief Filter functions down to those that get a binding
+     * @param functions Candidate functions (free functions or class members)
+     * @return FFI-compatible functions, without throwing overloads that have
+     *         an error_code twin
+     */
+    static std::vector<FFIFunction> bindableFunctions(const std::vector<FFIFunction>& functions);
+
+    /**
+     * @brief Check whether any bound function passes or returns a struct tm
+     * @param functions Free functions
+     * @param classes Classes, whose shims are checked too
+     * @return true if the declarations need <time.h>
+     */
+    static bool usesTm(const std::vector<FFIFunction>& functions, const std::vector<FFIClass>& classes);
+
+    /**
+     * @brief Check whether a shim catches C++ exceptions
+     * @param func FFI function descriptor
+     * @return true for // @throws functions, and for all others under
+     *         ExceptionBehavior::Panic; such shims report what() through a
+     *         trailing char** exception allocated with malloc(). Destructors,
+     *         iteration steps and field accessors never catch
+     */
+    bool catchesExceptions(const FFIFunction& func) const;
+
+    /**
+     * @brief List the shim functions generated for a class
+     * @param cls FFI class descriptor
+     * @return Constructors, methods, static methods and the destructor; the
+     *         targets of a class apply to those without their own
+     */
+    static std::vector<FFIFunction> shimFunctions(const FFIClass& cls);
+
+    /**
+     * @brief List the direct shims of a // @devirtualize class
+     * @param cls FFI class descriptor
+     * @return A Class_method_direct shim per virtual method, calling
+     *         Class::method without virtual dispatch; empty for other classes
+     * @throws std::invalid_argument if the class is abstract or has no
+     *         bindable virtual methods
+     */
+    static std::vector<FFIFunction> directShims(const FFIClass& cls);
 
     /**
      * @brief Generate C header file
@@ -268,6 +1302,55 @@ public:
         const std::vector<FFIClass>& classes,
         const std::string& library_name
     );
+
+    /**
+     * @brief Generate bindings.h, the public declaration of the shim's ABI
+     * @param functions List of FFI functions
+     * @param classes List of FFI classes
+     * @param library_name Name of the library
+     * @return Declarations of exactly the extern "C" functions of
+     *         generateHeader, with void* handles and plain C types; it
+     *         includes only <stddef.h> and <stdint.h> and compiles as C or C++
+     */
+    std::string generateBindingsHeader(
+        const std::vector<FFIFunction>& functions,
+        const std::vector<FFIClass>& classes,
+        const std::string& library_name
+    );
+
+    /**
+     * @brief Generate the self-contained C API header for non-Go consumers
+     * @param module Functions, classes and enums of the library
+     * @param library_name Name of the library
+     * @return <library>_c.h content with typed opaque handles, enums, mirrored
+     *         struct definitions and Doxygen-documented shim declarations
+     */
+    std::string generateCHeader(const FFIModule& module, const std::string& library_name);
+
+    /**
+     * @brief Generate a Windows module-definition file exporting every shim
+     * @param module Functions and classes of the library
+     * @param library_name Name of the library (DLL base name)
+     * @return .def file content
+     */
+    std::string generateDefFile(const FFIModule& module, const std::string& library_name);
+
+    /**
+     * @brief Generate the Windows calling-convention/linkage macros
+     * @param library_name Name of the library
+     * @param allow_export true to export when <LIB>_BUILD_DLL is defined (shim build)
+     * @return Preprocessor block defining <LIB>_API and <LIB>_CALL
+     */
+    std::string generateLinkageMacros(const std::string& library_name, bool allow_export);
+
+private:
+    FFIOptions options_;
+
+    std::string shimParameterList(const FFIFunction& func, const std::string& type_prefix = "");
+    const std::vector<std::string>* preservedSignals(const FFIFunction& func) const;
+    void checkPreservedSignals(const std::vector<FFIFunction>& functions, const std::vector<FFIClass>& classes) const;
+    void checkSymbols(const std::vector<FFIFunction>& functions, const std::vector<FFIClass>& classes) const;
+    std::string generateLayoutChecks(const std::vector<FFIClass>& classes);
 };
 
 /**
@@ -276,14 +1359,16 @@ public:
 class FFIGenerator {
 public:
     FFIGenerator();
+    explicit FFIGenerator(const FFIOptions& options);
     ~FFIGenerator() = default;
 
     /**
      * @brief Generate FFI bindings for C++ source
      * @param cpp_source C++ source code
      * @param library_name Name of the library
-     * @param target_lang Target language ("rust" or "go")
+     * @param target_lang Target: "go" or "c-header"
      * @return FFI binding code
+     * @throws std::invalid_argument for an unsupported target
      */
     std::string generate(
         const std::string& cpp_source,
@@ -291,6 +1376,37 @@ public:
         const std::string& target_lang
     );
 
+    /**
+     * @brief Generate every file of the Go package from one analysis
+     * @param cpp_source C++ source code
+     * @param library_name Name of the library
+     * @return File name -> content: <library>.go, the signal-unsafe, feature,
+     *         per-target and per-target layout files, mappings.go, with
+     *         benchmarks the pool, devirtualize, small-string and scratch-arena
+     *         benchmark files, and with decls_header generated_decls.h;
+     *         files with nothing to bind are ""
+     */
+    std::map<std::string, std::string> generateGoFiles(
+        const std::string& cpp_source,
+        const std::string& library_name
+    );
+
+    /**
+     * @brief Generate the Go package as one file, for embedding in tools
+     * @param cpp_source C++ source code
+     * @param library_name Name of the library
+     * @return Content of <library>.go: preamble, types, wrappers and the
+     *         support code they use, in package FFIOptions::package_name.
+     *         Benchmarks and mappings.go are left out
+     * @throws std::invalid_argument if the package needs more files: with
+     *         decls_header, per-target layouts or bindings, or signal-unsafe
+     *         or feature wrappers behind build tags
+     */
+    std::string generateSingleGoFile(
+        const std::string& cpp_source,
+        const std::string& library_name
+    );
+
     /**
      * @brief Generate C wrapper layer
      * @param cpp_source C++ source code
@@ -302,13 +1418,159 @@ public:
         const std::string& library_name
     );
 
+    /**
+     * @brief Generate bindings.h declaring the shim's extern "C" functions
+     * @param cpp_source C++ source code
+     * @param library_name Name of the library
+     * @return Header content (see CWrapperGenerator::generateBindingsHeader)
+     */
+    std::string generateBindingsHeader(
+        const std::string& cpp_source,
+        const std::string& library_name
+    );
+
+    /**
+     * @brief Generate a Windows .def file exporting the shim symbols
+     * @param cpp_source C++ source code
+     * @param library_name Name of the library
+     * @return .def file content
+     */
+    std::string generateDefFile(
+        const std::string& cpp_source,
+        const std::string& library_name
+    );
+
+    /**
+     * @brief Report gated and unbindable functions of a C++ source
+     * @param cpp_source C++ source code
+     * @param library_name Name of the library
+     * @return Report text (see GoFFIGenerator::generateReport)
+     */
+    std::string generateReport(
+        const std::string& cpp_source,
+        const std::string& library_name
+    );
+
 private:
+    FFIOptions options_;
     FFIAnalyzer analyzer_;
     RustFFIGenerator rust_generator_;
     GoFFIGenerator go_generator_;
     CWrapperGenerator c_wrapper_generator_;
 };
 
+/**
+ * @brief End-to-end fixture: a C++ library and Go tests of its bindings
+ *
+ * A fixture directory holds fixture.conf, the library sources and any
+ * number of *_test.go files. fixture.conf is a list of "key = value" lines:
+ *   library  = name of the library; its bindings come from <library>.h
+ *   modules  = C++20 module interface units declaring more of its API,
+ *              compiled before the sources; <library>.h is then optional
+ *              [default: none]
+ *   sources  = files compiled into the library [default: every *.cpp]
+ *   cxxflags = compiler flags for the library and shim [default: -std=c++17]
+ *   validate_enums = true to generate the enum argument checks [default: false]
+ *   cached_strings = true to cache the strings of // @cached methods [default: false]
+ *   bounds_check = true to check the indexes of operator[] accessors [default: false]
+ *   default_exception_behavior = abort or panic [default: abort]
+ *   validation_failure = error or panic, for arguments outside their
+ *              documented constraints [default: error]
+ *   constraint = a function, a parameter, then the constraint replacing
+ *              its documented one; one line per parameter [default: none]
+ *   unvalidated = functions whose constraints are not checked [default: none]
+ *   invalidating_errors = a class, then the status codes invalidating its
+ *              handle; one line per class [default: none]
+ *   reconnect_factory = a class, then the static method its Reconnect calls
+ *   delete_invalidated = classes whose invalidated objects Delete and
+ *              Reconnect still free [default: none]
+ *   preserve_signals = a function, then the signals chained to its handlers;
+ *              one line per function [default: none]
+ *   small_string_size = longest string argument passed from a stack buffer
+ *              [default: 0, always allocate]
+ *   scratch_arena = true to copy the string arguments of a call into one
+ *              C allocation [default: false]
+ *   windows_dll_import = true to declare the shims as imported from a DLL
+ *              [default: false]
+ *   symbol_prefix = prefix of the shim symbols [default: the library name]
+ *   cgo_prologue, cgo_epilogue, shim_prologue = a file of the fixture whose
+ *              text is injected as the FFIOptions of that name [default: none]
+ *   features = optional features, MACRO or MACRO:tag each; go test runs with
+ *              the tags of those whose macro cxxflags defines [default: none]
+ */
+struct SelfTestFixture {
+    std::string name;                   // Directory name
+    std::string path;                   // Fixture directory
+    std::string library_name;
+    std::vector<std::string> sources;   // Relative to path
+    std::string cxxflags = "-std=c++17";
+    bool validate_enums = false;
+    bool cached_strings = false;
+    bool bounds_check = false;
+    bool scratch_arena = false;
+    bool windows_dll_import = false;
+    bool race = false;                  // go test runs with -race
+    size_t small_string_size = 0;
+    std::string symbol_prefix;
+    std::string cgo_prologue;           // Injected text, read from the file fixture.conf names
+    std::string cgo_epilogue;
+    std::string shim_prologue;
+    ExceptionBehavior default_exception_behavior = ExceptionBehavior::Abort;
+    ValidationFailure validation_failure = ValidationFailure::Error;
+    std::map<std::string, std::map<std::string, std::string>> constraints;  // Function -> parameter -> phrasing
+    std::set<std::string> unvalidated;
+    std::map<std::string, std::string> features;   // Feature macro -> Go build tag ("" derives it)
+    std::map<std::string, HandleInvalidation> handle_invalidation;   // Class -> rule
+    std::map<std::string, TaggedPayload> tagged_payloads;           // Method -> payload types
+    std::map<std::string, std::vector<std::string>> preserve_signals;  // Function -> chained signals
+    std::vector<std::string> modules;   // Module interface units, relative to path
+};
+
+/**
+ * @brief Outcome of building and testing one fixture
+ */
+struct SelfTestResult {
+    std::string fixture;
+    bool passed = false;
+    std::string stage;      // Failed stage: "config", "generate", "compile" or "go test"
+    std::string output;     // Compiler or go test output of the failed stage
+    std::string work_dir;   // Kept on failure for inspection
+};
+
+/**
+ * @brief Runs fixtures through the whole pipeline: analyze, generate the
+ *        shim and Go package, compile the library, then go test
+ *
+ * The compiler and go tool are taken from the CXX and GO environment
+ * variables, defaulting to c++ and go.
+ */
+class SelfTestRunner {
+public:
+    SelfTestRunner() = default;
+    explicit SelfTestRunner(const FFIOptions& options) : options_(options) {}
+
+    /**
+     * @brief Read a fixture directory
+     * @throws std::runtime_error if fixture.conf is missing or invalid
+     */
+    static SelfTestFixture loadFixture(const std::string& dir);
+
+    /**
+     * @brief Build and test one fixture in a fresh temporary directory
+     */
+    SelfTestResult run(const SelfTestFixture& fixture);
+
+    /**
+     * @brief Run every subdirectory of fixtures_dir that has a fixture.conf,
+     *        in name order
+     * @throws std::runtime_error if fixtures_dir is not a directory
+     */
+    std::vector<SelfTestResult> runAll(const std::string& fixtures_dir);
+
+private:
+    FFIOptions options_;
+};
+
 } // namespace ffi
 } // namespace hybrid_transpiler
 
@@ -312,6 +312,10 @@ public:
     std::vector<TemplateParameter> template_parameters;
     TemplateSpecialization specialization;
 
+    // Conditions deciding whether the overload exists (SFINAE): each
+    // std::enable_if or std::void_t it uses, and its requires-clause
+    std::vector<std::string> constraints;
+
     // Threading information
     std::vector<ThreadInfo> threads_created;
     std::vector<LockInfo> lock_scopes;
@@ -16,7 +16,8 @@ class CodeGenerator;
  */
 enum class TargetLanguage {
     Rust,
-    Go
+    Go,
+    CHeader     // Standalone C API header over the FFI shim
 };
 
 /**
@@ -30,6 +31,15 @@ struct TranspilerOptions {
     bool generate_tests = false;
     bool verbose = false;           // Verbose output
     bool quiet = false;             // Minimal output
+    bool emit_def_file = false;     // Also write <stem>.def (CHeader target)
+    std::string symbol_prefix;      // Shim symbol prefix, "" for the library name (CHeader target)
+    bool legacy_symbol_names = false;  // Keep the unprefixed <Class>_<method> shim names
+    bool windows_dll_import = false;   // Declare the shims imported from a Windows DLL
+    std::string cgo_prologue;       // Text injected into the cgo preamble (single_file)
+    std::string cgo_epilogue;
+    std::string shim_prologue;      // Text injected into the shim (CHeader, single_file)
+    bool single_file = false;       // Go cgo bindings in one file at output_path (Go target)
+    std::string package_name;       // Their Go package, "" for the library name
     std::string output_path;
 };
 
@@ -68,6 +78,9 @@ private:
 
     bool parseSourceFile(const std::string& input_path);
     bool generateCode(const std::string& output_path);
+    bool generateCHeader(const std::string& input_path);
+    bool generateSingleGoFile(const std::string& input_path);
+    bool writeShim(const std::string& source, const std::string& library_name);
 };
 
 } // namespace hybrid
@@ -0,0 +1,2082 @@
+/**
+ * @file c_wrapper_gen.cpp
+ * @brief extern "C" shim and header generator
+ *
+ * The shim exposes C++ classes through opaque void* handles:
+ *   Calculator_new(...)      -> new Calculator(...)
+ *   Calculator_delete(self)  -> delete self
+ *   Calculator_add(self, v)  -> self->add(v)
+ */
+
+#include "ffi.h"
+#include <algorithm>
+#include <cctype>
+#include <iterator>
+#include <regex>
+#include <sstream>
+
+namespace hybrid_transpiler {
+namespace ffi {
+
+namespace {
+
+std::string parameterName(const FFIParameter& param, size_t index) {
+    return param.name.empty() ? "arg" + std::to_string(index) : param.name;
+}
+
+/**
+ * Code compiled only when the macro of an optional feature is defined
+ */
+std::string featureGuarded(const std::string& code, const std::string& macro) {
+    return "#ifdef " + macro + "\n" + code + "#endif // " + macro + "\n";
+}
+
+/**
+ * Targets' preprocessor conditions joined into one
+ */
+std::string targetCondition(const std::vector<std::string>& targets) {
+    std::string condition;
+    for (const auto& triple : targets) {
+        std::string target = ABIProfile::fromTriple(triple).preprocessorCondition();
+        condition += condition.empty() ? "" : " || ";
+        condition += targets.size() > 1 ? "(" + target + ")" : target;
+    }
+    return condition;
+}
+
+/**
+ * Wrap shim code in the namespace of what it binds, so names in it resolve
+ * as in that declaration; its extern "C" functions keep their symbols
+ */
+std::string inNamespace(const std::string& code, const std::string& cpp_namespace) {
+    if (cpp_namespace.empty() || code.empty()) {
+        return code;
+    }
+    return "namespace " + cpp_namespace + " {\n" + code + "} // namespace " + cpp_namespace + "\n";
+}
+
+/**
+ * Mirrored struct written by the callee, which the Go caller passes as a
+ * pointer to its own value
+ */
+bool isStructOutput(const FFIParameter& param) {
+    return param.c_type == "void*" && param.direction == ParamDirection::Out;
+}
+
+/**
+ * std::string passed by non-const reference, which crosses as a char* and
+ * its length and is filled through the slot declared by the wrapper
+ */
+bool isStringReference(const FFIParameter& param) {
+    return param.c_type == "char**" && param.direction != ParamDirection::In;
+}
+
+/**
+ * Result returned by lvalue reference (int32_t&, Person&) and crossing as a
+ * pointer to the referenced object, which stays owned by C++
+ */
+bool returnsReference(const FFIFunction& func) {
+    std::string type = func.return_type;
+    while (!type.empty() && type.back() == ' ') {
+        type.pop_back();
+    }
+    return type.size() > 1 && type.back() == '&' && type[type.size() - 2] != '&' &&
+           type.find('(') == std::string::npos && !func.c_return_type.empty() && func.c_return_type.back() == '*' &&
+           func.c_return_type != "const char*" && func.c_return_type != "char*";
+}
+
+/**
+ * std::wstring, by value or reference, which crosses as UTF-8 like
+ * std::string and is converted by the shim
+ */
+bool isWideString(const FFIParameter& param) {
+    std::string type = param.cpp_type;
+    if (type.compare(0, 6, "const ") == 0) {
+        type = type.substr(6);
+    }
+    type = type.substr(0, type.find_last_not_of("& ") + 1);
+    return type == "std::wstring" && (param.c_type == "const char*" || param.c_type == "char**");
+}
+
+/**
+ * Expression passing a C parameter on to the C++ callee
+ */
+std::string argumentExpression(const FFIParameter& param, size_t index) {
+    std::string name = parameterName(param, index);
+    bool is_struct = param.c_type == "void*";
+
+    // Mirrored struct outputs are filled through the slot declared by the wrapper
+    if (isStructOutput(param)) {
+        return (param.is_reference ? "*" : "") + name + "_slot.get()";
+    }
+    if (isStringReference(param)) {
+        return name + "_slot.get()";
+    }
+
+    // Scalars passed by non-const reference arrive as pointers, like a writable struct tm*
+    if (!is_struct && param.direction != ParamDirection::In) {
+        return param.is_pointer ? name : "*" + name;
+    }
+
+    if (param.is_enum) {
+        return "static_cast<" + param.cpp_type + ">(" + name + ")";
+    }
+    if (isWideString(param)) {
+        return "wideFromUtf8(" + name + ")";
+    }
+
+    size_t bits = CWrapperGenerator::bitsetWidth(param.cpp_type);
+    if (bits > 64) {
+        return "bitsetFromWords<" + std::to_string(bits) + ">(" + name + ")";
+    }
+    if (bits) {
+        return "std::bitset<" + std::to_string(bits) + ">(" + name + ")";
+    }
+
+    // Class handles arrive as void* and are cast back to the C++ type
+    if (param.c_type == "void*" || param.c_type == "const void*") {
+        if (param.is_reference) {
+            std::string pointee = param.cpp_type.substr(0, param.cpp_type.find('&'));
+            return "*static_cast<" + pointee + "*>(" + name + ")";
+        }
+        if (!param.cpp_type.empty() && param.cpp_type != param.c_type) {
+            return "static_cast<" + param.cpp_type + ">(" + name + ")";
+        }
+    }
+
+    return name;
+}
+
+/**
+ * C type of a parameter in the typed C API header: enums and handles use
+ * the prefixed typedefs, which are identical to the shim's integer/void* types
+ */
+std::string typedCType(const FFIParameter& param, const std::string& type_prefix) {
+    if (type_prefix.empty()) {
+        return param.c_type;
+    }
+    if (param.is_enum) {
+        return type_prefix + "_" + param.cpp_type;
+    }
+    if (param.c_type == "void*" || param.c_type == "const void*") {
+        std::string base = param.cpp_type;
+        if (base.compare(0, 6, "const ") == 0) {
+            base = base.substr(6);
+        }
+        while (!base.empty() && (base.back() == '&' || base.back() == '*' || base.back() == ' ')) {
+            base.pop_back();
+        }
+        if (!base.empty() && base != "void") {
+            return (param.c_type == "const void*" ? "const " : "") + type_prefix + "_" + base + "*";
+        }
+    }
+    return param.c_type;
+}
+
+/**
+ * Doxygen block for the C API header; empty input yields no comment
+ */
+std::string docComment(const std::string& doc) {
+    if (doc.empty()) {
+        return "";
+    }
+    if (doc.find('\n') == std::string::npos) {
+        return "/** " + doc + " */\n";
+    }
+
+    std::stringstream ss(doc);
+    std::stringstream out;
+    std::string line;
+    out << "/**\n";
+    while (std::getline(ss, line)) {
+        out << (line.empty() ? " *" : " * " + line) << "\n";
+    }
+    out << " */\n";
+    return out.str();
+}
+
+const char* const kExceptionNote =
+    "@note A C++ exception leaves its what() message in *exception, allocated with malloc(), "
+    "and the result is zero; release the message with free().";
+
+/**
+ * Doc note for the message parameter that replaces a printf format
+ */
+std::string printfNote(const FFIFunction& func, const std::string& doc) {
+    if (!func.printf_format) {
+        return "";
+    }
+    return std::string(doc.empty() ? "" : "\n") + "@note " + parameterName(func.parameters.back(), func.parameters.size() - 1) +
+           " is printed as is, not as a format; format the message before the call.";
+}
+
+/**
+ * Doc note on releasing a std::unique_ptr<T[]> result
+ */
+std::string arrayNote(const FFIFunction& func, const std::string& doc) {
+    if (!CWrapperGenerator::uniqueArrayReturn(func)) {
+        return "";
+    }
+    return std::string(doc.empty() ? "" : "\n") + "@note The array holds " + func.array_size +
+           " elements; release it with " + CWrapperGenerator::arrayDeleterName(func) + "().";
+}
+
+/**
+ * Doc note on releasing the strings written to std::string& parameters
+ */
+std::string stringNote(const FFIFunction& func, const std::string& doc) {
+    std::string note;
+    for (size_t i = 0; i < func.parameters.size(); ++i) {
+        if (isStringReference(func.parameters[i])) {
+            std::string name = parameterName(func.parameters[i], i);
+            note += std::string(doc.empty() && note.empty() ? "" : "\n") + "@note *" + name + " (NULL or *" + name +
+                    "_len bytes from malloc()) is freed and set to a malloc()ed copy of the result, NULL when "
+                    "empty; release it with free().";
+        }
+    }
+    return note;
+}
+
+/**
+ * Doc note on the encoding of std::wstring parameters
+ */
+std::string wideStringNote(const FFIFunction& func, const std::string& doc) {
+    std::string names;
+    for (size_t i = 0; i < func.parameters.size(); ++i) {
+        if (isWideString(func.parameters[i])) {
+            names += (names.empty() ? "" : ", ") + parameterName(func.parameters[i], i);
+        }
+    }
+    if (names.empty()) {
+        return "";
+    }
+    return std::string(doc.empty() ? "" : "\n") + "@note " + names +
+           ": UTF-8, converted from and to the std::wstring of the C++ call (UTF-16 on Windows, UTF-32 elsewhere).";
+}
+
+/**
+ * Destructor shim of a bound class
+ */
+std::string deleterName(const std::vector<FFIClass>& classes, const std::string& class_name) {
+    for (const auto& cls : classes) {
+        if (cls.name == class_name && !cls.symbol_stem.empty()) {
+            return cls.symbol_stem + "_delete";
+        }
+    }
+    return class_name + "_delete";
+}
+
+/**
+ * Doc note on who releases what a factory returns
+ */
+std::string factoryNote(const FFIFunction& func, const std::string& doc, const std::vector<FFIClass>& classes) {
+    if (func.factory.empty()) {
+        return "";
+    }
+    return std::string(doc.empty() ? "" : "\n") + "@note " +
+           (func.borrowed ? "The library keeps ownership of the result; do not release it."
+                          : "The caller owns the result; release it with " + deleterName(classes, func.factory) + "().");
+}
+
+/**
+ * Doc note on a result C++ returned by reference
+ */
+std::string referenceNote(const FFIFunction& func, const std::string& doc) {
+    if (!returnsReference(func)) {
+        return "";
+    }
+    return std::string(doc.empty() ? "" : "\n") +
+           "@note The result points to the object C++ returned by reference, which C++ keeps ownership of; "
+           "do not release it.";
+}
+
+/**
+ * Wrap the body of a shim definition in try/catch, reporting what() through
+ * the trailing exception parameter; a non-void shim then returns zero
+ */
+std::string catchingWrapper(const std::string& wrapper, const std::string& return_type) {
+    size_t open = wrapper.find("{\n") + 2;
+    size_t close = wrapper.rfind("}\n");
+    std::string body;
+    std::istringstream lines(wrapper.substr(open, close - open));
+    for (std::string line; std::getline(lines, line);) {
+        body += "    " + line + "\n";
+    }
+
+    std::stringstream ss;
+    ss << wrapper.substr(0, open);
+    ss << "    *exception = nullptr;\n";
+    ss << "    try {\n" << body;
+    ss << "    } catch (const std::exception& e) {\n";
+    ss << "        *exception = strdup(e.what());\n";
+    ss << "    } catch (...) {\n";
+    ss << "        *exception = strdup(\"unknown C++ exception\");\n";
+    ss << "    }\n";
+    if (return_type != "void") {
+        ss << "    return {};\n";
+    }
+    ss << "}\n";
+    return ss.str();
+}
+
+std::string stripSpaces(const std::string& text) {
+    std::string result;
+    for (char c : text) {
+        if (!std::isspace(static_cast<unsigned char>(c))) {
+            result += c;
+        }
+    }
+    return result;
+}
+
+/**
+ * Class visited through a pointer or reference: const Node* -> Node
+ */
+std::string elementClass(const std::string& cpp_type) {
+    std::string name = cpp_type.substr(0, cpp_type.find_first_of("&*"));
+    return stripSpaces(name.compare(0, 6, "const ") == 0 ? name.substr(6) : name);
+}
+
+/**
+ * Doc note on the callback of a visitor and copying what it visits, or on
+ * the handler a logger setter installs
+ */
+std::string visitorNote(const FFIFunction& func, const std::string& doc, const std::vector<FFIClass>& classes) {
+    if (!func.logger.callback.empty()) {
+        return std::string(doc.empty() ? "" : "\n") + "@note " + func.logger.callback + " gets the level and text of "
+               "each message, possibly from other threads; NULL uninstalls it.";
+    }
+    if (func.visitor.callback.empty()) {
+        return "";
+    }
+    std::string note = std::string(doc.empty() ? "" : "\n") + "@note " + func.visitor.callback;
+    if (!func.visitor.policy.empty()) {
+        return note + " stands in for " + func.visitor.policy + ", called with its argument, borrowed for the call, "
+                      "and " + func.visitor.context + "; its result goes back to " + func.name + ".";
+    }
+    note += " is called with each element, borrowed for the call, and " + func.visitor.context;
+    note += !func.visitor.result.cpp_type.empty() ? "; its " + func.visitor.result.cpp_type + " goes back to " +
+                                                        func.name + "."
+            : func.visitor.stops ? "; returning false stops the visit."
+                                 : "; its result is ignored.";
+    if (CWrapperGenerator::copiesVisitorElements(func)) {
+        note += " " + CWrapperGenerator::visitorCopyName(func) + "() copies an element, released with " +
+                deleterName(classes, elementClass(func.visitor.element.cpp_type)) + "().";
+    }
+    return note;
+}
+
+/**
+ * Captureless lambda adapting the C++ callback signature to a VisitorCall,
+ * which arrives as its void* context; a template policy gets a functor
+ * holding the VisitorCall instead
+ */
+std::string visitorAdapter(const FFIFunction& func) {
+    const FFIParameter& element = func.visitor.element;
+    std::string pass = element.cpp_type == "const std::string&"      ? "element.c_str()"
+                     : element.cpp_type.find('*') != std::string::npos ? "element"
+                                                                       : "&element";
+    if (!func.visitor.policy.empty()) {
+        return "[&visitor_call](" + element.cpp_type + " element) -> bool { return visitor_call(" + pass + "); }";
+    }
+    std::string type;
+    for (const auto& param : func.parameters) {
+        if (param.name == func.visitor.callback) {
+            type = param.cpp_type;
+        }
+    }
+    bool context_first = stripSpaces(type.substr(type.find("(*)") + 3)).compare(0, 7, "(void*,") == 0;
+    std::string result = type.substr(0, type.find("(*)"));
+    while (!result.empty() && result.back() == ' ') {
+        result.pop_back();
+    }
+
+    std::string params = context_first ? "void* data, " + element.cpp_type + " element"
+                                       : element.cpp_type + " element, void* data";
+    std::string call = "(*static_cast<VisitorCall*>(data))(" + pass + ")";
+    if (!func.visitor.result.cpp_type.empty()) {
+        call = "return static_cast<" + result + ">(" + call + ")";
+    } else if (func.visitor.stops) {
+        call = "return " + call;
+    }
+    return "[](" + params + ") -> " + result + " { " + call + "; }";
+}
+
+std::string cIdentifier(const std::string& name) {
+    std::string result;
+    for (char c : name) {
+        result += std::isalnum(static_cast<unsigned char>(c)) ? c : '_';
+    }
+    return result;
+}
+
+std::string argumentList(const FFIFunction& func) {
+    bool error_code_out = CWrapperGenerator::hasErrorCodeOut(func);
+    std::stringstream ss;
+    std::string separator;
+    for (size_t i = 0; i < func.parameters.size(); ++i) {
+        bool policy_context = !func.visitor.policy.empty() && func.parameters[i].name == func.visitor.context;
+        if (policy_context) {
+            continue;
+        }
+        ss << separator;
+        separator = ", ";
+        if (error_code_out && i + 1 == func.parameters.size()) {
+            ss << "ec";
+        } else if (!func.visitor.callback.empty() && func.parameters[i].name == func.visitor.callback) {
+            ss << visitorAdapter(func);
+        } else if (func.parameters[i].name == func.visitor.context && !func.visitor.callback.empty()) {
+            ss << "&visitor_call";
+        } else if (func.printf_format && i + 1 == func.parameters.size()) {
+            // Already formatted: a literal format keeps any % in the message inert
+            ss << "\"%s\", " << argumentExpression(func.parameters[i], i);
+        } else {
+            ss << argumentExpression(func.parameters[i], i);
+        }
+    }
+    return ss.str();
+}
+
+/**
+ * Parameter list without a trailing std::error_code&, used to pair a
+ * throwing overload with its error_code twin
+ */
+std::string overloadKey(const FFIFunction& func) {
+    std::string key = func.class_name + "::" + func.name + "(";
+    size_t count = func.parameters.size();
+    if (CWrapperGenerator::hasErrorCodeOut(func)) {
+        count--;
+    }
+    for (size_t i = 0; i < count; ++i) {
+        key += stripSpaces(func.parameters[i].cpp_type) + ",";
+    }
+    return key + ")";
+}
+
+/**
+ * What the shim symbols of a class's members start with
+ */
+std::string symbolStem(const FFIClass& cls) {
+    return cls.symbol_stem.empty() ? cls.name : cls.symbol_stem;
+}
+
+/**
+ * Every shim a class exports: its shim functions, with any direct shims
+ * ahead of the destructor
+ */
+std::vector<FFIFunction> exportedShims(const FFIClass& cls) {
+    std::vector<FFIFunction> shims = CWrapperGenerator::shimFunctions(cls);
+    std::vector<FFIFunction> direct = CWrapperGenerator::directShims(cls);
+    shims.insert(shims.end() - (shims.empty() ? 0 : 1), direct.begin(), direct.end());
+    return shims;
+}
+
+/**
+ * Signals FFIOptions::preserve_signals may chain, the POSIX ones a library
+ * installs handlers for
+ */
+const char* const kChainableSignals[] = {
+    "SIGABRT", "SIGALRM", "SIGBUS", "SIGCHLD", "SIGCONT", "SIGFPE", "SIGHUP", "SIGILL", "SIGINT",
+    "SIGIO", "SIGPIPE", "SIGPROF", "SIGQUIT", "SIGSEGV", "SIGSYS", "SIGTERM", "SIGTRAP", "SIGTSTP",
+    "SIGTTIN", "SIGTTOU", "SIGURG", "SIGUSR1", "SIGUSR2", "SIGVTALRM", "SIGWINCH", "SIGXCPU", "SIGXFSZ",
+};
+
+std::string qualifiedName(const FFIFunction& func) {
+    return func.class_name.empty() ? func.name : func.class_name + "::" + func.name;
+}
+
+} // namespace
+
+bool CWrapperGenerator::hasErrorCodeOut(const FFIFunction& func) {
+    if (func.parameters.empty()) {
+        return false;
+    }
+    return stripSpaces(func.parameters.back().cpp_type) == "std::error_code&";
+}
+
+size_t CWrapperGenerator::arrayReturnExtent(const FFIFunction& func, std::string* element_c_type) {
+    std::smatch match;
+    static const std::regex array_ref(R"((.+?)\s*\(\s*&\s*\)\s*\[\s*(\d+)\s*\])");
+    if (!std::regex_match(func.return_type, match, array_ref)) {
+        return 0;
+    }
+
+    if (element_c_type) {
+        *element_c_type = func.c_return_type.empty() ? match[1].str() : func.c_return_type;
+        if (element_c_type->find("const ") == 0) {
+            *element_c_type = element_c_type->substr(6);
+        }
+    }
+    return std::stoul(match[2].str());
+}
+
+bool CWrapperGenerator::uniqueArrayReturn(const FFIFunction& func, std::string* element_type) {
+    std::smatch match;
+    static const std::regex unique_array(R"((?:std::)?unique_ptr<(.+)\[\]>)");
+    std::string type = stripSpaces(func.return_type);
+    if (!std::regex_match(type, match, unique_array)) {
+        return false;
+    }
+    if (element_type) {
+        *element_type = match[1].str();
+    }
+    return true;
+}
+
+std::string CWrapperGenerator::arrayDeleterName(const FFIFunction& func) {
+    return shimName(func) + "_delete_array";
+}
+
+bool CWrapperGenerator::copiesVisitorElements(const FFIFunction& func) {
+    return func.visitor.element.c_type == "void*" && !func.visitor.collect.empty();
+}
+
+std::string CWrapperGenerator::visitorCopyName(const FFIFunction& func) {
+    return shimName(func) + "_copy_element";
+}
+
+size_t CWrapperGenerator::bitsetWidth(const std::string& cpp_type) {
+    std::smatch match;
+    static const std::regex bitset(R"((?:const)?std::bitset<(\d+)>&?)");
+    std::string type = stripSpaces(cpp_type);
+    if (!std::regex_match(type, match, bitset) || (type.back() == '&' && type.compare(0, 5, "const") != 0)) {
+        return 0;
+    }
+    return std::stoul(match[1].str());
+}
+
+bool CWrapperGenerator::expectedTypes(const FFIFunction& func, std::string* value_type, std::string* error_type) {
+    std::string type = stripSpaces(func.return_type);
+    const std::string prefix = "std::expected<";
+    if (type.compare(0, prefix.size(), prefix) != 0 || type.back() != '>') {
+        return false;
+    }
+
+    // Split T and E at the top-level comma
+    std::string args = type.substr(prefix.size(), type.size() - prefix.size() - 1);
+    int depth = 0;
+    for (size_t i = 0; i < args.size(); ++i) {
+        if (args[i] == '<') depth++;
+        else if (args[i] == '>') depth--;
+        else if (args[i] == ',' && depth == 0) {
+            if (value_type) *value_type = args.substr(0, i);
+            if (error_type) *error_type = args.substr(i + 1);
+            return true;
+        }
+    }
+    return false;
+}
+
+std::vector<FFIFunction> CWrapperGenerator::bindableFunctions(const std::vector<FFIFunction>& functions) {
+    // A throwing overload is dropped when an error_code twin exists, since
+    // both would map to the same Go name
+    std::vector<std::string> error_code_keys;
+    for (const auto& func : functions) {
+        if (func.can_use_ffi && hasErrorCodeOut(func)) {
+            error_code_keys.push_back(overloadKey(func));
+        }
+    }
+
+    std::vector<FFIFunction> bindable;
+    for (const auto& func : functions) {
+        if (!func.can_use_ffi) continue;
+        if (!hasErrorCodeOut(func) &&
+            std::find(error_code_keys.begin(), error_code_keys.end(), overloadKey(func)) != error_code_keys.end()) {
+            continue;
+        }
+        bindable.push_back(func);
+    }
+    return bindable;
+}
+
+bool CWrapperGenerator::usesTm(const std::vector<FFIFunction>& functions, const std::vector<FFIClass>& classes) {
+    auto uses = [](const FFIFunction& func) {
+        if (func.c_return_type.find("struct tm") != std::string::npos) {
+            return true;
+        }
+        return std::any_of(func.parameters.begin(), func.parameters.end(), [](const FFIParameter& param) {
+            return param.c_type.find("struct tm") != std::string::npos;
+        });
+    };
+    std::vector<FFIFunction> bound = bindableFunctions(functions);
+    if (std::any_of(bound.begin(), bound.end(), uses)) {
+        return true;
+    }
+    for (const auto& cls : classes) {
+        std::vector<FFIFunction> shims = shimFunctions(cls);
+        if (std::any_of(shims.begin(), shims.end(), uses)) {
+            return true;
+        }
+    }
+    return false;
+}
+
+bool CWrapperGenerator::catchesExceptions(const FFIFunction& func) const {
+    // Free functions bound under their own name have no shim to catch in,
+    // and a logger setter only installs its handler
+    if (func.is_destructor || !func.iteration.empty() || !func.field_name.empty() || !func.logger.callback.empty() ||
+        (func.class_name.empty() && shimName(func) == func.name)) {
+        return false;
+    }
+    return func.throws || options_.default_exception_behavior == ExceptionBehavior::Panic;
+}
+
+const std::vector<std::string>* CWrapperGenerator::preservedSignals(const FFIFunction& func) const {
+    if (func.is_destructor || !func.iteration.empty() || !func.field_name.empty() || func.direct) {
+        return nullptr;
+    }
+    auto rule = options_.preserve_signals.find(qualifiedName(func));
+    return rule == options_.preserve_signals.end() ? nullptr : &rule->second;
+}
+
+void CWrapperGenerator::checkPreservedSignals(const std::vector<FFIFunction>& functions,
+                                              const std::vector<FFIClass>& classes) const {
+    std::vector<FFIFunction> shims;
+    for (const auto& func : bindableFunctions(functions)) {
+        if (shimName(func) != func.name) {
+            shims.push_back(func);
+        }
+    }
+    for (const auto& cls : classes) {
+        for (const auto& shim : shimFunctions(cls)) {
+            shims.push_back(shim);
+        }
+    }
+    for (const auto& rule : options_.preserve_signals) {
+        bool found = std::any_of(shims.begin(), shims.end(), [&](const FFIFunction& shim) {
+            return preservedSignals(shim) == &rule.second;
+        });
+        if (!found) {
+            throw std::invalid_argument("preserve_signals: " + rule.first +
+                                        " is not a bound function with a shim; extern \"C\" functions "
+                                        "bound under their own name have none");
+        }
+        for (const auto& signal : rule.second) {
+            if (std::find(std::begin(kChainableSignals), std::end(kChainableSignals), signal) ==
+                std::end(kChainableSignals)) {
+                throw std::invalid_argument("preserve_signals: " + rule.first + ": " + signal +
+                                            " is not a POSIX signal name such as SIGPIPE");
+            }
+        }
+    }
+}
+
+void CWrapperGenerator::checkSymbols(const std::vector<FFIFunction>& functions,
+                                     const std::vector<FFIClass>& classes) const {
+    // Symbol -> the bindings that claimed it, for targets of their own or
+    // all of them; per-target variants of a function share their symbol
+    std::map<std::string, std::vector<std::pair<std::string, std::vector<std::string>>>> owners;
+    auto overlap = [](const std::vector<std::string>& a, const std::vector<std::string>& b) {
+        return a.empty() || b.empty() || std::any_of(a.begin(), a.end(), [&b](const std::string& triple) {
+            return std::find(b.begin(), b.end(), triple) != b.end();
+        });
+    };
+    auto claim = [&](const std::string& symbol, const std::string& owner, const std::vector<std::string>& targets) {
+        for (const auto& claimed : owners[symbol]) {
+            if (overlap(claimed.second, targets)) {
+                throw std::invalid_argument("shim symbol " + symbol + " is generated for both " + claimed.first +
+                                            " and " + owner +
+                                            (owner.find('(') == std::string::npos ? "" : ", which C cannot overload"));
+            }
+        }
+        owners[symbol].push_back({owner, targets});
+    };
+    auto claimShim = [&claim](const FFIFunction& func) {
+        std::string owner = (func.cpp_namespace.empty() ? "" : func.cpp_namespace + "::") + qualifiedName(func) + "(";
+        for (size_t i = 0; i < func.parameters.size(); ++i) {
+            owner += (i > 0 ? ", " : "") + func.parameters[i].cpp_type;
+        }
+        owner += ")";
+        claim(shimName(func), owner, func.targets);
+        if (uniqueArrayReturn(func)) {
+            claim(arrayDeleterName(func), owner + " (its delete[])", func.targets);
+        }
+        if (copiesVisitorElements(func)) {
+            claim(visitorCopyName(func), owner + " (its element copy)", func.targets);
+        }
+    };
+    for (const auto& func : bindableFunctions(functions)) {
+        claimShim(func);
+    }
+    for (const auto& cls : classes) {
+        if (!cls.is_mirrored) {
+            for (const auto& shim : exportedShims(cls)) {
+                claimShim(shim);
+            }
+        }
+    }
+}
+
+std::string CWrapperGenerator::targetGuarded(const std::string& code, const std::vector<std::string>& targets) {
+    if (targets.empty() || code.empty()) {
+        return code;
+    }
+    return "#if " + targetCondition(targets) + "\n" + code + "#endif\n";
+}
+
+std::string CWrapperGenerator::shimName(const FFIFunction& func) {
+    if (!func.c_name.empty()) {
+        return func.c_name;
+    }
+    if (func.class_name.empty()) {
+        return func.name;
+    }
+    if (func.is_constructor) {
+        return func.class_name + "_new";
+    }
+    if (func.is_destructor) {
+        return func.class_name + "_delete";
+    }
+    return func.class_name + "_" + func.name;
+}
+
+std::string CWrapperGenerator::shimReturnType(const FFIFunction& func) {
+    if (func.is_constructor) {
+        return "void*";
+    }
+    // Arrays and wide bitsets are copied into a caller-provided buffer instead
+    if (arrayReturnExtent(func) || bitsetWidth(func.return_type) > 64) {
+        return "void";
+    }
+    if (func.c_return_type.empty()) {
+        return "void";
+    }
+    return func.c_return_type;
+}
+
+std::string CWrapperGenerator::macroPrefix(const std::string& library_name) {
+    std::string prefix;
+    for (char c : library_name) {
+        prefix += std::isalnum(static_cast<unsigned char>(c))
+            ? static_cast<char>(std::toupper(static_cast<unsigned char>(c)))
+            : '_';
+    }
+    return prefix;
+}
+
+std::vector<FFIFunction> CWrapperGenerator::shimFunctions(const FFIClass& cls) {
+    std::vector<FFIFunction> shims;
+
+    // Mirrored structs are accessed directly from Go
+    if (cls.is_mirrored) {
+        return shims;
+    }
+
+    bool has_constructor = false;
+    for (const auto& method : cls.methods) {
+        if (method.is_constructor) {
+            has_constructor = true;
+        }
+    }
+
+    // Implicit default constructor for concrete classes
+    if (!has_constructor && !cls.is_abstract) {
+        FFIFunction ctor;
+        ctor.name = cls.name;
+        ctor.class_name = cls.name;
+        ctor.cpp_namespace = cls.cpp_namespace;
+        ctor.is_method = true;
+        ctor.is_constructor = true;
+        ctor.feature = cls.feature;
+        ctor.targets = cls.targets;
+        ctor.c_name = cls.symbol_stem.empty() ? "" : cls.symbol_stem + "_new";
+        shims.push_back(ctor);
+    }
+
+    size_t ctor_index = 0;
+    for (const auto& method : bindableFunctions(cls.methods)) {
+        FFIFunction shim = method;
+        shim.class_name = cls.name;
+        shim.is_method = true;
+        if (shim.feature.empty()) {
+            shim.feature = cls.feature;
+        }
+        if (shim.targets.empty()) {
+            shim.targets = cls.targets;
+        }
+        // Overloaded constructors: Class_new, Class_new1, Class_new2, ...
+        if (shim.is_constructor && ctor_index++ > 0 && shim.c_name.empty()) {
+            shim.c_name = cls.name + "_new" + std::to_string(ctor_index - 1);
+        }
+        shims.push_back(shim);
+    }
+
+    for (const auto& method : bindableFunctions(cls.static_methods)) {
+        FFIFunction shim = method;
+        shim.class_name = cls.name;
+        shim.is_static = true;
+        if (shim.feature.empty()) {
+            shim.feature = cls.feature;
+        }
+        if (shim.targets.empty()) {
+            shim.targets = cls.targets;
+        }
+        shims.push_back(shim);
+    }
+
+    // Iteration shims drive begin() to end() through a heap-allocated iterator pair
+    if (!cls.iterator_element.cpp_type.empty()) {
+        for (const std::string step : {"begin", "has_next", "next", "get", "delete"}) {
+            FFIFunction shim;
+            shim.name = "iter_" + step;
+            shim.class_name = cls.name;
+            shim.cpp_namespace = cls.cpp_namespace;
+            shim.c_name = cls.symbol_stem.empty() ? "" : cls.symbol_stem + "_" + shim.name;
+            shim.is_method = true;
+            shim.is_static = step != "begin";
+            shim.is_const = cls.iterator_const;
+            shim.iteration = step;
+            shim.feature = cls.feature;
+            shim.targets = cls.targets;
+            shim.return_type = step == "begin" ? "void*" : step == "has_next" ? "bool" : "void";
+            if (shim.is_static) {
+                FFIParameter it;
+                it.name = "it";
+                it.cpp_type = "void*";
+                it.c_type = "void*";
+                shim.parameters.push_back(it);
+            }
+            if (step == "get") {
+                shim.return_type = cls.iterator_element.cpp_type;
+                shim.c_return_type = cls.iterator_element.c_type;
+                shim.returns_enum = cls.iterator_element.is_enum;
+            } else if (shim.return_type != "void") {
+                shim.c_return_type = shim.return_type;
+            }
+            shims.push_back(shim);
+        }
+    }
+
+    FFIFunction dtor;
+    dtor.name = "~" + cls.name;
+    dtor.class_name = cls.name;
+    dtor.cpp_namespace = cls.cpp_namespace;
+    dtor.c_name = cls.symbol_stem.empty() ? "" : cls.symbol_stem + "_delete";
+    dtor.is_method = true;
+    dtor.is_destructor = true;
+    dtor.feature = cls.feature;
+    dtor.targets = cls.targets;
+    shims.push_back(dtor);
+
+    return shims;
+}
+
+std::vector<FFIFunction> CWrapperGenerator::directShims(const FFIClass& cls) {
+    std::vector<FFIFunction> shims;
+    if (!cls.devirtualize || cls.is_mirrored) {
+        return shims;
+    }
+    if (cls.is_abstract) {
+        throw std::invalid_argument(cls.name + " is marked @devirtualize but is abstract");
+    }
+    for (const auto& shim : shimFunctions(cls)) {
+        if (shim.is_virtual && !shim.is_static && !shim.is_constructor && !shim.is_destructor) {
+            FFIFunction direct = shim;
+            direct.c_name = shimName(shim) + "_direct";
+            direct.direct = true;
+            direct.visitor.collect.clear();
+            direct.doc = "Calls " + cls.name + "::" + shim.name + " without virtual dispatch, so self must be "
+                         "exactly a " + cls.name + ", as " + symbolStem(cls) + "_new() makes.";
+            shims.push_back(direct);
+        }
+    }
+    if (shims.empty()) {
+        throw std::invalid_argument(cls.name + " is marked @devirtualize but has no bindable virtual methods");
+    }
+    return shims;
+}
+
+std::string CWrapperGenerator::shimParameterList(const FFIFunction& func, const std::string& type_prefix) {
+    std::vector<std::string> params;
+
+    // Instance methods and the destructor take the object handle first
+    if (!func.class_name.empty() && !func.is_static && !func.is_constructor) {
+        std::string handle = type_prefix.empty() ? "void" : type_prefix + "_" + func.class_name;
+        params.push_back((func.is_const ? "const " : "") + handle + "* self");
+    }
+
+    bool error_code_out = hasErrorCodeOut(func);
+    size_t count = func.parameters.size() - (error_code_out ? 1 : 0);
+    for (size_t i = 0; i < count; ++i) {
+        const auto& param = func.parameters[i];
+        if (!func.logger.context.empty() && param.name == func.logger.context) {
+            continue;
+        }
+        std::string type = typedCType(param, type_prefix);
+        size_t pointer = type.find("(*)");
+        if (pointer != std::string::npos) {
+            // Function pointers name the parameter inside the declarator
+            params.push_back(type.insert(pointer + 2, parameterName(param, i)));
+            continue;
+        }
+        params.push_back(type + " " + parameterName(param, i));
+        if (isStringReference(param)) {
+            params.push_back("size_t* " + parameterName(param, i) + "_len");
+        }
+    }
+
+    // std::error_code& is reported through value/category/message out-params
+    if (error_code_out) {
+        params.push_back("int* ec_value");
+        params.push_back("const char** ec_category");
+        params.push_back("char** ec_message");
+    }
+
+    std::string element;
+    if (arrayReturnExtent(func, &element)) {
+        params.push_back(element + "* out_array");
+    }
+    if (bitsetWidth(func.return_type) > 64) {
+        params.push_back("uint64_t* out_bits");
+    }
+
+    // std::expected reports which alternative it holds plus the error value
+    std::string expected_error;
+    if (expectedTypes(func, nullptr, &expected_error)) {
+        params.push_back("bool* has_value");
+        params.push_back(expected_error == "std::string" ? "char** unexpected" : "int* unexpected");
+    }
+
+    if (catchesExceptions(func)) {
+        params.push_back("char** exception");
+    }
+
+    if (params.empty()) {
+        return "void";
+    }
+
+    std::stringstream ss;
+    for (size_t i = 0; i < params.size(); ++i) {
+        if (i > 0) ss << ", ";
+        ss << params[i];
+    }
+    return ss.str();
+}
+
+std::string CWrapperGenerator::generateDeclaration(const FFIFunction& func, const std::string& linkage,
+                                                   const std::string& type_prefix) {
+    std::stringstream ss;
+    std::string return_type = shimReturnType(func);
+    if (!type_prefix.empty() && func.is_constructor) {
+        return_type = type_prefix + "_" + func.class_name + "*";
+    } else if (!type_prefix.empty() && func.returns_enum) {
+        return_type = type_prefix + "_" + func.return_type;
+    }
+
+    if (!linkage.empty()) {
+        ss << linkage << "_API ";
+    }
+    ss << return_type << " ";
+    if (!linkage.empty()) {
+        ss << linkage << "_CALL ";
+    }
+    ss << shimName(func) << "(" << shimParameterList(func, type_prefix) << ");";
+
+    // Owned arrays are handed back to the shim that delete[]s them
+    if (uniqueArrayReturn(func)) {
+        ss << "\n";
+        if (!linkage.empty()) {
+            ss << linkage << "_API ";
+        }
+        ss << "void ";
+        if (!linkage.empty()) {
+            ss << linkage << "_CALL ";
+        }
+        ss << arrayDeleterName(func) << "(" << func.c_return_type << " array);";
+    }
+    if (copiesVisitorElements(func)) {
+        ss << "\n";
+        if (!linkage.empty()) {
+            ss << linkage << "_API ";
+        }
+        ss << (type_prefix.empty() ? "void" : type_prefix + "_" + elementClass(func.visitor.element.cpp_type)) << "* ";
+        if (!linkage.empty()) {
+            ss << linkage << "_CALL ";
+        }
+        ss << visitorCopyName(func) << "(const void* element);";
+    }
+
+    return ss.str();
+}
+
+std::string CWrapperGenerator::generateLinkageMacros(const std::string& library_name, bool allow_export) {
+    // Shims are pinned to __cdecl so MSVC and MinGW agree on the x86 name
+    // decoration (_name) regardless of /Gz or -mrtd defaults; on x64 there
+    // is no decoration and the macro is harmless.
+    std::string prefix = macroPrefix(library_name);
+    std::stringstream ss;
+
+    ss << "#if defined(_WIN32)\n";
+    if (allow_export) {
+        ss << "#  if defined(" << prefix << "_BUILD_DLL)\n";
+        ss << "#    define " << prefix << "_API __declspec(dllexport)\n";
+        ss << "#  else\n";
+        ss << "#    define " << prefix << "_API __declspec(dllimport)\n";
+        ss << "#  endif\n";
+    } else {
+        ss << "#  define " << prefix << "_API __declspec(dllimport)\n";
+    }
+    ss << "#  define " << prefix << "_CALL __cdecl\n";
+    ss << "#else\n";
+    ss << "#  define " << prefix << "_API\n";
+    ss << "#  define " << prefix << "_CALL\n";
+    ss << "#endif\n";
+
+    return ss.str();
+}
+
+std::string CWrapperGenerator::generateFunctionWrapper(const FFIFunction& func, const std::string& linkage) {
+    // Free functions bound under their own name are already extern "C"
+    if (func.class_name.empty() && shimName(func) == func.name) {
+        return "";
+    }
+
+    std::string return_type = shimReturnType(func);
+    std::stringstream ss;
+
+    ss << return_type << " ";
+    if (!linkage.empty()) {
+        ss << linkage << "_CALL ";
+    }
+    ss << shimName(func) << "(" << shimParameterList(func) << ") {\n";
+
+    std::string args = argumentList(func);
+
+    if (func.is_constructor) {
+        ss << "    return new " << func.class_name << "(" << args << ");\n";
+        ss << "}\n";
+        return catchesExceptions(func) ? catchingWrapper(ss.str(), return_type) : ss.str();
+    }
+    if (func.is_destructor) {
+        ss << "    delete static_cast<" << func.class_name << "*>(self);\n";
+        ss << "}\n";
+        return ss.str();
+    }
+
+    // Steps of an IterationState, the iterator pair of one walk over the container
+    if (!func.iteration.empty()) {
+        std::string container = (func.is_const ? "const " : "") + func.class_name;
+        std::string state = "static_cast<IterationState<" + container + ">*>(it)";
+        if (func.iteration == "begin") {
+            ss << "    return new IterationState<" << container << ">(*static_cast<" << container << "*>(self));\n";
+        } else if (func.iteration == "has_next") {
+            ss << "    auto* state = " << state << ";\n";
+            ss << "    return state->it != state->end;\n";
+        } else if (func.iteration == "next") {
+            ss << "    ++" << state << "->it;\n";
+        } else if (func.iteration == "get" && return_type == "void*") {
+            // Proxies are converted to the wrapped class, which Go then owns
+            ss << "    return new " << func.return_type << "(*" << state << "->it);\n";
+        } else if (func.iteration == "get") {
+            ss << "    return static_cast<" << return_type << ">(*" << state << "->it);\n";
+        } else {
+            ss << "    delete " << state << ";\n";
+        }
+        ss << "}\n";
+        return ss.str();
+    }
+
+    // Element accessors of operator[], indexed by the intptr_t Go passes
+    if (func.subscript) {
+        std::string self_type = (func.is_const ? "const " : "") + func.class_name + "*";
+        std::string element = "(*static_cast<" + self_type + ">(self))[static_cast<" + func.parameters[0].cpp_type +
+                              ">(" + parameterName(func.parameters[0], 0) + ")]";
+        if (func.parameters.size() == 1) {
+            ss << "    return " << element << ";\n";
+        } else {
+            ss << "    " << element << " = " << parameterName(func.parameters[1], 1) << ";\n";
+        }
+        ss << "}\n";
+        return ss.str();
+    }
+
+    // Synthesized accessors of a struct demoted from a Go mirror, or of a std::atomic member
+    if (!func.field_name.empty()) {
+        std::string self_type = (func.is_const ? "const " : "") + func.class_name + "*";
+        std::string member = "static_cast<" + self_type + ">(self)->" + func.field_name;
+        if (func.atomic && func.parameters.empty()) {
+            ss << "    return " << member << ".load();\n";
+        } else if (func.atomic) {
+            ss << "    " << member << ".store(" << parameterName(func.parameters[0], 0) << ");\n";
+        } else if (func.parameters.empty()) {
+            ss << "    return " << member << ";\n";
+        } else {
+            ss << "    " << member << " = " << parameterName(func.parameters[0], 0) << ";\n";
+        }
+        ss << "}\n";
+        return ss.str();
+    }
+
+    // A logger setter installs an adapter calling the last handler given,
+    // which stays valid for messages logged while another is installed
+    if (!func.logger.callback.empty()) {
+        std::string sink_type = func.logger.level.c_type + ", const char*";
+        ss << "    static std::atomic<void (*)(" << sink_type << ")> go_log_handler{nullptr};\n";
+        ss << "    if (" << func.logger.callback << ") {\n";
+        ss << "        go_log_handler = " << func.logger.callback << ";\n";
+        ss << "    }\n";
+
+        std::string callback_type = func.logger.callback_type;
+        std::string arguments = callback_type.substr(callback_type.find("(*)") + 4);
+        arguments.pop_back();
+        std::stringstream list(arguments);
+        std::string params;
+        std::string message;
+        for (std::string argument; std::getline(list, argument, ',');) {
+            argument = argument.substr(argument.find_first_not_of(' '));
+            bool text = argument == "const char*" || argument == "const std::string&";
+            if (text) {
+                message = argument == "const char*" ? "message" : "message.c_str()";
+            }
+            std::string name = text ? " message" : argument == "void*" ? "" : " level";
+            params += (params.empty() ? "" : ", ") + argument + name;
+        }
+        ss << "    auto forward = +[](" << params << ") {\n";
+        ss << "        go_log_handler.load()(static_cast<" << func.logger.level.c_type << ">(level), " << message << ");\n";
+        ss << "    };\n";
+        std::string args;
+        for (const auto& param : func.parameters) {
+            args += args.empty() ? "" : ", ";
+            args += param.name == func.logger.callback ? func.logger.callback + " ? forward : nullptr" : "nullptr";
+        }
+        ss << "    " << func.name << "(" << args << ");\n";
+        ss << "}\n";
+        return ss.str();
+    }
+
+    std::string invoke;
+    if (func.is_static) {
+        invoke = func.class_name + "::" + func.name + "(" + args + ")";
+    } else if (!func.visitor.free_receiver.empty()) {
+        // A free function bound as a method gets the object as its first argument
+        std::string self_type = (func.is_const ? "const " : "") + func.class_name + "*";
+        std::string self = "static_cast<" + self_type + ">(self)";
+        if (func.visitor.free_receiver.back() == '&') {
+            self = "*" + self;
+        }
+        invoke = func.name + "(" + self + (args.empty() ? "" : ", ") + args + ")";
+    } else if (!func.class_name.empty()) {
+        std::string self_type = (func.is_const ? "const " : "") + func.class_name + "*";
+        std::string scope = func.direct ? func.class_name + "::" : "";
+        invoke = "static_cast<" + self_type + ">(self)->" + scope + func.name + "(" + args + ")";
+    } else {
+        invoke = func.name + "(" + args + ")";
+    }
+
+    if (func.returns_enum) {
+        invoke = "static_cast<" + return_type + ">(" + invoke + ")";
+    }
+    if (returnsReference(func)) {
+        invoke = "&" + invoke;
+    }
+    size_t bits = bitsetWidth(func.return_type);
+    if (bits && bits <= 64) {
+        invoke += ".to_ullong()";
+    }
+
+    bool error_code_out = hasErrorCodeOut(func);
+    size_t extent = arrayReturnExtent(func);
+    std::string expected_error;
+    bool expected = expectedTypes(func, nullptr, &expected_error);
+
+    for (size_t i = 0; i < func.parameters.size(); ++i) {
+        const auto& param = func.parameters[i];
+        if (isStructOutput(param)) {
+            std::string type = param.cpp_type.substr(0, param.cpp_type.find_last_not_of("&* ") + 1);
+            ss << "    OutputStruct<" << type << "> " << parameterName(param, i) << "_slot("
+               << parameterName(param, i) << ");\n";
+        } else if (isStringReference(param)) {
+            ss << (isWideString(param) ? "    OutputWideString " : "    OutputString ") << parameterName(param, i)
+               << "_slot(" << parameterName(param, i) << ", "
+               << parameterName(param, i) << "_len);\n";
+        }
+    }
+    if (error_code_out) {
+        ss << "    std::error_code ec;\n";
+    }
+    if (!func.factory.empty() && !func.borrowed) {
+        // Go deletes the object through the class returned, whatever its dynamic type
+        ss << "    static_assert(!std::is_polymorphic<" << func.factory << ">::value || std::has_virtual_destructor<"
+           << func.factory << ">::value,\n";
+        ss << "                  \"" << func.factory << " is deleted through base pointers and needs a virtual destructor\");\n";
+    }
+    if (!func.visitor.callback.empty()) {
+        ss << "    VisitorCall visitor_call{" << func.visitor.callback << ", " << func.visitor.context << "};\n";
+    }
+    if (const std::vector<std::string>* chained = preservedSignals(func)) {
+        std::string signals;
+        for (const auto& signal : *chained) {
+            signals += (signals.empty() ? "" : ", ") + signal;
+        }
+        ss << "#if !defined(_WIN32)\n";
+        ss << "    SignalGuard signal_guard(std::vector<int>{" << signals << "});\n";
+        ss << "#endif\n";
+    }
+
+    if (bits > 64) {
+        ss << "    bitsetToWords(" << invoke << ", out_bits);\n";
+    } else if (extent) {
+        ss << "    const auto& array = " << invoke << ";\n";
+        ss << "    for (size_t i = 0; i < " << extent << "; ++i) {\n";
+        ss << "        out_array[i] = array[i];\n";
+        ss << "    }\n";
+    } else if (uniqueArrayReturn(func)) {
+        ss << "    return " << invoke << ".release();\n";
+    } else if (expected) {
+        ss << "    auto expected = " << invoke << ";\n";
+        ss << "    *has_value = expected.has_value();\n";
+        ss << "    if (!expected) {\n";
+        if (expected_error == "std::string") {
+            ss << "        *unexpected = strdup(expected.error().c_str());\n";
+        } else {
+            ss << "        *unexpected = static_cast<int>(expected.error());\n";
+        }
+        if (return_type != "void") {
+            ss << "        return {};\n";
+        }
+        ss << "    }\n";
+        if (return_type != "void") {
+            ss << "    return *expected;\n";
+        }
+    } else if (return_type == "void") {
+        ss << "    " << invoke << ";\n";
+    } else if (error_code_out) {
+        ss << "    auto result = " << invoke << ";\n";
+    } else {
+        ss << "    return " << invoke << ";\n";
+    }
+
+    if (error_code_out) {
+        // category().name() has static storage; the message is malloc'd for Go to free
+        ss << "    *ec_value = ec.value();\n";
+        ss << "    *ec_category = ec.category().name();\n";
+        ss << "    *ec_message = ec ? strdup(ec.message().c_str()) : nullptr;\n";
+        if (return_type != "void") {
+            ss << "    return result;\n";
+        }
+    }
+
+    ss << "}\n";
+    std::string wrapper = catchesExceptions(func) ? catchingWrapper(ss.str(), return_type) : ss.str();
+
+    // Arrays from new[] must be released with delete[], never free or delete
+    if (uniqueArrayReturn(func)) {
+        wrapper += "\nvoid ";
+        if (!linkage.empty()) {
+            wrapper += linkage + "_CALL ";
+        }
+        wrapper += arrayDeleterName(func) + "(" + return_type + " array) {\n";
+        wrapper += "    delete[] array;\n";
+        wrapper += "}\n";
+    }
+
+    // Collected elements outlive the visit, so each is copied as it arrives
+    if (copiesVisitorElements(func)) {
+        std::string element = "static_cast<const " + elementClass(func.visitor.element.cpp_type) + "*>(element)";
+        wrapper += "\nvoid* ";
+        if (!linkage.empty()) {
+            wrapper += linkage + "_CALL ";
+        }
+        wrapper += visitorCopyName(func) + "(const void* element) {\n";
+        if (func.visitor.clone.empty()) {
+            wrapper += "    return new " + elementClass(func.visitor.element.cpp_type) + "(*" + element + ");\n";
+        } else {
+            wrapper += "    return " + element + "->" + func.visitor.clone + "();\n";
+        }
+        wrapper += "}\n";
+    }
+    return wrapper;
+}
+
+std::string CWrapperGenerator::generateClassWrapper(const FFIClass& cls, const std::string& linkage) {
+    std::stringstream ss;
+
+    ss << "// " << cls.name << "\n";
+    for (const auto& shim : exportedShims(cls)) {
+        std::string wrapper = generateFunctionWrapper(shim, linkage);
+        if (shim.feature != cls.feature && options_.features.count(shim.feature)) {
+            wrapper = featureGuarded(wrapper, shim.feature);
+        }
+        if (shim.targets != cls.targets) {
+            wrapper = targetGuarded(wrapper, shim.targets);
+        }
+        ss << wrapper << "\n";
+    }
+
+    // Optional features compile only when their macro is defined, and
+    // classes some targets lack only for the others
+    std::string code = ss.str();
+    if (!cls.cpp_namespace.empty()) {
+        code.pop_back();
+        code = inNamespace(code, cls.cpp_namespace) + "\n";
+    }
+    if (!cls.targets.empty()) {
+        code.pop_back();
+        code = targetGuarded(code, cls.targets) + "\n";
+    }
+    if (options_.features.count(cls.feature)) {
+        code.pop_back();
+        return featureGuarded(code, cls.feature) + "\n";
+    }
+    return code;
+}
+
+std::string CWrapperGenerator::generateLayoutChecks(const std::vector<FFIClass>& classes) {
+    LayoutEngine engine(classes);
+    std::vector<ABIProfile> profiles = LayoutEngine::profiles(options_);
+    std::stringstream portable;
+    std::vector<std::stringstream> per_target(profiles.size());
+
+    auto check = [this](std::stringstream& ss, const FFIClass& cls, const StructLayout& layout) {
+        std::stringstream asserts;
+        std::string type = cls.cpp_namespace.empty() ? cls.name : cls.cpp_namespace + "::" + cls.name;
+        asserts << "static_assert(sizeof(" << type << ") == " << layout.size
+                << ", \"" << cls.name << " does not match its Go mirror\");\n";
+        // offsetof is only reliable for standard-layout types
+        if (cls.base_classes.empty()) {
+            for (const auto& field : layout.fields) {
+                asserts << "static_assert(offsetof(" << type << ", " << field.name << ") == " << field.offset
+                        << ", \"" << cls.name << "::" << field.name << " does not match its Go mirror\");\n";
+            }
+        }
+        ss << (options_.features.count(cls.feature) ? featureGuarded(asserts.str(), cls.feature) : asserts.str());
+    };
+
+    for (const auto& cls : classes) {
+        if (!cls.is_mirrored) {
+            continue;
+        }
+        if (engine.isPortable(cls, profiles)) {
+            check(portable, cls, engine.layout(cls, profiles[0]));
+            continue;
+        }
+        for (size_t i = 0; i < profiles.size(); ++i) {
+            if (LayoutEngine::isDeclared(cls, profiles[i])) {
+                check(per_target[i], cls, engine.layout(cls, profiles[i]));
+            }
+        }
+    }
+
+    std::stringstream ss;
+    if (!portable.str().empty()) {
+        ss << "// Struct layouts assumed by the Go mirrors\n" << portable.str() << "\n";
+    }
+    for (size_t i = 0; i < profiles.size(); ++i) {
+        if (per_target[i].str().empty()) {
+            continue;
+        }
+        ss << "// Struct layouts assumed by the Go mirrors for " << profiles[i].triple << "\n";
+        ss << "#if " << profiles[i].preprocessorCondition() << "\n";
+        ss << per_target[i].str();
+        ss << "#endif\n\n";
+    }
+    return ss.str();
+}
+
+std::string CWrapperGenerator::generateHeader(
+    const std::vector<FFIFunction>& functions,
+    const std::vector<FFIClass>& all_classes,
+    const std::string& library_name
+) {
+    std::vector<FFIClass> classes = LayoutEngine::resolveMirrors(all_classes, options_);
+    std::string prefix = macroPrefix(library_name);
+    std::string linkage = options_.windows_dll_import ? prefix : "";
+    std::stringstream ss;
+
+    ss << "/* Code generated by Hybrid Transpiler. DO NOT EDIT. */\n";
+    ss << "#ifndef " << prefix << "_WRAPPER_H\n";
+    ss << "#define " << prefix << "_WRAPPER_H\n\n";
+
+    ss << "#include <stddef.h>\n";
+    ss << "#include <stdint.h>\n";
+    ss << "#include <stdbool.h>\n";
+    if (usesTm(functions, classes)) {
+        ss << "#include <time.h>\n";
+    }
+    ss << "\n";
+
+    if (options_.windows_dll_import) {
+        ss << generateLinkageMacros(library_name, true) << "\n";
+    }
+
+    ss << "#ifdef __cplusplus\n";
+    ss << "extern \"C\" {\n";
+    ss << "#endif\n\n";
+
+    for (const auto& func : bindableFunctions(functions)) {
+        ss << targetGuarded(generateDeclaration(func, linkage) + "\n", func.targets);
+    }
+    if (!functions.empty()) {
+        ss << "\n";
+    }
+
+    for (const auto& cls : classes) {
+        if (cls.is_mirrored) {
+            continue;
+        }
+        ss << "/* " << cls.name << " */\n";
+        for (const auto& shim : exportedShims(cls)) {
+            ss << targetGuarded(generateDeclaration(shim, linkage) + "\n", shim.targets);
+        }
+        ss << "\n";
+    }
+
+    ss << "#ifdef __cplusplus\n";
+    ss << "}\n";
+    ss << "#endif\n\n";
+    ss << "#endif /* " << prefix << "_WRAPPER_H */\n";
+
+    return ss.str();
+}
+
+std::string CWrapperGenerator::generateImplementation(
+    const std::vector<FFIFunction>& functions,
+    const std::vector<FFIClass>& all_classes,
+    const std::string& library_name
+) {
+    std::vector<FFIClass> classes = LayoutEngine::resolveMirrors(all_classes, options_);
+    std::string prefix = macroPrefix(library_name);
+    std::string linkage = options_.windows_dll_import ? prefix : "";
+    std::stringstream ss;
+
+    ss << "// Code generated by Hybrid Transpiler. DO NOT EDIT.\n";
+    if (!options_.shim_prologue.empty()) {
+        ss << "\n// shim_prologue\n" << options_.shim_prologue;
+        if (options_.shim_prologue.back() != '\n') {
+            ss << "\n";
+        }
+        ss << "\n";
+    }
+    if (options_.windows_dll_import) {
+        // The shim is compiled into the DLL, so its declarations export
+        ss << "#define " << prefix << "_BUILD_DLL\n";
+    }
+    ss << "#include \"" << library_name << "_wrapper.h\"\n";
+    if (options_.library_header) {
+        ss << "#include \"" << library_name << ".h\"\n";
+    }
+
+    checkPreservedSignals(functions, classes);
+    checkSymbols(functions, classes);
+
+    std::vector<std::string> includes;
+    bool signal_guards = false;
+    bool wide_bitsets = false;
+    bool struct_outputs = false;
+    bool string_outputs = false;
+    bool wide_strings = false;
+    bool iteration = false;
+    bool visitors = false;
+    auto collectIncludes = [&](const FFIFunction& func) {
+        std::vector<std::string> needed;
+        if (hasErrorCodeOut(func)) {
+            needed = {"cstring", "system_error"};
+        } else if (expectedTypes(func)) {
+            needed = {"cstring", "expected"};
+        }
+        if (std::any_of(func.parameters.begin(), func.parameters.end(), isStructOutput) &&
+            shimName(func) != func.name) {
+            needed.push_back("cstring");
+            needed.push_back("type_traits");
+            struct_outputs = true;
+        }
+        if (std::any_of(func.parameters.begin(), func.parameters.end(), isStringReference)) {
+            needed.push_back("cstdlib");
+            needed.push_back("cstring");
+            needed.push_back("string");
+            string_outputs = true;
+        }
+        if (std::any_of(func.parameters.begin(), func.parameters.end(), isWideString) &&
+            shimName(func) != func.name) {
+            needed.push_back("string");
+            wide_strings = true;
+        }
+        if (!func.factory.empty() && !func.borrowed && shimName(func) != func.name) {
+            needed.push_back("type_traits");
+        }
+        if (func.iteration == "begin") {
+            needed.push_back("utility");
+            iteration = true;
+        }
+        visitors = visitors || !func.visitor.callback.empty();
+        if (!func.logger.callback.empty()) {
+            needed.push_back("atomic");
+        }
+        if (preservedSignals(func)) {
+            needed.push_back("algorithm");
+            needed.push_back("csignal");
+            needed.push_back("vector");
+            signal_guards = true;
+        }
+        if (catchesExceptions(func)) {
+            needed.push_back("cstring");
+            needed.push_back("exception");
+        }
+        size_t widest = bitsetWidth(func.return_type);
+        for (const auto& param : func.parameters) {
+            widest = std::max(widest, bitsetWidth(param.cpp_type));
+        }
+        if (widest) {
+            needed.push_back("bitset");
+            wide_bitsets = wide_bitsets || widest > 64;
+        }
+        for (const auto& header : needed) {
+            if (std::find(includes.begin(), includes.end(), header) == includes.end()) {
+                includes.push_back(header);
+            }
+        }
+    };
+    for (const auto& func : bindableFunctions(functions)) {
+        collectIncludes(func);
+    }
+    for (const auto& cls : classes) {
+        for (const auto& shim : shimFunctions(cls)) {
+            collectIncludes(shim);
+        }
+    }
+    std::sort(includes.begin(), includes.end());
+    for (const auto& header : includes) {
+        ss << "#include <" << header << ">\n";
+    }
+    // GCC rejects some standard headers included after importing a module
+    // that includes them, so imports come last
+    for (const auto& module : options_.modules) {
+        ss << "import " << module << ";\n";
+    }
+    ss << "\n";
+
+    ss << generateLayoutChecks(classes);
+
+    if (struct_outputs) {
+        // Trivial structs are filled in the caller's buffer; anything else is
+        // constructed here, copied out, then destroyed (members go after the body)
+        ss << "template <typename T, bool Trivial = std::is_trivially_default_constructible<T>::value &&\n";
+        ss << "                                     std::is_trivially_destructible<T>::value>\n";
+        ss << "class OutputStruct {\n";
+        ss << "public:\n";
+        ss << "    explicit OutputStruct(void* out) : out_(out) {}\n";
+        ss << "    ~OutputStruct() { std::memcpy(out_, static_cast<const void*>(&value_), sizeof(T)); }\n";
+        ss << "    T* get() { return &value_; }\n";
+        ss << "private:\n";
+        ss << "    void* out_;\n";
+        ss << "    T value_;\n";
+        ss << "};\n\n";
+        ss << "template <typename T>\n";
+        ss << "class OutputStruct<T, true> {\n";
+        ss << "public:\n";
+        ss << "    explicit OutputStruct(void* out) : out_(static_cast<T*>(out)) {}\n";
+        ss << "    T* get() { return out_; }\n";
+        ss << "private:\n";
+        ss << "    T* out_;\n";
+        ss << "};\n\n";
+    }
+
+    if (string_outputs) {
+        // The callee works on a local std::string taking over the caller's
+        // malloc()ed buffer, then copied once into a new one (nullptr while
+        // empty), also when it throws
+        ss << "class OutputString {\n";
+        ss << "public:\n";
+        ss << "    OutputString(char** data, size_t* size)\n";
+        ss << "        : data_(data), size_(size), value_(*data ? std::string(*data, *size) : std::string()) {\n";
+        ss << "        std::free(*data);\n";
+        ss << "        *data = nullptr;\n";
+        ss << "    }\n";
+        ss << "    ~OutputString() {\n";
+        ss << "        char* copy = value_.empty() ? nullptr : static_cast<char*>(std::malloc(value_.size()));\n";
+        ss << "        if (copy) {\n";
+        ss << "            std::memcpy(copy, value_.data(), value_.size());\n";
+        ss << "        }\n";
+        ss << "        *data_ = copy;\n";
+        ss << "        *size_ = copy ? value_.size() : 0;\n";
+        ss << "    }\n";
+        ss << "    std::string& get() { return value_; }\n";
+        ss << "private:\n";
+        ss << "    char** data_;\n";
+        ss << "    size_t* size_;\n";
+        ss << "    std::string value_;\n";
+        ss << "};\n\n";
+    }
+
+    if (wide_strings) {
+        // std::wstring holds UTF-16 where wchar_t has 16 bits (Windows) and
+        // UTF-32 elsewhere. Go passes and gets UTF-8, so characters outside
+        // the BMP become surrogate pairs on the way in and are joined again
+        // on the way out; invalid sequences and lone surrogates become U+FFFD
+        ss << "static std::wstring wideFromUtf8(const std::string& text) {\n";
+        ss << "    static const char32_t shortest[] = {0, 0, 0x80, 0x800, 0x10000};\n";
+        ss << "    std::wstring wide;\n";
+        ss << "    for (size_t i = 0; i < text.size();) {\n";
+        ss << "        unsigned char lead = static_cast<unsigned char>(text[i]);\n";
+        ss << "        size_t length = lead < 0x80 ? 1 : (lead >> 5) == 0x6 ? 2 : (lead >> 4) == 0xE ? 3 : (lead >> 3) == 0x1E ? 4 : 0;\n";
+        ss << "        char32_t code = length > 1 ? lead & (0x7F >> length) : lead;\n";
+        ss << "        bool valid = length != 0 && i + length <= text.size();\n";
+        ss << "        for (size_t k = 1; valid && k < length; ++k) {\n";
+        ss << "            unsigned char next = static_cast<unsigned char>(text[i + k]);\n";
+        ss << "            valid = (next & 0xC0) == 0x80;\n";
+        ss << "            code = (code << 6) | (next & 0x3F);\n";
+        ss << "        }\n";
+        ss << "        if (!valid || code < shortest[length] || (code >= 0xD800 && code <= 0xDFFF) || code > 0x10FFFF) {\n";
+        ss << "            code = 0xFFFD;\n";
+        ss << "            length = 1;\n";
+        ss << "        }\n";
+        ss << "        i += length;\n";
+        ss << "        if (sizeof(wchar_t) == 2 && code > 0xFFFF) {\n";
+        ss << "            code -= 0x10000;\n";
+        ss << "            wide.push_back(static_cast<wchar_t>(0xD800 + (code >> 10)));\n";
+        ss << "            wide.push_back(static_cast<wchar_t>(0xDC00 + (code & 0x3FF)));\n";
+        ss << "        } else {\n";
+        ss << "            wide.push_back(static_cast<wchar_t>(code));\n";
+        ss << "        }\n";
+        ss << "    }\n";
+        ss << "    return wide;\n";
+        ss << "}\n\n";
+        ss << "static std::string utf8FromWide(const std::wstring& wide) {\n";
+        ss << "    std::string text;\n";
+        ss << "    for (size_t i = 0; i < wide.size(); ++i) {\n";
+        ss << "        char32_t code = static_cast<char32_t>(wide[i]);\n";
+        ss << "        char32_t low = i + 1 < wide.size() ? static_cast<char32_t>(wide[i + 1]) : 0;\n";
+        ss << "        if (code >= 0xD800 && code <= 0xDBFF && low >= 0xDC00 && low <= 0xDFFF) {\n";
+        ss << "            code = 0x10000 + ((code - 0xD800) << 10) + (low - 0xDC00);\n";
+        ss << "            ++i;\n";
+        ss << "        } else if ((code >= 0xD800 && code <= 0xDFFF) || code > 0x10FFFF) {\n";
+        ss << "            code = 0xFFFD;\n";
+        ss << "        }\n";
+        ss << "        if (code < 0x80) {\n";
+        ss << "            text += static_cast<char>(code);\n";
+        ss << "        } else if (code < 0x800) {\n";
+        ss << "            text += static_cast<char>(0xC0 | (code >> 6));\n";
+        ss << "            text += static_cast<char>(0x80 | (code & 0x3F));\n";
+        ss << "        } else if (code < 0x10000) {\n";
+        ss << "            text += static_cast<char>(0xE0 | (code >> 12));\n";
+        ss << "            text += static_cast<char>(0x80 | ((code >> 6) & 0x3F));\n";
+        ss << "            text += static_cast<char>(0x80 | (code & 0x3F));\n";
+        ss << "        } else {\n";
+        ss << "            text += static_cast<char>(0xF0 | (code >> 18));\n";
+        ss << "            text += static_cast<char>(0x80 | ((code >> 12) & 0x3F));\n";
+        ss << "            text += static_cast<char>(0x80 | ((code >> 6) & 0x3F));\n";
+        ss << "            text += static_cast<char>(0x80 | (code & 0x3F));\n";
+        ss << "        }\n";
+        ss << "    }\n";
+        ss << "    return text;\n";
+        ss << "}\n\n";
+    }
+
+    if (wide_strings && string_outputs) {
+        // The UTF-8 slot converts once on the way in and once on the way out
+        ss << "class OutputWideString {\n";
+        ss << "public:\n";
+        ss << "    OutputWideString(char** data, size_t* size) : text_(data, size), value_(wideFromUtf8(text_.get())) {}\n";
+        ss << "    ~OutputWideString() { text_.get() = utf8FromWide(value_); }\n";
+        ss << "    std::wstring& get() { return value_; }\n";
+        ss << "private:\n";
+        ss << "    OutputString text_;\n";
+        ss << "    std::wstring value_;\n";
+        ss << "};\n\n";
+    }
+
+    if (wide_bitsets) {
+        // Bitsets wider than 64 bits travel as uint64_t words, bit i in word i/64
+        ss << "template <size_t N>\n";
+        ss << "static std::bitset<N> bitsetFromWords(const uint64_t* words) {\n";
+        ss << "    std::bitset<N> bits;\n";
+        ss << "    for (size_t i = 0; i < N; ++i) {\n";
+        ss << "        bits[i] = (words[i / 64] >> (i % 64)) & 1;\n";
+        ss << "    }\n";
+        ss << "    return bits;\n";
+        ss << "}\n\n";
+        ss << "template <size_t N>\n";
+        ss << "static void bitsetToWords(const std::bitset<N>& bits, uint64_t* words) {\n";
+        ss << "    for (size_t i = 0; i < (N + 63) / 64; ++i) {\n";
+        ss << "        words[i] = 0;\n";
+        ss << "    }\n";
+        ss << "    for (size_t i = 0; i < N; ++i) {\n";
+        ss << "        words[i / 64] |= static_cast<uint64_t>(bits[i]) << (i % 64);\n";
+        ss << "    }\n";
+        ss << "}\n\n";
+    }
+
+    if (iteration) {
+        // begin() and end() may return proxies of different types, compared with !=
+        ss << "template <typename Container>\n";
+        ss << "struct IterationState {\n";
+        ss << "    explicit IterationState(Container& container) : it(container.begin()), end(container.end()) {}\n";
+        ss << "    decltype(std::declval<Container&>().begin()) it;\n";
+        ss << "    decltype(std::declval<Container&>().end()) end;\n";
+        ss << "};\n\n";
+    }
+
+    if (visitors) {
+        // The Go func behind a visitor is a cgo.Handle, passed back with each
+        // element; its result is a bool or an enum's value
+        ss << "struct VisitorCall {\n";
+        ss << "    int64_t (*visit)(void*, uintptr_t);\n";
+        ss << "    uintptr_t visitor;\n";
+        ss << "    int64_t operator()(const void* element) const {\n";
+        ss << "        return visit(const_cast<void*>(element), visitor);\n";
+        ss << "    }\n";
+        ss << "};\n\n";
+    }
+
+    if (signal_guards) {
+        // Go's handlers are snapshot before the call and put back after it; a
+        // chained signal runs the handler the call installed, then Go's
+        ss << "#if !defined(_WIN32)\n";
+        ss << "static struct sigaction go_signal_actions[NSIG];\n";
+        ss << "static struct sigaction library_signal_actions[NSIG];\n\n";
+        ss << "static void callSignalAction(const struct sigaction& action, int sig, siginfo_t* info, void* context) {\n";
+        ss << "    if (action.sa_flags & SA_SIGINFO) {\n";
+        ss << "        action.sa_sigaction(sig, info, context);\n";
+        ss << "    } else if (action.sa_handler != SIG_DFL && action.sa_handler != SIG_IGN) {\n";
+        ss << "        action.sa_handler(sig);\n";
+        ss << "    }\n";
+        ss << "}\n\n";
+        ss << "static void chainSignal(int sig, siginfo_t* info, void* context) {\n";
+        ss << "    callSignalAction(library_signal_actions[sig], sig, info, context);\n";
+        ss << "    callSignalAction(go_signal_actions[sig], sig, info, context);\n";
+        ss << "}\n\n";
+        ss << "static bool sameSignalAction(const struct sigaction& a, const struct sigaction& b) {\n";
+        ss << "    return a.sa_handler == b.sa_handler && a.sa_flags == b.sa_flags;\n";
+        ss << "}\n\n";
+        ss << "class SignalGuard {\n";
+        ss << "public:\n";
+        ss << "    explicit SignalGuard(std::vector<int> chained) : chained_(std::move(chained)) {\n";
+        ss << "        for (int sig = 1; sig < NSIG; ++sig) {\n";
+        ss << "            sigaction(sig, nullptr, &saved_[sig]);\n";
+        ss << "        }\n";
+        ss << "    }\n";
+        ss << "    ~SignalGuard() {\n";
+        ss << "        for (int sig = 1; sig < NSIG; ++sig) {\n";
+        ss << "            struct sigaction current;\n";
+        ss << "            if (sigaction(sig, nullptr, &current) != 0 || sameSignalAction(current, saved_[sig])) {\n";
+        ss << "                continue;\n";
+        ss << "            }\n";
+        ss << "            // An earlier call may have chained it; unchaining drops that call's handler too\n";
+        ss << "            bool was_chained = saved_[sig].sa_sigaction == chainSignal;\n";
+        ss << "            if (std::find(chained_.begin(), chained_.end(), sig) == chained_.end()) {\n";
+        ss << "                sigaction(sig, was_chained ? &go_signal_actions[sig] : &saved_[sig], nullptr);\n";
+        ss << "                continue;\n";
+        ss << "            }\n";
+        ss << "            if (!was_chained) {\n";
+        ss << "                go_signal_actions[sig] = saved_[sig];\n";
+        ss << "            }\n";
+        ss << "            library_signal_actions[sig] = current;\n";
+        ss << "            struct sigaction chain = go_signal_actions[sig];\n";
+        ss << "            chain.sa_sigaction = chainSignal;\n";
+        ss << "            chain.sa_flags |= SA_SIGINFO | SA_ONSTACK;\n";
+        ss << "            sigaction(sig, &chain, nullptr);\n";
+        ss << "        }\n";
+        ss << "    }\n";
+        ss << "private:\n";
+        ss << "    std::vector<int> chained_;\n";
+        ss << "    struct sigaction saved_[NSIG] = {};\n";
+        ss << "};\n";
+        ss << "#endif\n\n";
+    }
+
+    ss << "extern \"C\" {\n\n";
+
+    for (const auto& func : bindableFunctions(functions)) {
+        std::string wrapper = inNamespace(generateFunctionWrapper(func, linkage), func.cpp_namespace);
+        if (!wrapper.empty() && options_.features.count(func.feature)) {
+            wrapper = featureGuarded(wrapper, func.feature);
+        }
+        wrapper = targetGuarded(wrapper, func.targets);
+        if (!wrapper.empty()) {
+            ss << wrapper << "\n";
+        }
+    }
+
+    for (const auto& cls : classes) {
+        if (!cls.is_mirrored) {
+            ss << generateClassWrapper(cls, linkage);
+        }
+    }
+
+    ss << "} // extern \"C\"\n";
+
+    return ss.str();
+}
+
+std::string CWrapperGenerator::generateBindingsHeader(
+    const std::vector<FFIFunction>& functions,
+    const std::vector<FFIClass>& all_classes,
+    const std::string& library_name
+) {
+    std::vector<FFIClass> classes = LayoutEngine::resolveMirrors(all_classes, options_);
+    std::string prefix = macroPrefix(library_name);
+    std::string linkage = options_.windows_dll_import ? prefix : "";
+    std::stringstream ss;
+
+    ss << "/* Code generated by Hybrid Transpiler. DO NOT EDIT. */\n";
+    ss << "/**\n";
+    ss << " * @file bindings.h\n";
+    ss << " * @brief extern \"C\" ABI of " << library_name << "\n";
+    ss << " *\n";
+    ss << " * Every function the " << library_name << " shim exports, declared with plain C\n";
+    ss << " * types for C code and other languages' FFIs. Objects and structs are\n";
+    ss << " * void* handles; a class's _new shim creates one and its _delete shim\n";
+    ss << " * releases it.\n";
+    ss << " */\n";
+    ss << "#ifndef " << prefix << "_BINDINGS_H\n";
+    ss << "#define " << prefix << "_BINDINGS_H\n\n";
+
+    ss << "#include <stddef.h>\n";
+    ss << "#include <stdint.h>\n";
+    if (usesTm(functions, classes)) {
+        ss << "#include <time.h>\n";
+    }
+    ss << "\n";
+
+    // bool without <stdbool.h>, which C consumers may not want pulled in
+    ss << "#ifdef __cplusplus\n";
+    ss << "#define " << prefix << "_BOOL bool\n";
+    ss << "#else\n";
+    ss << "#define " << prefix << "_BOOL _Bool\n";
+    ss << "#endif\n\n";
+
+    if (options_.windows_dll_import) {
+        ss << generateLinkageMacros(library_name, true) << "\n";
+    }
+
+    ss << "#ifdef __cplusplus\n";
+    ss << "extern \"C\" {\n";
+    ss << "#endif\n\n";
+
+    static const std::regex bool_type("\\bbool\\b");
+    auto declare = [&](const FFIFunction& func) {
+        std::string doc = func.doc + printfNote(func, func.doc);
+        doc += arrayNote(func, doc);
+        doc += stringNote(func, doc);
+        doc += wideStringNote(func, doc);
+        doc += factoryNote(func, doc, classes);
+        doc += referenceNote(func, doc);
+        std::string declaration = std::regex_replace(generateDeclaration(func, linkage), bool_type, prefix + "_BOOL");
+        ss << targetGuarded(docComment(doc + visitorNote(func, doc, classes)) + declaration + "\n", func.targets)
+           << "\n";
+    };
+
+    for (const auto& func : bindableFunctions(functions)) {
+        declare(func);
+    }
+    for (const auto& cls : classes) {
+        if (cls.is_mirrored) {
+            continue;
+        }
+        ss << "/* " << cls.name << " */\n\n";
+        for (const auto& shim : exportedShims(cls)) {
+            declare(shim);
+        }
+    }
+
+    ss << "#ifdef __cplusplus\n";
+    ss << "}\n";
+    ss << "#endif\n\n";
+    ss << "#endif /* " << prefix << "_BINDINGS_H */\n";
+
+    return ss.str();
+}
+
+std::string CWrapperGenerator::generateCHeader(const FFIModule& module, const std::string& library_name) {
+    std::string prefix = macroPrefix(library_name);
+    std::string type_prefix = cIdentifier(library_name);
+    std::string linkage = options_.windows_dll_import ? prefix : "";
+    std::vector<FFIClass> classes = LayoutEngine::resolveMirrors(module.classes, options_);
+    checkSymbols(module.functions, classes);
+    std::stringstream ss;
+
+    ss << "/* Code generated by Hybrid Transpiler. DO NOT EDIT. */\n";
+    ss << "/**\n";
+    ss << " * @file " << library_name << "_c.h\n";
+    ss << " * @brief C API of " << library_name << "\n";
+    ss << " *\n";
+    ss << " * Flattened extern \"C\" interface to the " << library_name << " C++ library, the same\n";
+    ss << " * shim the Go bindings link against. Objects are opaque handles created by\n";
+    ss << " * their class's _new shim and released with its _delete shim.\n";
+    ss << " */\n";
+    ss << "#ifndef " << prefix << "_C_H\n";
+    ss << "#define " << prefix << "_C_H\n\n";
+
+    ss << "#include <stddef.h>\n";
+    ss << "#include <stdint.h>\n";
+    ss << "#include <stdbool.h>\n";
+    if (usesTm(module.functions, classes)) {
+        ss << "#include <time.h>\n";
+    }
+    ss << "\n";
+
+    if (options_.windows_dll_import) {
+        ss << generateLinkageMacros(library_name, true) << "\n";
+    }
+
+    ss << "#ifdef __cplusplus\n";
+    ss << "extern \"C\" {\n";
+    ss << "#endif\n\n";
+
+    for (const auto& ffi_enum : module.enums) {
+        std::string type_name = type_prefix + "_" + ffi_enum.name;
+        ss << docComment(ffi_enum.doc);
+        ss << "typedef " << ffi_enum.underlying_type << " " << type_name << ";\n";
+        ss << "enum {\n";
+        for (size_t i = 0; i < ffi_enum.enumerators.size(); ++i) {
+            const auto& enumerator = ffi_enum.enumerators[i];
+            ss << "    " << type_name << "_" << enumerator.first;
+            if (!enumerator.second.empty()) {
+                // Values may refer to earlier enumerators, which are prefixed too
+                std::string value = enumerator.second;
+                for (const auto& other : ffi_enum.enumerators) {
+                    value = std::regex_replace(value, std::regex("\\b" + other.first + "\\b"),
+                                               type_name + "_" + other.first);
+                }
+                ss << " = " << value;
+            }
+            ss << (i + 1 < ffi_enum.enumerators.size() ? "," : "") << "\n";
+        }
+        ss << "};\n\n";
+    }
+
+    for (const auto& cls : classes) {
+        std::string type_name = type_prefix + "_" + cls.name;
+
+        if (cls.is_mirrored) {
+            if (!cls.base_classes.empty()) {
+                // Inherited members may sit in a base's tail padding, which C cannot express
+                ss << "/* " << cls.name << " is mirrored by the Go bindings only (its layout depends on C++ inheritance). */\n\n";
+                continue;
+            }
+            auto definition = [&](const std::vector<FFIParameter>& fields) {
+                std::string text = "typedef struct " + type_name + " {\n";
+                for (const auto& field : fields) {
+                    text += "    " + typedCType(field, type_prefix) + " " + field.name + ";\n";
+                }
+                return text + "} " + type_name + ";\n";
+            };
+            ss << docComment(cls.doc);
+            if (cls.targets.empty() && cls.target_fields.empty()) {
+                ss << definition(cls.fields) << "\n";
+                continue;
+            }
+
+            // A definition per configuration of the struct, shared by the targets that agree on it
+            std::vector<std::pair<std::string, std::vector<std::string>>> definitions;
+            for (const auto& triple : options_.target_triples) {
+                auto fields = cls.target_fields.find(triple);
+                if (!LayoutEngine::isDeclared(cls, ABIProfile::fromTriple(triple))) {
+                    continue;
+                }
+                std::string text = definition(fields == cls.target_fields.end() ? cls.fields : fields->second);
+                auto same = std::find_if(definitions.begin(), definitions.end(),
+                                         [&text](const std::pair<std::string, std::vector<std::string>>& d) {
+                                             return d.first == text;
+                                         });
+                if (same == definitions.end()) {
+                    definitions.push_back({text, {triple}});
+                } else {
+                    same->second.push_back(triple);
+                }
+            }
+            for (size_t i = 0; i < definitions.size(); ++i) {
+                ss << (i == 0 ? "#if " : "#elif ") << targetCondition(definitions[i].second) << "\n"
+                   << definitions[i].first;
+            }
+            ss << "#endif\n\n";
+            continue;
+        }
+
+        ss << "/* " << cls.name << " */\n\n";
+        ss << docComment(cls.doc.empty() ? "Opaque handle to a C++ " + cls.name + "." : cls.doc);
+        ss << "typedef struct " << type_name << " " << type_name << ";\n\n";
+
+        for (const auto& shim : exportedShims(cls)) {
+            std::string doc = shim.doc;
+            if (shim.is_constructor && doc.empty()) {
+                doc = "Create a " + cls.name + ". Release it with " + symbolStem(cls) + "_delete().";
+            } else if (shim.is_destructor) {
+                doc = "Destroy a " + cls.name + " and release its handle.";
+            }
+            if (hasErrorCodeOut(shim)) {
+                doc += std::string(doc.empty() ? "" : "\n") + "@note *ec_message is allocated with malloc(); release it with free().";
+            }
+            std::string expected_error;
+            if (expectedTypes(shim, nullptr, &expected_error) && expected_error == "std::string") {
+                doc += std::string(doc.empty() ? "" : "\n") + "@note *unexpected is allocated with malloc(); release it with free().";
+            }
+            if (catchesExceptions(shim)) {
+                doc += std::string(doc.empty() ? "" : "\n") + kExceptionNote;
+            }
+            doc += printfNote(shim, doc);
+            doc += arrayNote(shim, doc);
+            doc += stringNote(shim, doc);
+            doc += wideStringNote(shim, doc);
+            doc += factoryNote(shim, doc, classes);
+            doc += referenceNote(shim, doc);
+            std::string declaration = generateDeclaration(shim, linkage, type_prefix) + "\n";
+            ss << targetGuarded(docComment(doc + visitorNote(shim, doc, classes)) + declaration, shim.targets) << "\n";
+        }
+    }
+
+    std::vector<FFIFunction> functions = bindableFunctions(module.functions);
+    if (!functions.empty()) {
+        ss << "/* Functions */\n\n";
+    }
+    for (const auto& func : functions) {
+        std::string doc = func.doc + printfNote(func, func.doc);
+        if (catchesExceptions(func)) {
+            doc += std::string(doc.empty() ? "" : "\n") + kExceptionNote;
+        }
+        doc += arrayNote(func, doc);
+        doc += stringNote(func, doc);
+        doc += wideStringNote(func, doc);
+        doc += factoryNote(func, doc, classes);
+        doc += referenceNote(func, doc);
+        std::string declaration = generateDeclaration(func, linkage, type_prefix) + "\n";
+        ss << targetGuarded(docComment(doc + visitorNote(func, doc, classes)) + declaration, func.targets) << "\n";
+    }
+
+    ss << "#ifdef __cplusplus\n";
+    ss << "}\n";
+    ss << "#endif\n\n";
+    ss << "#endif /* " << prefix << "_C_H */\n";
+
+    return ss.str();
+}
+
+std::string CWrapperGenerator::generateDefFile(const FFIModule& module, const std::string& library_name) {
+    std::stringstream ss;
+
+    ss << "; Code generated by Hybrid Transpiler. DO NOT EDIT.\n";
+    ss << "LIBRARY " << library_name << "\n";
+    ss << "EXPORTS\n";
+
+    // Only Windows targets link with it, once per symbol their variants share
+    std::set<std::string> exported;
+    auto exportShim = [&](const FFIFunction& func) {
+        bool windows = func.targets.empty() ||
+                       std::any_of(func.targets.begin(), func.targets.end(), [](const std::string& triple) {
+                           return ABIProfile::fromTriple(triple).go_os == "windows";
+                       });
+        if (!windows || !exported.insert(shimName(func)).second) {
+            return;
+        }
+        ss << "    " << shimName(func) << "\n";
+        if (uniqueArrayReturn(func)) {
+            ss << "    " << arrayDeleterName(func) << "\n";
+        }
+        if (copiesVisitorElements(func)) {
+            ss << "    " << visitorCopyName(func) << "\n";
+        }
+    };
+    for (const auto& cls : LayoutEngine::resolveMirrors(module.classes, options_)) {
+        for (const auto& shim : exportedShims(cls)) {
+            exportShim(shim);
+        }
+    }
+    for (const auto& func : bindableFunctions(module.functions)) {
+        exportShim(func);
+    }
+
+    return ss.str();
+}
+
+} // namespace ffi
+} // namespace hybrid_transpiler
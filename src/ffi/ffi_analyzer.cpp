@@ -4,12 +4,1836 @@
  */
 
 #include "ffi.h"
+#include "parser.h"
 #include <algorithm>
+#include <cctype>
+#include <cstring>
+#include <map>
 #include <regex>
+#include <set>
+#include <sstream>
+#include <stdexcept>
 
 namespace hybrid_transpiler {
 namespace ffi {
 
+namespace {
+
+/**
+ * Comment block directly preceding a declaration
+ */
+struct DeclComment {
+    std::string doc;                        // ///, /** */ text
+    std::vector<std::string> annotations;   // // @name markers
+    std::string feature;                    // Innermost #ifdef/#if defined() macro around it
+};
+
+std::string trim(const std::string& text) {
+    size_t start = text.find_first_not_of(" \t\r\n");
+    if (start == std::string::npos) {
+        return "";
+    }
+    size_t end = text.find_last_not_of(" \t\r\n");
+    return text.substr(start, end - start + 1);
+}
+
+void appendDocLine(std::string& doc, const std::string& line) {
+    if (doc.empty() && line.empty()) {
+        return;
+    }
+    doc += line + "\n";
+}
+
+/**
+ * Number of parameters of the list opening at source[open]; nested
+ * parentheses, brackets and template arguments are skipped
+ */
+size_t parameterCount(const std::string& source, size_t open) {
+    int depth = 0;
+    size_t commas = 0;
+    bool empty = true;
+    for (size_t pos = open + 1; pos < source.size(); ++pos) {
+        char c = source[pos];
+        if (c == '(' || c == '<' || c == '[' || c == '{') {
+            depth++;
+        } else if ((c == ')' || c == '>' || c == ']' || c == '}') && depth > 0) {
+            depth--;
+        } else if (c == ')') {
+            break;
+        } else if (c == ',' && depth == 0) {
+            commas++;
+        }
+        if (!std::isspace(static_cast<unsigned char>(c))) {
+            empty = false;
+        }
+    }
+    std::string inside = source.substr(open + 1, source.find(')', open) - open - 1);
+    if (empty || trim(inside) == "void") {
+        return 0;
+    }
+    return commas + 1;
+}
+
+/**
+ * Length of the template head line starts with ("template <...>"), 0 if it
+ * starts with none, or all of line if the head goes on below. Comparisons
+ * in parentheses, as in sizeof(T) > 4, do not close it
+ */
+size_t templateHeadLength(const std::string& line) {
+    static const std::regex head(R"(^template\s*<)");
+    std::smatch match;
+    if (!std::regex_search(line, match, head)) {
+        return 0;
+    }
+    int angle_depth = 0;
+    int paren_depth = 0;
+    for (size_t i = match.length(0) - 1; i < line.size(); ++i) {
+        if (line[i] == '(') paren_depth++;
+        else if (line[i] == ')') paren_depth--;
+        else if (line[i] == '<' && paren_depth == 0) angle_depth++;
+        else if (line[i] == '>' && paren_depth == 0 && --angle_depth == 0) return i + 1;
+    }
+    return line.size();
+}
+
+/**
+ * Map declarations to the comment block in front of them and the feature
+ * macro guarding them. Keys are "Name" for types and "Name/N" or
+ * "Class::method/N" for functions taking N parameters, so overloads keep
+ * their own comments; function templates are "Name<>/N", apart from the
+ * overloads they constrain.
+ */
+std::map<std::string, DeclComment> extractComments(const std::string& source) {
+    static const std::regex type_decl(R"(^(?:class|struct|enum(?:\s+class|\s+struct)?)\s+(\w+))");
+    static const std::regex func_decl(R"((~?\w+)\s*\()");
+    static const std::regex feature_if(R"(#\s*(?:ifdef\s+(\w+)|if\s+(?:defined\s*\(\s*(\w+)\s*\)|defined\s+(\w+)|([A-Za-z_]\w*))))"
+                                       R"(\s*(?://.*|/\*.*)?)");
+
+    std::map<std::string, DeclComment> comments;
+    std::vector<std::pair<std::string, int>> scopes;   // class name, brace depth inside it
+    std::vector<std::string> conditions;   // Open #if blocks: feature macro, "" for other conditions
+    std::string pending_scope;
+    DeclComment pending;
+    bool in_block = false;
+    bool templated = false;   // A template head came since the last declaration
+    int depth = 0;
+
+    std::istringstream lines(source);
+    std::string raw;
+    size_t offset = 0;
+    while (std::getline(lines, raw)) {
+        size_t line_start = offset;
+        offset += raw.size() + 1;
+        std::string line = trim(raw);
+
+        if (in_block) {
+            size_t end = line.find("*/");
+            std::string text = trim(line.substr(0, end));
+            if (!text.empty() && text[0] == '*') {
+                text = trim(text.substr(1));
+            }
+            if (end != std::string::npos) {
+                in_block = false;
+                if (!text.empty()) appendDocLine(pending.doc, text);
+            } else {
+                appendDocLine(pending.doc, text);
+            }
+            continue;
+        }
+        if (line.compare(0, 3, "/**") == 0 && line.compare(0, 4, "/**<") != 0) {
+            size_t end = line.find("*/", 3);
+            std::string text = trim(line.substr(3, end == std::string::npos ? std::string::npos : end - 3));
+            appendDocLine(pending.doc, text);
+            in_block = end == std::string::npos;
+            continue;
+        }
+        if (line.compare(0, 3, "///") == 0 && line.compare(0, 4, "///<") != 0) {
+            appendDocLine(pending.doc, trim(line.substr(3)));
+            continue;
+        }
+        if (line.compare(0, 3, "// ") == 0 && line.size() > 4 && line[3] == '@') {
+            pending.annotations.push_back(trim(line.substr(4)));
+            continue;
+        }
+        if (!line.empty() && line[0] == '#') {
+            std::string directive = trim(line.substr(1));
+            std::smatch condition;
+            if (std::regex_match(line, condition, feature_if)) {
+                conditions.push_back(condition[1].str() + condition[2].str() + condition[3].str() +
+                                     condition[4].str());
+            } else if (directive.compare(0, 2, "if") == 0) {
+                conditions.push_back("");
+            } else if (directive.compare(0, 4, "else") == 0 || directive.compare(0, 4, "elif") == 0) {
+                // The alternative of a feature is not the feature
+                if (!conditions.empty()) conditions.back().clear();
+            } else if (directive.compare(0, 5, "endif") == 0 && !conditions.empty()) {
+                conditions.pop_back();
+            }
+            continue;
+        }
+        // A template head belongs to the declaration after it, as do the lines
+        // of a return type spelled above the name (typename std::enable_if<...>::type)
+        size_t head = templateHeadLength(line);
+        size_t text_start = head == 0 ? 0 : raw.find_first_not_of(" \t\r") + head;
+        templated = templated || head > 0;
+        line = trim(line.substr(head));
+        if (line.empty() || line.compare(0, 2, "//") == 0 ||
+            (templated && line.find_first_of("(;{}") == std::string::npos)) {
+            continue;
+        }
+
+        std::smatch match;
+        std::string name;
+        std::string text = raw.substr(text_start);
+        bool is_type = std::regex_search(line, match, type_decl);
+        if (is_type) {
+            name = match[1].str();
+        } else if (std::regex_search(text, match, func_decl)) {
+            name = match[1].str() + (templated ? "<>" : "");
+            size_t open = line_start + text_start + match.position(0) + match.length(0) - 1;
+            name += "/" + std::to_string(parameterCount(source, open));
+        }
+        templated = false;
+        for (auto it = conditions.rbegin(); it != conditions.rend() && pending.feature.empty(); ++it) {
+            pending.feature = *it;
+        }
+        if (!name.empty() && (!pending.doc.empty() || !pending.annotations.empty() || !pending.feature.empty())) {
+            while (!pending.doc.empty() && pending.doc.back() == '\n') {
+                pending.doc.pop_back();
+            }
+            std::string key = (!is_type && !scopes.empty()) ? scopes.back().first + "::" + name : name;
+            comments[key] = pending;
+        }
+        pending = DeclComment();
+
+        if (is_type && line.find(';') == std::string::npos) {
+            pending_scope = name;
+        }
+        for (char c : line) {
+            if (c == '{') {
+                depth++;
+                if (!pending_scope.empty()) {
+                    scopes.emplace_back(pending_scope, depth);
+                    pending_scope.clear();
+                }
+            } else if (c == '}') {
+                if (!scopes.empty() && scopes.back().second == depth) {
+                    scopes.pop_back();
+                }
+                depth--;
+            }
+        }
+    }
+
+    return comments;
+}
+
+std::string typeSpelling(const std::shared_ptr<hybrid::Type>& type) {
+    if (!type) {
+        return "void";
+    }
+    return (type->is_const ? "const " : "") + type->name;
+}
+
+/**
+ * Source ranges of extern "C" { ... } blocks
+ */
+std::vector<std::pair<size_t, size_t>> externCBlocks(const std::string& source) {
+    std::vector<std::pair<size_t, size_t>> blocks;
+    static const std::regex block_start(R"(extern\s+"C"\s*\{)");
+
+    for (auto it = std::sregex_iterator(source.begin(), source.end(), block_start);
+         it != std::sregex_iterator(); ++it) {
+        size_t start = it->position() + it->length();
+        int depth = 1;
+        size_t pos = start;
+        for (; pos < source.size() && depth > 0; ++pos) {
+            if (source[pos] == '{') depth++;
+            else if (source[pos] == '}') depth--;
+        }
+        blocks.emplace_back(start, pos);
+    }
+    return blocks;
+}
+
+bool isExternC(const std::string& source, const std::string& name) {
+    std::regex declared(R"(extern\s+"C"\s+[^;{}()]*\b)" + name + R"(\s*\()");
+    if (std::regex_search(source, declared)) {
+        return true;
+    }
+
+    std::regex call(R"(\b)" + name + R"(\s*\()");
+    for (const auto& block : externCBlocks(source)) {
+        std::string body = source.substr(block.first, block.second - block.first);
+        if (std::regex_search(body, call)) {
+            return true;
+        }
+    }
+    return false;
+}
+
+std::vector<FFIEnum> extractEnums(const std::string& source) {
+    static const std::regex enum_decl(
+        R"(\benum\s+(?:class\s+|struct\s+)?(\w+)\s*(?::\s*([\w\s:]+?))?\s*\{([^}]*)\})");
+    static const std::regex line_comment("//[^\n]*");
+    static const std::regex block_comment(R"(/\*[\s\S]*?\*/)");
+
+    std::string cleaned = std::regex_replace(source, line_comment, "");
+    cleaned = std::regex_replace(cleaned, block_comment, "");
+
+    std::vector<FFIEnum> enums;
+    for (auto it = std::sregex_iterator(cleaned.begin(), cleaned.end(), enum_decl);
+         it != std::sregex_iterator(); ++it) {
+        FFIEnum ffi_enum;
+        ffi_enum.name = (*it)[1].str();
+        if ((*it)[2].matched) {
+            ffi_enum.underlying_type = trim((*it)[2].str());
+            if (ffi_enum.underlying_type.compare(0, 5, "std::") == 0) {
+                ffi_enum.underlying_type = ffi_enum.underlying_type.substr(5);
+            }
+        }
+
+        std::istringstream body((*it)[3].str());
+        std::string enumerator;
+        while (std::getline(body, enumerator, ',')) {
+            enumerator = trim(enumerator);
+            if (enumerator.empty()) {
+                continue;
+            }
+            size_t equals = enumerator.find('=');
+            if (equals == std::string::npos) {
+                ffi_enum.enumerators.emplace_back(enumerator, "");
+            } else {
+                ffi_enum.enumerators.emplace_back(trim(enumerator.substr(0, equals)),
+                                                  trim(enumerator.substr(equals + 1)));
+            }
+        }
+        enums.push_back(ffi_enum);
+    }
+    return enums;
+}
+
+/**
+ * Named constant as declared, before its type and initializer are resolved
+ */
+struct SourceConstant {
+    std::string scope;      // Enclosing class, "" at namespace scope
+    std::string name;
+    std::string type;
+    std::string initializer;
+};
+
+/**
+ * Source without comments and preprocessor directives, as the declaration
+ * scanners read it. Both branches of an #ifdef are kept, so members guarded
+ * by one are bound along with the rest
+ */
+std::string declarationText(const std::string& source) {
+    static const std::regex line_comment("//[^\n]*");
+    static const std::regex block_comment(R"(/\*[\s\S]*?\*/)");
+    static const std::regex directive(R"((^|\n)[ \t]*#[^\n]*)");
+
+    std::string cleaned = std::regex_replace(source, line_comment, "");
+    cleaned = std::regex_replace(cleaned, block_comment, "");
+    return std::regex_replace(cleaned, directive, "$1");
+}
+
+/**
+ * Static const/constexpr class members and namespace-scope const/constexpr
+ * variables. Namespaces and extern "C" blocks are transparent; anything else
+ * in braces (function bodies, initializer lists) is skipped.
+ */
+std::vector<SourceConstant> extractConstants(const std::string& source) {
+    static const std::regex class_head(R"(^(?:template\s*<[^>]*>\s*)?(?:class|struct|union)\s+(\w+)[^=]*$)");
+    static const std::regex transparent_head(R"(^(?:namespace(?:\s+[\w:]+)?|extern\s+"C")$)");
+    static const std::regex access(R"(^(?:(?:public|protected|private)\s*:\s*)+)");
+    static const std::regex const_head(R"(^(?:(?:static|inline)\s+)*(?:constexpr|const)\s)");
+    static const std::regex declaration(
+        R"(^((?:(?:static|inline|constexpr|const)\s+)+)([A-Za-z_][\w:]*(?:\s+[A-Za-z_][\w:]*)*?)\s+(\w+)\s*(?:=\s*([\s\S]+)|\{([\s\S]*)\})$)");
+
+    std::string cleaned = declarationText(source);
+
+    // Class name, "" for transparent scopes, "{" for skipped ones
+    std::vector<std::string> scopes;
+    auto skipping = [&scopes]() {
+        return std::find(scopes.begin(), scopes.end(), "{") != scopes.end();
+    };
+
+    std::vector<SourceConstant> constants;
+    size_t statement = 0;
+    for (size_t pos = 0; pos < cleaned.size(); ++pos) {
+        char c = cleaned[pos];
+        if (c != '{' && c != '}' && c != ';') {
+            continue;
+        }
+        std::string text = trim(cleaned.substr(statement, pos - statement));
+        statement = pos + 1;
+        // Access specifiers share a statement with the next declaration
+        text = std::regex_replace(text, access, "");
+
+        std::smatch match;
+        if (c == '{' && !skipping() && std::regex_search(text, const_head)) {
+            // Brace initializer: the declaration runs on to "};"
+            size_t close = cleaned.find('}', pos);
+            size_t end = close == std::string::npos ? close : cleaned.find_first_not_of(" \t\r\n", close + 1);
+            if (end != std::string::npos && cleaned[end] == ';') {
+                text += "{" + cleaned.substr(pos + 1, close - pos - 1) + "}";
+                pos = end;
+                statement = end + 1;
+                c = ';';
+            }
+        }
+        if (c == '{') {
+            if (skipping()) {
+                scopes.push_back("{");
+            } else if (std::regex_match(text, match, class_head)) {
+                scopes.push_back(match[1].str());
+            } else if (std::regex_match(text, transparent_head)) {
+                scopes.push_back("");
+            } else {
+                scopes.push_back("{");
+            }
+            continue;
+        }
+        if (c == '}') {
+            if (!scopes.empty()) {
+                scopes.pop_back();
+            }
+            continue;
+        }
+
+        if (skipping() || !std::regex_match(text, match, declaration)) {
+            continue;
+        }
+        std::string specifiers = match[1].str();
+        std::string scope;
+        for (auto it = scopes.rbegin(); it != scopes.rend() && scope.empty(); ++it) {
+            scope = *it;
+        }
+        bool is_static = specifiers.find("static") != std::string::npos;
+        bool is_const = specifiers.find("const") != std::string::npos;
+        if (!is_const || (!scope.empty() && !is_static)) {
+            continue;
+        }
+        SourceConstant constant;
+        constant.scope = scope;
+        constant.type = trim(match[2].str());
+        constant.name = match[3].str();
+        constant.initializer = trim(match[4].matched ? match[4].str() : match[5].str());
+        constants.push_back(constant);
+    }
+    return constants;
+}
+
+/**
+ * Expression with every name qualified to a known constant (Class::NAME)
+ * or enumerator (Enum::NAME) and C++ literal suffixes dropped, so the Go
+ * generator can spell it with Go constant names. Empty if anything else
+ * is referenced: function calls, nullptr, unknown names.
+ */
+std::string qualifyExpression(const std::string& expression, const std::string& scope,
+                              const std::vector<FFIConstant>& constants, const std::vector<FFIEnum>& enums) {
+    static const std::regex token(
+        R"(\s+|"(?:[^"\\]|\\.)*"|(?:::\s*)?[A-Za-z_]\w*(?:\s*::\s*[A-Za-z_]\w*)*|[0-9][\w.]*(?:[eE][-+][0-9]+[fFlL]?)?|<<|>>|[-+*/%()|&^~])");
+
+    auto is_constant = [&constants](const std::string& name) {
+        return std::any_of(constants.begin(), constants.end(),
+                           [&name](const FFIConstant& c) { return c.name == name; });
+    };
+    auto declares = [](const FFIEnum& e, const std::string& enumerator) {
+        return std::any_of(e.enumerators.begin(), e.enumerators.end(),
+                           [&enumerator](const std::pair<std::string, std::string>& value) {
+                               return value.first == enumerator;
+                           });
+    };
+    auto enum_of = [&](const std::string& enumerator) -> std::string {
+        for (const auto& e : enums) {
+            if (declares(e, enumerator)) {
+                return e.name;
+            }
+        }
+        return "";
+    };
+    auto resolve = [&](const std::string& spelled) -> std::string {
+        std::vector<std::string> parts;
+        std::string name = std::regex_replace(spelled, std::regex(R"(\s+)"), "");
+        for (size_t start = name.compare(0, 2, "::") == 0 ? 2 : 0; start <= name.size();) {
+            size_t end = name.find("::", start);
+            parts.push_back(name.substr(start, end == std::string::npos ? std::string::npos : end - start));
+            start = end == std::string::npos ? name.size() + 1 : end + 2;
+        }
+        if (parts.size() == 1) {
+            if (!scope.empty() && is_constant(scope + "::" + parts[0])) {
+                return scope + "::" + parts[0];
+            }
+            if (is_constant(parts[0])) {
+                return parts[0];
+            }
+            std::string owner = enum_of(parts[0]);
+            return owner.empty() ? "" : owner + "::" + parts[0];
+        }
+        // Namespace qualification is dropped: constants and enums are keyed
+        // by their innermost scope
+        std::string qualified = parts[parts.size() - 2] + "::" + parts.back();
+        bool enumerator = std::any_of(enums.begin(), enums.end(), [&](const FFIEnum& e) {
+            return e.name == parts[parts.size() - 2] && declares(e, parts.back());
+        });
+        if (is_constant(qualified) || enumerator) {
+            return qualified;
+        }
+        if (is_constant(parts.back())) {
+            return parts.back();
+        }
+        return "";
+    };
+
+    std::string result;
+    size_t pos = 0;
+    std::smatch match;
+    while (pos < expression.size()) {
+        std::string rest = expression.substr(pos);
+        if (!std::regex_search(rest, match, token, std::regex_constants::match_continuous)) {
+            return "";
+        }
+        std::string text = match.str();
+        pos += text.size();
+        char first = text[0];
+        if (first == ':' || std::isalpha(static_cast<unsigned char>(first)) || first == '_') {
+            size_t next = expression.find_first_not_of(" \t\r\n", pos);
+            if (next != std::string::npos && expression[next] == '(') {
+                return "";
+            }
+            if (text == "true" || text == "false") {
+                result += text;
+                continue;
+            }
+            text = resolve(text);
+            if (text.empty()) {
+                return "";
+            }
+        } else if (std::isdigit(static_cast<unsigned char>(first))) {
+            bool hex = text.size() > 1 && (text[1] == 'x' || text[1] == 'X');
+            bool decimal = !hex && text.find_first_of(".eE") != std::string::npos;
+            while (!text.empty() && (std::strchr("uUlL", text.back()) ||
+                                     (decimal && std::strchr("fF", text.back())))) {
+                text.pop_back();
+            }
+        }
+        result += text;
+    }
+    return trim(result);
+}
+
+// Access specifiers, qualifiers and references around a member's result type
+const std::regex kMemberNoise(R"(\b(?:public|protected|private)\s*:|\b(?:const|constexpr|inline|virtual)\b|&)");
+
+/**
+ * Body of the class or struct named name, between its braces; "" if source
+ * does not define it
+ */
+std::string classBody(const std::string& source, const std::string& name) {
+    std::smatch match;
+    if (!std::regex_search(source, match, std::regex(R"(\b(?:class|struct)\s+)" + name + R"(\b[^;{]*\{)"))) {
+        return "";
+    }
+    size_t open = match.position(0) + match.length(0) - 1;
+    size_t close = open;
+    for (int depth = 0; close < source.size(); ++close) {
+        depth += source[close] == '{' ? 1 : source[close] == '}' ? -1 : 0;
+        if (depth == 0) {
+            break;
+        }
+    }
+    return source.substr(open + 1, close - open - 1);
+}
+
+/**
+ * Type operator*() of the iterator class named by iterator_type returns,
+ * without cv-qualifiers and reference, or "" if the class is not declared
+ * in source
+ */
+std::string dereferenceType(const std::string& source, const std::string& iterator_type) {
+    std::string name = iterator_type.substr(iterator_type.rfind(':') + 1);
+    name = trim(name.substr(0, name.find_first_of("&*")));
+    if (name.compare(0, 6, "const ") == 0) {
+        name = trim(name.substr(6));
+    }
+    if (!std::regex_match(name, std::regex(R"([A-Za-z_]\w*)"))) {
+        return "";
+    }
+
+    std::string body = classBody(source, name);
+    std::smatch match;
+    static const std::regex dereference(R"(([^;{}]*?)\boperator\s*\*\s*\(\s*\))");
+    if (!std::regex_search(body, match, dereference)) {
+        return "";
+    }
+    std::string type = trim(std::regex_replace(match[1].str(), kMemberNoise, ""));
+    return type == "auto" || type.find("decltype") != std::string::npos ? "" : type;
+}
+
+/**
+ * Result and argument types of the operator() of the functor class named
+ * functor, or an empty result if source declares none
+ */
+std::pair<std::string, std::vector<std::string>> callOperator(const std::string& source, const std::string& functor) {
+    if (!std::regex_match(functor, std::regex(R"([A-Za-z_]\w*)"))) {
+        return {};
+    }
+    std::string body = classBody(source, functor);
+    std::smatch match;
+    static const std::regex call(R"(([^;{}]*?)\boperator\s*\(\s*\)\s*\(([^()]*)\))");
+    if (!std::regex_search(body, match, call)) {
+        return {};
+    }
+
+    // Argument types without their names
+    static const std::regex named(R"((.*?[\w*&>])\s*\b[A-Za-z_]\w*\s*)");
+    std::vector<std::string> arguments;
+    std::stringstream list(match[2].str());
+    for (std::string argument; std::getline(list, argument, ',');) {
+        argument = trim(argument);
+        std::smatch name;
+        bool builtin = std::regex_match(argument, std::regex(R"((?:unsigned|signed)?\s*(?:char|short|int|long|float|double|bool)(?:\s+(?:int|long))*)"));
+        if (!builtin && std::regex_match(argument, name, named)) {
+            argument = trim(name[1].str());
+        }
+        if (!argument.empty() && argument != "void") {
+            arguments.push_back(argument);
+        }
+    }
+    return {trim(std::regex_replace(match[1].str(), kMemberNoise, "")), arguments};
+}
+
+/**
+ * A public operator[] taking one index: the result type and index type as
+ * written, and whether it is a const member
+ */
+struct Subscript {
+    std::string result;
+    std::string index;
+    bool is_const = false;
+};
+
+/**
+ * Public operator[] overloads of one parameter the class named name declares
+ * in source; a struct's members are public until an access specifier says
+ * otherwise
+ */
+std::vector<Subscript> subscriptOperators(const std::string& source, const std::string& name, bool is_struct) {
+    static const std::regex comment(R"(//[^\n]*|/\*[\s\S]*?\*/)");
+    static const std::regex access(R"(\b(public|protected|private)\s*:)");
+    static const std::regex subscript(R"(([^;{}]*?)\boperator\s*\[\s*\]\s*\(([^(),]*)\)\s*(const\b)?)");
+    static const std::regex named(R"((.*?[\w*&>])\s*\b[A-Za-z_]\w*)");
+    static const std::regex builtin(R"((?:unsigned|signed)?\s*(?:char|short|int|long)(?:\s+(?:int|long))*)");
+    std::string body = std::regex_replace(classBody(source, name), comment, "");
+
+    std::vector<Subscript> result;
+    for (auto it = std::sregex_iterator(body.begin(), body.end(), subscript); it != std::sregex_iterator(); ++it) {
+        const std::smatch& match = *it;
+        // The last access specifier in front of it decides
+        std::string before = body.substr(0, match.position(2));
+        bool is_public = is_struct;
+        for (auto label = std::sregex_iterator(before.begin(), before.end(), access); label != std::sregex_iterator();
+             ++label) {
+            is_public = (*label)[1] == "public";
+        }
+        std::string type = std::regex_replace(match[1].str(), std::regex(R"(^[\s\S]*\b(?:public|protected|private)\s*:)"), "");
+        type = trim(std::regex_replace(type, std::regex(R"(\b(?:inline|virtual|constexpr)\b)"), ""));
+        std::string index = trim(match[2].str());
+        std::smatch parameter;
+        if (!std::regex_match(index, builtin) && std::regex_match(index, parameter, named)) {
+            index = trim(parameter[1].str());
+        }
+        if (is_public && !type.empty() && !index.empty() && index != "void") {
+            result.push_back({type, index, match[3].matched});
+        }
+    }
+    return result;
+}
+
+/**
+ * What kind of callable a result type is: "a pointer to member function",
+ * "a pointer to data member", "a std::function" or "a function pointer",
+ * with the member's class in owner; "" for any other type. Aliases source
+ * declares with using or typedef are looked through
+ */
+std::string callableKind(const std::string& source, const std::string& type, std::string* owner = nullptr) {
+    std::string spelling = trim(type);
+    if (spelling.compare(0, 6, "const ") == 0) {
+        spelling = trim(spelling.substr(6));
+    }
+    std::smatch match;
+    if (std::regex_match(spelling, std::regex(R"([A-Za-z_]\w*)"))) {
+        std::regex alias(R"(\busing\s+)" + spelling + R"(\s*=\s*([^;]+);)");
+        std::regex typedef_alias(R"(\btypedef\s+([^;]*\b)" + spelling + R"(\b[^;]*);)");
+        if (std::regex_search(source, match, alias) || std::regex_search(source, match, typedef_alias)) {
+            spelling = match[1].str();
+        }
+    }
+
+    static const std::regex function_object(R"(^(?:std::)?(?:move_only_)?function\s*<)");
+    static const std::regex member_function(R"(\(\s*(\w+)\s*::\s*\*[^()]*\)\s*\()");
+    static const std::regex data_member(R"((\w+)\s*::\s*\*)");
+    static const std::regex function_pointer(R"(\(\s*\*[^()]*\)\s*\()");
+    if (std::regex_search(spelling, function_object)) {
+        return "a std::function";
+    }
+    if (std::regex_search(spelling, match, member_function)) {
+        if (owner) {
+            *owner = match[1].str();
+        }
+        return "a pointer to member function";
+    }
+    if (std::regex_search(spelling, match, data_member)) {
+        if (owner) {
+            *owner = match[1].str();
+        }
+        return "a pointer to data member";
+    }
+    return std::regex_search(spelling, function_pointer) ? "a function pointer" : "";
+}
+
+/**
+ * Why a callable result is not bound, and what to declare instead
+ */
+std::string callableReason(const std::string& type, const std::string& kind, const std::string& owner) {
+    std::string described = "Return type " + type + " is " + kind + (owner.empty() ? "" : " of " + owner);
+    if (kind == "a pointer to data member") {
+        return described + " and has no C equivalent: bind accessor methods instead";
+    }
+    std::string callback = "take a callback such as void (*fn)(int32_t, void*) with a void* context, "
+                           "which is bound to a Go func";
+    if (kind == "a pointer to member function") {
+        return described + ", which Go cannot call through C: return an enum naming the method and "
+                           "dispatch on it in a bound method, or " + callback;
+    }
+    return described + ", which Go cannot call through C: call it in C++ and return its result, or " + callback;
+}
+
+/**
+ * Functions and members returning a callable that the parser cannot read:
+ * declarator syntax such as R (C::*pick(int))() and members returning a
+ * std::function. Each comes with the class declaring it ("" for free
+ * functions) and its result type spelled as a type
+ */
+struct CallableDeclaration {
+    std::string owner;
+    std::string name;
+    std::string return_type;
+    bool is_static = false;
+};
+
+std::vector<CallableDeclaration> callableDeclarations(const std::string& source,
+                                                      const std::vector<std::string>& classes) {
+    static const std::regex declarator(
+        R"(([A-Za-z_][\w:<>,\s*&]*?)\(\s*((?:\w+\s*::\s*)?\*)\s*(\w+)\s*\([^()]*\)\s*\)\s*(\([^()]*\)\s*(?:const\b)?)[^;{]*[;{])");
+    static const std::regex function_object(
+        R"(((?:\bstatic\s+)?(?:std::)?(?:move_only_)?function\s*<[^;{}]*>)\s*(\w+)\s*\([^()]*\)[^;{]*[;{])");
+    static const std::regex access(R"(\b(public|protected|private)\s*:)");
+
+    std::vector<CallableDeclaration> found;
+    auto scan = [&](const std::string& text, const std::string& owner, bool is_struct) {
+        auto visible = [&](size_t position) {
+            bool is_public = is_struct;
+            std::string before = text.substr(0, position);
+            for (auto it = std::sregex_iterator(before.begin(), before.end(), access); it != std::sregex_iterator();
+                 ++it) {
+                is_public = (*it)[1].str() == "public";
+            }
+            return owner.empty() || is_public;
+        };
+        for (auto it = std::sregex_iterator(text.begin(), text.end(), declarator); it != std::sregex_iterator(); ++it) {
+            std::string result = std::regex_replace((*it)[1].str(), kMemberNoise, "");
+            bool is_static = std::regex_search(result, std::regex(R"(\bstatic\b)"));
+            result = trim(std::regex_replace(result, std::regex(R"(\b(?:static|extern)\b)"), ""));
+            if (visible(it->position(3))) {
+                found.push_back({owner, (*it)[3].str(),
+                                 result + " (" + trim((*it)[2].str()) + ")" + trim((*it)[4].str()), is_static});
+            }
+        }
+        for (auto it = std::sregex_iterator(text.begin(), text.end(), function_object);
+             it != std::sregex_iterator(); ++it) {
+            std::string result = trim((*it)[1].str());
+            bool is_static = result.compare(0, 7, "static ") == 0;
+            if (visible(it->position(2))) {
+                found.push_back({owner, (*it)[2].str(), is_static ? trim(result.substr(7)) : result, is_static});
+            }
+        }
+    };
+
+    // Members first, then free functions in what the class bodies leave
+    static const std::regex comment(R"(//[^\n]*|/\*[\s\S]*?\*/)");
+    std::string code = std::regex_replace(source, comment, " ");
+    std::string outside = code;
+    for (const auto& name : classes) {
+        std::string body = classBody(code, name);
+        if (body.empty()) {
+            continue;
+        }
+        bool is_struct = std::regex_search(code, std::regex(R"(\bstruct\s+)" + name + R"(\b[^;{]*\{)"));
+        scan(body, name, is_struct);
+        size_t at = outside.find(body);
+        if (at != std::string::npos) {
+            outside.replace(at, body.size(), std::string(body.size(), ' '));
+        }
+    }
+    scan(outside, "", false);
+    return found;
+}
+
+bool isPublic(const hybrid::ClassDecl& cls, const std::string& member) {
+    for (const auto& section : cls.access_sections) {
+        if (std::find(section.members.begin(), section.members.end(), member) != section.members.end()) {
+            return section.level == hybrid::ClassDecl::AccessSection::Public;
+        }
+    }
+    return cls.is_struct;
+}
+
+bool isOutputName(const std::string& name) {
+    auto ends_with = [&name](const std::string& suffix) {
+        return name.size() > suffix.size() && name.compare(name.size() - suffix.size(), suffix.size(), suffix) == 0;
+    };
+    return name == "out" || name.compare(0, 4, "out_") == 0 ||
+           (name.size() > 3 && name.compare(0, 3, "out") == 0 && std::isupper(static_cast<unsigned char>(name[3]))) ||
+           ends_with("_out") || ends_with("Out");
+}
+
+/**
+ * Direction of a scalar or std::string passed by non-const reference, or of
+ * a mirrored struct passed by non-const pointer or reference: // @inout
+ * [name...] first, then Doxygen @param[out] / @param[in,out], then the name.
+ * Anything undecided is in/out, which is also correct (if less convenient)
+ * for outputs.
+ */
+ParamDirection referenceDirection(const std::string& name, const DeclComment& comment) {
+    for (const auto& annotation : comment.annotations) {
+        if (annotation == "inout") {
+            return ParamDirection::InOut;
+        }
+        if (annotation.compare(0, 6, "inout ") == 0) {
+            std::istringstream names(annotation.substr(6));
+            std::string listed;
+            while (names >> listed) {
+                if (listed == name) {
+                    return ParamDirection::InOut;
+                }
+            }
+        }
+    }
+
+    static const std::regex documented(R"([@\\]param\s*\[\s*(in\s*,?\s*)?out\s*\]\s+(\w+))");
+    for (auto it = std::sregex_iterator(comment.doc.begin(), comment.doc.end(), documented);
+         it != std::sregex_iterator(); ++it) {
+        if ((*it)[2].str() == name) {
+            return (*it)[1].matched ? ParamDirection::InOut : ParamDirection::Out;
+        }
+    }
+
+    return isOutputName(name) ? ParamDirection::Out : ParamDirection::InOut;
+}
+
+/**
+ * Whether a C return type can carry a @status code
+ */
+bool isStatusType(const std::string& c_type) {
+    static const std::set<std::string> integers = {
+        "int", "long", "long long", "short", "unsigned int", "unsigned long",
+        "int8_t", "int16_t", "int32_t", "int64_t", "uint8_t", "uint16_t", "uint32_t", "uint64_t",
+    };
+    return integers.count(c_type) > 0;
+}
+
+/**
+ * Values of the integer C types a constraint may bound; false for floating
+ * point types, which take any number. long is 32 bits on Windows, so it is
+ * held to that
+ */
+bool integerRange(const std::string& c_type, long double* min, long double* max) {
+    static const std::map<std::string, std::pair<long double, long double>> ranges = {
+        {"int8_t", {-128.0L, 127.0L}},
+        {"int16_t", {-32768.0L, 32767.0L}}, {"short", {-32768.0L, 32767.0L}},
+        {"int32_t", {-2147483648.0L, 2147483647.0L}}, {"int", {-2147483648.0L, 2147483647.0L}},
+        {"long", {-2147483648.0L, 2147483647.0L}},
+        {"int64_t", {-9223372036854775808.0L, 9223372036854775807.0L}},
+        {"long long", {-9223372036854775808.0L, 9223372036854775807.0L}},
+        {"intptr_t", {-9223372036854775808.0L, 9223372036854775807.0L}},
+        {"uint8_t", {0.0L, 255.0L}},
+        {"uint16_t", {0.0L, 65535.0L}}, {"unsigned short", {0.0L, 65535.0L}},
+        {"uint32_t", {0.0L, 4294967295.0L}}, {"unsigned int", {0.0L, 4294967295.0L}},
+        {"unsigned long", {0.0L, 4294967295.0L}},
+        {"uint64_t", {0.0L, 18446744073709551615.0L}}, {"unsigned long long", {0.0L, 18446744073709551615.0L}},
+        {"size_t", {0.0L, 18446744073709551615.0L}},
+    };
+    auto range = ranges.find(c_type);
+    if (range == ranges.end()) {
+        return false;
+    }
+    *min = range->second.first;
+    *max = range->second.second;
+    return true;
+}
+
+/**
+ * Whether a constraint can bound a parameter: a number passed by value
+ */
+bool isConstrainable(const FFIParameter& param) {
+    long double min = 0;
+    long double max = 0;
+    return param.direction == ParamDirection::In && !param.is_enum && param.c_type.find('*') == std::string::npos &&
+           (integerRange(param.c_type, &min, &max) || param.c_type == "float" || param.c_type == "double");
+}
+
+/**
+ * Constraint of one sentence, as accepted by parseConstraint
+ */
+bool matchConstraint(const std::string& phrase, FFIConstraint& constraint) {
+    static const std::string number = R"(([-+]?(?:0x[0-9a-f]+|\d+(?:\.\d+)?(?:e[-+]?\d+)?)))";
+    static const std::regex between("between " + number + " and " + number +
+                                    R"((?:,? \(?(inclusive|exclusive)\)?)?)");
+    static const std::regex interval(R"((?:in )?(?:the )?(?:range )?([\[(]) ?)" + number + " ?, ?" + number +
+                                     R"( ?([\])]))");
+    static const std::regex one_of(R"(one of ((?:)" + number + R"(, )*)" + number + R"(,? or )" + number + ")");
+    static const std::regex values(number);
+    // Tried in order, so "greater than or equal to" is not read as "greater than"
+    static const std::vector<std::pair<std::regex, std::string>> bounds = {
+        {std::regex("(?:at least|no less than|not less than|greater than or equal to|>=) " + number), ">="},
+        {std::regex("(?:at most|no more than|not more than|no greater than|less than or equal to|<=) " + number),
+         "<="},
+        {std::regex("(?:greater than|more than|larger than|>) " + number), ">"},
+        {std::regex("(?:less than|smaller than|<) " + number), "<"},
+        {std::regex("non-?negative|not negative"), ">= 0"},
+        {std::regex("non-?positive|not positive"), "<= 0"},
+        {std::regex("positive"), "> 0"},
+        {std::regex("negative"), "< 0"},
+    };
+
+    std::smatch match;
+    if (std::regex_match(phrase, match, between)) {
+        constraint.min = match[1].str();
+        constraint.max = match[2].str();
+        constraint.min_exclusive = constraint.max_exclusive = match[3].str() == "exclusive";
+        return true;
+    }
+    if (std::regex_match(phrase, match, interval)) {
+        constraint.min = match[2].str();
+        constraint.max = match[3].str();
+        constraint.min_exclusive = match[1].str() == "(";
+        constraint.max_exclusive = match[4].str() == ")";
+        return true;
+    }
+    if (std::regex_match(phrase, match, one_of)) {
+        std::string listed = match[1].str();
+        for (auto it = std::sregex_iterator(listed.begin(), listed.end(), values); it != std::sregex_iterator(); ++it) {
+            constraint.values.push_back((*it)[1].str());
+        }
+        return true;
+    }
+
+    // One bound, or a lower and an upper one joined by "and"
+    size_t join = phrase.find(" and ");
+    std::vector<std::string> parts = {phrase.substr(0, join)};
+    if (join != std::string::npos) {
+        parts.push_back(phrase.substr(join + 5));
+    }
+    for (const auto& part : parts) {
+        auto bound = std::find_if(bounds.begin(), bounds.end(), [&part, &match](const std::pair<std::regex, std::string>& b) {
+            return std::regex_match(part, match, b.first);
+        });
+        if (bound == bounds.end()) {
+            return false;
+        }
+        std::string op = bound->second.substr(0, bound->second.find(' '));
+        std::string value = match.size() > 1 ? match[1].str() : "0";
+        bool lower = op[0] == '>';
+        if (!(lower ? constraint.min : constraint.max).empty()) {
+            return false;
+        }
+        (lower ? constraint.min : constraint.max) = value;
+        (lower ? constraint.min_exclusive : constraint.max_exclusive) = op.size() == 1;
+    }
+    return true;
+}
+
+/**
+ * Normalized phrasing of a parsed constraint, as the Go docs echo it
+ */
+std::string constraintText(const FFIConstraint& constraint) {
+    if (!constraint.values.empty()) {
+        std::string text = "one of ";
+        for (size_t i = 0; i < constraint.values.size(); ++i) {
+            text += (i == 0 ? "" : i + 1 == constraint.values.size() ? " or " : ", ") + constraint.values[i];
+        }
+        return text;
+    }
+    if (!constraint.min.empty() && !constraint.max.empty() && !constraint.min_exclusive && !constraint.max_exclusive) {
+        return "between " + constraint.min + " and " + constraint.max;
+    }
+    std::string lower = constraint.min.empty() ? ""
+                      : (constraint.min_exclusive ? "greater than " : "at least ") + constraint.min;
+    std::string upper = constraint.max.empty() ? ""
+                      : (constraint.max_exclusive ? "less than " : "at most ") + constraint.max;
+    return lower + (lower.empty() || upper.empty() ? "" : " and ") + upper;
+}
+
+/**
+ * Parse the constraint a sentence states of a parameter of C type c_type.
+ * Only whole phrasings are accepted, optionally after "must be" or
+ * "range:": "between 0 and 9", "[0, 1)", "at least 1 and at most 64",
+ * "greater than 0", "<= 100", "non-negative", "one of 8, 16 or 32". A
+ * sentence that reads like a constraint otherwise ("between 0 and 9 unless
+ * fast is set", "up to 4") is returned skipped, as is one whose bounds the
+ * type cannot hold; one saying nothing of values comes back empty.
+ */
+FFIConstraint parseConstraint(const std::string& sentence, const std::string& c_type) {
+    static const std::regex lead(R"(^(?:.*\b(?:must|should|shall|has to|needs to) (?:be|lie) |(?:valid |allowed )?(?:range|values)(?: is| are)?:? ))");
+    static const std::regex trigger(
+        R"(\b(?:between|at least|at most|greater|less than|more than|fewer|one of|range|positive|negative|)"
+        R"(minimum|maximum|exceed|above|below|up to|no more|no less)\b|[<>]|(?:^|\bin )[\[(] ?[-+]?\d)");
+    FFIConstraint constraint;
+
+    std::string phrase;
+    for (char c : sentence) {
+        if (std::isspace(static_cast<unsigned char>(c))) {
+            if (!phrase.empty() && phrase.back() != ' ') phrase += ' ';
+        } else {
+            phrase += static_cast<char>(std::tolower(static_cast<unsigned char>(c)));
+        }
+    }
+    phrase = trim(phrase);
+    while (!phrase.empty() && phrase.back() == '.') {
+        phrase.pop_back();
+    }
+    if (!std::regex_search(phrase, trigger)) {
+        return constraint;
+    }
+    // The phrasing may also close the sentence: "Fraction of the input, in the range [0, 1)"
+    std::smatch match;
+    std::string stated = std::regex_search(phrase, match, lead) ? match.suffix().str() : phrase;
+    bool matched = matchConstraint(stated, constraint);
+    for (size_t comma = stated.find(", "); !matched && comma != std::string::npos; comma = stated.find(", ", comma + 1)) {
+        constraint = FFIConstraint();
+        matched = matchConstraint(stated.substr(comma + 2), constraint);
+    }
+    if (!matched) {
+        FFIConstraint skipped;
+        skipped.skipped = "\"" + trim(sentence) + "\" is not a phrasing the generator checks";
+        return skipped;
+    }
+
+    // The bounds must be literals of the parameter's type
+    long double type_min = 0;
+    long double type_max = 0;
+    bool integer = integerRange(c_type, &type_min, &type_max);
+    std::vector<std::string> literals = constraint.values;
+    for (const auto& bound : {constraint.min, constraint.max}) {
+        if (!bound.empty()) literals.push_back(bound);
+    }
+    for (const auto& literal : literals) {
+        if (integer && literal.find("0x") == std::string::npos && literal.find_first_of(".e") != std::string::npos) {
+            constraint = FFIConstraint();
+            constraint.skipped = "\"" + trim(sentence) + "\": " + literal + " is not an integer";
+            return constraint;
+        }
+        long double value = std::stold(literal);
+        if (integer && (value < type_min || value > type_max)) {
+            constraint = FFIConstraint();
+            constraint.skipped = "\"" + trim(sentence) + "\": " + literal + " is outside the range of " + c_type;
+            return constraint;
+        }
+    }
+    if (!constraint.min.empty() && !constraint.max.empty()) {
+        long double min = std::stold(constraint.min);
+        long double max = std::stold(constraint.max);
+        if (min > max || (min == max && (constraint.min_exclusive || constraint.max_exclusive))) {
+            constraint = FFIConstraint();
+            constraint.skipped = "\"" + trim(sentence) + "\" allows no value";
+            return constraint;
+        }
+    }
+    // A bound the type itself keeps ("non-negative" of a size_t) needs no check
+    if (integer && !constraint.min.empty() && !constraint.min_exclusive && std::stold(constraint.min) == type_min) {
+        constraint.min.clear();
+    }
+    if (integer && !constraint.max.empty() && !constraint.max_exclusive && std::stold(constraint.max) == type_max) {
+        constraint.max.clear();
+    }
+    if (constraint.min.empty() && constraint.max.empty() && constraint.values.empty()) {
+        return FFIConstraint();
+    }
+    // Go compares literals without a sign, so a leading + goes
+    for (auto* literal : {&constraint.min, &constraint.max}) {
+        if (!literal->empty() && (*literal)[0] == '+') literal->erase(0, 1);
+    }
+    for (auto& literal : constraint.values) {
+        if (literal[0] == '+') literal.erase(0, 1);
+    }
+    constraint.text = constraintText(constraint);
+    return constraint;
+}
+
+/**
+ * Constraint of a parameter: FFIOptions::constraints first, then the
+ * Doxygen @param text of its declaration, whose sentences must state no
+ * more than one
+ */
+FFIConstraint parameterConstraint(const FFIParameter& param, const std::string& doc,
+                                  const std::map<std::string, std::string>* overrides) {
+    if (overrides && overrides->count(param.name)) {
+        const std::string& phrasing = overrides->at(param.name);
+        return phrasing.empty() ? FFIConstraint() : parseConstraint(phrasing, param.c_type);
+    }
+
+    // The @param paragraph runs until the next command or a blank line
+    static const std::regex documented(R"(^[@\\]param(?:\s*\[\s*in\s*\])?\s+(\w+)\s*(.*)$)");
+    std::string text;
+    bool inside = false;
+    std::istringstream lines(doc);
+    for (std::string line; std::getline(lines, line);) {
+        line = trim(line);
+        std::smatch match;
+        if (std::regex_match(line, match, documented)) {
+            inside = match[1].str() == param.name;
+            if (inside) text = match[2].str();
+        } else if (line.empty() || line[0] == '@' || line[0] == '\\') {
+            inside = false;
+        } else if (inside) {
+            text += " " + line;
+        }
+    }
+
+    FFIConstraint constraint;
+    static const std::regex sentence_end(R"(\.\s+|;\s*|\.$)");
+    for (auto it = std::sregex_token_iterator(text.begin(), text.end(), sentence_end, -1);
+         it != std::sregex_token_iterator(); ++it) {
+        FFIConstraint stated = parseConstraint(it->str(), param.c_type);
+        if (stated.text.empty() && stated.skipped.empty()) {
+            continue;
+        }
+        if (!constraint.text.empty() || !constraint.skipped.empty()) {
+            constraint = FFIConstraint();
+            constraint.skipped = "\"" + trim(text) + "\" states more than one constraint";
+            return constraint;
+        }
+        constraint = stated;
+    }
+    return constraint;
+}
+
+/**
+ * Length parameter of a std::unique_ptr<T[]> result: // @array_size <name>
+ * first, then a parameter named size, length or count. A length passed by
+ * non-const reference is written by the callee and becomes an output.
+ * Returns "" when no integer parameter qualifies.
+ */
+std::string arraySizeParameter(FFIFunction& func, const DeclComment& comment) {
+    std::vector<std::string> names = {"size", "length", "count"};
+    for (const auto& annotation : comment.annotations) {
+        if (annotation.compare(0, 11, "array_size ") == 0) {
+            names = {trim(annotation.substr(11))};
+        }
+    }
+
+    for (const auto& name : names) {
+        for (auto& param : func.parameters) {
+            // Scalars by non-const reference cross as pointers
+            std::string type = param.c_type;
+            if (param.direction != ParamDirection::In && !type.empty() && type.back() == '*') {
+                type.pop_back();
+            }
+            if (param.name != name || !(isStatusType(type) || type == "size_t")) {
+                continue;
+            }
+            if (param.direction == ParamDirection::InOut) {
+                param.direction = ParamDirection::Out;
+            }
+            return param.name;
+        }
+    }
+    return "";
+}
+
+/**
+ * Class named by a pointer or reference type: const Node* -> Node
+ */
+std::string pointeeType(const std::string& cpp_type) {
+    std::string name = trim(cpp_type.substr(0, cpp_type.find_first_of("&*")));
+    return name.compare(0, 6, "const ") == 0 ? trim(name.substr(6)) : name;
+}
+
+/**
+ * Default name of a visitor's collecting method: what follows for_each_ or
+ * forEach in its name, else the element, made plural (for_each_node -> nodes)
+ */
+std::string collectorName(const FFIFunction& func, const std::string& element) {
+    std::string name = element;
+    if (func.name.compare(0, 9, "for_each_") == 0 && func.name.size() > 9) {
+        name = func.name.substr(9);
+    } else if (func.name.compare(0, 7, "forEach") == 0 && func.name.size() > 7) {
+        name = func.name.substr(7);
+    }
+    char last = name.back();
+    if (last == 's' || last == 'x') {
+        return name + "es";
+    }
+    if (last == 'y' && name.size() > 1 && std::string("aeiou").find(name[name.size() - 2]) == std::string::npos) {
+        return name.substr(0, name.size() - 1) + "ies";
+    }
+    return name + "s";
+}
+
+/**
+ * A {...} block of a source: a namespace or linkage block, whose contents
+ * are at namespace scope, or any other
+ */
+struct BraceBlock {
+    size_t open = 0;
+    size_t close = std::string::npos;
+    std::string name;   // Namespace name ("engine::net"), "" for others
+    bool is_namespace = false;
+};
+
+/**
+ * Brace blocks of a source without comments, in order of their opening brace
+ */
+std::vector<BraceBlock> braceBlocks(const std::string& code) {
+    static const std::regex namespace_head(R"((?:^|[^\w])namespace\s*([\w:]*)\s*$)");
+    static const std::regex linkage_head(R"(extern\s*"C(?:\+\+)?"\s*$)");
+
+    std::vector<BraceBlock> blocks;
+    std::vector<size_t> open;
+    size_t statement = 0;
+    for (size_t pos = 0; pos < code.size(); ++pos) {
+        char c = code[pos];
+        if (c == '"' || c == '\'') {
+            for (pos++; pos < code.size() && code[pos] != c; pos++) {
+                if (code[pos] == '\\') pos++;
+            }
+        } else if (c == '{') {
+            BraceBlock block;
+            block.open = pos;
+            std::string head = code.substr(statement, pos - statement);
+            std::smatch match;
+            if (std::regex_search(head, match, namespace_head)) {
+                block.name = match[1].str();
+                block.is_namespace = true;
+            } else {
+                block.is_namespace = std::regex_search(head, linkage_head);
+            }
+            open.push_back(blocks.size());
+            blocks.push_back(block);
+            statement = pos + 1;
+        } else if (c == '}' || c == ';') {
+            if (c == '}' && !open.empty()) {
+                blocks[open.back()].close = pos;
+                open.pop_back();
+            }
+            statement = pos + 1;
+        }
+    }
+    return blocks;
+}
+
+/**
+ * Namespace enclosing pos ("engine::net"); at_namespace_scope tells whether
+ * pos is outside every class and function body
+ */
+std::string namespaceAt(const std::vector<BraceBlock>& blocks, size_t pos, bool* at_namespace_scope = nullptr) {
+    std::string name;
+    bool scope = true;
+    for (const auto& block : blocks) {
+        if (block.open >= pos) {
+            break;
+        }
+        if (block.close < pos) {
+            continue;
+        }
+        scope = scope && block.is_namespace;
+        if (!block.name.empty()) {
+            name += (name.empty() ? "" : "::") + block.name;
+        }
+    }
+    if (at_namespace_scope) {
+        *at_namespace_scope = scope;
+    }
+    return name;
+}
+
+/**
+ * Record the namespaces of classes, their members and free functions. The
+ * parser drops namespaces, so each is found again in the source: the n-th
+ * class or free function of a name at the n-th definition or namespace
+ * scope declaration of that name
+ */
+void assignNamespaces(FFIModule& module, const std::string& source) {
+    static const std::regex comment(R"(//[^\n]*|/\*[\s\S]*?\*/)");
+    std::string code = std::regex_replace(source, comment, " ");
+    std::vector<BraceBlock> blocks = braceBlocks(code);
+
+    auto positions = [&](const std::string& pattern) {
+        std::vector<size_t> found;
+        std::regex declaration(pattern);
+        for (auto it = std::sregex_iterator(code.begin(), code.end(), declaration); it != std::sregex_iterator();
+             ++it) {
+            bool at_scope = false;
+            namespaceAt(blocks, it->position(1), &at_scope);
+            if (at_scope) {
+                found.push_back(it->position(1));
+            }
+        }
+        return found;
+    };
+
+    std::map<std::string, size_t> seen;
+    for (auto& cls : module.classes) {
+        std::vector<size_t> found =
+            positions(R"(\b(?:class|struct|union)\s+()" + cls.name + R"(\b[^;{()]*\{))");
+        size_t index = seen[cls.name]++;
+        if (index < found.size()) {
+            cls.cpp_namespace = namespaceAt(blocks, found[index]);
+        }
+        for (auto* members : {&cls.methods, &cls.static_methods}) {
+            for (auto& method : *members) {
+                method.cpp_namespace = cls.cpp_namespace;
+            }
+        }
+    }
+
+    seen.clear();
+    for (auto& func : module.functions) {
+        std::vector<size_t> found = positions(R"((?:^|[^\w:])()" + func.name + R"(\s*\())");
+        size_t index = seen[func.name]++;
+        if (index < found.size()) {
+            func.cpp_namespace = namespaceAt(blocks, found[index]);
+        }
+    }
+}
+
+/**
+ * First position at or after pos, before end, that is neither whitespace
+ * nor inside a comment
+ */
+size_t skipBlanks(const std::string& text, size_t pos, size_t end) {
+    while (pos < end) {
+        if (std::isspace(static_cast<unsigned char>(text[pos]))) {
+            pos++;
+        } else if (text.compare(pos, 2, "//") == 0) {
+            pos = std::min(text.find('\n', pos), end);
+        } else if (text.compare(pos, 2, "/*") == 0) {
+            size_t close = text.find("*/", pos + 2);
+            pos = close == std::string::npos ? end : std::min(close + 2, end);
+        } else {
+            break;
+        }
+    }
+    return pos;
+}
+
+/**
+ * End of the declaration starting at pos: past its ';', or past the closing
+ * brace of a function body, namespace or linkage block. A directive ends
+ * with its line
+ */
+size_t declarationEnd(const std::string& text, size_t pos, size_t end) {
+    if (text[pos] == '#') {
+        size_t eol = pos;
+        do {
+            eol = text.find('\n', eol + 1);
+        } while (eol != std::string::npos && eol < end && text[eol - 1] == '\\');
+        return eol == std::string::npos ? end : std::min(eol + 1, end);
+    }
+
+    static const std::regex type_keyword(R"(\b(?:class|struct|union|enum)\b)");
+    size_t start = pos;
+    int depth = 0;
+    while (pos < end) {
+        char c = text[pos];
+        if (text.compare(pos, 2, "//") == 0 || text.compare(pos, 2, "/*") == 0) {
+            pos = skipBlanks(text, pos, end);
+            continue;
+        }
+        if (c == '"' || c == '\'') {
+            for (pos++; pos < end && text[pos] != c; pos++) {
+                if (text[pos] == '\\') pos++;
+            }
+        } else if (c == '{') {
+            depth++;
+        } else if (c == '}' && --depth == 0) {
+            // A type definition or braced initializer goes on to its ';'
+            std::string head = text.substr(start, text.find('{', start) - start);
+            size_t paren = head.find('(');
+            std::string declarator = head.substr(0, paren);
+            bool type = paren == std::string::npos && std::regex_search(head, type_keyword);
+            bool initializer = declarator.find('=') != std::string::npos &&
+                               declarator.find("operator") == std::string::npos;
+            if (!type && !initializer) {
+                return pos + 1;
+            }
+        } else if (c == ';' && depth == 0) {
+            return pos + 1;
+        }
+        pos++;
+    }
+    return end;
+}
+
+/**
+ * The declarations of text[begin, end) in a module purview that other units
+ * see, spelled as a header would: exported ones without their export
+ * keyword and with the comments in front of them, inside the namespaces and
+ * linkage blocks that hold them. Everything else, imports included, is
+ * dropped; so are using-declarations re-exporting a header's names, which
+ * the header declares already
+ */
+std::string exportedDeclarations(const std::string& text, size_t begin, size_t end, bool exported) {
+    static const std::regex export_block(R"(^export\s*\{)");
+    static const std::regex import_decl(R"(^(?:export\s+)?import\b)");
+    static const std::regex reexport(R"(^export\s+using\s+[\w:]+\s*;$)");
+    static const std::regex export_keyword(R"(^export\s+)");
+    static const std::regex scope_block(R"(^(?:(?:inline\s+)?namespace\b[^{;=]*|extern\s*"C"\s*)\{)");
+
+    std::string result;
+    size_t pos = begin;
+    while (pos < end) {
+        size_t start = skipBlanks(text, pos, end);
+        if (start == end) {
+            break;
+        }
+        std::string comments = text.substr(pos, start - pos);
+        pos = declarationEnd(text, start, end);
+        std::string decl = text.substr(start, pos - start);
+        size_t open = decl.find('{');
+        size_t close = decl.rfind('}');
+        std::smatch match;
+
+        if (decl[0] == '#') {
+            result += comments + decl;
+        } else if (std::regex_search(decl, import_decl) || std::regex_search(decl, reexport)) {
+            continue;
+        } else if (std::regex_search(decl, export_block)) {
+            result += exportedDeclarations(text, start + open + 1, start + close, true);
+        } else if (std::regex_search(decl, match, export_keyword)) {
+            result += comments + decl.substr(match.length(0));
+        } else if (std::regex_search(decl, scope_block) && close != std::string::npos) {
+            result += comments + decl.substr(0, open + 1) +
+                      exportedDeclarations(text, start + open + 1, start + close, exported) + "\n}";
+        } else if (exported) {
+            result += comments + decl;
+        }
+    }
+    return result + "\n";
+}
+
+/**
+ * A source with C++20 module interface units spelled as headers: whatever
+ * precedes the first module declaration is kept as it is, each interface
+ * unit (export module engine;) contributes its exported declarations, and
+ * global module fragments, implementation units and private module
+ * fragments are dropped. modules collects the names the shim imports, a
+ * partition's under its primary module. Returns false, leaving source
+ * unchanged, when there are no module declarations
+ */
+bool moduleDeclarations(std::string& source, std::vector<std::string>& modules) {
+    static const std::regex module_decl(R"((?:^|\n)[ \t]*(export[ \t]+)?module\b[ \t]*([\w.]*)[\w.:]*[ \t]*;)");
+
+    std::vector<std::smatch> units;
+    for (auto it = std::sregex_iterator(source.begin(), source.end(), module_decl);
+         it != std::sregex_iterator(); ++it) {
+        units.push_back(*it);
+    }
+    if (units.empty()) {
+        return false;
+    }
+
+    std::string result = source.substr(0, units[0].position(0));
+    for (size_t i = 0; i < units.size(); ++i) {
+        size_t begin = units[i].position(0) + units[i].length(0);
+        size_t end = i + 1 < units.size() ? units[i + 1].position(0) : source.size();
+        std::string name = units[i][2].str();
+        if (!units[i][1].matched || name.empty()) {
+            continue;
+        }
+        if (std::find(modules.begin(), modules.end(), name) == modules.end()) {
+            modules.push_back(name);
+        }
+        result += "\n" + exportedDeclarations(source, begin, end, false);
+    }
+    source = result;
+    return true;
+}
+
+/**
+ * Value of a preprocessor condition for a target: 1 or 0 when it only
+ * tests platform macros, -1 when anything else decides it
+ */
+class PlatformCondition {
+public:
+    PlatformCondition(const std::string& text, const std::set<std::string>& defined) : defined_(defined) {
+        static const std::regex token(R"(\s*(defined\b|[A-Za-z_]\w*|\d\w*|&&|\|\||!=?|[()]|\S))");
+        std::string code = std::regex_replace(text, std::regex(R"(/\*.*?\*/|//.*$)"), " ");
+        for (auto it = std::sregex_iterator(code.begin(), code.end(), token); it != std::sregex_iterator(); ++it) {
+            tokens_.push_back((*it)[1].str());
+        }
+    }
+
+    int evaluate() {
+        int value = disjunction();
+        return ok_ && pos_ == tokens_.size() ? value : -1;
+    }
+
+private:
+    const std::set<std::string>& defined_;
+    std::vector<std::string> tokens_;
+    size_t pos_ = 0;
+    bool ok_ = true;
+
+    bool accept(const std::string& text) {
+        if (pos_ < tokens_.size() && tokens_[pos_] == text) {
+            pos_++;
+            return true;
+        }
+        return false;
+    }
+
+    int macro(const std::string& name) const {
+        return ABIProfile::isPlatformMacro(name) ? static_cast<int>(defined_.count(name)) : -1;
+    }
+
+    int disjunction() {
+        int value = conjunction();
+        while (accept("||")) {
+            int rhs = conjunction();
+            value = value == 1 || rhs == 1 ? 1 : value == 0 && rhs == 0 ? 0 : -1;
+        }
+        return value;
+    }
+
+    int conjunction() {
+        int value = unary();
+        while (accept("&&")) {
+            int rhs = unary();
+            value = value == 0 || rhs == 0 ? 0 : value == 1 && rhs == 1 ? 1 : -1;
+        }
+        return value;
+    }
+
+    int unary() {
+        if (accept("!")) {
+            int value = unary();
+            return value < 0 ? value : 1 - value;
+        }
+        return primary();
+    }
+
+    int primary() {
+        if (pos_ >= tokens_.size()) {
+            ok_ = false;
+            return -1;
+        }
+        std::string text = tokens_[pos_++];
+        if (text == "(") {
+            int value = disjunction();
+            ok_ = accept(")") && ok_;
+            return value;
+        }
+        if (text == "defined") {
+            bool paren = accept("(");
+            if (pos_ >= tokens_.size() || !std::regex_match(tokens_[pos_], std::regex(R"([A-Za-z_]\w*)"))) {
+                ok_ = false;
+                return -1;
+            }
+            int value = macro(tokens_[pos_++]);
+            ok_ = (!paren || accept(")")) && ok_;
+            return value;
+        }
+        if (std::isdigit(static_cast<unsigned char>(text[0]))) {
+            return text == "0" ? 0 : text == "1" ? 1 : -1;
+        }
+        if (std::isalpha(static_cast<unsigned char>(text[0])) || text[0] == '_') {
+            // Platform macros are defined as nonzero values
+            return macro(text);
+        }
+        ok_ = false;
+        return -1;
+    }
+};
+
+/**
+ * The source as a compiler for a target with the given predefined macros
+ * sees it: branches of #if blocks decided by platform macros alone are
+ * kept or blanked and their directives dropped. Everything else, feature
+ * macros included, is left for the compiler; line numbers are unchanged
+ */
+std::string configureSource(const std::string& source, const std::vector<std::string>& macros) {
+    static const std::regex directive(R"(^\s*#\s*(if|ifdef|ifndef|elif|elifdef|elifndef|else|endif)\b(.*)$)");
+    std::set<std::string> defined(macros.begin(), macros.end());
+
+    struct Block {
+        bool decided;        // Its directives are evaluated and dropped
+        bool parent_active;
+        bool taken;          // A branch was already kept
+        bool active;
+    };
+    std::vector<Block> blocks;
+    auto active = [&blocks]() { return blocks.empty() || blocks.back().active; };
+    auto condition = [&defined](const std::string& keyword, const std::string& text) {
+        if (keyword == "if" || keyword == "elif") {
+            return PlatformCondition(text, defined).evaluate();
+        }
+        int value = PlatformCondition("defined " + text, defined).evaluate();
+        bool negated = keyword == "ifndef" || keyword == "elifndef";
+        return value < 0 || !negated ? value : 1 - value;
+    };
+
+    std::string result;
+    std::istringstream lines(source);
+    std::string line;
+    while (std::getline(lines, line)) {
+        // A directive continued over several lines is evaluated whole
+        std::string physical = line;
+        std::string logical = line;
+        size_t continued = 0;
+        while (!logical.empty() && logical.back() == '\\' && std::getline(lines, line)) {
+            physical += "\n" + line;
+            logical.pop_back();
+            logical += " " + line;
+            continued++;
+        }
+        std::string kept = logical;
+        std::smatch match;
+        if (std::regex_match(logical, match, directive)) {
+            std::string keyword = match[1].str();
+            std::string text = match[2].str();
+            if (keyword == "if" || keyword == "ifdef" || keyword == "ifndef") {
+                int value = active() ? condition(keyword, text) : 0;
+                if (!active()) {
+                    blocks.push_back({true, false, true, false});
+                } else if (value < 0) {
+                    blocks.push_back({false, true, false, true});
+                } else {
+                    blocks.push_back({true, true, value == 1, value == 1});
+                }
+                kept = blocks.back().decided ? "" : logical;
+            } else if (blocks.empty()) {
+                // Unbalanced: leave it for the compiler to report
+            } else if (!blocks.back().decided) {
+                if (keyword == "endif") {
+                    blocks.pop_back();
+                }
+            } else if (keyword == "endif") {
+                blocks.pop_back();
+                kept = "";
+            } else if (keyword == "else") {
+                Block& block = blocks.back();
+                block.active = block.parent_active && !block.taken;
+                block.taken = true;
+                kept = "";
+            } else {
+                Block& block = blocks.back();
+                int value = block.parent_active && !block.taken ? condition(keyword, text) : 0;
+                kept = "";
+                if (value < 0) {
+                    // The rest of the chain is left to the compiler, opened by this branch
+                    std::string test = keyword == "elif" ? "" : keyword == "elifdef" ? "defined " : "!defined ";
+                    kept = "#if " + test + text;
+                    block = {false, true, false, true};
+                } else {
+                    block.active = value == 1;
+                    block.taken = block.taken || value == 1;
+                }
+            }
+        } else if (!active()) {
+            kept = "";
+        }
+        result += (kept == logical ? physical : kept + std::string(continued, '\n')) + "\n";
+    }
+    if (!source.empty() && source.back() != '\n') {
+        result.pop_back();
+    }
+    return result;
+}
+
+/**
+ * What must agree for one binding to serve every configuration declaring a function
+ */
+std::string configurationSignature(const FFIFunction& func) {
+    std::string signature = func.cpp_namespace + "::" + func.class_name + "::" + func.name + "(";
+    for (const auto& param : func.parameters) {
+        signature += param.cpp_type + " " + param.c_type + " " +
+                     std::to_string(static_cast<int>(param.direction)) + ", ";
+    }
+    signature += ") " + func.return_type + " " + func.c_return_type + (func.is_const ? " const" : "") +
+                 (func.is_static ? " static" : "") + (func.can_use_ffi ? "" : " unbound");
+    return signature;
+}
+
+/**
+ * Target triples of the given configurations, or none when they are all of them
+ */
+std::vector<std::string> configurationTargets(const std::vector<size_t>& indexes, size_t count,
+                                              const std::vector<std::vector<std::string>>& triples) {
+    std::vector<std::string> targets;
+    if (indexes.size() == count) {
+        return targets;
+    }
+    for (size_t index : indexes) {
+        targets.insert(targets.end(), triples[index].begin(), triples[index].end());
+    }
+    return targets;
+}
+
+/**
+ * Functions of several configurations: those they declare alike once, the
+ * others once per signature with the targets declaring it
+ */
+std::vector<FFIFunction> mergeFunctions(const std::vector<std::pair<size_t, const std::vector<FFIFunction>*>>& lists,
+                                        const std::vector<std::vector<std::string>>& triples) {
+    std::vector<std::string> order;
+    std::map<std::string, std::pair<FFIFunction, std::vector<size_t>>> seen;
+    for (const auto& list : lists) {
+        for (const auto& func : *list.second) {
+            std::string signature = configurationSignature(func);
+            auto it = seen.find(signature);
+            if (it == seen.end()) {
+                order.push_back(signature);
+                seen.emplace(signature, std::make_pair(func, std::vector<size_t>{list.first}));
+            } else if (it->second.second.back() != list.first) {
+                it->second.second.push_back(list.first);
+            }
+        }
+    }
+
+    std::vector<FFIFunction> merged;
+    for (const auto& signature : order) {
+        FFIFunction func = seen[signature].first;
+        func.targets = configurationTargets(seen[signature].second, lists.size(), triples);
+        merged.push_back(func);
+    }
+    return merged;
+}
+
+/**
+ * One module from the analyses of a header's platform configurations, each
+ * compiled for the target triples listed at the same index. Classes and
+ * functions they disagree on are bound per target
+ * @throws std::invalid_argument if they declare an enum differently
+ */
+FFIModule mergeConfigurations(const std::vector<FFIModule>& modules,
+                              const std::vector<std::vector<std::string>>& triples) {
+    FFIModule merged = modules[0];
+    merged.classes.clear();
+
+    std::vector<std::pair<size_t, const std::vector<FFIFunction>*>> functions;
+    for (size_t i = 0; i < modules.size(); ++i) {
+        functions.push_back({i, &modules[i].functions});
+    }
+    merged.functions = mergeFunctions(functions, triples);
+
+    // Go has one type per enum, so its values must not depend on the target
+    for (size_t i = 1; i < modules.size(); ++i) {
+        for (const auto& ffi_enum : modules[i].enums) {
+            auto same = std::find_if(merged.enums.begin(), merged.enums.end(),
+                                     [&ffi_enum](const FFIEnum& e) { return e.name == ffi_enum.name; });
+            if (same == merged.enums.end()) {
+                merged.enums.push_back(ffi_enum);
+            } else if (same->underlying_type != ffi_enum.underlying_type || same->enumerators != ffi_enum.enumerators) {
+                throw std::invalid_argument("enum " + ffi_enum.name + " differs between " + triples[0][0] +
+                                            " and " + triples[i][0] + "; declare it the same for every target");
+            }
+        }
+    }
+
+    // Constants only some targets define, or define differently, are left out
+    merged.constants.clear();
+    for (const auto& constant : modules[0].constants) {
+        bool everywhere = std::all_of(modules.begin() + 1, modules.end(), [&constant](const FFIModule& module) {
+            return std::any_of(module.constants.begin(), module.constants.end(), [&constant](const FFIConstant& c) {
+                return c.name == constant.name && c.cpp_type == constant.cpp_type && c.value == constant.value;
+            });
+        });
+        if (everywhere) {
+            merged.constants.push_back(constant);
+        }
+    }
+
+    for (const auto& module : modules) {
+        for (const auto& name : module.modules) {
+            if (std::find(merged.modules.begin(), merged.modules.end(), name) == merged.modules.end()) {
+                merged.modules.push_back(name);
+            }
+        }
+    }
+
+    // Classes in the order the configurations first declare them
+    std::vector<std::string> class_names;
+    for (const auto& module : modules) {
+        for (const auto& cls : module.classes) {
+            if (std::find(class_names.begin(), class_names.end(), cls.name) == class_names.end()) {
+                class_names.push_back(cls.name);
+            }
+        }
+    }
+    auto sameField = [](const FFIParameter& a, const FFIParameter& b) {
+        return a.name == b.name && a.cpp_type == b.cpp_type && a.c_type == b.c_type;
+    };
+    for (const auto& name : class_names) {
+        std::vector<std::pair<size_t, const FFIClass*>> declared;
+        for (size_t i = 0; i < modules.size(); ++i) {
+            for (const auto& cls : modules[i].classes) {
+                if (cls.name == name) {
+                    declared.push_back({i, &cls});
+                }
+            }
+        }
+
+        FFIClass cls = *declared[0].second;
+        std::vector<size_t> indexes;
+        std::vector<std::pair<size_t, const std::vector<FFIFunction>*>> methods;
+        std::vector<std::pair<size_t, const std::vector<FFIFunction>*>> static_methods;
+        for (const auto& entry : declared) {
+            indexes.push_back(entry.first);
+            methods.push_back({entry.first, &entry.second->methods});
+            static_methods.push_back({entry.first, &entry.second->static_methods});
+        }
+        cls.targets = configurationTargets(indexes, modules.size(), triples);
+        // Members every configuration of the class has are not specific to a target
+        cls.methods = mergeFunctions(methods, triples);
+        cls.static_methods = mergeFunctions(static_methods, triples);
+
+        // Data members that differ make the layout target-specific
+        auto declares = [&declared, &sameField](const FFIParameter& field) {
+            return std::all_of(declared.begin(), declared.end(), [&](const std::pair<size_t, const FFIClass*>& entry) {
+                const auto& fields = entry.second->fields;
+                return std::any_of(fields.begin(), fields.end(),
+                                   [&](const FFIParameter& other) { return sameField(field, other); });
+            });
+        };
+        bool same_fields = std::all_of(declared.begin(), declared.end(), [&](const std::pair<size_t, const FFIClass*>& e) {
+            const auto& fields = e.second->fields;
+            return std::equal(cls.fields.begin(), cls.fields.end(), fields.begin(), fields.end(), sameField);
+        });
+        if (!same_fields) {
+            cls.fields.clear();
+            for (const auto& field : declared[0].second->fields) {
+                if (declares(field)) {
+                    cls.fields.push_back(field);
+                }
+            }
+            for (const auto& entry : declared) {
+                for (const auto& triple : triples[entry.first]) {
+                    cls.target_fields[triple] = entry.second->fields;
+                }
+            }
+        }
+        merged.classes.push_back(cls);
+    }
+
+    return merged;
+}
+
+} // namespace
+
 void FFIAnalyzer::initializeTypeMappings() {
     // C++ to C type mappings
     cpp_to_c_types_ = {
@@ -36,6 +1860,7 @@ void FFIAnalyzer::initializeTypeMappings() {
         {"uint32_t", "uint32_t"},
         {"uint64_t", "uint64_t"},
         {"size_t", "size_t"},
+        {"intptr_t", "intptr_t"},
         {"const char*", "const char*"},
         {"char*", "char*"},
     };
@@ -65,6 +1890,7 @@ void FFIAnalyzer::initializeTypeMappings() {
         {"uint32_t", "u32"},
         {"uint64_t", "u64"},
         {"size_t", "usize"},
+        {"intptr_t", "isize"},
         {"const char*", "*const i8"},
         {"char*", "*mut i8"},
     };
@@ -94,6 +1920,7 @@ void FFIAnalyzer::initializeTypeMappings() {
         {"uint32_t", "uint32"},
         {"uint64_t", "uint64"},
         {"size_t", "C.size_t"},
+        {"intptr_t", "C.intptr_t"},
         {"const char*", "*C.char"},
         {"char*", "*C.char"},
     };
@@ -138,6 +1965,1045 @@ FFIClass FFIAnalyzer::analyzeClass(const std::string& class_decl) {
     return cls;
 }
 
+FFIParameter FFIAnalyzer::analyzeType(const std::string& cpp_type, const FFIModule& module) {
+    FFIParameter param;
+    param.cpp_type = trim(cpp_type);
+
+    std::string base = param.cpp_type;
+    if (base.compare(0, 6, "const ") == 0) {
+        param.is_const = true;
+        base = trim(base.substr(6));
+    }
+    if (!base.empty() && (base.back() == '&' || base.back() == '*')) {
+        param.is_reference = base.back() == '&';
+        param.is_pointer = base.back() == '*';
+        base = trim(base.substr(0, base.size() - 1));
+    }
+
+    bool is_class = std::any_of(module.classes.begin(), module.classes.end(),
+                                [&base](const FFIClass& cls) { return cls.name == base; });
+    auto ffi_enum = std::find_if(module.enums.begin(), module.enums.end(),
+                                 [&base](const FFIEnum& e) { return e.name == base; });
+
+    size_t bits = CWrapperGenerator::bitsetWidth(param.cpp_type);
+    // std::wstring crosses as UTF-8 too; the shim converts it from and to wchar_t
+    bool text = base == "std::string" || base == "std::wstring";
+    if (text && !param.is_pointer && (!param.is_reference || param.is_const)) {
+        param.c_type = "const char*";
+    } else if (text && param.is_reference) {
+        // A local string in the shim, copied out through char** and size_t*;
+        // analyzeSource settles the direction
+        param.c_type = "char**";
+        param.direction = ParamDirection::InOut;
+    } else if (bits) {
+        param.c_type = bits > 64 ? "const uint64_t*" : "uint64_t";
+    } else if (base == "std::tm" || base == "tm" || base == "struct tm") {
+        // The C struct itself; Go converts it from and to time.Time
+        if (param.is_const || (!param.is_pointer && !param.is_reference)) {
+            param.c_type = param.is_pointer ? "const struct tm*" : "struct tm";
+        } else {
+            param.c_type = "struct tm*";
+            param.direction = ParamDirection::InOut;
+        }
+    } else if (ffi_enum != module.enums.end() && !param.is_pointer && (!param.is_reference || param.is_const)) {
+        param.c_type = ffi_enum->underlying_type;
+        param.cpp_type = base;
+        param.is_enum = true;
+    } else if (is_class && (param.is_reference || param.is_pointer)) {
+        // Objects cross the boundary as the opaque handles of their own shims
+        param.c_type = param.is_const ? "const void*" : "void*";
+        // A writable mirrored struct is a Go value instead; analyzeSource settles the direction
+        bool mirrored = std::any_of(module.classes.begin(), module.classes.end(),
+                                    [&base](const FFIClass& cls) { return cls.name == base && cls.is_mirrored; });
+        if (mirrored && !param.is_const) {
+            param.direction = ParamDirection::InOut;
+        }
+    } else if (base == "bool" && !param.is_reference) {
+        param.c_type = param.is_pointer ? (param.is_const ? "const bool*" : "bool*") : "bool";
+    } else if (cpp_to_c_types_.count(param.cpp_type)) {
+        param.c_type = cpp_to_c_types_[param.cpp_type];
+    } else if (cpp_to_c_types_.count(base) && base != "void" && !param.is_reference) {
+        param.c_type = cpp_to_c_types_[base];
+        if (param.is_pointer) {
+            param.c_type = (param.is_const ? "const " : "") + param.c_type + "*";
+        }
+    } else if (cpp_to_c_types_.count(base) && param.is_reference && param.is_const) {
+        // const T& of a scalar is passed by value
+        param.c_type = cpp_to_c_types_[base];
+    } else if (cpp_to_c_types_.count(base) && param.is_reference && base != "void" && base != "char" &&
+               base.find('*') == std::string::npos) {
+        // T& of a scalar is passed by pointer; analyzeSource settles the direction
+        param.c_type = (base == "bool" ? std::string("bool") : cpp_to_c_types_[base]) + "*";
+        param.direction = ParamDirection::InOut;
+    }
+
+    return param;
+}
+
+FFIModule FFIAnalyzer::analyzeSource(const std::string& cpp_source, const std::string& library_name) {
+    // Module interface units are analyzed as the headers their exports amount to
+    std::vector<std::string> modules;
+    std::string declarations = cpp_source;
+    if (moduleDeclarations(declarations, modules)) {
+        FFIModule module = analyzeSource(declarations, library_name);
+        module.modules = modules;
+        return module;
+    }
+
+    // Targets whose platform macros select different parts of the header are
+    // analyzed apart, and what they disagree on is bound per target
+    std::vector<std::string> configurations;
+    std::vector<std::vector<std::string>> configuration_triples;
+    for (const auto& profile : LayoutEngine::profiles(options_)) {
+        std::string configured = configureSource(cpp_source, profile.predefinedMacros());
+        auto it = std::find(configurations.begin(), configurations.end(), configured);
+        if (it == configurations.end()) {
+            configurations.push_back(configured);
+            configuration_triples.push_back({profile.triple});
+        } else {
+            configuration_triples[it - configurations.begin()].push_back(profile.triple);
+        }
+    }
+    if (configurations.size() > 1) {
+        std::vector<FFIModule> modules;
+        for (const auto& configured : configurations) {
+            modules.push_back(analyzeSource(configured, library_name));
+        }
+        return mergeConfigurations(modules, configuration_triples);
+    }
+    if (configurations.size() == 1 && configurations[0] != cpp_source) {
+        return analyzeSource(configurations[0], library_name);
+    }
+
+    FFIModule module;
+    hybrid::IR ir = hybrid::Parser::parseString(declarationText(cpp_source));
+    std::map<std::string, DeclComment> comments = extractComments(cpp_source);
+
+    module.enums = extractEnums(cpp_source);
+    for (auto& ffi_enum : module.enums) {
+        ffi_enum.underlying_type = toCType(ffi_enum.underlying_type);
+        ffi_enum.doc = comments[ffi_enum.name].doc;
+        for (const auto& annotation : comments[ffi_enum.name].annotations) {
+            if (annotation.compare(0, 13, "go:enum-names") == 0) {
+                std::string name = trim(annotation.substr(13));
+                ffi_enum.name_function = name.compare(0, 5, "func=") == 0 ? trim(name.substr(5)) : name;
+            }
+        }
+    }
+
+    // Scalar constants default arguments may refer to, in declaration order
+    // so each initializer resolves against the ones before it
+    for (const auto& source_constant : extractConstants(cpp_source)) {
+        FFIParameter type = analyzeType(source_constant.type, module);
+        FFIConstant constant;
+        constant.name = source_constant.scope.empty() ? source_constant.name
+                                                      : source_constant.scope + "::" + source_constant.name;
+        constant.cpp_type = source_constant.type;
+        constant.c_type = type.c_type;
+        constant.value = qualifyExpression(source_constant.initializer, source_constant.scope,
+                                           module.constants, module.enums);
+        bool scalar = !type.c_type.empty() && !type.is_pointer && !type.is_reference &&
+                      type.c_type.find('*') == std::string::npos;
+        if (scalar && !constant.value.empty()) {
+            module.constants.push_back(constant);
+        }
+    }
+
+    // The parser reports scoped enums as classes too
+    auto is_enum = [&module](const std::string& name) {
+        return std::any_of(module.enums.begin(), module.enums.end(),
+                           [&name](const FFIEnum& e) { return e.name == name; });
+    };
+
+    // Register every class and whether it is mirrored first, so handles and
+    // struct outputs resolve regardless of declaration order
+    for (const auto& decl : ir.getClasses()) {
+        if (!decl.is_template && !is_enum(decl.name)) {
+            const auto& annotations = comments[decl.name].annotations;
+            FFIClass cls;
+            cls.name = decl.name;
+            cls.is_mirrored = std::find(annotations.begin(), annotations.end(), "mirror") != annotations.end();
+            module.classes.push_back(cls);
+        }
+    }
+
+    // What a callback is called with: a class handle, text or a scalar
+    auto visitorElement = [&](const std::string& argument, const std::string& callback_type, std::string& reason) {
+        FFIParameter element = analyzeType(argument, module);
+        std::string base = pointeeType(element.cpp_type);
+        bool handle = (element.is_pointer || element.is_reference) &&
+                      std::any_of(module.classes.begin(), module.classes.end(), [&base](const FFIClass& c) {
+                          return c.name == base && !c.is_mirrored;
+                      });
+        bool scalar = !element.c_type.empty() && element.c_type.find('*') == std::string::npos &&
+                      !element.is_reference && !CWrapperGenerator::bitsetWidth(element.cpp_type);
+        // Text reaches Go as a NUL-terminated const char*, copied into a string
+        bool text = argument == "const char*" || argument == "const std::string&";
+        if (!handle && !scalar && !text) {
+            reason = "Callback " + callback_type + " must take a class, a string or a scalar element";
+        }
+        element.cpp_type = argument;
+        element.c_type = handle ? "void*" : element.c_type;
+        element.name = "element";
+        return element;
+    };
+
+    // What C++ gets back from a callback whose Go func panicked: false or the
+    // first enumerator unless // @panic_result names another value
+    auto settlePanicResult = [&](FFIFunction& func, const DeclComment& comment, const std::string& result) {
+        for (const auto& annotation : comment.annotations) {
+            if (annotation.compare(0, 13, "panic_result ") == 0) {
+                func.visitor.panic_result = trim(annotation.substr(13));
+            }
+        }
+        const std::string& value = func.visitor.panic_result;
+        std::string reason;
+        auto ffi_enum = std::find_if(module.enums.begin(), module.enums.end(),
+                                     [&result](const FFIEnum& e) { return e.name == result; });
+        if (value.empty()) {
+            return;
+        } else if (result == "void") {
+            reason = "// @panic_result needs a callback returning bool or an enum";
+        } else if (result == "bool" && value != "true" && value != "false") {
+            reason = "// @panic_result " + value + " is not a bool";
+        } else if (ffi_enum != module.enums.end() &&
+                   std::none_of(ffi_enum->enumerators.begin(), ffi_enum->enumerators.end(),
+                                [&value](const std::pair<std::string, std::string>& e) { return e.first == value; })) {
+            reason = "// @panic_result " + value + " is not an enumerator of " + result;
+        }
+        if (!reason.empty() && func.can_use_ffi) {
+            func.can_use_ffi = false;
+            func.reason = reason;
+        }
+    };
+
+    // visit/for_each: a function pointer called with each element and the
+    // void* context passed alongside it, which Go drives with a func
+    auto bindVisitor = [&](FFIFunction& func, const DeclComment& comment) {
+        std::vector<size_t> callbacks;
+        std::vector<size_t> contexts;
+        for (size_t i = 0; i < func.parameters.size(); ++i) {
+            if (func.parameters[i].cpp_type.find("(*)") != std::string::npos) {
+                callbacks.push_back(i);
+            } else if (func.parameters[i].cpp_type == "void*") {
+                contexts.push_back(i);
+            }
+        }
+        if (callbacks.empty()) {
+            return;
+        }
+
+        static const std::regex signature(R"((.+?)\s*\(\*\)\((.*)\))");
+        std::smatch match;
+        std::string callback_type = func.parameters[callbacks[0]].cpp_type;
+        std::regex_match(callback_type, match, signature);
+        std::string result = trim(match[1].str());
+        std::vector<std::string> arguments;
+        std::stringstream list(match[2].str());
+        for (std::string argument; std::getline(list, argument, ',');) {
+            arguments.push_back(trim(argument));
+        }
+        size_t context_index = std::find(arguments.begin(), arguments.end(), "void*") - arguments.begin();
+
+        std::string reason;
+        FFIParameter element;
+        FFIParameter returned = result == "void" || result == "bool" ? FFIParameter{} : analyzeType(result, module);
+        if (callbacks.size() > 1) {
+            reason = "Only one callback parameter is supported";
+        } else if (result != "void" && result != "bool" && !returned.is_enum) {
+            reason = "Callback " + callback_type + " must return void, bool or an enum";
+        } else if (arguments.size() != 2 || context_index == arguments.size() ||
+                   std::count(arguments.begin(), arguments.end(), "void*") != 1) {
+            reason = "Callback " + callback_type + " must take an element and a void* context";
+        } else if (contexts.size() != 1) {
+            reason = "Callback " + callback_type + " needs one void* context parameter passed with it";
+        } else {
+            element = visitorElement(arguments[1 - context_index], callback_type, reason);
+        }
+        if (!reason.empty()) {
+            if (func.can_use_ffi) {
+                func.can_use_ffi = false;
+                func.reason = reason;
+            }
+            return;
+        }
+
+        FFIParameter& callback = func.parameters[callbacks[0]];
+        FFIParameter& context = func.parameters[contexts[0]];
+        callback.name = callback.name.empty() ? "visit" : callback.name;
+        context.name = context.name.empty() ? "context" : context.name;
+        callback.c_type = "int64_t (*)(void*, uintptr_t)";
+        context.c_type = "uintptr_t";
+        func.visitor.callback = callback.name;
+        func.visitor.context = context.name;
+        func.visitor.element = element;
+        func.visitor.stops = result == "bool";
+        func.visitor.result = returned;
+        // Collecting ignores what the callback returns, which an enum result must not be
+        if (!returned.is_enum) {
+            func.visitor.collect = collectorName(func, element.c_type == "void*"        ? pointeeType(element.cpp_type)
+                                                       : element.c_type == "const char*" ? "string"
+                                                                                         : "value");
+        }
+        for (const auto& annotation : comment.annotations) {
+            if (annotation.compare(0, 8, "collect ") == 0 && !returned.is_enum) {
+                func.visitor.collect = trim(annotation.substr(8));
+            }
+        }
+        settlePanicResult(func, comment, result);
+    };
+
+    // A // @logger setter installs a callback called with an enum level and
+    // a message, which Go turns into slog records; a void* context passed
+    // with it stays unused
+    auto bindLogger = [&](FFIFunction& func, const std::string& return_type) {
+        std::vector<size_t> callbacks;
+        std::vector<size_t> contexts;
+        for (size_t i = 0; i < func.parameters.size(); ++i) {
+            if (func.parameters[i].cpp_type.find("(*)") != std::string::npos) {
+                callbacks.push_back(i);
+            } else if (func.parameters[i].cpp_type == "void*") {
+                contexts.push_back(i);
+            }
+        }
+
+        std::string reason;
+        std::string callback_type = callbacks.empty() ? "" : func.parameters[callbacks[0]].cpp_type;
+        static const std::regex signature(R"((.+?)\s*\(\*\)\((.*)\))");
+        std::smatch match;
+        std::vector<std::string> arguments;
+        if (std::regex_match(callback_type, match, signature)) {
+            std::stringstream list(match[2].str());
+            for (std::string argument; std::getline(list, argument, ',');) {
+                arguments.push_back(trim(argument));
+            }
+        }
+        FFIParameter level;
+        size_t messages = 0;
+        size_t levels = 0;
+        for (const auto& argument : arguments) {
+            FFIParameter type = analyzeType(argument, module);
+            if (argument == "const char*" || argument == "const std::string&") {
+                messages++;
+            } else if (type.is_enum) {
+                level = type;
+                levels++;
+            } else if (argument != "void*") {
+                levels = 2;
+            }
+        }
+
+        if (func.is_method) {
+            reason = "Only free functions are bound as // @logger setters";
+        } else if (callbacks.size() != 1 || func.parameters.size() != 1 + contexts.size() || contexts.size() > 1) {
+            reason = "A // @logger setter must take one log callback and at most a void* context";
+        } else if (return_type != "void") {
+            reason = "A // @logger setter must return void";
+        } else if (trim(match[1].str()) != "void" || messages != 1 || levels != 1 ||
+                   arguments.size() != 2 + contexts.size()) {
+            reason = "Log callback " + callback_type + " must return void and take an enum level and a "
+                     "const char* or const std::string& message" +
+                     (contexts.empty() ? "" : ", plus the void* context");
+        }
+        if (!reason.empty()) {
+            func.can_use_ffi = false;
+            func.reason = reason;
+            return;
+        }
+
+        FFIParameter& callback = func.parameters[callbacks[0]];
+        callback.name = callback.name.empty() ? "handler" : callback.name;
+        callback.c_type = "void (*)(" + level.c_type + ", const char*)";
+        func.logger.callback = callback.name;
+        func.logger.callback_type = callback_type;
+        func.logger.level = level;
+        if (!contexts.empty()) {
+            FFIParameter& context = func.parameters[contexts[0]];
+            context.name = context.name.empty() ? "context" : context.name;
+            context.c_type = "void*";
+            func.logger.context = context.name;
+        }
+    };
+
+    // A function template on a callable policy, listed with the functor it is
+    // instantiated with (// @instantiate <Functor>), takes a Go func for the
+    // policy through the visitor callback; the functor's operator() gives its
+    // signature. Other templates are not bound
+    auto bindPolicy = [&](FFIFunction& func, const hybrid::Function& source_func, const DeclComment& comment) {
+        std::vector<std::string> type_parameters;
+        for (const auto& parameter : source_func.template_parameters) {
+            type_parameters.push_back(parameter.kind == hybrid::TemplateParameter::TypeParam ? parameter.name : "");
+        }
+        std::vector<size_t> policies;
+        for (size_t i = 0; i < func.parameters.size(); ++i) {
+            std::string base = pointeeType(func.parameters[i].cpp_type);
+            base = base.substr(0, base.find_last_not_of("& ") + 1);
+            if (!base.empty() && std::find(type_parameters.begin(), type_parameters.end(), base) !=
+                                     type_parameters.end()) {
+                policies.push_back(i);
+            }
+        }
+        std::vector<std::string> functors;
+        for (const auto& annotation : comment.annotations) {
+            if (annotation.compare(0, 12, "instantiate ") == 0) {
+                functors.push_back(trim(annotation.substr(12)));
+            }
+        }
+        if (type_parameters.size() != 1 || policies.size() != 1) {
+            return;
+        }
+        const std::string& policy_type = type_parameters[0];
+        if (functors.empty()) {
+            func.reason = "Template function " + func.name + " is bound only for a listed instantiation: "
+                          "mark it // @instantiate <functor> to take a Go func for its " + policy_type;
+            return;
+        }
+        if (functors.size() > 1) {
+            func.reason = "Template function " + func.name + " lists more than one instantiation";
+            return;
+        }
+
+        auto call = callOperator(cpp_source, functors[0]);
+        std::string reason;
+        FFIParameter element;
+        if (call.first.empty()) {
+            reason = "Functor " + functors[0] + " of " + func.name + " declares no operator()";
+        } else if (call.first != "bool" || call.second.size() != 1) {
+            reason = "Functor " + functors[0] + " of " + func.name + " must return bool and take one argument";
+        } else {
+            element = visitorElement(call.second[0], functors[0], reason);
+        }
+        for (size_t i = 0; i < func.parameters.size() && reason.empty(); ++i) {
+            if (func.parameters[i].c_type.empty() && i != policies[0]) {
+                reason = "Parameter type " + func.parameters[i].cpp_type + " has no C equivalent";
+            }
+        }
+        if (!reason.empty()) {
+            func.reason = reason;
+            return;
+        }
+
+        FFIParameter& callback = func.parameters[policies[0]];
+        callback.c_type = "int64_t (*)(void*, uintptr_t)";
+        FFIParameter context;
+        context.name = callback.name + "_context";
+        context.cpp_type = "void*";
+        context.c_type = "uintptr_t";
+        func.visitor.callback = callback.name;
+        func.visitor.context = context.name;
+        func.visitor.element = element;
+        func.visitor.stops = true;
+        func.visitor.policy = functors[0];
+        func.parameters.insert(func.parameters.begin() + policies[0] + 1, context);
+        func.can_use_ffi = true;
+        func.reason.clear();
+        settlePanicResult(func, comment, "bool");
+    };
+
+    auto convert = [&](const hybrid::Function& source_func, const std::string& class_name) {
+        FFIFunction func;
+        func.name = source_func.name;
+        func.class_name = class_name;
+        func.is_method = !class_name.empty();
+        func.is_const = source_func.is_const;
+        func.is_static = source_func.is_static;
+        func.is_virtual = source_func.is_virtual;
+        // Templates have keys of their own, so a constrained overload keeps its annotations
+        std::string key = func.name + (source_func.is_template ? "<>/" : "/") +
+                          std::to_string(source_func.parameters.size());
+        const DeclComment& comment = comments[class_name.empty() ? key : class_name + "::" + key];
+        func.doc = comment.doc;
+        func.feature = comment.feature.empty() && !class_name.empty() ? comments[class_name].feature : comment.feature;
+        for (const auto& annotation : comment.annotations) {
+            func.main_thread_only = func.main_thread_only || annotation == "main_thread_only";
+            func.signal_unsafe = func.signal_unsafe || annotation == "signal_unsafe";
+            func.cached = func.cached || annotation == "cached";
+            func.throws = func.throws || annotation == "throws";
+            func.borrowed = func.borrowed || annotation == "borrowed";
+        }
+        bool logger = std::find(comment.annotations.begin(), comment.annotations.end(), "logger") !=
+                      comment.annotations.end();
+
+        bool skipped = std::find(comment.annotations.begin(), comment.annotations.end(), "skip") !=
+                       comment.annotations.end();
+        bool is_template = source_func.is_template;
+        if (skipped) {
+            func.can_use_ffi = false;
+            func.reason = "Left out with // @skip";
+        } else if (!source_func.constraints.empty()) {
+            // Whether enable_if and friends let the overload exist takes a compiler to tell
+            func.can_use_ffi = false;
+            func.reason = "Overload of " + func.name + " is constrained by " + source_func.constraints[0] +
+                          ", and whether it exists cannot be determined without compiling it";
+        } else if (is_template) {
+            func.can_use_ffi = false;
+            func.reason = "Template functions require monomorphization";
+        }
+
+        for (size_t i = 0; i < source_func.parameters.size(); ++i) {
+            const auto& source_param = source_func.parameters[i];
+            if (typeSpelling(source_param.type) == "...") {
+                // printf-style: Go formats the message, the shim passes it through "%s"
+                if (!func.parameters.empty() && func.parameters.back().cpp_type == "const char*") {
+                    func.printf_format = true;
+                } else if (func.can_use_ffi) {
+                    func.can_use_ffi = false;
+                    func.reason = "Variadic functions are bound only with a const char* format before ...";
+                }
+                continue;
+            }
+            FFIParameter param = analyzeType(typeSpelling(source_param.type), module);
+            param.name = source_param.name;
+            if (source_param.has_default) {
+                param.default_source = trim(source_param.default_value);
+                param.default_value = qualifyExpression(param.default_source, class_name,
+                                                        module.constants, module.enums);
+            }
+            func.parameters.push_back(param);
+
+            bool error_code_out = i + 1 == source_func.parameters.size() &&
+                                  CWrapperGenerator::hasErrorCodeOut(func);
+            // Callbacks and their void* context are settled by bindVisitor
+            bool callback = param.cpp_type.find("(*)") != std::string::npos || param.cpp_type == "void*";
+            if (param.c_type.empty() && !error_code_out && !callback && func.can_use_ffi) {
+                func.can_use_ffi = false;
+                func.reason = "Parameter type " + param.cpp_type + " has no C equivalent";
+            }
+        }
+        if (logger && func.can_use_ffi) {
+            bindLogger(func, typeSpelling(source_func.return_type));
+        } else if (!logger) {
+            bindVisitor(func, comment);
+        }
+        if (is_template && !skipped && source_func.constraints.empty()) {
+            bindPolicy(func, source_func, comment);
+        }
+        for (const auto& param : func.parameters) {
+            bool error_code_out = &param == &func.parameters.back() && CWrapperGenerator::hasErrorCodeOut(func);
+            if (param.c_type.empty() && !error_code_out && func.can_use_ffi) {
+                func.can_use_ffi = false;
+                func.reason = "Parameter type " + param.cpp_type + " has no C equivalent";
+            }
+        }
+
+        for (auto& param : func.parameters) {
+            if (param.direction == ParamDirection::InOut) {
+                param.direction = referenceDirection(param.name, comment);
+            }
+        }
+
+        // Documented constraints the Go wrapper checks arguments against
+        std::string qualified = class_name.empty() ? func.name : class_name + "::" + func.name;
+        auto overrides = options_.constraints.find(qualified);
+        for (auto& param : func.parameters) {
+            if (isConstrainable(param)) {
+                param.constraint = parameterConstraint(
+                    param, comment.doc, overrides == options_.constraints.end() ? nullptr : &overrides->second);
+            }
+        }
+        func.unvalidated = options_.unvalidated.count(qualified) > 0 ||
+                           std::find(comment.annotations.begin(), comment.annotations.end(), "novalidate") !=
+                               comment.annotations.end();
+
+        if (source_func.is_constructor) {
+            func.is_constructor = true;
+            bool has_output = std::any_of(func.parameters.begin(), func.parameters.end(), [](const FFIParameter& p) {
+                return p.direction == ParamDirection::Out;
+            });
+            if (has_output && func.can_use_ffi) {
+                func.can_use_ffi = false;
+                func.reason = "Constructors cannot have output parameters";
+            }
+            return func;
+        }
+
+        func.return_type = typeSpelling(source_func.return_type);
+        std::string value_type = func.return_type;
+        std::string element;
+        if (CWrapperGenerator::arrayReturnExtent(func, &element)) {
+            value_type = element;
+        } else if (CWrapperGenerator::uniqueArrayReturn(func, &element)) {
+            value_type = element;
+            func.array_size = arraySizeParameter(func, comment);
+            FFIParameter result = analyzeType(element, module);
+            if (result.c_type.empty() || result.is_enum || result.c_type.find('*') != std::string::npos ||
+                CWrapperGenerator::bitsetWidth(element)) {
+                if (func.can_use_ffi) {
+                    func.can_use_ffi = false;
+                    func.reason = "std::unique_ptr<T[]> results need a scalar element type";
+                }
+            } else if (func.array_size.empty() && func.can_use_ffi) {
+                func.can_use_ffi = false;
+                func.reason = "std::unique_ptr<T[]> results need a length parameter: name it size, length or "
+                              "count, or mark it // @array_size <name>";
+            }
+        } else if (CWrapperGenerator::expectedTypes(func, &value_type)) {
+            // c_return_type carries T; E is handled by the generators
+        }
+
+        if (value_type != "void") {
+            FFIParameter result = analyzeType(value_type, module);
+            // Untyped memory is handed over as is; FFIOptions::tagged_payloads may type it
+            if (value_type == "void*" || value_type == "const void*") {
+                result.c_type = value_type;
+            }
+            // Wide bitsets are returned through a uint64_t word buffer
+            func.c_return_type = CWrapperGenerator::bitsetWidth(value_type) > 64 ? "uint64_t" : result.c_type;
+            // Owned arrays cross as the released pointer
+            if (CWrapperGenerator::uniqueArrayReturn(func) && !result.c_type.empty()) {
+                func.c_return_type = result.c_type + "*";
+            }
+            func.returns_enum = result.is_enum;
+            // @status: an integer result is a status code, 0 on success
+            bool status = std::find(comment.annotations.begin(), comment.annotations.end(), "status") !=
+                          comment.annotations.end();
+            func.returns_status = status && isStatusType(func.c_return_type) &&
+                                  !CWrapperGenerator::hasErrorCodeOut(func) && !CWrapperGenerator::expectedTypes(func);
+            std::string owner;
+            std::string callable = callableKind(cpp_source, value_type, &owner);
+            if (!callable.empty() && func.can_use_ffi) {
+                func.can_use_ffi = false;
+                func.reason = callableReason(value_type, callable, owner);
+            }
+            bool text = value_type.find("std::string") != std::string::npos ||
+                        value_type.find("std::wstring") != std::string::npos;
+            if ((result.c_type.empty() || text) && func.can_use_ffi) {
+                func.can_use_ffi = false;
+                func.reason = "Return type " + value_type + " has no C equivalent";
+            }
+        }
+        return func;
+    };
+
+    for (const auto& decl : ir.getClasses()) {
+        if (decl.is_template || is_enum(decl.name)) {
+            continue;
+        }
+
+        auto cls = std::find_if(module.classes.begin(), module.classes.end(),
+                                [&decl](const FFIClass& c) { return c.name == decl.name; });
+        const DeclComment& comment = comments[decl.name];
+        cls->doc = comment.doc;
+        cls->feature = comment.feature;
+        cls->base_classes = decl.base_classes;
+        for (const auto& annotation : comment.annotations) {
+            // @poolable [method]: recycle instances with reset() or the named method
+            if (annotation == "poolable" || annotation.compare(0, 9, "poolable ") == 0) {
+                std::string method = trim(annotation.substr(8));
+                cls->pool_reset = method.empty() ? "reset" : method;
+            }
+            // @devirtualize: what Go constructs is of exactly this class
+            if (annotation == "devirtualize") {
+                cls->devirtualize = true;
+            }
+        }
+
+        for (const auto& field : decl.fields) {
+            if (isPublic(decl, field.name) && !field.is_static) {
+                FFIParameter param = analyzeType(typeSpelling(field.type), module);
+                param.name = field.name;
+                cls->fields.push_back(param);
+            }
+        }
+
+        std::set<std::string> constructors;
+        for (const auto& method : decl.methods) {
+            // Pure virtuals and virtuals shape the class even when not public
+            if (method.is_pure_virtual) {
+                cls->is_abstract = true;
+            }
+            if (method.is_virtual) {
+                cls->has_virtual_functions = true;
+                cls->is_polymorphic = true;
+            }
+            if (!isPublic(decl, method.name)) {
+                continue;
+            }
+
+            hybrid::Function source_method = method;
+            if (method.name == decl.name) {
+                source_method.is_constructor = true;
+            } else if (method.is_constructor) {
+                // A name-only match without return type, e.g. a member
+                // initializer, is not a constructor of this class
+                continue;
+            }
+
+            FFIFunction func = convert(source_method, decl.name);
+            if (func.is_constructor) {
+                // ~Class() matches the constructor pattern too
+                std::string signature;
+                for (const auto& param : func.parameters) {
+                    signature += param.cpp_type + ",";
+                }
+                if (!constructors.insert(signature).second) {
+                    continue;
+                }
+            }
+
+            if (func.is_static) {
+                cls->static_methods.push_back(func);
+            } else {
+                cls->methods.push_back(func);
+            }
+        }
+        // new cannot create an abstract class, whatever constructors it declares
+        if (cls->is_abstract) {
+            cls->methods.erase(std::remove_if(cls->methods.begin(), cls->methods.end(),
+                                              [](const FFIFunction& f) { return f.is_constructor; }),
+                               cls->methods.end());
+        }
+
+        // Public std::atomic<T> members of a scalar T get load and store accessors
+        for (const auto& field : cls->is_mirrored ? std::vector<FFIParameter>() : cls->fields) {
+            std::smatch atomic;
+            if (!std::regex_match(field.cpp_type, atomic, std::regex(R"(std::atomic\s*<\s*(.+?)\s*>)"))) {
+                continue;
+            }
+            FFIParameter value = analyzeType(atomic[1].str(), module);
+            if (value.c_type.empty() || value.c_type.find('*') != std::string::npos || value.is_enum ||
+                CWrapperGenerator::bitsetWidth(value.cpp_type)) {
+                continue;
+            }
+            value.name = "value";
+            for (const std::string access : {"load", "store"}) {
+                FFIFunction accessor;
+                accessor.name = access + "_" + field.name;
+                accessor.class_name = cls->name;
+                accessor.is_method = true;
+                accessor.field_name = field.name;
+                accessor.atomic = true;
+                if (access == "load") {
+                    accessor.is_const = true;
+                    accessor.return_type = value.cpp_type;
+                    accessor.c_return_type = value.c_type;
+                    accessor.doc = "Atomically loads " + cls->name + "::" + field.name + ".";
+                } else {
+                    accessor.return_type = "void";
+                    accessor.parameters.push_back(value);
+                    accessor.doc = "Atomically stores value into " + cls->name + "::" + field.name + ".";
+                }
+                cls->methods.push_back(accessor);
+            }
+        }
+
+        // operator[] with an integer index reads elements through at and, when
+        // it returns a mutable reference, writes them through set_at
+        const Subscript* reader = nullptr;
+        const Subscript* writer = nullptr;
+        std::vector<Subscript> subscripts =
+            cls->is_mirrored ? std::vector<Subscript>() : subscriptOperators(cpp_source, cls->name, decl.is_struct);
+        for (const auto& subscript : subscripts) {
+            std::string index = analyzeType(subscript.index, module).c_type;
+            if (!isStatusType(index) && index != "size_t") {
+                continue;
+            }
+            if (!reader || (subscript.is_const && !reader->is_const)) {
+                reader = &subscript;
+            }
+            bool mutable_reference = subscript.result.back() == '&' &&
+                                     !std::regex_search(subscript.result, std::regex(R"(\bconst\b)"));
+            if (!subscript.is_const && mutable_reference) {
+                writer = &subscript;
+            }
+        }
+        for (const auto& accessor_of : {std::make_pair("at", reader), std::make_pair("set_at", writer)}) {
+            std::string name = accessor_of.first;
+            const Subscript* subscript = accessor_of.second;
+            bool taken = std::any_of(cls->methods.begin(), cls->methods.end(),
+                                     [&name](const FFIFunction& f) { return f.name == name; });
+            if (!subscript || taken) {
+                continue;
+            }
+            FFIParameter value = analyzeType(trim(std::regex_replace(subscript->result, kMemberNoise, "")), module);
+            if (value.c_type.empty() || value.c_type.find('*') != std::string::npos || value.is_enum ||
+                CWrapperGenerator::bitsetWidth(value.cpp_type)) {
+                continue;
+            }
+            // Go indexes with int, which intptr_t matches on every target
+            FFIParameter index = analyzeType(subscript->index, module);
+            index.name = "i";
+            index.c_type = "intptr_t";
+            FFIFunction accessor;
+            accessor.name = name;
+            accessor.class_name = cls->name;
+            accessor.is_method = true;
+            accessor.subscript = true;
+            accessor.parameters.push_back(index);
+            if (name == "at") {
+                accessor.is_const = subscript->is_const;
+                accessor.return_type = value.cpp_type;
+                accessor.c_return_type = value.c_type;
+                accessor.doc = "Returns element i through " + cls->name + "::operator[].";
+            } else {
+                value.name = "value";
+                accessor.return_type = "void";
+                accessor.parameters.push_back(value);
+                accessor.doc = "Stores value at element i through " + cls->name + "::operator[].";
+            }
+            cls->methods.push_back(accessor);
+        }
+
+        // begin()/end(), whose iterators may be proxies, are driven by iteration
+        // shims; // @iterable <type> names what they yield when the header does not
+        auto member = [&cls](const std::string& name) {
+            return std::find_if(cls->methods.begin(), cls->methods.end(), [&name](const FFIFunction& f) {
+                return f.name == name && f.parameters.empty();
+            });
+        };
+        auto begin = member("begin");
+        if (begin == cls->methods.end() || member("end") == cls->methods.end()) {
+            continue;
+        }
+        std::string element = dereferenceType(cpp_source, begin->return_type);
+        for (const auto& annotation : comment.annotations) {
+            if (annotation.compare(0, 9, "iterable ") == 0) {
+                element = trim(annotation.substr(9));
+            }
+        }
+        FFIParameter type = analyzeType(element, module);
+        bool wrapped = std::any_of(module.classes.begin(), module.classes.end(), [&element](const FFIClass& c) {
+            return c.name == element && !c.is_mirrored;
+        });
+        bool value = !type.c_type.empty() && type.c_type.find('*') == std::string::npos &&
+                     !CWrapperGenerator::bitsetWidth(element);
+        if (element.empty() || (!wrapped && !value)) {
+            continue;
+        }
+        cls->iterator_element = type;
+        cls->iterator_element.cpp_type = element;
+        if (wrapped) {
+            cls->iterator_element.c_type = "void*";
+        }
+        cls->iterator_const = true;
+        for (auto& method : cls->methods) {
+            if (method.name == "begin" || method.name == "end") {
+                cls->iterator_const = cls->iterator_const && method.is_const;
+                method.can_use_ffi = false;
+                method.reason = "Iterated in Go through Range and Elements";
+            }
+        }
+    }
+
+    for (const auto& source_func : ir.getFunctions()) {
+        FFIFunction func = convert(source_func, "");
+        if (func.name == "main") {
+            continue;
+        }
+        // A visitor taking an object first is bound as a method of its class
+        std::string owner = func.parameters.empty() ? "" : pointeeType(func.parameters[0].cpp_type);
+        auto cls = std::find_if(module.classes.begin(), module.classes.end(),
+                                [&owner](const FFIClass& c) { return c.name == owner && !c.is_mirrored; });
+        if (!func.visitor.callback.empty() && cls != module.classes.end() &&
+            (func.parameters[0].c_type == "void*" || func.parameters[0].c_type == "const void*")) {
+            func.visitor.free_receiver = func.parameters[0].cpp_type;
+            func.class_name = owner;
+            func.is_method = true;
+            func.is_const = func.parameters[0].is_const;
+            func.parameters.erase(func.parameters.begin());
+            cls->methods.push_back(func);
+            continue;
+        }
+        // A variadic extern "C" function still needs a fixed-arity shim, and a visitor or logger an adapter
+        if (!isExternC(cpp_source, func.name) || func.printf_format || !func.visitor.callback.empty() ||
+            !func.logger.callback.empty()) {
+            std::string prefix;
+            for (char c : library_name) {
+                prefix += std::isalnum(static_cast<unsigned char>(c)) ? c : '_';
+            }
+            func.c_name = prefix + "_" + func.name;
+        }
+        module.functions.push_back(func);
+    }
+
+    // Go has one SetLogger, so one setter installs the log callback
+    std::string logger;
+    for (auto& func : module.functions) {
+        if (func.logger.callback.empty() || !func.can_use_ffi) {
+            continue;
+        }
+        if (!logger.empty()) {
+            func.can_use_ffi = false;
+            func.reason = "Only one // @logger setter is bound per library, and " + logger + " already is";
+        }
+        logger = logger.empty() ? func.name : logger;
+    }
+
+    // Declarations returning a callable the parser skipped are still reported
+    std::vector<std::string> class_names;
+    for (const auto& cls : module.classes) {
+        class_names.push_back(cls.name);
+    }
+    for (const auto& declaration : callableDeclarations(cpp_source, class_names)) {
+        auto cls = std::find_if(module.classes.begin(), module.classes.end(),
+                                [&declaration](const FFIClass& c) { return c.name == declaration.owner; });
+        std::vector<FFIFunction>& siblings = declaration.owner.empty() ? module.functions
+                                             : declaration.is_static   ? cls->static_methods
+                                                                       : cls->methods;
+        bool known = std::any_of(siblings.begin(), siblings.end(),
+                                 [&declaration](const FFIFunction& f) { return f.name == declaration.name; });
+        if (known || (!declaration.owner.empty() && cls == module.classes.end())) {
+            continue;
+        }
+        std::string owner;
+        std::string callable = callableKind(cpp_source, declaration.return_type, &owner);
+        if (callable.empty()) {
+            continue;
+        }
+        FFIFunction func;
+        func.name = declaration.name;
+        func.class_name = declaration.owner;
+        func.is_method = !declaration.owner.empty();
+        func.is_static = declaration.is_static;
+        func.return_type = declaration.return_type;
+        func.doc = comments[declaration.name].doc;
+        func.can_use_ffi = false;
+        func.reason = callableReason(declaration.return_type, callable, owner);
+        siblings.push_back(func);
+    }
+
+    // Collected class elements are copies: by their // @clone member if they
+    // have one, else by copy construction, which abstract classes lack. A
+    // collector may not share a name with another member of its owner
+    auto settleCollectors = [&](std::vector<FFIFunction>& functions) {
+        // for_each_node and forEachNode bind to the same Go name
+        auto key = [](const std::string& name) {
+            std::string folded;
+            for (char c : name) {
+                if (c != '_') {
+                    folded += static_cast<char>(std::tolower(static_cast<unsigned char>(c)));
+                }
+            }
+            return folded;
+        };
+        std::set<std::string> taken;
+        for (const auto& func : functions) {
+            taken.insert(key(func.name));
+        }
+        for (auto& func : functions) {
+            FFIVisitor& visitor = func.visitor;
+            if (visitor.callback.empty() || !func.can_use_ffi) {
+                continue;
+            }
+            if (visitor.element.c_type == "void*") {
+                std::string element = pointeeType(visitor.element.cpp_type);
+                for (const auto& annotation : comments[element].annotations) {
+                    if (annotation == "clone" || annotation.compare(0, 6, "clone ") == 0) {
+                        visitor.clone = annotation == "clone" ? "clone" : trim(annotation.substr(6));
+                    }
+                }
+                auto cls = std::find_if(module.classes.begin(), module.classes.end(),
+                                        [&element](const FFIClass& c) { return c.name == element; });
+                if (visitor.clone.empty() && cls->is_abstract) {
+                    visitor.collect.clear();
+                }
+            }
+            if (!visitor.collect.empty() && !taken.insert(key(visitor.collect)).second) {
+                visitor.collect = func.name + "_" + visitor.collect;
+                taken.insert(key(visitor.collect));
+            }
+        }
+    };
+    settleCollectors(module.functions);
+    for (auto& cls : module.classes) {
+        settleCollectors(cls.methods);
+    }
+
+    // Free functions and static members returning a mutable T* of a class are
+    // factories, bound to return its wrapper; the caller owns the object
+    // unless the factory is // @borrowed. Factories are the only source of an
+    // abstract class, so their nullptr is an error rather than an empty result
+    auto settleFactories = [&](std::vector<FFIFunction>& functions) {
+        for (auto& func : functions) {
+            std::string type = func.return_type;
+            type.erase(std::remove(type.begin(), type.end(), ' '), type.end());
+            if ((func.is_method && !func.is_static) || func.c_return_type != "void*" ||
+                func.return_type.compare(0, 6, "const ") == 0 || std::count(type.begin(), type.end(), '*') != 1 ||
+                type.back() != '*') {
+                continue;
+            }
+            std::string element = pointeeType(func.return_type);
+            auto cls = std::find_if(module.classes.begin(), module.classes.end(),
+                                    [&element](const FFIClass& c) { return c.name == element; });
+            if (cls == module.classes.end()) {
+                continue;
+            }
+            func.factory = element;
+            func.interface_factory = cls->is_abstract && !cls->is_mirrored;
+            cls->borrowed = cls->borrowed || func.borrowed;
+        }
+    };
+    settleFactories(module.functions);
+    for (auto& cls : module.classes) {
+        settleFactories(cls.static_methods);
+    }
+
+    assignNamespaces(module, cpp_source);
+    assignSymbols(module, library_name);
+    return module;
+}
+
+void FFIAnalyzer::assignSymbols(FFIModule& module, const std::string& library_name) const {
+    if (options_.legacy_symbol_names) {
+        return;
+    }
+    std::string prefix = options_.symbol_prefix;
+    if (prefix.empty()) {
+        for (char c : library_name) {
+            prefix += std::isalnum(static_cast<unsigned char>(c)) ? c : '_';
+        }
+    } else if (!std::regex_match(prefix, std::regex(R"([A-Za-z_]\w*)"))) {
+        throw std::invalid_argument("symbol_prefix: '" + prefix + "' is not a C identifier");
+    }
+    auto stem = [&prefix](const std::string& cpp_namespace) {
+        std::string result = prefix;
+        if (!cpp_namespace.empty()) {
+            result += "_" + std::regex_replace(cpp_namespace, std::regex("::"), "_");
+        }
+        return result;
+    };
+
+    // Overloads bound under one name are told apart by their index among them
+    auto name = [](const std::vector<FFIFunction*>& bound, const std::string& scope) {
+        auto base = [&scope](const FFIFunction& func) {
+            return scope + "_" + (func.is_constructor ? "new" : func.is_destructor ? "delete" : func.name);
+        };
+        std::map<std::string, size_t> overloads;
+        for (const auto* func : bound) {
+            overloads[base(*func)]++;
+        }
+        std::map<std::string, size_t> index;
+        for (auto* func : bound) {
+            std::string symbol = base(*func);
+            func->c_name = overloads[symbol] > 1 ? symbol + "_" + std::to_string(index[symbol]++) : symbol;
+        }
+    };
+
+    // Free functions bound under their own extern "C" name have no shim
+    std::map<std::string, std::vector<FFIFunction*>> free_functions;
+    for (auto& func : module.functions) {
+        if (func.can_use_ffi && !func.c_name.empty()) {
+            free_functions[stem(func.cpp_namespace)].push_back(&func);
+        }
+    }
+    for (const auto& scope : free_functions) {
+        name(scope.second, scope.first);
+    }
+
+    for (auto& cls : module.classes) {
+        cls.symbol_stem = stem(cls.cpp_namespace) + "_" + cls.name;
+        std::vector<FFIFunction*> bound;
+        for (auto* members : {&cls.methods, &cls.static_methods}) {
+            for (auto& method : *members) {
+                if (method.can_use_ffi) {
+                    bound.push_back(&method);
+                }
+            }
+        }
+        name(bound, cls.symbol_stem);
+    }
+}
+
 bool FFIAnalyzer::isFFICompatible(const std::string& cpp_type) {
     // Remove const, volatile, etc.
     std::string clean_type = cpp_type;
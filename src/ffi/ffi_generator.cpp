@@ -0,0 +1,154 @@
+/**
+ * @file ffi_generator.cpp
+ * @brief Entry point tying the analyzer to the binding generators
+ *
+ * Analyzes the public API of a C++ source once and hands the result to the
+ * generator for the requested target: Go (cgo) bindings, or a standalone C
+ * header for any other language that can call a C ABI.
+ */
+
+#include "ffi.h"
+#include <algorithm>
+#include <stdexcept>
+
+namespace hybrid_transpiler {
+namespace ffi {
+
+FFIGenerator::FFIGenerator() : FFIGenerator(FFIOptions{}) {}
+
+FFIGenerator::FFIGenerator(const FFIOptions& options)
+    : options_(options), analyzer_(options), go_generator_(options), c_wrapper_generator_(options) {}
+
+std::string FFIGenerator::generate(
+    const std::string& cpp_source,
+    const std::string& library_name,
+    const std::string& target_lang
+) {
+    FFIModule module = analyzer_.analyzeSource(cpp_source, library_name);
+
+    if (target_lang == "go") {
+        return go_generator_.generatePackage(module.functions, module.classes, library_name, module.enums,
+                                              module.constants);
+    }
+    if (target_lang == "c-header") {
+        return c_wrapper_generator_.generateCHeader(module, library_name);
+    }
+
+    throw std::invalid_argument("Unsupported FFI target: " + target_lang);
+}
+
+std::map<std::string, std::string> FFIGenerator::generateGoFiles(
+    const std::string& cpp_source,
+    const std::string& library_name
+) {
+    FFIModule module = analyzer_.analyzeSource(cpp_source, library_name);
+    std::map<std::string, std::string> files = go_generator_.generateLayoutFiles(module.classes, library_name);
+    files[library_name + ".go"] = go_generator_.generatePackage(module.functions, module.classes, library_name,
+                                                                module.enums, module.constants);
+    files[library_name + "_signal_unsafe.go"] = go_generator_.generateSignalUnsafeFile(
+        module.functions, module.classes, library_name, module.enums, module.constants);
+    if (options_.benchmarks) {
+        files[library_name + "_pool_test.go"] = go_generator_.generatePoolBenchmarks(module.classes, library_name);
+        files[library_name + "_devirtualize_test.go"] =
+            go_generator_.generateDevirtualizeBenchmarks(module.classes, library_name);
+        files[library_name + "_small_strings_test.go"] =
+            go_generator_.generateSmallStringBenchmarks(module.functions, module.classes, library_name);
+        files[library_name + "_scratch_arena_test.go"] =
+            go_generator_.generateScratchArenaBenchmarks(module.functions, module.classes, library_name);
+    }
+    files["mappings.go"] = go_generator_.generateMappings(module.functions, module.classes, library_name,
+                                                          module.enums, module.constants);
+    for (const auto& file : go_generator_.generateFeatureFiles(module.functions, module.classes, library_name,
+                                                               module.enums, module.constants)) {
+        files[file.first] = file.second;
+    }
+    for (const auto& file : go_generator_.generateTargetFiles(module.functions, module.classes, library_name,
+                                                              module.enums, module.constants)) {
+        files[file.first] = file.second;
+    }
+    // The preambles include the header, so it is only ever written with them
+    files["generated_decls.h"] = go_generator_.generateDeclsHeader(module.functions, module.classes, library_name);
+    return files;
+}
+
+std::string FFIGenerator::generateSingleGoFile(
+    const std::string& cpp_source,
+    const std::string& library_name
+) {
+    if (options_.decls_header) {
+        throw std::invalid_argument("A single Go file declares the shims in its own preamble; "
+                                    "turn off decls_header");
+    }
+
+    // Benchmarks and mappings.go only describe the bindings; everything else
+    // the package needs beyond <library>.go is selected by build constraints
+    std::set<std::string> target_files;
+    for (const auto& profile : LayoutEngine::profiles(options_)) {
+        target_files.insert(library_name + "_" + profile.go_os + "_" + profile.go_arch + ".go");
+    }
+    std::string package;
+    for (const auto& file : generateGoFiles(cpp_source, library_name)) {
+        const std::string& name = file.first;
+        bool test = name.size() > 8 && name.compare(name.size() - 8, 8, "_test.go") == 0;
+        if (name == library_name + ".go") {
+            package = file.second;
+        } else if (!file.second.empty() && !test && name != "mappings.go") {
+            std::string reason = name.find("_layout_") != std::string::npos
+                ? "mirrored structs whose layout differs between the target triples need a file per "
+                  "target; configure a single target or LayoutMismatchPolicy::Accessors"
+                : target_files.count(name)
+                ? "the header declares some functions or classes differently per target; configure a single target"
+                : name.find("_signal_unsafe") != std::string::npos
+                ? "signal-unsafe functions are built only with their build tag; use SignalUnsafePolicy::Exclude"
+                : "optional features are built only with their build tags";
+            throw std::invalid_argument("Cannot generate a single Go file: " + name + " is needed, as " + reason);
+        }
+    }
+    return package;
+}
+
+std::pair<std::string, std::string> FFIGenerator::generateCWrapper(
+    const std::string& cpp_source,
+    const std::string& library_name
+) {
+    FFIModule module = analyzer_.analyzeSource(cpp_source, library_name);
+    FFIOptions options = options_;
+    for (const auto& name : module.modules) {
+        if (std::find(options.modules.begin(), options.modules.end(), name) == options.modules.end()) {
+            options.modules.push_back(name);
+        }
+    }
+    CWrapperGenerator generator(options);
+    return {
+        generator.generateHeader(module.functions, module.classes, library_name),
+        generator.generateImplementation(module.functions, module.classes, library_name)
+    };
+}
+
+std::string FFIGenerator::generateBindingsHeader(
+    const std::string& cpp_source,
+    const std::string& library_name
+) {
+    FFIModule module = analyzer_.analyzeSource(cpp_source, library_name);
+    return c_wrapper_generator_.generateBindingsHeader(module.functions, module.classes, library_name);
+}
+
+std::string FFIGenerator::generateDefFile(
+    const std::string& cpp_source,
+    const std::string& library_name
+) {
+    FFIModule module = analyzer_.analyzeSource(cpp_source, library_name);
+    return c_wrapper_generator_.generateDefFile(module, library_name);
+}
+
+std::string FFIGenerator::generateReport(
+    const std::string& cpp_source,
+    const std::string& library_name
+) {
+    FFIModule module = analyzer_.analyzeSource(cpp_source, library_name);
+    return go_generator_.generateReport(module.functions, module.classes, library_name, module.enums,
+                                         module.constants);
+}
+
+} // namespace ffi
+} // namespace hybrid_transpiler
@@ -0,0 +1,5175 @@
+/**
+ * @file go_ffi_gen.cpp
+ * @brief Go (cgo) bindings generator
+ *
+ * Produces a single Go file: a cgo preamble declaring the extern "C" shim
+ * (see c_wrapper_gen.cpp) followed by idiomatic Go wrappers. C++ classes
+ * become handle structs with New<Class>/Delete and one method per member.
+ */
+
+#include "ffi.h"
+#include <algorithm>
+#include <cctype>
+#include <functional>
+#include <map>
+#include <regex>
+#include <set>
+#include <sstream>
+#include <stdexcept>
+#include <tuple>
+
+namespace hybrid_transpiler {
+namespace ffi {
+
+namespace {
+
+const std::vector<std::string> kGoKeywords = {
+    "break", "case", "chan", "const", "continue", "default", "defer",
+    "else", "fallthrough", "for", "func", "go", "goto", "if", "import",
+    "interface", "map", "package", "range", "return", "select", "struct",
+    "switch", "type", "var"
+};
+
+// Packages the generated package may import
+const std::vector<std::string> kGoPackages = {
+    "context", "errors", "fmt", "log/slog", "math/bits", "runtime", "runtime/cgo", "strconv", "sync", "sync/atomic", "time", "unsafe"
+};
+
+// Further packages the generated files, or code next to them, commonly use
+const std::vector<std::string> kCommonPackages = {"C", "io", "os", "testing"};
+
+const std::vector<std::string> kGoPredeclared = {
+    "any", "bool", "byte", "comparable", "complex64", "complex128", "error", "float32",
+    "float64", "int", "int8", "int16", "int32", "int64", "rune", "string", "uint", "uint8",
+    "uint16", "uint32", "uint64", "uintptr", "true", "false", "iota", "nil", "append", "cap",
+    "clear", "close", "complex", "copy", "delete", "imag", "len", "make", "max", "min", "new",
+    "panic", "print", "println", "real", "recover"
+};
+
+// Top-level identifiers of the support code (generate*Support); Bitset<N> too
+const std::vector<std::string> kSupportNames = {
+    "ErrorCode", "ErrGenericCategory", "ErrSystemCategory", "ErrIOStreamCategory", "ErrFutureCategory",
+    "ErrAsioMiscCategory", "ErrAsioNetdbCategory", "ErrAsioAddrinfoCategory", "errorCategories",
+    "errorCodeResult", "UnexpectedError", "StatusError", "statusResult", "RunOnMainThread", "onMainThread",
+    "Features", "features", "ExceptionError", "exceptionResult", "panicOnException", "ErrHandleInvalidated",
+    "HandleInvalidatedError", "visitorState", "visitorCallback", "tmFromTime", "timeFromTm", "payloadType",
+    "RawPayload", "ErrUnknownPayloadTag", "ErrPayloadTooSmall", "Mapping", "ParamMapping", "Mappings", "mappings",
+    "smallStringSize", "cString", "freeCString", "benchmarkSmallString", "logSink", "logCallback",
+    "ValidationError", "FactoryError", "stringCache", "scratchArena", "newScratchArena", "benchmarkScratchStrings"
+};
+
+// Header holding the cgo declarations under FFIOptions::decls_header, next to the Go files
+const char* const kDeclsHeader = "generated_decls.h";
+
+// Packages the generated wrapper bodies refer to, which a parameter must not shadow
+const std::vector<std::string> kWrapperPackages = {"C", "errors", "fmt", "runtime", "strconv", "time", "unsafe"};
+
+std::string receiverName(const std::string& go_type) {
+    return std::string(1, static_cast<char>(std::tolower(static_cast<unsigned char>(go_type[0]))));
+}
+
+std::string packageName(const FFIOptions& options, const std::string& library_name) {
+    if (!options.package_name.empty()) {
+        return options.package_name;
+    }
+
+    std::string name;
+    for (char c : library_name) {
+        if (std::isalnum(static_cast<unsigned char>(c))) {
+            name += static_cast<char>(std::tolower(static_cast<unsigned char>(c)));
+        }
+    }
+    return name.empty() ? "bindings" : name;
+}
+
+/**
+ * Append user text at a preamble anchor. The preamble lives inside a Go block
+ * comment, so a stray comment terminator would truncate it.
+ */
+void appendInjected(std::stringstream& ss, const std::string& key, const std::string& text) {
+    if (text.empty()) {
+        return;
+    }
+    if (text.find("*/") != std::string::npos) {
+        throw std::invalid_argument(key + " must not contain '*/' (it would end the cgo preamble)");
+    }
+
+    ss << "// " << key << "\n";
+    ss << text;
+    if (text.back() != '\n') {
+        ss << "\n";
+    }
+}
+
+std::string qualifiedName(const FFIFunction& func) {
+    return func.class_name.empty() ? func.name : func.class_name + "::" + func.name;
+}
+
+/**
+ * Namespace, qualified name and parameter types, which tell overloads and
+ * same-named functions of different namespaces apart
+ */
+std::string functionKey(const FFIFunction& func) {
+    std::string key = (func.cpp_namespace.empty() ? "" : func.cpp_namespace + "::") + qualifiedName(func) + "(";
+    for (size_t i = 0; i < func.parameters.size(); ++i) {
+        key += (i > 0 ? "," : "") + func.parameters[i].cpp_type;
+    }
+    return key + (func.is_const ? ") const" : ")");
+}
+
+/**
+ * Key of the Go names bound to a function; the C symbol tells apart two
+ * declarations with one signature, such as members of nested classes
+ */
+std::string functionEntity(const FFIFunction& func) {
+    return functionKey(func) + (func.c_name.empty() ? "" : " " + func.c_name);
+}
+
+/**
+ * Doc lines warning that a wrapper only exists behind the opt-in build tag
+ */
+// Comments avoid package-qualified names so import detection ignores them
+const char* const kPrintfDoc =
+    "// The message is formatted in Go with the verbs of package fmt; C++ receives\n"
+    "// it as a finished string, never as a format.\n";
+
+const char* const kThrowsDoc =
+    "// A C++ exception it throws is returned as an *ExceptionError.\n";
+
+/**
+ * Whether the Go wrapper checks an argument against its documented constraint
+ */
+bool validates(const FFIFunction& func) {
+    return !func.unvalidated && std::any_of(func.parameters.begin(), func.parameters.end(), [](const FFIParameter& p) {
+        return !p.constraint.text.empty();
+    });
+}
+
+std::string signalUnsafeDoc(const FFIFunction& func, const std::string& tag) {
+    return "//\n"
+           "// Warning: " + qualifiedName(func) + " changes signal handling in ways that can\n"
+           "// conflict with the Go runtime, which installs its own handlers (see os/signal).\n"
+           "// This wrapper is only built with -tags " + tag + ".\n";
+}
+
+/**
+ * Doc lines of a function listed in FFIOptions::preserve_signals; "" for others
+ */
+std::string preservedSignalsDoc(const FFIFunction& func, const FFIOptions& options) {
+    auto rule = options.preserve_signals.find(qualifiedName(func));
+    if (rule == options.preserve_signals.end()) {
+        return "";
+    }
+    std::string doc = "// The signal handlers it installs are replaced by the Go runtime's again once it\n"
+                      "// returns";
+    if (rule->second.empty()) {
+        return doc + ".\n";
+    }
+    std::string signals;
+    for (size_t i = 0; i < rule->second.size(); ++i) {
+        signals += (i == 0 ? "" : i + 1 == rule->second.size() ? " and " : ", ") + rule->second[i];
+    }
+    return doc + ", except that its " + signals + " handling runs before Go's.\n";
+}
+
+/**
+ * Import block for the packages a Go body references; "" if none
+ */
+std::string goImports(const std::string& body) {
+    std::vector<std::string> imports;
+    for (const std::string& package : kGoPackages) {
+        std::string selector = package.substr(package.rfind('/') + 1) + ".";
+        for (size_t pos = body.find(selector); pos != std::string::npos; pos = body.find(selector, pos + 1)) {
+            // Skip identifiers that merely end in the package name
+            if (pos == 0 || !(std::isalnum(static_cast<unsigned char>(body[pos - 1])) || body[pos - 1] == '_')) {
+                imports.push_back(package);
+                break;
+            }
+        }
+    }
+
+    std::stringstream ss;
+    if (imports.size() == 1) {
+        ss << "\nimport \"" << imports[0] << "\"\n";
+    } else if (!imports.empty()) {
+        ss << "\nimport (\n";
+        for (const auto& package : imports) {
+            ss << "\t\"" << package << "\"\n";
+        }
+        ss << ")\n";
+    }
+    return ss.str();
+}
+
+/**
+ * Go field for an output parameter: out_width, outWidth and width_out all
+ * become Width
+ */
+std::string outputFieldName(const std::string& name) {
+    std::string field = name;
+    if (field.compare(0, 4, "out_") == 0) {
+        field = field.substr(4);
+    } else if (field.size() > 3 && field.compare(0, 3, "out") == 0 &&
+               std::isupper(static_cast<unsigned char>(field[3]))) {
+        field = field.substr(3);
+    } else if (field.size() > 4 && field.compare(field.size() - 4, 4, "_out") == 0) {
+        field = field.substr(0, field.size() - 4);
+    } else if (field.size() > 3 && field.compare(field.size() - 3, 3, "Out") == 0) {
+        field = field.substr(0, field.size() - 3);
+    }
+    return field;
+}
+
+/**
+ * Struct named by a handle parameter type: const Config& and Config* are Config
+ */
+std::string pointeeName(const std::string& cpp_type) {
+    std::string name = cpp_type;
+    if (name.compare(0, 6, "const ") == 0) {
+        name = name.substr(6);
+    }
+    return name.substr(0, name.find_last_not_of("&* ") + 1);
+}
+
+/**
+ * C name of the exported Go function a visitor's callback calls back into
+ */
+std::string visitorExport(const std::string& library_name) {
+    std::string name;
+    for (char c : library_name) {
+        name += std::isalnum(static_cast<unsigned char>(c)) ? c : '_';
+    }
+    return name + "_go_visitor";
+}
+
+/**
+ * C name of the exported Go function a logger setter's handler calls back into
+ */
+std::string logExport(const std::string& library_name) {
+    std::string name;
+    for (char c : library_name) {
+        name += std::isalnum(static_cast<unsigned char>(c)) ? c : '_';
+    }
+    return name + "_go_log";
+}
+
+bool installsLogger(const std::vector<FFIFunction>& functions) {
+    std::vector<FFIFunction> bindable = CWrapperGenerator::bindableFunctions(functions);
+    return std::any_of(bindable.begin(), bindable.end(),
+                       [](const FFIFunction& func) { return !func.logger.callback.empty(); });
+}
+
+bool isVisitorCallback(const FFIFunction& func, const FFIParameter& param) {
+    return !func.visitor.callback.empty() && param.name == func.visitor.callback;
+}
+
+/**
+ * The void* context of a visitor carries the Go func's cgo.Handle, so Go callers never see it
+ */
+bool isVisitorContext(const FFIFunction& func, const FFIParameter& param) {
+    return !func.visitor.callback.empty() && param.name == func.visitor.context;
+}
+
+std::set<std::string> mirroredNames(const std::vector<FFIClass>& classes) {
+    std::set<std::string> names;
+    for (const auto& cls : classes) {
+        if (cls.is_mirrored) {
+            names.insert(cls.name);
+        }
+    }
+    return names;
+}
+
+/**
+ * Constant expression of a default argument. It is parsed with C++
+ * precedence and printed with Go's, spaced the way gofmt spaces it.
+ */
+struct ConstantExpr {
+    std::string text;                   // Name, literal or operator
+    std::vector<ConstantExpr> operands; // None for names and literals
+    bool paren = false;
+};
+
+class ConstantParser {
+public:
+    explicit ConstantParser(const std::string& expression) {
+        static const std::regex token(
+            R"(\s*("(?:[^"\\]|\\.)*"|[A-Za-z_]\w*(?:::\w+)*|[0-9][\w.]*(?:[eE][-+][0-9]+)?|<<|>>|&&|\|\||[-+*/%()|&^~!]))");
+        std::smatch match;
+        std::string rest = expression;
+        while (std::regex_search(rest, match, token, std::regex_constants::match_continuous)) {
+            tokens_.push_back(match[1].str());
+            rest = match.suffix();
+        }
+        valid_ = trim(rest).empty();
+    }
+
+    /**
+     * Parse the whole expression; false if it is not one
+     */
+    bool parse(ConstantExpr& expr) {
+        if (!valid_ || !binary(0, expr)) {
+            return false;
+        }
+        return pos_ == tokens_.size();
+    }
+
+private:
+    std::vector<std::string> tokens_;
+    size_t pos_ = 0;
+    bool valid_ = true;
+
+    static std::string trim(const std::string& text) {
+        size_t start = text.find_first_not_of(" \t\r\n");
+        return start == std::string::npos ? "" : text.substr(start);
+    }
+
+    // C++ binary operators from the loosest binding level to the tightest
+    static const std::vector<std::vector<std::string>>& levels() {
+        static const std::vector<std::vector<std::string>> table = {
+            {"||"}, {"&&"}, {"|"}, {"^"}, {"&"}, {"<<", ">>"}, {"+", "-"}, {"*", "/", "%"}
+        };
+        return table;
+    }
+
+    bool binary(size_t level, ConstantExpr& expr) {
+        if (level == levels().size()) {
+            return unary(expr);
+        }
+        if (!binary(level + 1, expr)) {
+            return false;
+        }
+        const auto& ops = levels()[level];
+        while (pos_ < tokens_.size() && std::find(ops.begin(), ops.end(), tokens_[pos_]) != ops.end()) {
+            ConstantExpr node;
+            node.text = tokens_[pos_++];
+            node.operands.push_back(expr);
+            node.operands.emplace_back();
+            if (!binary(level + 1, node.operands.back())) {
+                return false;
+            }
+            expr = node;
+        }
+        return true;
+    }
+
+    bool unary(ConstantExpr& expr) {
+        if (pos_ == tokens_.size()) {
+            return false;
+        }
+        const std::string& text = tokens_[pos_];
+        if (text == "+" || text == "-" || text == "~" || text == "!") {
+            pos_++;
+            expr.text = text;
+            expr.operands.emplace_back();
+            return unary(expr.operands.back());
+        }
+        if (text == "(") {
+            pos_++;
+            if (!binary(0, expr) || pos_ == tokens_.size() || tokens_[pos_] != ")") {
+                return false;
+            }
+            pos_++;
+            expr.paren = true;
+            return true;
+        }
+        if (std::string("*/%|&^)<>").find(text[0]) != std::string::npos) {
+            return false;
+        }
+        expr.text = text;
+        pos_++;
+        return true;
+    }
+};
+
+int goPrecedence(const ConstantExpr& expr) {
+    static const std::map<std::string, int> precedence = {
+        {"||", 1}, {"&&", 2}, {"|", 4}, {"^", 4}, {"+", 4}, {"-", 4},
+        {"*", 5}, {"/", 5}, {"%", 5}, {"<<", 5}, {">>", 5}, {"&", 5},
+    };
+    return expr.operands.size() == 2 ? precedence.at(expr.text) : 0;
+}
+
+/**
+ * Precedence of an operand as its parent sees it; parentheses make it atomic
+ */
+int operandPrecedence(const ConstantExpr& expr) {
+    return expr.paren ? 0 : goPrecedence(expr);
+}
+
+/**
+ * Parenthesize operands whose C++ grouping Go's precedence would change:
+ * C++ binds a + b << 2 as (a + b) << 2, Go as a + (b << 2)
+ */
+void regroup(ConstantExpr& expr) {
+    for (auto& operand : expr.operands) {
+        regroup(operand);
+    }
+    if (expr.operands.size() == 2) {
+        int prec = goPrecedence(expr);
+        int left = operandPrecedence(expr.operands[0]);
+        int right = operandPrecedence(expr.operands[1]);
+        expr.operands[0].paren = expr.operands[0].paren || (left && left < prec);
+        expr.operands[1].paren = expr.operands[1].paren || (right && right <= prec);
+    } else if (expr.operands.size() == 1 && expr.text == "~") {
+        expr.text = "^";
+    }
+}
+
+// gofmt's spacing of binary expressions (go/printer: walkBinary, cutoff)
+void walkBinary(const ConstantExpr& expr, bool& has4, bool& has5, int& max_problem) {
+    int prec = goPrecedence(expr);
+    has4 = has4 || prec == 4;
+    has5 = has5 || prec == 5;
+    const ConstantExpr& left = expr.operands[0];
+    const ConstantExpr& right = expr.operands[1];
+    if (operandPrecedence(left) >= prec) {
+        walkBinary(left, has4, has5, max_problem);
+    }
+    if (operandPrecedence(right) > prec) {
+        walkBinary(right, has4, has5, max_problem);
+    } else if (!right.paren && right.operands.size() == 1) {
+        std::string pair = expr.text + right.text;
+        if (pair == "&^" || pair == "&&") {
+            max_problem = 5;
+        } else if ((pair == "++" || pair == "--") && max_problem < 4) {
+            max_problem = 4;
+        }
+    }
+}
+
+int cutoff(const ConstantExpr& expr, int depth) {
+    bool has4 = false;
+    bool has5 = false;
+    int max_problem = 0;
+    walkBinary(expr, has4, has5, max_problem);
+    if (max_problem > 0) {
+        return max_problem + 1;
+    }
+    if (has4 && has5) {
+        return depth == 1 ? 5 : 4;
+    }
+    return depth == 1 ? 6 : 4;
+}
+
+void printGo(const ConstantExpr& expr, int depth, std::string& out) {
+    if (expr.paren) {
+        ConstantExpr inner = expr;
+        inner.paren = false;
+        out += "(";
+        printGo(inner, std::max(depth - 1, 1), out);
+        out += ")";
+    } else if (expr.operands.empty()) {
+        out += expr.text;
+    } else if (expr.operands.size() == 1) {
+        out += expr.text;
+        printGo(expr.operands[0], depth, out);
+    } else {
+        int prec = goPrecedence(expr);
+        bool blank = prec < cutoff(expr, depth);
+        const ConstantExpr& left = expr.operands[0];
+        printGo(left, depth + (operandPrecedence(left) == prec ? 0 : 1), out);
+        out += blank ? " " + expr.text + " " : expr.text;
+        printGo(expr.operands[1], depth + 1, out);
+    }
+}
+
+std::string bitsetGoType(size_t width) {
+    return "Bitset" + std::to_string(width);
+}
+
+/**
+ * Reset method of a poolable class: a bindable, non-static member taking
+ * no arguments and returning nothing
+ */
+FFIFunction poolResetMethod(const FFIClass& cls) {
+    for (const auto& method : CWrapperGenerator::bindableFunctions(cls.methods)) {
+        if (method.name == cls.pool_reset && !method.is_constructor && method.parameters.empty() &&
+            method.c_return_type.empty() && (method.return_type.empty() || method.return_type == "void")) {
+            return method;
+        }
+    }
+    throw std::invalid_argument(cls.name + " is poolable but has no bindable " + cls.pool_reset +
+                                "() method taking no arguments and returning void");
+}
+
+/**
+ * Reconnection factory of a handle invalidation rule: a bindable static
+ * member returning a pointer to the class, named with or without its scope
+ */
+FFIFunction reconnectFactory(const FFIClass& cls, const std::string& factory) {
+    std::string scope = cls.name + "::";
+    std::string name = factory.compare(0, scope.size(), scope) == 0 ? factory.substr(scope.size()) : factory;
+    for (const auto& shim : CWrapperGenerator::shimFunctions(cls)) {
+        if (shim.name == name && shim.is_static && shim.iteration.empty() &&
+            CWrapperGenerator::shimReturnType(shim) == "void*" && pointeeName(shim.return_type) == cls.name) {
+            return shim;
+        }
+    }
+    throw std::invalid_argument("handle_invalidation: " + factory + " is not a bindable static " + cls.name +
+                                " method returning " + cls.name + "*");
+}
+
+/**
+ * Member of a tagged payload rule: a bindable method of the class taking no
+ * arguments and returning one Go value
+ */
+FFIFunction payloadMember(const FFIClass& cls, const std::string& rule, const std::string& name) {
+    for (const auto& shim : CWrapperGenerator::shimFunctions(cls)) {
+        if (shim.name == name && !shim.is_static && !shim.is_constructor && !shim.is_destructor &&
+            shim.iteration.empty() && shim.parameters.empty() && !shim.returns_status &&
+            !CWrapperGenerator::hasErrorCodeOut(shim) && !CWrapperGenerator::expectedTypes(shim)) {
+            return shim;
+        }
+    }
+    throw std::invalid_argument("tagged_payloads: " + rule + ": " + cls.name + "::" + name +
+                                " is not a bindable method taking no arguments");
+}
+
+/**
+ * The size() method bounds-checked operator[] accessors call: public,
+ * without arguments, bound in the main package and returning an integer;
+ * nullptr if the class has none
+ */
+const FFIFunction* sizeMethod(const FFIClass& cls) {
+    static const std::set<std::string> integers = {
+        "short", "unsigned short", "int", "unsigned int", "long", "unsigned long", "long long", "unsigned long long",
+        "int8_t", "int16_t", "int32_t", "int64_t", "uint8_t", "uint16_t", "uint32_t", "uint64_t", "size_t", "intptr_t",
+    };
+    for (const auto& method : cls.methods) {
+        if (method.name == "size" && method.parameters.empty() && method.can_use_ffi && !method.throws &&
+            !method.main_thread_only && !method.signal_unsafe && method.feature.empty() && method.targets.empty() &&
+            integers.count(method.c_return_type)) {
+            return &method;
+        }
+    }
+    return nullptr;
+}
+
+/**
+ * Index of the constructor taking no arguments, which the pool benchmark
+ * uses as its factory; -1 if there is none
+ */
+int defaultConstructorIndex(const FFIClass& cls) {
+    int index = 0;
+    for (const auto& shim : CWrapperGenerator::shimFunctions(cls)) {
+        if (!shim.is_constructor) {
+            continue;
+        }
+        if (shim.parameters.empty()) {
+            return index;
+        }
+        index++;
+    }
+    return -1;
+}
+
+/**
+ * Doc line of a result returned by lvalue reference, which crosses as a
+ * pointer C++ keeps ownership of
+ */
+std::string referenceDoc(const FFIFunction& func, const std::string& go_return) {
+    std::string type = func.return_type;
+    while (!type.empty() && type.back() == ' ') {
+        type.pop_back();
+    }
+    if (type.size() < 2 || type.back() != '&' || type[type.size() - 2] == '&' || type.find('(') != std::string::npos ||
+        func.c_return_type.empty() || func.c_return_type.back() != '*' || go_return != "unsafe.Pointer") {
+        return "";
+    }
+    return "// The result points to the object C++ returned by reference, which C++\n"
+           "// keeps ownership of.\n";
+}
+
+} // namespace
+
+std::string GoFFIGenerator::goName(const std::string& name) {
+    std::string result;
+    bool upper_next = true;
+
+    for (char c : name) {
+        if (c == '_' || c == '~') {
+            upper_next = true;
+            continue;
+        }
+        if (upper_next) {
+            result += static_cast<char>(std::toupper(static_cast<unsigned char>(c)));
+            upper_next = false;
+        } else {
+            result += c;
+        }
+    }
+
+    return result;
+}
+
+std::string GoFFIGenerator::goParamName(const std::string& name) {
+    std::string result = goName(name);
+    if (result.empty()) {
+        return result;
+    }
+    result[0] = static_cast<char>(std::tolower(static_cast<unsigned char>(result[0])));
+
+    if (std::find(kGoKeywords.begin(), kGoKeywords.end(), result) != kGoKeywords.end()) {
+        result += "_";
+    }
+    return result;
+}
+
+std::string GoFFIGenerator::goType(const std::string& c_type) {
+    static const std::map<std::string, std::string> go_types = {
+        {"bool", "bool"},
+        {"_Bool", "bool"},
+        {"char", "int8"},
+        {"unsigned char", "uint8"},
+        {"short", "int16"},
+        {"unsigned short", "uint16"},
+        {"int", "int32"},
+        {"unsigned int", "uint32"},
+        {"long", "int64"},
+        {"unsigned long", "uint64"},
+        {"long long", "int64"},
+        {"unsigned long long", "uint64"},
+        {"float", "float32"},
+        {"double", "float64"},
+        {"int8_t", "int8"},
+        {"int16_t", "int16"},
+        {"int32_t", "int32"},
+        {"int64_t", "int64"},
+        {"uint8_t", "uint8"},
+        {"uint16_t", "uint16"},
+        {"uint32_t", "uint32"},
+        {"uint64_t", "uint64"},
+        {"size_t", "uint"},
+        {"intptr_t", "int"},
+        {"const char*", "string"},
+        {"char*", "string"},
+        {"struct tm", "time.Time"},
+    };
+
+    auto it = go_types.find(c_type);
+    if (it != go_types.end()) {
+        return it->second;
+    }
+    return "unsafe.Pointer";
+}
+
+std::string GoFFIGenerator::cgoType(const std::string& c_type) {
+    static const std::map<std::string, std::string> cgo_types = {
+        {"bool", "C.bool"},
+        {"_Bool", "C.bool"},
+        {"char", "C.char"},
+        {"unsigned char", "C.uchar"},
+        {"short", "C.short"},
+        {"unsigned short", "C.ushort"},
+        {"int", "C.int"},
+        {"unsigned int", "C.uint"},
+        {"long", "C.long"},
+        {"unsigned long", "C.ulong"},
+        {"long long", "C.longlong"},
+        {"unsigned long long", "C.ulonglong"},
+        {"float", "C.float"},
+        {"double", "C.double"},
+        {"const char*", "*C.char"},
+        {"char*", "*C.char"},
+        {"struct tm", "C.struct_tm"},
+    };
+
+    auto it = cgo_types.find(c_type);
+    if (it != cgo_types.end()) {
+        return it->second;
+    }
+    if (!c_type.empty() && c_type.back() == '*') {
+        return "unsafe.Pointer";
+    }
+    // Fixed-width and size types keep their C spelling: C.int32_t, C.size_t
+    return "C." + c_type;
+}
+
+/**
+ * cgo type of a typed data pointer ("int32_t*" -> "*C.int32_t"), which the
+ * Go side holds as an unsafe.Pointer; "" for void pointers, strings and
+ * anything that is not a pointer
+ */
+std::string GoFFIGenerator::cgoPointer(const std::string& c_type) {
+    if (c_type.empty() || c_type.back() != '*' || goType(c_type) != "unsafe.Pointer") {
+        return "";
+    }
+    std::string pointee = c_type.substr(0, c_type.size() - 1);
+    if (pointee.compare(0, 6, "const ") == 0) {
+        pointee = pointee.substr(6);
+    }
+    while (!pointee.empty() && pointee.back() == ' ') {
+        pointee.pop_back();
+    }
+    if (pointee == "void" || pointee.find_first_of("()[") != std::string::npos) {
+        return "";
+    }
+    return "*" + cgoType(pointee);
+}
+
+std::string GoFFIGenerator::argumentName(const FFIFunction& func, size_t index) const {
+    // A printf format named fmt would shadow the package that formats it
+    if (func.printf_format && index + 1 == func.parameters.size()) {
+        return "format";
+    }
+    const auto& param = func.parameters[index];
+    std::string name = goParamName(param.name.empty() ? "arg" + std::to_string(index) : param.name);
+    // Nor may it shadow a package or predeclared identifier the body uses
+    for (const auto* names : {&kGoPredeclared, &kWrapperPackages}) {
+        if (std::find(names->begin(), names->end(), name) != names->end()) {
+            return name + "_";
+        }
+    }
+    // Or redeclare the receiver: func (w *Widget) Resize(w_ int32, h int32)
+    if (!func.class_name.empty() && !func.is_static && !func.is_constructor &&
+        name == receiverName(typeName(func.class_name))) {
+        return name + "_";
+    }
+    return name;
+}
+
+std::string GoFFIGenerator::goParameterList(const FFIFunction& func) {
+    size_t count = func.parameters.size();
+    if (CWrapperGenerator::hasErrorCodeOut(func)) {
+        count--;
+    }
+
+    std::stringstream ss;
+    std::string separator;
+    ss << "(";
+    for (size_t i = 0; i < count; ++i) {
+        const auto& param = func.parameters[i];
+        if (direction(param) == ParamDirection::Out || isVisitorContext(func, param)) {
+            continue;
+        }
+        ss << separator << argumentName(func, i) << " " << goParameterType(func, param);
+        separator = ", ";
+    }
+    if (func.printf_format) {
+        ss << ", args ...any";
+    }
+    ss << ")";
+    return ss.str();
+}
+
+std::string GoFFIGenerator::goParameterType(const FFIFunction& func, const FFIParameter& param) {
+    if (isVisitorCallback(func, param)) {
+        std::string result = func.visitor.result.cpp_type.empty() ? "bool" : enumGoType(func.visitor.result.cpp_type);
+        return "func(" + visitorElementType(func) + ") " + result;
+    }
+    if (!func.logger.callback.empty() && param.name == func.logger.callback) {
+        return "*slog.Logger";
+    }
+    size_t bits = CWrapperGenerator::bitsetWidth(param.cpp_type);
+    std::string enum_type = param.is_enum ? enumGoType(param.cpp_type) : "";
+    return direction(param) == ParamDirection::InOut ? "*" + referencedGoType(param)
+         : bits ? bitsetGoType(bits)
+         : !enum_type.empty() ? enum_type
+         : param.c_type == "const struct tm*" ? "time.Time"
+         : goType(param.c_type);
+}
+
+std::string GoFFIGenerator::goArgumentNames(const FFIFunction& func) {
+    size_t count = func.parameters.size();
+    if (CWrapperGenerator::hasErrorCodeOut(func)) {
+        count--;
+    }
+
+    std::string names;
+    for (size_t i = 0; i < count; ++i) {
+        const auto& param = func.parameters[i];
+        if (direction(param) == ParamDirection::Out || isVisitorContext(func, param)) {
+            continue;
+        }
+        if (!names.empty()) names += ", ";
+        names += argumentName(func, i);
+    }
+    if (func.printf_format) {
+        names += ", args...";
+    }
+    return names;
+}
+
+std::string GoFFIGenerator::goReturnType(const FFIFunction& func) {
+    if (func.returns_status) {
+        return "";
+    }
+    std::string element;
+    size_t extent = CWrapperGenerator::arrayReturnExtent(func, &element);
+    if (extent) {
+        return "[" + std::to_string(extent) + "]" + goType(element);
+    }
+    if (CWrapperGenerator::uniqueArrayReturn(func)) {
+        return "[]" + goType(func.c_return_type.substr(0, func.c_return_type.size() - 1));
+    }
+    size_t bits = CWrapperGenerator::bitsetWidth(func.return_type);
+    if (bits) {
+        return bitsetGoType(bits);
+    }
+    if (!func.factory.empty() && !mirrors_.count(func.factory)) {
+        return "*" + typeName(func.factory);
+    }
+    std::string value_type = func.return_type;
+    CWrapperGenerator::expectedTypes(func, &value_type);
+    if (func.returns_enum && !enumGoType(value_type).empty()) {
+        return enumGoType(value_type);
+    }
+
+    std::string return_type = CWrapperGenerator::shimReturnType(func);
+    return return_type == "void" ? "" : goType(return_type);
+}
+
+std::vector<std::string> GoFFIGenerator::goResultTypes(const FFIFunction& func) {
+    std::vector<std::string> results;
+    std::string return_type = goReturnType(func);
+    if (!return_type.empty()) {
+        results.push_back(return_type);
+    }
+    std::string result_struct = resultStructName(func);
+    if (!result_struct.empty()) {
+        results.push_back(result_struct);
+    }
+    for (const auto& param : func.parameters) {
+        if (isOutput(func, param) && result_struct.empty()) {
+            results.push_back(referencedGoType(param));
+        }
+    }
+    if (returnsCommaOk(func)) {
+        std::swap(results[0], results[1]);
+    }
+    if (CWrapperGenerator::hasErrorCodeOut(func) || CWrapperGenerator::expectedTypes(func) || func.returns_status ||
+        returnsException(func) || returnsValidationError(func) || returnsFactoryError(func)) {
+        results.push_back("error");
+    }
+    return results;
+}
+
+std::string GoFFIGenerator::goSignature(const FFIFunction& func) {
+    std::string signature = goParameterList(func);
+    std::vector<std::string> results = goResultTypes(func);
+
+    if (results.size() == 1) {
+        signature += " " + results[0];
+    } else if (!results.empty()) {
+        signature += " (";
+        for (size_t i = 0; i < results.size(); ++i) {
+            signature += (i > 0 ? ", " : "") + results[i];
+        }
+        signature += ")";
+    }
+    return signature;
+}
+
+ParamDirection GoFFIGenerator::direction(const FFIParameter& param) const {
+    // A struct demoted from its mirror is an accessor handle, passed as is
+    if (param.c_type == "void*" && !mirrors_.count(pointeeName(param.cpp_type))) {
+        return ParamDirection::In;
+    }
+    return param.direction;
+}
+
+bool GoFFIGenerator::returnsException(const FFIFunction& func) const {
+    // Constructors have no error result and panic instead
+    return func.throws && !func.is_constructor && CWrapperGenerator(options_).catchesExceptions(func);
+}
+
+bool GoFFIGenerator::returnsValidationError(const FFIFunction& func) const {
+    // Constructors have no error result and panic instead
+    return options_.validation_failure == ValidationFailure::Error && !func.is_constructor && validates(func);
+}
+
+bool GoFFIGenerator::returnsFactoryError(const FFIFunction& func) const {
+    return func.interface_factory && !mirrors_.count(func.factory);
+}
+
+std::string GoFFIGenerator::validationGuard(const FFIFunction& func) {
+    if (!validates(func)) {
+        return "";
+    }
+    std::vector<std::string> types = goResultTypes(func);
+    std::string fail = "\t\tpanic(";
+    if (returnsValidationError(func)) {
+        fail = "\t\treturn ";
+        for (size_t i = 0; i + 1 < types.size(); ++i) {
+            fail += goZero(types[i]) + ", ";
+        }
+    }
+
+    std::string guard;
+    for (size_t i = 0; i < func.parameters.size(); ++i) {
+        const FFIConstraint& constraint = func.parameters[i].constraint;
+        if (constraint.text.empty()) {
+            continue;
+        }
+        std::string name = argumentName(func, i);
+        std::string condition;
+        if (!constraint.values.empty()) {
+            for (const auto& value : constraint.values) {
+                condition += (condition.empty() ? "" : " && ") + name + " != " + value;
+            }
+        } else if (func.parameters[i].c_type == "float" || func.parameters[i].c_type == "double") {
+            // Negated, so NaN fails too
+            std::string lower = constraint.min.empty() ? ""
+                              : name + (constraint.min_exclusive ? " > " : " >= ") + constraint.min;
+            std::string upper = constraint.max.empty() ? ""
+                              : name + (constraint.max_exclusive ? " < " : " <= ") + constraint.max;
+            condition = "!(" + lower + (lower.empty() || upper.empty() ? "" : " && ") + upper + ")";
+        } else {
+            std::string lower = constraint.min.empty() ? ""
+                              : name + (constraint.min_exclusive ? " <= " : " < ") + constraint.min;
+            std::string upper = constraint.max.empty() ? ""
+                              : name + (constraint.max_exclusive ? " >= " : " > ") + constraint.max;
+            condition = lower + (lower.empty() || upper.empty() ? "" : " || ") + upper;
+        }
+        guard += "\tif " + condition + " {\n";
+        guard += fail + "&ValidationError{Function: \"" + qualifiedName(func) + "\", Parameter: \"" +
+                 func.parameters[i].name + "\", Value: " + name + ", Constraint: \"" + constraint.text + "\"}" +
+                 (fail.back() == '(' ? ")" : "") + "\n";
+        guard += "\t}\n";
+    }
+    return guard;
+}
+
+std::string GoFFIGenerator::validationDoc(const FFIFunction& func) const {
+    std::string doc;
+    for (size_t i = 0; i < func.parameters.size(); ++i) {
+        const FFIConstraint& constraint = func.parameters[i].constraint;
+        if (!constraint.text.empty()) {
+            doc += "// " + argumentName(func, i) + " must be " + constraint.text + ".\n";
+        }
+    }
+    if (doc.empty()) {
+        return "";
+    }
+    if (func.unvalidated) {
+        return doc + "// Other values are passed to C++ unchecked.\n";
+    }
+    return doc + (returnsValidationError(func) ? "// Other values return a *ValidationError before C++ is called.\n"
+                                                : "// Other values panic with a *ValidationError before C++ is called.\n");
+}
+
+std::string GoFFIGenerator::goZero(const std::string& go_type) const {
+    static const std::set<std::string> numbers = {
+        "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "float32", "float64",
+    };
+    if (go_type == "string") {
+        return "\"\"";
+    }
+    if (go_type == "bool") {
+        return "false";
+    }
+    if (go_type == "unsafe.Pointer" || go_type[0] == '*' || go_type.compare(0, 2, "[]") == 0) {
+        return "nil";
+    }
+    bool is_enum = std::any_of(enums_.begin(), enums_.end(),
+                               [&](const FFIEnum& e) { return typeName(e.name) == go_type; });
+    bool narrow_bitset = go_type.compare(0, 6, "Bitset") == 0 && std::stoul(go_type.substr(6)) <= 64;
+    if (numbers.count(go_type) || is_enum || narrow_bitset) {
+        return "0";
+    }
+    // Arrays, wide bitsets, mirrored structs and result structs
+    return go_type + "{}";
+}
+
+bool GoFFIGenerator::isOutput(const FFIFunction& func, const FFIParameter& param) const {
+    // The length of a std::unique_ptr<T[]> result is the length of the slice
+    return direction(param) == ParamDirection::Out && param.name != func.array_size;
+}
+
+bool GoFFIGenerator::returnsCommaOk(const FFIFunction& func) const {
+    // A lookup filling one std::string& reads like a Go map: (value, ok)
+    std::vector<const FFIParameter*> outputs;
+    for (const auto& param : func.parameters) {
+        if (isOutput(func, param)) {
+            outputs.push_back(&param);
+        }
+    }
+    return func.c_return_type == "bool" && outputs.size() == 1 && outputs[0]->c_type == "char**" &&
+           resultStructBase(func).empty();
+}
+
+std::string GoFFIGenerator::referencedGoType(const FFIParameter& param) const {
+    if (param.c_type == "void*") {
+        return typeName(pointeeName(param.cpp_type));
+    }
+    return goType(param.c_type.substr(0, param.c_type.size() - 1));
+}
+
+std::string GoFFIGenerator::identifier(const std::string& entity, const std::string& name) const {
+    auto it = identifiers_.find(entity);
+    return it == identifiers_.end() ? name : it->second;
+}
+
+std::string GoFFIGenerator::typeName(const std::string& cpp_name) const {
+    return identifier("type " + cpp_name, goName(cpp_name));
+}
+
+std::string GoFFIGenerator::wrapperName(const FFIFunction& func) const {
+    // Static methods become package-level functions prefixed by the class
+    std::string name = func.class_name.empty() ? goName(func.name) : typeName(func.class_name) + goName(func.name);
+    return identifier("func " + functionEntity(func), func.logger.callback.empty() ? name : "SetLogger");
+}
+
+std::string GoFFIGenerator::constructorName(const FFIClass& cls, size_t index) const {
+    return identifier("constructor " + cls.name + "/" + std::to_string(index),
+                      "New" + typeName(cls.name) + (index > 0 ? std::to_string(index) : ""));
+}
+
+std::string GoFFIGenerator::methodName(const FFIFunction& method) const {
+    return identifier("method " + functionEntity(method), goName(method.name));
+}
+
+std::string GoFFIGenerator::rawPayloadName(const FFIFunction& method) const {
+    return identifier("raw " + functionEntity(method), goParamName(methodName(method)));
+}
+
+std::string GoFFIGenerator::cacheField(const FFIFunction& method) const {
+    return identifier("cache " + functionEntity(method), goParamName(methodName(method)) + "Cache");
+}
+
+std::string GoFFIGenerator::poolName(const FFIClass& cls) const {
+    return identifier("pool " + cls.name, typeName(cls.name) + "Pool");
+}
+
+std::string GoFFIGenerator::enumeratorName(const std::string& ffi_enum, const std::string& enumerator) const {
+    return identifier("enumerator " + ffi_enum + "::" + enumerator, typeName(ffi_enum) + goName(enumerator));
+}
+
+void GoFFIGenerator::resolveNames(const std::vector<FFIFunction>& functions, const std::vector<FFIClass>& classes) {
+    static const std::regex bitset_type(R"(Bitset\d+)");
+    const std::string& affix = options_.collision_affix;
+    if (!std::regex_match(affix, std::regex(R"([A-Za-z]\w*)"))) {
+        throw std::invalid_argument("collision_affix '" + affix + "' is not a Go identifier");
+    }
+    identifiers_.clear();
+    renames_.clear();
+
+    // Identifier -> what it names, reserved ones first
+    std::map<std::string, std::string> taken;
+    for (const auto* packages : {&kGoPackages, &kCommonPackages}) {
+        for (const auto& package : *packages) {
+            taken[package.substr(package.rfind('/') + 1)] = "package " + package;
+        }
+    }
+    for (const auto& name : kGoPredeclared) {
+        taken[name] = "predeclared " + name;
+    }
+    for (const auto& name : kSupportNames) {
+        taken[name] = "support code " + name;
+    }
+
+    // Earlier declarations keep their names: types, then class members,
+    // result structs, enumerators, constants and functions
+    auto declareIn = [&](std::map<std::string, std::string>& scope, const std::string& entity,
+                         const std::string& name, const std::string& origin) {
+        std::string go = name;
+        while (scope.count(go) || std::regex_match(go, bitset_type)) {
+            if (options_.collision_rule == RenameRule::Prefix) {
+                std::string prefix = affix;
+                bool exported = std::isupper(static_cast<unsigned char>(go[0]));
+                prefix[0] = static_cast<char>(exported ? std::toupper(static_cast<unsigned char>(prefix[0]))
+                                                       : std::tolower(static_cast<unsigned char>(prefix[0])));
+                go[0] = static_cast<char>(std::toupper(static_cast<unsigned char>(go[0])));
+                go = prefix + go;
+            } else {
+                go += affix;
+            }
+        }
+        if (go != name) {
+            std::string reason = scope.count(name) ? scope[name] : "support code " + name;
+            renames_.push_back(origin + ": " + name + " -> " + go + " (collides with " + reason + ")");
+        }
+        scope[go] = origin;
+        identifiers_[entity] = go;
+    };
+    auto declare = [&](const std::string& entity, const std::string& name, const std::string& origin) {
+        declareIn(taken, entity, name, origin);
+    };
+    // Variants of a function bound for different targets share its Go names
+    struct Variant {
+        std::vector<std::string> targets;
+        std::string entity;
+        std::string key;
+    };
+    std::map<std::string, std::vector<Variant>> variants;
+    auto shareVariant = [&](const FFIFunction& func, const std::string& entity) {
+        for (const auto& variant : variants[qualifiedName(func)]) {
+            bool disjoint = std::none_of(variant.targets.begin(), variant.targets.end(), [&func](const std::string& t) {
+                return std::find(func.targets.begin(), func.targets.end(), t) != func.targets.end();
+            });
+            if (!disjoint) {
+                continue;
+            }
+            std::string key = functionEntity(func);
+            identifiers_[entity] = identifiers_[variant.entity];
+            for (const std::string kind : {"direct ", "default "}) {
+                if (identifiers_.count(kind + variant.key)) {
+                    identifiers_[kind + key] = identifiers_[kind + variant.key];
+                }
+            }
+            return true;
+        }
+        return false;
+    };
+    // Methods go in the scope of their type rather than the package's; true
+    // unless the function shares the names of a variant
+    auto declareFunction = [&](const FFIFunction& func, const std::string& entity, const std::string& name,
+                               std::map<std::string, std::string>* members = nullptr) {
+        std::map<std::string, std::string>& scope = members ? *members : taken;
+        std::string key = functionEntity(func);
+        bool shared = !func.targets.empty() && shareVariant(func, entity);
+        if (!shared) {
+            if (!func.targets.empty()) {
+                variants[qualifiedName(func)].push_back({func.targets, entity, key});
+            }
+            declareIn(scope, entity, name, qualifiedName(func));
+        }
+        std::string declared = identifier(entity, name);
+        if (func.main_thread_only && !func.is_constructor && !identifiers_.count("direct " + key)) {
+            declareIn(scope, "direct " + key, goParamName(declared), qualifiedName(func) + " (main-thread call)");
+        }
+        size_t count = func.parameters.size() - (CWrapperGenerator::hasErrorCodeOut(func) ? 1 : 0);
+        if (firstDefault(func) < count && !identifiers_.count("default " + key)) {
+            declareIn(scope, "default " + key, declared + "Default", qualifiedName(func) + " (default arguments)");
+        }
+        return !shared;
+    };
+    bool excluded = options_.signal_unsafe_policy == SignalUnsafePolicy::Exclude;
+
+    for (const auto& ffi_enum : enums_) {
+        declare("type " + ffi_enum.name, goName(ffi_enum.name), ffi_enum.name);
+    }
+    for (const auto& cls : classes) {
+        declare("type " + cls.name, goName(cls.name), cls.name);
+    }
+
+    for (const auto& cls : classes) {
+        if (cls.is_mirrored) {
+            continue;
+        }
+        size_t ctor_index = 0;
+        for (const auto& shim : CWrapperGenerator::shimFunctions(cls)) {
+            size_t index = shim.is_constructor ? ctor_index++ : 0;
+            if (shim.signal_unsafe && excluded) {
+                continue;
+            }
+            if (shim.is_constructor) {
+                declareFunction(shim, "constructor " + cls.name + "/" + std::to_string(index),
+                                "New" + typeName(cls.name) + (index > 0 ? std::to_string(index) : ""));
+            } else if (shim.is_static && shim.iteration.empty()) {
+                declareFunction(shim, "func " + functionEntity(shim), typeName(cls.name) + goName(shim.name));
+            }
+        }
+        if (!cls.pool_reset.empty()) {
+            declare("pool " + cls.name, typeName(cls.name) + "Pool", cls.name + " (pool)");
+            declare("pool constructor " + cls.name, "New" + poolName(cls), cls.name + " (pool)");
+        }
+    }
+    for (const auto& payload : options_.tagged_payloads) {
+        size_t scope = payload.first.rfind("::");
+        if (scope != std::string::npos) {
+            std::string table = goParamName(typeName(payload.first.substr(0, scope))) +
+                                goName(payload.first.substr(scope + 2)) + "Types";
+            declare("payload types " + payload.first, table, payload.first + " (payload types)");
+        }
+    }
+
+    auto declareResult = [&](const FFIFunction& func) {
+        std::string name = resultStructBase(func);
+        if (!name.empty() && !identifiers_.count("result " + name) && (!func.signal_unsafe || !excluded)) {
+            declare("result " + name, name, qualifiedName(func) + " (results)");
+        }
+    };
+    for (const auto& cls : classes) {
+        for (const auto& shim : CWrapperGenerator::shimFunctions(cls)) {
+            declareResult(shim);
+        }
+    }
+    for (const auto& func : CWrapperGenerator::bindableFunctions(functions)) {
+        declareResult(func);
+    }
+
+    for (const auto& ffi_enum : enums_) {
+        for (const auto& enumerator : ffi_enum.enumerators) {
+            std::string name = ffi_enum.name + "::" + enumerator.first;
+            declare("enumerator " + name, typeName(ffi_enum.name) + goName(enumerator.first), name);
+        }
+    }
+    std::set<std::string> referenced = referencedConstants(functions, classes);
+    for (const auto& constant : constants_) {
+        if (referenced.count(constant.name)) {
+            declare("constant " + constant.name, goConstantName(constant.name), constant.name);
+        }
+    }
+
+    for (const auto& func : CWrapperGenerator::bindableFunctions(functions)) {
+        if (!func.signal_unsafe || !excluded) {
+            declareFunction(func, "func " + functionEntity(func),
+                            func.logger.callback.empty() ? goName(func.name) : "SetLogger");
+            if (!func.visitor.collect.empty() && !identifiers_.count("collect " + functionEntity(func))) {
+                declare("collect " + functionEntity(func), goName(func.visitor.collect),
+                        qualifiedName(func) + " (collected)");
+            }
+        }
+    }
+
+    // Methods share a scope with the fields and generated methods of their
+    // type; overloads are numbered in declaration order, like constructors
+    for (const auto& cls : classes) {
+        if (cls.is_mirrored) {
+            continue;
+        }
+        std::map<std::string, std::string> members;
+        for (const std::string field : {"ptr", "mu", "handle", "invalid", "exact", "borrowed"}) {
+            members[field] = "field " + field;
+        }
+        std::vector<std::string> generated = {"Delete"};
+        if (!cachedMethods(cls).empty()) {
+            generated.push_back("InvalidateCache");
+        }
+        auto rule = options_.handle_invalidation.find(cls.name);
+        if (rule != options_.handle_invalidation.end() && !rule->second.factory.empty()) {
+            generated.push_back("Reconnect");
+        }
+        if (!cls.iterator_element.cpp_type.empty()) {
+            generated.insert(generated.end(), {"Range", "Elements"});
+        }
+        for (const auto& name : generated) {
+            members[name] = "generated method " + typeName(cls.name) + "." + name;
+        }
+
+        std::map<std::string, size_t> overloads;
+        for (const auto& shim : CWrapperGenerator::shimFunctions(cls)) {
+            if (shim.is_constructor || shim.is_destructor || shim.is_static || !shim.iteration.empty() ||
+                (shim.signal_unsafe && excluded)) {
+                continue;
+            }
+            std::string key = functionEntity(shim);
+            size_t index = overloads[shim.name];
+            std::string name = goName(shim.name) + (index > 0 ? std::to_string(index) : "");
+            if (declareFunction(shim, "method " + key, name, &members)) {
+                overloads[shim.name]++;
+            }
+            std::string declared = identifier("method " + key, name);
+            // A tagged payload's own name goes to its typed accessor
+            if (taggedPayload(shim)) {
+                declareIn(members, "raw " + key, goParamName(declared), qualifiedName(shim) + " (raw payload)");
+            }
+            if (isCached(shim)) {
+                declareIn(members, "uncached " + key, declared + "Uncached", qualifiedName(shim) + " (uncached)");
+                declareIn(members, "cache " + key, goParamName(declared) + "Cache", qualifiedName(shim) + " (cache)");
+            }
+            if (!shim.visitor.collect.empty() && !identifiers_.count("collect " + key)) {
+                declareIn(members, "collect " + key, goName(shim.visitor.collect),
+                          qualifiedName(shim) + " (collected)");
+            }
+        }
+    }
+}
+
+std::string GoFFIGenerator::goConstantName(const std::string& name) const {
+    size_t colon = name.rfind("::");
+    std::string scope = colon == std::string::npos ? "" : name.substr(0, colon);
+    std::string member = colon == std::string::npos ? name : name.substr(colon + 2);
+
+    // Enumerators keep the names generateEnum gives them
+    for (const auto& ffi_enum : enums_) {
+        if (ffi_enum.name == scope) {
+            return enumeratorName(scope, member);
+        }
+    }
+
+    // DEFAULT_WIDTH and kDefaultWidth both become DefaultWidth
+    if (member.size() > 1 && member[0] == 'k' && std::isupper(static_cast<unsigned char>(member[1]))) {
+        member = member.substr(1);
+    }
+    if (std::none_of(member.begin(), member.end(), [](char c) { return std::islower(static_cast<unsigned char>(c)); })) {
+        std::transform(member.begin(), member.end(), member.begin(),
+                       [](char c) { return static_cast<char>(std::tolower(static_cast<unsigned char>(c))); });
+    }
+    return identifier("constant " + name, (scope.empty() ? "" : typeName(scope)) + goName(member));
+}
+
+std::string GoFFIGenerator::goDefault(const FFIParameter& param, int depth) const {
+    ConstantExpr expr;
+    if (param.default_value.empty() || param.direction != ParamDirection::In ||
+        CWrapperGenerator::bitsetWidth(param.cpp_type) || !ConstantParser(param.default_value).parse(expr)) {
+        return "";
+    }
+
+    // Spell every name as its Go constant; the C++ types of the names
+    // decide whether the expression fits the parameter
+    std::set<std::string> types;
+    bool strings = false;
+    bool numbers = false;
+    bool fractional = false;
+    bool known = true;
+    std::function<void(ConstantExpr&)> rename = [&](ConstantExpr& node) {
+        for (auto& operand : node.operands) {
+            rename(operand);
+        }
+        const std::string& text = node.text;
+        if (!node.operands.empty()) {
+            return;
+        }
+        if (text[0] == '"') {
+            strings = true;
+            return;
+        }
+        if (std::isdigit(static_cast<unsigned char>(text[0]))) {
+            bool hex = text.size() > 1 && (text[1] == 'x' || text[1] == 'X');
+            numbers = true;
+            fractional = fractional || (!hex && text.find_first_of(".eE") != std::string::npos);
+            return;
+        }
+        if (text == "true" || text == "false") {
+            types.insert("bool");
+            return;
+        }
+
+        size_t colon = text.rfind("::");
+        std::string owner = colon == std::string::npos ? "" : text.substr(0, colon);
+        auto ffi_enum = std::find_if(enums_.begin(), enums_.end(),
+                                     [&owner](const FFIEnum& e) { return e.name == owner; });
+        auto constant = std::find_if(constants_.begin(), constants_.end(),
+                                     [&text](const FFIConstant& c) { return c.name == text; });
+        if (ffi_enum != enums_.end()) {
+            types.insert(ffi_enum->name);
+        } else if (constant != constants_.end()) {
+            types.insert(constant->c_type == "float" || constant->c_type == "double" ? "float"
+                         : constant->c_type == "bool" ? "bool"
+                         : enumGoType(constant->cpp_type).empty() ? "int"
+                         : constant->cpp_type);
+        } else {
+            known = false;
+        }
+        node.text = goConstantName(text);
+    };
+    rename(expr);
+    if (!known) {
+        return "";
+    }
+    bool single = expr.operands.empty() && !expr.paren;
+    regroup(expr);
+    std::string go;
+    printGo(expr, depth, go);
+
+    if (param.c_type == "const char*") {
+        return strings && single ? go : "";
+    }
+    if (strings) {
+        return "";
+    }
+    if (param.c_type == "bool") {
+        return types.size() == 1 && types.count("bool") && !numbers ? go : "";
+    }
+    if (types.count("bool")) {
+        return "";
+    }
+    if (param.is_enum && !enumGoType(param.cpp_type).empty()) {
+        // Only the enum's own values, without arithmetic
+        return single && types.size() == 1 && types.count(param.cpp_type) ? go : "";
+    }
+
+    std::string numeric = goType(param.c_type);
+    bool integer = numeric.compare(0, 3, "int") == 0 || numeric.compare(0, 4, "uint") == 0;
+    if (numeric == "unsafe.Pointer" || numeric == "string" || (integer && (fractional || types.count("float")))) {
+        return "";
+    }
+    types.erase("int");
+    types.erase("float");
+    // Enumerators are typed Go constants; other constants are untyped
+    return types.empty() ? go : numeric + "(" + go + ")";
+}
+
+size_t GoFFIGenerator::firstDefault(const FFIFunction& func) const {
+    size_t count = func.parameters.size();
+    if (CWrapperGenerator::hasErrorCodeOut(func)) {
+        count--;
+    }
+    if (func.printf_format) {
+        return count;
+    }
+    size_t first = count;
+    while (first > 0 && !goDefault(func.parameters[first - 1]).empty()) {
+        first--;
+    }
+    return first;
+}
+
+std::string GoFFIGenerator::resultStructName(const FFIFunction& func) const {
+    std::string base = resultStructBase(func);
+    return base.empty() ? "" : identifier("result " + base, base);
+}
+
+std::string GoFFIGenerator::resultStructBase(const FFIFunction& func) const {
+    auto it = options_.result_structs.find(qualifiedName(func));
+    bool has_output = std::any_of(func.parameters.begin(), func.parameters.end(), [&](const FFIParameter& p) {
+        return isOutput(func, p);
+    });
+    if (it == options_.result_structs.end() || !has_output) {
+        return "";
+    }
+    if (!it->second.empty()) {
+        return it->second;
+    }
+    return typeName(func.class_name) + goName(func.name) + "Result";
+}
+
+std::string GoFFIGenerator::generateResultStruct(const FFIFunction& func) {
+    std::vector<std::pair<std::string, std::string>> fields;   // name, type
+    size_t width = 0;
+    for (const auto& param : func.parameters) {
+        if (isOutput(func, param)) {
+            fields.emplace_back(goName(outputFieldName(param.name)), referencedGoType(param));
+            width = std::max(width, fields.back().first.size());
+        }
+    }
+
+    std::stringstream ss;
+    ss << "// " << resultStructName(func) << " holds the outputs of " << qualifiedName(func) << ".\n";
+    ss << "type " << resultStructName(func) << " struct {\n";
+    for (const auto& field : fields) {
+        ss << "\t" << field.first << std::string(width - field.first.size(), ' ') << " " << field.second << "\n";
+    }
+    ss << "}\n";
+    return ss.str();
+}
+
+std::string GoFFIGenerator::generateFunctionBinding(const FFIFunction& func) {
+    CWrapperGenerator c_generator(options_);
+    return c_generator.generateDeclaration(func);
+}
+
+std::string GoFFIGenerator::marshalCall(const FFIFunction& func, const std::string& receiver,
+                                        std::stringstream& prelude) {
+    std::vector<std::string> args;
+
+    if (!receiver.empty()) {
+        args.push_back(receiver);
+    }
+
+    bool error_code_out = CWrapperGenerator::hasErrorCodeOut(func);
+    size_t count = func.parameters.size() - (error_code_out ? 1 : 0);
+
+    // Undeclared enumerators are undefined behavior on the C++ side
+    for (size_t i = 0; options_.validate_enums && i < count; ++i) {
+        const auto& param = func.parameters[i];
+        std::string enum_type = param.is_enum ? enumGoType(param.cpp_type) : "";
+        if (!enum_type.empty()) {
+            std::string name = argumentName(func, i);
+            prelude << "\tif !" << name << ".IsValid() {\n";
+            prelude << "\t\tpanic(\"" << qualifiedName(func) << ": invalid " << enum_type
+                    << " \" + strconv.Itoa(int(" << name << ")))\n";
+            prelude << "\t}\n";
+        }
+    }
+
+    // One C allocation holds every string argument, NUL included
+    std::vector<size_t> arena = arenaStrings(func);
+    if (!arena.empty()) {
+        std::string size;
+        for (size_t i : arena) {
+            size += "len(" + argumentName(func, i) + ") + ";
+        }
+        prelude << "\tscratch := newScratchArena(" << size << arena.size() << ")\n";
+        prelude << "\tdefer scratch.free()\n";
+    }
+
+    for (size_t i = 0; i < count; ++i) {
+        const auto& param = func.parameters[i];
+        std::string name = argumentName(func, i);
+        std::string go_type = goType(param.c_type);
+        size_t bits = CWrapperGenerator::bitsetWidth(param.cpp_type);
+
+        if (isVisitorCallback(func, param)) {
+            args.push_back("visitorCallback");
+        } else if (isVisitorContext(func, param)) {
+            args.push_back("C.uintptr_t(visitor)");
+        } else if (direction(param) != ParamDirection::In) {
+            // A C temporary, so nil dereferences panic in Go and never reach C++
+            std::string c_name = "c" + goName(name);
+            if (param.c_type == "void*") {
+                // A mirror has the C++ layout and no Go pointers, so C++ fills the Go value itself
+                if (direction(param) == ParamDirection::InOut) {
+                    prelude << "\t" << c_name << " := *" << name << "\n";
+                } else {
+                    prelude << "\tvar " << c_name << " " << referencedGoType(param) << "\n";
+                }
+                args.push_back("unsafe.Pointer(&" + c_name + ")");
+                continue;
+            }
+            if (param.c_type == "char**") {
+                // The shim frees what it is given and replaces it with its own
+                // malloc()ed copy, which is freed however the call ends
+                if (direction(param) == ParamDirection::InOut) {
+                    prelude << "\t" << c_name << ", " << c_name << "Len := C.CString(*" << name << "), C.size_t(len(*"
+                            << name << "))\n";
+                } else {
+                    prelude << "\tvar " << c_name << " *C.char\n";
+                    prelude << "\tvar " << c_name << "Len C.size_t\n";
+                }
+                prelude << "\tdefer func() { C.free(unsafe.Pointer(" << c_name << ")) }()\n";
+                args.push_back("&" + c_name);
+                args.push_back("&" + c_name + "Len");
+                continue;
+            }
+            std::string c_type = cgoType(param.c_type.substr(0, param.c_type.size() - 1));
+            if (direction(param) == ParamDirection::InOut && c_type == "C.struct_tm") {
+                prelude << "\t" << c_name << " := tmFromTime(*" << name << ")\n";
+            } else if (direction(param) == ParamDirection::InOut) {
+                prelude << "\t" << c_name << " := " << c_type << "(*" << name << ")\n";
+            } else {
+                prelude << "\tvar " << c_name << " " << c_type << "\n";
+            }
+            args.push_back("&" + c_name);
+        } else if (param.c_type == "struct tm") {
+            args.push_back("tmFromTime(" + name + ")");
+        } else if (param.c_type == "const struct tm*") {
+            std::string c_name = "c" + goName(name);
+            prelude << "\t" << c_name << " := tmFromTime(" << name << ")\n";
+            args.push_back("&" + c_name);
+        } else if (bits > 64) {
+            args.push_back("(*C.uint64_t)(unsafe.Pointer(&" + name + "[0]))");
+        } else if (bits) {
+            args.push_back("C.uint64_t(" + name + ")");
+        } else if (go_type == "string") {
+            std::string c_name = "c" + goName(name);
+            std::string value = func.printf_format && i + 1 == count ? "fmt.Sprintf(" + name + ", args...)" : name;
+            if (std::find(arena.begin(), arena.end(), i) != arena.end()) {
+                prelude << "\t" << c_name << " := scratch.copyString(" << value << ")\n";
+            } else if (passesSmallStrings(func)) {
+                prelude << "\tvar " << c_name << "Buf [smallStringSize + 1]byte\n";
+                prelude << "\t" << c_name << ", " << c_name << "Owned := cString(" << value << ", " << c_name
+                        << "Buf[:])\n";
+                prelude << "\tdefer freeCString(" << c_name << "Owned)\n";
+            } else {
+                prelude << "\t" << c_name << " := C.CString(" << value << ")\n";
+                prelude << "\tdefer C.free(unsafe.Pointer(" << c_name << "))\n";
+            }
+            args.push_back(c_name);
+        } else if (!cgoPointer(param.c_type).empty()) {
+            args.push_back("(" + cgoPointer(param.c_type) + ")(" + name + ")");
+        } else if (go_type == "unsafe.Pointer") {
+            args.push_back(name);
+        } else {
+            args.push_back(cgoType(param.c_type) + "(" + name + ")");
+        }
+    }
+
+    if (error_code_out) {
+        prelude << "\tvar ecValue C.int\n";
+        prelude << "\tvar ecCategory, ecMessage *C.char\n";
+        args.push_back("&ecValue");
+        args.push_back("&ecCategory");
+        args.push_back("&ecMessage");
+    }
+
+    std::string element;
+    size_t extent = CWrapperGenerator::arrayReturnExtent(func, &element);
+    if (extent) {
+        prelude << "\tvar out [" << extent << "]" << cgoType(element) << "\n";
+        args.push_back("&out[0]");
+    }
+
+    size_t bits = CWrapperGenerator::bitsetWidth(func.return_type);
+    if (bits > 64) {
+        prelude << "\tvar result " << bitsetGoType(bits) << "\n";
+        args.push_back("(*C.uint64_t)(unsafe.Pointer(&result[0]))");
+    }
+
+    std::string expected_error;
+    if (CWrapperGenerator::expectedTypes(func, nullptr, &expected_error)) {
+        prelude << "\tvar hasValue C.bool\n";
+        prelude << "\tvar unexpected " << (expected_error == "std::string" ? "*C.char" : "C.int") << "\n";
+        args.push_back("&hasValue");
+        args.push_back("&unexpected");
+    }
+
+    if (CWrapperGenerator(options_).catchesExceptions(func)) {
+        prelude << "\tvar exception *C.char\n";
+        args.push_back("&exception");
+    }
+
+    std::stringstream call;
+    call << "C." << CWrapperGenerator::shimName(func) << "(";
+    for (size_t i = 0; i < args.size(); ++i) {
+        if (i > 0) call << ", ";
+        call << args[i];
+    }
+    call << ")";
+
+    return call.str();
+}
+
+std::string GoFFIGenerator::generateCall(const FFIFunction& func, const std::string& receiver) {
+    std::stringstream body;
+    std::string call = marshalCall(func, receiver, body);
+    std::string go_return = goReturnType(func);
+
+    // The callback finds the Go func through a cgo.Handle, which is safe to
+    // look up again when the func visits the same object re-entrantly
+    std::string visited;
+    if (!func.visitor.callback.empty()) {
+        // A thread-safe object is unlocked while the func runs, or calling
+        // its methods from there would deadlock
+        std::string unlocked;
+        if (options_.thread_safe && receiver.size() > 4 && receiver.compare(receiver.size() - 4, 4, ".ptr") == 0) {
+            unlocked = ", unlocked: &" + receiver.substr(0, receiver.size() - 4) + ".mu";
+        }
+        std::string callback;
+        for (size_t i = 0; i < func.parameters.size(); ++i) {
+            if (isVisitorCallback(func, func.parameters[i])) {
+                callback = argumentName(func, i);
+            }
+        }
+        const FFIParameter& element = func.visitor.element;
+        std::string element_type = visitorElementType(func);
+        std::string value = element.c_type == "void*"        ? "&" + element_type.substr(1) + "{ptr: element}"
+                          : element.c_type == "const char*" ? "C.GoString((*C.char)(element))"
+                                                            : element_type + "(*(*" + cgoType(element.c_type) + ")(element))";
+        // What C++ gets once the func panicked, unless that is false
+        std::string fallback = visitorFallback(func);
+        fallback = fallback == "false" ? ""
+                 : fallback == "true"  ? ", fallback: 1"
+                                       : ", fallback: int64(" + fallback + ")";
+        if (!func.visitor.result.cpp_type.empty()) {
+            // An undeclared enumerator panics, so C++ gets the fallback instead
+            std::string result_type = enumGoType(func.visitor.result.cpp_type);
+            body << "\tstate := &visitorState{decide: func(element unsafe.Pointer) int64 {\n";
+            body << "\t\tresult := " << callback << "(" << value << ")\n";
+            body << "\t\tif !result.IsValid() {\n";
+            body << "\t\t\tpanic(\"" << qualifiedName(func) << ": invalid " << result_type
+                 << " \" + strconv.Itoa(int(result)))\n";
+            body << "\t\t}\n";
+            body << "\t\treturn int64(result)\n";
+            body << "\t}" << fallback << unlocked << "}\n";
+        } else {
+            body << "\tstate := &visitorState{visit: func(element unsafe.Pointer) bool { return " << callback << "("
+                 << value << ") }" << (func.visitor.policy.empty() ? "" : ", policy: true") << fallback << unlocked
+                 << "}\n";
+        }
+        body << "\tvisitor := cgo.NewHandle(state)\n";
+        body << "\tdefer visitor.Delete()\n";
+        visited = "\tstate.done()\n";
+    }
+    std::string expected_error;
+    bool expected = CWrapperGenerator::expectedTypes(func, nullptr, &expected_error);
+    bool error_code_out = CWrapperGenerator::hasErrorCodeOut(func);
+    // Errors found in Go (invalid arguments, a nullptr from a factory) return
+    // early, so the call that gets to the end succeeded
+    bool checked_in_go = (returnsValidationError(func) || returnsFactoryError(func)) && !expected &&
+                         !error_code_out && !func.returns_status && !returnsException(func);
+
+    // In/out parameters are copied back after the call; outputs follow the value
+    std::stringstream copy_back;
+    std::vector<std::string> outputs;
+    for (size_t i = 0; i < func.parameters.size(); ++i) {
+        const auto& param = func.parameters[i];
+        std::string name = argumentName(func, i);
+        if (direction(param) == ParamDirection::In || param.name == func.array_size) {
+            continue;
+        }
+        std::string c_name = "c" + goName(name);
+        // One copy of the shim's buffer, which needs no terminator
+        std::string converted = param.c_type == "void*"       ? c_name
+                              : param.c_type == "struct tm*" ? "timeFromTm(" + c_name + ")"
+                              : param.c_type == "char**"
+                                  ? "string(unsafe.Slice((*byte)(unsafe.Pointer(" + c_name + ")), " + c_name + "Len))"
+                                  : referencedGoType(param) + "(" + c_name + ")";
+        if (direction(param) == ParamDirection::InOut) {
+            copy_back << "\t*" << name << " = " << converted << "\n";
+        } else if (direction(param) == ParamDirection::Out) {
+            outputs.push_back(resultStructName(func).empty()
+                                  ? converted
+                                  : goName(outputFieldName(param.name)) + ": " + converted);
+        }
+    }
+    if (!outputs.empty() && !resultStructName(func).empty()) {
+        std::string grouped = resultStructName(func) + "{";
+        for (size_t i = 0; i < outputs.size(); ++i) {
+            grouped += (i > 0 ? ", " : "") + outputs[i];
+        }
+        outputs = {grouped + "}"};
+    }
+    bool has_outputs = !copy_back.str().empty() || !outputs.empty();
+
+    // A C++ exception caught by the shim is checked right after the call
+    std::string check;
+    if (returnsException(func)) {
+        std::vector<std::string> types = goResultTypes(func);
+        std::string zeros;
+        for (size_t i = 0; i + 1 < types.size(); ++i) {
+            zeros += goZero(types[i]) + ", ";
+        }
+        check = "\tif err := exceptionResult(\"" + qualifiedName(func) + "\", exception); err != nil {\n"
+                "\t\treturn " + zeros + "err\n"
+                "\t}\n";
+    } else if (CWrapperGenerator(options_).catchesExceptions(func)) {
+        check = "\tpanicOnException(\"" + qualifiedName(func) + "\", exception)\n";
+    }
+    // A panic in the visitor's func comes first
+    check = visited + check;
+
+    // First the value (if any), then the outputs, then the error (if any)
+    std::string value;
+    std::string element;
+    if (CWrapperGenerator::arrayReturnExtent(func, &element)) {
+        body << "\t" << call << "\n" << check;
+        body << "\tvar result " << go_return << "\n";
+        body << "\tfor i, v := range out {\n";
+        body << "\t\tresult[i] = " << goType(element) << "(v)\n";
+        body << "\t}\n";
+        value = "result";
+    } else if (CWrapperGenerator::uniqueArrayReturn(func)) {
+        // The slice copies the array, which goes straight back to delete[]
+        std::string length;
+        for (size_t i = 0; i < func.parameters.size(); ++i) {
+            if (func.parameters[i].name == func.array_size) {
+                std::string name = argumentName(func, i);
+                length = direction(func.parameters[i]) == ParamDirection::Out ? "c" + goName(name) : name;
+            }
+        }
+        body << "\tarray := " << call << "\n" << check;
+        body << "\tdefer C." << CWrapperGenerator::arrayDeleterName(func) << "(array)\n";
+        body << "\tresult := make(" << go_return << ", " << length << ")\n";
+        body << "\tfor i, v := range unsafe.Slice(array, " << length << ") {\n";
+        body << "\t\tresult[i] = " << go_return.substr(2) << "(v)\n";
+        body << "\t}\n";
+        value = "result";
+    } else if (CWrapperGenerator::bitsetWidth(func.return_type) > 64) {
+        body << "\t" << call << "\n" << check;
+        value = "result";
+    } else if (func.returns_status) {
+        body << "\tstatus := " << call << "\n" << check;
+    } else if (go_return.empty() && returnsException(func) && !has_outputs && !expected && !error_code_out) {
+        body << "\t" << call << "\n" << visited;
+        body << "\treturn exceptionResult(\"" << qualifiedName(func) << "\", exception)\n";
+        return body.str();
+    } else if (go_return.empty()) {
+        body << "\t" << call << "\n" << check;
+    } else if (!func.factory.empty() && !mirrors_.count(func.factory)) {
+        // A factory's object comes back as the wrapper of the class it returns
+        std::string wrapped = "&" + go_return.substr(1) + "{ptr: ptr" + (func.borrowed ? ", borrowed: true" : "") + "}";
+        body << "\tptr := " << call << "\n" << check;
+        if (returnsFactoryError(func)) {
+            std::vector<std::string> types = goResultTypes(func);
+            std::string zeros;
+            for (size_t i = 0; i + 1 < types.size(); ++i) {
+                zeros += goZero(types[i]) + ", ";
+            }
+            body << "\tif ptr == nil {\n";
+            body << "\t\treturn " << zeros << "&FactoryError{Function: \"" << qualifiedName(func) << "\"}\n";
+            body << "\t}\n";
+            body << "\tresult := " << wrapped << "\n";
+        } else if (goResultTypes(func).size() == 1) {
+            body << "\tif ptr == nil {\n";
+            body << "\t\treturn nil\n";
+            body << "\t}\n";
+            body << "\treturn " << wrapped << "\n";
+            return body.str();
+        } else {
+            body << "\tvar result " << go_return << "\n";
+            body << "\tif ptr != nil {\n";
+            body << "\t\tresult = " << wrapped << "\n";
+            body << "\t}\n";
+        }
+        value = "result";
+    } else {
+        std::string converted = call;
+        if (go_return == "string") {
+            converted = "C.GoString(" + call + ")";
+        } else if (go_return == "time.Time") {
+            converted = "timeFromTm(" + call + ")";
+        } else if (go_return != "unsafe.Pointer") {
+            converted = go_return + "(" + call + ")";
+        } else if (!cgoPointer(func.c_return_type).empty()) {
+            converted = "unsafe.Pointer(" + call + ")";
+        }
+        if (!expected && !error_code_out && !has_outputs && check.empty() && !checked_in_go) {
+            body << "\treturn " << converted << "\n";
+            return body.str();
+        }
+        body << "\tresult := " << converted << "\n" << check;
+        value = "result";
+    }
+    body << copy_back.str();
+
+    for (const auto& output : outputs) {
+        value = returnsCommaOk(func) ? output + ", " + value : value + (value.empty() ? "" : ", ") + output;
+    }
+    std::string values = value.empty() ? "" : value + ", ";
+    if (expected) {
+        body << "\tif !hasValue {\n";
+        if (expected_error == "std::string") {
+            body << "\t\tdefer C.free(unsafe.Pointer(unexpected))\n";
+            body << "\t\treturn " << values << "errors.New(C.GoString(unexpected))\n";
+        } else {
+            body << "\t\treturn " << values << "&UnexpectedError{Type: \"" << expected_error
+                 << "\", Value: int(unexpected)}\n";
+        }
+        body << "\t}\n";
+        body << "\treturn " << values << "nil\n";
+    } else if (error_code_out) {
+        body << "\treturn " << values << "errorCodeResult(ecValue, ecCategory, ecMessage)\n";
+    } else if (func.returns_status) {
+        std::string result = "statusResult(\"" + qualifiedName(func) + "\", int64(status))";
+        std::string invalidating = receiver.empty() ? "" : invalidatingStatus(func.class_name);
+        if (!invalidating.empty()) {
+            // Later calls see the flag before they reach the dead handle
+            std::string owner = receiver.substr(0, receiver.rfind('.'));
+            body << "\tif " << invalidating << " {\n";
+            body << "\t\t" << owner << ".invalid.Store(true)\n";
+            body << "\t\treturn " << values << "&HandleInvalidatedError{Err: " << result << "}\n";
+            body << "\t}\n";
+        }
+        body << "\treturn " << values << result << "\n";
+    } else if (returnsException(func) || checked_in_go) {
+        body << "\treturn " << values << "nil\n";
+    } else if (!value.empty()) {
+        body << "\treturn " << value << "\n";
+    }
+
+    return body.str();
+}
+
+std::string GoFFIGenerator::generateWrapper(const FFIFunction& func) {
+    std::stringstream ss;
+    std::string go_name = wrapperName(func);
+    std::string qualified = qualifiedName(func);
+    if (!func.logger.callback.empty()) {
+        return generateLogger(func);
+    }
+
+    ss << "// " << go_name << " wraps " << qualified << ".\n";
+    ss << visitorDoc(func, "");
+    ss << referenceDoc(func, goReturnType(func));
+    if (!func.factory.empty() && !mirrors_.count(func.factory)) {
+        std::string type_name = typeName(func.factory);
+        ss << (func.borrowed ? "// C++ keeps ownership of the returned " + type_name + ", whose Delete only detaches it.\n"
+                             : "// The caller owns the returned " + type_name + "; call Delete when done.\n");
+    }
+    if (returnsFactoryError(func)) {
+        ss << "// A nullptr from C++ is returned as a *FactoryError.\n";
+    }
+    if (func.printf_format) {
+        ss << kPrintfDoc;
+    }
+    if (returnsException(func)) {
+        ss << kThrowsDoc;
+    }
+    if (func.signal_unsafe) {
+        ss << signalUnsafeDoc(func, options_.signal_unsafe_tag);
+    }
+    ss << preservedSignalsDoc(func, options_);
+    ss << validationDoc(func);
+    std::string variant = generateDefaultVariant(func, go_name, "", "");
+    if (!variant.empty()) {
+        variant = "\n" + variant;
+    }
+    std::string collector = generateCollector(func, go_name, "", "");
+    if (!collector.empty()) {
+        variant += "\n" + collector;
+    }
+    if (!func.main_thread_only) {
+        ss << "func " << go_name << goSignature(func) << " {\n";
+        ss << validationGuard(func) << generateCall(func, "");
+        ss << "}\n";
+        return ss.str() + variant;
+    }
+
+    // The exported wrapper hops to the main thread and makes the call there
+    std::string direct = identifier("direct " + functionEntity(func), goParamName(go_name));
+    ss << "// It must run on the main thread and is dispatched through RunOnMainThread.\n";
+    ss << "func " << go_name << goSignature(func) << " {\n";
+    ss << validationGuard(func) << generateMainThreadDispatch(func, direct + "(" + goArgumentNames(func) + ")");
+    ss << "}\n\n";
+    ss << "func " << direct << goSignature(func) << " {\n";
+    ss << generateCall(func, "");
+    ss << "}\n";
+
+    return ss.str() + variant;
+}
+
+std::string GoFFIGenerator::generateMethod(const FFIClass& cls, const FFIFunction& method) {
+    std::string type_name = typeName(cls.name);
+    std::string recv = receiverName(type_name);
+    // A tagged payload's name goes to the typed accessor of generatePayload
+    std::string method_name = taggedPayload(method) ? rawPayloadName(method) : methodName(method);
+    std::string receiver = "func (" + recv + " *" + type_name + ") ";
+    std::string lock = options_.thread_safe ? "\t" + recv + ".mu.Lock()\n\tdefer " + recv + ".mu.Unlock()\n" : "";
+    std::stringstream ss;
+    if (isCached(method)) {
+        return generateCachedMethod(cls, method);
+    }
+    // An invalidated handle is never passed to C++; Reconnect waits for calls in flight
+    if (options_.handle_invalidation.count(cls.name)) {
+        std::vector<std::string> types = goResultTypes(method);
+        std::string guard = "\t\tpanic(ErrHandleInvalidated)\n";
+        if (!types.empty() && types.back() == "error") {
+            guard = "\t\treturn ";
+            for (size_t i = 0; i + 1 < types.size(); ++i) {
+                guard += goZero(types[i]) + ", ";
+            }
+            guard += "ErrHandleInvalidated\n";
+        }
+        lock = "\t" + recv + ".handle.RLock()\n\tdefer " + recv + ".handle.RUnlock()\n" +
+               "\tif " + recv + ".invalid.Load() {\n" + guard + "\t}\n" + lock;
+    }
+    // A setter clears the cached getter once it has run
+    for (const auto& field : clearedCaches(cls, method)) {
+        lock += "\tdefer " + recv + "." + field + ".clear()\n";
+    }
+
+    // Arguments are checked before anything is locked
+    lock = validationGuard(method) + lock;
+
+    const FFIFunction* size = method.subscript ? sizeMethod(cls) : nullptr;
+    if (size && options_.bounds_check) {
+        // Checked under the same lock as the access, so the size cannot change in between
+        std::string index = argumentName(method, 0);
+        std::string call = "C." + CWrapperGenerator::shimName(*size) + "(" + recv + ".ptr";
+        if (CWrapperGenerator(options_).catchesExceptions(*size)) {
+            lock += "\tvar sizeException *C.char\n";
+            lock += "\tn := int(" + call + ", &sizeException))\n";
+            lock += "\tpanicOnException(\"" + qualifiedName(*size) + "\", sizeException)\n";
+            lock += "\tif " + index + " < 0 || " + index + " >= n {\n";
+        } else {
+            lock += "\tif n := int(" + call + ")); " + index + " < 0 || " + index + " >= n {\n";
+        }
+        lock += "\t\tpanic(\"" + cls.name + "::operator[]: index \" + strconv.Itoa(" + index +
+                ") + \" out of range [0:\" + strconv.Itoa(n) + \"]\")\n";
+        lock += "\t}\n";
+    }
+
+    if (method.atomic) {
+        // Nothing is cached on the Go side, so every call is the C++ load or store itself
+        ss << "// " << method_name << " atomically " << (method.parameters.empty() ? "loads " : "stores value into ")
+           << cls.name << "::" << method.field_name << " with std::atomic::" << method.name.substr(0, method.name.find('_'))
+           << ".\n";
+        ss << "// It may run concurrently with the other atomic accessors of " << recv << ".\n";
+    } else if (method.subscript) {
+        ss << "// " << method_name << (method.name == "at" ? " returns element i of " : " stores value at element i of ")
+           << recv << " through " << cls.name << "::operator[].\n";
+        if (size && options_.bounds_check) {
+            ss << "// It panics if i is outside [0, " << recv << "." << methodName(*size) << "()).\n";
+        } else {
+            ss << "// It does not check i, which must index an element of " << recv << ".\n";
+        }
+    } else {
+        ss << "// " << method_name << " wraps " << cls.name << "::" << method.name << ".\n";
+    }
+    ss << visitorDoc(method, recv);
+    ss << referenceDoc(method, goReturnType(method));
+    if (method.printf_format) {
+        ss << kPrintfDoc;
+    }
+    if (returnsException(method)) {
+        ss << kThrowsDoc;
+    }
+    if (method.signal_unsafe) {
+        ss << signalUnsafeDoc(method, options_.signal_unsafe_tag);
+    }
+    ss << preservedSignalsDoc(method, options_);
+    ss << validationDoc(method);
+    std::string variant = generateDefaultVariant(method, method_name, "(" + recv + " *" + type_name + ") ",
+                                                 recv + ".");
+    if (!variant.empty()) {
+        variant = "\n" + variant;
+    }
+    std::string collector = generateCollector(method, method_name, "(" + recv + " *" + type_name + ") ", recv + ".");
+    if (!collector.empty()) {
+        variant += "\n" + collector;
+    }
+    if (!method.main_thread_only) {
+        ss << receiver << method_name << goSignature(method) << " {\n";
+        ss << lock << generateMethodCall(cls, method, recv);
+        ss << "}\n";
+        return ss.str() + variant;
+    }
+
+    std::string direct = identifier("direct " + functionEntity(method), goParamName(method_name));
+    ss << "// It must run on the main thread and is dispatched through RunOnMainThread.\n";
+    ss << receiver << method_name << goSignature(method) << " {\n";
+    ss << lock << generateMainThreadDispatch(method, recv + "." + direct + "(" + goArgumentNames(method) + ")");
+    ss << "}\n\n";
+    ss << receiver << direct << goSignature(method) << " {\n";
+    ss << generateMethodCall(cls, method, recv);
+    ss << "}\n";
+
+    return ss.str() + variant;
+}
+
+std::string GoFFIGenerator::generateMethodCall(const FFIClass& cls, const FFIFunction& method,
+                                               const std::string& recv) {
+    std::string call = generateCall(method, recv + ".ptr");
+    for (const auto& direct : CWrapperGenerator::directShims(cls)) {
+        if (CWrapperGenerator::shimName(direct) != CWrapperGenerator::shimName(method) + "_direct") {
+            continue;
+        }
+        // An object Go constructed skips the vtable through the direct shim
+        std::string body = generateCall(direct, recv + ".ptr");
+        if (goResultTypes(method).empty()) {
+            body += "\treturn\n";
+        }
+        std::string indented;
+        std::istringstream lines(body);
+        for (std::string line; std::getline(lines, line);) {
+            indented += "\t" + line + "\n";
+        }
+        return "\tif " + recv + ".exact {\n" + indented + "\t}\n" + call;
+    }
+    return call;
+}
+
+bool GoFFIGenerator::isCached(const FFIFunction& func) {
+    // A cached result would outlive an invalidated handle
+    return options_.cached_strings && func.cached && !options_.handle_invalidation.count(func.class_name) && func.is_method && !func.is_static && !func.is_constructor &&
+           !func.is_destructor && !func.main_thread_only && func.parameters.empty() &&
+           goResultTypes(func) == std::vector<std::string>{"string"};
+}
+
+std::vector<FFIFunction> GoFFIGenerator::cachedMethods(const FFIClass& cls) {
+    std::vector<FFIFunction> cached;
+    for (const auto& shim : CWrapperGenerator::shimFunctions(cls)) {
+        if (isCached(shim)) {
+            cached.push_back(shim);
+        }
+    }
+    return cached;
+}
+
+std::vector<std::string> GoFFIGenerator::clearedCaches(const FFIClass& cls, const FFIFunction& method) {
+    std::vector<std::string> fields;
+    if (method.is_const || method.is_static || method.parameters.size() != 1) {
+        return fields;
+    }
+    // setName and set_name set name
+    for (const auto& getter : cachedMethods(cls)) {
+        std::string capitalized = getter.name;
+        capitalized[0] = static_cast<char>(std::toupper(static_cast<unsigned char>(capitalized[0])));
+        if (method.name == "set" + capitalized || method.name == "set_" + getter.name) {
+            fields.push_back(cacheField(getter));
+        }
+    }
+    return fields;
+}
+
+std::string GoFFIGenerator::generateCachedMethod(const FFIClass& cls, const FFIFunction& method) {
+    std::string type_name = typeName(cls.name);
+    std::string recv = receiverName(type_name);
+    std::string method_name = methodName(method);
+    std::string uncached = identifier("uncached " + functionEntity(method), method_name + "Uncached");
+    std::string field = recv + "." + cacheField(method);
+    std::string receiver = "func (" + recv + " *" + type_name + ") ";
+
+    std::vector<std::string> clearing;
+    for (const auto& shim : CWrapperGenerator::shimFunctions(cls)) {
+        std::vector<std::string> cleared = clearedCaches(cls, shim);
+        if (std::find(cleared.begin(), cleared.end(), cacheField(method)) != cleared.end()) {
+            clearing.push_back(methodName(shim));
+        }
+    }
+    clearing.push_back("InvalidateCache");
+    std::string setters;
+    for (size_t i = 0; i < clearing.size(); ++i) {
+        setters += (i == 0 ? "" : i + 1 == clearing.size() ? " or " : ", ") + clearing[i];
+    }
+    std::string unsafe_doc = method.signal_unsafe ? signalUnsafeDoc(method, options_.signal_unsafe_tag) : "";
+    std::stringstream ss;
+
+    ss << "// " << method_name << " wraps " << cls.name << "::" << method.name << " and caches the result:\n";
+    ss << "// changes made on the C++ side are not seen until " << setters << " clears it.\n";
+    ss << "// " << uncached << " always reads from C++.\n";
+    ss << unsafe_doc;
+    ss << receiver << method_name << "() string {\n";
+    ss << "\treturn " << field << ".load(" << recv << "." << uncached << ")\n";
+    ss << "}\n\n";
+
+    ss << "// " << uncached << " wraps " << cls.name << "::" << method.name << ", bypassing the cache of "
+       << method_name << ".\n";
+    ss << unsafe_doc;
+    ss << receiver << uncached << "() string {\n";
+    if (options_.thread_safe) {
+        ss << "\t" << recv << ".mu.Lock()\n";
+        ss << "\tdefer " << recv << ".mu.Unlock()\n";
+    }
+    ss << generateCall(method, recv + ".ptr");
+    ss << "}\n";
+
+    return ss.str();
+}
+
+std::string GoFFIGenerator::generateStringCacheSupport() {
+    return
+        "// stringCache holds the cached result of a string accessor. clear bumps gen\n"
+        "// before dropping the value, and load takes back what it published if gen\n"
+        "// moved while it read from C++, so a result read before a setter ran is\n"
+        "// never left cached after it.\n"
+        "type stringCache struct {\n"
+        "\tvalue atomic.Pointer[string]\n"
+        "\tgen   atomic.Uint64\n"
+        "}\n"
+        "\n"
+        "// load returns the cached result, calling read for it when there is none.\n"
+        "func (c *stringCache) load(read func() string) string {\n"
+        "\tif cached := c.value.Load(); cached != nil {\n"
+        "\t\treturn *cached\n"
+        "\t}\n"
+        "\tgen := c.gen.Load()\n"
+        "\tresult := read()\n"
+        "\tif c.value.CompareAndSwap(nil, &result) && c.gen.Load() != gen {\n"
+        "\t\tc.value.CompareAndSwap(&result, nil)\n"
+        "\t}\n"
+        "\treturn result\n"
+        "}\n"
+        "\n"
+        "// clear drops the cached result, along with one being read meanwhile.\n"
+        "func (c *stringCache) clear() {\n"
+        "\tc.gen.Add(1)\n"
+        "\tc.value.Store(nil)\n"
+        "}\n";
+}
+
+std::string GoFFIGenerator::generateDefaultVariant(const FFIFunction& func, const std::string& go_name,
+                                                  const std::string& receiver, const std::string& callee) {
+    size_t first = firstDefault(func);
+    size_t count = func.parameters.size() - (CWrapperGenerator::hasErrorCodeOut(func) ? 1 : 0);
+    if (first == count) {
+        return "";
+    }
+
+    // The variant takes the parameters before the defaulted ones
+    FFIFunction shortened = func;
+    shortened.parameters.erase(shortened.parameters.begin() + first, shortened.parameters.begin() + count);
+
+    // gofmt spaces operators more tightly in calls with several arguments
+    int depth = goArgumentNames(func).find(',') == std::string::npos ? 1 : 2;
+    std::string arguments;
+    std::string defaults;
+    for (size_t i = 0; i < count; ++i) {
+        if (direction(func.parameters[i]) == ParamDirection::Out) {
+            continue;
+        }
+        std::string argument = i < first ? argumentName(func, i) : goDefault(func.parameters[i], depth);
+        arguments += (arguments.empty() ? "" : ", ") + argument;
+        if (i >= first) {
+            defaults += (defaults.empty() ? "" : ", ") + argumentName(func, i) + " = " + goDefault(func.parameters[i]);
+        }
+    }
+
+    std::string signature = func.is_constructor ? goParameterList(shortened) + " *" + typeName(func.class_name)
+                                                : goSignature(shortened);
+    std::string name = identifier("default " + functionEntity(func), go_name + "Default");
+    bool returns = func.is_constructor || !goResultTypes(func).empty();
+    std::stringstream ss;
+    ss << "// " << name << " calls " << go_name << " with the C++ default arguments\n";
+    ss << "// " << defaults << ".\n";
+    ss << "func " << receiver << name << signature << " {\n";
+    ss << "\t" << (returns ? "return " : "") << callee << go_name << "(" << arguments << ")\n";
+    ss << "}\n";
+    return ss.str();
+}
+
+std::string GoFFIGenerator::generateMainThreadDispatch(const FFIFunction& func, const std::string& direct_call) {
+    std::vector<std::string> types = goResultTypes(func);
+    std::vector<std::string> names;
+    if (!goReturnType(func).empty()) {
+        names.push_back("result");
+    }
+    if (!resultStructName(func).empty()) {
+        names.push_back(goParamName(resultStructName(func)));
+    }
+    for (size_t i = 0; i < func.parameters.size() && resultStructName(func).empty(); ++i) {
+        if (isOutput(func, func.parameters[i])) {
+            names.push_back(argumentName(func, i));
+        }
+    }
+    if (returnsCommaOk(func)) {
+        std::swap(names[0], names[1]);
+    }
+    if (names.size() < types.size()) {
+        names.push_back("err");
+    }
+
+    std::string results;
+    std::stringstream ss;
+    for (size_t i = 0; i < names.size(); ++i) {
+        ss << "\tvar " << names[i] << " " << types[i] << "\n";
+        results += (i > 0 ? ", " : "") + names[i];
+    }
+    ss << "\tonMainThread(\"" << qualifiedName(func) << "\", func() {\n";
+    ss << "\t\t" << (results.empty() ? "" : results + " = ") << direct_call << "\n";
+    ss << "\t})\n";
+    if (!results.empty()) {
+        ss << "\treturn " << results << "\n";
+    }
+
+    return ss.str();
+}
+
+std::string GoFFIGenerator::generateConstructor(const FFIClass& cls, const FFIFunction& ctor, size_t index) {
+    std::string type_name = typeName(cls.name);
+    std::string func_name = constructorName(cls, index);
+    std::stringstream ss;
+
+    ss << "// " << func_name << " constructs a " << cls.name << ". Call Delete when done.\n";
+    if (ctor.signal_unsafe) {
+        ss << signalUnsafeDoc(ctor, options_.signal_unsafe_tag);
+    }
+    if (ctor.main_thread_only) {
+        ss << "// It must run on the main thread and is dispatched through RunOnMainThread.\n";
+    }
+    ss << validationDoc(ctor);
+    ss << "func " << func_name << goParameterList(ctor) << " *" << type_name << " {\n";
+    ss << validationGuard(ctor);
+
+    // What the constructor makes is exactly the class, so virtual calls need no dispatch
+    std::string exact = cls.devirtualize && !cls.is_mirrored ? ", exact: true" : "";
+    std::stringstream prelude;
+    std::string call = marshalCall(ctor, "", prelude);
+    ss << prelude.str();
+    // Constructors have no error result, so even // @throws ones panic
+    std::string check;
+    if (CWrapperGenerator(options_).catchesExceptions(ctor)) {
+        check = "\tpanicOnException(\"" + qualifiedName(ctor) + "\", exception)\n";
+    }
+    if (ctor.main_thread_only) {
+        ss << "\tvar ptr unsafe.Pointer\n";
+        ss << "\tonMainThread(\"" << qualifiedName(ctor) << "\", func() {\n";
+        ss << "\t\tptr = " << call << "\n";
+        ss << "\t})\n";
+        ss << check;
+        ss << "\treturn &" << type_name << "{ptr: ptr" << exact << "}\n";
+    } else if (!check.empty()) {
+        ss << "\tptr := " << call << "\n";
+        ss << check;
+        ss << "\treturn &" << type_name << "{ptr: ptr" << exact << "}\n";
+    } else {
+        ss << "\treturn &" << type_name << "{ptr: " << call << exact << "}\n";
+    }
+    ss << "}\n";
+
+    std::string variant = generateDefaultVariant(ctor, func_name, "", "");
+    if (!variant.empty()) {
+        ss << "\n" << variant;
+    }
+    return ss.str();
+}
+
+std::string GoFFIGenerator::generateDestructor(const FFIClass& cls) {
+    std::string type_name = typeName(cls.name);
+    std::string recv = receiverName(type_name);
+    std::stringstream ss;
+
+    FFIFunction dtor;
+    dtor.class_name = cls.name;
+    dtor.c_name = cls.symbol_stem.empty() ? "" : cls.symbol_stem + "_delete";
+    dtor.is_destructor = true;
+
+    ss << "// Delete frees the underlying C++ object. It is safe to call more than once.\n";
+    if (cls.is_abstract) {
+        ss << "// The virtual destructor cleans up after the concrete class.\n";
+    }
+    // Objects from a // @borrowed factory still belong to C++
+    bool lends = lendsHandles(cls);
+    std::string borrowed = lends ? " && !" + recv + ".borrowed" : "";
+    if (lends) {
+        ss << "// A borrowed object, which C++ keeps ownership of, is only detached.\n";
+    }
+    auto rule = options_.handle_invalidation.find(cls.name);
+    if (rule != options_.handle_invalidation.end()) {
+        // Unless the rule says the destructor is safe, a dead handle is never passed to C++
+        std::string valid = " && !" + recv + ".invalid.Load()";
+        if (rule->second.delete_invalidated) {
+            ss << "// An invalidated handle is deleted too.\n";
+            valid = "";
+        } else {
+            ss << "// An invalidated handle is dropped without calling C++, which leaks the\n";
+            ss << "// C++ object; HandleInvalidation::delete_invalidated frees it instead.\n";
+        }
+        ss << "func (" << recv << " *" << type_name << ") Delete() {\n";
+        ss << "\t" << recv << ".handle.Lock()\n";
+        ss << "\tdefer " << recv << ".handle.Unlock()\n";
+        ss << "\tif " << recv << ".ptr != nil" << valid << borrowed << " {\n";
+        ss << "\t\tC." << CWrapperGenerator::shimName(dtor) << "(" << recv << ".ptr)\n";
+        ss << "\t}\n";
+        ss << "\t" << recv << ".ptr = nil\n";
+        ss << "\t" << recv << ".invalid.Store(false)\n";
+        ss << "}\n";
+        return ss.str();
+    }
+    ss << "func (" << recv << " *" << type_name << ") Delete() {\n";
+    if (options_.thread_safe) {
+        ss << "\t" << recv << ".mu.Lock()\n";
+        ss << "\tdefer " << recv << ".mu.Unlock()\n";
+    }
+    if (lends) {
+        ss << "\tif " << recv << ".ptr != nil" << borrowed << " {\n";
+        ss << "\t\tC." << CWrapperGenerator::shimName(dtor) << "(" << recv << ".ptr)\n";
+        ss << "\t}\n";
+        ss << "\t" << recv << ".ptr = nil\n";
+        ss << "}\n";
+        return ss.str();
+    }
+    ss << "\tif " << recv << ".ptr != nil {\n";
+    ss << "\t\tC." << CWrapperGenerator::shimName(dtor) << "(" << recv << ".ptr)\n";
+    ss << "\t\t" << recv << ".ptr = nil\n";
+    ss << "\t}\n";
+    ss << "}\n";
+
+    return ss.str();
+}
+
+std::string GoFFIGenerator::generatePool(const FFIClass& cls) {
+    std::string type_name = typeName(cls.name);
+    std::string pool_name = poolName(cls);
+    std::string pool_constructor = identifier("pool constructor " + cls.name, "New" + pool_name);
+    std::string reset = methodName(poolResetMethod(cls));
+    std::stringstream ss;
+
+    ss << "// " << pool_name << " recycles " << type_name << " objects through a sync.Pool. Put resets an\n";
+    ss << "// object with " << cls.name << "::" << cls.pool_reset << " instead of freeing it, so hot paths avoid\n";
+    ss << "// allocating a new C++ object on every use.\n";
+    ss << "type " << pool_name << " struct {\n";
+    ss << "\tpool sync.Pool\n";
+    ss << "}\n\n";
+
+    ss << "// " << pool_constructor << " returns a pool that calls create when it has no object to hand out.\n";
+    ss << "// Objects the pool drops during garbage collection are deleted by a finalizer.\n";
+    ss << "func " << pool_constructor << "(create func() *" << type_name << ") *" << pool_name << " {\n";
+    ss << "\tp := &" << pool_name << "{}\n";
+    ss << "\tp.pool.New = func() any {\n";
+    ss << "\t\tobj := create()\n";
+    ss << "\t\truntime.SetFinalizer(obj, (*" << type_name << ").Delete)\n";
+    ss << "\t\treturn obj\n";
+    ss << "\t}\n";
+    ss << "\treturn p\n";
+    ss << "}\n\n";
+
+    ss << "// Get returns a reset " << type_name << " from the pool, creating one if the pool is empty.\n";
+    ss << "func (p *" << pool_name << ") Get() *" << type_name << " {\n";
+    ss << "\treturn p.pool.Get().(*" << type_name << ")\n";
+    ss << "}\n\n";
+
+    ss << "// Put resets obj and returns it to the pool. obj must not be used afterwards;\n";
+    ss << "// deleted objects are ignored.\n";
+    ss << "func (p *" << pool_name << ") Put(obj *" << type_name << ") {\n";
+    ss << "\tif obj == nil || obj.ptr == nil {\n";
+    ss << "\t\treturn\n";
+    ss << "\t}\n";
+    ss << "\tobj." << reset << "()\n";
+    if (!cachedMethods(cls).empty()) {
+        ss << "\tobj.InvalidateCache()\n";
+    }
+    ss << "\tp.pool.Put(obj)\n";
+    ss << "}\n";
+
+    return ss.str();
+}
+
+std::string GoFFIGenerator::generateClassBinding(const FFIClass& cls) {
+    std::string type_name = typeName(cls.name);
+    std::stringstream ss;
+
+    std::vector<std::pair<std::string, std::string>> fields = {{"ptr", "unsafe.Pointer"}};
+    if (options_.thread_safe) {
+        fields.emplace_back("mu", "sync.Mutex");
+    }
+    auto rule = options_.handle_invalidation.find(cls.name);
+    bool invalidates = rule != options_.handle_invalidation.end();
+    if (invalidates) {
+        fields.emplace_back("handle", "sync.RWMutex");
+        fields.emplace_back("invalid", "atomic.Bool");
+    }
+    if (lendsHandles(cls)) {
+        fields.emplace_back("borrowed", "bool");
+    }
+    if (!CWrapperGenerator::directShims(cls).empty()) {
+        fields.emplace_back("exact", "bool");
+    }
+    std::vector<FFIFunction> cached = cachedMethods(cls);
+    for (const auto& method : cached) {
+        fields.emplace_back(cacheField(method), "stringCache");
+    }
+    size_t width = 0;
+    for (const auto& field : fields) {
+        width = std::max(width, field.first.size());
+    }
+
+    ss << "// " << type_name << " wraps the C++ class " << cls.name << ".\n";
+    if (cls.is_abstract) {
+        ss << "// The class is abstract: factories make its objects, and its virtual methods\n";
+        ss << "// dispatch to the concrete class C++ chose.\n";
+    }
+    if (options_.thread_safe) {
+        ss << "// It is safe for concurrent use by multiple goroutines: its methods hold\n";
+        ss << "// a mutex for the duration of each C++ call.\n";
+    } else {
+        ss << "// It is not safe for concurrent use by multiple goroutines.\n";
+    }
+    if (!CWrapperGenerator::directShims(cls).empty()) {
+        ss << "// Objects its constructors make call its virtual methods without dispatch.\n";
+    }
+    if (invalidates) {
+        std::string errors;
+        for (size_t i = 0; i < rule->second.errors.size(); ++i) {
+            const auto& all = rule->second.errors;
+            errors += (i == 0 ? "" : i + 1 == all.size() ? " or " : ", ") + all[i];
+        }
+        ss << "// A method reporting " << errors << " invalidates its C++ handle: later\n";
+        ss << "// calls return ErrHandleInvalidated, or panic with it, without calling C++";
+        ss << (rule->second.factory.empty() ? ".\n" : "\n// until Reconnect replaces the handle.\n");
+    }
+    ss << "type " << type_name << " struct {\n";
+    for (const auto& field : fields) {
+        ss << "\t" << field.first << std::string(width - field.first.size() + 1, ' ') << field.second << "\n";
+    }
+    ss << "}\n\n";
+
+    size_t ctor_index = 0;
+    for (const auto& shim : CWrapperGenerator::shimFunctions(cls)) {
+        // Signal-unsafe members live in generateSignalUnsafeFile, if anywhere,
+        // members of another feature in generateFeatureFiles and those only
+        // some targets declare in generateTargetFiles
+        bool elsewhere = shim.signal_unsafe || configuredFeature(shim.feature) != configuredFeature(cls.feature) ||
+                         shim.targets != cls.targets;
+        if (shim.is_constructor) {
+            size_t index = ctor_index++;
+            if (!elsewhere) {
+                ss << generateConstructor(cls, shim, index) << "\n";
+            }
+        } else if (elsewhere || !shim.iteration.empty()) {
+            continue;
+        } else if (shim.is_destructor) {
+            ss << generateDestructor(cls) << "\n";
+        } else if (shim.is_static) {
+            ss << generateWrapper(shim) << "\n";
+        } else {
+            ss << generateMethod(cls, shim) << "\n";
+            if (taggedPayload(shim)) {
+                ss << generatePayload(cls, shim) << "\n";
+            }
+        }
+    }
+
+    if (!cached.empty()) {
+        std::string recv = receiverName(type_name);
+        std::string names;
+        for (size_t i = 0; i < cached.size(); ++i) {
+            names += (i == 0 ? "" : i + 1 == cached.size() ? " and " : ", ") + methodName(cached[i]);
+        }
+        ss << "// InvalidateCache clears the cached " << (cached.size() > 1 ? "results" : "result") << " of " << names
+           << ", so\n";
+        ss << "// " << (cached.size() > 1 ? "their next calls read" : "its next call reads") << " from C++.\n";
+        ss << "func (" << recv << " *" << type_name << ") InvalidateCache() {\n";
+        for (const auto& method : cached) {
+            ss << "\t" << recv << "." << cacheField(method) << ".clear()\n";
+        }
+        ss << "}\n\n";
+    }
+    if (invalidates && !rule->second.factory.empty()) {
+        ss << generateReconnect(cls) << "\n";
+    }
+    if (!cls.iterator_element.cpp_type.empty()) {
+        ss << generateIteration(cls) << "\n";
+    }
+    if (!cls.pool_reset.empty()) {
+        ss << generatePool(cls) << "\n";
+    }
+
+    return ss.str();
+}
+
+std::string GoFFIGenerator::generateReconnect(const FFIClass& cls) {
+    std::string type_name = typeName(cls.name);
+    std::string recv = receiverName(type_name);
+    FFIFunction factory = reconnectFactory(cls, options_.handle_invalidation.at(cls.name).factory);
+    std::string qualified = qualifiedName(factory);
+    std::stringstream ss;
+
+    bool deletes = options_.handle_invalidation.at(cls.name).delete_invalidated;
+    ss << "// Reconnect replaces an invalidated C++ handle with one from " << qualified << ",\n";
+    ss << "// keeping " << recv << " itself. It waits for calls in flight and does nothing while\n";
+    if (deletes) {
+        ss << "// the handle is valid; the old handle is deleted once the new one is made.\n";
+    } else {
+        ss << "// the handle is valid; the old handle is dropped without calling C++, which\n";
+        ss << "// leaks the C++ object.\n";
+    }
+    ss << "func (" << recv << " *" << type_name << ") Reconnect" << goParameterList(factory) << " error {\n";
+    ss << "\t" << recv << ".handle.Lock()\n";
+    ss << "\tdefer " << recv << ".handle.Unlock()\n";
+    ss << "\tif !" << recv << ".invalid.Load() {\n";
+    ss << "\t\treturn nil\n";
+    ss << "\t}\n";
+    std::stringstream prelude;
+    std::string call = marshalCall(factory, "", prelude);
+    ss << prelude.str();
+    ss << "\tptr := " << call << "\n";
+    if (returnsException(factory)) {
+        ss << "\tif err := exceptionResult(\"" << qualified << "\", exception); err != nil {\n";
+        ss << "\t\treturn err\n";
+        ss << "\t}\n";
+    } else if (CWrapperGenerator(options_).catchesExceptions(factory)) {
+        ss << "\tpanicOnException(\"" << qualified << "\", exception)\n";
+    }
+    ss << "\tif ptr == nil {\n";
+    ss << "\t\treturn errors.New(\"" << qualified << " returned no handle\")\n";
+    ss << "\t}\n";
+    if (deletes) {
+        FFIFunction dtor;
+        dtor.class_name = cls.name;
+        dtor.c_name = cls.symbol_stem.empty() ? "" : cls.symbol_stem + "_delete";
+        dtor.is_destructor = true;
+        std::string borrowed = lendsHandles(cls) ? " && !" + recv + ".borrowed" : "";
+        ss << "\tif " << recv << ".ptr != nil" << borrowed << " {\n";
+        ss << "\t\tC." << CWrapperGenerator::shimName(dtor) << "(" << recv << ".ptr)\n";
+        ss << "\t}\n";
+    }
+    ss << "\t" << recv << ".ptr = ptr\n";
+    if (cls.devirtualize) {
+        ss << "\t" << recv << ".exact = false\n";
+    }
+    ss << "\t" << recv << ".invalid.Store(false)\n";
+    ss << "\treturn nil\n";
+    ss << "}\n";
+
+    return ss.str();
+}
+
+std::string GoFFIGenerator::generatePayload(const FFIClass& cls, const FFIFunction& method) {
+    static const std::regex literal(R"(-?(?:0[xX][0-9a-fA-F]+|\d+))");
+    const TaggedPayload& payload = *taggedPayload(method);
+    std::string rule = qualifiedName(method);
+    std::string type_name = typeName(cls.name);
+    std::string recv = receiverName(type_name);
+    std::string table = identifier("payload types " + rule,
+                                   goParamName(type_name) + goName(method.name) + "Types");
+    FFIFunction tag = payloadMember(cls, rule, payload.tag);
+    std::string tag_type = goReturnType(tag);
+    std::string tag_enum = tag.returns_enum ? tag.return_type : "";
+    auto ffi_enum = std::find_if(enums_.begin(), enums_.end(),
+                                 [&tag_enum](const FFIEnum& e) { return e.name == tag_enum; });
+    std::stringstream ss;
+
+    // Entries for enumerators in declaration order, then integer tags
+    std::vector<std::tuple<size_t, std::string, std::string>> entries;
+    size_t width = 0;
+    for (const auto& type : payload.types) {
+        std::string value = type.first;
+        std::string key;
+        size_t order = ffi_enum == enums_.end() ? 0 : ffi_enum->enumerators.size();
+        if (ffi_enum != enums_.end()) {
+            std::string scope = ffi_enum->name + "::";
+            value = value.compare(0, scope.size(), scope) == 0 ? value.substr(scope.size()) : value;
+            for (size_t i = 0; i < ffi_enum->enumerators.size(); ++i) {
+                if (ffi_enum->enumerators[i].first == value) {
+                    order = i;
+                    key = enumeratorName(ffi_enum->name, value);
+                }
+            }
+        }
+        if (key.empty() && std::regex_match(value, literal)) {
+            key = ffi_enum == enums_.end() ? value : tag_type + "(" + value + ")";
+        }
+        if (key.empty()) {
+            throw std::invalid_argument("tagged_payloads: " + rule + ": tag " + type.first + " is neither " +
+                                        (ffi_enum == enums_.end() ? "" : "an enumerator of " + tag_enum + " nor ") +
+                                        "an integer");
+        }
+
+        // Values are copied out, handles borrowed from the object
+        std::string go_type = enumGoType(type.second);
+        go_type = go_type.empty() ? scalarPayloadType(type.second) : go_type;
+        std::string entry;
+        if (mirrors_.count(type.second)) {
+            std::string mirror = typeName(type.second);
+            entry = "{unsafe.Sizeof(" + mirror + "{}), func(p unsafe.Pointer) any { return *(*" + mirror + ")(p) }}";
+        } else if (!go_type.empty()) {
+            std::string zero = go_type == "bool" ? "false" : go_type + "(0)";
+            entry = "{unsafe.Sizeof(" + zero + "), func(p unsafe.Pointer) any { return *(*" + go_type + ")(p) }}";
+        } else {
+            entry = "{0, func(p unsafe.Pointer) any { return &" + typeName(type.second) +
+                    "{ptr: p, borrowed: true} }}";
+        }
+        entries.emplace_back(order, key, entry);
+        width = std::max(width, key.size());
+    }
+    std::stable_sort(entries.begin(), entries.end(), [](const auto& a, const auto& b) {
+        return std::get<0>(a) < std::get<0>(b);
+    });
+
+    ss << "// " << table << " holds the type " << rule << " points to for each\n";
+    ss << "// " << qualifiedName(tag) << " tag that has one.\n";
+    ss << "var " << table << " = map[" << tag_type << "]payloadType{\n";
+    for (const auto& entry : entries) {
+        const std::string& key = std::get<1>(entry);
+        ss << "\t" << key << ":" << std::string(width - key.size() + 1, ' ') << std::get<2>(entry) << ",\n";
+    }
+    ss << "}\n\n";
+
+    std::string tag_call = recv + "." + methodName(tag) + "()";
+    std::string go_name = methodName(method);
+    ss << "// " << go_name << " returns what " << rule << " points to as the type " << table << "\n";
+    ss << "// gives its tag: a copy of a struct or scalar, or a handle borrowed from " << recv << ".\n";
+    ss << "// A tag with no type returns a RawPayload and ErrUnknownPayloadTag";
+    if (payload.size.empty()) {
+        ss << ", and a nil\n// payload nil.\n";
+    } else {
+        ss << ", a payload\n";
+        ss << "// smaller than its type a RawPayload and ErrPayloadTooSmall, and a nil payload nil.\n";
+    }
+    ss << "func (" << recv << " *" << type_name << ") " << go_name << "() (any, error) {\n";
+    ss << "\tptr := " << recv << "." << rawPayloadName(method) << "()\n";
+    ss << "\tif ptr == nil {\n";
+    ss << "\t\treturn nil, nil\n";
+    ss << "\t}\n";
+    ss << "\ttag := " << tag_call << "\n";
+    if (payload.size.empty()) {
+        ss << "\tpayload, ok := " << table << "[tag]\n";
+        ss << "\tif !ok {\n";
+        ss << "\t\treturn RawPayload{Tag: int64(tag)}, ErrUnknownPayloadTag\n";
+        ss << "\t}\n";
+    } else {
+        ss << "\tsize := uintptr(" << recv << "." << methodName(payloadMember(cls, rule, payload.size)) << "())\n";
+        ss << "\tpayload, ok := " << table << "[tag]\n";
+        ss << "\tif !ok || size < payload.size {\n";
+        ss << "\t\traw := RawPayload{Tag: int64(tag), Bytes: C.GoBytes(ptr, C.int(size))}\n";
+        ss << "\t\tif !ok {\n";
+        ss << "\t\t\treturn raw, ErrUnknownPayloadTag\n";
+        ss << "\t\t}\n";
+        ss << "\t\treturn raw, ErrPayloadTooSmall\n";
+        ss << "\t}\n";
+    }
+    ss << "\treturn payload.decode(ptr), nil\n";
+    ss << "}\n";
+
+    return ss.str();
+}
+
+std::string GoFFIGenerator::generateIteration(const FFIClass& cls) {
+    std::string type_name = typeName(cls.name);
+    std::string recv = receiverName(type_name);
+    const FFIParameter& element = cls.iterator_element;
+    bool wrapped = element.c_type == "void*";
+    std::string element_type = wrapped ? "*" + typeName(element.cpp_type)
+                             : element.is_enum && !enumGoType(element.cpp_type).empty() ? enumGoType(element.cpp_type)
+                             : goType(element.c_type);
+    std::string iter = "C." + (cls.symbol_stem.empty() ? cls.name : cls.symbol_stem) + "_iter_";
+    std::string get = iter + "get(it)";
+    std::stringstream ss;
+
+    ss << "// Range calls yield with each element of " << recv << " from begin() to end(), stopping\n";
+    ss << "// early if yield returns false; from Go 1.23, for v := range " << recv << ".Range\n";
+    ss << "// works too. " << recv << " must not be modified while Range runs";
+    if (options_.thread_safe) {
+        ss << ", and as it\n";
+        ss << "// stays locked, yield must not call its methods";
+    }
+    ss << ".\n";
+    if (wrapped) {
+        ss << "// Each element is a copy; Delete it when done.\n";
+    }
+    ss << "func (" << recv << " *" << type_name << ") Range(yield func(" << element_type << ") bool) {\n";
+    if (options_.handle_invalidation.count(cls.name)) {
+        ss << "\t" << recv << ".handle.RLock()\n";
+        ss << "\tdefer " << recv << ".handle.RUnlock()\n";
+        ss << "\tif " << recv << ".invalid.Load() {\n";
+        ss << "\t\tpanic(ErrHandleInvalidated)\n";
+        ss << "\t}\n";
+    }
+    if (options_.thread_safe) {
+        ss << "\t" << recv << ".mu.Lock()\n";
+        ss << "\tdefer " << recv << ".mu.Unlock()\n";
+    }
+    ss << "\tit := " << iter << "begin(" << recv << ".ptr)\n";
+    ss << "\tdefer " << iter << "delete(it)\n";
+    ss << "\tfor ; " << iter << "has_next(it); " << iter << "next(it) {\n";
+    ss << "\t\tif !yield(" << (wrapped ? "&" + typeName(element.cpp_type) + "{ptr: " + get + "}"
+                                      : element_type + "(" + get + ")") << ") {\n";
+    ss << "\t\t\treturn\n";
+    ss << "\t\t}\n";
+    ss << "\t}\n";
+    ss << "}\n\n";
+
+    ss << "// Elements returns the elements of " << recv << " from begin() to end().\n";
+    ss << "func (" << recv << " *" << type_name << ") Elements() []" << element_type << " {\n";
+    ss << "\tvar elements []" << element_type << "\n";
+    ss << "\t" << recv << ".Range(func(v " << element_type << ") bool {\n";
+    ss << "\t\telements = append(elements, v)\n";
+    ss << "\t\treturn true\n";
+    ss << "\t})\n";
+    ss << "\treturn elements\n";
+    ss << "}\n";
+
+    return ss.str();
+}
+
+std::string GoFFIGenerator::visitorElementType(const FFIFunction& func) const {
+    const FFIParameter& element = func.visitor.element;
+    if (element.c_type == "void*") {
+        return "*" + typeName(pointeeName(element.cpp_type));
+    }
+    std::string enum_type = element.is_enum ? enumGoType(element.cpp_type) : "";
+    return enum_type.empty() ? goType(element.c_type) : enum_type;
+}
+
+std::string GoFFIGenerator::visitorDoc(const FFIFunction& func, const std::string& recv) const {
+    if (func.visitor.callback.empty()) {
+        return "";
+    }
+    std::string callback;
+    for (size_t i = 0; i < func.parameters.size(); ++i) {
+        if (isVisitorCallback(func, func.parameters[i])) {
+            callback = argumentName(func, i);
+        }
+    }
+    std::stringstream ss;
+    if (!func.visitor.policy.empty()) {
+        ss << "// " << callback << " stands in for the " << func.visitor.policy << " that " << func.name
+           << " is instantiated with; its results go back to C++.\n";
+    } else if (!func.visitor.result.cpp_type.empty()) {
+        ss << "// " << callback << " is called with each element, and C++ acts on the "
+           << enumGoType(func.visitor.result.cpp_type) << " it returns.\n";
+    } else if (func.visitor.stops) {
+        ss << "// " << callback << " is called with each element and returns false to stop the visit.\n";
+    } else {
+        ss << "// " << callback << " is called with each element; once it returns false it is not called\n";
+        ss << "// again, though C++ still visits the remaining elements.\n";
+    }
+    if (func.visitor.element.c_type == "void*") {
+        ss << "// The " << visitorElementType(func) << " it gets is borrowed from C++ and valid only while "
+           << callback << " runs.\n";
+    }
+    if (options_.thread_safe && !recv.empty()) {
+        ss << "// " << recv << " is unlocked while " << callback << " runs, so it may call the methods of " << recv
+           << ",\n// and so may other goroutines between two elements.\n";
+    }
+    if (!func.visitor.panic_result.empty() || !func.visitor.result.cpp_type.empty()) {
+        ss << "// If " << callback << " panics, C++ gets " << visitorFallback(func)
+           << " from then on, and the panic is\n// raised again once C++ has returned.\n";
+    }
+    return ss.str();
+}
+
+std::string GoFFIGenerator::visitorFallback(const FFIFunction& func) const {
+    const std::string& value = func.visitor.panic_result;
+    if (func.visitor.result.cpp_type.empty()) {
+        return value.empty() ? "false" : value;
+    }
+    std::string result = pointeeName(func.visitor.result.cpp_type);
+    for (const auto& ffi_enum : enums_) {
+        if (ffi_enum.name == result && !ffi_enum.enumerators.empty()) {
+            return enumeratorName(result, value.empty() ? ffi_enum.enumerators[0].first : value);
+        }
+    }
+    return "false";
+}
+
+std::string GoFFIGenerator::collectorName(const FFIFunction& func) const {
+    return identifier("collect " + functionEntity(func), goName(func.visitor.collect));
+}
+
+std::string GoFFIGenerator::generateCollector(const FFIFunction& func, const std::string& go_name,
+                                             const std::string& receiver, const std::string& callee) {
+    // A collector returns the slice, plus the visitor's error if it has one
+    std::vector<std::string> results = goResultTypes(func);
+    if (func.visitor.collect.empty() || results.size() > 1 || (!results.empty() && results[0] != "error")) {
+        return "";
+    }
+
+    // It takes the visitor's parameters but the func, which it supplies
+    std::string element_type = visitorElementType(func);
+    std::string callback;
+    for (size_t i = 0; i < func.parameters.size(); ++i) {
+        if (isVisitorCallback(func, func.parameters[i])) {
+            callback = argumentName(func, i);
+        }
+    }
+    std::string parameters = goParameterList(func);
+    std::string func_param = callback + " func(" + element_type + ") bool";
+    size_t pos = parameters.find(func_param);
+    if (parameters.compare(pos + func_param.size(), 2, ", ") == 0) {
+        parameters.erase(pos, func_param.size() + 2);
+    } else {
+        parameters.erase(pos == 1 ? pos : pos - 2, func_param.size() + (pos == 1 ? 0 : 2));
+    }
+
+    std::string value = "element";
+    std::string copy_doc;
+    if (CWrapperGenerator::copiesVisitorElements(func)) {
+        std::string element_class = pointeeName(func.visitor.element.cpp_type);
+        value = "&" + element_type.substr(1) + "{ptr: C." + CWrapperGenerator::visitorCopyName(func) + "(element.ptr)}";
+        copy_doc = func.visitor.clone.empty() ? " Each is a copy;"
+                                              : " Each is a copy made by " + element_class + "::" + func.visitor.clone + ";";
+        copy_doc += " Delete it when done.";
+    }
+
+    std::string closure = "func(element " + element_type + ") bool {\n"
+                          "\t\tcollected = append(collected, " + value + ")\n"
+                          "\t\treturn true\n"
+                          "\t}";
+    std::string arguments;
+    std::stringstream names(goArgumentNames(func));
+    for (std::string name; std::getline(names, name, ',');) {
+        name = name[0] == ' ' ? name.substr(1) : name;
+        arguments += (arguments.empty() ? "" : ", ") + (name == callback ? closure : name);
+    }
+
+    std::string name = collectorName(func);
+    std::stringstream ss;
+    ss << "// " << name << " collects the elements " << go_name << " visits into a slice.\n";
+    if (!copy_doc.empty()) {
+        ss << "//" << copy_doc << "\n";
+    }
+    ss << "func " << receiver << name << parameters << " ";
+    if (results.empty()) {
+        ss << "[]" << element_type << " {\n";
+    } else {
+        ss << "([]" << element_type << ", error) {\n";
+    }
+    ss << "\tvar collected []" << element_type << "\n";
+    ss << "\t" << (results.empty() ? "" : "err := ") << callee << go_name << "(" << arguments << ")\n";
+    ss << "\treturn collected" << (results.empty() ? "" : ", err") << "\n";
+    ss << "}\n";
+    return ss.str();
+}
+
+std::string GoFFIGenerator::generatePoolBenchmarks(
+    const std::vector<FFIClass>& classes,
+    const std::string& library_name
+) {
+    std::stringstream body;
+    resolveNames({}, LayoutEngine::resolveMirrors(classes, options_));
+
+    for (const auto& cls : classes) {
+        int ctor_index = defaultConstructorIndex(cls);
+        if (cls.pool_reset.empty() || cls.is_mirrored || ctor_index < 0 || !configuredFeature(cls.feature).empty() ||
+            !cls.targets.empty()) {
+            continue;
+        }
+
+        std::string type_name = typeName(cls.name);
+        std::string ctor = constructorName(cls, ctor_index);
+
+        body << "\n";
+        body << "// Benchmark" << type_name << "NewDelete allocates and frees a C++ " << cls.name
+             << " per iteration.\n";
+        body << "func Benchmark" << type_name << "NewDelete(b *testing.B) {\n";
+        body << "\tfor i := 0; i < b.N; i++ {\n";
+        body << "\t\tobj := " << ctor << "()\n";
+        body << "\t\tobj.Delete()\n";
+        body << "\t}\n";
+        body << "}\n\n";
+
+        body << "// Benchmark" << type_name << "Pool recycles " << cls.name << " objects through "
+             << poolName(cls) << ".\n";
+        body << "func Benchmark" << type_name << "Pool(b *testing.B) {\n";
+        std::string pool_constructor = identifier("pool constructor " + cls.name, "New" + poolName(cls));
+        body << "\tpool := " << pool_constructor << "(" << ctor << ")\n";
+        body << "\tfor i := 0; i < b.N; i++ {\n";
+        body << "\t\tpool.Put(pool.Get())\n";
+        body << "\t}\n";
+        body << "}\n";
+    }
+
+    if (body.str().empty()) {
+        return "";
+    }
+
+    std::stringstream ss;
+    ss << "// Code generated by Hybrid Transpiler. DO NOT EDIT.\n\n";
+    ss << "package " << packageName(options_, library_name) << "\n\n";
+    ss << "import \"testing\"\n";
+    ss << body.str();
+
+    return ss.str();
+}
+
+std::string GoFFIGenerator::generateDevirtualizeBenchmarks(
+    const std::vector<FFIClass>& classes,
+    const std::string& library_name
+) {
+    std::stringstream body;
+    resolveNames({}, LayoutEngine::resolveMirrors(classes, options_));
+
+    for (const auto& cls : classes) {
+        int ctor_index = defaultConstructorIndex(cls);
+        if (!cls.devirtualize || cls.is_mirrored || ctor_index < 0 || !configuredFeature(cls.feature).empty() ||
+            !cls.targets.empty()) {
+            continue;
+        }
+
+        std::string type_name = typeName(cls.name);
+        std::string ctor = constructorName(cls, ctor_index);
+        for (const auto& direct : CWrapperGenerator::directShims(cls)) {
+            if (!direct.parameters.empty() || !configuredFeature(direct.feature).empty() || direct.signal_unsafe ||
+                direct.main_thread_only || !direct.targets.empty()) {
+                continue;
+            }
+            // Named after the method the direct shim bypasses the vtable of
+            std::string method_name = goName(direct.name);
+            for (const auto& shim : CWrapperGenerator::shimFunctions(cls)) {
+                if (CWrapperGenerator::shimName(shim) + "_direct" == CWrapperGenerator::shimName(direct)) {
+                    method_name = methodName(shim);
+                }
+            }
+            for (bool exact : {false, true}) {
+                std::string benchmark = "Benchmark" + type_name + method_name + (exact ? "Direct" : "Virtual");
+                body << "\n";
+                body << "// " << benchmark << " calls " << cls.name << "::" << direct.name
+                     << (exact ? " without virtual dispatch.\n" : " through the vtable.\n");
+                body << "func " << benchmark << "(b *testing.B) {\n";
+                body << "\tobj := " << ctor << "()\n";
+                body << "\tdefer obj.Delete()\n";
+                body << "\tobj.exact = " << (exact ? "true" : "false") << "\n";
+                body << "\tfor i := 0; i < b.N; i++ {\n";
+                body << "\t\tobj." << method_name << "()\n";
+                body << "\t}\n";
+                body << "}\n";
+            }
+        }
+    }
+
+    if (body.str().empty()) {
+        return "";
+    }
+
+    std::stringstream ss;
+    ss << "// Code generated by Hybrid Transpiler. DO NOT EDIT.\n\n";
+    ss << "package " << packageName(options_, library_name) << "\n\n";
+    ss << "import \"testing\"\n";
+    ss << body.str();
+
+    return ss.str();
+}
+
+std::string GoFFIGenerator::generateSmallStringBenchmarks(
+    const std::vector<FFIFunction>& functions,
+    const std::vector<FFIClass>& classes,
+    const std::string& library_name
+) {
+    if (!bindsSmallStrings(functions, LayoutEngine::resolveMirrors(classes, options_))) {
+        return "";
+    }
+
+    std::stringstream ss;
+    ss << "// Code generated by Hybrid Transpiler. DO NOT EDIT.\n\n";
+    ss << "package " << packageName(options_, library_name) << "\n\n";
+    ss << "import (\n";
+    ss << "\t\"strings\"\n";
+    ss << "\t\"testing\"\n";
+    ss << ")\n\n";
+    ss << "// benchmarkSmallString is the longest string that still takes the stack buffer.\n";
+    ss << "var benchmarkSmallString = strings.Repeat(\"x\", smallStringSize)\n\n";
+    for (bool stack : {true, false}) {
+        std::string benchmark = stack ? "BenchmarkSmallStringStack" : "BenchmarkSmallStringHeap";
+        ss << "// " << benchmark << " converts benchmarkSmallString "
+           << (stack ? "in a stack buffer.\n" : "on the C heap, as C.CString does.\n");
+        ss << "func " << benchmark << "(b *testing.B) {\n";
+        ss << "\tb.ReportAllocs()\n";
+        if (stack) {
+            ss << "\tvar buf [smallStringSize + 1]byte\n";
+        }
+        ss << "\tfor i := 0; i < b.N; i++ {\n";
+        ss << "\t\t_, owned := cString(benchmarkSmallString, " << (stack ? "buf[:]" : "nil") << ")\n";
+        ss << "\t\tfreeCString(owned)\n";
+        ss << "\t}\n";
+        ss << "}\n";
+        if (stack) {
+            ss << "\n";
+        }
+    }
+    return ss.str();
+}
+
+std::string GoFFIGenerator::generateScratchArenaBenchmarks(
+    const std::vector<FFIFunction>& functions,
+    const std::vector<FFIClass>& classes,
+    const std::string& library_name
+) {
+    if (!bindsScratchArena(functions, LayoutEngine::resolveMirrors(classes, options_))) {
+        return "";
+    }
+
+    std::stringstream ss;
+    ss << "// Code generated by Hybrid Transpiler. DO NOT EDIT.\n\n";
+    ss << "package " << packageName(options_, library_name) << "\n\n";
+    ss << "import \"testing\"\n\n";
+    ss << "// benchmarkScratchStrings are the string arguments of one call.\n";
+    ss << "var benchmarkScratchStrings = []string{\"/var/lib/hybrid\", \"transpiler.conf\", \"utf-8\"}\n\n";
+    for (bool shared : {true, false}) {
+        std::string benchmark = shared ? "BenchmarkScratchArena" : "BenchmarkScratchPerString";
+        ss << "// " << benchmark << " copies benchmarkScratchStrings "
+           << (shared ? "into one arena, as the wrappers do.\n" : "into an allocation each, as C.CString does.\n");
+        ss << "func " << benchmark << "(b *testing.B) {\n";
+        ss << "\tb.ReportAllocs()\n";
+        ss << "\tfor i := 0; i < b.N; i++ {\n";
+        if (shared) {
+            ss << "\t\tsize := 0\n";
+            ss << "\t\tfor _, s := range benchmarkScratchStrings {\n";
+            ss << "\t\t\tsize += len(s) + 1\n";
+            ss << "\t\t}\n";
+            ss << "\t\tscratch := newScratchArena(size)\n";
+            ss << "\t\tfor _, s := range benchmarkScratchStrings {\n";
+            ss << "\t\t\tscratch.copyString(s)\n";
+            ss << "\t\t}\n";
+            ss << "\t\tscratch.free()\n";
+        } else {
+            ss << "\t\tfor _, s := range benchmarkScratchStrings {\n";
+            ss << "\t\t\tscratch := newScratchArena(len(s) + 1)\n";
+            ss << "\t\t\tscratch.copyString(s)\n";
+            ss << "\t\t\tscratch.free()\n";
+            ss << "\t\t}\n";
+        }
+        ss << "\t}\n";
+        ss << "\tb.ReportMetric(" << (shared ? "1" : "float64(len(benchmarkScratchStrings))")
+           << ", \"C-allocs/op\")\n";
+        ss << "}\n";
+        if (shared) {
+            ss << "\n";
+        }
+    }
+    return ss.str();
+}
+
+std::string GoFFIGenerator::generateMappings(
+    const std::vector<FFIFunction>& functions,
+    const std::vector<FFIClass>& all_classes,
+    const std::string& library_name,
+    const std::vector<FFIEnum>& enums,
+    const std::vector<FFIConstant>& constants
+) {
+    std::vector<FFIClass> classes = LayoutEngine::resolveMirrors(all_classes, options_);
+    enums_ = enums;
+    constants_ = constants;
+    mirrors_ = mirroredNames(classes);
+    logs_ = installsLogger(functions);
+    resolveNames(functions, classes);
+    CWrapperGenerator c_generator(options_);
+    bool excluded = options_.signal_unsafe_policy == SignalUnsafePolicy::Exclude;
+
+    auto quoted = [](const std::string& text) { return "\"" + text + "\""; };
+    auto entry = [&](const FFIFunction& func, const std::string& kind, const std::string& go_name) {
+        std::vector<std::string> fields = {"Kind: " + quoted(kind)};
+        // A free function bound as a method keeps its own C++ name
+        fields.push_back("Cpp: " + quoted(func.visitor.free_receiver.empty() ? qualifiedName(func) : func.name));
+        fields.push_back("C: " + quoted(CWrapperGenerator::shimName(func)));
+        fields.push_back("Go: " + quoted(go_name));
+
+        std::string params;
+        for (size_t i = 0; i < func.parameters.size(); ++i) {
+            const auto& param = func.parameters[i];
+            bool error_code = CWrapperGenerator::hasErrorCodeOut(func) && i + 1 == func.parameters.size();
+            bool logger_context = !func.logger.context.empty() && param.name == func.logger.context;
+            bool hidden = error_code || isVisitorContext(func, param) || logger_context ||
+                          direction(param) == ParamDirection::Out;
+            std::string go_type = hidden ? "" : goParameterType(func, param);
+            std::string way = error_code || direction(param) == ParamDirection::Out ? "out"
+                            : direction(param) == ParamDirection::InOut ? "inout"
+                                                                        : "in";
+            // Go keeps what it passes; C++ sees strings, handles, slices and funcs only during the
+            // call, though an installed logger stays in use after it
+            bool borrowed = !hidden && func.logger.callback.empty() &&
+                            (go_type == "string" || go_type == "unsafe.Pointer" || go_type[0] == '*' ||
+                             go_type.compare(0, 2, "[]") == 0 || go_type.compare(0, 5, "func(") == 0);
+            params += std::string(params.empty() ? "" : ", ") + "{Name: " + quoted(param.name) +
+                      ", Cpp: " + quoted(param.cpp_type) + ", Go: " + quoted(go_type) +
+                      ", Direction: " + quoted(way) + (borrowed ? ", Ownership: \"borrowed\"" : "") + "}";
+        }
+        if (!params.empty()) {
+            fields.push_back("Params: []ParamMapping{" + params + "}");
+        }
+
+        std::vector<std::string> results = goResultTypes(func);
+        if (func.is_constructor) {
+            results = {"*" + typeName(func.class_name)};
+        }
+        std::string result_type = func.is_constructor ? "" : func.return_type == "void" ? "" : func.return_type;
+        if (!result_type.empty()) {
+            fields.push_back("Result: " + quoted(result_type));
+        }
+        if (!results.empty()) {
+            std::string list;
+            for (const auto& result : results) {
+                list += std::string(list.empty() ? "" : ", ") + quoted(result);
+            }
+            fields.push_back("GoResults: []string{" + list + "}");
+        }
+
+        std::string go_result = func.is_constructor ? "*" : goReturnType(func);
+        std::string ownership = func.is_constructor || (!func.factory.empty() && !func.borrowed) ? "caller"
+                              : !func.factory.empty() || go_result == "unsafe.Pointer"        ? "cpp"
+                              : go_result == "string" || go_result.compare(0, 1, "[") == 0    ? "copied"
+                                                                                               : "";
+        if (go_result.empty() || func.is_destructor) {
+            ownership = "";
+        }
+        if (!ownership.empty()) {
+            fields.push_back("Ownership: " + quoted(ownership));
+        }
+
+        std::string errors = CWrapperGenerator::hasErrorCodeOut(func)  ? "error_code"
+                           : func.returns_status                       ? "status"
+                           : CWrapperGenerator::expectedTypes(func)    ? "expected"
+                           : returnsException(func)                    ? "exception"
+                           : returnsFactoryError(func)                 ? "factory"
+                           : returnsValidationError(func)              ? "validation"
+                           : c_generator.catchesExceptions(func)       ? "panic"
+                                                                       : "none";
+        fields.push_back("Errors: " + quoted(errors));
+        bool locked = options_.thread_safe && !func.class_name.empty() && !func.is_static && !func.is_constructor;
+        fields.push_back("Threading: " + quoted(func.main_thread_only ? "main_thread"
+                                                : locked              ? "mutex"
+                                                                      : "unsynchronized"));
+        std::string tag = func.signal_unsafe ? options_.signal_unsafe_tag
+                        : !configuredFeature(func.feature).empty() ? featureTag(func.feature)
+                                                                   : targetConstraint(func.targets);
+        if (!tag.empty()) {
+            fields.push_back("BuildTag: " + quoted(tag));
+        }
+
+        std::string line = "\t{";
+        for (size_t i = 0; i < fields.size(); ++i) {
+            line += (i > 0 ? ", " : "") + fields[i];
+        }
+        return line + "},\n";
+    };
+
+    std::stringstream table;
+    for (const auto& cls : classes) {
+        std::string type_name = typeName(cls.name);
+        size_t ctor_index = 0;
+        for (const auto& shim : CWrapperGenerator::shimFunctions(cls)) {
+            size_t index = shim.is_constructor ? ctor_index++ : 0;
+            if ((shim.signal_unsafe && excluded) || !shim.iteration.empty()) {
+                continue;
+            }
+            if (shim.is_constructor) {
+                table << entry(shim, "constructor", constructorName(cls, index));
+            } else if (shim.is_destructor) {
+                table << entry(shim, "destructor", type_name + ".Delete");
+            } else if (shim.is_static) {
+                table << entry(shim, "static", wrapperName(shim));
+            } else {
+                std::string method_name = taggedPayload(shim) ? rawPayloadName(shim) : methodName(shim);
+                table << entry(shim, "method", type_name + "." + method_name);
+            }
+        }
+    }
+    for (const auto& func : CWrapperGenerator::bindableFunctions(functions)) {
+        if (!(func.signal_unsafe && excluded)) {
+            table << entry(func, "function", wrapperName(func));
+        }
+    }
+
+    std::stringstream ss;
+    ss << "// Code generated by Hybrid Transpiler. DO NOT EDIT.\n\n";
+    ss << "package " << packageName(options_, library_name) << "\n\n";
+    ss << "// Mapping describes how the package binds one C++ function. It comes from the\n";
+    ss << "// same analysis as the bindings, so tests can check the package against it.\n";
+    ss << "type Mapping struct {\n";
+    ss << "\tKind      string         // function, method, static, constructor or destructor\n";
+    ss << "\tCpp       string         // C++ name, e.g. Tree::walk\n";
+    ss << "\tC         string         // C shim the Go binding calls\n";
+    ss << "\tGo        string         // Go name; Type.Method for methods\n";
+    ss << "\tParams    []ParamMapping // C++ parameters in order\n";
+    ss << "\tResult    string         // C++ result type (\"\" for void and constructors)\n";
+    ss << "\tGoResults []string       // Go result types, error included\n";
+    ss << "\tOwnership string         // Of the result: caller (calls Delete), cpp (keeps it), copied or \"\" for values\n";
+    ss << "\tErrors    string         // none, error_code, status, expected, exception (an error), factory (nullptr), validation or panic\n";
+    ss << "\tThreading string         // unsynchronized, mutex (held for the call) or main_thread\n";
+    ss << "\tBuildTag  string         // Tag or constraint the binding is built only with (\"\" if always)\n";
+    ss << "}\n\n";
+    ss << "// ParamMapping describes one C++ parameter of a Mapping.\n";
+    ss << "type ParamMapping struct {\n";
+    ss << "\tName      string // C++ name\n";
+    ss << "\tCpp       string // C++ type\n";
+    ss << "\tGo        string // Go type; \"\" if Go does not pass it\n";
+    ss << "\tDirection string // in, out (a Go result) or inout\n";
+    ss << "\tOwnership string // borrowed if C++ may use it only during the call, \"\" for values\n";
+    ss << "}\n\n";
+    ss << "var mappings = []Mapping{\n";
+    ss << table.str();
+    ss << "}\n\n";
+    ss << "// Mappings returns a Mapping per bound C++ function, in binding order.\n";
+    ss << "func Mappings() []Mapping {\n";
+    ss << "\treturn append([]Mapping(nil), mappings...)\n";
+    ss << "}\n";
+
+    return ss.str();
+}
+
+std::string GoFFIGenerator::enumGoType(const std::string& cpp_type) const {
+    std::string base = cpp_type;
+    if (base.compare(0, 6, "const ") == 0) {
+        base = base.substr(6);
+    }
+    base = base.substr(0, base.find_last_not_of(" &") + 1);
+
+    for (const auto& ffi_enum : enums_) {
+        if (ffi_enum.name == base) {
+            return typeName(ffi_enum.name);
+        }
+    }
+    return "";
+}
+
+std::string GoFFIGenerator::enumNamer(const FFIEnum& ffi_enum, const std::vector<FFIFunction>& functions) {
+    if (ffi_enum.name_function.empty()) {
+        return "";
+    }
+    std::string annotation = ffi_enum.name + ": // @go:enum-names names " + ffi_enum.name_function;
+    bool declared = false;
+    for (const auto& func : CWrapperGenerator::bindableFunctions(functions)) {
+        if (func.name != ffi_enum.name_function) {
+            continue;
+        }
+        declared = true;
+        if (func.parameters.size() == 1 && func.parameters[0].is_enum &&
+            enumGoType(func.parameters[0].cpp_type) == typeName(ffi_enum.name) &&
+            goResultTypes(func) == std::vector<std::string>{"string"}) {
+            // String() lives next to the enum, which no build tag excludes
+            if (func.signal_unsafe || !configuredFeature(func.feature).empty() || !func.targets.empty()) {
+                throw std::invalid_argument(annotation + ", which is only bound behind a build tag");
+            }
+            return wrapperName(func);
+        }
+    }
+    throw std::invalid_argument(annotation + (declared ? ", which does not map a " + ffi_enum.name +
+                                                             " to a const char*"
+                                                       : ", which is not a bound free function"));
+}
+
+std::string GoFFIGenerator::generateEnum(const FFIEnum& ffi_enum, const std::vector<FFIFunction>& functions) {
+    std::string type_name = typeName(ffi_enum.name);
+    std::string namer = enumNamer(ffi_enum, functions);
+    std::stringstream ss;
+
+    if (ffi_enum.doc.empty()) {
+        ss << "// " << type_name << " mirrors the C++ enum " << ffi_enum.name << ".\n";
+    } else {
+        std::istringstream doc(ffi_enum.doc);
+        std::string line;
+        while (std::getline(doc, line)) {
+            ss << (line.empty() ? "//" : "// " + line) << "\n";
+        }
+    }
+    ss << "type " << type_name << " " << goType(ffi_enum.underlying_type) << "\n\n";
+
+    // Implicit values count up from the previous enumerator, as in C++
+    std::vector<std::string> names;
+    size_t width = 0;
+    for (const auto& enumerator : ffi_enum.enumerators) {
+        names.push_back(enumeratorName(ffi_enum.name, enumerator.first));
+        width = std::max(width, names.back().size());
+    }
+    ss << "const (\n";
+    for (size_t i = 0; i < ffi_enum.enumerators.size(); ++i) {
+        std::string value = ffi_enum.enumerators[i].second;
+        if (value.empty()) {
+            value = i == 0 ? "0" : names[i - 1] + " + 1";
+        } else {
+            // Values may refer to earlier enumerators
+            for (size_t j = 0; j < i; ++j) {
+                value = std::regex_replace(value, std::regex("\\b" + ffi_enum.enumerators[j].first + "\\b"),
+                                           names[j]);
+            }
+        }
+        ss << "\t" << names[i] << std::string(width - names[i].size(), ' ') << " " << type_name
+           << " = " << value << "\n";
+    }
+    ss << ")\n\n";
+
+    ss << "// IsValid reports whether v is one of the declared " << type_name << " values.\n";
+    ss << "func (v " << type_name << ") IsValid() bool {\n";
+    ss << "\tfor _, known := range [...]" << type_name << "{";
+    for (size_t i = 0; i < names.size(); ++i) {
+        ss << (i > 0 ? ", " : "") << names[i];
+    }
+    ss << "} {\n";
+    ss << "\t\tif v == known {\n";
+    ss << "\t\t\treturn true\n";
+    ss << "\t\t}\n";
+    ss << "\t}\n";
+    ss << "\treturn false\n";
+    ss << "}\n";
+
+    // The C++ names stay authoritative; undeclared values never reach C++
+    if (!namer.empty()) {
+        bool is_unsigned = goType(ffi_enum.underlying_type).compare(0, 4, "uint") == 0;
+        ss << "\n";
+        ss << "// String returns the name " << ffi_enum.name_function << " gives v in C++, or " << type_name
+           << "(<value>)\n";
+        ss << "// for a value " << type_name << " does not declare.\n";
+        ss << "func (v " << type_name << ") String() string {\n";
+        ss << "\tif !v.IsValid() {\n";
+        ss << "\t\treturn \"" << type_name << "(\" + "
+           << (is_unsigned ? "strconv.FormatUint(uint64(v), 10)" : "strconv.FormatInt(int64(v), 10)")
+           << " + \")\"\n";
+        ss << "\t}\n";
+        ss << "\treturn " << namer << "(v)\n";
+        ss << "}\n";
+    }
+
+    return ss.str();
+}
+
+std::set<std::string> GoFFIGenerator::referencedConstants(const std::vector<FFIFunction>& functions,
+                                                          const std::vector<FFIClass>& classes, bool rules) const {
+    static const std::regex name(R"("(?:[^"\\]|\\.)*"|[A-Za-z_]\w*(?:::\w+)*)");
+
+    // Constants the default variants use, then the ones their values use
+    std::set<std::string> used;
+    std::vector<std::string> pending;
+    auto collect = [&](const FFIFunction& func) {
+        size_t count = func.parameters.size() - (CWrapperGenerator::hasErrorCodeOut(func) ? 1 : 0);
+        for (size_t i = firstDefault(func); i < count; ++i) {
+            pending.push_back(func.parameters[i].default_value);
+        }
+    };
+    for (const auto& cls : classes) {
+        for (const auto& shim : CWrapperGenerator::shimFunctions(cls)) {
+            collect(shim);
+        }
+    }
+    for (const auto& func : CWrapperGenerator::bindableFunctions(functions)) {
+        collect(func);
+    }
+    // Status codes invalidating a handle are compared against by name
+    for (const auto& rule : options_.handle_invalidation) {
+        for (const auto& error : rules ? rule.second.errors : std::vector<std::string>{}) {
+            pending.push_back(error);
+        }
+    }
+    while (!pending.empty()) {
+        std::string value = pending.back();
+        pending.pop_back();
+        for (auto it = std::sregex_iterator(value.begin(), value.end(), name); it != std::sregex_iterator(); ++it) {
+            auto constant = std::find_if(constants_.begin(), constants_.end(),
+                                         [&it](const FFIConstant& c) { return c.name == it->str(); });
+            if (constant != constants_.end() && used.insert(constant->name).second) {
+                pending.push_back(constant->value);
+            }
+        }
+    }
+    return used;
+}
+
+std::string GoFFIGenerator::generateConstants(const std::vector<FFIFunction>& functions,
+                                             const std::vector<FFIClass>& classes) {
+    std::set<std::string> used = referencedConstants(functions, classes);
+    if (used.empty()) {
+        return "";
+    }
+
+    std::vector<std::pair<std::string, std::string>> lines;
+    size_t width = 0;
+    for (const auto& constant : constants_) {
+        if (used.count(constant.name)) {
+            FFIParameter value;
+            value.c_type = constant.c_type;
+            value.cpp_type = constant.cpp_type;
+            value.is_enum = !enumGoType(constant.cpp_type).empty();
+            value.default_value = constant.value;
+            lines.emplace_back(goConstantName(constant.name), goDefault(value));
+            width = std::max(width, lines.back().first.size());
+        }
+    }
+
+    bool defaults = !referencedConstants(functions, classes, false).empty();
+    bool rules = false;
+    for (const auto& rule : options_.handle_invalidation) {
+        for (const auto& error : rule.second.errors) {
+            rules = rules || used.count(error);
+        }
+    }
+    std::stringstream ss;
+    ss << "// C++ constants referenced by " << (defaults ? "default arguments" : "")
+       << (defaults && rules ? " and " : "") << (rules ? "handle invalidation rules" : "") << ".\n";
+    ss << "const (\n";
+    for (const auto& line : lines) {
+        ss << "\t" << line.first << std::string(width - line.first.size(), ' ') << " = " << line.second << "\n";
+    }
+    ss << ")\n";
+    return ss.str();
+}
+
+std::string GoFFIGenerator::invalidatingStatus(const std::string& class_name) const {
+    auto rule = options_.handle_invalidation.find(class_name);
+    if (rule == options_.handle_invalidation.end()) {
+        return "";
+    }
+
+    // status is a C integer, so only untyped Go constants compare against it
+    static const std::regex literal(R"(-?(?:0[xX][0-9a-fA-F]+|\d+))");
+    std::string condition;
+    for (const auto& error : rule->second.errors) {
+        std::string value = error;
+        if (!std::regex_match(error, literal)) {
+            auto constant = std::find_if(constants_.begin(), constants_.end(),
+                                         [&error](const FFIConstant& c) { return c.name == error; });
+            bool integer = constant != constants_.end() && enumGoType(constant->cpp_type).empty() &&
+                           constant->c_type != "float" && constant->c_type != "double" &&
+                           constant->c_type != "bool" && constant->c_type != "const char*";
+            if (!integer) {
+                throw std::invalid_argument("handle_invalidation: " + error + " of " + class_name +
+                                            " is neither an integer nor an integer constant");
+            }
+            value = goConstantName(error);
+        }
+        condition += (condition.empty() ? "" : " || ") + std::string("status == ") + value;
+    }
+    return condition;
+}
+
+void GoFFIGenerator::validateHandleInvalidation(const std::vector<FFIClass>& classes) {
+    for (const auto& rule : options_.handle_invalidation) {
+        auto cls = std::find_if(classes.begin(), classes.end(), [&rule](const FFIClass& c) {
+            return c.name == rule.first && !c.is_mirrored;
+        });
+        if (cls == classes.end()) {
+            throw std::invalid_argument("handle_invalidation: " + rule.first + " is not a bound class");
+        }
+        if (rule.second.errors.empty()) {
+            throw std::invalid_argument("handle_invalidation: " + rule.first + " names no errors");
+        }
+        invalidatingStatus(rule.first);
+
+        std::vector<FFIFunction> shims = CWrapperGenerator::shimFunctions(*cls);
+        if (std::none_of(shims.begin(), shims.end(),
+                         [](const FFIFunction& f) { return f.returns_status && !f.is_static; })) {
+            throw std::invalid_argument("handle_invalidation: " + rule.first + " has no @status methods");
+        }
+        if (rule.second.factory.empty()) {
+            continue;
+        }
+        reconnectFactory(*cls, rule.second.factory);
+    }
+}
+
+std::string GoFFIGenerator::scalarPayloadType(const std::string& cpp_type) {
+    // Named by its C type, with or without std::
+    std::string c_type = cpp_type.compare(0, 5, "std::") == 0 ? cpp_type.substr(5) : cpp_type;
+    std::string go_type = goType(c_type);
+    bool scalar = go_type != "unsafe.Pointer" && go_type != "string" && go_type != "time.Time";
+    return scalar ? go_type : "";
+}
+
+const TaggedPayload* GoFFIGenerator::taggedPayload(const FFIFunction& func) const {
+    auto payload = options_.tagged_payloads.find(qualifiedName(func));
+    bool raw = func.c_return_type == "void*" || func.c_return_type == "const void*";
+    bool member = func.is_method && !func.is_static && !func.is_constructor && func.parameters.empty();
+    return payload != options_.tagged_payloads.end() && raw && member ? &payload->second : nullptr;
+}
+
+bool GoFFIGenerator::lendsHandles(const FFIClass& cls) const {
+    // Tagged payloads hand out handles the object they came from still owns
+    for (const auto& payload : options_.tagged_payloads) {
+        for (const auto& type : payload.second.types) {
+            if (type.second == cls.name && !cls.is_mirrored) {
+                return true;
+            }
+        }
+    }
+    return cls.borrowed;
+}
+
+void GoFFIGenerator::validateTaggedPayloads(const std::vector<FFIClass>& classes) {
+    auto integer = [this](const FFIFunction& func) {
+        static const std::set<std::string> integers = {"int8", "int16", "int32", "int64", "uint8", "uint16",
+                                                       "uint32", "uint64", "uint"};
+        return goResultTypes(func).size() == 1 && integers.count(goReturnType(func));
+    };
+    for (const auto& rule : options_.tagged_payloads) {
+        size_t scope = rule.first.rfind("::");
+        std::string class_name = scope == std::string::npos ? "" : rule.first.substr(0, scope);
+        auto cls = std::find_if(classes.begin(), classes.end(), [&class_name](const FFIClass& c) {
+            return c.name == class_name && !c.is_mirrored;
+        });
+        if (cls == classes.end()) {
+            throw std::invalid_argument("tagged_payloads: " + rule.first + " is not a method of a bound class");
+        }
+        FFIFunction method = payloadMember(*cls, rule.first, rule.first.substr(scope + 2));
+        if (!taggedPayload(method) || goResultTypes(method).size() != 1 || method.main_thread_only) {
+            throw std::invalid_argument("tagged_payloads: " + rule.first +
+                                        " must return void* and may not run on the main thread only");
+        }
+        if (rule.second.types.empty()) {
+            throw std::invalid_argument("tagged_payloads: " + rule.first + " names no types");
+        }
+        FFIFunction tag = payloadMember(*cls, rule.first, rule.second.tag);
+        bool enum_tag = tag.returns_enum && goResultTypes(tag).size() == 1 && !enumGoType(tag.return_type).empty();
+        if (!enum_tag && !integer(tag)) {
+            throw std::invalid_argument("tagged_payloads: " + rule.first + ": tag " + cls->name + "::" +
+                                        rule.second.tag + " returns neither an enum nor an integer");
+        }
+        if (!rule.second.size.empty() && !integer(payloadMember(*cls, rule.first, rule.second.size))) {
+            throw std::invalid_argument("tagged_payloads: " + rule.first + ": size " + cls->name + "::" +
+                                        rule.second.size + " does not return an integer");
+        }
+        for (const auto& type : rule.second.types) {
+            bool bound = std::any_of(classes.begin(), classes.end(), [&type](const FFIClass& c) {
+                return c.name == type.second;
+            });
+            if (!bound && enumGoType(type.second).empty() && scalarPayloadType(type.second).empty()) {
+                throw std::invalid_argument("tagged_payloads: " + rule.first + ": " + type.second + " of tag " +
+                                            type.first + " is neither a bound class, an enum nor a scalar");
+            }
+        }
+        generatePayload(*cls, method);
+    }
+}
+
+std::string GoFFIGenerator::generateMirror(const FFIClass& cls, const StructLayout& layout,
+                                          const std::string& targets) {
+    std::string type_name = typeName(cls.name);
+    std::vector<std::pair<std::string, std::string>> members;
+    size_t offset = 0;
+
+    // Explicit padding pins every member to the offset the C++ ABI chose
+    auto pad = [&members](size_t bytes) {
+        if (bytes > 0) {
+            members.emplace_back("_", "[" + std::to_string(bytes) + "]byte");
+        }
+    };
+
+    for (const auto& field : layout.fields) {
+        pad(field.offset - offset);
+
+        std::string bits = std::to_string(field.size * 8);
+        std::string go_field_type;
+        if (field.c_type == "bool" || field.c_type == "_Bool") {
+            go_field_type = field.size == 1 ? "bool" : "uint" + bits;
+        } else if (field.c_type == "long") {
+            go_field_type = "int" + bits;
+        } else if (field.c_type == "unsigned long") {
+            go_field_type = "uint" + bits;
+        } else if (field.c_type.back() == '*') {
+            go_field_type = "unsafe.Pointer";
+        } else {
+            go_field_type = goType(field.c_type);
+        }
+        members.emplace_back(goName(field.name), go_field_type);
+        offset = field.offset + field.size;
+    }
+    pad(layout.size - offset);
+
+    size_t width = 0;
+    for (const auto& member : members) {
+        width = std::max(width, member.first.size());
+    }
+
+    std::stringstream ss;
+    ss << "// " << type_name << " mirrors the C++ struct " << cls.name << " (" << layout.size
+       << " bytes on " << targets << ").\n";
+    ss << "type " << type_name << " struct {\n";
+    for (const auto& member : members) {
+        ss << "\t" << member.first << std::string(width - member.first.size() + 1, ' ') << member.second << "\n";
+    }
+    ss << "}\n";
+
+    return ss.str();
+}
+
+std::map<std::string, std::string> GoFFIGenerator::generateLayoutFiles(
+    const std::vector<FFIClass>& all_classes,
+    const std::string& library_name
+) {
+    std::vector<FFIClass> classes = LayoutEngine::resolveMirrors(all_classes, options_);
+    LayoutEngine engine(classes);
+    std::vector<ABIProfile> profiles = LayoutEngine::profiles(options_);
+    std::map<std::string, std::string> files;
+    resolveNames({}, classes);
+
+    // Targets that share a build constraint share a file
+    std::vector<std::string> constraints;
+    std::map<std::string, std::vector<ABIProfile>> targets;
+    for (const auto& profile : profiles) {
+        std::string constraint = profile.goBuildConstraint();
+        if (targets.find(constraint) == targets.end()) {
+            constraints.push_back(constraint);
+        }
+        targets[constraint].push_back(profile);
+    }
+
+    for (const auto& constraint : constraints) {
+        const auto& group = targets[constraint];
+        std::string triples;
+        for (const auto& profile : group) {
+            triples += (triples.empty() ? "" : ", ") + profile.triple;
+        }
+
+        std::stringstream body;
+        for (const auto& cls : classes) {
+            if (cls.is_mirrored && !engine.isPortable(cls, profiles) && LayoutEngine::isDeclared(cls, group[0])) {
+                body << "\n" << generateMirror(cls, engine.layout(cls, group[0]), triples);
+            }
+        }
+        if (body.str().empty()) {
+            continue;
+        }
+
+        std::stringstream ss;
+        ss << "// Code generated by Hybrid Transpiler. DO NOT EDIT.\n\n";
+        ss << "//go:build " << constraint << "\n\n";
+        ss << "package " << packageName(options_, library_name) << "\n";
+        if (body.str().find("unsafe.") != std::string::npos) {
+            ss << "\nimport \"unsafe\"\n";
+        }
+        ss << body.str();
+
+        files[library_name + "_layout_" + group[0].go_os + "_" + group[0].go_arch + ".go"] = ss.str();
+    }
+
+    return files;
+}
+
+std::string GoFFIGenerator::generateErrorCodeSupport() {
+    // Category names as reported by std::error_category::name(); the asio
+    // ones cover the standalone library's non-system categories
+    return
+        "// ErrorCode is a C++ std::error_code reported through an error_code& parameter.\n"
+        "type ErrorCode struct {\n"
+        "\tValue    int\n"
+        "\tCategory string\n"
+        "\tMessage  string\n"
+        "}\n"
+        "\n"
+        "func (e *ErrorCode) Error() string {\n"
+        "\treturn e.Category + \" error \" + strconv.Itoa(e.Value) + \": \" + e.Message\n"
+        "}\n"
+        "\n"
+        "// Is matches the sentinel for the error's category, e.g. errors.Is(err, ErrSystemCategory).\n"
+        "func (e *ErrorCode) Is(target error) bool {\n"
+        "\tsentinel, ok := errorCategories[e.Category]\n"
+        "\treturn ok && sentinel == target\n"
+        "}\n"
+        "\n"
+        "// Sentinel errors for well-known std::error_category names.\n"
+        "var (\n"
+        "\tErrGenericCategory      = errors.New(\"generic\")\n"
+        "\tErrSystemCategory       = errors.New(\"system\")\n"
+        "\tErrIOStreamCategory     = errors.New(\"iostream\")\n"
+        "\tErrFutureCategory       = errors.New(\"future\")\n"
+        "\tErrAsioMiscCategory     = errors.New(\"asio.misc\")\n"
+        "\tErrAsioNetdbCategory    = errors.New(\"asio.netdb\")\n"
+        "\tErrAsioAddrinfoCategory = errors.New(\"asio.addrinfo\")\n"
+        ")\n"
+        "\n"
+        "var errorCategories = map[string]error{\n"
+        "\t\"generic\":       ErrGenericCategory,\n"
+        "\t\"system\":        ErrSystemCategory,\n"
+        "\t\"iostream\":      ErrIOStreamCategory,\n"
+        "\t\"future\":        ErrFutureCategory,\n"
+        "\t\"asio.misc\":     ErrAsioMiscCategory,\n"
+        "\t\"asio.netdb\":    ErrAsioNetdbCategory,\n"
+        "\t\"asio.addrinfo\": ErrAsioAddrinfoCategory,\n"
+        "}\n"
+        "\n"
+        "func errorCodeResult(value C.int, category, message *C.char) error {\n"
+        "\tif value == 0 {\n"
+        "\t\treturn nil\n"
+        "\t}\n"
+        "\tdefer C.free(unsafe.Pointer(message))\n"
+        "\treturn &ErrorCode{Value: int(value), Category: C.GoString(category), Message: C.GoString(message)}\n"
+        "}\n";
+}
+
+std::string GoFFIGenerator::generateUnexpectedErrorSupport() {
+    return
+        "// UnexpectedError is the enum error of a C++ std::expected that holds no value.\n"
+        "type UnexpectedError struct {\n"
+        "\tType  string\n"
+        "\tValue int\n"
+        "}\n"
+        "\n"
+        "func (e *UnexpectedError) Error() string {\n"
+        "\treturn e.Type + \"(\" + strconv.Itoa(e.Value) + \")\"\n"
+        "}\n";
+}
+
+std::string GoFFIGenerator::generateValidationSupport() {
+    return
+        "// ValidationError is an argument outside the values its C++ documentation allows,\n"
+        "// caught before the call reached C++.\n"
+        "type ValidationError struct {\n"
+        "\tFunction   string // Qualified C++ name\n"
+        "\tParameter  string // C++ parameter name\n"
+        "\tValue      any\n"
+        "\tConstraint string // As documented, e.g. \"between 0 and 9\"\n"
+        "}\n"
+        "\n"
+        "func (e *ValidationError) Error() string {\n"
+        "\treturn e.Function + \": \" + e.Parameter + \" is \" + fmt.Sprint(e.Value) + \", must be \" + e.Constraint\n"
+        "}\n";
+}
+
+std::string GoFFIGenerator::generateFactorySupport() {
+    return
+        "// FactoryError is the nullptr a C++ factory of an abstract class returned\n"
+        "// instead of an object.\n"
+        "type FactoryError struct {\n"
+        "\tFunction string\n"
+        "}\n"
+        "\n"
+        "func (e *FactoryError) Error() string {\n"
+        "\treturn e.Function + \": no object created\"\n"
+        "}\n";
+}
+
+std::string GoFFIGenerator::generateStatusSupport() {
+    return
+        "// StatusError is the nonzero status code returned by a C++ function annotated @status.\n"
+        "type StatusError struct {\n"
+        "\tFunction string\n"
+        "\tCode     int64\n"
+        "}\n"
+        "\n"
+        "func (e *StatusError) Error() string {\n"
+        "\treturn e.Function + \": status \" + strconv.FormatInt(e.Code, 10)\n"
+        "}\n"
+        "\n"
+        "func statusResult(function string, code int64) error {\n"
+        "\tif code == 0 {\n"
+        "\t\treturn nil\n"
+        "\t}\n"
+        "\treturn &StatusError{Function: function, Code: code}\n"
+        "}\n";
+}
+
+std::string GoFFIGenerator::generateExceptionSupport(bool panics) {
+    std::string support =
+        "// ExceptionError is a C++ exception caught at the shim: functions annotated\n"
+        "// @throws return it, and the others panic with it when the bindings were\n"
+        "// generated with the panic default exception behavior.\n"
+        "type ExceptionError struct {\n"
+        "\tFunction string\n"
+        "\tWhat     string\n"
+        "}\n"
+        "\n"
+        "func (e *ExceptionError) Error() string {\n"
+        "\treturn e.Function + \": \" + e.What\n"
+        "}\n"
+        "\n"
+        "func exceptionResult(function string, what *C.char) error {\n"
+        "\tif what == nil {\n"
+        "\t\treturn nil\n"
+        "\t}\n"
+        "\tdefer C.free(unsafe.Pointer(what))\n"
+        "\treturn &ExceptionError{Function: function, What: C.GoString(what)}\n"
+        "}\n";
+    if (panics) {
+        support +=
+            "\n"
+            "func panicOnException(function string, what *C.char) {\n"
+            "\tif err := exceptionResult(function, what); err != nil {\n"
+            "\t\tpanic(err)\n"
+            "\t}\n"
+            "}\n";
+    }
+    return support;
+}
+
+std::string GoFFIGenerator::generateHandleSupport() {
+    return
+        "// ErrHandleInvalidated is returned by calls on an object whose C++ handle an\n"
+        "// error of its handle invalidation rule has invalidated.\n"
+        "var ErrHandleInvalidated = errors.New(\"C++ handle invalidated\")\n"
+        "\n"
+        "// HandleInvalidatedError is the error that invalidated a C++ handle, returned by\n"
+        "// the call that saw it. It matches ErrHandleInvalidated.\n"
+        "type HandleInvalidatedError struct {\n"
+        "\tErr error\n"
+        "}\n"
+        "\n"
+        "func (e *HandleInvalidatedError) Error() string {\n"
+        "\treturn e.Err.Error() + \" (handle invalidated)\"\n"
+        "}\n"
+        "\n"
+        "func (e *HandleInvalidatedError) Is(target error) bool {\n"
+        "\treturn target == ErrHandleInvalidated\n"
+        "}\n"
+        "\n"
+        "func (e *HandleInvalidatedError) Unwrap() error {\n"
+        "\treturn e.Err\n"
+        "}\n";
+}
+
+std::string GoFFIGenerator::generateBitsetSupport(size_t width) {
+    std::string type_name = bitsetGoType(width);
+    std::string n = std::to_string(width);
+    std::string words = std::to_string((width + 63) / 64);
+    std::stringstream ss;
+
+    if (width <= 64) {
+        ss << "// " << type_name << " holds a C++ std::bitset<" << n << ">; bit i is 1<<i.\n";
+        ss << "type " << type_name << " uint64\n\n";
+    } else {
+        ss << "// " << type_name << " holds a C++ std::bitset<" << n << "> as 64-bit words; bit i is\n";
+        ss << "// 1<<(i%64) of word i/64.\n";
+        ss << "type " << type_name << " [" << words << "]uint64\n\n";
+    }
+
+    ss << "// Len returns the number of bits, " << n << ".\n";
+    ss << "func (b " << type_name << ") Len() int {\n";
+    ss << "\treturn " << n << "\n";
+    ss << "}\n\n";
+
+    std::string bit = width <= 64 ? "b" : "b[i/64]";
+    std::string shift = width <= 64 ? "uint(i)" : "uint(i%64)";
+    std::string check =
+        "\tif i < 0 || i >= " + n + " {\n"
+        "\t\tpanic(\"" + type_name + ": bit index out of range\")\n"
+        "\t}\n";
+
+    ss << "// Test reports whether bit i is set. Like std::bitset::test, it panics if i\n";
+    ss << "// is out of range.\n";
+    ss << "func (b " << type_name << ") Test(i int) bool {\n";
+    ss << check;
+    ss << "\treturn " << bit << "&(1<<" << shift << ") != 0\n";
+    ss << "}\n\n";
+
+    ss << "// Set sets bit i to value. It panics if i is out of range.\n";
+    ss << "func (b *" << type_name << ") Set(i int, value bool) {\n";
+    ss << check;
+    ss << "\tif value {\n";
+    ss << "\t\t" << (width <= 64 ? "*b" : bit) << " |= 1 << " << shift << "\n";
+    ss << "\t} else {\n";
+    ss << "\t\t" << (width <= 64 ? "*b" : bit) << " &^= 1 << " << shift << "\n";
+    ss << "\t}\n";
+    ss << "}\n\n";
+
+    ss << "// Count returns the number of set bits.\n";
+    ss << "func (b " << type_name << ") Count() int {\n";
+    if (width <= 64) {
+        ss << "\treturn bits.OnesCount64(uint64(b))\n";
+    } else {
+        ss << "\tcount := 0\n";
+        ss << "\tfor _, word := range b {\n";
+        ss << "\t\tcount += bits.OnesCount64(word)\n";
+        ss << "\t}\n";
+        ss << "\treturn count\n";
+    }
+    ss << "}\n";
+
+    return ss.str();
+}
+
+std::string GoFFIGenerator::generatePreamble(
+    const std::vector<FFIFunction>& functions,
+    const std::vector<FFIClass>& all_classes,
+    const std::string& library_name,
+    const std::string& body
+) {
+    std::vector<FFIClass> classes = LayoutEngine::resolveMirrors(all_classes, options_);
+    std::stringstream ss;
+
+    ss << "#cgo CFLAGS: -I${SRCDIR}/" << options_.include_dir << "\n";
+    if (options_.decls_header) {
+        ss << "#cgo CFLAGS: -I${SRCDIR}\n";
+    }
+    if (options_.windows_dll_import) {
+        // MinGW's ld resolves -l<lib> against an MSVC import library (<lib>.lib)
+        // as well as lib<lib>.dll.a, so one directive covers both toolchains.
+        // The C++ runtime lives inside the DLL, hence no -lstdc++ on Windows.
+        ss << "#cgo !windows LDFLAGS: -L${SRCDIR}/" << options_.lib_dir
+           << " -l" << library_name << " -lstdc++\n";
+        ss << "#cgo windows LDFLAGS: -L${SRCDIR}/" << options_.lib_dir
+           << " -l" << library_name << "\n";
+    } else {
+        ss << "#cgo LDFLAGS: -L${SRCDIR}/" << options_.lib_dir
+           << " -l" << library_name << " -lstdc++\n";
+    }
+    ss << generateSmallStringDirectives(functions, classes, body);
+    ss << "\n";
+
+    if (!options_.cgo_prologue.empty()) {
+        appendInjected(ss, "cgo_prologue", options_.cgo_prologue);
+        ss << "\n";
+    }
+
+    // Optional features and per-target variants declare their shims in their own files
+    if (options_.decls_header) {
+        ss << "#include \"" << kDeclsHeader << "\"\n";
+    } else {
+        ss << generateDeclarations(functions, classes, library_name, true, [this](const FFIFunction& func) {
+            return configuredFeature(func.feature).empty() && func.targets.empty();
+        });
+    }
+
+    if (!options_.cgo_epilogue.empty()) {
+        ss << "\n";
+        appendInjected(ss, "cgo_epilogue", options_.cgo_epilogue);
+    }
+
+    return ss.str();
+}
+
+std::string GoFFIGenerator::generateDeclarations(
+    const std::vector<FFIFunction>& functions,
+    const std::vector<FFIClass>& classes,
+    const std::string& library_name,
+    bool visitor_export,
+    const std::function<bool(const FFIFunction&)>& declared
+) {
+    CWrapperGenerator c_generator(options_);
+    std::string linkage = options_.windows_dll_import ? CWrapperGenerator::macroPrefix(library_name) : "";
+    std::stringstream ss;
+
+    ss << "#include <stdlib.h>\n";
+    ss << "#include <stdint.h>\n";
+    ss << "#include <stdbool.h>\n";
+    if (CWrapperGenerator::usesTm(functions, classes)) {
+        ss << "#include <time.h>\n";
+    }
+    ss << "\n";
+
+    if (options_.windows_dll_import) {
+        ss << c_generator.generateLinkageMacros(library_name, false) << "\n";
+    }
+
+    // Variants of a shim for different targets share its symbol
+    auto declare = [&](const FFIFunction& func) {
+        if (declared(func)) {
+            ss << CWrapperGenerator::targetGuarded(c_generator.generateDeclaration(func, linkage) + "\n", func.targets);
+        }
+    };
+    for (const auto& func : CWrapperGenerator::bindableFunctions(functions)) {
+        declare(func);
+    }
+
+    for (const auto& cls : classes) {
+        for (const auto& shim : CWrapperGenerator::shimFunctions(cls)) {
+            declare(shim);
+        }
+        for (const auto& shim : CWrapperGenerator::directShims(cls)) {
+            declare(shim);
+        }
+    }
+
+    // Declared for the Go side to take its address; the //export in the
+    // package means the preamble may only declare, never define
+    bool visitors = std::any_of(functions.begin(), functions.end(),
+                                [](const FFIFunction& func) { return !func.visitor.callback.empty(); });
+    for (const auto& cls : classes) {
+        for (const auto& shim : CWrapperGenerator::shimFunctions(cls)) {
+            visitors = visitors || !shim.visitor.callback.empty();
+        }
+    }
+    if (visitor_export && visitors) {
+        ss << "int64_t " << visitorExport(library_name) << "(void* element, uintptr_t visitor);\n";
+    }
+    for (const auto& func : CWrapperGenerator::bindableFunctions(functions)) {
+        if (visitor_export && !func.logger.callback.empty()) {
+            ss << "void " << logExport(library_name) << "(" << func.logger.level.c_type << " level, char* message);\n";
+        }
+    }
+
+    return ss.str();
+}
+
+std::string GoFFIGenerator::generateDeclsHeader(
+    const std::vector<FFIFunction>& functions,
+    const std::vector<FFIClass>& all_classes,
+    const std::string& library_name
+) {
+    if (!options_.decls_header) {
+        return "";
+    }
+    std::vector<FFIClass> classes = LayoutEngine::resolveMirrors(all_classes, options_);
+    std::string guard = CWrapperGenerator::macroPrefix(library_name) + "_GENERATED_DECLS_H";
+    std::stringstream ss;
+
+    // Every file of the package includes it, so feature shims are declared too
+    ss << "/* Code generated by Hybrid Transpiler. DO NOT EDIT. */\n";
+    ss << "#ifndef " << guard << "\n";
+    ss << "#define " << guard << "\n\n";
+    ss << generateDeclarations(functions, classes, library_name, true, [](const FFIFunction&) { return true; });
+    ss << "\n#endif /* " << guard << " */\n";
+
+    return ss.str();
+}
+
+std::string GoFFIGenerator::generatePackage(
+    const std::vector<FFIFunction>& functions,
+    const std::vector<FFIClass>& all_classes,
+    const std::string& library_name,
+    const std::vector<FFIEnum>& enums,
+    const std::vector<FFIConstant>& constants
+) {
+    std::vector<FFIClass> classes = LayoutEngine::resolveMirrors(all_classes, options_);
+    LayoutEngine engine(classes);
+    std::vector<ABIProfile> profiles = LayoutEngine::profiles(options_);
+    std::stringstream body;
+
+    enums_ = enums;
+    constants_ = constants;
+    mirrors_ = mirroredNames(classes);
+    logs_ = installsLogger(functions);
+    resolveNames(functions, classes);
+    for (const auto& ffi_enum : enums_) {
+        body << generateEnum(ffi_enum, functions) << "\n";
+    }
+    std::string constant_block = generateConstants(functions, classes);
+    if (!constant_block.empty()) {
+        body << constant_block << "\n";
+    }
+
+    // Result structs, shared by functions that name the same struct
+    std::map<std::string, std::string> result_structs;
+    std::set<std::string> grouped;
+    auto collectResultStruct = [&](const FFIFunction& func) {
+        std::string name = resultStructName(func);
+        bool bound = !func.signal_unsafe || options_.signal_unsafe_policy == SignalUnsafePolicy::BuildTag;
+        if (name.empty() || !bound) {
+            return;
+        }
+        grouped.insert(qualifiedName(func));
+        // Per-target variants declare theirs in generateTargetFiles
+        if (!func.targets.empty()) {
+            return;
+        }
+        std::string decl = generateResultStruct(func);
+        // Compare the fields only; the doc line names the first function
+        std::string fields = decl.substr(decl.find('\n'));
+        auto existing = result_structs.find(name);
+        if (existing != result_structs.end() && existing->second.substr(existing->second.find('\n')) != fields) {
+            throw std::invalid_argument("result struct " + name + " has different fields for " + qualifiedName(func));
+        }
+        result_structs.emplace(name, decl);
+    };
+    for (const auto& cls : classes) {
+        for (const auto& shim : CWrapperGenerator::shimFunctions(cls)) {
+            collectResultStruct(shim);
+        }
+    }
+    for (const auto& func : CWrapperGenerator::bindableFunctions(functions)) {
+        collectResultStruct(func);
+    }
+    for (const auto& entry : options_.result_structs) {
+        if (!grouped.count(entry.first)) {
+            throw std::invalid_argument("result_structs: " + entry.first +
+                                        " is not a bound function with output parameters");
+        }
+    }
+    for (const auto& entry : result_structs) {
+        body << entry.second << "\n";
+    }
+    validateHandleInvalidation(classes);
+    validateTaggedPayloads(classes);
+
+    for (const auto& cls : classes) {
+        if (!cls.is_mirrored && configuredFeature(cls.feature).empty() && cls.targets.empty()) {
+            body << generateClassBinding(cls);
+        } else if (cls.is_mirrored && engine.isPortable(cls, profiles)) {
+            // Split mirrors live in the per-target files of generateLayoutFiles
+            body << generateMirror(cls, engine.layout(cls, profiles[0]), "every configured target") << "\n";
+        }
+    }
+
+    for (const auto& func : CWrapperGenerator::bindableFunctions(functions)) {
+        if (!func.signal_unsafe && configuredFeature(func.feature).empty() && func.targets.empty()) {
+            body << generateWrapper(func) << "\n";
+        }
+    }
+
+    // One Go type per bitset width in the API
+    std::set<size_t> bitset_widths;
+    auto collectBitsets = [&bitset_widths](const FFIFunction& func) {
+        bitset_widths.insert(CWrapperGenerator::bitsetWidth(func.return_type));
+        for (const auto& param : func.parameters) {
+            bitset_widths.insert(CWrapperGenerator::bitsetWidth(param.cpp_type));
+        }
+    };
+    for (const auto& func : CWrapperGenerator::bindableFunctions(functions)) {
+        collectBitsets(func);
+    }
+    for (const auto& cls : classes) {
+        for (const auto& shim : CWrapperGenerator::shimFunctions(cls)) {
+            collectBitsets(shim);
+        }
+    }
+    for (size_t width : bitset_widths) {
+        if (width) {
+            body << generateBitsetSupport(width) << "\n";
+        }
+    }
+
+    // Support code is shared with the signal-unsafe, feature and per-target
+    // wrappers in their own files
+    std::string uses = body.str();
+    for (const auto& group : targetGroups()) {
+        uses += generateTargetBody(functions, classes, group);
+    }
+    bool signal_unsafe_bound = options_.signal_unsafe_policy == SignalUnsafePolicy::BuildTag;
+    if (signal_unsafe_bound) {
+        uses += generateGatedBody(functions, classes, "", true);
+    }
+    for (const auto& feature : options_.features) {
+        uses += generateGatedBody(functions, classes, feature.first, false);
+        if (signal_unsafe_bound) {
+            uses += generateGatedBody(functions, classes, feature.first, true);
+        }
+    }
+    if (uses.find("errorCodeResult(") != std::string::npos) {
+        body << generateErrorCodeSupport() << "\n";
+    }
+    if (uses.find("&UnexpectedError{") != std::string::npos) {
+        body << generateUnexpectedErrorSupport() << "\n";
+    }
+    if (uses.find("statusResult(") != std::string::npos) {
+        body << generateStatusSupport() << "\n";
+    }
+    if (uses.find("&ValidationError{") != std::string::npos) {
+        body << generateValidationSupport() << "\n";
+    }
+    if (uses.find("&FactoryError{") != std::string::npos) {
+        body << generateFactorySupport() << "\n";
+    }
+    bool panics = uses.find("panicOnException(") != std::string::npos;
+    if (panics || uses.find("exceptionResult(") != std::string::npos) {
+        body << generateExceptionSupport(panics) << "\n";
+    }
+    if (uses.find("ErrHandleInvalidated") != std::string::npos) {
+        body << generateHandleSupport() << "\n";
+    }
+    if (uses.find("onMainThread(") != std::string::npos) {
+        body << generateMainThreadSupport(library_name) << "\n";
+    }
+    if (uses.find("visitorCallback") != std::string::npos) {
+        body << generateVisitorSupport(library_name) << "\n";
+    }
+    if (uses.find("logSink") != std::string::npos) {
+        for (const auto& func : CWrapperGenerator::bindableFunctions(functions)) {
+            if (!func.logger.callback.empty()) {
+                body << generateLoggerSupport(func, library_name) << "\n";
+            }
+        }
+    }
+    if (uses.find(" stringCache\n") != std::string::npos) {
+        body << generateStringCacheSupport() << "\n";
+    }
+    if (uses.find("tmFromTime(") != std::string::npos || uses.find("timeFromTm(") != std::string::npos) {
+        body << generateTimeSupport() << "\n";
+    }
+    if (uses.find("cString(") != std::string::npos) {
+        body << generateSmallStringSupport() << "\n";
+    }
+    if (uses.find("newScratchArena(") != std::string::npos) {
+        body << generateScratchArenaSupport() << "\n";
+    }
+    if (uses.find("RawPayload{") != std::string::npos) {
+        body << generatePayloadSupport(uses.find("ErrPayloadTooSmall") != std::string::npos) << "\n";
+    }
+    if (!options_.features.empty()) {
+        body << generateFeatureSupport() << "\n";
+    }
+
+    std::string body_text = body.str();
+    std::stringstream ss;
+
+    ss << "// Code generated by Hybrid Transpiler. DO NOT EDIT.\n\n";
+    ss << "package " << packageName(options_, library_name) << "\n\n";
+    ss << "/*\n";
+    ss << generatePreamble(functions, classes, library_name, body_text);
+    ss << "*/\n";
+    ss << "import \"C\"\n";
+
+    ss << goImports(body_text);
+
+    // Drop the blank line trailing the last declaration
+    while (body_text.size() > 1 && body_text.substr(body_text.size() - 2) == "\n\n") {
+        body_text.pop_back();
+    }
+    ss << "\n" << body_text;
+
+    return ss.str();
+}
+
+std::string GoFFIGenerator::generateMainThreadSupport(const std::string& library_name) {
+    // Comments avoid package-qualified names so import detection ignores them
+    return
+        "// RunOnMainThread runs fn on the main thread and returns once fn has finished.\n"
+        "// Wrappers of C++ functions that must run on the main thread, such as GUI and\n"
+        "// event-loop APIs, dispatch through it. Install it before calling them, for\n"
+        "// example with a function that hands fn to a loop on the main goroutine that\n"
+        "// called LockOSThread from an init function.\n"
+        "var RunOnMainThread func(fn func())\n"
+        "\n"
+        "func onMainThread(name string, fn func()) {\n"
+        "\tif RunOnMainThread == nil {\n"
+        "\t\tpanic(name + \" must run on the main thread: set " + packageName(options_, library_name) +
+        ".RunOnMainThread to a function that runs its argument there\")\n"
+        "\t}\n"
+        "\tRunOnMainThread(fn)\n"
+        "}\n";
+}
+
+std::string GoFFIGenerator::generateVisitorSupport(const std::string& library_name) {
+    std::string callback = visitorExport(library_name);
+    return
+        "// visitorState is the Go side of one visit: the C++ callback passes each\n"
+        "// element to visit until it returns false, and a panic in it is raised\n"
+        "// again by done once C++ has returned. A template policy's results, and\n"
+        "// the enum values decide returns, all go back to C++, so only a panic\n"
+        "// stops them. Once a panic stopped the visit, C++ gets fallback. The\n"
+        "// mutex of a thread-safe object is unlocked while visit or decide runs.\n"
+        "type visitorState struct {\n"
+        "\tvisit     func(element unsafe.Pointer) bool\n"
+        "\tdecide    func(element unsafe.Pointer) int64\n"
+        "\tpolicy    bool\n"
+        "\tstopped   bool\n"
+        "\tfallback  int64\n"
+        "\trecovered any\n"
+        "\tunlocked  sync.Locker\n"
+        "}\n"
+        "\n"
+        "func (s *visitorState) done() {\n"
+        "\tif s.recovered != nil {\n"
+        "\t\tpanic(s.recovered)\n"
+        "\t}\n"
+        "}\n"
+        "\n"
+        "var visitorCallback = (*[0]byte)(C." + callback + ")\n"
+        "\n"
+        "//export " + callback + "\n"
+        "func " + callback + "(element unsafe.Pointer, visitor C.uintptr_t) (result C.int64_t) {\n"
+        "\tstate := cgo.Handle(visitor).Value().(*visitorState)\n"
+        "\tif state.recovered != nil {\n"
+        "\t\treturn C.int64_t(state.fallback)\n"
+        "\t}\n"
+        "\tif state.stopped {\n"
+        "\t\treturn 0\n"
+        "\t}\n"
+        "\t// A panic must not unwind through C++\n"
+        "\tdefer func() {\n"
+        "\t\tif r := recover(); r != nil {\n"
+        "\t\t\tstate.stopped, state.recovered = true, r\n"
+        "\t\t\tresult = C.int64_t(state.fallback)\n"
+        "\t\t}\n"
+        "\t}()\n"
+        "\tif state.unlocked != nil {\n"
+        "\t\tstate.unlocked.Unlock()\n"
+        "\t\tdefer state.unlocked.Lock()\n"
+        "\t}\n"
+        "\tif state.decide != nil {\n"
+        "\t\treturn C.int64_t(state.decide(element))\n"
+        "\t}\n"
+        "\tnext := state.visit(element)\n"
+        "\tstate.stopped = !next && !state.policy\n"
+        "\tif next {\n"
+        "\t\treturn 1\n"
+        "\t}\n"
+        "\treturn 0\n"
+        "}\n";
+}
+
+bool GoFFIGenerator::passesSmallStrings(const FFIFunction& func) const {
+    // nocallback would turn a visitor's call back into Go into a panic, and
+    // with a logger installed any call may log into Go
+    if (options_.small_string_size == 0 || !func.visitor.callback.empty() || logs_) {
+        return false;
+    }
+    return std::any_of(func.parameters.begin(), func.parameters.end(), [this](const FFIParameter& param) {
+        return goType(param.c_type) == "string" && direction(param) == ParamDirection::In;
+    });
+}
+
+bool GoFFIGenerator::bindsSmallStrings(const std::vector<FFIFunction>& functions,
+                                       const std::vector<FFIClass>& classes) const {
+    if (installsLogger(functions)) {
+        return false;
+    }
+    auto bound = [this](const FFIFunction& func) {
+        return passesSmallStrings(func) &&
+               (!func.signal_unsafe || options_.signal_unsafe_policy == SignalUnsafePolicy::BuildTag);
+    };
+    std::vector<FFIFunction> bindable = CWrapperGenerator::bindableFunctions(functions);
+    if (std::any_of(bindable.begin(), bindable.end(), bound)) {
+        return true;
+    }
+    for (const auto& cls : classes) {
+        std::vector<FFIFunction> shims = CWrapperGenerator::shimFunctions(cls);
+        if (!cls.is_mirrored && std::any_of(shims.begin(), shims.end(), bound)) {
+            return true;
+        }
+    }
+    return false;
+}
+
+std::vector<size_t> GoFFIGenerator::arenaStrings(const FFIFunction& func) const {
+    std::vector<size_t> strings;
+    if (!options_.scratch_arena || passesSmallStrings(func)) {
+        return strings;
+    }
+    size_t count = func.parameters.size() - (CWrapperGenerator::hasErrorCodeOut(func) ? 1 : 0);
+    for (size_t i = 0; i < count; ++i) {
+        const auto& param = func.parameters[i];
+        // printf formats are only known once fmt.Sprintf has run
+        bool formatted = func.printf_format && i + 1 == count;
+        if (goType(param.c_type) == "string" && direction(param) == ParamDirection::In && !formatted &&
+            !isVisitorCallback(func, param) && !isVisitorContext(func, param)) {
+            strings.push_back(i);
+        }
+    }
+    // A single string gains nothing over C.CString
+    if (strings.size() < 2) {
+        strings.clear();
+    }
+    return strings;
+}
+
+bool GoFFIGenerator::bindsScratchArena(const std::vector<FFIFunction>& functions,
+                                       const std::vector<FFIClass>& classes) const {
+    auto bound = [this](const FFIFunction& func) {
+        return !arenaStrings(func).empty() &&
+               (!func.signal_unsafe || options_.signal_unsafe_policy == SignalUnsafePolicy::BuildTag);
+    };
+    std::vector<FFIFunction> bindable = CWrapperGenerator::bindableFunctions(functions);
+    if (std::any_of(bindable.begin(), bindable.end(), bound)) {
+        return true;
+    }
+    for (const auto& cls : classes) {
+        std::vector<FFIFunction> shims = CWrapperGenerator::shimFunctions(cls);
+        if (!cls.is_mirrored && std::any_of(shims.begin(), shims.end(), bound)) {
+            return true;
+        }
+    }
+    return false;
+}
+
+std::string GoFFIGenerator::generateScratchArenaSupport() {
+    return
+        "// scratchArena holds the C copies of one call's string arguments in a\n"
+        "// single allocation, which free releases however the call ends.\n"
+        "type scratchArena struct {\n"
+        "\tbase unsafe.Pointer\n"
+        "\tused uintptr\n"
+        "}\n"
+        "\n"
+        "// newScratchArena allocates size bytes of C memory for copyString.\n"
+        "func newScratchArena(size int) scratchArena {\n"
+        "\treturn scratchArena{base: C.malloc(C.size_t(size))}\n"
+        "}\n"
+        "\n"
+        "// copyString copies s and a terminating NUL to the arena's next free bytes.\n"
+        "// C++ reads s up to its first NUL.\n"
+        "func (a *scratchArena) copyString(s string) *C.char {\n"
+        "\tp := unsafe.Add(a.base, a.used)\n"
+        "\tbuf := unsafe.Slice((*byte)(p), len(s)+1)\n"
+        "\tcopy(buf, s)\n"
+        "\tbuf[len(s)] = 0\n"
+        "\ta.used += uintptr(len(s) + 1)\n"
+        "\treturn (*C.char)(p)\n"
+        "}\n"
+        "\n"
+        "// free releases every string copied into the arena.\n"
+        "func (a *scratchArena) free() {\n"
+        "\tC.free(a.base)\n"
+        "}\n";
+}
+
+std::string GoFFIGenerator::generateSmallStringDirectives(const std::vector<FFIFunction>& functions,
+                                                          const std::vector<FFIClass>& classes,
+                                                          const std::string& body) const {
+    // Only shims this file calls: cgo rejects directives for functions the build never uses
+    std::set<std::string> called;
+    static const std::regex call(R"(\bC\.(\w+)\()");
+    for (auto it = std::sregex_iterator(body.begin(), body.end(), call); it != std::sregex_iterator(); ++it) {
+        called.insert((*it)[1].str());
+    }
+
+    std::vector<FFIFunction> shims = CWrapperGenerator::bindableFunctions(functions);
+    for (const auto& cls : classes) {
+        for (const auto& shim : CWrapperGenerator::shimFunctions(cls)) {
+            shims.push_back(shim);
+        }
+        for (const auto& shim : CWrapperGenerator::directShims(cls)) {
+            shims.push_back(shim);
+        }
+    }
+
+    // The stack buffer stays on the stack only if the shim neither keeps it nor calls back into Go
+    std::stringstream ss;
+    for (const auto& shim : shims) {
+        std::string name = CWrapperGenerator::shimName(shim);
+        if (passesSmallStrings(shim) && called.erase(name)) {
+            ss << "#cgo noescape " << name << "\n";
+            ss << "#cgo nocallback " << name << "\n";
+        }
+    }
+    return ss.str();
+}
+
+std::string GoFFIGenerator::generateSmallStringSupport() {
+    return
+        "// smallStringSize is the longest string argument passed to C++ from a buffer\n"
+        "// on the Go stack; longer ones are copied to the C heap.\n"
+        "const smallStringSize = " + std::to_string(options_.small_string_size) + "\n"
+        "\n"
+        "// cString returns s as a NUL-terminated C string. When s fits in buf it is\n"
+        "// copied there and owned is nil; otherwise C.CString copies it and owned is\n"
+        "// that copy, for freeCString to release. C++ reads s up to its first NUL\n"
+        "// either way.\n"
+        "func cString(s string, buf []byte) (p, owned *C.char) {\n"
+        "\tif len(s) >= len(buf) {\n"
+        "\t\towned = C.CString(s)\n"
+        "\t\treturn owned, owned\n"
+        "\t}\n"
+        "\tcopy(buf, s)\n"
+        "\tbuf[len(s)] = 0\n"
+        "\treturn (*C.char)(unsafe.Pointer(&buf[0])), nil\n"
+        "}\n"
+        "\n"
+        "// freeCString releases what cString copied to the C heap.\n"
+        "func freeCString(owned *C.char) {\n"
+        "\tif owned != nil {\n"
+        "\t\tC.free(unsafe.Pointer(owned))\n"
+        "\t}\n"
+        "}\n";
+}
+
+std::string GoFFIGenerator::generateLogger(const FFIFunction& func) {
+    std::string go_name = wrapperName(func);
+    std::string shim = "C." + CWrapperGenerator::shimName(func);
+    std::stringstream ss;
+    ss << "// " << go_name << " wraps " << qualifiedName(func) << ": each message C++ logs becomes a\n";
+    ss << "// record of logger at the slog level of its " << enumGoType(func.logger.level.cpp_type) << ". A nil logger\n";
+    ss << "// uninstalls the handler.\n";
+    ss << "func " << go_name << "(logger *slog.Logger) {\n";
+    ss << "\tlogSink.mu.Lock()\n";
+    ss << "\tdefer logSink.mu.Unlock()\n";
+    ss << "\tlogSink.logger.Store(logger)\n";
+    ss << "\tif logger == nil {\n";
+    ss << "\t\t" << shim << "(nil)\n";
+    ss << "\t\treturn\n";
+    ss << "\t}\n";
+    ss << "\t" << shim << "(logCallback)\n";
+    ss << "}\n";
+    return ss.str();
+}
+
+std::string GoFFIGenerator::generateLoggerSupport(const FFIFunction& func, const std::string& library_name) {
+    std::string callback = logExport(library_name);
+    std::string level_type = enumGoType(func.logger.level.cpp_type);
+
+    // Levels are matched by the end of their name: LOG_DEBUG and kDebug are both debug
+    static const std::vector<std::pair<std::string, std::vector<std::string>>> groups = {
+        {"slog.LevelDebug", {"trace", "verbose", "debug"}},
+        {"slog.LevelWarn", {"warn", "warning"}},
+        {"slog.LevelError", {"err", "error", "critical", "fatal"}},
+    };
+    std::string base = pointeeName(func.logger.level.cpp_type);
+    std::stringstream cases;
+    for (const auto& ffi_enum : enums_) {
+        if (ffi_enum.name != base) {
+            continue;
+        }
+        for (const auto& group : groups) {
+            std::string matched;
+            for (const auto& enumerator : ffi_enum.enumerators) {
+                std::string name = enumerator.first;
+                std::transform(name.begin(), name.end(), name.begin(),
+                               [](char c) { return static_cast<char>(std::tolower(static_cast<unsigned char>(c))); });
+                bool named = std::any_of(group.second.begin(), group.second.end(), [&name](const std::string& suffix) {
+                    return name.size() >= suffix.size() && name.compare(name.size() - suffix.size(), suffix.size(),
+                                                                        suffix) == 0;
+                });
+                if (named) {
+                    matched += (matched.empty() ? "" : ", ") + std::string("l == ") +
+                               enumeratorName(ffi_enum.name, enumerator.first);
+                }
+            }
+            if (!matched.empty()) {
+                cases << "\tcase " << matched << ":\n";
+                cases << "\t\trecord = " << group.first << "\n";
+            }
+        }
+    }
+
+    std::stringstream ss;
+    ss << "// logSink holds the logger " << wrapperName(func) << " installed, which the C++ log handler\n";
+    ss << "// passes each message to; mu keeps it in step with the installed handler.\n";
+    ss << "var logSink struct {\n";
+    ss << "\tmu     sync.Mutex\n";
+    ss << "\tlogger atomic.Pointer[slog.Logger]\n";
+    ss << "}\n";
+    ss << "\n";
+    ss << "var logCallback = (*[0]byte)(C." << callback << ")\n";
+    ss << "\n";
+    ss << "//export " << callback << "\n";
+    ss << "func " << callback << "(level C." << func.logger.level.c_type << ", message *C.char) {\n";
+    ss << "\tlogger := logSink.logger.Load()\n";
+    ss << "\tif logger == nil {\n";
+    ss << "\t\treturn\n";
+    ss << "\t}\n";
+    ss << "\trecord := slog.LevelInfo\n";
+    // Aliased levels share a value, so the cases compare rather than list them
+    if (!cases.str().empty()) {
+        ss << "\tswitch l := " << level_type << "(level); {\n";
+        ss << cases.str();
+        ss << "\t}\n";
+    }
+    ss << "\tlogger.Log(context.Background(), record, C.GoString(message))\n";
+    ss << "}\n";
+    return ss.str();
+}
+
+std::string GoFFIGenerator::generateTimeSupport() {
+    return
+        "// tmFromTime fills a C struct tm with the wall clock of t in its own location:\n"
+        "// tm_year counts from 1900 and tm_mon and tm_yday from 0. Fractions of a\n"
+        "// second and the zone offset, which struct tm cannot hold, are dropped.\n"
+        "func tmFromTime(t time.Time) C.struct_tm {\n"
+        "\tvar tm C.struct_tm\n"
+        "\ttm.tm_year = C.int(t.Year() - 1900)\n"
+        "\ttm.tm_mon = C.int(t.Month() - 1)\n"
+        "\ttm.tm_mday = C.int(t.Day())\n"
+        "\ttm.tm_hour = C.int(t.Hour())\n"
+        "\ttm.tm_min = C.int(t.Minute())\n"
+        "\ttm.tm_sec = C.int(t.Second())\n"
+        "\ttm.tm_wday = C.int(t.Weekday())\n"
+        "\ttm.tm_yday = C.int(t.YearDay() - 1)\n"
+        "\tif t.IsDST() {\n"
+        "\t\ttm.tm_isdst = 1\n"
+        "\t}\n"
+        "\treturn tm\n"
+        "}\n"
+        "\n"
+        "// timeFromTm reads a C struct tm as a wall clock in UTC. Fields out of range\n"
+        "// carry over as with mktime, so a leap second 60 is the next minute.\n"
+        "func timeFromTm(tm C.struct_tm) time.Time {\n"
+        "\treturn time.Date(int(tm.tm_year)+1900, time.Month(tm.tm_mon)+1, int(tm.tm_mday),\n"
+        "\t\tint(tm.tm_hour), int(tm.tm_min), int(tm.tm_sec), 0, time.UTC)\n"
+        "}\n";
+}
+
+std::string GoFFIGenerator::generatePayloadSupport(bool sized) {
+    std::string support =
+        "// payloadType reads the payload a tag marks: size is the least number of\n"
+        "// bytes it takes, 0 for a class handle.\n"
+        "type payloadType struct {\n"
+        "\tsize   uintptr\n"
+        "\tdecode func(unsafe.Pointer) any\n"
+        "}\n"
+        "\n"
+        "// RawPayload is a payload that could not be typed: its tag and, when its\n"
+        "// size is known, a copy of its bytes.\n"
+        "type RawPayload struct {\n"
+        "\tTag   int64\n"
+        "\tBytes []byte\n"
+        "}\n"
+        "\n"
+        "// ErrUnknownPayloadTag is returned with a RawPayload whose tag has no type.\n"
+        "var ErrUnknownPayloadTag = errors.New(\"unknown payload tag\")\n";
+    if (sized) {
+        support +=
+            "\n"
+            "// ErrPayloadTooSmall is returned with a RawPayload smaller than the type of its tag.\n"
+            "var ErrPayloadTooSmall = errors.New(\"payload smaller than the type of its tag\")\n";
+    }
+    return support;
+}
+
+std::string GoFFIGenerator::generateFeatureSupport() {
+    std::string tags;
+    size_t index = 0;
+    for (const auto& feature : options_.features) {
+        tags += (index++ == 0 ? "" : ", ") + featureTag(feature.first);
+    }
+    return
+        "// features lists the build tags of the optional features compiled in; the\n"
+        "// file of each feature adds its tag from an init function.\n"
+        "var features []string\n"
+        "\n"
+        "// Features returns the build tags of the optional C++ features this binary\n"
+        "// was built with. The functions of a feature only exist when its tag is set.\n"
+        "// Known features: " + tags + ".\n"
+        "func Features() []string {\n"
+        "\treturn append([]string(nil), features...)\n"
+        "}\n";
+}
+
+std::string GoFFIGenerator::configuredFeature(const std::string& macro) const {
+    return options_.features.count(macro) ? macro : "";
+}
+
+std::string GoFFIGenerator::featureTag(const std::string& macro) const {
+    if (!std::regex_match(macro, std::regex(R"([A-Za-z_]\w*)"))) {
+        throw std::invalid_argument("feature macro '" + macro + "' is not an identifier");
+    }
+    std::string tag = options_.features.at(macro).tag;
+    if (tag.empty()) {
+        // MYLIB_WITH_ENCRYPTION -> mylib_encryption
+        std::string lower = "_" + macro + "_";
+        std::transform(lower.begin(), lower.end(), lower.begin(),
+                       [](char c) { return static_cast<char>(std::tolower(static_cast<unsigned char>(c))); });
+        for (size_t pos = lower.find("_with_"); pos != std::string::npos; pos = lower.find("_with_", pos)) {
+            lower.erase(pos, 5);
+        }
+        tag = lower.substr(1, lower.size() - 2);
+    }
+    if (!std::regex_match(tag, std::regex(R"([\w.]+)"))) {
+        throw std::invalid_argument("feature tag '" + tag + "' of " + macro + " is not a Go build tag");
+    }
+    return tag;
+}
+
+std::string GoFFIGenerator::generateGatedBody(
+    const std::vector<FFIFunction>& functions,
+    const std::vector<FFIClass>& classes,
+    const std::string& feature,
+    bool signal_unsafe
+) {
+    std::stringstream body;
+
+    for (const auto& cls : classes) {
+        // A feature's own classes are bound whole, like those of the main file
+        bool whole = configuredFeature(cls.feature) == feature;
+        if (whole && !feature.empty() && !signal_unsafe && !cls.is_mirrored) {
+            body << generateClassBinding(cls);
+            continue;
+        }
+        size_t ctor_index = 0;
+        for (const auto& shim : CWrapperGenerator::shimFunctions(cls)) {
+            size_t index = shim.is_constructor ? ctor_index++ : 0;
+            if (shim.signal_unsafe != signal_unsafe || configuredFeature(shim.feature) != feature ||
+                (whole && !signal_unsafe) || !shim.iteration.empty()) {
+                continue;
+            }
+            if (shim.is_constructor) {
+                body << generateConstructor(cls, shim, index) << "\n";
+            } else if (shim.is_static) {
+                body << generateWrapper(shim) << "\n";
+            } else {
+                body << generateMethod(cls, shim) << "\n";
+            }
+        }
+    }
+
+    for (const auto& func : CWrapperGenerator::bindableFunctions(functions)) {
+        if (func.signal_unsafe == signal_unsafe && configuredFeature(func.feature) == feature) {
+            body << generateWrapper(func) << "\n";
+        }
+    }
+
+    return body.str();
+}
+
+std::string GoFFIGenerator::generateGatedFile(
+    const std::vector<FFIFunction>& functions,
+    const std::vector<FFIClass>& classes,
+    const std::string& library_name,
+    const std::string& feature,
+    bool signal_unsafe
+) {
+    std::string body_text = generateGatedBody(functions, classes, feature, signal_unsafe);
+    std::string tag = feature.empty() ? "" : featureTag(feature);
+    // Features() lists a feature whether or not it binds anything
+    if (!feature.empty() && !signal_unsafe) {
+        body_text += "func init() {\n";
+        body_text += "\tfeatures = append(features, \"" + tag + "\")\n";
+        body_text += "}\n";
+    }
+    if (body_text.empty()) {
+        return "";
+    }
+
+    std::string constraint = !signal_unsafe ? tag
+                           : feature.empty() ? options_.signal_unsafe_tag
+                           : tag + " && " + options_.signal_unsafe_tag;
+    std::stringstream ss;
+
+    ss << "// Code generated by Hybrid Transpiler. DO NOT EDIT.\n\n";
+    ss << "//go:build " << constraint << "\n\n";
+    ss << "package " << packageName(options_, library_name) << "\n\n";
+
+    // cgo and linker flags come from the main file of the package, plus
+    // whatever a feature needs on top
+    ss << "/*\n";
+    if (!feature.empty() && !signal_unsafe) {
+        const FFIFeature& flags = options_.features.at(feature);
+        if (!flags.cgo_cflags.empty()) {
+            ss << "#cgo CFLAGS: " << flags.cgo_cflags << "\n";
+        }
+        if (!flags.cgo_ldflags.empty()) {
+            ss << "#cgo LDFLAGS: " << flags.cgo_ldflags << "\n";
+        }
+        if (!flags.cgo_cflags.empty() || !flags.cgo_ldflags.empty()) {
+            ss << "\n";
+        }
+    }
+    std::string directives = generateSmallStringDirectives(functions, classes, body_text);
+    if (!directives.empty()) {
+        ss << directives << "\n";
+    }
+    if (options_.decls_header) {
+        ss << "#include \"" << kDeclsHeader << "\"\n";
+    } else {
+        ss << generateDeclarations(functions, classes, library_name, false, [&](const FFIFunction& func) {
+            return func.signal_unsafe == signal_unsafe && configuredFeature(func.feature) == feature;
+        });
+    }
+    ss << "*/\n";
+    ss << "import \"C\"\n";
+    ss << goImports(body_text);
+
+    while (body_text.size() > 1 && body_text.substr(body_text.size() - 2) == "\n\n") {
+        body_text.pop_back();
+    }
+    ss << "\n" << body_text;
+
+    return ss.str();
+}
+
+std::string GoFFIGenerator::generateSignalUnsafeFile(
+    const std::vector<FFIFunction>& functions,
+    const std::vector<FFIClass>& all_classes,
+    const std::string& library_name,
+    const std::vector<FFIEnum>& enums,
+    const std::vector<FFIConstant>& constants
+) {
+    if (options_.signal_unsafe_policy == SignalUnsafePolicy::Exclude) {
+        return "";
+    }
+    enums_ = enums;
+    constants_ = constants;
+
+    std::vector<FFIClass> classes = LayoutEngine::resolveMirrors(all_classes, options_);
+    mirrors_ = mirroredNames(classes);
+    logs_ = installsLogger(functions);
+    resolveNames(functions, classes);
+    return generateGatedFile(functions, classes, library_name, "", true);
+}
+
+std::map<std::string, std::string> GoFFIGenerator::generateFeatureFiles(
+    const std::vector<FFIFunction>& functions,
+    const std::vector<FFIClass>& all_classes,
+    const std::string& library_name,
+    const std::vector<FFIEnum>& enums,
+    const std::vector<FFIConstant>& constants
+) {
+    enums_ = enums;
+    constants_ = constants;
+
+    std::vector<FFIClass> classes = LayoutEngine::resolveMirrors(all_classes, options_);
+    mirrors_ = mirroredNames(classes);
+    logs_ = installsLogger(functions);
+    resolveNames(functions, classes);
+
+    std::map<std::string, std::string> files;
+    for (const auto& feature : options_.features) {
+        // mylib_encryption.go rather than mylib_mylib_encryption.go
+        std::string tag = featureTag(feature.first);
+        std::string base = tag.compare(0, library_name.size() + 1, library_name + "_") == 0 ? tag
+                                                                                           : library_name + "_" + tag;
+        files[base + ".go"] = generateGatedFile(functions, classes, library_name, feature.first, false);
+        if (options_.signal_unsafe_policy == SignalUnsafePolicy::BuildTag) {
+            std::string gated = generateGatedFile(functions, classes, library_name, feature.first, true);
+            if (!gated.empty()) {
+                files[base + "_signal_unsafe.go"] = gated;
+            }
+        }
+    }
+    return files;
+}
+
+std::vector<std::pair<std::string, std::vector<std::string>>> GoFFIGenerator::targetGroups() const {
+    // Targets that share a build constraint share a file
+    std::vector<std::pair<std::string, std::vector<std::string>>> groups;
+    for (const auto& profile : LayoutEngine::profiles(options_)) {
+        std::string constraint = profile.goBuildConstraint();
+        auto group = std::find_if(groups.begin(), groups.end(),
+                                  [&constraint](const std::pair<std::string, std::vector<std::string>>& g) {
+                                      return g.first == constraint;
+                                  });
+        if (group == groups.end()) {
+            groups.push_back({constraint, {profile.triple}});
+        } else {
+            group->second.push_back(profile.triple);
+        }
+    }
+    return groups;
+}
+
+std::string GoFFIGenerator::targetConstraint(const std::vector<std::string>& targets) const {
+    std::vector<std::string> constraints;
+    for (const auto& group : targetGroups()) {
+        bool declared = std::any_of(group.second.begin(), group.second.end(), [&targets](const std::string& triple) {
+            return std::find(targets.begin(), targets.end(), triple) != targets.end();
+        });
+        if (declared) {
+            constraints.push_back(group.first);
+        }
+    }
+    std::string constraint;
+    for (const auto& each : constraints) {
+        constraint += (constraint.empty() ? "" : " || ") + (constraints.size() > 1 ? "(" + each + ")" : each);
+    }
+    return constraint;
+}
+
+std::string GoFFIGenerator::generateTargetBody(
+    const std::vector<FFIFunction>& functions,
+    const std::vector<FFIClass>& classes,
+    const std::pair<std::string, std::vector<std::string>>& group
+) {
+    // Go cannot tell apart targets that share a build constraint, so they must agree
+    auto covers = [&group](const std::vector<std::string>& targets, const std::string& what) {
+        size_t declared = std::count_if(group.second.begin(), group.second.end(), [&targets](const std::string& t) {
+            return std::find(targets.begin(), targets.end(), t) != targets.end();
+        });
+        if (group.first.empty() && declared > 0) {
+            throw std::invalid_argument(what + " is bound per target, but " + group.second[0] +
+                                        " has no Go build constraint");
+        }
+        if (declared > 0 && declared < group.second.size()) {
+            throw std::invalid_argument(what + " differs between target triples sharing the Go build constraint " +
+                                        group.first);
+        }
+        return !targets.empty() && declared > 0;
+    };
+    // The build tags of signal-unsafe functions and features cannot be combined with a target's
+    bool excluded = options_.signal_unsafe_policy == SignalUnsafePolicy::Exclude;
+    auto bound = [&](const FFIFunction& func) {
+        if (func.signal_unsafe && excluded) {
+            return false;
+        }
+        if (func.signal_unsafe || !configuredFeature(func.feature).empty()) {
+            throw std::invalid_argument(qualifiedName(func) + " differs between targets and cannot also be " +
+                                        (func.signal_unsafe ? "signal-unsafe" : "part of feature " + func.feature));
+        }
+        return true;
+    };
+
+    std::stringstream body;
+    std::set<std::string> result_structs;
+    auto declareResult = [&](const FFIFunction& func) {
+        std::string name = resultStructName(func);
+        if (!name.empty() && result_structs.insert(name).second) {
+            body << generateResultStruct(func) << "\n";
+        }
+    };
+
+    for (const auto& cls : classes) {
+        if (cls.is_mirrored) {
+            continue;
+        }
+        if (covers(cls.targets, cls.name)) {
+            if (!configuredFeature(cls.feature).empty()) {
+                throw std::invalid_argument(cls.name + " differs between targets and cannot also be part of feature " +
+                                            cls.feature);
+            }
+            for (const auto& shim : CWrapperGenerator::shimFunctions(cls)) {
+                if (shim.targets == cls.targets && bound(shim)) {
+                    declareResult(shim);
+                }
+            }
+            body << generateClassBinding(cls);
+        }
+        size_t ctor_index = 0;
+        for (const auto& shim : CWrapperGenerator::shimFunctions(cls)) {
+            size_t index = shim.is_constructor ? ctor_index++ : 0;
+            if (shim.targets == cls.targets || !shim.iteration.empty() || !covers(shim.targets, qualifiedName(shim)) ||
+                !bound(shim)) {
+                continue;
+            }
+            declareResult(shim);
+            if (shim.is_constructor) {
+                body << generateConstructor(cls, shim, index) << "\n";
+            } else if (shim.is_static) {
+                body << generateWrapper(shim) << "\n";
+            } else {
+                body << generateMethod(cls, shim) << "\n";
+            }
+        }
+    }
+
+    for (const auto& func : CWrapperGenerator::bindableFunctions(functions)) {
+        if (covers(func.targets, qualifiedName(func)) && bound(func)) {
+            declareResult(func);
+            body << generateWrapper(func) << "\n";
+        }
+    }
+
+    return body.str();
+}
+
+std::map<std::string, std::string> GoFFIGenerator::generateTargetFiles(
+    const std::vector<FFIFunction>& functions,
+    const std::vector<FFIClass>& all_classes,
+    const std::string& library_name,
+    const std::vector<FFIEnum>& enums,
+    const std::vector<FFIConstant>& constants
+) {
+    enums_ = enums;
+    constants_ = constants;
+
+    std::vector<FFIClass> classes = LayoutEngine::resolveMirrors(all_classes, options_);
+    mirrors_ = mirroredNames(classes);
+    logs_ = installsLogger(functions);
+    resolveNames(functions, classes);
+
+    std::map<std::string, std::string> files;
+    for (const auto& group : targetGroups()) {
+        std::string body_text = generateTargetBody(functions, classes, group);
+        if (body_text.empty()) {
+            continue;
+        }
+
+        std::stringstream ss;
+        ss << "// Code generated by Hybrid Transpiler. DO NOT EDIT.\n\n";
+        ss << "//go:build " << group.first << "\n\n";
+        ss << "package " << packageName(options_, library_name) << "\n\n";
+        ss << "/*\n";
+        std::string directives = generateSmallStringDirectives(functions, classes, body_text);
+        if (!directives.empty()) {
+            ss << directives << "\n";
+        }
+        if (options_.decls_header) {
+            ss << "#include \"" << kDeclsHeader << "\"\n";
+        } else {
+            const auto& triples = group.second;
+            ss << generateDeclarations(functions, classes, library_name, false, [&triples](const FFIFunction& func) {
+                return std::find(func.targets.begin(), func.targets.end(), triples[0]) != func.targets.end();
+            });
+        }
+        ss << "*/\n";
+        ss << "import \"C\"\n";
+        ss << goImports(body_text);
+
+        while (body_text.size() > 1 && body_text.substr(body_text.size() - 2) == "\n\n") {
+            body_text.pop_back();
+        }
+        ss << "\n" << body_text;
+
+        ABIProfile profile = ABIProfile::fromTriple(group.second[0]);
+        files[library_name + "_" + profile.go_os + "_" + profile.go_arch + ".go"] = ss.str();
+    }
+    return files;
+}
+
+std::string GoFFIGenerator::generateReport(
+    const std::vector<FFIFunction>& functions,
+    const std::vector<FFIClass>& all_classes,
+    const std::string& library_name,
+    const std::vector<FFIEnum>& enums,
+    const std::vector<FFIConstant>& constants
+) {
+    std::vector<FFIClass> classes = LayoutEngine::resolveMirrors(all_classes, options_);
+    enums_ = enums;
+    constants_ = constants;
+    mirrors_ = mirroredNames(classes);
+    logs_ = installsLogger(functions);
+    resolveNames(functions, classes);
+    std::vector<std::string> main_thread;
+    std::vector<std::string> signal_unsafe;
+    std::vector<std::string> preserved;
+    std::vector<std::string> unbound;
+    std::vector<std::string> required_defaults;
+    std::vector<std::string> cached;
+    std::vector<std::string> constrained;
+    std::vector<std::string> ambiguous;
+    std::map<std::string, std::string> interface_factories;
+    std::map<std::string, size_t> feature_symbols;
+
+    std::vector<std::string> result_structs;
+    std::vector<std::string> symbols;
+
+    auto listSymbol = [&symbols](const FFIFunction& func) {
+        symbols.push_back(functionKey(func) + (func.direct ? ", direct" : "") + " -> " +
+                          CWrapperGenerator::shimName(func));
+    };
+    auto classify = [&](const FFIFunction& func) {
+        if (!resultStructName(func).empty()) {
+            std::string entry = qualifiedName(func) + " -> " + resultStructName(func) + " {";
+            std::string separator = "";
+            for (const auto& param : func.parameters) {
+                if (isOutput(func, param)) {
+                    entry += separator + param.name + ": " + goName(outputFieldName(param.name));
+                    separator = ", ";
+                }
+            }
+            result_structs.push_back(entry + "}");
+        }
+        // Defaults before the trailing run the Go variant supplies
+        size_t first = firstDefault(func);
+        for (size_t i = 0; i < first; ++i) {
+            const auto& param = func.parameters[i];
+            if (!param.default_source.empty()) {
+                required_defaults.push_back(qualifiedName(func) + ": " + param.name + " = " + param.default_source);
+            }
+        }
+        for (const auto& param : func.parameters) {
+            std::string entry = qualifiedName(func) + "(" + param.name + "): ";
+            if (!param.constraint.text.empty()) {
+                constrained.push_back(entry + param.constraint.text + (func.unvalidated ? ", not checked" : ""));
+            } else if (!param.constraint.skipped.empty()) {
+                ambiguous.push_back(entry + param.constraint.skipped);
+            }
+        }
+        if (returnsFactoryError(func)) {
+            std::string& made_by = interface_factories[func.factory];
+            made_by += (made_by.empty() ? "" : ", ") + qualifiedName(func);
+        }
+        if (func.main_thread_only) {
+            main_thread.push_back(qualifiedName(func));
+        }
+        if (func.signal_unsafe) {
+            signal_unsafe.push_back(qualifiedName(func));
+        }
+        auto rule = options_.preserve_signals.find(qualifiedName(func));
+        if (rule != options_.preserve_signals.end()) {
+            std::string entry = qualifiedName(func);
+            for (size_t i = 0; i < rule->second.size(); ++i) {
+                entry += (i == 0 ? ", chaining " : ", ") + rule->second[i];
+            }
+            preserved.push_back(entry);
+        }
+        if (!configuredFeature(func.feature).empty()) {
+            feature_symbols[func.feature]++;
+        }
+    };
+    auto collectUnbound = [&unbound](const std::vector<FFIFunction>& candidates) {
+        for (const auto& func : candidates) {
+            if (!func.can_use_ffi) {
+                unbound.push_back(qualifiedName(func) + ": " + func.reason);
+            }
+        }
+    };
+
+    for (const auto& cls : classes) {
+        for (const auto& shim : CWrapperGenerator::shimFunctions(cls)) {
+            classify(shim);
+            if (!cls.is_mirrored) {
+                listSymbol(shim);
+            }
+        }
+        for (const auto& shim : CWrapperGenerator::directShims(cls)) {
+            if (!cls.is_mirrored) {
+                listSymbol(shim);
+            }
+        }
+        for (const auto& method : cachedMethods(cls)) {
+            std::string entry = qualifiedName(method);
+            std::string field = cacheField(method);
+            for (const auto& shim : CWrapperGenerator::shimFunctions(cls)) {
+                std::vector<std::string> cleared = clearedCaches(cls, shim);
+                if (std::find(cleared.begin(), cleared.end(), field) != cleared.end()) {
+                    entry += ", cleared by " + qualifiedName(shim);
+                }
+            }
+            cached.push_back(entry);
+        }
+        collectUnbound(cls.methods);
+        collectUnbound(cls.static_methods);
+    }
+    for (const auto& func : CWrapperGenerator::bindableFunctions(functions)) {
+        classify(func);
+        listSymbol(func);
+    }
+    collectUnbound(functions);
+
+    // What the platform configurations of the header disagree on
+    std::vector<std::string> split;
+    auto joined = [](const std::vector<std::string>& triples) {
+        std::string text;
+        for (const auto& triple : triples) {
+            text += (text.empty() ? "" : ", ") + triple;
+        }
+        return text;
+    };
+    auto listSplit = [&](const FFIFunction& func) {
+        std::string result = func.is_constructor || func.is_destructor ? ""
+                           : " -> " + (func.return_type.empty() ? std::string("void") : func.return_type);
+        split.push_back(functionKey(func) + result + ": " + joined(func.targets));
+    };
+    for (const auto& cls : all_classes) {
+        if (!cls.targets.empty()) {
+            split.push_back(cls.name + ": " + joined(cls.targets));
+        }
+        std::vector<std::pair<std::string, std::vector<std::string>>> layouts;
+        for (const auto& fields : cls.target_fields) {
+            std::string members;
+            for (const auto& field : fields.second) {
+                members += (members.empty() ? "" : ", ") + field.cpp_type + " " + field.name;
+            }
+            auto same = std::find_if(layouts.begin(), layouts.end(),
+                                     [&members](const std::pair<std::string, std::vector<std::string>>& layout) {
+                                         return layout.first == members;
+                                     });
+            if (same == layouts.end()) {
+                layouts.push_back({members, {fields.first}});
+            } else {
+                same->second.push_back(fields.first);
+            }
+        }
+        for (const auto& layout : layouts) {
+            split.push_back(cls.name + " {" + layout.first + "}: " + joined(layout.second));
+        }
+        for (const auto& shim : CWrapperGenerator::shimFunctions(cls)) {
+            if (shim.targets != cls.targets) {
+                listSplit(shim);
+            }
+        }
+    }
+    for (const auto& func : functions) {
+        if (!func.targets.empty()) {
+            listSplit(func);
+        }
+    }
+
+    // A feature without symbols usually means a misspelled macro
+    std::vector<std::string> features;
+    for (const auto& feature : options_.features) {
+        size_t count = feature_symbols[feature.first];
+        features.push_back(feature.first + ": " + std::to_string(count) + (count == 1 ? " symbol" : " symbols") +
+                           ", built only with -tags " + featureTag(feature.first));
+    }
+
+    std::stringstream ss;
+    ss << "FFI binding report for " << library_name << "\n";
+
+    auto section = [&ss](const std::string& title, const std::vector<std::string>& entries) {
+        if (entries.empty()) {
+            return;
+        }
+        ss << "\n" << title << " (" << entries.size() << "):\n";
+        for (const auto& entry : entries) {
+            ss << "  " << entry << "\n";
+        }
+    };
+    section("Main thread only, dispatched through RunOnMainThread", main_thread);
+    section(options_.signal_unsafe_policy == SignalUnsafePolicy::Exclude
+                ? "Signal unsafe, excluded from the Go bindings"
+                : "Signal unsafe, built only with -tags " + options_.signal_unsafe_tag,
+            signal_unsafe);
+    section("Go signal handlers restored after the call", preserved);
+    section("Not bound", unbound);
+    section("Optional features", features);
+    section("Declared differently per target, bound only where declared", split);
+    section("Output parameters returned as result structs", result_structs);
+    section("Default arguments required in Go", required_defaults);
+    section("String results cached in Go", cached);
+    std::vector<std::string> interfaces;
+    for (const auto& made : interface_factories) {
+        interfaces.push_back(made.first + ": " + made.second);
+    }
+    section("Abstract classes made by factories, whose nullptr is an error", interfaces);
+    section("Arguments checked against documented constraints", constrained);
+    section("Documented constraints left unchecked as ambiguous", ambiguous);
+    section("Renamed to avoid Go name collisions", renames_);
+    section("Shim symbols", symbols);
+
+    if (main_thread.empty() && signal_unsafe.empty() && unbound.empty() && features.empty() && split.empty()) {
+        ss << "\nEvery function is bound without restrictions.\n";
+    }
+
+    return ss.str();
+}
+
+} // namespace ffi
+} // namespace hybrid_transpiler
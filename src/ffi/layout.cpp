@@ -0,0 +1,458 @@
+/**
+ * @file layout.cpp
+ * @brief Struct layout engine for mirrored structs
+ *
+ * Differences between the two C++ ABIs that a Go mirror has to follow:
+ *   struct E1 {}; struct E2 {};
+ *   struct M : E1, E2 { int32_t x; };   // Itanium: 4 bytes, MSVC: 8
+ *   struct B { int32_t i; int8_t c; B(); };
+ *   struct D : B { int8_t d; };          // Itanium: 8 bytes, MSVC: 12
+ */
+
+#include "ffi.h"
+#include <algorithm>
+#include <map>
+#include <set>
+#include <stdexcept>
+
+namespace hybrid_transpiler {
+namespace ffi {
+
+namespace {
+
+size_t alignUp(size_t offset, size_t alignment) {
+    return (offset + alignment - 1) / alignment * alignment;
+}
+
+std::vector<std::string> splitTriple(const std::string& triple) {
+    std::vector<std::string> parts;
+    size_t start = 0;
+    while (start <= triple.size()) {
+        size_t end = triple.find('-', start);
+        if (end == std::string::npos) {
+            end = triple.size();
+        }
+        parts.push_back(triple.substr(start, end - start));
+        start = end + 1;
+    }
+    return parts;
+}
+
+bool startsWith(const std::string& text, const std::string& prefix) {
+    return text.compare(0, prefix.size(), prefix) == 0;
+}
+
+/**
+ * Size and alignment of a scalar member; false for types without a known layout.
+ */
+bool scalarLayout(const std::string& c_type, const ABIProfile& profile, size_t& size, size_t& alignment) {
+    static const std::map<std::string, size_t> fixed_sizes = {
+        {"char", 1}, {"signed char", 1}, {"unsigned char", 1},
+        {"int8_t", 1}, {"uint8_t", 1},
+        {"short", 2}, {"unsigned short", 2}, {"int16_t", 2}, {"uint16_t", 2},
+        {"int", 4}, {"unsigned int", 4}, {"int32_t", 4}, {"uint32_t", 4}, {"float", 4},
+    };
+
+    if (c_type == "bool" || c_type == "_Bool") {
+        size = alignment = profile.bool_size;
+        return true;
+    }
+    if (c_type == "long" || c_type == "unsigned long") {
+        size = alignment = profile.long_size;
+        return true;
+    }
+    if (c_type == "long long" || c_type == "unsigned long long" || c_type == "int64_t" ||
+        c_type == "uint64_t" || c_type == "double") {
+        size = 8;
+        alignment = profile.int64_alignment;
+        return true;
+    }
+    if (c_type == "size_t" || c_type == "ptrdiff_t" || c_type == "intptr_t" ||
+        c_type == "uintptr_t" || (!c_type.empty() && c_type.back() == '*')) {
+        size = alignment = profile.pointer_size;
+        return true;
+    }
+
+    auto it = fixed_sizes.find(c_type);
+    if (it == fixed_sizes.end()) {
+        return false;
+    }
+    size = alignment = it->second;
+    return true;
+}
+
+/**
+ * Itanium only reuses the tail padding of bases that are not POD for the
+ * purpose of layout; user-declared special members or virtuals or bases
+ * of their own disqualify a class.
+ */
+bool isLayoutPOD(const FFIClass& cls) {
+    if (cls.has_virtual_functions || cls.is_polymorphic || !cls.base_classes.empty()) {
+        return false;
+    }
+    for (const auto& method : cls.methods) {
+        if (method.is_constructor || method.is_destructor) {
+            return false;
+        }
+    }
+    return true;
+}
+
+/**
+ * Data members a target's configuration of the header declares
+ */
+const std::vector<FFIParameter>& targetFields(const FFIClass& cls, const ABIProfile& profile) {
+    auto it = cls.target_fields.find(profile.triple);
+    return it == cls.target_fields.end() ? cls.fields : it->second;
+}
+
+FFIFunction makeAccessor(const FFIClass& cls, const FFIParameter& field, bool setter) {
+    FFIFunction accessor;
+    accessor.class_name = cls.name;
+    accessor.cpp_namespace = cls.cpp_namespace;
+    accessor.is_method = true;
+    accessor.field_name = field.name;
+
+    if (setter) {
+        FFIParameter value;
+        value.name = "value";
+        value.c_type = field.c_type;
+        value.cpp_type = field.c_type;
+        accessor.name = "set_" + field.name;
+        accessor.parameters.push_back(value);
+    } else {
+        accessor.name = field.name;
+        accessor.is_const = true;
+        accessor.return_type = field.c_type;
+        accessor.c_return_type = field.c_type;
+    }
+    if (!cls.symbol_stem.empty()) {
+        accessor.c_name = cls.symbol_stem + "_" + accessor.name;
+    }
+    return accessor;
+}
+
+} // namespace
+
+ABIProfile ABIProfile::fromTriple(const std::string& triple) {
+    std::vector<std::string> parts = splitTriple(triple);
+    if (parts.size() < 2 || parts[0].empty()) {
+        throw std::invalid_argument("unrecognized target triple '" + triple + "'");
+    }
+
+    ABIProfile profile;
+    profile.triple = triple;
+    const std::string& arch = parts[0];
+
+    for (size_t i = 1; i < parts.size(); ++i) {
+        const std::string& part = parts[i];
+        if (part == "linux" || part == "windows" || part == "darwin" || part == "freebsd") {
+            profile.go_os = part;
+        } else if (startsWith(part, "mingw") || part == "win32") {
+            profile.go_os = "windows";
+        } else if (startsWith(part, "macos")) {
+            profile.go_os = "darwin";
+        } else if (startsWith(part, "msvc")) {
+            profile.kind = ABIKind::MSVC;
+        }
+    }
+
+    bool x86_32 = arch.size() == 4 && arch[0] == 'i' && arch.substr(2) == "86";
+    if (arch == "x86_64" || arch == "amd64") {
+        profile.go_arch = "amd64";
+    } else if (arch == "aarch64" || arch == "arm64") {
+        profile.go_arch = "arm64";
+    } else if (x86_32) {
+        profile.go_arch = "386";
+    } else if (startsWith(arch, "arm")) {
+        profile.go_arch = "arm";
+    } else if (arch == "powerpc64le") {
+        profile.go_arch = "ppc64le";
+    }
+
+    if (x86_32 || profile.go_arch == "arm" || arch == "powerpc" || arch == "wasm32") {
+        profile.pointer_size = 4;
+    }
+    // LLP64 on Windows, ILP32 on 32-bit targets, LP64 elsewhere
+    if (profile.go_os == "windows" || profile.pointer_size == 4) {
+        profile.long_size = 4;
+    }
+    if (x86_32 && profile.kind == ABIKind::Itanium && profile.go_os != "windows") {
+        profile.int64_alignment = 4;
+    }
+    if (arch == "powerpc" && profile.go_os == "darwin") {
+        profile.bool_size = 4;
+    }
+
+    return profile;
+}
+
+std::string ABIProfile::goBuildConstraint() const {
+    if (go_os.empty() || go_arch.empty()) {
+        return "";
+    }
+    return go_os + " && " + go_arch;
+}
+
+std::string ABIProfile::preprocessorCondition() const {
+    std::string condition = kind == ABIKind::MSVC ? "defined(_MSC_VER)" : "!defined(_MSC_VER)";
+
+    if (go_os == "windows") {
+        condition += " && defined(_WIN32)";
+    } else if (go_os == "linux") {
+        condition += " && defined(__linux__)";
+    } else if (go_os == "darwin") {
+        condition += " && defined(__APPLE__)";
+    } else if (go_os == "freebsd") {
+        condition += " && defined(__FreeBSD__)";
+    }
+
+    condition += pointer_size == 4 ? " && UINTPTR_MAX == 0xFFFFFFFFu" : " && UINTPTR_MAX > 0xFFFFFFFFu";
+    return condition;
+}
+
+std::vector<std::string> ABIProfile::predefinedMacros() const {
+    std::vector<std::string> macros;
+    bool wide = pointer_size == 8;
+
+    if (kind == ABIKind::MSVC) {
+        macros.push_back("_MSC_VER");
+        if (go_arch == "amd64") {
+            macros.insert(macros.end(), {"_M_X64", "_M_AMD64"});
+        } else if (go_arch == "386") {
+            macros.push_back("_M_IX86");
+        } else if (go_arch == "arm64") {
+            macros.push_back("_M_ARM64");
+        } else if (go_arch == "arm") {
+            macros.push_back("_M_ARM");
+        }
+    } else if (go_arch == "amd64") {
+        macros.insert(macros.end(), {"__x86_64__", "__amd64__"});
+    } else if (go_arch == "386") {
+        macros.push_back("__i386__");
+    } else if (go_arch == "arm64") {
+        macros.push_back("__aarch64__");
+    } else if (go_arch == "arm") {
+        macros.push_back("__arm__");
+    } else if (go_arch == "ppc64le") {
+        macros.push_back("__powerpc64__");
+    }
+
+    if (go_os == "windows") {
+        macros.push_back("_WIN32");
+        if (wide) {
+            macros.push_back("_WIN64");
+        }
+        if (kind == ABIKind::Itanium) {
+            macros.push_back("__MINGW32__");
+            if (wide) {
+                macros.push_back("__MINGW64__");
+            }
+        }
+    } else if (go_os == "linux") {
+        macros.insert(macros.end(), {"__linux__", "__linux", "__unix__", "__unix"});
+    } else if (go_os == "darwin") {
+        macros.insert(macros.end(), {"__APPLE__", "__MACH__"});
+    } else if (go_os == "freebsd") {
+        macros.insert(macros.end(), {"__FreeBSD__", "__unix__", "__unix"});
+    }
+    if (kind == ABIKind::Itanium && long_size == 8) {
+        macros.push_back("__LP64__");
+    }
+    return macros;
+}
+
+bool ABIProfile::isPlatformMacro(const std::string& macro) {
+    static const std::set<std::string> macros = {
+        "_MSC_VER", "_M_X64", "_M_AMD64", "_M_IX86", "_M_ARM64", "_M_ARM",
+        "__x86_64__", "__amd64__", "__i386__", "__aarch64__", "__arm__", "__powerpc64__",
+        "_WIN32", "_WIN64", "__MINGW32__", "__MINGW64__",
+        "__linux__", "__linux", "__unix__", "__unix", "__APPLE__", "__MACH__", "__FreeBSD__", "__LP64__",
+    };
+    return macros.count(macro) > 0;
+}
+
+const FFIClass* LayoutEngine::findClass(const std::string& name) const {
+    for (const auto& cls : classes_) {
+        if (cls.name == name) {
+            return &cls;
+        }
+    }
+    return nullptr;
+}
+
+std::vector<FFIParameter> LayoutEngine::memberFields(const FFIClass& cls) const {
+    std::vector<FFIParameter> members;
+    for (const auto& base_name : cls.base_classes) {
+        if (const FFIClass* base = findClass(base_name)) {
+            for (const auto& field : memberFields(*base)) {
+                members.push_back(field);
+            }
+        }
+    }
+    for (auto field : cls.fields) {
+        if (field.c_type.empty()) {
+            field.c_type = field.cpp_type;
+        }
+        members.push_back(field);
+    }
+    return members;
+}
+
+StructLayout LayoutEngine::layout(const FFIClass& cls, const ABIProfile& profile) const {
+    StructLayout result;
+    size_t offset = 0;
+    bool placed_empty_base = false;
+
+    for (const auto& base_name : cls.base_classes) {
+        const FFIClass* base = findClass(base_name);
+        if (!base) {
+            result.reason = "unknown base class " + base_name;
+            return result;
+        }
+
+        StructLayout base_layout = layout(*base, profile);
+        if (!base_layout.valid) {
+            return base_layout;
+        }
+
+        if (base_layout.is_empty) {
+            // Itanium overlays every empty base at offset 0; MSVC does so only
+            // for the first and gives later ones a byte each
+            if (profile.kind == ABIKind::MSVC && placed_empty_base) {
+                offset += 1;
+            }
+            placed_empty_base = true;
+            continue;
+        }
+
+        offset = alignUp(offset, base_layout.alignment);
+        for (auto field : base_layout.fields) {
+            field.offset += offset;
+            result.fields.push_back(field);
+        }
+        bool reuse_tail = profile.kind == ABIKind::Itanium && !isLayoutPOD(*base);
+        offset += reuse_tail ? base_layout.data_size : base_layout.size;
+        result.alignment = std::max(result.alignment, base_layout.alignment);
+    }
+
+    for (const auto& field : targetFields(cls, profile)) {
+        std::string c_type = field.c_type.empty() ? field.cpp_type : field.c_type;
+        size_t size = 0;
+        size_t alignment = 1;
+        if (!scalarLayout(c_type, profile, size, alignment)) {
+            result.reason = "no known layout for " + cls.name + "::" + field.name + " (" + c_type + ")";
+            return result;
+        }
+
+        offset = alignUp(offset, alignment);
+        result.fields.push_back({field.name, c_type, offset, size});
+        offset += size;
+        result.alignment = std::max(result.alignment, alignment);
+    }
+
+    result.valid = true;
+    result.is_empty = result.fields.empty();
+    result.data_size = result.is_empty ? 0 : offset;
+    result.size = std::max<size_t>(alignUp(offset, result.alignment), 1);
+    return result;
+}
+
+bool LayoutEngine::sameLayout(const StructLayout& a, const StructLayout& b) {
+    if (!a.valid || !b.valid || a.size != b.size || a.fields.size() != b.fields.size()) {
+        return false;
+    }
+    for (size_t i = 0; i < a.fields.size(); ++i) {
+        if (a.fields[i].name != b.fields[i].name || a.fields[i].offset != b.fields[i].offset ||
+            a.fields[i].size != b.fields[i].size) {
+            return false;
+        }
+    }
+    return true;
+}
+
+std::vector<ABIProfile> LayoutEngine::profiles(const FFIOptions& options) {
+    std::vector<ABIProfile> result;
+    for (const auto& triple : options.target_triples) {
+        result.push_back(ABIProfile::fromTriple(triple));
+    }
+    return result;
+}
+
+bool LayoutEngine::isDeclared(const FFIClass& cls, const ABIProfile& profile) {
+    return cls.targets.empty() ||
+           std::find(cls.targets.begin(), cls.targets.end(), profile.triple) != cls.targets.end();
+}
+
+bool LayoutEngine::isPortable(const FFIClass& cls, const std::vector<ABIProfile>& profiles) const {
+    // A struct some configurations lack or declare differently needs a mirror per target
+    if (profiles.empty() || !cls.targets.empty() || !cls.target_fields.empty()) {
+        return false;
+    }
+
+    StructLayout first = layout(cls, profiles[0]);
+    for (size_t i = 1; i < profiles.size(); ++i) {
+        if (!sameLayout(first, layout(cls, profiles[i]))) {
+            return false;
+        }
+    }
+    return first.valid;
+}
+
+bool LayoutEngine::isSplittable(const FFIClass& cls, const std::vector<ABIProfile>& profiles) const {
+    // Targets sharing a build constraint (windows/amd64 for both MSVC and
+    // MinGW) must agree, since Go cannot tell them apart
+    std::map<std::string, std::pair<bool, StructLayout>> by_constraint;
+    bool declared_anywhere = false;
+    for (const auto& profile : profiles) {
+        std::string constraint = profile.goBuildConstraint();
+        bool declared = isDeclared(cls, profile);
+        StructLayout current = declared ? layout(cls, profile) : StructLayout{};
+        if (constraint.empty() || (declared && !current.valid)) {
+            return false;
+        }
+
+        auto it = by_constraint.find(constraint);
+        if (it != by_constraint.end() &&
+            (it->second.first != declared || (declared && !sameLayout(it->second.second, current)))) {
+            return false;
+        }
+        by_constraint[constraint] = {declared, current};
+        declared_anywhere = declared_anywhere || declared;
+    }
+    return declared_anywhere;
+}
+
+std::vector<FFIClass> LayoutEngine::resolveMirrors(const std::vector<FFIClass>& classes, const FFIOptions& options) {
+    LayoutEngine engine(classes);
+    std::vector<ABIProfile> target_profiles = profiles(options);
+    std::vector<FFIClass> resolved;
+
+    for (const auto& cls : classes) {
+        if (!cls.is_mirrored || engine.isPortable(cls, target_profiles) ||
+            (options.layout_mismatch == LayoutMismatchPolicy::Split && engine.isSplittable(cls, target_profiles))) {
+            resolved.push_back(cls);
+            continue;
+        }
+
+        FFIClass demoted = cls;
+        demoted.is_mirrored = false;
+        ABIProfile scalar_types;
+        for (const auto& field : engine.memberFields(cls)) {
+            // Members with no C representation get no accessor
+            size_t size = 0;
+            size_t alignment = 1;
+            if (!scalarLayout(field.c_type, scalar_types, size, alignment)) {
+                continue;
+            }
+            demoted.methods.push_back(makeAccessor(cls, field, false));
+            demoted.methods.push_back(makeAccessor(cls, field, true));
+        }
+        resolved.push_back(demoted);
+    }
+
+    return resolved;
+}
+
+} // namespace ffi
+} // namespace hybrid_transpiler
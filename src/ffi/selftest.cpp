@@ -0,0 +1,483 @@
+/**
+ * @file selftest.cpp
+ * @brief End-to-end fixture runner behind `hybrid-transpiler selftest`
+ *
+ * Each fixture goes through the same steps a user of the bindings takes:
+ * the header is analyzed, the shim and Go package are generated, the library
+ * and shim are compiled into a shared library, and go test runs against it.
+ *
+ * Work directory layout, matching the default include_dir/lib_dir options:
+ *   include/  fixture sources, <library>_wrapper.h, <library>_shim.cpp,
+ *             objects of module interface units and, with
+ *             FFIOptions::bindings_header, bindings.h
+ *   lib/      lib<library>.so
+ *   go/       generated package (with FFIOptions::decls_header, generated_decls.h
+ *             too), go.mod and the fixture's *_test.go files
+ */
+
+#include "ffi.h"
+#include <algorithm>
+#include <cstdlib>
+#include <filesystem>
+#include <fstream>
+#include <sstream>
+#include <stdexcept>
+
+namespace hybrid_transpiler {
+namespace ffi {
+
+namespace {
+
+namespace fs = std::filesystem;
+
+std::string trim(const std::string& text) {
+    size_t start = text.find_first_not_of(" \t\r");
+    if (start == std::string::npos) {
+        return "";
+    }
+    return text.substr(start, text.find_last_not_of(" \t\r") - start + 1);
+}
+
+std::vector<std::string> splitWords(const std::string& text) {
+    std::vector<std::string> words;
+    std::istringstream in(text);
+    std::string word;
+    while (in >> word) {
+        words.push_back(word);
+    }
+    return words;
+}
+
+std::string readFile(const fs::path& path) {
+    std::ifstream in(path);
+    std::stringstream ss;
+    ss << in.rdbuf();
+    return ss.str();
+}
+
+void writeFile(const fs::path& path, const std::string& content) {
+    std::ofstream out(path);
+    out << content;
+}
+
+std::string shellQuote(const std::string& text) {
+    std::string quoted = "'";
+    for (char c : text) {
+        quoted += c == '\'' ? std::string("'\\''") : std::string(1, c);
+    }
+    return quoted + "'";
+}
+
+std::string toolFromEnv(const char* variable, const std::string& fallback) {
+    const char* value = std::getenv(variable);
+    return value && *value ? value : fallback;
+}
+
+/**
+ * Run command in dir, capturing stdout and stderr into output
+ */
+bool runCaptured(const fs::path& dir, const std::string& command, std::string& output) {
+    fs::path log = dir / "command.log";
+    std::string line = "cd " + shellQuote(dir.string()) + " && " + command + " > " +
+                       shellQuote(log.string()) + " 2>&1";
+    bool ok = std::system(line.c_str()) == 0;
+    output = readFile(log);
+    fs::remove(log);
+    return ok;
+}
+
+} // namespace
+
+SelfTestFixture SelfTestRunner::loadFixture(const std::string& dir) {
+    fs::path path(dir);
+    fs::path config = path / "fixture.conf";
+    if (!fs::is_regular_file(config)) {
+        throw std::runtime_error("missing " + config.string());
+    }
+
+    SelfTestFixture fixture;
+    fixture.name = path.filename().string();
+    if (fixture.name.empty()) {
+        fixture.name = path.parent_path().filename().string();
+    }
+    fixture.path = path.string();
+
+    std::istringstream lines(readFile(config));
+    std::string line;
+    int line_number = 0;
+    while (std::getline(lines, line)) {
+        line_number++;
+        line = trim(line.substr(0, line.find('#')));
+        if (line.empty()) {
+            continue;
+        }
+
+        size_t equals = line.find('=');
+        if (equals == std::string::npos) {
+            throw std::runtime_error(config.string() + ":" + std::to_string(line_number) +
+                                     ": expected key = value");
+        }
+        std::string key = trim(line.substr(0, equals));
+        std::string value = trim(line.substr(equals + 1));
+        if (key == "library") {
+            fixture.library_name = value;
+        } else if (key == "modules") {
+            fixture.modules = splitWords(value);
+        } else if (key == "sources") {
+            fixture.sources = splitWords(value);
+        } else if (key == "cxxflags") {
+            fixture.cxxflags = value;
+        } else if (key == "validate_enums" && (value == "true" || value == "false")) {
+            fixture.validate_enums = value == "true";
+        } else if (key == "validate_enums") {
+            throw std::runtime_error(config.string() + ":" + std::to_string(line_number) +
+                                     ": validate_enums must be true or false");
+        } else if (key == "cached_strings" && (value == "true" || value == "false")) {
+            fixture.cached_strings = value == "true";
+        } else if (key == "cached_strings") {
+            throw std::runtime_error(config.string() + ":" + std::to_string(line_number) +
+                                     ": cached_strings must be true or false");
+        } else if (key == "scratch_arena" && (value == "true" || value == "false")) {
+            fixture.scratch_arena = value == "true";
+        } else if (key == "windows_dll_import" && (value == "true" || value == "false")) {
+            fixture.windows_dll_import = value == "true";
+        } else if (key == "windows_dll_import") {
+            throw std::runtime_error(config.string() + ":" + std::to_string(line_number) +
+                                     ": windows_dll_import must be true or false");
+        } else if (key == "scratch_arena") {
+            throw std::runtime_error(config.string() + ":" + std::to_string(line_number) +
+                                     ": scratch_arena must be true or false");
+        } else if (key == "bounds_check" && (value == "true" || value == "false")) {
+            fixture.bounds_check = value == "true";
+        } else if (key == "bounds_check") {
+            throw std::runtime_error(config.string() + ":" + std::to_string(line_number) +
+                                     ": bounds_check must be true or false");
+        } else if (key == "race" && (value == "true" || value == "false")) {
+            fixture.race = value == "true";
+        } else if (key == "race") {
+            throw std::runtime_error(config.string() + ":" + std::to_string(line_number) +
+                                     ": race must be true or false");
+        } else if (key == "small_string_size" && !value.empty() && value.size() <= 5 &&
+                   value.find_first_not_of("0123456789") == std::string::npos) {
+            fixture.small_string_size = std::stoul(value);
+        } else if (key == "small_string_size") {
+            throw std::runtime_error(config.string() + ":" + std::to_string(line_number) +
+                                     ": small_string_size must be a byte count");
+        } else if (key == "symbol_prefix") {
+            fixture.symbol_prefix = value;
+        } else if ((key == "cgo_prologue" || key == "cgo_epilogue" || key == "shim_prologue") &&
+                   fs::is_regular_file(path / value)) {
+            // Read from a file, as a value ends at the first '#'
+            std::string text = readFile(path / value);
+            if (key == "cgo_prologue") {
+                fixture.cgo_prologue = text;
+            } else if (key == "cgo_epilogue") {
+                fixture.cgo_epilogue = text;
+            } else {
+                fixture.shim_prologue = text;
+            }
+        } else if (key == "cgo_prologue" || key == "cgo_epilogue" || key == "shim_prologue") {
+            throw std::runtime_error(config.string() + ":" + std::to_string(line_number) + ": " + key +
+                                     " must name a file of the fixture, not '" + value + "'");
+        } else if (key == "default_exception_behavior" && (value == "abort" || value == "panic")) {
+            fixture.default_exception_behavior = value == "panic" ? ExceptionBehavior::Panic
+                                                                  : ExceptionBehavior::Abort;
+        } else if (key == "default_exception_behavior") {
+            throw std::runtime_error(config.string() + ":" + std::to_string(line_number) +
+                                     ": default_exception_behavior must be abort or panic");
+        } else if (key == "validation_failure" && (value == "error" || value == "panic")) {
+            fixture.validation_failure = value == "panic" ? ValidationFailure::Panic : ValidationFailure::Error;
+        } else if (key == "validation_failure") {
+            throw std::runtime_error(config.string() + ":" + std::to_string(line_number) +
+                                     ": validation_failure must be error or panic");
+        } else if (key == "constraint" && splitWords(value).size() > 1) {
+            // The phrasing is the rest of the line, spaces and all
+            std::istringstream words(value);
+            std::string function;
+            std::string param;
+            words >> function >> param;
+            std::string phrasing;
+            std::getline(words, phrasing);
+            fixture.constraints[function][param] = trim(phrasing);
+        } else if (key == "constraint") {
+            throw std::runtime_error(config.string() + ":" + std::to_string(line_number) +
+                                     ": constraint must name a function and a parameter, then the constraint");
+        } else if (key == "unvalidated") {
+            for (const auto& word : splitWords(value)) {
+                fixture.unvalidated.insert(word);
+            }
+        } else if ((key == "invalidating_errors" || key == "reconnect_factory") && splitWords(value).size() > 1) {
+            std::vector<std::string> words = splitWords(value);
+            HandleInvalidation& rule = fixture.handle_invalidation[words[0]];
+            if (key == "invalidating_errors") {
+                rule.errors.assign(words.begin() + 1, words.end());
+            } else if (words.size() == 2) {
+                rule.factory = words[1];
+            } else {
+                throw std::runtime_error(config.string() + ":" + std::to_string(line_number) +
+                                         ": reconnect_factory must name a class and one factory");
+            }
+        } else if (key == "invalidating_errors" || key == "reconnect_factory") {
+            throw std::runtime_error(config.string() + ":" + std::to_string(line_number) +
+                                     ": " + key + " must name a class, then its " +
+                                     (key == "reconnect_factory" ? "factory" : "errors"));
+        } else if (key == "delete_invalidated") {
+            for (const auto& word : splitWords(value)) {
+                fixture.handle_invalidation[word].delete_invalidated = true;
+            }
+        } else if (key == "payload_tag" && (splitWords(value).size() == 2 || splitWords(value).size() == 3)) {
+            std::vector<std::string> words = splitWords(value);
+            TaggedPayload& payload = fixture.tagged_payloads[words[0]];
+            payload.tag = words[1];
+            payload.size = words.size() == 3 ? words[2] : "";
+        } else if (key == "payload_type" && splitWords(value).size() == 3) {
+            std::vector<std::string> words = splitWords(value);
+            fixture.tagged_payloads[words[0]].types[words[1]] = words[2];
+        } else if (key == "payload_tag" || key == "payload_type") {
+            throw std::runtime_error(config.string() + ":" + std::to_string(line_number) + ": " + key +
+                                     " must name a method, then " +
+                                     (key == "payload_tag" ? "its tag method and optional size method"
+                                                           : "a tag and its type"));
+        } else if (key == "preserve_signals" && !splitWords(value).empty()) {
+            std::vector<std::string> words = splitWords(value);
+            fixture.preserve_signals[words[0]].assign(words.begin() + 1, words.end());
+        } else if (key == "preserve_signals") {
+            throw std::runtime_error(config.string() + ":" + std::to_string(line_number) +
+                                     ": preserve_signals must name a function, then its chained signals");
+        } else if (key == "features") {
+            for (const auto& word : splitWords(value)) {
+                size_t colon = word.find(':');
+                fixture.features[word.substr(0, colon)] = colon == std::string::npos ? "" : word.substr(colon + 1);
+            }
+        } else {
+            throw std::runtime_error(config.string() + ":" + std::to_string(line_number) +
+                                     ": unknown key '" + key + "'");
+        }
+    }
+
+    if (fixture.library_name.empty()) {
+        throw std::runtime_error(config.string() + ": library is required");
+    }
+    if (fixture.modules.empty() && !fs::is_regular_file(path / (fixture.library_name + ".h"))) {
+        throw std::runtime_error(config.string() + ": " + fixture.library_name + ".h not found");
+    }
+    for (const auto& unit : fixture.modules) {
+        if (!fs::is_regular_file(path / unit)) {
+            throw std::runtime_error(config.string() + ": module interface unit " + unit + " not found");
+        }
+    }
+    if (fixture.sources.empty()) {
+        for (const auto& entry : fs::directory_iterator(path)) {
+            if (entry.path().extension() == ".cpp") {
+                fixture.sources.push_back(entry.path().filename().string());
+            }
+        }
+        std::sort(fixture.sources.begin(), fixture.sources.end());
+    }
+    return fixture;
+}
+
+SelfTestResult SelfTestRunner::run(const SelfTestFixture& fixture) {
+    SelfTestResult result;
+    result.fixture = fixture.name;
+
+    fs::path work = fs::temp_directory_path() / ("hybrid_selftest_" + fixture.name);
+    fs::remove_all(work);
+    fs::create_directories(work / "include");
+    fs::create_directories(work / "lib");
+    fs::create_directories(work / "go");
+    result.work_dir = work.string();
+
+    // The shim includes <library>.h, so sources are compiled next to it
+    for (const auto& entry : fs::directory_iterator(fixture.path)) {
+        if (!entry.is_regular_file() || entry.path().filename() == "fixture.conf") {
+            continue;
+        }
+        bool is_go = entry.path().extension() == ".go";
+        fs::copy_file(entry.path(), work / (is_go ? "go" : "include") / entry.path().filename(),
+                      fs::copy_options::overwrite_existing);
+    }
+
+    FFIOptions options = options_;
+    options.include_dir = "../include";
+    options.lib_dir = "../lib";
+    // Compiled with the fixture's tests, so a broken benchmark fails the run
+    options.benchmarks = true;
+    options.validate_enums = options.validate_enums || fixture.validate_enums;
+    options.cached_strings = options.cached_strings || fixture.cached_strings;
+    options.bounds_check = options.bounds_check || fixture.bounds_check;
+    options.scratch_arena = options.scratch_arena || fixture.scratch_arena;
+    options.windows_dll_import = options.windows_dll_import || fixture.windows_dll_import;
+    if (fixture.small_string_size) {
+        options.small_string_size = fixture.small_string_size;
+    }
+    if (!fixture.symbol_prefix.empty()) {
+        options.symbol_prefix = fixture.symbol_prefix;
+    }
+    if (!fixture.cgo_prologue.empty()) {
+        options.cgo_prologue = fixture.cgo_prologue;
+    }
+    if (!fixture.cgo_epilogue.empty()) {
+        options.cgo_epilogue = fixture.cgo_epilogue;
+    }
+    if (!fixture.shim_prologue.empty()) {
+        options.shim_prologue = fixture.shim_prologue;
+    }
+    if (fixture.default_exception_behavior == ExceptionBehavior::Panic) {
+        options.default_exception_behavior = ExceptionBehavior::Panic;
+    }
+    if (fixture.validation_failure == ValidationFailure::Panic) {
+        options.validation_failure = ValidationFailure::Panic;
+    }
+    for (const auto& function : fixture.constraints) {
+        for (const auto& param : function.second) {
+            options.constraints[function.first][param.first] = param.second;
+        }
+    }
+    options.unvalidated.insert(fixture.unvalidated.begin(), fixture.unvalidated.end());
+    for (const auto& feature : fixture.features) {
+        options.features[feature.first].tag = feature.second;
+    }
+    for (const auto& rule : fixture.handle_invalidation) {
+        options.handle_invalidation[rule.first] = rule.second;
+    }
+    for (const auto& payload : fixture.tagged_payloads) {
+        options.tagged_payloads[payload.first] = payload.second;
+    }
+    for (const auto& rule : fixture.preserve_signals) {
+        options.preserve_signals[rule.first] = rule.second;
+    }
+    const std::string& library = fixture.library_name;
+
+    try {
+        // Interface units follow the header, as the shim imports them after including it
+        fs::path header = fs::path(fixture.path) / (library + ".h");
+        options.library_header = fs::is_regular_file(header);
+        std::string source = options.library_header ? readFile(header) : "";
+        for (const auto& unit : fixture.modules) {
+            source += "\n" + readFile(fs::path(fixture.path) / unit);
+        }
+        FFIModule module = FFIAnalyzer(options).analyzeSource(source, library);
+        options.modules = module.modules;
+
+        CWrapperGenerator c_generator(options);
+        writeFile(work / "include" / (library + "_wrapper.h"),
+                  c_generator.generateHeader(module.functions, module.classes, library));
+        writeFile(work / "include" / (library + "_shim.cpp"),
+                  c_generator.generateImplementation(module.functions, module.classes, library));
+        if (options.bindings_header) {
+            writeFile(work / "include" / "bindings.h",
+                      c_generator.generateBindingsHeader(module.functions, module.classes, library));
+        }
+
+        for (const auto& file : FFIGenerator(options).generateGoFiles(source, library)) {
+            if (!file.second.empty()) {
+                writeFile(work / "go" / file.first, file.second);
+            }
+        }
+        writeFile(work / "go" / "go.mod", "module selftest/" + fixture.name + "\n\ngo 1.18\n");
+    } catch (const std::exception& e) {
+        result.stage = "generate";
+        result.output = e.what();
+        return result;
+    }
+
+    // bindings.h stands alone in both languages and agrees with the shim it declares
+    std::string cxx = toolFromEnv("CXX", "c++");
+    if (options.bindings_header) {
+        std::string checks = toolFromEnv("CC", "cc") + " -std=c99 -Wall -Werror -fsyntax-only -x c bindings.h && " +
+                             cxx + " " + fixture.cxxflags + " -Wall -Werror -fsyntax-only -x c++ bindings.h";
+        if (!runCaptured(work / "include", checks, result.output)) {
+            result.stage = "compile";
+            return result;
+        }
+    }
+
+    // Interface units are compiled on their own first: the sources and shim
+    // importing them need their compiled interfaces, and a forced include
+    // would come before their module declaration
+    std::string objects;
+    for (const auto& unit : fixture.modules) {
+        std::string object = fs::path(unit).stem().string() + ".o";
+        std::string compile_unit = cxx + " " + fixture.cxxflags + " -fPIC -I. -c -x c++ " + shellQuote(unit) +
+                                   " -o " + shellQuote(object);
+        if (!runCaptured(work / "include", compile_unit, result.output)) {
+            result.stage = "compile";
+            return result;
+        }
+        objects += " " + shellQuote(object);
+    }
+
+    std::string compile = cxx + " " + fixture.cxxflags + " -fPIC -shared -I.";
+    if (options.bindings_header) {
+        compile += " -include bindings.h";
+    }
+    for (const auto& source : fixture.sources) {
+        compile += " " + shellQuote(source);
+    }
+    compile += " " + shellQuote(library + "_shim.cpp") + objects + " -o " +
+               shellQuote("../lib/lib" + library + ".so");
+    if (!runCaptured(work / "include", compile, result.output)) {
+        result.stage = "compile";
+        return result;
+    }
+
+    // Only the features compiled into the library are built into the package
+    std::string tags;
+    std::vector<std::string> flags = splitWords(fixture.cxxflags);
+    for (const auto& feature : options.features) {
+        bool defined = std::any_of(flags.begin(), flags.end(), [&feature](const std::string& flag) {
+            return flag == "-D" + feature.first || flag.compare(0, feature.first.size() + 3,
+                                                                "-D" + feature.first + "=") == 0;
+        });
+        if (defined) {
+            tags += (tags.empty() ? "" : ",") + GoFFIGenerator(options).featureTag(feature.first);
+        }
+    }
+
+    std::string lib_dir = shellQuote((work / "lib").string());
+    std::string go_test = "LD_LIBRARY_PATH=" + lib_dir + "${LD_LIBRARY_PATH:+:$LD_LIBRARY_PATH} " +
+                          toolFromEnv("GO", "go") + " test -count=1" + (fixture.race ? " -race" : "") +
+                          (tags.empty() ? "" : " -tags " + shellQuote(tags)) + " ./...";
+    if (!runCaptured(work / "go", go_test, result.output)) {
+        result.stage = "go test";
+        return result;
+    }
+
+    result.passed = true;
+    result.output.clear();
+    result.work_dir.clear();
+    fs::remove_all(work);
+    return result;
+}
+
+std::vector<SelfTestResult> SelfTestRunner::runAll(const std::string& fixtures_dir) {
+    if (!fs::is_directory(fixtures_dir)) {
+        throw std::runtime_error("fixtures directory not found: " + fixtures_dir);
+    }
+
+    std::vector<fs::path> dirs;
+    for (const auto& entry : fs::directory_iterator(fixtures_dir)) {
+        if (entry.is_directory() && fs::exists(entry.path() / "fixture.conf")) {
+            dirs.push_back(entry.path());
+        }
+    }
+    std::sort(dirs.begin(), dirs.end());
+
+    std::vector<SelfTestResult> results;
+    for (const auto& dir : dirs) {
+        try {
+            results.push_back(run(loadFixture(dir.string())));
+        } catch (const std::runtime_error& e) {
+            SelfTestResult result;
+            result.fixture = dir.filename().string();
+            result.stage = "config";
+            result.output = e.what();
+            results.push_back(result);
+        }
+    }
+    return results;
+}
+
+} // namespace ffi
+} // namespace hybrid_transpiler
@@ -1,8 +1,12 @@
 #include "transpiler.h"
+#include "ffi.h"
+#include <algorithm>
+#include <cctype>
 #include <iostream>
 #include <string>
 #include <vector>
 #include <fstream>
+#include <sstream>
 
 void printUsage(const char* program_name) {
     std::cout << "Hybrid Transpiler - Convert C++ code to modern, safe languages (Rust/Go)\n\n";
@@ -15,18 +19,67 @@ void printUsage(const char* program_name) {
     std::cout << "  • Threading → Safe concurrency\n";
     std::cout << "  • Async/Coroutines → async/await\n\n";
 
-    std::cout << "Usage: " << program_name << " [options]\n\n";
+    std::cout << "Usage: " << program_name << " [options]\n";
+    std::cout << "       " << program_name << " selftest --fixtures <dir> [--validate-enums] [--bindings-header]\n";
+    std::cout << "                                  [--thread-safe] [--cached-strings] [--decls-header]\n";
+    std::cout << "                                  [--default-exception-behavior=panic|abort]\n";
+    std::cout << "                                  [--small-strings=N] [--symbol-prefix=P]\n";
+    std::cout << "                                  [--legacy-symbols] [--bounds-check]\n";
+    std::cout << "                                  [--validation-failure=error|panic] [--scratch-arena]\n";
+    std::cout << "                                  [--dll-import] [--cgo-prologue=FILE]\n";
+    std::cout << "                                  [--cgo-epilogue=FILE] [--shim-prologue=FILE]\n\n";
 
     std::cout << "Options:\n";
     std::cout << "  -i, --input <file>      Input C++ source file (required)\n";
     std::cout << "  -o, --output <file>     Output file path (auto-generated if omitted)\n";
-    std::cout << "  -t, --target <lang>     Target language: rust, go [default: rust]\n";
+    std::cout << "  -t, --target <lang>     Target language: rust, go, c-header [default: rust]\n";
     std::cout << "  -O, --opt-level <N>     Optimization level 0-3 [default: 0]\n";
     std::cout << "                          0 = readable, 1 = balanced,\n";
     std::cout << "                          2 = optimized, 3 = aggressive\n";
     std::cout << "  --no-safety-checks      Disable safety checks\n";
     std::cout << "  --no-comments           Don't preserve comments\n";
     std::cout << "  --gen-tests             Generate test cases\n";
+    std::cout << "  --def                   With c-header, also write a Windows .def file\n";
+    std::cout << "  --symbol-prefix=P       With c-header, single-file or selftest, start every\n";
+    std::cout << "                          shim symbol with P instead of the library name\n";
+    std::cout << "  --legacy-symbols        With c-header, single-file or selftest, keep the old\n";
+    std::cout << "                          unprefixed <Class>_<method> shim names\n";
+    std::cout << "  --dll-import            With c-header, single-file or selftest, declare the\n";
+    std::cout << "                          shims __declspec(dllimport) for a Windows DLL\n";
+    std::cout << "  --cgo-prologue=FILE     With single-file or selftest, insert the text of\n";
+    std::cout << "                          FILE in the cgo preamble before its #includes\n";
+    std::cout << "  --cgo-epilogue=FILE     With single-file or selftest, append the text of\n";
+    std::cout << "                          FILE to the cgo preamble\n";
+    std::cout << "  --shim-prologue=FILE    With c-header, single-file or selftest, insert the\n";
+    std::cout << "                          text of FILE in the shim before its #includes\n";
+    std::cout << "  --single-file <file>    Write the cgo bindings of the input, preamble, types,\n";
+    std::cout << "                          wrappers and support code, to one Go file, and\n";
+    std::cout << "                          the shim it calls next to it\n";
+    std::cout << "  --package=NAME          With --single-file, the Go package [default: library]\n";
+    std::cout << "  --validate-enums        With selftest, Go wrappers panic on undeclared\n";
+    std::cout << "                          enum values instead of passing them to C++\n";
+    std::cout << "  --bindings-header       With selftest, also emit bindings.h and check it\n";
+    std::cout << "                          compiles as C and C++ against the shim\n";
+    std::cout << "  --decls-header          With selftest, declare the shims in generated_decls.h\n";
+    std::cout << "                          next to the Go files instead of in each preamble\n";
+    std::cout << "  --thread-safe           With selftest, class wrappers lock a mutex around\n";
+    std::cout << "                          every call and are safe for concurrent use\n";
+    std::cout << "  --cached-strings        With selftest, cache the strings of // @cached\n";
+    std::cout << "                          methods in their Go wrappers\n";
+    std::cout << "  --bounds-check          With selftest, the At and SetAt accessors of\n";
+    std::cout << "                          operator[] panic on an index outside [0, Size())\n";
+    std::cout << "  --validation-failure=error|panic\n";
+    std::cout << "                          With selftest, what an argument outside the\n";
+    std::cout << "                          constraint of its @param docs does: return a\n";
+    std::cout << "                          *ValidationError [default], or panic with it\n";
+    std::cout << "  --default-exception-behavior=panic|abort\n";
+    std::cout << "                          With selftest, what a C++ exception escaping a\n";
+    std::cout << "                          function not annotated // @throws does: panic in\n";
+    std::cout << "                          Go with its what() message, or abort [default]\n";
+    std::cout << "  --small-strings=N       With selftest, pass string arguments of up to N\n";
+    std::cout << "                          bytes from a Go stack buffer instead of the C heap\n";
+    std::cout << "  --scratch-arena         With selftest, copy the string arguments of a call\n";
+    std::cout << "                          into one C allocation instead of one each\n";
     std::cout << "  --verbose               Enable verbose output\n";
     std::cout << "  --quiet                 Minimal output (errors only)\n";
     std::cout << "  -h, --help              Show this help message\n";
@@ -44,6 +97,14 @@ void printUsage(const char* program_name) {
     std::cout << "  " << program_name << " -i example.cpp --verbose\n\n";
     std::cout << "  # Quiet mode (errors only)\n";
     std::cout << "  " << program_name << " -i example.cpp --quiet\n\n";
+    std::cout << "  # C API header for Python/Zig/C# consumers\n";
+    std::cout << "  " << program_name << " -i widget.h -t c-header --def\n";
+    std::cout << "  # Output: widget_c.h, widget.def, widget_wrapper.h, widget_shim.cpp\n\n";
+    std::cout << "  # Go bindings in one file, to vendor into a small tool\n";
+    std::cout << "  " << program_name << " -i widget.h -t go --single-file widget.go --package=widget\n";
+    std::cout << "  # Output: widget.go, widget_wrapper.h, widget_shim.cpp\n\n";
+    std::cout << "  # Build and go test every end-to-end fixture\n";
+    std::cout << "  " << program_name << " selftest --fixtures tests/fixtures\n\n";
     std::cout << "  # Generate with test cases\n";
     std::cout << "  " << program_name << " -i vector.cpp --gen-tests\n\n";
 
@@ -70,14 +131,161 @@ void printVersion() {
     std::cout << "License: MIT\n";
 }
 
+// Reads the file an injected-text option names into text
+bool readInjectedFile(const std::string& arg, std::string& text) {
+    std::string path = arg.substr(arg.find('=') + 1);
+    std::ifstream in(path);
+    if (path.empty() || !in.is_open()) {
+        std::cerr << "Error: " << arg.substr(0, arg.find('=')) << " names no readable file: '" << path << "'\n";
+        return false;
+    }
+    std::stringstream content;
+    content << in.rdbuf();
+    text = content.str();
+    return true;
+}
+
+void printIndented(const std::string& text) {
+    std::string line;
+    std::istringstream lines(text);
+    while (std::getline(lines, line)) {
+        std::cout << "      " << line << "\n";
+    }
+}
+
+int runSelfTest(int argc, char* argv[]) {
+    std::string fixtures_dir;
+    hybrid_transpiler::ffi::FFIOptions ffi_options;
+
+    for (int i = 2; i < argc; ++i) {
+        std::string arg = argv[i];
+        if (arg == "--fixtures") {
+            if (i + 1 < argc) {
+                fixtures_dir = argv[++i];
+            } else {
+                std::cerr << "Error: --fixtures requires a directory\n";
+                return 1;
+            }
+        } else if (arg == "--validate-enums") {
+            ffi_options.validate_enums = true;
+        } else if (arg == "--bindings-header") {
+            ffi_options.bindings_header = true;
+        } else if (arg == "--decls-header") {
+            ffi_options.decls_header = true;
+        } else if (arg == "--thread-safe") {
+            ffi_options.thread_safe = true;
+        } else if (arg == "--cached-strings") {
+            ffi_options.cached_strings = true;
+        } else if (arg == "--bounds-check") {
+            ffi_options.bounds_check = true;
+        } else if (arg == "--scratch-arena") {
+            ffi_options.scratch_arena = true;
+        } else if (arg.compare(0, 16, "--symbol-prefix=") == 0) {
+            ffi_options.symbol_prefix = arg.substr(16);
+        } else if (arg == "--legacy-symbols") {
+            ffi_options.legacy_symbol_names = true;
+        } else if (arg == "--dll-import") {
+            ffi_options.windows_dll_import = true;
+        } else if (arg.compare(0, 15, "--cgo-prologue=") == 0) {
+            if (!readInjectedFile(arg, ffi_options.cgo_prologue)) {
+                return 1;
+            }
+        } else if (arg.compare(0, 15, "--cgo-epilogue=") == 0) {
+            if (!readInjectedFile(arg, ffi_options.cgo_epilogue)) {
+                return 1;
+            }
+        } else if (arg.compare(0, 16, "--shim-prologue=") == 0) {
+            if (!readInjectedFile(arg, ffi_options.shim_prologue)) {
+                return 1;
+            }
+        } else if (arg.compare(0, 16, "--small-strings=") == 0) {
+            std::string size = arg.substr(16);
+            if (size.empty() || size.size() > 5 || size.find_first_not_of("0123456789") != std::string::npos) {
+                std::cerr << "Error: --small-strings must be a byte count, not '" << size << "'\n";
+                return 1;
+            }
+            ffi_options.small_string_size = std::stoul(size);
+        } else if (arg.compare(0, 21, "--validation-failure=") == 0) {
+            std::string failure = arg.substr(21);
+            if (failure == "error") {
+                ffi_options.validation_failure = hybrid_transpiler::ffi::ValidationFailure::Error;
+            } else if (failure == "panic") {
+                ffi_options.validation_failure = hybrid_transpiler::ffi::ValidationFailure::Panic;
+            } else {
+                std::cerr << "Error: --validation-failure must be error or panic, not '" << failure << "'\n";
+                return 1;
+            }
+        } else if (arg.compare(0, 29, "--default-exception-behavior=") == 0) {
+            std::string behavior = arg.substr(29);
+            if (behavior == "panic") {
+                ffi_options.default_exception_behavior = hybrid_transpiler::ffi::ExceptionBehavior::Panic;
+            } else if (behavior == "abort") {
+                ffi_options.default_exception_behavior = hybrid_transpiler::ffi::ExceptionBehavior::Abort;
+            } else {
+                std::cerr << "Error: --default-exception-behavior must be panic or abort, not '" << behavior << "'\n";
+                return 1;
+            }
+        } else {
+            std::cerr << "Error: Unknown selftest option '" << arg << "'\n";
+            std::cerr << "Usage: " << argv[0] << " selftest --fixtures <dir> [--validate-enums] [--bindings-header]"
+                      << " [--thread-safe] [--cached-strings] [--decls-header] [--bounds-check]"
+                      << " [--default-exception-behavior=panic|abort] [--small-strings=N]"
+                      << " [--symbol-prefix=P] [--legacy-symbols] [--validation-failure=error|panic]"
+                      << " [--scratch-arena] [--dll-import] [--cgo-prologue=FILE] [--cgo-epilogue=FILE]"
+                      << " [--shim-prologue=FILE]\n";
+            return 1;
+        }
+    }
+
+    if (fixtures_dir.empty()) {
+        std::cerr << "Error: No fixtures directory specified\n";
+        std::cerr << "Example: " << argv[0] << " selftest --fixtures tests/fixtures\n";
+        return 1;
+    }
+
+    std::vector<hybrid_transpiler::ffi::SelfTestResult> results;
+    try {
+        results = hybrid_transpiler::ffi::SelfTestRunner(ffi_options).runAll(fixtures_dir);
+    } catch (const std::exception& e) {
+        std::cerr << "Error: " << e.what() << "\n";
+        return 1;
+    }
+    if (results.empty()) {
+        std::cerr << "Error: No fixtures (directories with a fixture.conf) in " << fixtures_dir << "\n";
+        return 1;
+    }
+
+    size_t failed = 0;
+    for (const auto& result : results) {
+        if (result.passed) {
+            std::cout << "  PASS " << result.fixture << "\n";
+            continue;
+        }
+        failed++;
+        std::cout << "  FAIL " << result.fixture << " (" << result.stage << ")\n";
+        printIndented(result.output);
+        if (!result.work_dir.empty()) {
+            std::cout << "      work directory kept at " << result.work_dir << "\n";
+        }
+    }
+
+    std::cout << "\n" << results.size() - failed << " passed, " << failed << " failed\n";
+    return failed == 0 ? 0 : 1;
+}
+
 int main(int argc, char* argv[]) {
     if (argc < 2) {
         printUsage(argv[0]);
         return 1;
     }
 
+    if (std::string(argv[1]) == "selftest") {
+        return runSelfTest(argc, argv);
+    }
+
     hybrid::TranspilerOptions options;
     std::string input_file;
+    std::string single_file;
     std::vector<std::string> input_files;
 
     // Parse command line arguments
@@ -115,21 +323,25 @@ int main(int argc, char* argv[]) {
                     options.target = hybrid::TargetLanguage::Rust;
                 } else if (target == "go") {
                     options.target = hybrid::TargetLanguage::Go;
+                } else if (target == "c-header") {
+                    options.target = hybrid::TargetLanguage::CHeader;
                 } else {
                     std::cerr << "Error: Unknown target language '" << target << "'\n";
-                    std::cerr << "Supported languages: rust, go\n";
+                    std::cerr << "Supported languages: rust, go, c-header\n";
 
                     // Suggest corrections for common typos
                     if (target == "rs" || target == "r") {
                         std::cerr << "Did you mean 'rust'?\n";
                     } else if (target == "golang") {
                         std::cerr << "Use 'go' instead of 'golang'\n";
+                    } else if (target == "c" || target == "h") {
+                        std::cerr << "Did you mean 'c-header'?\n";
                     }
 
                     return 1;
                 }
             } else {
-                std::cerr << "Error: --target requires a language (rust|go)\n";
+                std::cerr << "Error: --target requires a language (rust|go|c-header)\n";
                 std::cerr << "See '" << argv[0] << " --help' for more information.\n";
                 return 1;
             }
@@ -164,6 +376,36 @@ int main(int argc, char* argv[]) {
             options.preserve_comments = false;
         } else if (arg == "--gen-tests") {
             options.generate_tests = true;
+        } else if (arg == "--def") {
+            options.emit_def_file = true;
+        } else if (arg.compare(0, 16, "--symbol-prefix=") == 0) {
+            options.symbol_prefix = arg.substr(16);
+        } else if (arg == "--legacy-symbols") {
+            options.legacy_symbol_names = true;
+        } else if (arg == "--dll-import") {
+            options.windows_dll_import = true;
+        } else if (arg.compare(0, 15, "--cgo-prologue=") == 0) {
+            if (!readInjectedFile(arg, options.cgo_prologue)) {
+                return 1;
+            }
+        } else if (arg.compare(0, 15, "--cgo-epilogue=") == 0) {
+            if (!readInjectedFile(arg, options.cgo_epilogue)) {
+                return 1;
+            }
+        } else if (arg.compare(0, 16, "--shim-prologue=") == 0) {
+            if (!readInjectedFile(arg, options.shim_prologue)) {
+                return 1;
+            }
+        } else if (arg == "--single-file") {
+            if (i + 1 < argc) {
+                single_file = argv[++i];
+            } else {
+                std::cerr << "Error: --single-file requires a file path\n";
+                std::cerr << "Usage: " << argv[0] << " -i <file.h> -t go --single-file <file.go>\n";
+                return 1;
+            }
+        } else if (arg.compare(0, 10, "--package=") == 0) {
+            options.package_name = arg.substr(10);
         } else if (arg == "--verbose") {
             options.verbose = true;
         } else if (arg == "--quiet") {
@@ -205,9 +447,42 @@ int main(int argc, char* argv[]) {
     }
     test_file.close();
 
+    if (!single_file.empty()) {
+        if (options.target != hybrid::TargetLanguage::Go) {
+            std::cerr << "Error: --single-file is only supported with --target go\n";
+            return 1;
+        }
+        if (!options.output_path.empty()) {
+            std::cerr << "Error: --single-file already names the output file; drop --output\n";
+            return 1;
+        }
+        options.single_file = true;
+        options.output_path = single_file;
+    }
+    if (!options.package_name.empty()) {
+        const std::string& name = options.package_name;
+        if (!options.single_file) {
+            std::cerr << "Error: --package is only supported with --single-file\n";
+            return 1;
+        }
+        bool identifier = !std::isdigit(static_cast<unsigned char>(name[0])) &&
+                          std::all_of(name.begin(), name.end(), [](char c) {
+                              return std::isalnum(static_cast<unsigned char>(c)) || c == '_';
+                          });
+        if (!identifier || name == "_" || name == "main") {
+            std::cerr << "Error: --package must name an importable Go package, not '" << name << "'\n";
+            return 1;
+        }
+    }
+
     // Auto-generate output filename if not specified
     if (options.output_path.empty()) {
-        std::string extension = (options.target == hybrid::TargetLanguage::Rust) ? ".rs" : ".go";
+        std::string extension = ".rs";
+        if (options.target == hybrid::TargetLanguage::Go) {
+            extension = ".go";
+        } else if (options.target == hybrid::TargetLanguage::CHeader) {
+            extension = "_c.h";
+        }
         size_t dot_pos = input_file.find_last_of('.');
         if (dot_pos != std::string::npos) {
             options.output_path = input_file.substr(0, dot_pos) + extension;
@@ -220,12 +495,39 @@ int main(int argc, char* argv[]) {
         }
     }
 
+    if (options.emit_def_file && options.target != hybrid::TargetLanguage::CHeader) {
+        std::cerr << "Error: --def is only supported with --target c-header\n";
+        return 1;
+    }
+    if ((!options.symbol_prefix.empty() || options.legacy_symbol_names || options.windows_dll_import) &&
+        options.target != hybrid::TargetLanguage::CHeader && !options.single_file) {
+        std::cerr << "Error: --symbol-prefix, --legacy-symbols and --dll-import are only supported with"
+                  << " --target c-header or --single-file\n";
+        return 1;
+    }
+    if ((!options.cgo_prologue.empty() || !options.cgo_epilogue.empty()) && !options.single_file) {
+        std::cerr << "Error: --cgo-prologue and --cgo-epilogue are only supported with --single-file\n";
+        return 1;
+    }
+    if (!options.shim_prologue.empty() && options.target != hybrid::TargetLanguage::CHeader &&
+        !options.single_file) {
+        std::cerr << "Error: --shim-prologue is only supported with --target c-header or --single-file\n";
+        return 1;
+    }
+
+    std::string target_name = "Rust";
+    if (options.target == hybrid::TargetLanguage::Go) {
+        target_name = "Go";
+    } else if (options.target == hybrid::TargetLanguage::CHeader) {
+        target_name = "C header";
+    }
+
     // Display verbose information
     if (options.verbose) {
         std::cout << "Configuration:\n";
         std::cout << "  Input:  " << input_file << "\n";
         std::cout << "  Output: " << options.output_path << "\n";
-        std::cout << "  Target: " << (options.target == hybrid::TargetLanguage::Rust ? "Rust" : "Go") << "\n";
+        std::cout << "  Target: " << target_name << "\n";
         std::cout << "  Optimization level: " << options.optimization_level << "\n";
         std::cout << "  Safety checks: " << (options.enable_safety_checks ? "enabled" : "disabled") << "\n";
         std::cout << "  Preserve comments: " << (options.preserve_comments ? "yes" : "no") << "\n";
@@ -235,8 +537,7 @@ int main(int argc, char* argv[]) {
     // Create transpiler and run
     if (!options.quiet) {
         std::cout << "Transpiling " << input_file << " to "
-                  << (options.target == hybrid::TargetLanguage::Rust ? "Rust" : "Go")
-                  << "...\n";
+                  << target_name << "...\n";
     }
 
     hybrid::Transpiler transpiler(options);
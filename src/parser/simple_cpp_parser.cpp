@@ -41,7 +41,7 @@ public:
 
         // Parse namespaces and extract content
         std::string processed = parser.processNamespaces(source);
-        parser.source_ = processed;
+        parser.source_ = parser.removeBraceInitializers(processed);
 
         // Parse all classes in the source
         parser.parseClasses(ir);
@@ -75,6 +75,30 @@ private:
         return result;
     }
 
+    /**
+     * Remove brace initializers of members and variables, such as
+     * std::atomic<bool> open{true};, whose "};" would end a class body early
+     */
+    std::string removeBraceInitializers(const std::string& code) const {
+        std::regex initializer(R"(([a-zA-Z_]\w*)\s*(?:=\s*)?\{[^{};]*\}\s*;)");
+        // Type definitions and statements in function bodies keep their braces
+        std::regex definition(R"(\b(?:class|struct|union|enum|namespace|return)\b|[(=])");
+
+        std::string result;
+        size_t last = 0;
+        for (auto it = std::sregex_iterator(code.begin(), code.end(), initializer); it != std::sregex_iterator(); ++it) {
+            size_t start = it->position();
+            size_t statement = start == 0 ? std::string::npos : code.find_last_of(";{}", start - 1);
+            statement = statement == std::string::npos ? 0 : statement + 1;
+            if (std::regex_search(code.substr(statement, start - statement), definition)) {
+                continue;
+            }
+            result += code.substr(last, start - last) + (*it)[1].str() + ";";
+            last = start + it->length();
+        }
+        return result + code.substr(last);
+    }
+
     /**
      * Parse all class declarations
      */
@@ -198,16 +222,18 @@ private:
     void parseStandaloneFunctions(IR& ir) {
         std::string cleaned = removeComments(source_);
 
-        // First, remove class/struct definitions to avoid matching methods
+        // First, remove class/struct definitions, with their template heads, to avoid matching methods
         cleaned = std::regex_replace(cleaned,
-            std::regex(R"((class|struct)\s+\w+\s*(?::\s*public\s+\w+(?:\s*,\s*\w+)*)?\s*\{[^}]*(?:\{[^}]*\}[^}]*)*\};)"),
+            std::regex(R"((?:template\s*<(?:[^<>]|<[^<>]*>)*>\s*)?(class|struct)\s+\w+\s*(?::\s*public\s+\w+(?:\s*,\s*\w+)*)?\s*\{[^}]*(?:\{[^}]*\}[^}]*)*\};)"),
             "");
+        cleaned = trailingDeclarators(cleaned);
 
         // Pattern for standalone functions:
-        // [template<...>] [inline] [static] return_type function_name(params) [const] { body }
+        // [template<...>] [inline] [static] [const] return_type function_name(params) [const] { body }
         // or declarations: return_type function_name(params);
+        // or trailing returns: auto function_name(params) -> return_type;
         std::regex func_pattern(
-            R"((?:template\s*<[^>]*>\s*)?(?:inline\s+|static\s+|extern\s+)*(?:auto|void|bool|char|short|int|long|float|double|size_t|std::\w+(?:<[^>]*>)?|\w+)\s*[*&]?\s+([a-zA-Z_]\w*)\s*\(([^)]*)\)\s*(?:const\s*)?(?:->[\s\w:*&<>]+\s*)?(?:\{([^}]*(?:\{[^}]*\}[^}]*)*)\}|;))",
+            R"((?:template\s*<[^>]*>\s*)?(?:inline\s+|static\s+|extern\s+)*(?:const\s+)?(?:auto|void|bool|char|short|int|long|float|double|size_t|(?:typename\s+)?std::\w+(?:<(?:[^<>]|<[^<>]*>)*>)?(?:::\w+)?|\w+)\s*[*&]?\s+([a-zA-Z_]\w*)\s*\(((?:[^()]|\([^()]*\))*)\)\s*(?:const\s*)?(?:->\s*([^;{=]+?)\s*)?(?:\{([^}]*(?:\{[^}]*\}[^}]*)*)\}|;))",
             std::regex::ECMAScript
         );
 
@@ -221,24 +247,29 @@ private:
             std::string full_match = match.str();
             std::string func_name = match[1].str();
 
-            // Skip if it's a class method implementation (contains ::)
-            if (full_match.find("::") != std::string::npos) {
+            // Skip if it's a class method implementation (Name::method); std:: in
+            // the return type is fine. The name may also occur in the return
+            // type (std::bitset<8> set()), so it is found by its group
+            size_t name_pos = static_cast<size_t>(match.position(1) - match.position(0));
+            if (name_pos >= 2 && full_match.compare(name_pos - 2, 2, "::") == 0) {
                 continue;
             }
 
             Function func;
             func.name = func_name;
+            parseTemplateHead(cleaned, it->position(1), func);
 
             // Extract return type from the match
-            std::string prefix = match.prefix().str();
-            size_t func_start = full_match.find(func_name);
-            if (func_start != std::string::npos && func_start > 0) {
-                std::string type_part = full_match.substr(0, func_start);
+            if (name_pos > 0) {
+                std::string type_part = full_match.substr(0, name_pos);
                 // Clean up type part
                 type_part = std::regex_replace(type_part, std::regex(R"(^\s*(template\s*<[^>]*>\s*)?)"), "");
                 type_part = std::regex_replace(type_part, std::regex(R"((inline|static|extern)\s+)"), "");
                 type_part = trim(type_part);
-                if (!type_part.empty()) {
+                if (type_part == "auto" && match[3].matched) {
+                    // Trailing return type, e.g. auto values() -> int(&)[4]
+                    func.return_type = parseType(match[3].str());
+                } else if (!type_part.empty()) {
                     func.return_type = parseType(type_part);
                 }
             }
@@ -250,8 +281,8 @@ private:
             }
 
             // Store body if present
-            if (match[3].matched) {
-                func.body = match[3].str();
+            if (match[4].matched) {
+                func.body = match[4].str();
             }
 
             ir.addFunction(func);
@@ -339,11 +370,47 @@ private:
      * Parse a section (fields and methods within an access level)
      */
     void parseSection(const std::string& section, const std::string& access, ClassDecl& class_decl) {
+        std::string declarations = trailingDeclarators(section);
+
         // Parse field declarations
-        parseFields(section, access, class_decl);
+        parseFields(declarations, access, class_decl);
 
         // Parse method declarations/definitions
-        parseMethods(section, access, class_decl);
+        parseMethods(declarations, access, class_decl);
+    }
+
+    /**
+     * Rewrite array declarators that wrap the name, T (&name(params) const)[N]
+     * or T (*name(params))[N], as the trailing return types they amount to:
+     * auto name(params) const -> T(&)[N]. The method and function patterns
+     * then match them, and parseType settles what the return type is.
+     * Callables returned the same way are left to the analyzer, which reports them
+     */
+    static std::string trailingDeclarators(const std::string& source) {
+        static const std::regex declarator(
+            R"(((?:const\s+)?[A-Za-z_][\w:]*(?:<(?:[^<>]|<[^<>]*>)*>)?)\s*\(\s*([&*])\s*([A-Za-z_]\w*)\s*)"
+            R"(\(((?:[^()]|\([^()]*\))*)\)\s*(const\s*)?\)\s*(\[\s*\w+\s*\]))");
+        return std::regex_replace(source, declarator, "auto $3($4) $5-> $1($2)$6");
+    }
+
+    /**
+     * Record a member name under its access level
+     */
+    void recordAccess(const std::string& access, const std::string& name, ClassDecl& class_decl) {
+        ClassDecl::AccessSection::Level level = ClassDecl::AccessSection::Public;
+        if (access == "private") {
+            level = ClassDecl::AccessSection::Private;
+        } else if (access == "protected") {
+            level = ClassDecl::AccessSection::Protected;
+        }
+
+        for (auto& section : class_decl.access_sections) {
+            if (section.level == level) {
+                section.members.push_back(name);
+                return;
+            }
+        }
+        class_decl.access_sections.push_back({level, {name}});
     }
 
     /**
@@ -382,6 +449,7 @@ private:
                 field.type = parseType(type_str);
 
                 class_decl.fields.push_back(field);
+                recordAccess(access, field.name, class_decl);
             }
         }
     }
@@ -391,9 +459,9 @@ private:
      */
     void parseMethods(const std::string& section, const std::string& access, ClassDecl& class_decl) {
         // Match method signatures (including constructors, virtual, static)
-        // Pattern: [virtual] [static] [type] name(params) [const] [= 0] [{ body } | ;]
+        // Pattern: [virtual] [static] [type] name(params) [const] [-> type] [override|final] [= 0] [{ body } | ;]
         std::regex method_pattern(
-            R"((virtual\s+)?(static\s+)?(?:([a-zA-Z_][\w:<>,\s*&]*?)\s+)?([a-zA-Z_]\w*)\s*\(([^)]*)\)\s*(const)?\s*(=\s*0)?\s*(?:\{([^}]*(?:\{[^}]*\}[^}]*)*)\}|;))",
+            R"((virtual\s+)?(static\s+)?(?:([a-zA-Z_][\w:<>,\s*&\[\]]*?)\s+)?([a-zA-Z_]\w*)\s*\(((?:[^()]|\([^()]*\))*)\)\s*(const)?\s*(?:->\s*([^;{=]+?)\s*)?((?:(?:override|final)\s*)*)(=\s*0)?\s*(?:\{([^}]*(?:\{[^}]*\}[^}]*)*)\}|;))",
             std::regex::ECMAScript
         );
 
@@ -403,22 +471,31 @@ private:
         for (std::sregex_iterator it = methods_begin; it != methods_end; ++it) {
             std::smatch match = *it;
 
+            // ~Name() is the destructor, not a constructor; Delete binds it
+            if (it->position(4) > 0 && section[it->position(4) - 1] == '~') {
+                continue;
+            }
+
             Function method;
             method.name = match[4].str();
+            parseTemplateHead(section, it->position(4), method);
 
-            // Check if virtual
-            method.is_virtual = match[1].matched;
+            // Check if virtual; override and final imply it
+            method.is_virtual = match[1].matched || match[8].length() > 0;
 
             // Check if static
             method.is_static = match[2].matched;
 
             // Check if pure virtual (= 0)
-            method.is_pure_virtual = match[7].matched;
+            method.is_pure_virtual = match[9].matched;
 
             // Check if constructor (no return type and name matches class)
             if (match[3].str().empty() || match[3].str() == class_decl.name) {
                 method.is_constructor = true;
                 method.return_type = nullptr;
+            } else if (match[3].str() == "auto" && match[7].matched) {
+                // Trailing return type, e.g. auto values() -> int(&)[4]
+                method.return_type = parseType(match[7].str());
             } else {
                 method.return_type = parseType(match[3].str());
             }
@@ -433,11 +510,12 @@ private:
             method.is_const = match[6].matched;
 
             // Store body if present
-            if (match[8].matched) {
-                method.body = match[8].str();
+            if (match[10].matched) {
+                method.body = match[10].str();
             }
 
             class_decl.methods.push_back(method);
+            recordAccess(access, method.name, class_decl);
         }
     }
 
@@ -445,15 +523,18 @@ private:
      * Parse function parameters
      */
     void parseParameters(const std::string& params_str, Function& func) {
-        // Split by commas (but not inside <>)
+        // Split by commas (but not inside <> or the parameters of a function pointer)
         std::vector<std::string> param_strs;
         int angle_depth = 0;
+        int paren_depth = 0;
         size_t start = 0;
 
         for (size_t i = 0; i < params_str.length(); ++i) {
             if (params_str[i] == '<') angle_depth++;
             else if (params_str[i] == '>') angle_depth--;
-            else if (params_str[i] == ',' && angle_depth == 0) {
+            else if (params_str[i] == '(') paren_depth++;
+            else if (params_str[i] == ')') paren_depth--;
+            else if (params_str[i] == ',' && angle_depth == 0 && paren_depth == 0) {
                 param_strs.push_back(params_str.substr(start, i - start));
                 start = i + 1;
             }
@@ -469,9 +550,14 @@ private:
 
             // Simple parameter parsing: type name or just type
             std::regex param_pattern(R"(([a-zA-Z_][\w:<>,\s*&]*?)\s+([a-zA-Z_]\w*)(?:\s*=\s*(.+))?)");
+            // Function pointer: return_type (*name)(params), the name being optional
+            std::regex function_pointer_pattern(R"((.+?)\s*\(\s*\*\s*([a-zA-Z_]\w*)?\s*\)\s*\((.*)\))");
             std::smatch match;
 
-            if (std::regex_match(trimmed, match, param_pattern)) {
+            if (std::regex_match(trimmed, match, function_pointer_pattern)) {
+                param.type = parseFunctionPointer(match[1].str(), match[3].str());
+                param.name = match[2].str();
+            } else if (std::regex_match(trimmed, match, param_pattern)) {
                 param.type = parseType(match[1].str());
                 param.name = match[2].str();
 
@@ -489,6 +575,157 @@ private:
         }
     }
 
+    /**
+     * Index just past the '>' closing the '<' at open, or npos. Comparisons
+     * inside parentheses, as in sizeof(T) > 4, do not close it
+     */
+    static size_t closingAngle(const std::string& text, size_t open) {
+        int angle_depth = 0;
+        int paren_depth = 0;
+        for (size_t i = open; i < text.length(); ++i) {
+            if (text[i] == '(') paren_depth++;
+            else if (text[i] == ')') paren_depth--;
+            else if (text[i] == '<' && paren_depth == 0) angle_depth++;
+            else if (text[i] == '>' && paren_depth == 0 && --angle_depth == 0) return i + 1;
+        }
+        return std::string::npos;
+    }
+
+    /**
+     * Record the template head of the declaration whose name starts at
+     * name_pos: its named parameters, and the conditions deciding whether the
+     * overload exists at all, i.e. std::enable_if or std::void_t in the head
+     * or return type and a requires-clause after the head
+     */
+    void parseTemplateHead(const std::string& text, size_t name_pos, Function& func) {
+        size_t start = name_pos == 0 ? std::string::npos : text.find_last_of(";{}", name_pos - 1);
+        start = start == std::string::npos ? 0 : start + 1;
+        std::string declaration = text.substr(start, name_pos - start);
+
+        std::smatch head;
+        std::string return_type = declaration;
+        if (std::regex_search(declaration, head, std::regex(R"(\btemplate\s*<)"))) {
+            size_t open = head.position(0) + head.length(0) - 1;
+            size_t close = closingAngle(declaration, open);
+            if (close == std::string::npos) {
+                return;
+            }
+            func.is_template = true;
+            parseTemplateParameters(declaration.substr(open + 1, close - open - 2), func);
+
+            return_type = declaration.substr(close);
+            std::smatch clause;
+            if (std::regex_search(return_type, clause, std::regex(R"(^\s*requires\b[^\n]*)"))) {
+                func.constraints.push_back(trim(clause.str()));
+                return_type = clause.suffix().str();
+            }
+        }
+        recordConditions(return_type, func);
+    }
+
+    /**
+     * Parse the parameter list of a template head
+     */
+    void parseTemplateParameters(const std::string& params_str, Function& func) {
+        std::vector<std::string> param_strs;
+        int angle_depth = 0;
+        int paren_depth = 0;
+        size_t start = 0;
+        for (size_t i = 0; i < params_str.length(); ++i) {
+            if (params_str[i] == '(') paren_depth++;
+            else if (params_str[i] == ')') paren_depth--;
+            else if (params_str[i] == '<' && paren_depth == 0) angle_depth++;
+            else if (params_str[i] == '>' && paren_depth == 0) angle_depth--;
+            else if (params_str[i] == ',' && angle_depth == 0 && paren_depth == 0) {
+                param_strs.push_back(params_str.substr(start, i - start));
+                start = i + 1;
+            }
+        }
+        param_strs.push_back(params_str.substr(start));
+
+        std::regex template_param_pattern(R"(template\s*<.*>\s*(?:class|typename)\s*(?:\.\.\.\s*)?(\w*)\s*(?:=\s*(.+))?)");
+        std::regex type_param_pattern(R"((?:class|typename)\s*(?:\.\.\.\s*)?(\w*)\s*(?:=\s*(.+))?)");
+        for (const auto& param_str : param_strs) {
+            std::string trimmed = trim(param_str);
+            if (trimmed.empty()) continue;
+            recordConditions(trimmed, func);
+
+            TemplateParameter param;
+            std::smatch match;
+            if (std::regex_match(trimmed, match, template_param_pattern)) {
+                param.kind = TemplateParameter::TemplateParam;
+            } else if (std::regex_match(trimmed, match, type_param_pattern)) {
+                param.kind = TemplateParameter::TypeParam;
+            } else {
+                // A non-type parameter names itself after its type: int N = 0
+                std::string declaration = trimmed.substr(0, trimmed.find('='));
+                std::smatch name;
+                std::regex_search(declaration, name, std::regex(R"(([a-zA-Z_]\w*)\s*$)"));
+                std::string type = trim(declaration.substr(0, name.empty() ? declaration.size() : name.position(1)));
+                if (name.empty() || type.empty()) {
+                    continue;
+                }
+                param.kind = TemplateParameter::NonType;
+                param.name = name[1].str();
+                param.param_type = parseType(type);
+                if (trimmed.find('=') != std::string::npos) {
+                    param.default_value = trim(trimmed.substr(trimmed.find('=') + 1));
+                }
+                func.template_parameters.push_back(param);
+                continue;
+            }
+            // Unnamed parameters, such as typename = std::enable_if_t<...>, only constrain the overload
+            param.name = match[1].str();
+            param.default_value = trim(match[2].str());
+            if (!param.name.empty()) {
+                func.template_parameters.push_back(param);
+            }
+        }
+    }
+
+    /**
+     * Record every std::enable_if and std::void_t in text as a condition of func
+     */
+    void recordConditions(const std::string& text, Function& func) {
+        std::regex condition(R"((?:std::)?(?:enable_if_t|enable_if|void_t)\s*<)");
+        for (auto it = std::sregex_iterator(text.begin(), text.end(), condition); it != std::sregex_iterator();
+             ++it) {
+            size_t open = it->position(0) + it->length(0) - 1;
+            size_t close = closingAngle(text, open);
+            if (close == std::string::npos) {
+                close = text.length();
+            } else if (text.compare(close, 6, "::type") == 0) {
+                close += 6;
+            }
+            std::string spelled = std::regex_replace(text.substr(it->position(0), close - it->position(0)),
+                                                     std::regex(R"(\s+)"), " ");
+            func.constraints.push_back(spelled);
+        }
+    }
+
+    /**
+     * Parse a function pointer type; its name spells it without a declarator,
+     * e.g. void (*)(const Node*, void*)
+     */
+    std::shared_ptr<Type> parseFunctionPointer(const std::string& return_type, const std::string& params_str) {
+        auto function_type = std::make_shared<Type>(TypeKind::Function);
+        function_type->element_type = parseType(return_type);
+
+        Function signature;
+        parseParameters(params_str, signature);
+        std::string params;
+        for (const auto& param : signature.parameters) {
+            params += (params.empty() ? "" : ", ") + std::string(param.type->is_const ? "const " : "") +
+                      param.type->name;
+        }
+        function_type->name = trim(return_type) + " (*)(" + params + ")";
+
+        auto ptr_type = std::make_shared<Type>(TypeKind::Pointer);
+        ptr_type->element_type = function_type;
+        ptr_type->name = function_type->name;
+        return ptr_type;
+    }
+
     /**
      * Parse type string into Type object
      */
@@ -524,6 +761,22 @@ private:
             return ref_type;
         }
 
+        // Check for reference to fixed-size array: T (&)[N]
+        std::smatch array_ref;
+        if (std::regex_match(trimmed, array_ref, std::regex(R"((.+?)\s*\(\s*&\s*\)\s*\[\s*(\d+)\s*\])"))) {
+            auto element = parseType(array_ref[1].str());
+            auto array_type = std::make_shared<Type>(TypeKind::Array);
+            array_type->element_type = element;
+            array_type->name = element->name + "[" + array_ref[2].str() + "]";
+            array_type->size_bytes = std::stoul(array_ref[2].str()) * element->size_bytes;
+
+            auto ref_type = std::make_shared<Type>(TypeKind::Reference);
+            ref_type->element_type = array_type;
+            ref_type->name = trimmed;
+            ref_type->is_const = is_const;
+            return ref_type;
+        }
+
         // Check for smart pointers
         if (trimmed.find("std::unique_ptr<") == 0) {
             size_t start = trimmed.find('<') + 1;
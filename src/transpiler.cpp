@@ -2,6 +2,8 @@
 #include "ir.h"
 #include "codegen.h"
 #include "parser.h"
+#include "ffi.h"
+#include <filesystem>
 #include <fstream>
 #include <sstream>
 
@@ -21,6 +23,15 @@ Transpiler::Transpiler(const TranspilerOptions& options)
 Transpiler::~Transpiler() = default;
 
 bool Transpiler::transpile(const std::string& input_path) {
+    // The C header and single-file bindings are produced by the FFI
+    // pipeline, not by a code generator
+    if (options_.target == TargetLanguage::CHeader) {
+        return generateCHeader(input_path);
+    }
+    if (options_.single_file) {
+        return generateSingleGoFile(input_path);
+    }
+
     // Parse the input file
     if (!parseSourceFile(input_path)) {
         return false;
@@ -85,4 +96,126 @@ bool Transpiler::generateCode(const std::string& output_path) {
     return true;
 }
 
+bool Transpiler::generateCHeader(const std::string& input_path) {
+    std::ifstream in_file(input_path);
+    if (!in_file.is_open()) {
+        last_error_ = "Failed to open input file: " + input_path;
+        return false;
+    }
+    std::stringstream source;
+    source << in_file.rdbuf();
+
+    std::string library_name = std::filesystem::path(input_path).stem().string();
+    hybrid_transpiler::ffi::FFIOptions ffi_options;
+    ffi_options.symbol_prefix = options_.symbol_prefix;
+    ffi_options.legacy_symbol_names = options_.legacy_symbol_names;
+    ffi_options.windows_dll_import = options_.windows_dll_import;
+    hybrid_transpiler::ffi::FFIGenerator generator(ffi_options);
+
+    std::string header;
+    std::string def_file;
+    try {
+        header = generator.generate(source.str(), library_name, "c-header");
+        if (options_.emit_def_file) {
+            def_file = generator.generateDefFile(source.str(), library_name);
+        }
+    }
+    catch (const std::exception& e) {
+        last_error_ = "Failed to generate C header: " + std::string(e.what());
+        return false;
+    }
+
+    std::ofstream out_file(options_.output_path);
+    if (!out_file.is_open()) {
+        last_error_ = "Failed to open output file: " + options_.output_path;
+        return false;
+    }
+    out_file << header;
+    out_file.close();
+
+    if (options_.emit_def_file) {
+        // <stem>.def next to the header, for MSVC import libraries
+        std::filesystem::path def_path = std::filesystem::path(options_.output_path).parent_path() /
+                                         (library_name + ".def");
+        std::ofstream def_out(def_path);
+        if (!def_out.is_open()) {
+            last_error_ = "Failed to open output file: " + def_path.string();
+            return false;
+        }
+        def_out << def_file;
+    }
+
+    return writeShim(source.str(), library_name);
+}
+
+bool Transpiler::generateSingleGoFile(const std::string& input_path) {
+    std::ifstream in_file(input_path);
+    if (!in_file.is_open()) {
+        last_error_ = "Failed to open input file: " + input_path;
+        return false;
+    }
+    std::stringstream source;
+    source << in_file.rdbuf();
+
+    std::string library_name = std::filesystem::path(input_path).stem().string();
+    hybrid_transpiler::ffi::FFIOptions ffi_options;
+    ffi_options.package_name = options_.package_name;
+    ffi_options.cgo_prologue = options_.cgo_prologue;
+    ffi_options.cgo_epilogue = options_.cgo_epilogue;
+    ffi_options.symbol_prefix = options_.symbol_prefix;
+    ffi_options.legacy_symbol_names = options_.legacy_symbol_names;
+    ffi_options.windows_dll_import = options_.windows_dll_import;
+
+    std::string package;
+    try {
+        package = hybrid_transpiler::ffi::FFIGenerator(ffi_options).generateSingleGoFile(source.str(),
+                                                                                          library_name);
+    }
+    catch (const std::exception& e) {
+        last_error_ = "Failed to generate Go bindings: " + std::string(e.what());
+        return false;
+    }
+
+    std::ofstream out_file(options_.output_path);
+    if (!out_file.is_open()) {
+        last_error_ = "Failed to open output file: " + options_.output_path;
+        return false;
+    }
+    out_file << package;
+    out_file.close();
+    return writeShim(source.str(), library_name);
+}
+
+bool Transpiler::writeShim(const std::string& source, const std::string& library_name) {
+    // The extern "C" functions the header declares and the Go file links
+    // against; compiled into the library, they make the ABI complete
+    hybrid_transpiler::ffi::FFIOptions ffi_options;
+    ffi_options.symbol_prefix = options_.symbol_prefix;
+    ffi_options.legacy_symbol_names = options_.legacy_symbol_names;
+    ffi_options.windows_dll_import = options_.windows_dll_import;
+    ffi_options.shim_prologue = options_.shim_prologue;
+
+    std::pair<std::string, std::string> shim;
+    try {
+        shim = hybrid_transpiler::ffi::FFIGenerator(ffi_options).generateCWrapper(source, library_name);
+    }
+    catch (const std::exception& e) {
+        last_error_ = "Failed to generate the C++ shim: " + std::string(e.what());
+        return false;
+    }
+
+    // <stem>_wrapper.h and <stem>_shim.cpp next to the output
+    std::filesystem::path dir = std::filesystem::path(options_.output_path).parent_path();
+    for (const auto& file : {std::make_pair(library_name + "_wrapper.h", &shim.first),
+                             std::make_pair(library_name + "_shim.cpp", &shim.second)}) {
+        std::ofstream out(dir / file.first);
+        if (!out.is_open()) {
+            last_error_ = "Failed to open output file: " + (dir / file.first).string();
+            return false;
+        }
+        out << *file.second;
+    }
+    return true;
+}
+
 } // namespace hybrid
@@ -0,0 +1,28 @@
+#include "samples.h"
+
+std::unique_ptr<int32_t[]> firstSquares(size_t count) {
+    std::unique_ptr<int32_t[]> squares(new int32_t[count]);
+    for (size_t i = 0; i < count; ++i) {
+        squares[i] = static_cast<int32_t>(i * i);
+    }
+    return squares;
+}
+
+Histogram::Histogram(int32_t buckets) : buckets_(buckets) {}
+
+void Histogram::add(int32_t bucket) {
+    buckets_.at(bucket)++;
+}
+
+std::unique_ptr<uint32_t[]> Histogram::counts(size_t& size) const {
+    size = buckets_.size();
+    return head(static_cast<int32_t>(size));
+}
+
+std::unique_ptr<uint32_t[]> Histogram::head(int32_t n) const {
+    std::unique_ptr<uint32_t[]> counts(new uint32_t[n]);
+    for (int32_t i = 0; i < n; ++i) {
+        counts[i] = buckets_[i];
+    }
+    return counts;
+}
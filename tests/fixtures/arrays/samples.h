@@ -0,0 +1,25 @@
+#pragma once
+#include <cstddef>
+#include <cstdint>
+#include <memory>
+#include <vector>
+
+/// Returns the squares of 0 to count - 1.
+std::unique_ptr<int32_t[]> firstSquares(size_t count);
+
+class Histogram {
+public:
+    Histogram(int32_t buckets);
+
+    void add(int32_t bucket);
+
+    /// Copies the bucket counts; size receives their number.
+    std::unique_ptr<uint32_t[]> counts(size_t& size) const;
+
+    // @array_size n
+    /// Returns the first n bucket counts.
+    std::unique_ptr<uint32_t[]> head(int32_t n) const;
+
+private:
+    std::vector<uint32_t> buckets_;
+};
@@ -0,0 +1,39 @@
+package samples
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOwnedArrayBecomesSlice(t *testing.T) {
+	if got, want := FirstSquares(5), []int32{0, 1, 4, 9, 16}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("FirstSquares(5) = %v, want %v", got, want)
+	}
+	if got := FirstSquares(0); len(got) != 0 {
+		t.Fatalf("FirstSquares(0) = %v, want an empty slice", got)
+	}
+}
+
+func TestLengthOutputSizesSlice(t *testing.T) {
+	h := NewHistogram(4)
+	defer h.Delete()
+
+	h.Add(1)
+	h.Add(3)
+	h.Add(3)
+	if got, want := h.Counts(), []uint32{0, 1, 0, 2}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Counts() = %v, want %v", got, want)
+	}
+	if got, want := h.Head(2), []uint32{0, 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Head(2) = %v, want %v", got, want)
+	}
+}
+
+func TestSlicesOutliveTheArray(t *testing.T) {
+	// Each call deletes its array; the copies must stay intact
+	first := FirstSquares(3)
+	FirstSquares(64)
+	if got, want := first, []int32{0, 1, 4}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("FirstSquares(3) = %v after another call, want %v", got, want)
+	}
+}
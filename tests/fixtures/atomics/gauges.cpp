@@ -0,0 +1,7 @@
+#include "gauges.h"
+
+Gauge::Gauge() : level(0.5), id_(0) {}
+
+void Gauge::hit() {
+    hits.fetch_add(1);
+}
@@ -0,0 +1,18 @@
+#pragma once
+#include <atomic>
+#include <cstdint>
+
+class Gauge {
+public:
+    Gauge();
+
+    /// Adds one to hits from C++.
+    void hit();
+
+    std::atomic<int64_t> hits{0};
+    std::atomic<bool> open{true};
+    std::atomic<double> level;
+
+private:
+    int32_t id_;
+};
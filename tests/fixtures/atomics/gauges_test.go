@@ -0,0 +1,48 @@
+package gauges
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestAccessorsLoadAndStore(t *testing.T) {
+	gauge := NewGauge()
+	defer gauge.Delete()
+	if gauge.LoadHits() != 0 || !gauge.LoadOpen() || gauge.LoadLevel() != 0.5 {
+		t.Fatalf("initial values %d, %v, %v", gauge.LoadHits(), gauge.LoadOpen(), gauge.LoadLevel())
+	}
+	gauge.StoreHits(40)
+	gauge.Hit()
+	gauge.StoreOpen(false)
+	gauge.StoreLevel(2.25)
+	if gauge.LoadHits() != 41 || gauge.LoadOpen() || gauge.LoadLevel() != 2.25 {
+		t.Fatalf("stored values %d, %v, %v", gauge.LoadHits(), gauge.LoadOpen(), gauge.LoadLevel())
+	}
+}
+
+func TestConcurrentAccessorsDoNotRace(t *testing.T) {
+	gauge := NewGauge()
+	defer gauge.Delete()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				gauge.StoreLevel(float64(i))
+				gauge.StoreOpen(j%2 == 0)
+				gauge.Hit()
+				if level := gauge.LoadLevel(); level < 0 || level > 7 {
+					t.Errorf("LoadLevel() = %v, a value no goroutine stored", level)
+					return
+				}
+				gauge.LoadOpen()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if hits := gauge.LoadHits(); hits != 8000 {
+		t.Fatalf("LoadHits() = %d after 8000 hits", hits)
+	}
+}
@@ -0,0 +1,15 @@
+#include "flags.h"
+
+std::bitset<16> bits(int32_t mask) {
+    return std::bitset<16>(static_cast<uint32_t>(mask) & 0xFFFFu);
+}
+
+Flags::Flags() {}
+
+std::bitset<16> Flags::echo(std::bitset<16> bits) const {
+    return bits;
+}
+
+std::bitset<128> Flags::invert(const std::bitset<128>& bits) const {
+    return ~bits;
+}
@@ -0,0 +1,17 @@
+#pragma once
+#include <bitset>
+#include <cstdint>
+
+/// Returns the low 16 bits of mask as a bitset.
+std::bitset<16> bits(int32_t mask);
+
+class Flags {
+public:
+    Flags();
+
+    /// Returns bits unchanged.
+    std::bitset<16> echo(std::bitset<16> bits) const;
+
+    /// Returns every bit of bits flipped.
+    std::bitset<128> invert(const std::bitset<128>& bits) const;
+};
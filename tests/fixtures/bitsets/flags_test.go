@@ -0,0 +1,65 @@
+package flags
+
+import "testing"
+
+func TestBitsSetInGoRoundTrip(t *testing.T) {
+	f := NewFlags()
+	defer f.Delete()
+
+	var b Bitset16
+	b.Set(0, true)
+	b.Set(9, true)
+	b.Set(15, true)
+	b.Set(4, true)
+	b.Set(4, false)
+
+	got := f.Echo(b)
+	for i := 0; i < got.Len(); i++ {
+		want := i == 0 || i == 9 || i == 15
+		if got.Test(i) != want {
+			t.Fatalf("Echo(b).Test(%d) = %v, want %v", i, got.Test(i), want)
+		}
+	}
+	if got.Count() != 3 {
+		t.Fatalf("Echo(b).Count() = %d, want 3", got.Count())
+	}
+	if got.Len() != 16 {
+		t.Fatalf("Len() = %d, want 16", got.Len())
+	}
+}
+
+func TestBitsFromCpp(t *testing.T) {
+	b := Bits(0x8201)
+	if !b.Test(0) || !b.Test(9) || !b.Test(15) || b.Test(1) {
+		t.Fatalf("Bits(0x8201) = %#x, want bits 0, 9 and 15", uint64(b))
+	}
+	if b.Count() != 3 {
+		t.Fatalf("Bits(0x8201).Count() = %d, want 3", b.Count())
+	}
+}
+
+func TestWideBitset(t *testing.T) {
+	f := NewFlags()
+	defer f.Delete()
+
+	var b Bitset128
+	b.Set(3, true)
+	b.Set(127, true)
+	got := f.Invert(b)
+	if got.Test(3) || got.Test(127) || !got.Test(64) || !got.Test(0) {
+		t.Fatalf("Invert(b) = %#x, want every bit but 3 and 127", got)
+	}
+	if got.Count() != 126 || got.Len() != 128 {
+		t.Fatalf("Invert(b) has %d of %d bits set, want 126 of 128", got.Count(), got.Len())
+	}
+}
+
+func TestBitIndexOutOfRangePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Test(16) on a Bitset16 did not panic")
+		}
+	}()
+	var b Bitset16
+	b.Test(16)
+}
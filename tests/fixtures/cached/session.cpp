@@ -0,0 +1,47 @@
+#include "session.h"
+
+#include <chrono>
+#include <thread>
+
+namespace {
+
+// The copy a caller reads after the lock is released, one per thread
+const char* copyOut(const std::string& value) {
+    thread_local std::string copy;
+    copy = value;
+    return copy.c_str();
+}
+
+}  // namespace
+
+Session::Session(const char* name) : name_(name), server_("primary"), reads_(0) {}
+
+const char* Session::name() const {
+    const char* name;
+    {
+        std::lock_guard<std::mutex> lock(mu_);
+        name = copyOut(name_);
+    }
+    // Counted once read, and slow to return, so a setter can run in between
+    ++reads_;
+    std::this_thread::sleep_for(std::chrono::microseconds(50));
+    return name;
+}
+
+void Session::setName(const char* name) {
+    std::lock_guard<std::mutex> lock(mu_);
+    name_ = name;
+}
+
+const char* Session::server() const {
+    ++reads_;
+    std::lock_guard<std::mutex> lock(mu_);
+    return copyOut(server_);
+}
+
+void Session::reconnect(const char* server) {
+    std::lock_guard<std::mutex> lock(mu_);
+    server_ = server;
+}
+
+int Session::reads() const { return reads_; }
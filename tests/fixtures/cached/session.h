@@ -0,0 +1,28 @@
+#pragma once
+#include <atomic>
+#include <mutex>
+#include <string>
+
+/// Safe to use from many threads: each accessor copies under a lock.
+class Session {
+public:
+    Session(const char* name);
+
+    // @cached
+    const char* name() const;
+    void setName(const char* name);
+
+    // @cached
+    const char* server() const;
+    /// Changes the server behind the cache's back.
+    void reconnect(const char* server);
+
+    /// Number of times name() and server() were called.
+    int reads() const;
+
+private:
+    mutable std::mutex mu_;
+    std::string name_;
+    std::string server_;
+    mutable std::atomic<int> reads_;
+};
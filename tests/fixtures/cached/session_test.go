@@ -0,0 +1,100 @@
+package session
+
+import (
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestCachedReadsOnce(t *testing.T) {
+	s := NewSession("alice")
+	defer s.Delete()
+	for i := 0; i < 5; i++ {
+		if got := s.Name(); got != "alice" {
+			t.Fatalf("Name() = %q, want alice", got)
+		}
+	}
+	if s.Reads() != 1 {
+		t.Fatalf("C++ read the name %d times, want 1", s.Reads())
+	}
+}
+
+func TestSetterClearsCache(t *testing.T) {
+	s := NewSession("alice")
+	defer s.Delete()
+	s.Name()
+	s.SetName("bob")
+	if got := s.Name(); got != "bob" {
+		t.Fatalf("Name() after SetName = %q, want bob", got)
+	}
+}
+
+func TestInvalidateCache(t *testing.T) {
+	s := NewSession("alice")
+	defer s.Delete()
+	if s.Server() != "primary" {
+		t.Fatalf("Server() = %q, want primary", s.Server())
+	}
+	s.Reconnect("backup")
+	if s.Server() != "primary" {
+		t.Fatal("Server() saw a change made without clearing the cache")
+	}
+	if s.ServerUncached() != "backup" {
+		t.Fatalf("ServerUncached() = %q, want backup", s.ServerUncached())
+	}
+	s.InvalidateCache()
+	if s.Server() != "backup" {
+		t.Fatalf("Server() after InvalidateCache = %q, want backup", s.Server())
+	}
+}
+
+func TestConcurrentReads(t *testing.T) {
+	s := NewSession("carol")
+	defer s.Delete()
+	s.Name()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if s.Name() != "carol" {
+					t.Error("concurrent Name() returned the wrong value")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSetNameRacingName(t *testing.T) {
+	s := NewSession("v0")
+	defer s.Delete()
+	for round := 1; round <= 200; round++ {
+		want := "v" + strconv.Itoa(round)
+		// The readers go to C++, and SetName runs once one has read the old name
+		s.InvalidateCache()
+		reads := s.Reads()
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					s.Name()
+				}
+			}()
+		}
+		for s.Reads() == reads {
+			runtime.Gosched()
+		}
+		s.SetName(want)
+		wg.Wait()
+		// A name read before SetName finished must not stay cached after it
+		if got := s.Name(); got != want {
+			t.Fatalf("round %d: Name() after SetName = %q, want %q", round, got, want)
+		}
+	}
+}
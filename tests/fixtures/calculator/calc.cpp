@@ -0,0 +1,9 @@
+#include "calc.h"
+
+Calculator::Calculator() : value_(0) {}
+Calculator::Calculator(int32_t initial_value) : value_(initial_value) {}
+
+int32_t Calculator::getValue() const { return value_; }
+void Calculator::setValue(int32_t value) { value_ = value; }
+void Calculator::add(int32_t value) { value_ += value; }
+void Calculator::multiply(int32_t value) { value_ *= value; }
@@ -0,0 +1,23 @@
+#pragma once
+#include <cstdint>
+
+/// Accumulates integer operations.
+class Calculator {
+public:
+    Calculator();
+    explicit Calculator(int32_t initial_value);
+
+    int32_t getValue() const;
+    void setValue(int32_t value);
+    void add(int32_t value);
+    void multiply(int32_t value);
+
+private:
+    int32_t value_;
+};
+
+// @mirror
+struct Point {
+    float x;
+    float y;
+};
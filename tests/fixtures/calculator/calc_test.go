@@ -0,0 +1,39 @@
+package calc
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestCalculator(t *testing.T) {
+	c := NewCalculator1(6)
+	defer c.Delete()
+
+	c.Add(4)
+	c.Multiply(3)
+	if got := c.GetValue(); got != 30 {
+		t.Fatalf("GetValue() = %d, want 30", got)
+	}
+	c.SetValue(-2)
+	if got := c.GetValue(); got != -2 {
+		t.Fatalf("GetValue() = %d, want -2", got)
+	}
+}
+
+func TestDefaultConstructor(t *testing.T) {
+	c := NewCalculator()
+	defer c.Delete()
+
+	if got := c.GetValue(); got != 0 {
+		t.Fatalf("GetValue() = %d, want 0", got)
+	}
+	c.Delete()
+	c.Delete() // safe to call twice
+}
+
+func TestPointMirror(t *testing.T) {
+	p := Point{X: 3, Y: 4}
+	if unsafe.Sizeof(p) != 8 || p.X*p.X+p.Y*p.Y != 25 {
+		t.Fatalf("unexpected Point mirror %+v (size %d)", p, unsafe.Sizeof(p))
+	}
+}
@@ -0,0 +1,19 @@
+#include "ledger.h"
+
+Ledger::Ledger() : total_(0) {}
+
+void Ledger::add(int32_t amount) {
+    total_ += amount;
+}
+
+void Ledger::Delete() {
+    total_ = 0;
+}
+
+int32_t Ledger::total() const {
+    return total_;
+}
+
+int32_t checksum(int32_t value) {
+    return value * 31 + 7;
+}
@@ -0,0 +1,21 @@
+#pragma once
+#include <cstdint>
+
+class Ledger {
+public:
+    Ledger();
+
+    void add(int32_t amount);
+
+    /// Drops every entry; bound as DeleteCpp beside the generated Delete.
+    void Delete();
+
+    int32_t total() const;
+
+private:
+    int32_t total_;
+};
+
+/// Declared twice, as headers including each other's prototypes do.
+int32_t checksum(int32_t value);
+int32_t checksum(int32_t value);
@@ -0,0 +1,23 @@
+package ledger
+
+import "testing"
+
+func TestMethodNamedLikeDelete(t *testing.T) {
+	l := NewLedger()
+	defer l.Delete()
+	l.Add(5)
+	l.DeleteCpp()
+	if l.Total() != 0 {
+		t.Fatalf("Total() = %d after DeleteCpp, want 0", l.Total())
+	}
+	l.Add(3)
+	if l.Total() != 3 {
+		t.Fatalf("Total() = %d after Add(3), want 3", l.Total())
+	}
+}
+
+func TestRedeclaredFunction(t *testing.T) {
+	if Checksum(2) != 69 || ChecksumCpp(2) != 69 {
+		t.Fatalf("Checksum(2) = %d and ChecksumCpp(2) = %d, want 69", Checksum(2), ChecksumCpp(2))
+	}
+}
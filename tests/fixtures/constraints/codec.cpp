@@ -0,0 +1,64 @@
+#include "codec.h"
+
+#include <stdexcept>
+
+namespace {
+int current_level = 6;
+
+// A throw reaching the extern "C" shim aborts, so a level that got past Go fails the test
+void require(bool ok, const char* what) {
+    if (!ok) throw std::out_of_range(what);
+}
+}
+
+int setLevel(int level) {
+    require(level >= 0 && level <= 9, "level");
+    int previous = current_level;
+    current_level = level;
+    return previous;
+}
+
+int setLevelFast(int level) {
+    // No check on the hot path: an out-of-range level is clamped
+    int previous = current_level;
+    current_level = level < 0 ? 0 : level > 9 ? 9 : level;
+    return previous;
+}
+
+double samplePercent(double ratio) {
+    return ratio * 100;
+}
+
+int sampleBytes(int bits) {
+    return bits / 8;
+}
+
+int retryDelay(int count) {
+    return 100 << (count - 1);
+}
+
+int spawn(int threads) {
+    return threads;
+}
+
+int nest(int depth) {
+    return depth;
+}
+
+Encoder::Encoder(int quality) : quality_(quality), gain_(1.0f) {}
+
+void Encoder::setQuality(int quality) {
+    quality_ = quality;
+}
+
+int Encoder::quality() const {
+    return quality_;
+}
+
+void Encoder::setGain(float gain) {
+    gain_ = gain;
+}
+
+float Encoder::gain() const {
+    return gain_;
+}
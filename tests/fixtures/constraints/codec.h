@@ -0,0 +1,45 @@
+#pragma once
+
+/// Sets the compression level of the next block.
+/// @param level Must be between 0 and 9.
+/// @return The level in effect before.
+int setLevel(int level);
+
+/// Same as setLevel, for the inner loop.
+/// @param level Must be between 0 and 9.
+// @novalidate
+int setLevelFast(int level);
+
+/// Percentage of the input a ratio samples.
+/// @param ratio Fraction of the input, in the range [0, 1).
+double samplePercent(double ratio);
+
+/// @param bits Sample width. One of 8, 16 or 32.
+int sampleBytes(int bits);
+
+/// @param count Number of retries. At least 1 and at most 5.
+int retryDelay(int count);
+
+/// @param threads Up to 4 threads are used, fewer on small inputs.
+int spawn(int threads);
+
+/// @param depth Between 1 and 8 unless recursion is enabled.
+int nest(int depth);
+
+class Encoder {
+public:
+    /// @param quality Must be between 1 and 100.
+    explicit Encoder(int quality);
+
+    /// @param quality Must be between 1 and 100.
+    void setQuality(int quality);
+    int quality() const;
+
+    /// @param gain Must be greater than 0.
+    void setGain(float gain);
+    float gain() const;
+
+private:
+    int quality_;
+    float gain_;
+};
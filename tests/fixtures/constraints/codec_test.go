@@ -0,0 +1,116 @@
+package codec
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestInRangeArgumentsReachCpp(t *testing.T) {
+	if _, err := SetLevel(3); err != nil {
+		t.Fatalf("SetLevel(3) = %v", err)
+	}
+	previous, err := SetLevel(9)
+	if err != nil || previous != 3 {
+		t.Fatalf("SetLevel(9) = %d, %v, want 3, nil", previous, err)
+	}
+	if bytes, err := SampleBytes(16); err != nil || bytes != 2 {
+		t.Fatalf("SampleBytes(16) = %d, %v, want 2, nil", bytes, err)
+	}
+	if delay, err := RetryDelay(5); err != nil || delay != 1600 {
+		t.Fatalf("RetryDelay(5) = %d, %v, want 1600, nil", delay, err)
+	}
+	if percent, err := SamplePercent(0); err != nil || percent != 0 {
+		t.Fatalf("SamplePercent(0) = %v, %v, want 0, nil", percent, err)
+	}
+}
+
+func TestOutOfRangeArgumentsReturnValidationError(t *testing.T) {
+	SetLevel(4)
+	_, err := SetLevel(10)
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("SetLevel(10) error = %v, want a *ValidationError", err)
+	}
+	if invalid.Function != "setLevel" || invalid.Parameter != "level" || invalid.Value != int32(10) ||
+		invalid.Constraint != "between 0 and 9" {
+		t.Fatalf("SetLevel(10) error = %+v", invalid)
+	}
+	if want := "setLevel: level is 10, must be between 0 and 9"; err.Error() != want {
+		t.Fatalf("Error() = %q, want %q", err.Error(), want)
+	}
+	// Rejected before C++, which would have thrown
+	if previous, _ := SetLevel(4); previous != 4 {
+		t.Fatalf("SetLevel(10) changed the level to %d", previous)
+	}
+
+	checks := []struct {
+		name string
+		err  error
+	}{
+		{"SampleBytes(12)", second(SampleBytes(12))},
+		{"RetryDelay(0)", second(RetryDelay(0))},
+		{"RetryDelay(6)", second(RetryDelay(6))},
+		{"Spawn(5)", second(Spawn(5))},
+	}
+	for _, check := range checks {
+		if !errors.As(check.err, &invalid) {
+			t.Errorf("%s error = %v, want a *ValidationError", check.name, check.err)
+		}
+	}
+}
+
+func second(_ int32, err error) error {
+	return err
+}
+
+func TestHalfOpenRangeRejectsItsBoundAndNaN(t *testing.T) {
+	for _, ratio := range []float64{1, -0.5, math.NaN()} {
+		if _, err := SamplePercent(ratio); err == nil {
+			t.Errorf("SamplePercent(%v) returned no error", ratio)
+		}
+	}
+	if percent, err := SamplePercent(0.25); err != nil || percent != 25 {
+		t.Fatalf("SamplePercent(0.25) = %v, %v, want 25, nil", percent, err)
+	}
+}
+
+func TestNovalidateSkipsTheCheck(t *testing.T) {
+	SetLevelFast(12)
+	if previous := SetLevelFast(2); previous != 9 {
+		t.Fatalf("C++ saw level %d, want it clamped to 9", previous)
+	}
+}
+
+func TestAmbiguousConstraintIsNotChecked(t *testing.T) {
+	// "Between 1 and 8 unless recursion is enabled" is documented, not enforced
+	if depth := Nest(20); depth != 20 {
+		t.Fatalf("Nest(20) = %d", depth)
+	}
+}
+
+func TestMethodsAndConstructors(t *testing.T) {
+	encoder := NewEncoder(80)
+	defer encoder.Delete()
+	if err := encoder.SetQuality(101); err == nil {
+		t.Fatal("SetQuality(101) returned no error")
+	}
+	if encoder.Quality() != 80 {
+		t.Fatalf("Quality() = %d after a rejected SetQuality", encoder.Quality())
+	}
+	if err := encoder.SetQuality(100); err != nil || encoder.Quality() != 100 {
+		t.Fatalf("SetQuality(100) = %v, Quality() = %d", err, encoder.Quality())
+	}
+	// fixture.conf drops the constraint of setGain
+	encoder.SetGain(-1)
+	if encoder.Gain() != -1 {
+		t.Fatalf("Gain() = %v, want -1", encoder.Gain())
+	}
+
+	defer func() {
+		if _, ok := recover().(*ValidationError); !ok {
+			t.Fatal("NewEncoder(0) did not panic with a *ValidationError")
+		}
+	}()
+	NewEncoder(0)
+}
@@ -0,0 +1,33 @@
+#include "canvas.h"
+
+Canvas::Canvas(int32_t width, int32_t height)
+    : width_(width), height_(height), layers_(0), last_fill_(Fill::None) {}
+
+void Canvas::resize(int32_t width, int32_t height) {
+    width_ = width;
+    height_ = height;
+}
+
+int32_t Canvas::addLayer(Fill fill, int32_t limit) {
+    last_fill_ = fill;
+    if (layers_ < limit) {
+        layers_++;
+    }
+    return layers_;
+}
+
+int32_t Canvas::snap(int32_t value, int32_t spacing) {
+    return value / spacing * spacing;
+}
+
+int32_t Canvas::align(int32_t value, int32_t step) {
+    return value / step * step;
+}
+
+int32_t Canvas::width() const { return width_; }
+int32_t Canvas::height() const { return height_; }
+Fill Canvas::lastFill() const { return last_fill_; }
+
+int64_t area(int32_t width, int32_t height) {
+    return static_cast<int64_t>(width) * height;
+}
@@ -0,0 +1,43 @@
+#pragma once
+#include <cstdint>
+
+#define CANVAS_SNAP 16
+
+constexpr int32_t kMaxLayers = 8;
+
+enum class Fill { None, Solid, Hatched = 4 };
+
+class Canvas {
+public:
+    static constexpr int32_t DEFAULT_WIDTH = 640;
+    static constexpr int32_t DEFAULT_HEIGHT = DEFAULT_WIDTH * 3 / 4;
+    static constexpr int32_t GRID = 1 + 2 << 3;
+    static constexpr Fill kDefaultFill = Fill::Solid;
+
+    Canvas(int32_t width = DEFAULT_WIDTH, int32_t height = DEFAULT_HEIGHT);
+
+    /// Resizes the canvas.
+    void resize(int32_t width = Canvas::DEFAULT_WIDTH, int32_t height = DEFAULT_HEIGHT);
+
+    /// Adds a layer; returns how many there are.
+    int32_t addLayer(Fill fill = kDefaultFill, int32_t limit = kMaxLayers - 1);
+
+    /// Snaps to a grid; the spacing comes from a macro, so Go requires it.
+    int32_t snap(int32_t value, int32_t spacing = CANVAS_SNAP);
+
+    /// Rounds down to a multiple of step.
+    int32_t align(int32_t value, int32_t step = GRID);
+
+    int32_t width() const;
+    int32_t height() const;
+    Fill lastFill() const;
+
+private:
+    int32_t width_;
+    int32_t height_;
+    int32_t layers_;
+    Fill last_fill_;
+};
+
+/// Area of a width x height rectangle.
+int64_t area(int32_t width = Canvas::DEFAULT_WIDTH, int32_t height = Canvas::DEFAULT_HEIGHT);
@@ -0,0 +1,45 @@
+package canvas
+
+import "testing"
+
+func TestConstructorDefaults(t *testing.T) {
+	c := NewCanvasDefault()
+	defer c.Delete()
+	if c.Width() != 640 || c.Height() != 480 {
+		t.Fatalf("NewCanvasDefault() is %dx%d, want 640x480", c.Width(), c.Height())
+	}
+}
+
+func TestMethodDefaults(t *testing.T) {
+	c := NewCanvas(1, 1)
+	defer c.Delete()
+	c.ResizeDefault()
+	if c.Width() != CanvasDefaultWidth || c.Height() != CanvasDefaultHeight {
+		t.Fatalf("ResizeDefault() gave %dx%d", c.Width(), c.Height())
+	}
+	for i := 0; i < 10; i++ {
+		c.AddLayerDefault()
+	}
+	if n := c.AddLayer(FillHatched, 100); n != MaxLayers {
+		t.Fatalf("layers = %d, want %d", n, MaxLayers)
+	}
+	c.AddLayerDefault()
+	if c.LastFill() != FillSolid {
+		t.Fatalf("LastFill() = %d, want FillSolid", c.LastFill())
+	}
+}
+
+func TestShiftBindsLikeCpp(t *testing.T) {
+	c := NewCanvasDefault()
+	defer c.Delete()
+	// GRID is (1 + 2) << 3 in C++; Go would read 1 + 2<<3 as 17
+	if got := c.AlignDefault(100); got != 96 {
+		t.Fatalf("AlignDefault(100) = %d, want 96", got)
+	}
+}
+
+func TestFunctionDefaults(t *testing.T) {
+	if got := AreaDefault(); got != 640*480 {
+		t.Fatalf("AreaDefault() = %d, want %d", got, 640*480)
+	}
+}
@@ -0,0 +1,25 @@
+#include "counters.h"
+
+namespace {
+
+class DoublingCounter : public Counter {
+public:
+    explicit DoublingCounter(int32_t start) : Counter(start) {}
+    int32_t step() const override { return 2; }
+};
+
+} // namespace
+
+Counter::Counter() : value_(0) {}
+Counter::Counter(int32_t start) : value_(start) {}
+Counter::~Counter() {}
+
+int32_t Counter::next() {
+    value_ += step();
+    return value_;
+}
+
+int32_t Counter::step() const { return 1; }
+int32_t Counter::value() const { return value_; }
+
+Counter* Counter::doubling(int32_t start) { return new DoublingCounter(start); }
@@ -0,0 +1,22 @@
+#pragma once
+#include <cstdint>
+
+/// Counts in steps; subclasses pick the step.
+// @devirtualize
+class Counter {
+public:
+    Counter();
+    explicit Counter(int32_t start);
+    virtual ~Counter();
+
+    /// Advances by step() and returns the new value.
+    virtual int32_t next();
+    virtual int32_t step() const;
+    int32_t value() const;
+
+    /// A counter stepping by two, returned as a plain Counter.
+    static Counter* doubling(int32_t start);
+
+protected:
+    int32_t value_;
+};
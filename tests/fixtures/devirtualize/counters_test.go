@@ -0,0 +1,32 @@
+package counters
+
+import "testing"
+
+func TestConstructedObjectsCallDirectly(t *testing.T) {
+	c := NewCounter1(5)
+	defer c.Delete()
+	if !c.exact {
+		t.Fatal("NewCounter1 made an object that dispatches virtually")
+	}
+	if got := c.Next(); got != 6 {
+		t.Errorf("Next() = %d, want 6", got)
+	}
+	if got := c.Step(); got != 1 {
+		t.Errorf("Step() = %d, want 1", got)
+	}
+}
+
+func TestFactoryResultsDispatchVirtually(t *testing.T) {
+	c := CounterDoubling(5)
+	defer c.Delete()
+	if c.exact {
+		t.Fatal("CounterDoubling made an object that skips virtual dispatch")
+	}
+	// The subclass's step is only reached through the vtable
+	if got := c.Step(); got != 2 {
+		t.Errorf("Step() = %d, want 2", got)
+	}
+	if got := c.Next(); got != 7 {
+		t.Errorf("Next() = %d, want 7", got)
+	}
+}
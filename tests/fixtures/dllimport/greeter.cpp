@@ -0,0 +1,15 @@
+#include "greeter.h"
+
+int32_t add(int32_t a, int32_t b) {
+    return a + b;
+}
+
+Greeter::Greeter(int32_t start) : count_(start) {}
+
+int32_t Greeter::greet() {
+    return ++count_;
+}
+
+int32_t Greeter::count() const {
+    return count_;
+}
@@ -0,0 +1,18 @@
+#pragma once
+#include <cstdint>
+
+/// Sum of a and b.
+int32_t add(int32_t a, int32_t b);
+
+/// Counts the greetings it was asked for.
+class Greeter {
+public:
+    Greeter(int32_t start);
+
+    /// Number of greetings so far, after this one.
+    int32_t greet();
+    int32_t count() const;
+
+private:
+    int32_t count_;
+};
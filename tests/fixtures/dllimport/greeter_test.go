@@ -0,0 +1,47 @@
+package greeter
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFreeFunction(t *testing.T) {
+	if got := Add(2, 3); got != 5 {
+		t.Fatalf("Add(2, 3) = %d, want 5", got)
+	}
+}
+
+func TestClass(t *testing.T) {
+	g := NewGreeter(40)
+	defer g.Delete()
+	g.Greet()
+	if got := g.Greet(); got != 42 || g.Count() != 42 {
+		t.Fatalf("after two greetings: Greet() = %d, Count() = %d; want 42", got, g.Count())
+	}
+}
+
+// The preamble is the same on every OS, so it is checked everywhere; with
+// selftest --decls-header the declarations are in generated_decls.h
+func TestPreambleImportsFromDLL(t *testing.T) {
+	var preamble string
+	for _, name := range []string{"greeter.go", "generated_decls.h"} {
+		src, err := os.ReadFile(name)
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		preamble += string(src)
+	}
+	for _, want := range []string{
+		"#cgo windows LDFLAGS: -L${SRCDIR}/../lib -lgreeter\n",
+		"#cgo !windows LDFLAGS: -L${SRCDIR}/../lib -lgreeter -lstdc++\n",
+		"#  define GREETER_API __declspec(dllimport)\n",
+		"#  define GREETER_CALL __cdecl\n",
+		"GREETER_API int32_t GREETER_CALL ",
+		"GREETER_API void* GREETER_CALL ",
+	} {
+		if !strings.Contains(preamble, want) {
+			t.Errorf("cgo preamble lacks %q", want)
+		}
+	}
+}
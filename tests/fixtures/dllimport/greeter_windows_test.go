@@ -0,0 +1,38 @@
+//go:build windows
+
+package greeter
+
+import (
+	"syscall"
+	"testing"
+)
+
+// The shims must come from the DLL's export table, where any other
+// consumer of the DLL finds them too
+func TestShimsAreExported(t *testing.T) {
+	var dll *syscall.DLL
+	var err error
+	// MinGW names it libgreeter.dll, MSVC greeter.dll
+	for _, name := range []string{"libgreeter.dll", "greeter.dll"} {
+		if dll, err = syscall.LoadDLL(name); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("LoadDLL: %v", err)
+	}
+	defer dll.Release()
+
+	add, err := dll.FindProc("greeter_add")
+	if err != nil {
+		t.Fatalf("FindProc(greeter_add): %v", err)
+	}
+	if sum, _, _ := add.Call(2, 3); int32(sum) != Add(2, 3) {
+		t.Fatalf("greeter_add(2, 3) = %d through the export table, want %d", int32(sum), Add(2, 3))
+	}
+	for _, symbol := range []string{"greeter_Greeter_new", "greeter_Greeter_greet", "greeter_Greeter_delete"} {
+		if _, err := dll.FindProc(symbol); err != nil {
+			t.Errorf("FindProc(%s): %v", symbol, err)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+#include "shapes.h"
+
+Shape::Shape() : color_(Color::Red) {}
+
+void Shape::setColor(Color color) {
+    color_ = color;
+}
+
+Color Shape::color() const {
+    return color_;
+}
+
+int32_t hue(Color color) {
+    switch (color) {
+    case Color::Red:
+        return 0;
+    case Color::Green:
+        return 120;
+    case Color::Blue:
+        return 240;
+    }
+    return -1;
+}
+
+const char* color_name(Color color) {
+    switch (color) {
+    case Color::Red:
+        return "crimson";
+    case Color::Green:
+        return "forest";
+    case Color::Blue:
+        return "navy";
+    }
+    return nullptr;
+}
@@ -0,0 +1,22 @@
+#pragma once
+#include <cstdint>
+
+/// Fill color of a shape.
+// @go:enum-names func=color_name
+enum class Color : uint8_t { Red, Green = 4, Blue };
+
+class Shape {
+public:
+    Shape();
+    void setColor(Color color);
+    Color color() const;
+
+private:
+    Color color_;
+};
+
+/// Hue in degrees of a color.
+int32_t hue(Color color);
+
+/// Name of a color as the renderer's style sheets spell it.
+const char* color_name(Color color);
@@ -0,0 +1,53 @@
+package shapes
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEnumRoundTrip(t *testing.T) {
+	s := NewShape()
+	defer s.Delete()
+
+	if got := s.Color(); got != ColorRed {
+		t.Fatalf("Color() = %d, want ColorRed", got)
+	}
+	s.SetColor(ColorBlue)
+	if got := s.Color(); got != ColorBlue {
+		t.Fatalf("Color() = %d, want ColorBlue", got)
+	}
+	if ColorBlue != 5 || Hue(ColorGreen) != 120 {
+		t.Fatal("enumerator values do not match C++")
+	}
+}
+
+func TestIsValid(t *testing.T) {
+	if !ColorGreen.IsValid() || Color(1).IsValid() || Color(99).IsValid() {
+		t.Fatal("IsValid disagrees with the declared enumerators")
+	}
+}
+
+func TestInvalidEnumPanics(t *testing.T) {
+	defer func() {
+		r := recover()
+		msg, _ := r.(string)
+		if !strings.Contains(msg, "hue: invalid Color 99") {
+			t.Fatalf("recover() = %v, want an invalid Color panic", r)
+		}
+	}()
+	Hue(Color(99))
+}
+
+func TestStringUsesCppNames(t *testing.T) {
+	if got := fmt.Sprint(ColorGreen); got != "forest" {
+		t.Errorf("fmt.Sprint(ColorGreen) = %q, want color_name's \"forest\"", got)
+	}
+	if got := ColorBlue.String(); got != ColorName(ColorBlue) {
+		t.Errorf("ColorBlue.String() = %q, want %q", got, ColorName(ColorBlue))
+	}
+	// Undeclared values are formatted in Go instead of panicking in the argument check
+	if got := Color(99).String(); got != "Color(99)" {
+		t.Errorf("Color(99).String() = %q", got)
+	}
+}
@@ -0,0 +1,17 @@
+#include "parse.h"
+
+int32_t parseDigit(int32_t c, std::error_code& ec) {
+    if (c < '0' || c > '9') {
+        ec = std::make_error_code(std::errc::invalid_argument);
+        return 0;
+    }
+    return c - '0';
+}
+
+int32_t divide(int32_t a, int32_t b, std::error_code& ec) {
+    if (b == 0) {
+        ec = std::make_error_code(std::errc::result_out_of_range);
+        return 0;
+    }
+    return a / b;
+}
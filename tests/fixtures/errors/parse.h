@@ -0,0 +1,9 @@
+#pragma once
+#include <cstdint>
+#include <system_error>
+
+/// Parses a decimal digit, failing with std::errc::invalid_argument.
+int32_t parseDigit(int32_t c, std::error_code& ec);
+
+/// Divides a by b, failing with std::errc::result_out_of_range when b is 0.
+int32_t divide(int32_t a, int32_t b, std::error_code& ec);
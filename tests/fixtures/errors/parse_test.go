@@ -0,0 +1,32 @@
+package parse
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseDigit(t *testing.T) {
+	got, err := ParseDigit('7')
+	if err != nil || got != 7 {
+		t.Fatalf("ParseDigit('7') = %d, %v; want 7, nil", got, err)
+	}
+
+	_, err = ParseDigit('x')
+	var code *ErrorCode
+	if !errors.As(err, &code) {
+		t.Fatalf("ParseDigit('x') error = %v, want *ErrorCode", err)
+	}
+	if code.Category != "generic" || code.Message == "" {
+		t.Fatalf("unexpected error code %+v", code)
+	}
+}
+
+func TestDivide(t *testing.T) {
+	got, err := Divide(9, 3)
+	if err != nil || got != 3 {
+		t.Fatalf("Divide(9, 3) = %d, %v; want 3, nil", got, err)
+	}
+	if _, err := Divide(1, 0); err == nil {
+		t.Fatal("Divide(1, 0) succeeded, want an error")
+	}
+}
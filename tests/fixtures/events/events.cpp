@@ -0,0 +1,54 @@
+#include "events.h"
+
+KeyPress::KeyPress(int32_t code, bool shifted) : code_(code), shifted_(shifted) {}
+
+int32_t KeyPress::code() const { return code_; }
+
+bool KeyPress::shifted() const { return shifted_; }
+
+Event::Event(EventType type)
+    : type_(type), click_{0, 0, 0}, delta_(0), payload_(nullptr), size_(0) {}
+
+Event* Event::click(int32_t x, int32_t y, int32_t button) {
+    Event* event = new Event(EventType::Click);
+    event->click_ = ClickInfo{x, y, button};
+    event->payload_ = &event->click_;
+    event->size_ = sizeof(ClickInfo);
+    return event;
+}
+
+Event* Event::key(int32_t code, bool shifted) {
+    Event* event = new Event(EventType::Key);
+    event->key_.reset(new KeyPress(code, shifted));
+    event->payload_ = event->key_.get();
+    event->size_ = sizeof(KeyPress);
+    return event;
+}
+
+Event* Event::scroll(double delta) {
+    Event* event = new Event(EventType::Scroll);
+    event->delta_ = delta;
+    event->payload_ = &event->delta_;
+    event->size_ = sizeof(double);
+    return event;
+}
+
+Event* Event::custom(const char* data) {
+    Event* event = new Event(EventType::Custom);
+    event->data_ = data;
+    event->payload_ = event->data_.data();
+    event->size_ = event->data_.size();
+    return event;
+}
+
+Event* Event::truncated_click() {
+    Event* event = click(1, 2, 3);
+    event->size_ = sizeof(int32_t);
+    return event;
+}
+
+EventType Event::type() const { return type_; }
+
+const void* Event::payload() const { return payload_; }
+
+size_t Event::payload_size() const { return size_; }
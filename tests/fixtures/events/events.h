@@ -0,0 +1,57 @@
+#pragma once
+#include <cstddef>
+#include <cstdint>
+#include <memory>
+#include <string>
+
+/// What an event's payload points to.
+enum class EventType : int32_t { Click, Key, Scroll, Quit, Custom };
+
+// @mirror
+/// Where a click landed.
+struct ClickInfo {
+    int32_t x;
+    int32_t y;
+    int32_t button;
+};
+
+/// A key press, owned by the event carrying it.
+class KeyPress {
+public:
+    KeyPress(int32_t code, bool shifted);
+
+    int32_t code() const;
+    bool shifted() const;
+
+private:
+    int32_t code_;
+    bool shifted_;
+};
+
+/// An input event whose payload type depends on its type.
+class Event {
+public:
+    /// An event of type without a payload.
+    Event(EventType type);
+
+    static Event* click(int32_t x, int32_t y, int32_t button);
+    static Event* key(int32_t code, bool shifted);
+    static Event* scroll(double delta);
+    /// An application-defined event carrying data as its payload.
+    static Event* custom(const char* data);
+    /// A click event whose payload was cut short in transit.
+    static Event* truncated_click();
+
+    EventType type() const;
+    const void* payload() const;
+    size_t payload_size() const;
+
+private:
+    EventType type_;
+    ClickInfo click_;
+    std::unique_ptr<KeyPress> key_;
+    double delta_;
+    std::string data_;
+    const void* payload_;
+    size_t size_;
+};
@@ -0,0 +1,90 @@
+package events
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestClickPayloadIsACopiedStruct(t *testing.T) {
+	e := EventClick(10, 20, 1)
+	defer e.Delete()
+	payload, err := e.Payload()
+	if err != nil {
+		t.Fatalf("Payload() error = %v", err)
+	}
+	click, ok := payload.(ClickInfo)
+	if !ok {
+		t.Fatalf("Payload() = %T, want ClickInfo", payload)
+	}
+	if want := (ClickInfo{X: 10, Y: 20, Button: 1}); click != want {
+		t.Errorf("Payload() = %+v, want %+v", click, want)
+	}
+}
+
+func TestKeyPayloadIsABorrowedHandle(t *testing.T) {
+	e := EventKey(65, true)
+	defer e.Delete()
+	payload, err := e.Payload()
+	if err != nil {
+		t.Fatalf("Payload() error = %v", err)
+	}
+	key, ok := payload.(*KeyPress)
+	if !ok {
+		t.Fatalf("Payload() = %T, want *KeyPress", payload)
+	}
+	if key.Code() != 65 || !key.Shifted() {
+		t.Errorf("Payload() = key %d shifted %v, want 65 shifted true", key.Code(), key.Shifted())
+	}
+	// The event owns the key press, so this only detaches the handle
+	key.Delete()
+	key.Delete()
+}
+
+func TestScrollPayloadIsAScalar(t *testing.T) {
+	e := EventScroll(-2.5)
+	defer e.Delete()
+	payload, err := e.Payload()
+	if err != nil {
+		t.Fatalf("Payload() error = %v", err)
+	}
+	if delta, ok := payload.(float64); !ok || delta != -2.5 {
+		t.Errorf("Payload() = %T %v, want float64 -2.5", payload, payload)
+	}
+}
+
+func TestUnknownTagReturnsRawBytes(t *testing.T) {
+	e := EventCustom("ping")
+	defer e.Delete()
+	payload, err := e.Payload()
+	if !errors.Is(err, ErrUnknownPayloadTag) {
+		t.Fatalf("Payload() error = %v, want ErrUnknownPayloadTag", err)
+	}
+	raw, ok := payload.(RawPayload)
+	if !ok {
+		t.Fatalf("Payload() = %T, want RawPayload", payload)
+	}
+	if raw.Tag != int64(EventTypeCustom) || !bytes.Equal(raw.Bytes, []byte("ping")) {
+		t.Errorf("Payload() = %+v, want tag %d and bytes ping", raw, EventTypeCustom)
+	}
+}
+
+func TestShortPayloadIsNotDecoded(t *testing.T) {
+	e := EventTruncatedClick()
+	defer e.Delete()
+	payload, err := e.Payload()
+	if !errors.Is(err, ErrPayloadTooSmall) {
+		t.Fatalf("Payload() error = %v, want ErrPayloadTooSmall", err)
+	}
+	if raw, ok := payload.(RawPayload); !ok || raw.Tag != int64(EventTypeClick) || len(raw.Bytes) != 4 {
+		t.Errorf("Payload() = %+v, want a 4-byte RawPayload tagged Click", payload)
+	}
+}
+
+func TestMissingPayloadIsNil(t *testing.T) {
+	e := NewEvent(EventTypeQuit)
+	defer e.Delete()
+	if payload, err := e.Payload(); payload != nil || err != nil {
+		t.Errorf("Payload() = %v, %v, want nil, nil", payload, err)
+	}
+}
@@ -0,0 +1,39 @@
+#include "guard.h"
+#include <stdexcept>
+#include <string>
+
+int32_t parseDecimal(const char* text) {
+    std::string digits(text);
+    if (digits.empty() || digits.find_first_not_of("0123456789") != std::string::npos) {
+        throw std::invalid_argument("not a number: " + digits);
+    }
+    return static_cast<int32_t>(std::stol(digits));
+}
+
+void throwInt(int32_t value) {
+    throw value;
+}
+
+int32_t divide(int32_t a, int32_t b) {
+    if (b == 0) {
+        throw std::domain_error("division by zero");
+    }
+    return a / b;
+}
+
+Account::Account(int64_t balance) : balance_(balance) {
+    if (balance < 0) {
+        throw std::invalid_argument("negative opening balance");
+    }
+}
+
+void Account::withdraw(int64_t amount) {
+    if (amount > balance_) {
+        throw std::runtime_error("insufficient funds");
+    }
+    balance_ -= amount;
+}
+
+int64_t Account::balance() const {
+    return balance_;
+}
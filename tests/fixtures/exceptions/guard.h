@@ -0,0 +1,26 @@
+#pragma once
+#include <cstdint>
+
+/// Parses a decimal integer; throws std::invalid_argument on anything else.
+int32_t parseDecimal(const char* text);
+
+/// Throws something that is not a std::exception.
+void throwInt(int32_t value);
+
+// @throws
+/// Divides a by b; throws std::domain_error when b is 0.
+int32_t divide(int32_t a, int32_t b);
+
+class Account {
+public:
+    Account(int64_t balance);
+
+    // @throws
+    /// Takes amount from the balance; throws std::runtime_error on overdraft.
+    void withdraw(int64_t amount);
+
+    int64_t balance() const;
+
+private:
+    int64_t balance_;
+};
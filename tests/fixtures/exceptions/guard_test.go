@@ -0,0 +1,70 @@
+package guard
+
+import (
+	"errors"
+	"testing"
+)
+
+// recovered runs fn and returns the value it panicked with, or nil
+func recovered(fn func()) (value any) {
+	defer func() {
+		value = recover()
+	}()
+	fn()
+	return nil
+}
+
+func TestUnannotatedExceptionPanics(t *testing.T) {
+	if got := ParseDecimal("42"); got != 42 {
+		t.Fatalf("ParseDecimal(\"42\") = %d, want 42", got)
+	}
+
+	value := recovered(func() { ParseDecimal("12x") })
+	err, ok := value.(*ExceptionError)
+	if !ok {
+		t.Fatalf("ParseDecimal(\"12x\") panicked with %v (%T), want an *ExceptionError", value, value)
+	}
+	if err.What != "not a number: 12x" || err.Error() != "parseDecimal: not a number: 12x" {
+		t.Fatalf("panic = %q, want the what() message", err.Error())
+	}
+}
+
+func TestUnknownExceptionPanics(t *testing.T) {
+	value := recovered(func() { ThrowInt(7) })
+	if err, ok := value.(*ExceptionError); !ok || err.What != "unknown C++ exception" {
+		t.Fatalf("ThrowInt(7) panicked with %v, want an unknown C++ exception", value)
+	}
+}
+
+func TestConstructorPanics(t *testing.T) {
+	value := recovered(func() { NewAccount(-1) })
+	if err, ok := value.(*ExceptionError); !ok || err.What != "negative opening balance" {
+		t.Fatalf("NewAccount(-1) panicked with %v, want negative opening balance", value)
+	}
+}
+
+func TestThrowsReturnsError(t *testing.T) {
+	if q, err := Divide(7, 2); q != 3 || err != nil {
+		t.Fatalf("Divide(7, 2) = %d, %v; want 3, nil", q, err)
+	}
+	q, err := Divide(7, 0)
+	var exception *ExceptionError
+	if q != 0 || !errors.As(err, &exception) || exception.What != "division by zero" {
+		t.Fatalf("Divide(7, 0) = %d, %v; want 0 and division by zero", q, err)
+	}
+}
+
+func TestThrowingMethod(t *testing.T) {
+	account := NewAccount(100)
+	defer account.Delete()
+
+	if err := account.Withdraw(30); err != nil {
+		t.Fatalf("Withdraw(30) = %v", err)
+	}
+	if err := account.Withdraw(500); err == nil || err.Error() != "Account::withdraw: insufficient funds" {
+		t.Fatalf("Withdraw(500) = %v, want insufficient funds", err)
+	}
+	if got := account.Balance(); got != 70 {
+		t.Fatalf("Balance() = %d, want 70", got)
+	}
+}
@@ -0,0 +1,34 @@
+package shapes
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEveryPointerResultDeclaresOwnership(t *testing.T) {
+	for _, m := range Mappings() {
+		for _, result := range m.GoResults {
+			if (strings.HasPrefix(result, "*") || result == "unsafe.Pointer") && m.Ownership == "" {
+				t.Errorf("%s returns %s without a declared ownership", m.Go, result)
+			}
+		}
+	}
+}
+
+func TestMappingsDescribeFactories(t *testing.T) {
+	want := map[string]string{"ShapeCreate": "caller", "ShapeUnit": "cpp", "MakeSquare": "caller"}
+	for _, m := range Mappings() {
+		if ownership, ok := want[m.Go]; ok {
+			if m.Ownership != ownership {
+				t.Errorf("%s ownership = %q, want %q", m.Go, m.Ownership, ownership)
+			}
+			delete(want, m.Go)
+		}
+		if m.Go == "Shape.Name" && (m.C != "shapes_Shape_name" || m.Ownership != "copied") {
+			t.Errorf("Shape.Name maps to %s with ownership %q, want shapes_Shape_name copied", m.C, m.Ownership)
+		}
+	}
+	for name := range want {
+		t.Errorf("no mapping for %s", name)
+	}
+}
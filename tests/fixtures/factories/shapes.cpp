@@ -0,0 +1,52 @@
+#include "shapes.h"
+
+namespace {
+
+int32_t alive = 0;
+
+class Circle : public Shape {
+public:
+    explicit Circle(double radius) : radius_(radius) {}
+    double area() const override { return 3.0 * radius_ * radius_; }
+    const char* name() const override { return "circle"; }
+
+private:
+    double radius_;
+};
+
+class Square : public Shape {
+public:
+    explicit Square(double side) : side_(side) {}
+    double area() const override { return side_ * side_; }
+    const char* name() const override { return "square"; }
+
+private:
+    double side_;
+};
+
+} // namespace
+
+Shape::~Shape() { --alive; }
+
+Shape* Shape::create(ShapeKind kind, double size) {
+    Shape* shape = nullptr;
+    if (kind == ShapeKind::Circle) {
+        shape = new Circle(size);
+    } else if (kind == ShapeKind::Square) {
+        shape = new Square(size);
+    }
+    if (shape) {
+        ++alive;
+    }
+    return shape;
+}
+
+Shape* Shape::unit(ShapeKind kind) {
+    static Shape* circle = create(ShapeKind::Circle, 1);
+    static Shape* square = create(ShapeKind::Square, 1);
+    return kind == ShapeKind::Circle ? circle : kind == ShapeKind::Square ? square : nullptr;
+}
+
+int32_t Shape::live() { return alive; }
+
+Shape* make_square(double side) { return Shape::create(ShapeKind::Square, side); }
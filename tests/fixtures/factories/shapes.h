@@ -0,0 +1,27 @@
+#pragma once
+#include <cstdint>
+
+/// Kinds of shape the registry knows.
+enum class ShapeKind : int32_t { Circle = 1, Square };
+
+/// A shape of some kind chosen at runtime, as registered by a plugin.
+class Shape {
+public:
+    virtual ~Shape();
+
+    /// Makes a new shape of kind with the given size; nullptr for an unknown kind.
+    static Shape* create(ShapeKind kind, double size);
+
+    // @borrowed
+    /// The registry's unit shape of kind, shared by every caller.
+    static Shape* unit(ShapeKind kind);
+
+    /// Number of shapes alive, counting the registry's.
+    static int32_t live();
+
+    virtual double area() const = 0;
+    virtual const char* name() const = 0;
+};
+
+/// Makes a new square through the free-function factory.
+Shape* make_square(double side);
@@ -0,0 +1,70 @@
+package shapes
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFactoryDispatchesToEachConcreteType(t *testing.T) {
+	for _, tc := range []struct {
+		kind ShapeKind
+		name string
+		area float64
+	}{
+		{ShapeKindCircle, "circle", 12},
+		{ShapeKindSquare, "square", 4},
+	} {
+		s, err := ShapeCreate(tc.kind, 2)
+		if err != nil {
+			t.Fatalf("ShapeCreate(%d): %v", tc.kind, err)
+		}
+		if got := s.Name(); got != tc.name {
+			t.Errorf("ShapeCreate(%d).Name() = %q, want %q", tc.kind, got, tc.name)
+		}
+		if got := s.Area(); got != tc.area {
+			t.Errorf("ShapeCreate(%d).Area() = %v, want %v", tc.kind, got, tc.area)
+		}
+		s.Delete()
+	}
+}
+
+func TestFactoryReturnsErrorForUnknownKind(t *testing.T) {
+	s, err := ShapeCreate(ShapeKind(99), 1)
+	var factory *FactoryError
+	if s != nil || !errors.As(err, &factory) || factory.Function != "Shape::create" {
+		t.Fatalf("ShapeCreate(99) = %v, %v, want nil and a *FactoryError", s, err)
+	}
+}
+
+func TestDeleteFreesOwnedShapes(t *testing.T) {
+	before := ShapeLive()
+	s, _ := MakeSquare(3)
+	if got := s.Area(); got != 9 {
+		t.Errorf("MakeSquare(3).Area() = %v, want 9", got)
+	}
+	if got := ShapeLive(); got != before+1 {
+		t.Fatalf("ShapeLive() = %d after MakeSquare, want %d", got, before+1)
+	}
+	s.Delete()
+	s.Delete()
+	if got := ShapeLive(); got != before {
+		t.Errorf("ShapeLive() = %d after Delete, want %d", got, before)
+	}
+}
+
+func TestBorrowedShapesStayWithTheRegistry(t *testing.T) {
+	circle, _ := ShapeUnit(ShapeKindCircle)
+	if got := circle.Name(); got != "circle" {
+		t.Fatalf("ShapeUnit(ShapeKindCircle).Name() = %q, want circle", got)
+	}
+	before := ShapeLive()
+	circle.Delete()
+	if got := ShapeLive(); got != before {
+		t.Fatalf("ShapeLive() = %d after deleting a borrowed shape, want %d", got, before)
+	}
+	again, _ := ShapeUnit(ShapeKindCircle)
+	if got := again.Area(); got != 3 {
+		t.Errorf("ShapeUnit(ShapeKindCircle).Area() = %v after Delete, want 3", got)
+	}
+	again.Delete()
+}
@@ -0,0 +1,31 @@
+#include "vault.h"
+
+Vault::Vault(const char* secret) : secret_(secret) {}
+
+const char* Vault::secret() const { return secret_.c_str(); }
+
+int vaultVersion() { return 2; }
+
+#ifdef VAULT_WITH_ENCRYPTION
+void Vault::scramble(int key) {
+    for (char& c : secret_) {
+        c = static_cast<char>(c ^ key);
+    }
+}
+
+int checksum(const char* text, int key) {
+    int sum = 0;
+    for (; *text; ++text) {
+        sum += *text ^ key;
+    }
+    return sum;
+}
+#endif
+
+#if defined(VAULT_WITH_COMPRESSION)
+Compressor::Compressor() {}
+
+int Compressor::ratio() const { return 3; }
+
+int compressedSize(const char* text) { return static_cast<int>(std::string(text).size()) / 3; }
+#endif
@@ -0,0 +1,33 @@
+#pragma once
+#include <string>
+
+class Vault {
+public:
+    Vault(const char* secret);
+
+    const char* secret() const;
+#ifdef VAULT_WITH_ENCRYPTION
+    /// XORs every byte of the secret with key; twice restores it.
+    void scramble(int key);
+#endif
+
+private:
+    std::string secret_;
+};
+
+int vaultVersion();
+
+#ifdef VAULT_WITH_ENCRYPTION
+/// Sum of the bytes of text, each XORed with key.
+int checksum(const char* text, int key);
+#endif
+
+#if defined(VAULT_WITH_COMPRESSION)
+class Compressor {
+public:
+    Compressor();
+    int ratio() const;
+};
+
+int compressedSize(const char* text);
+#endif
@@ -0,0 +1,24 @@
+//go:build vault_encryption
+
+package vault
+
+import "testing"
+
+func TestScramble(t *testing.T) {
+	v := NewVault("open")
+	defer v.Delete()
+	v.Scramble(1)
+	if got := v.Secret(); got != "nqdo" {
+		t.Fatalf("Secret() after Scramble(1) = %q, want nqdo", got)
+	}
+	v.Scramble(1)
+	if got := v.Secret(); got != "open" {
+		t.Fatalf("Secret() after scrambling twice = %q, want open", got)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	if got := Checksum("ab", 1); got != 'a'^1+'b'^1 {
+		t.Fatalf("Checksum(ab, 1) = %d", got)
+	}
+}
@@ -0,0 +1,23 @@
+package vault
+
+import "testing"
+
+func TestCore(t *testing.T) {
+	v := NewVault("open")
+	defer v.Delete()
+	if got := v.Secret(); got != "open" {
+		t.Fatalf("Secret() = %q, want open", got)
+	}
+	if got := VaultVersion(); got != 2 {
+		t.Fatalf("VaultVersion() = %d, want 2", got)
+	}
+}
+
+// The library is built with VAULT_WITH_ENCRYPTION only, so the package
+// links without the compression symbols and reports one feature.
+func TestFeatures(t *testing.T) {
+	got := Features()
+	if len(got) != 1 || got[0] != "vault_encryption" {
+		t.Fatalf("Features() = %v, want [vault_encryption]", got)
+	}
+}
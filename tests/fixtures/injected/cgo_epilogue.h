@@ -0,0 +1 @@
+// stats: end of the generated declarations
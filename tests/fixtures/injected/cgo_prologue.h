@@ -0,0 +1 @@
+#define STATS_CGO 1
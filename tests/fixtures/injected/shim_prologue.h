@@ -0,0 +1 @@
+#include <numeric>
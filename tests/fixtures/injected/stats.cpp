@@ -0,0 +1,6 @@
+#include <numeric>
+#include "stats.h"
+
+int32_t capacity() {
+    return 64;
+}
@@ -0,0 +1,14 @@
+#pragma once
+#include <cstdint>
+
+// Like many older headers, this one expects <numeric> to be included first.
+
+/// Sum of the integers from 1 to n.
+inline int32_t triangle(int32_t n) {
+    int32_t values[64] = {};
+    std::iota(values, values + n, 1);
+    return std::accumulate(values, values + n, 0);
+}
+
+/// Number of values a triangle() call can sum.
+int32_t capacity();
@@ -0,0 +1,35 @@
+package stats
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// The shim only compiles with <numeric> from shim_prologue included first
+func TestShimPrologue(t *testing.T) {
+	if got := Triangle(10); got != 55 {
+		t.Fatalf("Triangle(10) = %d, want 55", got)
+	}
+	if got := Capacity(); got != 64 {
+		t.Fatalf("Capacity() = %d, want 64", got)
+	}
+}
+
+func TestCgoPrologueAndEpilogue(t *testing.T) {
+	src, err := os.ReadFile("stats.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	preamble := string(src)
+	preamble = preamble[:strings.Index(preamble, "import \"C\"")]
+	prologue := strings.Index(preamble, "#define STATS_CGO 1\n")
+	includes := strings.Index(preamble, "#include")
+	epilogue := strings.Index(preamble, "// stats: end of the generated declarations\n")
+	if prologue < 0 || prologue > includes {
+		t.Errorf("cgo_prologue is not before the #includes of the preamble:\n%s", preamble)
+	}
+	if epilogue < includes {
+		t.Errorf("cgo_epilogue is not after the declarations of the preamble:\n%s", preamble)
+	}
+}
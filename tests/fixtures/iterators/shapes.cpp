@@ -0,0 +1,38 @@
+#include "shapes.h"
+
+Point::Point(int x, int y) : x_(x), y_(y) {}
+int Point::x() const { return x_; }
+int Point::y() const { return y_; }
+
+PointIterator::PointIterator(const std::vector<int>* xs, std::size_t index) : xs_(xs), index_(index) {}
+Point PointIterator::operator*() const {
+    int x = (*xs_)[index_];
+    return Point(x, x * x);
+}
+PointIterator& PointIterator::operator++() {
+    ++index_;
+    return *this;
+}
+bool PointIterator::operator!=(const PointIterator& other) const { return index_ != other.index_; }
+
+Parabola::Parabola() {}
+void Parabola::add(int x) { xs_.push_back(x); }
+int Parabola::size() const { return static_cast<int>(xs_.size()); }
+PointIterator Parabola::begin() const { return PointIterator(&xs_, 0); }
+PointIterator Parabola::end() const { return PointIterator(&xs_, xs_.size()); }
+
+BitRef::BitRef(const Flags* flags, int bit) : flags_(flags), bit_(bit) {}
+BitRef::operator bool() const { return flags_->test(bit_); }
+
+BitIterator::BitIterator(const Flags* flags, int bit) : flags_(flags), bit_(bit) {}
+BitRef BitIterator::operator*() const { return BitRef(flags_, bit_); }
+BitIterator& BitIterator::operator++() {
+    ++bit_;
+    return *this;
+}
+bool BitIterator::operator!=(const BitIterator& other) const { return bit_ != other.bit_; }
+
+Flags::Flags(int word) : word_(word) {}
+bool Flags::test(int bit) const { return (word_ >> bit) & 1; }
+BitIterator Flags::begin() { return BitIterator(this, 0); }
+BitIterator Flags::end() { return BitIterator(this, 8); }
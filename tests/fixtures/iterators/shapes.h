@@ -0,0 +1,78 @@
+#pragma once
+#include <cstddef>
+#include <vector>
+
+class Point {
+public:
+    Point(int x, int y);
+    int x() const;
+    int y() const;
+
+private:
+    int x_;
+    int y_;
+};
+
+/// Builds each Point on dereference instead of pointing at a stored one.
+class PointIterator {
+public:
+    PointIterator(const std::vector<int>* xs, std::size_t index);
+    Point operator*() const;
+    PointIterator& operator++();
+    bool operator!=(const PointIterator& other) const;
+
+private:
+    const std::vector<int>* xs_;
+    std::size_t index_;
+};
+
+/// The points (x, x * x) of the added x values.
+class Parabola {
+public:
+    Parabola();
+    void add(int x);
+    int size() const;
+    PointIterator begin() const;
+    PointIterator end() const;
+
+private:
+    std::vector<int> xs_;
+};
+
+class Flags;
+
+/// Proxy for one bit, like std::vector<bool>::reference.
+class BitRef {
+public:
+    BitRef(const Flags* flags, int bit);
+    operator bool() const;
+
+private:
+    const Flags* flags_;
+    int bit_;
+};
+
+class BitIterator {
+public:
+    BitIterator(const Flags* flags, int bit);
+    BitRef operator*() const;
+    BitIterator& operator++();
+    bool operator!=(const BitIterator& other) const;
+
+private:
+    const Flags* flags_;
+    int bit_;
+};
+
+/// The low eight bits of a word.
+// @iterable bool
+class Flags {
+public:
+    Flags(int word);
+    bool test(int bit) const;
+    BitIterator begin();
+    BitIterator end();
+
+private:
+    int word_;
+};
@@ -0,0 +1,73 @@
+package shapes
+
+import "testing"
+
+func TestRangeYieldsWrappedObjects(t *testing.T) {
+	p := NewParabola()
+	defer p.Delete()
+	for _, x := range []int32{-2, 3, 5} {
+		p.Add(x)
+	}
+
+	var got [][2]int32
+	p.Range(func(pt *Point) bool {
+		defer pt.Delete()
+		got = append(got, [2]int32{pt.X(), pt.Y()})
+		return true
+	})
+	want := [][2]int32{{-2, 4}, {3, 9}, {5, 25}}
+	if len(got) != len(want) {
+		t.Fatalf("Range yielded %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Range yielded %v, want %v", got, want)
+		}
+	}
+}
+
+func TestRangeStopsEarly(t *testing.T) {
+	p := NewParabola()
+	defer p.Delete()
+	for x := int32(0); x < 10; x++ {
+		p.Add(x)
+	}
+	n := 0
+	p.Range(func(pt *Point) bool {
+		pt.Delete()
+		n++
+		return n < 4
+	})
+	if n != 4 {
+		t.Fatalf("Range called yield %d times after it returned false, want 4", n)
+	}
+}
+
+func TestElements(t *testing.T) {
+	p := NewParabola()
+	defer p.Delete()
+	if len(p.Elements()) != 0 {
+		t.Fatal("Elements of an empty Parabola is not empty")
+	}
+	p.Add(7)
+	points := p.Elements()
+	if len(points) != 1 || points[0].Y() != 49 {
+		t.Fatalf("Elements() = %v", points)
+	}
+	points[0].Delete()
+}
+
+func TestProxyValues(t *testing.T) {
+	f := NewFlags(0xA5)
+	defer f.Delete()
+	got := f.Elements()
+	want := []bool{true, false, true, false, false, true, false, true}
+	if len(got) != len(want) {
+		t.Fatalf("Elements() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Elements() = %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+#include "settings.h"
+
+#include <iterator>
+
+namespace {
+int32_t visited = 0;
+}
+
+Config::Config() = default;
+
+Config::~Config() = default;
+
+void Config::set(const char* key, int32_t value) {
+    values_[key] = value;
+}
+
+int32_t Config::size() const {
+    return static_cast<int32_t>(values_.size());
+}
+
+const char* Config::key_at(int32_t index) const {
+    if (index < 0 || index >= size()) {
+        return nullptr;
+    }
+    auto it = values_.begin();
+    std::advance(it, index);
+    return it->first.c_str();
+}
+
+void for_each_key(Config* config, void (*visit)(const char*, void*), void* context) {
+    visited = 0;
+    for (int32_t i = 0; i < config->size(); ++i) {
+        ++visited;
+        visit(config->key_at(i), context);
+    }
+}
+
+int32_t walk_keys(const Config& config, bool (*visit)(const std::string& key, void* ctx), void* ctx) {
+    int32_t n = 0;
+    while (n < config.size()) {
+        std::string key = config.key_at(n++);
+        if (!visit(key, ctx)) {
+            break;
+        }
+    }
+    return n;
+}
+
+int32_t keys_visited() {
+    return visited;
+}
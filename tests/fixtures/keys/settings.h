@@ -0,0 +1,27 @@
+#pragma once
+#include <cstdint>
+#include <map>
+#include <string>
+
+/// Integer settings, visited in key order.
+class Config {
+public:
+    Config();
+    ~Config();
+    void set(const char* key, int32_t value);
+    int32_t size() const;
+    /// Key at index in key order; nullptr past the end.
+    const char* key_at(int32_t index) const;
+
+private:
+    std::map<std::string, int32_t> values_;
+};
+
+/// Calls visit with each key.
+void for_each_key(Config* config, void (*visit)(const char*, void*), void* context);
+
+/// Calls visit with each key until it returns false; returns how many keys it visited.
+int32_t walk_keys(const Config& config, bool (*visit)(const std::string& key, void* ctx), void* ctx);
+
+/// Number of keys for_each_key visited last time.
+int32_t keys_visited();
@@ -0,0 +1,64 @@
+package settings
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newConfig() *Config {
+	c := NewConfig()
+	for i, key := range []string{"timeout", "retries", "port", "depth"} {
+		c.Set(key, int32(i))
+	}
+	return c
+}
+
+func TestForEachKeyCollectsKeys(t *testing.T) {
+	c := newConfig()
+	defer c.Delete()
+	var keys []string
+	c.ForEachKey(func(key string) bool {
+		keys = append(keys, key)
+		return true
+	})
+	want := []string{"depth", "port", "retries", "timeout"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("ForEachKey visited %q, want %q", keys, want)
+	}
+	if got := c.Keys(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys() = %q, want %q", got, want)
+	}
+}
+
+func TestForEachKeyBreaksEarly(t *testing.T) {
+	c := newConfig()
+	defer c.Delete()
+	var keys []string
+	c.ForEachKey(func(key string) bool {
+		keys = append(keys, key)
+		return key != "port"
+	})
+	if want := []string{"depth", "port"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("ForEachKey visited %q, want %q", keys, want)
+	}
+	// The callback returns void, so C++ cannot stop: it walks on without calling Go
+	if got := KeysVisited(); got != 4 {
+		t.Errorf("KeysVisited() = %d, want 4", got)
+	}
+}
+
+func TestWalkKeysStopsCpp(t *testing.T) {
+	c := newConfig()
+	defer c.Delete()
+	var keys []string
+	n := c.WalkKeys(func(key string) bool {
+		keys = append(keys, key)
+		return len(keys) < 3
+	})
+	if want := []string{"depth", "port", "retries"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("WalkKeys visited %q, want %q", keys, want)
+	}
+	if n != 3 {
+		t.Errorf("WalkKeys returned %d, want 3 keys visited by C++", n)
+	}
+}
@@ -0,0 +1,53 @@
+#include "logs.h"
+#include <cstdarg>
+#include <cstdio>
+#include <string>
+
+namespace {
+std::string last_message;
+int32_t last_level = -1;
+
+void record(int32_t level, const char* fmt, va_list args) {
+    char buffer[256];
+    std::vsnprintf(buffer, sizeof(buffer), fmt, args);
+    last_message = buffer;
+    last_level = level;
+}
+}
+
+void logMessage(int32_t level, const char* fmt, ...) {
+    va_list args;
+    va_start(args, fmt);
+    record(level, fmt, args);
+    va_end(args);
+}
+
+const char* lastMessage() {
+    return last_message.c_str();
+}
+
+int32_t lastLevel() {
+    return last_level;
+}
+
+extern "C" int32_t trace(const char* format, ...) {
+    va_list args;
+    va_start(args, format);
+    record(0, format, args);
+    va_end(args);
+    return static_cast<int32_t>(last_message.size());
+}
+
+Journal::Journal() : lines_(0) {}
+
+void Journal::append(const char* fmt, ...) {
+    va_list args;
+    va_start(args, fmt);
+    record(1, fmt, args);
+    va_end(args);
+    lines_++;
+}
+
+int32_t Journal::lines() const {
+    return lines_;
+}
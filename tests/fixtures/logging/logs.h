@@ -0,0 +1,28 @@
+#pragma once
+#include <cstdint>
+
+/// Records a formatted message at the given level.
+void logMessage(int32_t level, const char* fmt, ...);
+
+/// Text of the last recorded message.
+const char* lastMessage();
+
+/// Level of the last recorded message.
+int32_t lastLevel();
+
+/// Records a formatted message; returns its length.
+extern "C" int32_t trace(const char* format, ...);
+
+class Journal {
+public:
+    Journal();
+
+    /// Appends a formatted line.
+    void append(const char* fmt, ...);
+
+    /// Number of lines appended so far.
+    int32_t lines() const;
+
+private:
+    int32_t lines_;
+};
@@ -0,0 +1,43 @@
+package logs
+
+import "testing"
+
+func TestFormattedInGo(t *testing.T) {
+	LogMessage(2, "x=%d name=%s ok=%v", 3, "y", true)
+	if got := LastMessage(); got != "x=3 name=y ok=true" {
+		t.Fatalf("LastMessage() = %q, want %q", got, "x=3 name=y ok=true")
+	}
+	if level := LastLevel(); level != 2 {
+		t.Fatalf("LastLevel() = %d, want 2", level)
+	}
+}
+
+func TestMessageIsNotAFormatInCpp(t *testing.T) {
+	LogMessage(1, "%s", "100% %n %s %x")
+	if got := LastMessage(); got != "100% %n %s %x" {
+		t.Fatalf("LastMessage() = %q, want the argument verbatim", got)
+	}
+	LogMessage(1, "50%% done")
+	if got := LastMessage(); got != "50% done" {
+		t.Fatalf("LastMessage() = %q, want %q", got, "50% done")
+	}
+}
+
+func TestVariadicExternC(t *testing.T) {
+	if n := Trace("%05.1f|%-4s|", 3.14159, "ab"); n != 11 {
+		t.Fatalf("Trace() = %d, want 11 (%q)", n, LastMessage())
+	}
+	if got := LastMessage(); got != "003.1|ab  |" {
+		t.Fatalf("LastMessage() = %q, want %q", got, "003.1|ab  |")
+	}
+}
+
+func TestVariadicMethod(t *testing.T) {
+	j := NewJournal()
+	defer j.Delete()
+	j.Append("line %d", 1)
+	j.Append("line %d of %d", 2, 2)
+	if j.Lines() != 2 || LastMessage() != "line 2 of 2" {
+		t.Fatalf("Lines() = %d, LastMessage() = %q", j.Lines(), LastMessage())
+	}
+}
@@ -0,0 +1,75 @@
+module;
+#include <cstdint>
+#include "garage.h"
+export module engine;
+
+// Part stays the header's type; the module only re-exports it
+export using ::Part;
+
+// Not exported, so not bound
+int32_t scaled(int32_t power);
+
+class Tuning {
+public:
+    int32_t boost() const;
+};
+
+/// An engine whose parts add to its weight.
+export class Engine {
+public:
+    Engine(int32_t power);
+
+    int32_t power() const;
+    int32_t cylinders() const;
+
+    /// Adds the weight of part, which is not kept.
+    void fit(const Part& part);
+    int32_t weight() const;
+
+private:
+    int32_t power_;
+    int32_t weight_;
+};
+
+export {
+/// Number of engines built so far.
+int32_t engines_built();
+
+// @status
+int32_t check_power(int32_t power);
+}
+
+// Definitions are module-internal; the exported declarations above are bound
+static int32_t built = 0;
+
+int32_t scaled(int32_t power) {
+    return power * 10;
+}
+
+Engine::Engine(int32_t power) : power_(power), weight_(0) {
+    built++;
+}
+
+int32_t Engine::power() const {
+    return scaled(power_) / 10;
+}
+
+int32_t Engine::cylinders() const {
+    return power_ >= 200 ? 8 : 4;
+}
+
+void Engine::fit(const Part& part) {
+    weight_ += part.weight();
+}
+
+int32_t Engine::weight() const {
+    return weight_;
+}
+
+int32_t engines_built() {
+    return built;
+}
+
+int32_t check_power(int32_t power) {
+    return power > 0 ? 0 : 22;
+}
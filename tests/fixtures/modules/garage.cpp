@@ -0,0 +1,21 @@
+#include "garage.h"
+#include <cstdio>
+
+static int32_t made = 0;
+
+Part::Part(const char* name, int32_t weight) : weight_(weight) {
+    std::snprintf(name_, sizeof(name_), "%s", name);
+    made++;
+}
+
+const char* Part::name() const {
+    return name_;
+}
+
+int32_t Part::weight() const {
+    return weight_;
+}
+
+int32_t parts_made() {
+    return made;
+}
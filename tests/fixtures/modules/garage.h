@@ -0,0 +1,18 @@
+#pragma once
+#include <cstdint>
+
+/// A part fitted to engines, declared in a plain header.
+class Part {
+public:
+    Part(const char* name, int32_t weight);
+
+    const char* name() const;
+    int32_t weight() const;
+
+private:
+    char name_[32];
+    int32_t weight_;
+};
+
+/// Number of parts made so far.
+int32_t parts_made();
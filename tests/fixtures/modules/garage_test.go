@@ -0,0 +1,54 @@
+package garage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEngineFromModule(t *testing.T) {
+	built := EnginesBuilt()
+	engine := NewEngine(250)
+	defer engine.Delete()
+	if got := engine.Power(); got != 250 {
+		t.Errorf("Power() = %d, want 250", got)
+	}
+	if got := engine.Cylinders(); got != 8 {
+		t.Errorf("Cylinders() = %d, want 8", got)
+	}
+	if got := EnginesBuilt(); got != built+1 {
+		t.Errorf("EnginesBuilt() = %d, want %d", got, built+1)
+	}
+}
+
+func TestHeaderTypeSharedWithModule(t *testing.T) {
+	// Engine::fit takes the Part the header declares, through the same handle
+	made := PartsMade()
+	piston := NewPart("piston", 3)
+	defer piston.Delete()
+	crank := NewPart("crankshaft", 12)
+	defer crank.Delete()
+	if got := PartsMade(); got != made+2 {
+		t.Errorf("PartsMade() = %d, want %d", got, made+2)
+	}
+	if got := piston.Name(); got != "piston" {
+		t.Errorf("Name() = %q, want piston", got)
+	}
+
+	engine := NewEngine(120)
+	defer engine.Delete()
+	engine.Fit(piston.ptr)
+	engine.Fit(crank.ptr)
+	if got := engine.Weight(); got != 15 {
+		t.Errorf("Weight() = %d, want 15", got)
+	}
+}
+
+func TestExportBlockStatus(t *testing.T) {
+	if err := CheckPower(90); err != nil {
+		t.Errorf("CheckPower(90) = %v", err)
+	}
+	var status *StatusError
+	if err := CheckPower(0); !errors.As(err, &status) || status.Code != 22 {
+		t.Errorf("CheckPower(0) = %v, want status 22", err)
+	}
+}
@@ -0,0 +1,41 @@
+#include "media.h"
+
+namespace audio {
+
+Device::Device(int32_t channels) : channels_(channels) {}
+
+int32_t Device::channels() const {
+    return channels_;
+}
+
+int32_t version() {
+    return 3;
+}
+
+} // namespace audio
+
+namespace video {
+
+Display::Display(int32_t width) : width_(width) {}
+
+int32_t Display::width() const {
+    return width_;
+}
+
+int32_t version() {
+    return 7;
+}
+
+int32_t scale(int32_t length, int32_t factor) {
+    return length * factor;
+}
+
+int32_t scale(int32_t width, int32_t height, int32_t factor_x, int32_t factor_y) {
+    return width * factor_x * height * factor_y;
+}
+
+} // namespace video
+
+extern "C" int32_t Device_channels() {
+    return 2;
+}
@@ -0,0 +1,45 @@
+#pragma once
+#include <cstdint>
+
+namespace audio {
+
+/// An output device with a fixed number of channels.
+class Device {
+public:
+    Device(int32_t channels);
+    int32_t channels() const;
+
+private:
+    int32_t channels_;
+};
+
+/// Version of the audio API.
+int32_t version();
+
+} // namespace audio
+
+namespace video {
+
+/// A display of a given width in pixels.
+class Display {
+public:
+    Display(int32_t width);
+    int32_t width() const;
+
+private:
+    int32_t width_;
+};
+
+/// Version of the video API.
+int32_t version();
+
+/// Scales a length by factor.
+int32_t scale(int32_t length, int32_t factor);
+/// Scales a width by factor_x and a height by factor_y, returning the area.
+int32_t scale(int32_t width, int32_t height, int32_t factor_x, int32_t factor_y);
+
+} // namespace video
+
+// Channels of the default device, a C function the old Device_channels
+// shim of audio::Device::channels would have collided with
+extern "C" int32_t Device_channels();
@@ -0,0 +1,59 @@
+package media
+
+import "testing"
+
+func TestSameNamedFunctionsOfTwoNamespaces(t *testing.T) {
+	if got := Version(); got != 3 {
+		t.Errorf("Version() = %d, want audio::version's 3", got)
+	}
+	if got := VersionCpp(); got != 7 {
+		t.Errorf("VersionCpp() = %d, want video::version's 7", got)
+	}
+}
+
+func TestOverloadsGetIndexedSymbols(t *testing.T) {
+	if got := Scale(4, 3); got != 12 {
+		t.Errorf("Scale(4, 3) = %d, want 12", got)
+	}
+	if got := ScaleCpp(2, 3, 2, 1); got != 12 {
+		t.Errorf("ScaleCpp(2, 3, 2, 1) = %d, want 12", got)
+	}
+}
+
+func TestMethodAndCFunctionOfTheSameName(t *testing.T) {
+	device := NewDevice(6)
+	defer device.Delete()
+	if got := device.Channels(); got != 6 {
+		t.Errorf("Device.Channels() = %d, want 6", got)
+	}
+	if got := DeviceChannels(); got != 2 {
+		t.Errorf("DeviceChannels() = %d, want the C function's 2", got)
+	}
+
+	display := NewDisplay(640)
+	defer display.Delete()
+	if got := display.Width(); got != 640 {
+		t.Errorf("Display.Width() = %d, want 640", got)
+	}
+}
+
+func TestMappingsShowFinalSymbols(t *testing.T) {
+	want := map[string]string{
+		"Device.Channels": "hyb_audio_Device_channels",
+		"NewDisplay":      "hyb_video_Display_new",
+		"VersionCpp":      "hyb_video_version",
+		"ScaleCpp":        "hyb_video_scale_1",
+		"DeviceChannels":  "Device_channels",
+	}
+	for _, m := range Mappings() {
+		if symbol, ok := want[m.Go]; ok {
+			if m.C != symbol {
+				t.Errorf("%s calls %s, want %s", m.Go, m.C, symbol)
+			}
+			delete(want, m.Go)
+		}
+	}
+	for name := range want {
+		t.Errorf("no mapping for %s", name)
+	}
+}
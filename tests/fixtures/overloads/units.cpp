@@ -0,0 +1,27 @@
+#include "units.h"
+
+int32_t round_mm(int32_t micrometres) {
+    return (micrometres + 500) / 1000;
+}
+
+Gauge::Gauge() : value_(0) {}
+
+void Gauge::set(int32_t value) {
+    value_ = value;
+}
+
+int32_t Gauge::value() const {
+    return value_;
+}
+
+void Gauge::nudge(int32_t delta) {
+    value_ += delta;
+}
+
+void Gauge::nudge(int32_t delta, int32_t times) {
+    value_ += delta * times;
+}
+
+int32_t Gauge::raw() const {
+    return value_ * 2;
+}
@@ -0,0 +1,47 @@
+#pragma once
+#include <cstdint>
+#include <type_traits>
+
+/// Rounds a length in micrometres to whole millimetres.
+int32_t round_mm(int32_t micrometres);
+
+/// Rounds a length in millimetres; integers take the overload above.
+template <typename T, std::enable_if_t<std::is_floating_point<T>::value, int> = 0>
+int32_t round_mm(T millimetres) {
+    return static_cast<int32_t>(millimetres + T(0.5));
+}
+
+/// Only exists where pointers are 32 bits wide, so a shim calling it would
+/// not compile on 64-bit targets.
+template <bool Narrow = (sizeof(void*) == 4), typename = std::enable_if_t<Narrow>>
+int32_t pointer_bits(int32_t handle) {
+    return handle;
+}
+
+enum class Preset { Low = 10, High = 90 };
+
+class Gauge {
+public:
+    Gauge();
+
+    void set(int32_t value);
+
+    /// Sets one of the enum presets.
+    template <typename T, typename = std::enable_if_t<std::is_enum<T>::value>>
+    void set(T preset) {
+        value_ = static_cast<int32_t>(preset);
+    }
+
+    int32_t value() const;
+
+    /// Overloads bound alike, as Nudge and Nudge1.
+    void nudge(int32_t delta);
+    void nudge(int32_t delta, int32_t times);
+
+    /// The value before calibration, for the library's own tests.
+    // @skip
+    int32_t raw() const;
+
+private:
+    int32_t value_;
+};
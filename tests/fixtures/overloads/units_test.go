@@ -0,0 +1,34 @@
+package units
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnconstrainedOverloadsBind(t *testing.T) {
+	if got := RoundMm(1499); got != 1 {
+		t.Fatalf("RoundMm(1499) = %d, want 1", got)
+	}
+	g := NewGauge()
+	defer g.Delete()
+	g.Set(42)
+	if g.Value() != 42 {
+		t.Fatalf("Value() = %d after Set(42)", g.Value())
+	}
+}
+
+func TestOverloadedMethodsAreNumbered(t *testing.T) {
+	g := NewGauge()
+	defer g.Delete()
+	g.Nudge(2)
+	g.Nudge1(3, 2)
+	if g.Value() != 8 {
+		t.Fatalf("Value() = %d after Nudge(2) and Nudge1(3, 2), want 8", g.Value())
+	}
+}
+
+func TestSkippedMethodIsLeftOut(t *testing.T) {
+	if _, ok := reflect.TypeOf(&Gauge{}).MethodByName("Raw"); ok {
+		t.Fatal("Gauge.Raw is bound despite // @skip")
+	}
+}
@@ -0,0 +1,54 @@
+#include "conn.h"
+
+#ifdef __linux__
+#include <dirent.h>
+#endif
+
+void describe(int32_t port, Endpoint* out) {
+    out->port = port;
+#ifdef _WIN32
+    out->socket = static_cast<uint64_t>(port) << 32;
+#else
+    out->fd = port % 1000;
+#endif
+    out->backlog = 128;
+}
+
+#if defined(_WIN32)
+uint64_t descriptor(int32_t port) {
+    return static_cast<uint64_t>(port) << 32;
+}
+#else
+int32_t descriptor(int32_t port) {
+    return port % 1000;
+}
+#endif
+
+#ifdef __linux__
+int32_t openDescriptors() {
+    DIR* dir = opendir("/proc/self/fd");
+    if (!dir) {
+        return -1;
+    }
+    int32_t count = 0;
+    while (dirent* entry = readdir(dir)) {
+        if (entry->d_name[0] != '.') {
+            count++;
+        }
+    }
+    closedir(dir);
+    return count;
+}
+#endif
+
+Listener::Listener(int32_t port) : port_(port) {}
+
+int32_t Listener::port() const {
+    return port_;
+}
+
+#ifndef _WIN32
+bool Listener::reusePort() const {
+    return port_ != 0;
+}
+#endif
@@ -0,0 +1,44 @@
+#pragma once
+#include <cstdint>
+
+// @mirror
+/// Where a listener is bound; each platform keeps its own kind of descriptor.
+struct Endpoint {
+    int32_t port;
+#ifdef _WIN32
+    uint64_t socket;
+#else
+    int32_t fd;
+#endif
+    int32_t backlog;
+};
+
+/// Fills out with the endpoint of a listener on port.
+void describe(int32_t port, Endpoint* out);
+
+/// Native descriptor of the listener on port: a SOCKET on Windows, an fd elsewhere.
+#if defined(_WIN32)
+uint64_t descriptor(int32_t port);
+#else
+int32_t descriptor(int32_t port);
+#endif
+
+#ifdef __linux__
+/// Descriptors the process has open, counted in /proc/self/fd.
+int32_t openDescriptors();
+#endif
+
+class Listener {
+public:
+    explicit Listener(int32_t port);
+
+    int32_t port() const;
+
+#ifndef _WIN32
+    /// Whether the listener shares its port through SO_REUSEPORT, which Windows lacks.
+    bool reusePort() const;
+#endif
+
+private:
+    int32_t port_;
+};
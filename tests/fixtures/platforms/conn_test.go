@@ -0,0 +1,43 @@
+package conn
+
+import "testing"
+
+func TestMirrorHasThisPlatformsFields(t *testing.T) {
+	endpoint := Describe(8080)
+	if endpoint.Port != 8080 || endpoint.Fd != 80 || endpoint.Backlog != 128 {
+		t.Fatalf("Describe(8080) = %+v, want {8080 80 128}", endpoint)
+	}
+}
+
+func TestSignatureOfThisPlatform(t *testing.T) {
+	var fd int32 = Descriptor(1042)
+	if fd != 42 {
+		t.Fatalf("Descriptor(1042) = %d, want 42", fd)
+	}
+}
+
+func TestPlatformOnlyFunctionIsBound(t *testing.T) {
+	if n := OpenDescriptors(); n < 3 {
+		t.Fatalf("OpenDescriptors() = %d, want at least stdin, stdout and stderr", n)
+	}
+}
+
+func TestPlatformOnlyMethodIsBound(t *testing.T) {
+	listener := NewListener(9000)
+	defer listener.Delete()
+	if listener.Port() != 9000 || !listener.ReusePort() {
+		t.Fatalf("Listener(9000): port %d, reuse %v", listener.Port(), listener.ReusePort())
+	}
+}
+
+func TestMappingsCarryTheBuildConstraint(t *testing.T) {
+	tags := map[string]string{}
+	for _, m := range Mappings() {
+		if m.Cpp == "descriptor" {
+			tags[m.Result] = m.BuildTag
+		}
+	}
+	if tags["int32_t"] != "linux && amd64" || tags["uint64_t"] != "windows && amd64" {
+		t.Fatalf("descriptor build tags = %v", tags)
+	}
+}
@@ -0,0 +1,63 @@
+#include "plugin.h"
+
+#include <cstring>
+
+namespace {
+
+int32_t live = 0;
+
+// Counted by the concrete classes, so a Delete that skipped their
+// destructors would leave live too high
+class SumCodec : public Codec {
+public:
+    SumCodec() { live++; }
+    ~SumCodec() override { live--; }
+
+    int64_t decode(const char* input) override {
+        calls_++;
+        int64_t sum = 0;
+        for (const char* c = input; *c; ++c) {
+            sum += static_cast<unsigned char>(*c);
+        }
+        return sum;
+    }
+    const char* name() const override { return "sum"; }
+    int32_t calls() const override { return calls_; }
+
+private:
+    int32_t calls_ = 0;
+};
+
+class LengthCodec : public Codec {
+public:
+    LengthCodec() { live++; }
+    ~LengthCodec() override { live--; }
+
+    int64_t decode(const char* input) override {
+        calls_++;
+        return static_cast<int64_t>(std::strlen(input));
+    }
+    const char* name() const override { return "length"; }
+    int32_t calls() const override { return calls_; }
+
+private:
+    int32_t calls_ = 0;
+};
+
+} // namespace
+
+Codec::~Codec() = default;
+
+Codec* create_codec(const char* name) {
+    if (std::strcmp(name, "sum") == 0) {
+        return new SumCodec();
+    }
+    if (std::strcmp(name, "length") == 0) {
+        return new LengthCodec();
+    }
+    return nullptr;
+}
+
+int32_t live_codecs() {
+    return live;
+}
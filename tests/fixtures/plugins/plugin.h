@@ -0,0 +1,24 @@
+#pragma once
+#include <cstdint>
+
+/// A decoder registered under a name. The header declares only the
+/// interface; create_codec picks an implementation hidden in plugin.cpp.
+class Codec {
+public:
+    virtual ~Codec();
+
+    /// Decodes input into the codec's checksum of it.
+    virtual int64_t decode(const char* input) = 0;
+
+    /// Name the codec was registered under.
+    virtual const char* name() const = 0;
+
+    /// Number of decode calls made on this codec.
+    virtual int32_t calls() const = 0;
+};
+
+/// Makes the codec registered as name; nullptr for an unknown name.
+Codec* create_codec(const char* name);
+
+/// Number of codecs alive.
+int32_t live_codecs();
@@ -0,0 +1,72 @@
+package plugin
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFactoryPicksTheRegisteredCodec(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		want int64
+	}{
+		{"sum", 294},
+		{"length", 3},
+	} {
+		codec, err := CreateCodec(tc.name)
+		if err != nil {
+			t.Fatalf("CreateCodec(%q): %v", tc.name, err)
+		}
+		if got := codec.Name(); got != tc.name {
+			t.Errorf("Name() = %q, want %q", got, tc.name)
+		}
+		if got := codec.Decode("abc"); got != tc.want {
+			t.Errorf("%s: Decode(\"abc\") = %d, want %d", tc.name, got, tc.want)
+		}
+		// State lives in the concrete C++ object
+		codec.Decode("")
+		if got := codec.Calls(); got != 2 {
+			t.Errorf("%s: Calls() = %d, want 2", tc.name, got)
+		}
+		codec.Delete()
+	}
+}
+
+func TestUnknownNameIsAnError(t *testing.T) {
+	codec, err := CreateCodec("zstd")
+	if codec != nil {
+		t.Fatalf("CreateCodec(\"zstd\") = %v, want nil", codec)
+	}
+	var factory *FactoryError
+	if !errors.As(err, &factory) || factory.Function != "create_codec" {
+		t.Fatalf("CreateCodec(\"zstd\") error = %v, want a *FactoryError from create_codec", err)
+	}
+	if want := "create_codec: no object created"; err.Error() != want {
+		t.Fatalf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestDeleteRunsTheConcreteDestructor(t *testing.T) {
+	before := LiveCodecs()
+	codec, _ := CreateCodec("length")
+	if got := LiveCodecs(); got != before+1 {
+		t.Fatalf("LiveCodecs() = %d after CreateCodec, want %d", got, before+1)
+	}
+	codec.Delete()
+	codec.Delete()
+	if got := LiveCodecs(); got != before {
+		t.Fatalf("LiveCodecs() = %d after Delete, want %d", got, before)
+	}
+}
+
+func TestMappingsNameTheFactoryError(t *testing.T) {
+	for _, m := range Mappings() {
+		if m.Go == "CreateCodec" {
+			if m.Errors != "factory" || m.Ownership != "caller" {
+				t.Fatalf("CreateCodec maps with errors %q, ownership %q", m.Errors, m.Ownership)
+			}
+			return
+		}
+	}
+	t.Fatal("no mapping for CreateCodec")
+}
@@ -0,0 +1,29 @@
+#include "samples.h"
+
+void add_all(int32_t* values, size_t n, int32_t delta) {
+    for (size_t i = 0; i < n; ++i) {
+        values[i] += delta;
+    }
+}
+
+double sum(const double* values, size_t n) {
+    double total = 0;
+    for (size_t i = 0; i < n; ++i) {
+        total += values[i];
+    }
+    return total;
+}
+
+Histogram::Histogram() : buckets_{} {}
+
+int32_t& Histogram::bucket(int32_t i) {
+    return buckets_[i];
+}
+
+int32_t Histogram::total() const {
+    int32_t total = 0;
+    for (int32_t count : buckets_) {
+        total += count;
+    }
+    return total;
+}
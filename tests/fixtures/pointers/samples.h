@@ -0,0 +1,23 @@
+#pragma once
+#include <cstddef>
+#include <cstdint>
+
+/// Adds delta to each of the n values.
+void add_all(int32_t* values, size_t n, int32_t delta);
+
+/// Sum of the n values.
+double sum(const double* values, size_t n);
+
+/// Counts samples into eight buckets.
+class Histogram {
+public:
+    Histogram();
+
+    /// Bucket i, which stays owned by the histogram.
+    int32_t& bucket(int32_t i);
+
+    int32_t total() const;
+
+private:
+    int32_t buckets_[8];
+};
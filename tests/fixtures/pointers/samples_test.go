@@ -0,0 +1,32 @@
+package samples
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestPointerParameters(t *testing.T) {
+	values := []int32{1, 2, 3}
+	AddAll(unsafe.Pointer(&values[0]), uint(len(values)), 10)
+	if values[0] != 11 || values[1] != 12 || values[2] != 13 {
+		t.Fatalf("AddAll(+10) left %v, want [11 12 13]", values)
+	}
+
+	samples := []float64{0.5, 1.5, 2}
+	if got := Sum(unsafe.Pointer(&samples[0]), uint(len(samples))); got != 4 {
+		t.Fatalf("Sum(%v) = %v, want 4", samples, got)
+	}
+}
+
+func TestReferenceResult(t *testing.T) {
+	h := NewHistogram()
+	defer h.Delete()
+
+	// The pointer refers to the bucket inside the histogram
+	bucket := (*int32)(h.Bucket(3))
+	*bucket += 5
+	*(*int32)(h.Bucket(6)) = 2
+	if *bucket != 5 || h.Total() != 7 {
+		t.Fatalf("bucket 3 = %d, total %d; want 5 and 7", *bucket, h.Total())
+	}
+}
@@ -0,0 +1,9 @@
+#include "policies.h"
+
+Item::Item(int32_t weight) : weight_(weight) {}
+int32_t Item::weight() const { return weight_; }
+
+Shelf::Shelf() {}
+void Shelf::add(int32_t weight) { items_.emplace_back(weight); }
+int32_t Shelf::size() const { return static_cast<int32_t>(items_.size()); }
+const std::vector<Item>& Shelf::items() const { return items_; }
@@ -0,0 +1,66 @@
+#pragma once
+#include <cstdint>
+#include <vector>
+
+class Item {
+public:
+    explicit Item(int32_t weight);
+    int32_t weight() const;
+
+private:
+    int32_t weight_;
+};
+
+/// Items in the order they were added.
+class Shelf {
+public:
+    Shelf();
+    void add(int32_t weight);
+    int32_t size() const;
+    const std::vector<Item>& items() const;
+
+private:
+    std::vector<Item> items_;
+};
+
+/// Accepts even numbers.
+struct IsEven {
+    bool operator()(int32_t value) const { return value % 2 == 0; }
+};
+
+/// Accepts items of at least 10.
+struct IsHeavy {
+    bool operator()(const Item& item) const { return item.weight() >= 10; }
+};
+
+/// Counts the numbers in [0, n) that pred accepts.
+// @instantiate IsEven
+template <typename Predicate>
+int32_t count_accepted(int32_t n, Predicate pred) {
+    int32_t count = 0;
+    for (int32_t i = 0; i < n; ++i) {
+        if (pred(i)) {
+            ++count;
+        }
+    }
+    return count;
+}
+
+/// Sums the weights of the items on shelf that keep accepts.
+// @instantiate IsHeavy
+template <typename Filter>
+int32_t total_weight(const Shelf& shelf, Filter keep) {
+    int32_t total = 0;
+    for (const Item& item : shelf.items()) {
+        if (keep(item)) {
+            total += item.weight();
+        }
+    }
+    return total;
+}
+
+/// The better of a and b; no instantiation is listed, so it stays unbound.
+template <typename Compare>
+int32_t better_of(int32_t a, int32_t b, Compare better) {
+    return better(a, b) ? a : b;
+}
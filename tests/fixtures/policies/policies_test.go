@@ -0,0 +1,49 @@
+package policies
+
+import "testing"
+
+func TestGoPredicateStandsInForPolicy(t *testing.T) {
+	if got := CountAccepted(10, func(v int32) bool { return v%2 == 0 }); got != 5 {
+		t.Errorf("CountAccepted(10, even) = %d, want 5", got)
+	}
+	// Every result goes back to C++, not just those before the first false
+	if got := CountAccepted(10, func(v int32) bool { return v%3 == 0 }); got != 4 {
+		t.Errorf("CountAccepted(10, multiple of 3) = %d, want 4", got)
+	}
+}
+
+func TestPolicyGetsBorrowedItems(t *testing.T) {
+	s := NewShelf()
+	defer s.Delete()
+	for _, w := range []int32{4, 12, 7, 20} {
+		s.Add(w)
+	}
+
+	seen := 0
+	heavy := s.TotalWeight(func(item *Item) bool {
+		seen++
+		return item.Weight() >= 10
+	})
+	if heavy != 32 || seen != 4 {
+		t.Errorf("TotalWeight(heavy) = %d after %d calls, want 32 after 4", heavy, seen)
+	}
+	if got := s.TotalWeight(func(item *Item) bool { return false }); got != 0 {
+		t.Errorf("TotalWeight(none) = %d, want 0", got)
+	}
+}
+
+func TestPanicInPolicy(t *testing.T) {
+	calls := 0
+	defer func() {
+		if r := recover(); r != "stop" {
+			t.Fatalf("CountAccepted panicked with %v, want stop", r)
+		}
+		if calls != 1 {
+			t.Errorf("pred was called %d times, want 1", calls)
+		}
+	}()
+	CountAccepted(10, func(v int32) bool {
+		calls++
+		panic("stop")
+	})
+}
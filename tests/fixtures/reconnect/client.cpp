@@ -0,0 +1,70 @@
+#include "client.h"
+
+namespace {
+std::atomic<int64_t> generation{0};
+std::atomic<int32_t> dead_calls{0};
+std::atomic<int32_t> live{0};
+}
+
+void dropConnections() {
+    generation++;
+}
+
+int32_t deadHandleCalls() {
+    return dead_calls;
+}
+
+int32_t liveSessions() {
+    return live;
+}
+
+Session::Session(int32_t port) : port_(port), generation_(generation), sent_(0), dead_(false) {
+    live++;
+}
+
+// Safe on a dead session, so the bindings delete invalidated handles too
+Session::~Session() {
+    live--;
+}
+
+Session* Session::open(int32_t port) {
+    return new Session(port);
+}
+
+bool Session::alive() {
+    if (dead_) {
+        // The real library has undefined behavior here
+        dead_calls++;
+        return false;
+    }
+    if (generation_ != generation) {
+        dead_ = true;
+        return false;
+    }
+    return true;
+}
+
+int32_t Session::send(int32_t message) {
+    if (!alive()) {
+        return MYLIB_ERR_DISCONNECTED;
+    }
+    if (message < 0) {
+        return MYLIB_ERR_BUSY;
+    }
+    sent_++;
+    return MYLIB_OK;
+}
+
+int32_t Session::ping() {
+    return alive() ? MYLIB_OK : MYLIB_ERR_DISCONNECTED;
+}
+
+int32_t Session::sent() {
+    alive();
+    return sent_;
+}
+
+int32_t Session::port() {
+    alive();
+    return port_;
+}
@@ -0,0 +1,46 @@
+#pragma once
+#include <atomic>
+#include <cstdint>
+
+constexpr int32_t MYLIB_OK = 0;
+constexpr int32_t MYLIB_ERR_BUSY = -2;
+constexpr int32_t MYLIB_ERR_DISCONNECTED = -3;
+
+/// Drops every open connection: each session fails its next call with
+/// MYLIB_ERR_DISCONNECTED, after which its handle must not be used.
+void dropConnections();
+
+/// Number of calls made on a session after it reported MYLIB_ERR_DISCONNECTED.
+int32_t deadHandleCalls();
+
+/// Number of sessions constructed and not yet destroyed.
+int32_t liveSessions();
+
+class Session {
+public:
+    Session(int32_t port);
+    ~Session();
+
+    /// Opens a session on port, as the reconnection factory.
+    static Session* open(int32_t port);
+
+    // @status
+    /// Sends message; MYLIB_ERR_BUSY for a negative one.
+    int32_t send(int32_t message);
+
+    // @status
+    int32_t ping();
+
+    /// Number of messages sent on this session.
+    int32_t sent();
+
+    int32_t port();
+
+private:
+    bool alive();
+
+    int32_t port_;
+    int64_t generation_;
+    std::atomic<int32_t> sent_;
+    std::atomic<bool> dead_;
+};
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// recovered runs fn and returns the value it panicked with, or nil
+func recovered(fn func()) (value any) {
+	defer func() {
+		value = recover()
+	}()
+	fn()
+	return nil
+}
+
+func TestDisconnectInvalidatesHandle(t *testing.T) {
+	s := NewSession(7)
+	defer s.Delete()
+	if err := s.Send(1); err != nil {
+		t.Fatalf("Send(1) = %v, want nil", err)
+	}
+
+	DropConnections()
+	dead := DeadHandleCalls()
+	err := s.Send(2)
+	if !errors.Is(err, ErrHandleInvalidated) {
+		t.Fatalf("Send after a disconnect = %v, want ErrHandleInvalidated", err)
+	}
+	var status *StatusError
+	if !errors.As(err, &status) || status.Code != MylibErrDisconnected {
+		t.Fatalf("Send after a disconnect = %v, want status %d", err, MylibErrDisconnected)
+	}
+
+	if err := s.Ping(); err != ErrHandleInvalidated {
+		t.Fatalf("Ping on an invalidated handle = %v, want ErrHandleInvalidated", err)
+	}
+	if value := recovered(func() { s.Sent() }); value != ErrHandleInvalidated {
+		t.Fatalf("Sent on an invalidated handle panicked with %v, want ErrHandleInvalidated", value)
+	}
+	if got := DeadHandleCalls(); got != dead {
+		t.Fatalf("%d calls reached the dead handle", got-dead)
+	}
+}
+
+func TestOtherStatusKeepsHandle(t *testing.T) {
+	s := NewSession(7)
+	defer s.Delete()
+
+	err := s.Send(-1)
+	var status *StatusError
+	if !errors.As(err, &status) || status.Code != -2 || errors.Is(err, ErrHandleInvalidated) {
+		t.Fatalf("Send(-1) = %v, want status -2 alone", err)
+	}
+	if err := s.Ping(); err != nil {
+		t.Fatalf("Ping after MYLIB_ERR_BUSY = %v, want nil", err)
+	}
+}
+
+func TestReconnectKeepsWrapper(t *testing.T) {
+	s := NewSession(7)
+	defer s.Delete()
+	wrapper := s
+	if err := s.Send(1); err != nil {
+		t.Fatalf("Send(1) = %v, want nil", err)
+	}
+	if err := s.Reconnect(9); err != nil || s.Port() != 7 {
+		t.Fatalf("Reconnect on a valid handle = %v, port %d; want a no-op", err, s.Port())
+	}
+
+	DropConnections()
+	dead := DeadHandleCalls()
+	if err := s.Ping(); !errors.Is(err, ErrHandleInvalidated) {
+		t.Fatalf("Ping after a disconnect = %v, want ErrHandleInvalidated", err)
+	}
+	if err := s.Reconnect(9); err != nil {
+		t.Fatalf("Reconnect(9) = %v, want nil", err)
+	}
+	if s != wrapper || s.Port() != 9 || s.Sent() != 0 {
+		t.Fatalf("after Reconnect(9): port %d, sent %d; want a new session on the same wrapper", s.Port(), s.Sent())
+	}
+	if err := s.Send(2); err != nil {
+		t.Fatalf("Send after Reconnect = %v, want nil", err)
+	}
+	if got := DeadHandleCalls(); got != dead {
+		t.Fatalf("%d calls reached the dead handle", got-dead)
+	}
+}
+
+func TestInvalidatedHandleIsFreed(t *testing.T) {
+	live := LiveSessions()
+	s := NewSession(7)
+	DropConnections()
+	if err := s.Ping(); !errors.Is(err, ErrHandleInvalidated) {
+		t.Fatalf("Ping after a disconnect = %v, want ErrHandleInvalidated", err)
+	}
+	if err := s.Reconnect(9); err != nil {
+		t.Fatalf("Reconnect(9) = %v, want nil", err)
+	}
+	if got := LiveSessions(); got != live+1 {
+		t.Fatalf("after Reconnect: %d live sessions, want %d", got, live+1)
+	}
+
+	DropConnections()
+	if err := s.Ping(); !errors.Is(err, ErrHandleInvalidated) {
+		t.Fatalf("Ping after a disconnect = %v, want ErrHandleInvalidated", err)
+	}
+	s.Delete()
+	if got := LiveSessions(); got != live {
+		t.Fatalf("after Delete: %d live sessions, want %d", got, live)
+	}
+}
+
+func TestConcurrentCallsAcrossDisconnects(t *testing.T) {
+	s := NewSession(7)
+	defer s.Delete()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				if err := s.Ping(); err != nil && !errors.Is(err, ErrHandleInvalidated) {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		DropConnections()
+		for s.Ping() == nil {
+		}
+		if err := s.Reconnect(7); err != nil {
+			t.Fatalf("Reconnect(7) = %v, want nil", err)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Ping = %v, want nil or ErrHandleInvalidated", err)
+	}
+	if err := s.Ping(); err != nil {
+		t.Fatalf("Ping after the last Reconnect = %v, want nil", err)
+	}
+}
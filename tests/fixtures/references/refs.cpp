@@ -0,0 +1,36 @@
+#include "refs.h"
+
+void doubleInPlace(int32_t& value) {
+    value *= 2;
+}
+
+bool divide(int32_t a, int32_t b, int32_t& quotient, int32_t& remainder) {
+    if (b == 0) {
+        return false;
+    }
+    quotient = a / b;
+    remainder = a % b;
+    return true;
+}
+
+void accumulate(int32_t delta, int64_t& out_total) {
+    out_total += delta;
+}
+
+Range::Range(int32_t low, int32_t high) : low_(low), high_(high) {}
+
+bool Range::clamp(int32_t& value) const {
+    int32_t clamped = value < low_ ? low_ : value > high_ ? high_ : value;
+    bool changed = clamped != value;
+    value = clamped;
+    return changed;
+}
+
+void Range::bounds(int32_t& outLow, int32_t& outHigh) const {
+    outLow = low_;
+    outHigh = high_;
+}
+
+void Range::contains(int32_t value, bool& out_flag) const {
+    out_flag = value >= low_ && value <= high_;
+}
@@ -0,0 +1,34 @@
+#pragma once
+#include <cstdint>
+
+/// Doubles value in place.
+void doubleInPlace(int32_t& value);
+
+/**
+ * Splits a into quotient and remainder.
+ * @param[out] quotient a / b
+ * @param[out] remainder a % b
+ * @return false if b is 0
+ */
+bool divide(int32_t a, int32_t b, int32_t& quotient, int32_t& remainder);
+
+// @inout out_total
+/// Adds delta to a running total that the caller keeps.
+void accumulate(int32_t delta, int64_t& out_total);
+
+class Range {
+public:
+    Range(int32_t low, int32_t high);
+
+    /// Clamps value into the range; returns whether it changed.
+    bool clamp(int32_t& value) const;
+
+    void bounds(int32_t& outLow, int32_t& outHigh) const;
+
+    /// Sets flag to whether value lies inside the range.
+    void contains(int32_t value, bool& out_flag) const;
+
+private:
+    int32_t low_;
+    int32_t high_;
+};
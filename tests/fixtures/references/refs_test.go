@@ -0,0 +1,59 @@
+package refs
+
+import "testing"
+
+func TestInOutDoublesInPlace(t *testing.T) {
+	value := int32(21)
+	DoubleInPlace(&value)
+	if value != 42 {
+		t.Fatalf("value = %d after DoubleInPlace, want 42", value)
+	}
+	DoubleInPlace(&value)
+	if value != 84 {
+		t.Fatalf("value = %d after second DoubleInPlace, want 84", value)
+	}
+}
+
+func TestOutParametersBecomeResults(t *testing.T) {
+	ok, quotient, remainder := Divide(17, 5)
+	if !ok || quotient != 3 || remainder != 2 {
+		t.Fatalf("Divide(17, 5) = %v, %d, %d; want true, 3, 2", ok, quotient, remainder)
+	}
+	if ok, _, _ := Divide(1, 0); ok {
+		t.Fatal("Divide(1, 0) succeeded")
+	}
+}
+
+func TestInOutAnnotationOverridesName(t *testing.T) {
+	total := int64(100)
+	Accumulate(5, &total)
+	Accumulate(-20, &total)
+	if total != 85 {
+		t.Fatalf("total = %d, want 85", total)
+	}
+}
+
+func TestMethods(t *testing.T) {
+	r := NewRange(0, 10)
+	defer r.Delete()
+
+	value := int32(15)
+	if !r.Clamp(&value) || value != 10 {
+		t.Fatalf("Clamp = %d, want 10 and changed", value)
+	}
+	if low, high := r.Bounds(); low != 0 || high != 10 {
+		t.Fatalf("Bounds() = %d, %d; want 0, 10", low, high)
+	}
+	if !r.Contains(3) || r.Contains(11) {
+		t.Fatal("Contains disagrees with the range")
+	}
+}
+
+func TestNilInOutPanicsInGo(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("DoubleInPlace(nil) did not panic")
+		}
+	}()
+	DoubleInPlace(nil)
+}
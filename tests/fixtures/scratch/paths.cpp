@@ -0,0 +1,35 @@
+#include "paths.h"
+
+#include <stdexcept>
+
+void join_path(const std::string& dir, const std::string& name, const std::string& ext, std::string& out_path) {
+    out_path = dir + "/" + name + "." + ext;
+}
+
+int32_t common_prefix(const char* from, const char* to) {
+    if (*from == '\0' || *to == '\0') {
+        throw std::invalid_argument("empty path");
+    }
+    int32_t n = 0;
+    while (from[n] != '\0' && from[n] == to[n]) {
+        ++n;
+    }
+    return n;
+}
+
+Registry::Registry() : size_(0), bytes_(0) {}
+
+Registry::~Registry() = default;
+
+void Registry::put(const std::string& key, const std::string& value) {
+    ++size_;
+    bytes_ += static_cast<int64_t>(key.size() + value.size());
+}
+
+int64_t Registry::bytes() const {
+    return bytes_;
+}
+
+int32_t Registry::size() const {
+    return size_;
+}
@@ -0,0 +1,27 @@
+#pragma once
+#include <cstdint>
+#include <string>
+
+/// Joins dir, name and ext as dir/name.ext.
+void join_path(const std::string& dir, const std::string& name, const std::string& ext, std::string& out_path);
+
+// @throws
+/// Number of bytes from and to have in common as a prefix; throws
+/// std::invalid_argument when either is empty.
+int32_t common_prefix(const char* from, const char* to);
+
+/// Remembers one value per key.
+class Registry {
+public:
+    Registry();
+    ~Registry();
+
+    void put(const std::string& key, const std::string& value);
+    /// Total bytes of the keys and values put.
+    int64_t bytes() const;
+    int32_t size() const;
+
+private:
+    int32_t size_;
+    int64_t bytes_;
+};
@@ -0,0 +1,52 @@
+package paths
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStringsShareTheArenaIntact(t *testing.T) {
+	for _, tc := range []struct {
+		dir, name, ext, want string
+	}{
+		{"usr", "lib", "so", "usr/lib.so"},
+		{"", "", "", "/."},
+		{"a", "", "c", "a/.c"},
+		{strings.Repeat("d", 4096), "x", strings.Repeat("e", 300), strings.Repeat("d", 4096) + "/x." + strings.Repeat("e", 300)},
+	} {
+		if got := JoinPath(tc.dir, tc.name, tc.ext); got != tc.want {
+			t.Errorf("JoinPath(%d, %d, %d bytes) = %d bytes, want %d", len(tc.dir), len(tc.name), len(tc.ext), len(got), len(tc.want))
+		}
+	}
+}
+
+func TestErrorReturnStillFreesTheArena(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		if _, err := CommonPrefix("", "/tmp"); err == nil {
+			t.Fatal("CommonPrefix(\"\", \"/tmp\") returned no error")
+		}
+	}
+	var exception *ExceptionError
+	if _, err := CommonPrefix("/tmp", ""); !errors.As(err, &exception) || exception.What != "empty path" {
+		t.Fatalf("CommonPrefix(\"/tmp\", \"\") error = %v, want an *ExceptionError for \"empty path\"", err)
+	}
+	n, err := CommonPrefix("/usr/local/lib", "/usr/bin")
+	if err != nil || n != 5 {
+		t.Fatalf("CommonPrefix = %d, %v, want 5, nil", n, err)
+	}
+}
+
+func TestMethodStrings(t *testing.T) {
+	r := NewRegistry()
+	defer r.Delete()
+	r.Put("alpha", "one")
+	r.Put("", "two")
+	r.Put("gamma", "")
+	if got := r.Size(); got != 3 {
+		t.Errorf("Size() = %d, want 3", got)
+	}
+	if got := r.Bytes(); got != 16 {
+		t.Errorf("Bytes() = %d, want 16", got)
+	}
+}
@@ -0,0 +1,45 @@
+#include "netlib.h"
+
+#include <csignal>
+#include <unistd.h>
+
+namespace {
+
+volatile sig_atomic_t broken_pipes = 0;
+
+void reportCrash(int, siginfo_t*, void*) {
+    const char message[] = "netlib: crashed\n";
+    write(2, message, sizeof(message) - 1);
+    _exit(3);
+}
+
+void countBrokenPipe(int) {
+    broken_pipes = broken_pipes + 1;
+}
+
+} // namespace
+
+int32_t netlib_init() {
+    struct sigaction crash = {};
+    crash.sa_sigaction = reportCrash;
+    crash.sa_flags = SA_SIGINFO;
+    sigaction(SIGSEGV, &crash, nullptr);
+
+    struct sigaction pipe = {};
+    pipe.sa_handler = countBrokenPipe;
+    sigaction(SIGPIPE, &pipe, nullptr);
+    return 0;
+}
+
+void netlib_shutdown() {
+    signal(SIGSEGV, SIG_DFL);
+    signal(SIGPIPE, SIG_DFL);
+}
+
+int32_t netlib_broken_pipes() {
+    return broken_pipes;
+}
+
+void netlib_raise_broken_pipe() {
+    raise(SIGPIPE);
+}
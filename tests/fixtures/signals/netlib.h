@@ -0,0 +1,14 @@
+#pragma once
+#include <cstdint>
+
+/// Installs the library's crash reporter for SIGSEGV and counts SIGPIPEs.
+int32_t netlib_init();
+
+/// Resets SIGSEGV and SIGPIPE to their default actions.
+void netlib_shutdown();
+
+/// Number of SIGPIPEs the library's handler has seen.
+int32_t netlib_broken_pipes();
+
+/// Raises SIGPIPE on the calling thread, as a write to a closed socket would.
+void netlib_raise_broken_pipe();
@@ -0,0 +1,47 @@
+package netlib
+
+import "testing"
+
+var sink int
+
+// dereferenceNil fails with the runtime error a nil pointer dereference
+// panics with, which needs the Go runtime's SIGSEGV handler
+func dereferenceNil(t *testing.T) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Fatal("nil pointer dereference did not panic")
+		}
+	}()
+	var p *int
+	sink = *p
+}
+
+func TestPanicsRecoverAfterInit(t *testing.T) {
+	if got := NetlibInit(); got != 0 {
+		t.Fatalf("NetlibInit() = %d, want 0", got)
+	}
+	dereferenceNil(t)
+}
+
+func TestChainedSignalReachesLibrary(t *testing.T) {
+	NetlibInit()
+	before := NetlibBrokenPipes()
+	NetlibRaiseBrokenPipe()
+	if got := NetlibBrokenPipes(); got != before+1 {
+		t.Errorf("NetlibBrokenPipes() = %d, want %d", got, before+1)
+	}
+	dereferenceNil(t)
+}
+
+func TestPanicsRecoverAfterShutdown(t *testing.T) {
+	NetlibInit()
+	NetlibShutdown()
+	dereferenceNil(t)
+	// SIGPIPE is Go's alone again, which ignores it
+	before := NetlibBrokenPipes()
+	NetlibRaiseBrokenPipe()
+	if got := NetlibBrokenPipes(); got != before {
+		t.Errorf("NetlibBrokenPipes() = %d after shutdown, want %d", got, before)
+	}
+}
@@ -0,0 +1,29 @@
+#include "diag.h"
+#include <thread>
+
+namespace {
+void (*installed_handler)(LogLevel, const char*, void*) = nullptr;
+void* installed_context = nullptr;
+int32_t dropped = 0;
+}
+
+void set_log_handler(void (*handler)(LogLevel level, const char* message, void* context), void* context) {
+    installed_handler = handler;
+    installed_context = context;
+}
+
+void log_message(LogLevel level, const char* message) {
+    if (installed_handler) {
+        installed_handler(level, message, installed_context);
+    } else {
+        dropped++;
+    }
+}
+
+void log_from_thread(LogLevel level, const char* message) {
+    std::thread([level, message] { log_message(level, message); }).join();
+}
+
+int32_t dropped_messages() {
+    return dropped;
+}
@@ -0,0 +1,24 @@
+#pragma once
+#include <cstdint>
+
+/// Severity of a log message.
+enum class LogLevel : int32_t {
+    Trace,
+    Debug,
+    Info,
+    Warning,
+    Error
+};
+
+/// Installs the handler every message is logged through; nullptr removes it.
+// @logger
+void set_log_handler(void (*handler)(LogLevel level, const char* message, void* context), void* context);
+
+/// Logs message at level through the installed handler.
+void log_message(LogLevel level, const char* message);
+
+/// Logs message at level from a thread of its own and waits for it.
+void log_from_thread(LogLevel level, const char* message);
+
+/// Number of messages logged while no handler was installed.
+int32_t dropped_messages();
@@ -0,0 +1,87 @@
+package diag
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+)
+
+// recorder is a slog.Handler keeping every record it handles.
+type recorder struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (r *recorder) Enabled(context.Context, slog.Level) bool { return true }
+
+func (r *recorder) Handle(_ context.Context, record slog.Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, record)
+	return nil
+}
+
+func (r *recorder) WithAttrs([]slog.Attr) slog.Handler { return r }
+
+func (r *recorder) WithGroup(string) slog.Handler { return r }
+
+func (r *recorder) last(t *testing.T) slog.Record {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.records) == 0 {
+		t.Fatal("no record was logged")
+	}
+	return r.records[len(r.records)-1]
+}
+
+func TestLevelsBecomeSlogLevels(t *testing.T) {
+	r := &recorder{}
+	SetLogger(slog.New(r))
+	defer SetLogger(nil)
+
+	for _, tc := range []struct {
+		level LogLevel
+		want  slog.Level
+	}{
+		{LogLevelTrace, slog.LevelDebug},
+		{LogLevelDebug, slog.LevelDebug},
+		{LogLevelInfo, slog.LevelInfo},
+		{LogLevelWarning, slog.LevelWarn},
+		{LogLevelError, slog.LevelError},
+	} {
+		LogMessage(tc.level, "disk almost full")
+		record := r.last(t)
+		if record.Level != tc.want || record.Message != "disk almost full" {
+			t.Fatalf("LogLevel %d logged %v %q, want %v %q", tc.level, record.Level, record.Message,
+				tc.want, "disk almost full")
+		}
+	}
+}
+
+func TestLogFromCppThread(t *testing.T) {
+	r := &recorder{}
+	SetLogger(slog.New(r))
+	defer SetLogger(nil)
+
+	LogFromThread(LogLevelError, "worker failed")
+	if record := r.last(t); record.Level != slog.LevelError || record.Message != "worker failed" {
+		t.Fatalf("logged %v %q, want ERROR %q", record.Level, record.Message, "worker failed")
+	}
+}
+
+func TestNilLoggerUninstallsHandler(t *testing.T) {
+	r := &recorder{}
+	SetLogger(slog.New(r))
+	SetLogger(nil)
+
+	before := DroppedMessages()
+	LogMessage(LogLevelInfo, "nobody listens")
+	if got := DroppedMessages(); got != before+1 {
+		t.Fatalf("DroppedMessages() = %d, want %d", got, before+1)
+	}
+	if len(r.records) != 0 {
+		t.Fatalf("uninstalled logger got %d records", len(r.records))
+	}
+}
@@ -0,0 +1,31 @@
+#include "tokens.h"
+
+#include <algorithm>
+
+int32_t token_length(const std::string& token) {
+    return static_cast<int32_t>(token.size());
+}
+
+bool same_token(const std::string& a, const std::string& b) {
+    return a == b;
+}
+
+Interner::Interner() {}
+Interner::~Interner() {}
+
+int32_t Interner::intern(const std::string& token) {
+    auto it = std::find(tokens_.begin(), tokens_.end(), token);
+    if (it != tokens_.end()) {
+        return static_cast<int32_t>(it - tokens_.begin());
+    }
+    tokens_.push_back(token);
+    return static_cast<int32_t>(tokens_.size() - 1);
+}
+
+int32_t Interner::count() const {
+    return static_cast<int32_t>(tokens_.size());
+}
+
+int32_t Interner::length_at(int32_t index) const {
+    return static_cast<int32_t>(tokens_.at(index).size());
+}
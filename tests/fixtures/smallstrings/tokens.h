@@ -0,0 +1,26 @@
+#pragma once
+#include <cstdint>
+#include <string>
+#include <vector>
+
+/// Counts the bytes of token up to its first NUL, as C++ receives it.
+int32_t token_length(const std::string& token);
+
+/// Whether a and b are the same token.
+bool same_token(const std::string& a, const std::string& b);
+
+/// Keeps one copy of each token it is given.
+class Interner {
+public:
+    Interner();
+    virtual ~Interner();
+
+    /// Index of token, which is added if it is new.
+    int32_t intern(const std::string& token);
+    int32_t count() const;
+    /// Length of the token at index.
+    int32_t length_at(int32_t index) const;
+
+private:
+    std::vector<std::string> tokens_;
+};
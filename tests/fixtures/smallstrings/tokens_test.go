@@ -0,0 +1,71 @@
+package tokens
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLengthsAroundBufferSize(t *testing.T) {
+	for _, n := range []int{0, 1, smallStringSize - 1, smallStringSize, smallStringSize + 1, 100} {
+		token := strings.Repeat("x", n)
+		if got := TokenLength(token); got != int32(n) {
+			t.Errorf("TokenLength(%d bytes) = %d", n, got)
+		}
+	}
+}
+
+func TestEmbeddedNulEndsString(t *testing.T) {
+	// C++ sees a const char*, so both paths stop at the first NUL
+	short := "ab\x00cd"
+	long := strings.Repeat("y", smallStringSize+2) + "\x00tail"
+	if got := TokenLength(short); got != 2 {
+		t.Errorf("TokenLength(%q) = %d, want 2", short, got)
+	}
+	if got := TokenLength(long); got != int32(smallStringSize+2) {
+		t.Errorf("TokenLength(%q) = %d, want %d", long, got, smallStringSize+2)
+	}
+	if !SameToken("ab\x00cd", "ab") {
+		t.Error("SameToken ignored the NUL")
+	}
+}
+
+func TestMixedLengths(t *testing.T) {
+	long := strings.Repeat("z", smallStringSize+1)
+	if SameToken(long[:smallStringSize], long) {
+		t.Error("SameToken matched tokens of different lengths")
+	}
+	if !SameToken(long, strings.Clone(long)) {
+		t.Error("SameToken failed on equal long tokens")
+	}
+}
+
+func TestInternerKeepsCopies(t *testing.T) {
+	in := NewInterner()
+	defer in.Delete()
+
+	// Each call reuses a stack buffer, so C++ must have copied the first token
+	first := in.Intern("abcdefgh")
+	in.Intern("zz")
+	if got := in.Intern("abcdefgh"); got != first {
+		t.Errorf("Intern returned %d for a known token, want %d", got, first)
+	}
+	if got := in.LengthAt(first); got != 8 {
+		t.Errorf("LengthAt(%d) = %d, want 8", first, got)
+	}
+	if got := in.Count(); got != 2 {
+		t.Errorf("Count() = %d, want 2", got)
+	}
+}
+
+func TestShortStringsDoNotAllocate(t *testing.T) {
+	in := NewInterner()
+	defer in.Delete()
+	in.Intern("token")
+
+	if allocs := testing.AllocsPerRun(100, func() { TokenLength("token") }); allocs != 0 {
+		t.Errorf("TokenLength allocated %v times per call", allocs)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { in.Intern("token") }); allocs != 0 {
+		t.Errorf("Intern allocated %v times per call", allocs)
+	}
+}
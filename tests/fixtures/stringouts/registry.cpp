@@ -0,0 +1,47 @@
+#include "registry.h"
+
+#include <map>
+
+namespace {
+
+std::map<std::string, std::string>& values() {
+    static std::map<std::string, std::string> values;
+    return values;
+}
+
+}
+
+void store(const std::string& key, const std::string& value) {
+    values()[key] = value;
+}
+
+bool lookup(const std::string& key, std::string& out_value) {
+    auto it = values().find(key);
+    if (it == values().end()) {
+        return false;
+    }
+    out_value = it->second;
+    return true;
+}
+
+void repeat(std::string& text, int32_t times) {
+    std::string repeated;
+    for (int32_t i = 0; i < times; ++i) {
+        repeated += text;
+    }
+    text = repeated;
+}
+
+Page::Page(size_t size) : size_(size) {}
+
+void Page::contents(std::string& out) const {
+    out.assign(size_, 'x');
+    if (size_ > 1) {
+        out[size_ / 2] = '\0';
+    }
+}
+
+void Page::title(std::string& out_title, int32_t& out_length) const {
+    out_title = "page of " + std::to_string(size_);
+    out_length = static_cast<int32_t>(out_title.size());
+}
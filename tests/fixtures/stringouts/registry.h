@@ -0,0 +1,27 @@
+#pragma once
+#include <cstddef>
+#include <cstdint>
+#include <string>
+
+/// Stores value under key, replacing any earlier value.
+void store(const std::string& key, const std::string& value);
+
+/// Finds the value stored under key; an empty value is still found.
+bool lookup(const std::string& key, std::string& out_value);
+
+/// Repeats text times times in place.
+void repeat(std::string& text, int32_t times);
+
+class Page {
+public:
+    explicit Page(size_t size);
+
+    /// Fills out with the page contents, which may hold NUL bytes.
+    void contents(std::string& out) const;
+
+    /// Writes the page title and its length.
+    void title(std::string& out_title, int32_t& out_length) const;
+
+private:
+    size_t size_;
+};
@@ -0,0 +1,46 @@
+package registry
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLookupTellsEmptyFromMissing(t *testing.T) {
+	Store("color", "blue")
+	Store("empty", "")
+	if value, ok := Lookup("color"); !ok || value != "blue" {
+		t.Fatalf("Lookup(color) = %q, %v; want blue, true", value, ok)
+	}
+	if value, ok := Lookup("empty"); !ok || value != "" {
+		t.Fatalf("Lookup(empty) = %q, %v; want \"\", true", value, ok)
+	}
+	if value, ok := Lookup("missing"); ok || value != "" {
+		t.Fatalf("Lookup(missing) = %q, %v; want \"\", false", value, ok)
+	}
+}
+
+func TestInOutStringIsReplaced(t *testing.T) {
+	text := "ab\x00"
+	Repeat(&text, 3)
+	if text != "ab\x00ab\x00ab\x00" {
+		t.Fatalf("text = %q after Repeat, want three copies", text)
+	}
+	Repeat(&text, 0)
+	if text != "" {
+		t.Fatalf("text = %q after Repeat(0), want empty", text)
+	}
+}
+
+func TestLargeOutputKeepsEveryByte(t *testing.T) {
+	const size = 8 << 20
+	page := NewPage(size)
+	defer page.Delete()
+	contents := page.Contents()
+	if len(contents) != size || contents[size/2] != 0 || strings.Count(contents, "x") != size-1 {
+		t.Fatalf("Contents returned %d bytes, want %d x's with one NUL", len(contents), size)
+	}
+	title, length := page.Title()
+	if title != "page of 8388608" || int(length) != len(title) {
+		t.Fatalf("Title() = %q, %d", title, length)
+	}
+}
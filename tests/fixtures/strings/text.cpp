@@ -0,0 +1,27 @@
+#include "text.h"
+
+int32_t byteLength(const std::string& text) {
+    return static_cast<int32_t>(text.size());
+}
+
+int32_t countOccurrences(const std::string& haystack, std::string needle) {
+    if (needle.empty()) {
+        return 0;
+    }
+    int32_t count = 0;
+    for (size_t pos = haystack.find(needle); pos != std::string::npos;
+         pos = haystack.find(needle, pos + needle.size())) {
+        count++;
+    }
+    return count;
+}
+
+Builder::Builder() {}
+
+void Builder::append(const std::string& text) {
+    buffer_ += text;
+}
+
+int32_t Builder::size() const {
+    return static_cast<int32_t>(buffer_.size());
+}
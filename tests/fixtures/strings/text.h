@@ -0,0 +1,19 @@
+#pragma once
+#include <cstdint>
+#include <string>
+
+/// Counts the bytes of text, exercising Go string to std::string conversion.
+int32_t byteLength(const std::string& text);
+
+/// Counts occurrences of needle in haystack.
+int32_t countOccurrences(const std::string& haystack, std::string needle);
+
+class Builder {
+public:
+    Builder();
+    void append(const std::string& text);
+    int32_t size() const;
+
+private:
+    std::string buffer_;
+};
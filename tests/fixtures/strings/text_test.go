@@ -0,0 +1,27 @@
+package text
+
+import "testing"
+
+func TestStringArguments(t *testing.T) {
+	if got := ByteLength("héllo"); got != 6 {
+		t.Fatalf("ByteLength = %d, want 6", got)
+	}
+	if got := ByteLength(""); got != 0 {
+		t.Fatalf("ByteLength(\"\") = %d, want 0", got)
+	}
+	if got := CountOccurrences("abcabcab", "ab"); got != 3 {
+		t.Fatalf("CountOccurrences = %d, want 3", got)
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	b := NewBuilder()
+	defer b.Delete()
+
+	for i := 0; i < 100; i++ {
+		b.Append("go")
+	}
+	if got := b.Size(); got != 200 {
+		t.Fatalf("Size() = %d, want 200", got)
+	}
+}
@@ -0,0 +1,49 @@
+#include "settings.h"
+
+namespace {
+int32_t constructed = 0;
+int32_t live = 0;
+}
+
+void defaultConfig(Config* out) {
+    out->retries = 3;
+    out->timeout = 1.5;
+    out->verbose = false;
+}
+
+int32_t loadPreset(int32_t id, Config* out) {
+    if (id != 1) {
+        return 2;
+    }
+    out->retries = 10;
+    out->timeout = 30.0;
+    out->verbose = true;
+    return 0;
+}
+
+void relax(Config* config) {
+    config->timeout *= 2;
+}
+
+Counted::Counted() : value(-1), serial(++constructed) {
+    live++;
+}
+
+Counted::~Counted() {
+    live--;
+}
+
+void fillCounted(Counted* out) {
+    // The constructor ran first: serial is already set
+    out->value = out->serial > 0 ? 7 : 0;
+}
+
+int32_t liveCounted() {
+    return live;
+}
+
+Store::Store() : current_{5, 2.0, true} {}
+
+void Store::snapshot(Config& out) const {
+    out = current_;
+}
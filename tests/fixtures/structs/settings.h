@@ -0,0 +1,46 @@
+#pragma once
+#include <cstdint>
+
+// @mirror
+struct Config {
+    int32_t retries;
+    double timeout;
+    bool verbose;
+};
+
+/// Fills out with the built-in defaults.
+void defaultConfig(Config* out);
+
+// @status
+/// Loads a numbered preset into out; returns 2 if there is no such preset.
+int32_t loadPreset(int32_t id, Config* out);
+
+/// Doubles the timeout of config in place.
+void relax(Config* config);
+
+// @mirror
+/// Counted needs construction and destruction, so outputs use a shim temporary.
+struct Counted {
+    Counted();
+    ~Counted();
+
+    int32_t value;
+    int32_t serial;
+};
+
+/// Sets the value of out to 7.
+void fillCounted(Counted* out);
+
+/// Number of Counted objects constructed and not yet destroyed.
+int32_t liveCounted();
+
+class Store {
+public:
+    Store();
+
+    /// Copies the current configuration into out.
+    void snapshot(Config& out) const;
+
+private:
+    Config current_;
+};
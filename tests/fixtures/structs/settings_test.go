@@ -0,0 +1,64 @@
+package settings
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOutputStructReturnedByValue(t *testing.T) {
+	config := DefaultConfig()
+	if config.Retries != 3 || config.Timeout != 1.5 || config.Verbose {
+		t.Fatalf("DefaultConfig() = %+v, want {3 1.5 false}", config)
+	}
+}
+
+func TestStatusCodeBecomesError(t *testing.T) {
+	config, err := LoadPreset(1)
+	if err != nil {
+		t.Fatalf("LoadPreset(1) failed: %v", err)
+	}
+	if config.Retries != 10 || config.Timeout != 30 || !config.Verbose {
+		t.Fatalf("LoadPreset(1) = %+v, want {10 30 true}", config)
+	}
+
+	_, err = LoadPreset(9)
+	var status *StatusError
+	if !errors.As(err, &status) || status.Code != 2 {
+		t.Fatalf("LoadPreset(9) error = %v, want status 2", err)
+	}
+}
+
+func TestInOutStructPointer(t *testing.T) {
+	config := Config{Retries: 1, Timeout: 4}
+	Relax(&config)
+	if config.Timeout != 8 || config.Retries != 1 {
+		t.Fatalf("Relax = %+v, want timeout 8 and retries unchanged", config)
+	}
+}
+
+func TestNonTrivialOutputUsesShimTemporary(t *testing.T) {
+	counted := FillCounted()
+	if counted.Value != 7 || counted.Serial <= 0 {
+		t.Fatalf("FillCounted() = %+v, want a constructed value of 7", counted)
+	}
+	if live := LiveCounted(); live != 0 {
+		t.Fatalf("LiveCounted() = %d after copy-out, want 0", live)
+	}
+}
+
+func TestReferenceOutputOnMethod(t *testing.T) {
+	s := NewStore()
+	defer s.Delete()
+	if config := s.Snapshot(); config.Retries != 5 || config.Timeout != 2 || !config.Verbose {
+		t.Fatalf("Snapshot() = %+v, want {5 2 true}", config)
+	}
+}
+
+func TestNilInOutStructPanicsInGo(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Relax(nil) did not panic")
+		}
+	}()
+	Relax(nil)
+}
@@ -0,0 +1,29 @@
+#include "samples.h"
+
+Samples::Samples(size_t count) : values_(count) {}
+
+int32_t& Samples::operator[](size_t i) {
+    return values_[i];
+}
+
+const int32_t& Samples::operator[](size_t i) const {
+    return values_[i];
+}
+
+size_t Samples::size() const {
+    return values_.size();
+}
+
+int64_t Samples::total() const {
+    int64_t sum = 0;
+    for (int32_t value : values_) {
+        sum += value;
+    }
+    return sum;
+}
+
+Curve::Curve() {}
+
+double Curve::operator[](int i) const {
+    return 1.0 / (1 << i);
+}
@@ -0,0 +1,29 @@
+#pragma once
+#include <cstddef>
+#include <cstdint>
+#include <vector>
+
+/// A fixed number of samples, zero until written.
+class Samples {
+public:
+    explicit Samples(size_t count);
+
+    int32_t& operator[](size_t i);
+    const int32_t& operator[](size_t i) const;
+
+    size_t size() const;
+
+    /// Sum of every sample, computed in C++.
+    int64_t total() const;
+
+private:
+    std::vector<int32_t> values_;
+};
+
+/// Weights of a fixed curve, read-only and without a size.
+class Curve {
+public:
+    Curve();
+
+    double operator[](int i) const;
+};
@@ -0,0 +1,54 @@
+package samples
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAtReadsWhatSetAtWrote(t *testing.T) {
+	samples := NewSamples(4)
+	defer samples.Delete()
+	for i := 0; i < int(samples.Size()); i++ {
+		if samples.At(i) != 0 {
+			t.Fatalf("At(%d) = %d before any write", i, samples.At(i))
+		}
+		samples.SetAt(i, int32(10*(i+1)))
+	}
+	if samples.At(2) != 30 {
+		t.Fatalf("At(2) = %d, want 30", samples.At(2))
+	}
+	// The writes went to the C++ elements themselves
+	if samples.Total() != 100 {
+		t.Fatalf("Total() = %d, want 100", samples.Total())
+	}
+}
+
+func TestIndexOutsideSizePanics(t *testing.T) {
+	samples := NewSamples(2)
+	defer samples.Delete()
+	for _, i := range []int{-1, 2} {
+		func() {
+			defer func() {
+				message, _ := recover().(string)
+				if !strings.Contains(message, "Samples::operator[]: index") {
+					t.Errorf("At(%d) recovered %q, want an index out of range panic", i, message)
+				}
+			}()
+			samples.At(i)
+		}()
+	}
+}
+
+func TestReadOnlySubscript(t *testing.T) {
+	curve := NewCurve()
+	defer curve.Delete()
+	if curve.At(0) != 1 || curve.At(3) != 0.125 {
+		t.Fatalf("At(0), At(3) = %v, %v", curve.At(0), curve.At(3))
+	}
+	// A const operator[] returning by value gives no setter
+	for _, mapping := range Mappings() {
+		if mapping.Go == "Curve.SetAt" {
+			t.Fatalf("Curve.SetAt bound to %s", mapping.C)
+		}
+	}
+}
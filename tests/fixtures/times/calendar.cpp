@@ -0,0 +1,21 @@
+#include "calendar.h"
+
+std::tm echo(const std::tm& when) { return when; }
+
+int32_t year_field(const std::tm* when) { return when->tm_year; }
+
+int32_t month_field(std::tm when) { return when.tm_mon; }
+
+int32_t year_day_field(const std::tm& when) { return when.tm_yday; }
+
+void leap_day(std::tm* out) {
+    *out = std::tm{};
+    out->tm_year = 124;
+    out->tm_mon = 1;
+    out->tm_mday = 29;
+    out->tm_hour = 12;
+    out->tm_min = 30;
+    out->tm_sec = 45;
+}
+
+void next_month(std::tm& when) { ++when.tm_mon; }
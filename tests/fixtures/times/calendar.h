@@ -0,0 +1,21 @@
+#pragma once
+#include <cstdint>
+#include <ctime>
+
+/// Returns when unchanged, through the shim and back.
+std::tm echo(const std::tm& when);
+
+/// tm_year of when, which counts from 1900.
+int32_t year_field(const std::tm* when);
+
+/// tm_mon of when, which counts from 0.
+int32_t month_field(std::tm when);
+
+/// tm_yday of when, which counts from 0.
+int32_t year_day_field(const std::tm& when);
+
+/// Fills out with the fields of 2024-02-29 12:30:45.
+void leap_day(std::tm* out);
+
+/// Moves when one month forward without normalizing it.
+void next_month(std::tm& when);
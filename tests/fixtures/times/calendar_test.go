@@ -0,0 +1,43 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFieldsAreOffset(t *testing.T) {
+	when := time.Date(2024, time.March, 15, 8, 5, 9, 0, time.UTC)
+	if got := YearField(when); got != 124 {
+		t.Errorf("YearField() = %d, want 124", got)
+	}
+	if got := MonthField(when); got != 2 {
+		t.Errorf("MonthField() = %d, want 2", got)
+	}
+	if got := YearDayField(when); got != 74 {
+		t.Errorf("YearDayField() = %d, want 74", got)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	when := time.Date(1999, time.December, 31, 23, 59, 58, 0, time.UTC)
+	if got := Echo(when); !got.Equal(when) {
+		t.Errorf("Echo(%v) = %v", when, got)
+	}
+	// The wall clock is kept, in UTC
+	local := time.Date(2021, time.July, 4, 9, 0, 0, 0, time.FixedZone("EDT", -4*3600))
+	if got, want := Echo(local), time.Date(2021, time.July, 4, 9, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Echo(%v) = %v, want %v", local, got, want)
+	}
+}
+
+func TestOutputs(t *testing.T) {
+	if got, want := LeapDay(), time.Date(2024, time.February, 29, 12, 30, 45, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("LeapDay() = %v, want %v", got, want)
+	}
+	// tm_mon 12 carries into the next year
+	when := time.Date(2023, time.December, 10, 0, 0, 0, 0, time.UTC)
+	NextMonth(&when)
+	if want := time.Date(2024, time.January, 10, 0, 0, 0, 0, time.UTC); !when.Equal(want) {
+		t.Errorf("NextMonth() = %v, want %v", when, want)
+	}
+}
@@ -0,0 +1,111 @@
+#include "tree.h"
+
+Node::Node(int32_t id, int32_t depth) : id_(id), depth_(depth) {}
+int32_t Node::id() const { return id_; }
+int32_t Node::depth() const { return depth_; }
+
+Shape::~Shape() {}
+
+namespace {
+
+class Square : public Shape {
+public:
+    explicit Square(int32_t side) : side_(side) {}
+    Shape* clone() const override { return new Square(side_); }
+    int32_t area() const override { return side_ * side_; }
+
+private:
+    int32_t side_;
+};
+
+int32_t calls = 0;
+int32_t chunks_read = 0;
+
+} // namespace
+
+Tree::Tree() {}
+Tree::~Tree() {
+    for (Shape* shape : shapes_) {
+        delete shape;
+    }
+}
+void Tree::add(int32_t id, int32_t depth) {
+    nodes_.emplace_back(id, depth);
+    shapes_.push_back(new Square(id));
+}
+int32_t Tree::size() const { return static_cast<int32_t>(nodes_.size()); }
+
+void Tree::forEach(void (*visit)(const Node*, void*), void* context) const {
+    for (const Node& node : nodes_) {
+        visit(&node, context);
+    }
+}
+
+void Tree::walk(bool (*visit)(const Node& node, void* user_data), void* user_data) const {
+    for (const Node& node : nodes_) {
+        if (!visit(node, user_data)) {
+            return;
+        }
+    }
+}
+
+void Tree::visitDepths(bool (*visit)(void* context, int32_t depth), void* context) const {
+    for (const Node& node : nodes_) {
+        if (!visit(context, node.depth())) {
+            return;
+        }
+    }
+}
+
+void Tree::forEachShape(void (*visit)(const Shape*, void*), void* context) const {
+    for (const Shape* shape : shapes_) {
+        visit(shape, context);
+    }
+}
+
+void for_each_shallow(const Tree& tree, int32_t max_depth, void (*visit)(const Node*, void*), void* ctx) {
+    struct Filter {
+        int32_t max_depth;
+        void (*visit)(const Node*, void*);
+        void* ctx;
+    } filter{max_depth, visit, ctx};
+    tree.forEach([](const Node* node, void* data) {
+        auto* f = static_cast<Filter*>(data);
+        if (node->depth() <= f->max_depth) {
+            f->visit(node, f->ctx);
+        }
+    }, &filter);
+}
+
+void count_to(int32_t n, void (*visit)(int32_t, void*), void* ctx) {
+    for (int32_t i = 1; i <= n; ++i) {
+        calls++;
+        visit(i, ctx);
+    }
+}
+
+int32_t counted() { return calls; }
+
+int32_t transfer(int32_t n, Action (*decide)(int32_t chunk, void* ctx), void* ctx) {
+    int32_t delivered = 0;
+    for (int32_t chunk = 1; chunk <= n; ++chunk) {
+        Action action = decide(chunk, ctx);
+        if (action == Action::Abort) {
+            break;
+        }
+        if (action == Action::Deliver) {
+            delivered++;
+        }
+    }
+    return delivered;
+}
+
+int32_t read_chunks(int32_t n, bool (*on_chunk)(int32_t chunk, void* ctx), void* ctx) {
+    chunks_read = 0;
+    for (int32_t chunk = 1; chunk <= n && on_chunk(chunk, ctx); ++chunk) {
+        chunks_read++;
+    }
+    return chunks_read;
+}
+
+int32_t last_read() { return chunks_read; }
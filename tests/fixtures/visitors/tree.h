@@ -0,0 +1,66 @@
+#pragma once
+#include <cstdint>
+#include <vector>
+
+class Node {
+public:
+    Node(int32_t id, int32_t depth);
+    int32_t id() const;
+    int32_t depth() const;
+
+private:
+    int32_t id_;
+    int32_t depth_;
+};
+
+/// A shape of a Tree, which only clone() can copy.
+// @clone
+class Shape {
+public:
+    virtual ~Shape();
+    virtual Shape* clone() const = 0;
+    virtual int32_t area() const = 0;
+};
+
+/// Nodes in insertion order, each with a square of side id.
+class Tree {
+public:
+    Tree();
+    ~Tree();
+    void add(int32_t id, int32_t depth);
+    int32_t size() const;
+    /// Calls visit with each node.
+    void forEach(void (*visit)(const Node*, void*), void* context) const;
+    /// Calls visit with each node until it returns false.
+    void walk(bool (*visit)(const Node& node, void* user_data), void* user_data) const;
+    /// Calls visit with the depth of each node until it returns false.
+    // @collect depths
+    void visitDepths(bool (*visit)(void* context, int32_t depth), void* context) const;
+    /// Calls visit with the square of each node.
+    void forEachShape(void (*visit)(const Shape*, void*), void* context) const;
+
+private:
+    std::vector<Node> nodes_;
+    std::vector<Shape*> shapes_;
+};
+
+/// Calls visit with each node of tree no deeper than max_depth.
+// @collect shallow_nodes
+void for_each_shallow(const Tree& tree, int32_t max_depth, void (*visit)(const Node*, void*), void* ctx);
+
+/// Calls visit with 1, 2, ..., n, counting the calls made.
+void count_to(int32_t n, void (*visit)(int32_t, void*), void* ctx);
+int32_t counted();
+
+/// What transfer does with a chunk.
+enum class Action : int32_t { Deliver, Skip, Abort };
+
+/// Offers chunks 1, 2, ..., n to decide until it returns Abort; returns how many were delivered.
+// @panic_result Abort
+int32_t transfer(int32_t n, Action (*decide)(int32_t chunk, void* ctx), void* ctx);
+
+/// Reads chunks 1, 2, ..., n while on_chunk returns true; returns how many were read.
+// @panic_result true
+int32_t read_chunks(int32_t n, bool (*on_chunk)(int32_t chunk, void* ctx), void* ctx);
+/// What the last read_chunks returned.
+int32_t last_read();
@@ -0,0 +1,198 @@
+package tree
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newTree() *Tree {
+	tr := NewTree()
+	for i, depth := range []int32{0, 1, 2, 1} {
+		tr.Add(int32(i+1), depth)
+	}
+	return tr
+}
+
+func ids(nodes []*Node) []int32 {
+	var result []int32
+	for _, n := range nodes {
+		result = append(result, n.Id())
+	}
+	return result
+}
+
+func TestForEachVisitsBorrowedNodes(t *testing.T) {
+	tr := newTree()
+	defer tr.Delete()
+
+	var got []int32
+	tr.ForEach(func(n *Node) bool {
+		got = append(got, n.Id())
+		return true
+	})
+	if want := []int32{1, 2, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ForEach visited %v, want %v", got, want)
+	}
+}
+
+func TestFalseStopsTheVisit(t *testing.T) {
+	tr := newTree()
+	defer tr.Delete()
+
+	// walk supports early exit, so C++ stops too
+	var walked []int32
+	tr.Walk(func(n *Node) bool {
+		walked = append(walked, n.Id())
+		return len(walked) < 2
+	})
+	if want := []int32{1, 2}; !reflect.DeepEqual(walked, want) {
+		t.Fatalf("Walk visited %v, want %v", walked, want)
+	}
+
+	// count_to does not, so C++ goes on but the func is not called again
+	before := Counted()
+	var seen []int32
+	CountTo(5, func(i int32) bool {
+		seen = append(seen, i)
+		return i < 3
+	})
+	if want := []int32{1, 2, 3}; !reflect.DeepEqual(seen, want) {
+		t.Fatalf("CountTo called the func with %v, want %v", seen, want)
+	}
+	if got := Counted() - before; got != 5 {
+		t.Fatalf("count_to made %d calls, want 5", got)
+	}
+}
+
+func TestCollectorsCopyElements(t *testing.T) {
+	tr := newTree()
+	nodes := tr.Nodes()
+	shallow := tr.ShallowNodes(1)
+	shapes := tr.Shapes()
+	tr.Delete()
+
+	// The copies outlive the tree
+	if got, want := ids(nodes), []int32{1, 2, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Nodes() = %v, want %v", got, want)
+	}
+	if got, want := ids(shallow), []int32{1, 2, 4}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ShallowNodes(1) = %v, want %v", got, want)
+	}
+	var areas []int32
+	for _, s := range shapes {
+		areas = append(areas, s.Area())
+	}
+	if want := []int32{1, 4, 9, 16}; !reflect.DeepEqual(areas, want) {
+		t.Fatalf("Shapes() areas = %v, want %v", areas, want)
+	}
+	for _, n := range append(nodes, shallow...) {
+		n.Delete()
+	}
+	for _, s := range shapes {
+		s.Delete()
+	}
+}
+
+func TestCollectScalars(t *testing.T) {
+	tr := newTree()
+	defer tr.Delete()
+
+	if got, want := tr.Depths(), []int32{0, 1, 2, 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Depths() = %v, want %v", got, want)
+	}
+	if got, want := Values(3), []int32{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Values(3) = %v, want %v", got, want)
+	}
+}
+
+func TestReentrantVisit(t *testing.T) {
+	// Thread-safe bindings unlock the tree while the func runs
+	tr := newTree()
+	defer tr.Delete()
+
+	pairs := 0
+	tr.ForEach(func(outer *Node) bool {
+		tr.Walk(func(inner *Node) bool {
+			pairs++
+			return true
+		})
+		return tr.Size() > 0
+	})
+	if pairs != 16 {
+		t.Fatalf("nested visits saw %d pairs, want 16", pairs)
+	}
+}
+
+func TestPanicInVisitor(t *testing.T) {
+	tr := newTree()
+	defer tr.Delete()
+
+	defer func() {
+		if r := recover(); r != "stop" {
+			t.Fatalf("ForEach panicked with %v, want stop", r)
+		}
+	}()
+	tr.ForEach(func(n *Node) bool {
+		panic("stop")
+	})
+}
+
+func TestAbortStopsTheTransfer(t *testing.T) {
+	var offered []int32
+	delivered := Transfer(10, func(chunk int32) Action {
+		offered = append(offered, chunk)
+		switch {
+		case chunk == 4:
+			return ActionAbort
+		case chunk%2 == 0:
+			return ActionSkip
+		}
+		return ActionDeliver
+	})
+	if !reflect.DeepEqual(offered, []int32{1, 2, 3, 4}) || delivered != 2 {
+		t.Fatalf("offered %v and delivered %d, want [1 2 3 4] and 2", offered, delivered)
+	}
+}
+
+func TestFalseStopsReading(t *testing.T) {
+	calls := 0
+	read := ReadChunks(10, func(chunk int32) bool {
+		calls++
+		return chunk < 3
+	})
+	if read != 2 || calls != 3 {
+		t.Fatalf("read %d chunks in %d calls, want 2 in 3", read, calls)
+	}
+}
+
+func TestInvalidActionAbortsAndPanics(t *testing.T) {
+	calls := 0
+	defer func() {
+		if r := recover(); r != "transfer: invalid Action 7" || calls != 1 {
+			t.Fatalf("Transfer panicked with %v after %d calls, want an invalid Action after 1", r, calls)
+		}
+	}()
+	Transfer(10, func(chunk int32) Action {
+		calls++
+		return Action(7)
+	})
+}
+
+func TestPanicResultKeepsReading(t *testing.T) {
+	calls := 0
+	func() {
+		defer func() {
+			if r := recover(); r != "bad chunk" {
+				t.Fatalf("ReadChunks panicked with %v, want bad chunk", r)
+			}
+		}()
+		ReadChunks(5, func(chunk int32) bool {
+			calls++
+			panic("bad chunk")
+		})
+	}()
+	// C++ got true for every chunk, though the func was called only once
+	if read := LastRead(); read != 5 || calls != 1 {
+		t.Fatalf("read %d chunks in %d calls, want 5 in 1", read, calls)
+	}
+}
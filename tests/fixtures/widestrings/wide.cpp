@@ -0,0 +1,55 @@
+#include "wide.h"
+
+namespace {
+
+bool isLowSurrogate(wchar_t unit) {
+    return sizeof(wchar_t) == 2 && unit >= 0xDC00 && unit <= 0xDFFF;
+}
+
+} // namespace
+
+int32_t characters(const std::wstring& text) {
+    int32_t count = 0;
+    for (wchar_t unit : text) {
+        count += isLowSurrogate(unit) ? 0 : 1;
+    }
+    return count;
+}
+
+int32_t codePointAt(const std::wstring& text, int32_t index) {
+    for (size_t i = 0; i < text.size(); ++i) {
+        bool pair = i + 1 < text.size() && isLowSurrogate(text[i + 1]);
+        if (index-- == 0) {
+            if (pair) {
+                return 0x10000 + ((static_cast<int32_t>(text[i]) - 0xD800) << 10) +
+                       (static_cast<int32_t>(text[i + 1]) - 0xDC00);
+            }
+            return static_cast<int32_t>(text[i]);
+        }
+        i += pair ? 1 : 0;
+    }
+    return -1;
+}
+
+void echo(const std::wstring& text, std::wstring& out) {
+    out = text;
+}
+
+void appendClef(std::wstring& text) {
+    if (sizeof(wchar_t) == 2) {
+        text += static_cast<wchar_t>(0xD834);
+        text += static_cast<wchar_t>(0xDD1E);
+    } else {
+        text += static_cast<wchar_t>(0x1D11E);
+    }
+}
+
+Title::Title(std::wstring text) : text_(std::move(text)) {}
+
+void Title::text(std::wstring& out) const {
+    out = text_;
+}
+
+void Title::setText(const std::wstring& text) {
+    text_ = text;
+}
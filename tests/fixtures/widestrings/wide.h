@@ -0,0 +1,27 @@
+#pragma once
+#include <cstdint>
+#include <string>
+
+/// Characters of text, a surrogate pair counting once.
+int32_t characters(const std::wstring& text);
+
+/// Code point of the character at index, or -1 past the end.
+int32_t codePointAt(const std::wstring& text, int32_t index);
+
+/// Copies text to out unchanged.
+void echo(const std::wstring& text, std::wstring& out);
+
+/// Appends a musical G clef, U+1D11E, to text.
+void appendClef(std::wstring& text);
+
+/// A window title kept as a std::wstring, as Windows APIs do.
+class Title {
+public:
+    explicit Title(std::wstring text);
+
+    void text(std::wstring& out) const;
+    void setText(const std::wstring& text);
+
+private:
+    std::wstring text_;
+};
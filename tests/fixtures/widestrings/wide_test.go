@@ -0,0 +1,46 @@
+package wide
+
+import "testing"
+
+func TestNonBMPCharactersArriveWhole(t *testing.T) {
+	text := "a😀é"
+	if n := Characters(text); n != 3 {
+		t.Fatalf("Characters(%q) = %d, want 3", text, n)
+	}
+	for i, want := range []rune(text) {
+		if got := CodePointAt(text, int32(i)); got != int32(want) {
+			t.Fatalf("CodePointAt(%q, %d) = %U, want %U", text, i, got, want)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, text := range []string{"", "plain", "Grüße, 世界", "𝄞 and 😀", "\U0010FFFF"} {
+		if got := Echo(text); got != text {
+			t.Fatalf("Echo(%q) = %q", text, got)
+		}
+	}
+	text := "G "
+	AppendClef(&text)
+	if text != "G 𝄞" {
+		t.Fatalf("AppendClef left %q", text)
+	}
+}
+
+func TestInvalidUTF8BecomesReplacement(t *testing.T) {
+	if got := Echo("a\xffb"); got != "a�b" {
+		t.Fatalf("Echo(%q) = %q, want %q", "a\xffb", got, "a�b")
+	}
+}
+
+func TestWideStringMembers(t *testing.T) {
+	title := NewTitle("Fenêtre 🪟")
+	defer title.Delete()
+	if got := title.Text(); got != "Fenêtre 🪟" {
+		t.Fatalf("Text() = %q", got)
+	}
+	title.SetText("窓")
+	if got := title.Text(); got != "窓" {
+		t.Fatalf("Text() = %q after SetText", got)
+	}
+}
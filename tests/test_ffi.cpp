@@ -0,0 +1,3723 @@
+#include "ffi.h"
+#include <algorithm>
+#include <cassert>
+#include <cstdlib>
+#include <filesystem>
+#include <fstream>
+#include <iostream>
+#include <stdexcept>
+
+namespace hybrid_transpiler {
+namespace ffi {
+namespace test {
+
+namespace {
+
+FFIParameter makeParam(const std::string& name, const std::string& c_type) {
+    FFIParameter param;
+    param.name = name;
+    param.cpp_type = c_type;
+    param.c_type = c_type;
+    return param;
+}
+
+FFIClass makeCalculator() {
+    FFIClass cls;
+    cls.name = "Calculator";
+
+    FFIFunction ctor;
+    ctor.name = "Calculator";
+    ctor.is_constructor = true;
+    ctor.parameters.push_back(makeParam("initial_value", "int32_t"));
+    cls.methods.push_back(ctor);
+
+    FFIFunction get_value;
+    get_value.name = "getValue";
+    get_value.is_const = true;
+    get_value.c_return_type = "int32_t";
+    cls.methods.push_back(get_value);
+
+    FFIFunction add;
+    add.name = "add";
+    add.parameters.push_back(makeParam("value", "int32_t"));
+    cls.methods.push_back(add);
+
+    return cls;
+}
+
+FFIClass makeStruct(const std::string& name, const std::vector<FFIParameter>& fields,
+                    const std::vector<std::string>& bases = {}) {
+    FFIClass cls;
+    cls.name = name;
+    cls.fields = fields;
+    cls.base_classes = bases;
+    cls.is_mirrored = true;
+    return cls;
+}
+
+// Structs whose layout differs between the Itanium and MSVC ABIs
+std::vector<FFIClass> makeLayoutProbes() {
+    FFIClass base = makeStruct("Base", {makeParam("i", "int32_t"), makeParam("c", "int8_t")});
+    FFIFunction base_ctor;
+    base_ctor.name = "Base";
+    base_ctor.is_constructor = true;
+    base.methods.push_back(base_ctor);   // not POD: Itanium reuses its tail padding
+
+    return {
+        makeStruct("Flags", {makeParam("a", "bool"), makeParam("b", "int32_t"), makeParam("c", "bool")}),
+        base,
+        makeStruct("Derived", {makeParam("d", "int8_t")}, {"Base"}),
+        makeStruct("Empty1", {}),
+        makeStruct("Empty2", {}),
+        makeStruct("Multi", {makeParam("x", "int32_t")}, {"Empty1", "Empty2"}),
+        makeStruct("Counter", {makeParam("count", "long")}),
+    };
+}
+
+// Annotated library used by the C header tests
+const char* const kWidgetSource = R"(#include <string>
+
+/// Color of a widget.
+enum class Color { Red, Green = 4, Blue };
+
+/**
+ * A resizable widget.
+ *
+ * Widgets start out red.
+ */
+class Widget {
+public:
+    /// Create a widget of the given width.
+    Widget(int width);
+    Widget();
+    /// Current width in pixels.
+    int width() const;
+    void setColor(Color c);
+    Color color() const;
+private:
+    int width_;
+    Color color_;
+};
+
+// @mirror
+struct Point {
+    int x;
+    int y;
+};
+
+/// Add two numbers.
+int add(int a, int b);
+)";
+
+const char* const kWidgetImplementation = R"(#include "widget.h"
+
+Widget::Widget(int width) : width_(width), color_(Color::Red) {}
+Widget::Widget() : Widget(1) {}
+int Widget::width() const { return width_; }
+void Widget::setColor(Color c) { color_ = c; }
+Color Widget::color() const { return color_; }
+int add(int a, int b) { return a + b; }
+)";
+
+void writeFile(const std::filesystem::path& path, const std::string& content) {
+    std::ofstream out(path);
+    out << content;
+}
+
+/**
+ * Host toolchain for tests that compile generated code; honors CC and CXX
+ */
+struct Toolchain {
+    std::string cc;
+    std::string cxx;
+    bool available = false;
+};
+
+Toolchain findToolchain() {
+    const char* cc = std::getenv("CC");
+    const char* cxx = std::getenv("CXX");
+    Toolchain toolchain;
+    toolchain.cc = cc ? cc : "cc";
+    toolchain.cxx = cxx ? cxx : "c++";
+    toolchain.available = std::system((toolchain.cc + " --version > /dev/null 2>&1").c_str()) == 0 &&
+                          std::system((toolchain.cxx + " --version > /dev/null 2>&1").c_str()) == 0;
+    return toolchain;
+}
+
+// Run a shell command with its output captured in dir/build.log
+bool runIn(const std::filesystem::path& dir, const std::string& command) {
+    std::string quoted = "cd \"" + dir.string() + "\" && ";
+    return std::system((quoted + command + " > build.log 2>&1").c_str()) == 0;
+}
+
+} // namespace
+
+void testGoPackageGeneration() {
+    GoFFIGenerator generator;
+    std::string code = generator.generatePackage({}, {makeCalculator()}, "calc");
+
+    assert(code.find("package calc") != std::string::npos);
+    assert(code.find("import \"C\"") != std::string::npos);
+    assert(code.find("#cgo LDFLAGS: -L${SRCDIR}/../lib -lcalc -lstdc++") != std::string::npos);
+    assert(code.find("void* Calculator_new(int32_t initial_value);") != std::string::npos);
+    assert(code.find("int32_t Calculator_getValue(const void* self);") != std::string::npos);
+    assert(code.find("func NewCalculator(initialValue int32) *Calculator {") != std::string::npos);
+    assert(code.find("func (c *Calculator) Add(value int32) {") != std::string::npos);
+    assert(code.find("C.Calculator_delete(c.ptr)") != std::string::npos);
+    std::cout << "  ✓ Go package generation test passed\n";
+}
+
+void testCWrapperGeneration() {
+    CWrapperGenerator generator;
+    std::string shim = generator.generateImplementation({}, {makeCalculator()}, "calc");
+
+    assert(shim.find("return new Calculator(initial_value);") != std::string::npos);
+    assert(shim.find("return static_cast<const Calculator*>(self)->getValue();") != std::string::npos);
+    assert(shim.find("delete static_cast<Calculator*>(self);") != std::string::npos);
+    std::cout << "  ✓ C wrapper generation test passed\n";
+}
+
+void testWindowsDllImportPreamble() {
+    FFIOptions options;
+    options.windows_dll_import = true;
+
+    FFIFunction add;
+    add.name = "add";
+    add.c_return_type = "int32_t";
+    add.parameters.push_back(makeParam("a", "int32_t"));
+    add.parameters.push_back(makeParam("b", "int32_t"));
+
+    GoFFIGenerator generator(options);
+    std::string preamble = generator.generatePreamble({add}, {makeCalculator()}, "mylib");
+
+    assert(preamble.find("#cgo windows LDFLAGS: -L${SRCDIR}/../lib -lmylib\n") != std::string::npos);
+    assert(preamble.find("#cgo !windows LDFLAGS: -L${SRCDIR}/../lib -lmylib -lstdc++") != std::string::npos);
+    assert(preamble.find("#  define MYLIB_API __declspec(dllimport)") != std::string::npos);
+    assert(preamble.find("#  define MYLIB_CALL __cdecl") != std::string::npos);
+    assert(preamble.find("dllexport") == std::string::npos);
+    assert(preamble.find("MYLIB_API int32_t MYLIB_CALL add(int32_t a, int32_t b);") != std::string::npos);
+    assert(preamble.find("MYLIB_API void MYLIB_CALL Calculator_delete(void* self);") != std::string::npos);
+
+    // The shim is built into the DLL and must export the same symbols
+    CWrapperGenerator c_generator(options);
+    std::string header = c_generator.generateHeader({add}, {makeCalculator()}, "mylib");
+    std::string shim = c_generator.generateImplementation({add}, {makeCalculator()}, "mylib");
+    assert(header.find("#    define MYLIB_API __declspec(dllexport)") != std::string::npos);
+    assert(shim.find("#define MYLIB_BUILD_DLL") != std::string::npos);
+    assert(shim.find("int32_t MYLIB_CALL Calculator_getValue(const void* self) {") != std::string::npos);
+    std::cout << "  ✓ Windows dll-import preamble test passed\n";
+}
+
+void testInjectedPrologueEpilogue() {
+    FFIOptions options;
+    options.cgo_prologue = "#define _GNU_SOURCE";
+    options.cgo_epilogue = "static inline int twice(int x) { return 2 * x; }\n";
+    options.shim_prologue = "#include <cstdio>\n";
+
+    GoFFIGenerator generator(options);
+    std::string preamble = generator.generatePreamble({}, {makeCalculator()}, "calc");
+
+    size_t prologue = preamble.find("#define _GNU_SOURCE\n");
+    size_t includes = preamble.find("#include <stdlib.h>");
+    size_t decls = preamble.find("Calculator_delete(void* self);");
+    size_t epilogue = preamble.find("static inline int twice(int x)");
+    assert(prologue != std::string::npos && prologue < includes);
+    assert(epilogue != std::string::npos && decls < epilogue);
+
+    CWrapperGenerator c_generator(options);
+    std::string shim = c_generator.generateImplementation({}, {makeCalculator()}, "calc");
+    assert(shim.find("#include <cstdio>") < shim.find("#include \"calc_wrapper.h\""));
+
+    // A comment terminator would cut the cgo preamble short
+    options.cgo_epilogue = "/* helper */";
+    GoFFIGenerator bad_generator(options);
+    bool rejected = false;
+    try {
+        bad_generator.generatePreamble({}, {}, "calc");
+    } catch (const std::invalid_argument&) {
+        rejected = true;
+    }
+    assert(rejected);
+    std::cout << "  ✓ Injected prologue/epilogue test passed\n";
+}
+
+void testErrorCodeOutParameter() {
+    FFIClass session;
+    session.name = "Session";
+
+    FFIFunction throwing;
+    throwing.name = "connect";
+    throwing.c_return_type = "int32_t";
+    throwing.parameters.push_back(makeParam("host", "const char*"));
+    session.methods.push_back(throwing);
+
+    FFIFunction with_ec = throwing;
+    FFIParameter ec = makeParam("ec", "void*");
+    ec.cpp_type = "std::error_code&";
+    ec.is_reference = true;
+    with_ec.parameters.push_back(ec);
+    session.methods.push_back(with_ec);
+
+    GoFFIGenerator generator;
+    std::string code = generator.generatePackage({}, {session}, "net");
+
+    // Only the error_code overload is bound, without the ec parameter
+    assert(code.find("func (s *Session) Connect(host string) (int32, error) {") != std::string::npos);
+    size_t first = code.find("func (s *Session) Connect(");
+    assert(code.find("func (s *Session) Connect(", first + 1) == std::string::npos);
+    assert(code.find("return result, errorCodeResult(ecValue, ecCategory, ecMessage)") != std::string::npos);
+    assert(code.find("ErrSystemCategory       = errors.New(\"system\")") != std::string::npos);
+    assert(code.find("func (e *ErrorCode) Is(target error) bool {") != std::string::npos);
+
+    CWrapperGenerator c_generator;
+    std::string shim = c_generator.generateImplementation({}, {session}, "net");
+    assert(shim.find("int32_t Session_connect(void* self, const char* host, int* ec_value, "
+                     "const char** ec_category, char** ec_message) {") != std::string::npos);
+    assert(shim.find("auto result = static_cast<Session*>(self)->connect(host, ec);") != std::string::npos);
+    assert(shim.find("*ec_category = ec.category().name();") != std::string::npos);
+    assert(shim.find("#include <system_error>") != std::string::npos);
+    std::cout << "  ✓ error_code out-parameter test passed\n";
+}
+
+void testFixedArrayReferenceReturn() {
+    FFIClass grid;
+    grid.name = "Grid";
+
+    FFIFunction values;
+    values.name = "values";
+    values.return_type = "int (&)[4]";
+    grid.methods.push_back(values);
+
+    GoFFIGenerator generator;
+    std::string code = generator.generatePackage({}, {grid}, "grid");
+    assert(code.find("void Grid_values(void* self, int* out_array);") != std::string::npos);
+    assert(code.find("func (g *Grid) Values() [4]int32 {") != std::string::npos);
+    assert(code.find("var out [4]C.int") != std::string::npos);
+    assert(code.find("C.Grid_values(g.ptr, &out[0])") != std::string::npos);
+    assert(code.find("result[i] = int32(v)") != std::string::npos);
+
+    CWrapperGenerator c_generator;
+    std::string shim = c_generator.generateImplementation({}, {grid}, "grid");
+    assert(shim.find("const auto& array = static_cast<Grid*>(self)->values();") != std::string::npos);
+    assert(shim.find("for (size_t i = 0; i < 4; ++i) {") != std::string::npos);
+    assert(shim.find("out_array[i] = array[i];") != std::string::npos);
+
+    // Parsed from a header: the declarator around the name and the trailing
+    // return spell the same type, for methods and free functions alike
+    std::string source = R"(
+#include <cstdint>
+class Grid {
+public:
+    Grid();
+    int32_t (&classic())[4];
+    const float (&cfloats() const)[3];
+    auto trail() -> int32_t(&)[4];
+};
+int32_t (&freeArr())[4];
+auto freeTrail() -> int32_t(&)[4];
+int32_t (*rowPtr(int32_t row))[4];
+)";
+    FFIModule module = FFIAnalyzer().analyzeSource(source, "grid");
+    const auto& methods = module.classes[0].methods;
+    assert(methods.size() == 4);
+    for (size_t i = 1; i < methods.size(); ++i) {
+        assert(methods[i].can_use_ffi);
+    }
+    assert(methods[1].name == "classic" && methods[2].name == "cfloats" && methods[2].is_const);
+    assert(module.functions.size() == 3);
+    assert(module.functions[0].name == "freeArr" && module.functions[0].can_use_ffi);
+    assert(module.functions[1].name == "freeTrail" && module.functions[1].can_use_ffi);
+    // Pointers to arrays are reported rather than dropped
+    assert(module.functions[2].name == "rowPtr" && !module.functions[2].can_use_ffi);
+
+    code = generator.generatePackage(module.functions, module.classes, "grid");
+    assert(code.find("func (g *Grid) Classic() [4]int32 {") != std::string::npos);
+    assert(code.find("func (g *Grid) Cfloats() [3]float32 {") != std::string::npos);
+    assert(code.find("func (g *Grid) Trail() [4]int32 {") != std::string::npos);
+    assert(code.find("func FreeArr() [4]int32 {") != std::string::npos);
+    assert(code.find("func FreeTrail() [4]int32 {") != std::string::npos);
+    shim = c_generator.generateImplementation(module.functions, module.classes, "grid");
+    assert(shim.find("const auto& array = static_cast<const Grid*>(self)->cfloats();") != std::string::npos);
+    assert(shim.find("const auto& array = freeArr();") != std::string::npos);
+    std::string report = generator.generateReport(module.functions, module.classes, "grid");
+    assert(report.find("  rowPtr: Return type int32_t(*)[4] has no C equivalent\n") != std::string::npos);
+    std::cout << "  ✓ Fixed array reference return test passed\n";
+}
+
+void testStdExpectedReturn() {
+    FFIClass parser;
+    parser.name = "Parser";
+
+    FFIFunction parse;
+    parse.name = "parse";
+    parse.return_type = "std::expected<int, std::string>";
+    parse.c_return_type = "int";
+    parse.parameters.push_back(makeParam("text", "const char*"));
+    parser.methods.push_back(parse);
+
+    FFIFunction reset;
+    reset.name = "reset";
+    reset.return_type = "std::expected<void, ParseError>";
+    parser.methods.push_back(reset);
+
+    std::string value_type;
+    std::string error_type;
+    assert(CWrapperGenerator::expectedTypes(parse, &value_type, &error_type));
+    assert(value_type == "int" && error_type == "std::string");
+    assert(!CWrapperGenerator::expectedTypes(makeCalculator().methods[0]));
+
+    GoFFIGenerator generator;
+    std::string code = generator.generatePackage({}, {parser}, "parser");
+    assert(code.find("int Parser_parse(void* self, const char* text, bool* has_value, char** unexpected);") != std::string::npos);
+    assert(code.find("void Parser_reset(void* self, bool* has_value, int* unexpected);") != std::string::npos);
+    assert(code.find("func (p *Parser) Parse(text string) (int32, error) {") != std::string::npos);
+    assert(code.find("return result, errors.New(C.GoString(unexpected))") != std::string::npos);
+    assert(code.find("func (p *Parser) Reset() error {") != std::string::npos);
+    assert(code.find("return &UnexpectedError{Type: \"ParseError\", Value: int(unexpected)}") != std::string::npos);
+    assert(code.find("type UnexpectedError struct {") != std::string::npos);
+
+    CWrapperGenerator c_generator;
+    std::string shim = c_generator.generateImplementation({}, {parser}, "parser");
+    assert(shim.find("#include <expected>") != std::string::npos);
+    assert(shim.find("auto expected = static_cast<Parser*>(self)->parse(text);") != std::string::npos);
+    assert(shim.find("*unexpected = strdup(expected.error().c_str());") != std::string::npos);
+    assert(shim.find("*unexpected = static_cast<int>(expected.error());") != std::string::npos);
+    assert(shim.find("return *expected;") != std::string::npos);
+    std::cout << "  ✓ std::expected return test passed\n";
+}
+
+void testABIProfiles() {
+    ABIProfile linux64 = ABIProfile::fromTriple("x86_64-unknown-linux-gnu");
+    assert(linux64.kind == ABIKind::Itanium && linux64.long_size == 8);
+    assert(linux64.goBuildConstraint() == "linux && amd64");
+
+    ABIProfile msvc = ABIProfile::fromTriple("x86_64-pc-windows-msvc");
+    assert(msvc.kind == ABIKind::MSVC && msvc.long_size == 4);
+    assert(msvc.goBuildConstraint() == "windows && amd64");
+    assert(msvc.preprocessorCondition().find("defined(_MSC_VER) && defined(_WIN32)") == 0);
+
+    ABIProfile mingw = ABIProfile::fromTriple("x86_64-w64-mingw32");
+    assert(mingw.kind == ABIKind::Itanium && mingw.go_os == "windows");
+
+    ABIProfile i386 = ABIProfile::fromTriple("i686-pc-linux-gnu");
+    assert(i386.pointer_size == 4 && i386.int64_alignment == 4);
+    assert(ABIProfile::fromTriple("powerpc-apple-darwin").bool_size == 4);
+
+    bool threw = false;
+    try {
+        ABIProfile::fromTriple("x86_64");
+    } catch (const std::invalid_argument&) {
+        threw = true;
+    }
+    assert(threw);
+
+    std::vector<FFIClass> probes = makeLayoutProbes();
+    LayoutEngine engine(probes);
+    auto sizes = [&](size_t index, size_t itanium_size, size_t msvc_size) {
+        return engine.layout(probes[index], linux64).size == itanium_size &&
+               engine.layout(probes[index], msvc).size == msvc_size;
+    };
+    assert(sizes(0, 12, 12));   // Flags
+    assert(sizes(2, 8, 12));    // Derived: tail padding reuse
+    assert(sizes(5, 4, 8));     // Multi: empty bases
+    assert(sizes(6, 8, 4));     // Counter: long
+
+    StructLayout derived = engine.layout(probes[2], linux64);
+    assert(derived.fields.size() == 3 && derived.fields[2].name == "d" && derived.fields[2].offset == 5);
+    assert(engine.layout(probes[0], ABIProfile::fromTriple("powerpc-apple-darwin")).size == 12);
+    assert(engine.layout(probes[0], ABIProfile::fromTriple("powerpc-apple-darwin")).fields[2].offset == 8);
+
+    FFIClass opaque = makeStruct("Opaque", {makeParam("name", "std::string")});
+    assert(!engine.layout(opaque, linux64).valid);
+    std::cout << "  ✓ ABI profile test passed\n";
+}
+
+void testMirroredStructLayouts() {
+    std::vector<FFIClass> probes = makeLayoutProbes();
+
+    GoFFIGenerator generator;
+    std::string code = generator.generatePackage({}, probes, "probe");
+    assert(code.find("// Flags mirrors the C++ struct Flags (12 bytes on every configured target).") != std::string::npos);
+    assert(code.find("type Flags struct {\n\tA bool\n\t_ [3]byte\n\tB int32\n\tC bool\n\t_ [3]byte\n}") != std::string::npos);
+    assert(code.find("type Derived struct") == std::string::npos);
+    assert(code.find("Flags_new") == std::string::npos);
+
+    auto files = generator.generateLayoutFiles(probes, "probe");
+    assert(files.size() == 2);
+    const std::string& windows = files["probe_layout_windows_amd64.go"];
+    assert(windows.find("//go:build windows && amd64\n\npackage probe") != std::string::npos);
+    assert(windows.find("type Derived struct {\n\tI int32\n\tC int8\n\t_ [3]byte\n\tD int8\n\t_ [3]byte\n}") != std::string::npos);
+    assert(windows.find("type Counter struct {\n\tCount int32\n}") != std::string::npos);
+    const std::string& linux = files["probe_layout_linux_amd64.go"];
+    assert(linux.find("type Derived struct {\n\tI int32\n\tC int8\n\tD int8\n\t_ [2]byte\n}") != std::string::npos);
+    assert(linux.find("type Multi struct {\n\tX int32\n}") != std::string::npos);
+    assert(linux.find("type Flags") == std::string::npos);
+
+    CWrapperGenerator c_generator;
+    std::string shim = c_generator.generateImplementation({}, probes, "probe");
+    assert(shim.find("static_assert(sizeof(Flags) == 12, \"Flags does not match its Go mirror\");") != std::string::npos);
+    assert(shim.find("static_assert(offsetof(Flags, b) == 4,") != std::string::npos);
+    assert(shim.find("#if defined(_MSC_VER) && defined(_WIN32) && UINTPTR_MAX > 0xFFFFFFFFu\n"
+                     "static_assert(sizeof(Derived) == 12,") != std::string::npos);
+    assert(shim.find("static_assert(sizeof(Derived) == 8,") != std::string::npos);
+
+    // Two targets Go cannot tell apart force a demotion to accessors
+    FFIOptions options;
+    options.target_triples = {"x86_64-w64-mingw32", "x86_64-pc-windows-msvc"};
+    GoFFIGenerator windows_generator(options);
+    code = windows_generator.generatePackage({}, probes, "probe");
+    assert(code.find("func (d *Derived) D() int8 {") != std::string::npos);
+    assert(code.find("func (d *Derived) SetI(value int32) {") != std::string::npos);
+    assert(code.find("int8_t Derived_d(const void* self);") != std::string::npos);
+    assert(code.find("type Flags struct") != std::string::npos);
+
+    options.layout_mismatch = LayoutMismatchPolicy::Accessors;
+    options.target_triples = {"x86_64-unknown-linux-gnu", "x86_64-pc-windows-msvc"};
+    CWrapperGenerator accessor_generator(options);
+    shim = accessor_generator.generateImplementation({}, probes, "probe");
+    assert(shim.find("int8_t Derived_d(const void* self) {\n    return static_cast<const Derived*>(self)->d;\n}") != std::string::npos);
+    assert(shim.find("void Derived_set_i(void* self, int32_t value) {\n    static_cast<Derived*>(self)->i = value;\n}") != std::string::npos);
+    assert(GoFFIGenerator(options).generateLayoutFiles(probes, "probe").empty());
+    std::cout << "  ✓ Mirrored struct layout test passed\n";
+}
+
+void testCHeaderGeneration() {
+    FFIGenerator generator;
+    std::string header = generator.generate(kWidgetSource, "widget", "c-header");
+
+    assert(header.find("#ifndef WIDGET_C_H") != std::string::npos);
+    assert(header.find("#ifdef __cplusplus\nextern \"C\" {\n#endif") != std::string::npos);
+    assert(header.find("/** Color of a widget. */\ntypedef int widget_Color;") != std::string::npos);
+    assert(header.find("    widget_Color_Green = 4,\n") != std::string::npos);
+    assert(header.find(" * A resizable widget.\n *\n * Widgets start out red.\n */\n"
+                       "typedef struct widget_Widget widget_Widget;") != std::string::npos);
+    assert(header.find("/** Create a widget of the given width. */\nwidget_Widget* widget_Widget_new_0(int width);") != std::string::npos);
+    assert(header.find("Release it with widget_Widget_delete(). */\nwidget_Widget* widget_Widget_new_1(void);") != std::string::npos);
+    assert(header.find("/** Current width in pixels. */\nint widget_Widget_width(const widget_Widget* self);") != std::string::npos);
+    assert(header.find("void widget_Widget_setColor(widget_Widget* self, widget_Color c);") != std::string::npos);
+    assert(header.find("widget_Color widget_Widget_color(const widget_Widget* self);") != std::string::npos);
+    assert(header.find("typedef struct widget_Point {\n    int x;\n    int y;\n} widget_Point;") != std::string::npos);
+    assert(header.find("/** Add two numbers. */\nint widget_add(int a, int b);") != std::string::npos);
+    assert(header.find("Color_new") == std::string::npos);
+
+    // A class returned by reference is the object C++ holds, not a copy
+    const char* person_source = R"(
+class Person {
+public:
+    Person();
+    /// The person this one reports to.
+    Person& manager();
+};
+)";
+    std::string people = generator.generate(person_source, "people", "c-header");
+    assert(people.find("/**\n * The person this one reports to.\n"
+                       " * @note The result points to the object C++ returned by reference, which C++ keeps "
+                       "ownership of; do not release it.\n */\nvoid* people_Person_manager(people_Person* self);") !=
+           std::string::npos);
+    std::string shim = generator.generateCWrapper(person_source, "people").second;
+    assert(shim.find("void* people_Person_manager(void* self) {\n"
+                     "    return &static_cast<Person*>(self)->manager();\n}") != std::string::npos);
+
+    std::string def = generator.generateDefFile(kWidgetSource, "widget");
+    assert(def.find("LIBRARY widget\nEXPORTS\n    widget_Widget_new_0\n    widget_Widget_new_1\n") != std::string::npos);
+    assert(def.find("    widget_add\n") != std::string::npos);
+
+    bool threw = false;
+    try {
+        generator.generate(kWidgetSource, "widget", "rust");
+    } catch (const std::invalid_argument&) {
+        threw = true;
+    }
+    assert(threw);
+    std::cout << "  ✓ C header generation test passed\n";
+}
+
+void testCHeaderCompilesAgainstShim() {
+    Toolchain toolchain = findToolchain();
+    if (!toolchain.available) {
+        std::cout << "  - C header compile test skipped (no C/C++ compiler)\n";
+        return;
+    }
+
+    namespace fs = std::filesystem;
+    fs::path dir = fs::temp_directory_path() / "hybrid_c_header_test";
+    fs::create_directories(dir);
+
+    FFIGenerator generator;
+    auto wrapper = generator.generateCWrapper(kWidgetSource, "widget");
+    writeFile(dir / "widget.h", kWidgetSource);
+    writeFile(dir / "widget.cpp", kWidgetImplementation);
+    writeFile(dir / "widget_wrapper.h", wrapper.first);
+    writeFile(dir / "widget_shim.cpp", wrapper.second);
+    writeFile(dir / "widget_c.h", generator.generate(kWidgetSource, "widget", "c-header"));
+
+    // Every declaration must resolve to a shim symbol at link time
+    std::string consumer =
+        "#include \"widget_c.h\"\n"
+        "#include \"widget_c.h\"\n"
+        "int main(void) {\n"
+        "    widget_Widget* w = widget_Widget_new_0(3);\n"
+        "    widget_Widget* d = widget_Widget_new_1();\n"
+        "    widget_Point p = {1, 2};\n"
+        "    widget_Widget_setColor(w, widget_Color_Blue);\n"
+        "    int ok = widget_Widget_width(w) == 3 && widget_Widget_width(d) == 1 &&\n"
+        "             widget_Widget_color(w) == widget_Color_Blue && widget_add(p.x, p.y) == 3;\n"
+        "    widget_Widget_delete(d);\n"
+        "    widget_Widget_delete(w);\n"
+        "    return ok ? 0 : 1;\n"
+        "}\n";
+    writeFile(dir / "consumer.c", consumer);
+    writeFile(dir / "consumer.cpp", consumer);
+
+    const std::string& cc = toolchain.cc;
+    const std::string& cxx = toolchain.cxx;
+    assert(runIn(dir, cxx + " -std=c++17 -c widget_shim.cpp widget.cpp"));
+    assert(runIn(dir, cc + " -std=c99 -Wall -Werror -c consumer.c -o consumer_c.o"));
+    assert(runIn(dir, cxx + " -Wall -Werror -c consumer.cpp -o consumer_cpp.o"));
+    assert(runIn(dir, cxx + " consumer_c.o widget_shim.o widget.o -o consumer_c"));
+    assert(runIn(dir, cxx + " consumer_cpp.o widget_shim.o widget.o -o consumer_cpp"));
+    assert(runIn(dir, "./consumer_c"));
+    assert(runIn(dir, "./consumer_cpp"));
+
+    fs::remove_all(dir);
+    std::cout << "  ✓ C header compile-against-shim test passed\n";
+}
+
+void testBindingsHeader() {
+    std::string source = std::string(kWidgetSource) + "\n/// Whether a width needs scrolling.\nbool isWide(int width, bool* clipped);\n";
+    FFIGenerator generator;
+    std::string header = generator.generateBindingsHeader(source, "widget");
+
+    assert(header.find(" * @file bindings.h\n") != std::string::npos);
+    assert(header.find("#include <stddef.h>\n#include <stdint.h>\n\n#ifdef __cplusplus\n#define WIDGET_BOOL bool\n"
+                       "#else\n#define WIDGET_BOOL _Bool\n#endif") != std::string::npos);
+    assert(header.find("stdbool") == std::string::npos);
+    assert(header.find("/** Whether a width needs scrolling. */\n"
+                       "WIDGET_BOOL widget_isWide(int width, WIDGET_BOOL* clipped);") != std::string::npos);
+    assert(header.find("/** Create a widget of the given width. */\nvoid* widget_Widget_new_0(int width);") != std::string::npos);
+    assert(header.find("void widget_Widget_delete(void* self);") != std::string::npos);
+    assert(header.find("Point") == std::string::npos);
+
+    Toolchain toolchain = findToolchain();
+    if (!toolchain.available) {
+        std::cout << "  - bindings.h compile test skipped (no C/C++ compiler)\n";
+        return;
+    }
+
+    namespace fs = std::filesystem;
+    fs::path dir = fs::temp_directory_path() / "hybrid_bindings_header_test";
+    fs::create_directories(dir);
+    auto wrapper = generator.generateCWrapper(source, "widget");
+    writeFile(dir / "widget.h", source);
+    writeFile(dir / "widget_wrapper.h", wrapper.first);
+    writeFile(dir / "widget_shim.cpp", wrapper.second);
+    writeFile(dir / "bindings.h", header);
+
+    // Standalone in both languages, and identical to what the shim defines
+    assert(runIn(dir, toolchain.cc + " -std=c99 -Wall -Wextra -Werror -fsyntax-only -x c bindings.h"));
+    assert(runIn(dir, toolchain.cxx + " -std=c++17 -Wall -Wextra -Werror -fsyntax-only -x c++ bindings.h"));
+    assert(runIn(dir, toolchain.cxx + " -std=c++17 -include bindings.h -c widget_shim.cpp"));
+
+    fs::remove_all(dir);
+    std::cout << "  ✓ bindings.h test passed\n";
+}
+
+void testPoolableClass() {
+    std::string source = R"(
+// @poolable
+class Calculator {
+public:
+    Calculator();
+    void add(int value);
+    void reset();
+};
+
+// @poolable clear
+class Scratch {
+public:
+    Scratch(int capacity);
+    void clear();
+};
+)";
+    FFIModule module = FFIAnalyzer().analyzeSource(source, "calc");
+    assert(module.classes.size() == 2);
+    assert(module.classes[0].pool_reset == "reset");
+    assert(module.classes[1].pool_reset == "clear");
+
+    GoFFIGenerator generator;
+    std::string code = generator.generatePackage({}, module.classes, "calc");
+    assert(code.find("import (\n\t\"runtime\"\n\t\"sync\"\n\t\"unsafe\"\n)") != std::string::npos);
+    assert(code.find("type CalculatorPool struct {\n\tpool sync.Pool\n}") != std::string::npos);
+    assert(code.find("func NewCalculatorPool(create func() *Calculator) *CalculatorPool {") != std::string::npos);
+    assert(code.find("runtime.SetFinalizer(obj, (*Calculator).Delete)") != std::string::npos);
+    assert(code.find("func (p *CalculatorPool) Get() *Calculator {") != std::string::npos);
+    assert(code.find("\tobj.Reset()\n\tp.pool.Put(obj)\n") != std::string::npos);
+    assert(code.find("\tobj.Clear()\n\tp.pool.Put(obj)\n") != std::string::npos);
+
+    // Only classes with a default constructor can be benchmarked
+    std::string bench = generator.generatePoolBenchmarks(module.classes, "calc");
+    assert(bench.find("func BenchmarkCalculatorNewDelete(b *testing.B) {") != std::string::npos);
+    assert(bench.find("pool := NewCalculatorPool(NewCalculator)") != std::string::npos);
+    assert(bench.find("Scratch") == std::string::npos);
+    assert(generator.generatePoolBenchmarks({makeCalculator()}, "calc").empty());
+
+    // The package only gets its benchmarks on request
+    assert(FFIGenerator().generateGoFiles(source, "calc").count("calc_pool_test.go") == 0);
+    FFIOptions options;
+    options.benchmarks = true;
+    assert(FFIGenerator(options).generateGoFiles(source, "calc").at("calc_pool_test.go") == bench);
+
+    FFIClass broken = makeCalculator();
+    broken.pool_reset = "add";   // takes an argument
+    bool threw = false;
+    try {
+        generator.generatePackage({}, {broken}, "calc");
+    } catch (const std::invalid_argument&) {
+        threw = true;
+    }
+    assert(threw);
+    std::cout << "  ✓ Poolable class test passed\n";
+}
+
+void testBitsetMapping() {
+    const char* source = R"(#include <bitset>
+class Flags {
+public:
+    Flags();
+    std::bitset<16> echo(std::bitset<16> bits) const;
+    std::bitset<128> invert(const std::bitset<128>& bits) const;
+};
+std::bitset<16> bits(int32_t mask);
+std::bitset<8> set();
+)";
+    assert(CWrapperGenerator::bitsetWidth("const std::bitset<16>&") == 16);
+    assert(CWrapperGenerator::bitsetWidth("std::bitset<16>&") == 0);
+
+    FFIModule module = FFIAnalyzer().analyzeSource(source, "flags");
+    const FFIFunction& echo = module.classes[0].methods[1];
+    assert(echo.parameters[0].c_type == "uint64_t" && echo.c_return_type == "uint64_t");
+    assert(module.classes[0].methods[2].parameters[0].c_type == "const uint64_t*");
+    // Names that also occur in their return type keep all of it
+    assert(module.functions.size() == 2);
+    assert(module.functions[0].name == "bits" && module.functions[0].return_type == "std::bitset<16>");
+    assert(module.functions[0].can_use_ffi && module.functions[0].c_return_type == "uint64_t");
+    assert(module.functions[1].name == "set" && module.functions[1].return_type == "std::bitset<8>");
+    assert(module.functions[1].can_use_ffi);
+
+    CWrapperGenerator c_generator;
+    std::string shim = c_generator.generateImplementation({}, module.classes, "flags");
+    assert(shim.find("#include <bitset>") != std::string::npos);
+    assert(shim.find("uint64_t flags_Flags_echo(const void* self, uint64_t bits) {\n"
+                     "    return static_cast<const Flags*>(self)->echo(std::bitset<16>(bits)).to_ullong();") != std::string::npos);
+    assert(shim.find("void flags_Flags_invert(const void* self, const uint64_t* bits, uint64_t* out_bits) {\n"
+                     "    bitsetToWords(static_cast<const Flags*>(self)->invert(bitsetFromWords<128>(bits)), out_bits);") != std::string::npos);
+
+    GoFFIGenerator generator;
+    std::string code = generator.generatePackage({}, module.classes, "flags");
+    assert(code.find("func (f *Flags) Echo(bits Bitset16) Bitset16 {\n"
+                     "\treturn Bitset16(C.flags_Flags_echo(f.ptr, C.uint64_t(bits)))") != std::string::npos);
+    assert(code.find("func (f *Flags) Invert(bits Bitset128) Bitset128 {\n\tvar result Bitset128\n") != std::string::npos);
+    assert(code.find("type Bitset16 uint64") != std::string::npos);
+    assert(code.find("type Bitset128 [2]uint64") != std::string::npos);
+    assert(code.find("func (b Bitset16) Test(i int) bool {") != std::string::npos);
+    assert(code.find("func (b *Bitset16) Set(i int, value bool) {") != std::string::npos);
+    assert(code.find("\treturn bits.OnesCount64(uint64(b))") != std::string::npos);
+    assert(code.find("\t\"math/bits\"\n") != std::string::npos);
+
+    // Round-trip through the compiled shim, checking individual bits
+    Toolchain toolchain = findToolchain();
+    if (!toolchain.available) {
+        std::cout << "  ✓ Bitset mapping test passed (round trip skipped, no compiler)\n";
+        return;
+    }
+    namespace fs = std::filesystem;
+    fs::path dir = fs::temp_directory_path() / "hybrid_bitset_test";
+    fs::create_directories(dir);
+    writeFile(dir / "flags.h", source);
+    writeFile(dir / "flags.cpp",
+              "#include \"flags.h\"\n"
+              "Flags::Flags() {}\n"
+              "std::bitset<16> Flags::echo(std::bitset<16> bits) const { return bits; }\n"
+              "std::bitset<128> Flags::invert(const std::bitset<128>& bits) const { return ~bits; }\n");
+    writeFile(dir / "flags_wrapper.h", c_generator.generateHeader({}, module.classes, "flags"));
+    writeFile(dir / "flags_shim.cpp", shim);
+    writeFile(dir / "main.c",
+              "#include \"flags_wrapper.h\"\n"
+              "int main(void) {\n"
+              "    void* flags = flags_Flags_new();\n"
+              "    uint64_t bits = flags_Flags_echo(flags, (1u << 0) | (1u << 9) | (1u << 15));\n"
+              "    uint64_t wide[2] = {0, 1ull << 63};\n"
+              "    uint64_t inverted[2];\n"
+              "    flags_Flags_invert(flags, wide, inverted);\n"
+              "    flags_Flags_delete(flags);\n"
+              "    return (bits & 1) && (bits >> 9 & 1) && (bits >> 15 & 1) && !(bits >> 1 & 1) &&\n"
+              "           bits == 0x8201 && inverted[0] == ~0ull && inverted[1] == ~(1ull << 63) ? 0 : 1;\n"
+              "}\n");
+    assert(runIn(dir, toolchain.cxx + " -std=c++17 -c flags_shim.cpp flags.cpp"));
+    assert(runIn(dir, toolchain.cc + " -std=c99 -c main.c"));
+    assert(runIn(dir, toolchain.cxx + " main.o flags_shim.o flags.o -o round_trip && ./round_trip"));
+    fs::remove_all(dir);
+    std::cout << "  ✓ Bitset mapping test passed\n";
+}
+
+void testThreadAndSignalGating() {
+    FFIModule module = FFIAnalyzer().analyzeSource(R"(
+class Window {
+public:
+    Window();
+    // @main_thread_only
+    int show(int mode);
+    // @signal_unsafe
+    void installCrashHandler();
+};
+
+// @main_thread_only
+void pumpEvents();
+
+// @signal_unsafe
+int blockSignals(int mask);
+
+int version();
+)", "gui");
+    const FFIClass& window = module.classes[0];
+    assert(window.methods[1].main_thread_only && !window.methods[1].signal_unsafe);
+    assert(window.methods[2].signal_unsafe);
+    assert(module.functions[0].main_thread_only && module.functions[1].signal_unsafe);
+    assert(!module.functions[2].main_thread_only && !module.functions[2].signal_unsafe);
+
+    GoFFIGenerator generator;
+    std::string code = generator.generatePackage(module.functions, module.classes, "gui");
+    assert(code.find("func (w *Window) Show(mode int32) int32 {\n"
+                     "\tvar result int32\n"
+                     "\tonMainThread(\"Window::show\", func() {\n"
+                     "\t\tresult = w.show(mode)\n"
+                     "\t})\n"
+                     "\treturn result\n") != std::string::npos);
+    assert(code.find("func (w *Window) show(mode int32) int32 {") != std::string::npos);
+    assert(code.find("onMainThread(\"pumpEvents\", func() {\n\t\tpumpEvents()\n\t})") != std::string::npos);
+    assert(code.find("var RunOnMainThread func(fn func())") != std::string::npos);
+    assert(code.find("must run on the main thread: set gui.RunOnMainThread") != std::string::npos);
+    assert(code.find("InstallCrashHandler") == std::string::npos);
+    assert(code.find("BlockSignals") == std::string::npos);
+    assert(code.find("func Version() int32 {") != std::string::npos);
+
+    std::string gated = generator.generateSignalUnsafeFile(module.functions, module.classes, "gui");
+    assert(gated.find("//go:build hybrid_signal_unsafe\n\npackage gui\n") != std::string::npos);
+    assert(gated.find("void gui_Window_installCrashHandler(void* self);") != std::string::npos);
+    assert(gated.find("int gui_blockSignals(int mask);") != std::string::npos);
+    assert(gated.find("func (w *Window) InstallCrashHandler() {") != std::string::npos);
+    assert(gated.find("func BlockSignals(mask int32) int32 {") != std::string::npos);
+    assert(gated.find("Show") == std::string::npos);
+    assert(gated.find("\"unsafe\"") == std::string::npos);
+
+    std::string report = generator.generateReport(module.functions, module.classes, "gui");
+    assert(report.find("Main thread only, dispatched through RunOnMainThread (2):\n"
+                       "  Window::show\n  pumpEvents\n") != std::string::npos);
+    assert(report.find("Signal unsafe, built only with -tags hybrid_signal_unsafe (2):\n") != std::string::npos);
+    assert(report.find("version") == report.find("version() -> gui_version\n"));
+
+    FFIOptions options;
+    options.signal_unsafe_policy = SignalUnsafePolicy::Exclude;
+    GoFFIGenerator excluding(options);
+    assert(excluding.generateSignalUnsafeFile(module.functions, module.classes, "gui").empty());
+    assert(excluding.generatePackage(module.functions, module.classes, "gui").find("BlockSignals") == std::string::npos);
+    assert(excluding.generateReport(module.functions, module.classes, "gui")
+               .find("Signal unsafe, excluded from the Go bindings (2):") != std::string::npos);
+    assert(generator.generateReport({}, {makeCalculator()}, "calc")
+               .find("Every function is bound without restrictions.") != std::string::npos);
+    std::cout << "  ✓ Thread and signal gating test passed\n";
+}
+
+void testSelfTestFixtures() {
+    namespace fs = std::filesystem;
+    fs::path dir = fs::temp_directory_path() / "hybrid_fixture_test";
+    fs::remove_all(dir);
+    fs::create_directories(dir);
+    writeFile(dir / "fixture.conf", "# comment\nlibrary = calc  # trailing\ncxxflags = -std=c++20\n"
+                                    "validate_enums = true\nrace = true\n");
+    writeFile(dir / "calc.h", "int twice(int x);\n");
+    writeFile(dir / "b.cpp", "");
+    writeFile(dir / "a.cpp", "");
+
+    SelfTestFixture fixture = SelfTestRunner::loadFixture(dir.string());
+    assert(fixture.name == "hybrid_fixture_test" && fixture.library_name == "calc");
+    assert(fixture.cxxflags == "-std=c++20" && fixture.validate_enums && fixture.race);
+    assert(fixture.sources.size() == 2 && fixture.sources[0] == "a.cpp" && fixture.sources[1] == "b.cpp");
+
+    auto rejects = [&dir](const std::string& config) {
+        writeFile(dir / "fixture.conf", config);
+        try {
+            SelfTestRunner::loadFixture(dir.string());
+        } catch (const std::runtime_error&) {
+            return true;
+        }
+        return false;
+    };
+    assert(rejects("cxxflags = -O2\n"));          // no library
+    assert(rejects("library = other\n"));         // other.h missing
+    assert(rejects("library calc\n"));
+    assert(rejects("library = calc\nlanguage = go\n"));
+    assert(rejects("library = calc\nvalidate_enums = yes\n"));
+    assert(rejects("library = calc\nrace = on\n"));
+    assert(rejects("library = calc\ncgo_prologue = missing.h\n"));
+    // Injected text comes from a file, as '#' would start a comment
+    writeFile(dir / "prologue.h", "#define CALC 1\n");
+    writeFile(dir / "fixture.conf", "library = calc\nshim_prologue = prologue.h\n");
+    assert(SelfTestRunner::loadFixture(dir.string()).shim_prologue == "#define CALC 1\n");
+    fs::remove_all(dir);
+
+    // Run the shipped fixtures end to end when a compiler and Go are present
+    const char* fixtures = std::getenv("HYBRID_FIXTURES_DIR");
+    fs::path fixtures_dir = fixtures ? fixtures : "tests/fixtures";
+    const char* go = std::getenv("GO");
+    bool has_go = std::system((std::string(go ? go : "go") + " version > /dev/null 2>&1").c_str()) == 0;
+    if (!findToolchain().available || !has_go || !fs::is_directory(fixtures_dir)) {
+        std::cout << "  ✓ Self-test fixture test passed (fixtures skipped, no toolchain)\n";
+        return;
+    }
+    std::vector<SelfTestResult> results = SelfTestRunner().runAll(fixtures_dir.string());
+    assert(results.size() >= 3);
+    for (const auto& result : results) {
+        if (!result.passed) {
+            std::cerr << result.fixture << " failed (" << result.stage << "):\n" << result.output << "\n";
+        }
+        assert(result.passed);
+    }
+    std::cout << "  ✓ Self-test fixture test passed\n";
+}
+
+void testEnumValidation() {
+    FFIModule module = FFIAnalyzer().analyzeSource(kWidgetSource, "widget");
+
+    GoFFIGenerator generator;
+    std::string code = generator.generatePackage(module.functions, module.classes, "widget", module.enums);
+    assert(code.find("// Color of a widget.\ntype Color int32\n") != std::string::npos);
+    assert(code.find("const (\n\tColorRed   Color = 0\n\tColorGreen Color = 4\n"
+                     "\tColorBlue  Color = ColorGreen + 1\n)") != std::string::npos);
+    assert(code.find("func (v Color) IsValid() bool {\n"
+                     "\tfor _, known := range [...]Color{ColorRed, ColorGreen, ColorBlue} {") != std::string::npos);
+    assert(code.find("func (w *Widget) SetColor(c Color) {\n\tC.widget_Widget_setColor(w.ptr, C.int(c))") != std::string::npos);
+    assert(code.find("func (w *Widget) Color() Color {\n\treturn Color(C.widget_Widget_color(w.ptr))") != std::string::npos);
+    assert(code.find("IsValid()") == code.find("IsValid() bool"));   // no checks unless requested
+
+    // Without the enum declarations the parameters stay plain integers
+    std::string untyped = generator.generatePackage(module.functions, module.classes, "widget");
+    assert(untyped.find("func (w *Widget) SetColor(c int32) {") != std::string::npos);
+    assert(untyped.find("type Color") == std::string::npos);
+
+    FFIOptions options;
+    options.validate_enums = true;
+    std::string checked = GoFFIGenerator(options).generatePackage(module.functions, module.classes,
+                                                                  "widget", module.enums);
+    assert(checked.find("func (w *Widget) SetColor(c Color) {\n"
+                        "\tif !c.IsValid() {\n"
+                        "\t\tpanic(\"Widget::setColor: invalid Color \" + strconv.Itoa(int(c)))\n"
+                        "\t}\n"
+                        "\tC.widget_Widget_setColor(w.ptr, C.int(c))") != std::string::npos);
+    assert(checked.find("\t\"strconv\"\n") != std::string::npos);
+
+    // Go round trip: the guard fires before an invalid value reaches C++
+    Toolchain toolchain = findToolchain();
+    if (!toolchain.available || std::system("go version > /dev/null 2>&1") != 0) {
+        std::cout << "  ✓ Enum validation test passed (Go round trip skipped, no toolchain)\n";
+        return;
+    }
+    namespace fs = std::filesystem;
+    fs::path dir = fs::temp_directory_path() / "hybrid_enum_validation";
+    fs::remove_all(dir);
+    fs::create_directories(dir);
+    writeFile(dir / "fixture.conf", "library = widget\nvalidate_enums = true\n");
+    writeFile(dir / "widget.h", kWidgetSource);
+    writeFile(dir / "widget.cpp", kWidgetImplementation);
+    writeFile(dir / "widget_test.go",
+              "package widget\n"
+              "\n"
+              "import \"testing\"\n"
+              "\n"
+              "func TestInvalidColor(t *testing.T) {\n"
+              "\tw := NewWidget(10)\n"
+              "\tdefer w.Delete()\n"
+              "\tw.SetColor(ColorBlue)\n"
+              "\tdefer func() {\n"
+              "\t\tif recover() == nil || w.Color() != ColorBlue {\n"
+              "\t\t\tt.Fatal(\"invalid Color reached C++\")\n"
+              "\t\t}\n"
+              "\t}()\n"
+              "\tw.SetColor(Color(3))\n"
+              "}\n");
+    SelfTestResult result = SelfTestRunner().run(SelfTestRunner::loadFixture(dir.string()));
+    if (!result.passed) {
+        std::cerr << result.stage << ":\n" << result.output << "\n";
+    }
+    assert(result.passed);
+    fs::remove_all(dir);
+    std::cout << "  ✓ Enum validation test passed\n";
+}
+
+void testReferenceParameters() {
+    FFIModule module = FFIAnalyzer().analyzeSource(R"(#include <system_error>
+void doubleInPlace(int& value);
+
+/// @param[out] width Width in pixels
+/// @param[in,out] out_height Height, scaled in place
+void size(int& width, int& out_height);
+
+// @inout out_total
+void accumulate(int delta, long long& out_total, int& out_count);
+
+int parse(const char* text, int& out_end, std::error_code& ec);
+
+class Probe {
+public:
+    Probe(int& out_status);
+    // @main_thread_only
+    void poll(bool& out_ready);
+};
+)", "refs");
+    const auto& functions = module.functions;
+    assert(functions[0].parameters[0].direction == ParamDirection::InOut);
+    assert(functions[0].parameters[0].c_type == "int*");
+    assert(functions[1].parameters[0].direction == ParamDirection::Out);     // documented [out]
+    assert(functions[1].parameters[1].direction == ParamDirection::InOut);   // documented [in,out]
+    assert(functions[2].parameters[1].direction == ParamDirection::InOut);   // // @inout
+    assert(functions[2].parameters[2].direction == ParamDirection::Out);     // out_ name
+    assert(functions[2].parameters[0].direction == ParamDirection::In);
+    assert(!module.classes[0].methods[0].can_use_ffi);                       // constructor output
+
+    CWrapperGenerator c_generator;
+    std::string shim = c_generator.generateImplementation(functions, module.classes, "refs");
+    assert(shim.find("void refs_doubleInPlace(int* value) {\n    doubleInPlace(*value);") != std::string::npos);
+    assert(shim.find("void refs_Probe_poll(void* self, bool* out_ready) {\n"
+                     "    static_cast<Probe*>(self)->poll(*out_ready);") != std::string::npos);
+
+    GoFFIGenerator generator;
+    std::string code = generator.generatePackage(functions, module.classes, "refs");
+    assert(code.find("func DoubleInPlace(value *int32) {\n"
+                     "\tcValue := C.int(*value)\n"
+                     "\tC.refs_doubleInPlace(&cValue)\n"
+                     "\t*value = int32(cValue)\n}") != std::string::npos);
+    assert(code.find("func Size(outHeight *int32) int32 {\n"
+                     "\tvar cWidth C.int\n"
+                     "\tcOutHeight := C.int(*outHeight)\n") != std::string::npos);
+    assert(code.find("\t*outHeight = int32(cOutHeight)\n\treturn int32(cWidth)\n") != std::string::npos);
+    assert(code.find("func Accumulate(delta int32, outTotal *int64) int32 {") != std::string::npos);
+    assert(code.find("func Parse(text string) (int32, int32, error) {") != std::string::npos);
+    assert(code.find("\treturn result, int32(cOutEnd), errorCodeResult(ecValue, ecCategory, ecMessage)\n") !=
+           std::string::npos);
+    assert(code.find("func (p *Probe) Poll() bool {\n"
+                     "\tvar outReady bool\n"
+                     "\tonMainThread(\"Probe::poll\", func() {\n"
+                     "\t\toutReady = p.poll()\n") != std::string::npos);
+    std::cout << "  ✓ Reference parameter test passed\n";
+}
+
+void testResultStructs() {
+    const char* source = R"(#include <system_error>
+/// @param[out] width Width in pixels
+/// @param[out] height Height in pixels
+/// @param[out] stride Bytes per row
+void getImageInfo(int handle, int& width, int& height, int& stride);
+
+bool decode(const char* path, int& out_width, int& out_height, std::error_code& ec);
+
+void bounds(int& out_low, int& out_high);
+)";
+    FFIModule module = FFIAnalyzer().analyzeSource(source, "img");
+
+    FFIOptions options;
+    options.result_structs = {{"getImageInfo", "ImageInfo"}, {"decode", ""}};
+    GoFFIGenerator generator(options);
+    std::string code = generator.generatePackage(module.functions, {}, "img");
+    assert(code.find("// ImageInfo holds the outputs of getImageInfo.\n"
+                     "type ImageInfo struct {\n\tWidth  int32\n\tHeight int32\n\tStride int32\n}") != std::string::npos);
+    assert(code.find("func GetImageInfo(handle int32) ImageInfo {") != std::string::npos);
+    assert(code.find("\treturn ImageInfo{Width: int32(cWidth), Height: int32(cHeight), Stride: int32(cStride)}\n") !=
+           std::string::npos);
+
+    // Composes with the error convention; out_ prefixes are dropped from field names
+    assert(code.find("type DecodeResult struct {\n\tWidth  int32\n\tHeight int32\n}") != std::string::npos);
+    assert(code.find("func Decode(path string) (bool, DecodeResult, error) {") != std::string::npos);
+    assert(code.find("\treturn result, DecodeResult{Width: int32(cOutWidth), Height: int32(cOutHeight)}, "
+                     "errorCodeResult(ecValue, ecCategory, ecMessage)\n") != std::string::npos);
+
+    // Grouping is per function
+    assert(code.find("func Bounds() (int32, int32) {") != std::string::npos);
+
+    std::string report = generator.generateReport(module.functions, {}, "img");
+    assert(report.find("Output parameters returned as result structs (2):\n"
+                       "  getImageInfo -> ImageInfo {width: Width, height: Height, stride: Stride}\n"
+                       "  decode -> DecodeResult {out_width: Width, out_height: Height}\n") != std::string::npos);
+
+    // Functions sharing a struct must agree on its fields; unknown names are rejected
+    auto rejects = [&module](const std::map<std::string, std::string>& result_structs) {
+        FFIOptions bad;
+        bad.result_structs = result_structs;
+        try {
+            GoFFIGenerator(bad).generatePackage(module.functions, {}, "img");
+        } catch (const std::invalid_argument&) {
+            return true;
+        }
+        return false;
+    };
+    assert(rejects({{"getImageInfo", "Size"}, {"bounds", "Size"}}));
+    assert(rejects({{"getImageInfoo", ""}}));
+    assert(!rejects({{"bounds", "Range"}}));
+    std::cout << "  ✓ Result struct test passed\n";
+}
+
+void testStructOutputs() {
+    const char* source = R"(#include <cstdint>
+// @mirror
+struct Config {
+    int32_t retries;
+    double timeout;
+};
+
+// @mirror
+struct Stats {
+    long total;
+};
+
+void getConfig(Config* out);
+
+// @status
+int loadConfig(const char* path, Config& out);
+
+void relax(Config* config);
+
+void apply(const Config* config);
+
+void getStats(Stats* out);
+
+// @status
+double ratio();
+)";
+    FFIModule module = FFIAnalyzer().analyzeSource(source, "cfg");
+    auto find = [&module](const std::string& name) {
+        return *std::find_if(module.functions.begin(), module.functions.end(),
+                             [&name](const FFIFunction& f) { return f.name == name; });
+    };
+    assert(find("getConfig").parameters[0].direction == ParamDirection::Out);
+    assert(find("relax").parameters[0].direction == ParamDirection::InOut);
+    assert(find("apply").parameters[0].direction == ParamDirection::In);
+    assert(find("loadConfig").returns_status);
+    assert(!find("ratio").returns_status);
+
+    // The shim fills the caller's buffer, through a temporary if Config is not trivial
+    CWrapperGenerator c_generator;
+    std::string shim = c_generator.generateImplementation(module.functions, module.classes, "cfg");
+    assert(shim.find("class OutputStruct<T, true> {") != std::string::npos);
+    assert(shim.find("void cfg_getConfig(void* out) {\n"
+                     "    OutputStruct<Config> out_slot(out);\n"
+                     "    getConfig(out_slot.get());\n}") != std::string::npos);
+    assert(shim.find("    return loadConfig(path, *out_slot.get());\n") != std::string::npos);
+    assert(shim.find("    relax(static_cast<Config*>(config));\n") != std::string::npos);
+
+    GoFFIGenerator generator;
+    std::string code = generator.generatePackage(module.functions, module.classes, "cfg");
+    assert(code.find("func GetConfig() Config {\n"
+                     "\tvar cOut Config\n"
+                     "\tC.cfg_getConfig(unsafe.Pointer(&cOut))\n"
+                     "\treturn cOut\n}") != std::string::npos);
+    assert(code.find("func LoadConfig(path string) (Config, error) {") != std::string::npos);
+    assert(code.find("\treturn cOut, statusResult(\"loadConfig\", int64(status))\n") != std::string::npos);
+    assert(code.find("type StatusError struct {") != std::string::npos);
+    assert(code.find("func Relax(config *Config) {\n"
+                     "\tcConfig := *config\n"
+                     "\tC.cfg_relax(unsafe.Pointer(&cConfig))\n"
+                     "\t*config = cConfig\n}") != std::string::npos);
+    assert(code.find("func Apply(config unsafe.Pointer) {") != std::string::npos);
+    assert(code.find("func Ratio() float64 {") != std::string::npos);
+
+    // A struct demoted to accessors is a handle, so its pointer is passed as is
+    FFIOptions options;
+    options.layout_mismatch = LayoutMismatchPolicy::Accessors;
+    options.target_triples = {"x86_64-unknown-linux-gnu", "x86_64-pc-windows-msvc"};
+    code = GoFFIGenerator(options).generatePackage(module.functions, module.classes, "cfg");
+    assert(code.find("func GetStats(out unsafe.Pointer) {") != std::string::npos);
+    assert(code.find("func GetConfig() Config {") != std::string::npos);
+    std::cout << "  ✓ Struct output test passed\n";
+}
+
+void testPrintfFunctions() {
+    const char* source = R"(#include <cstdarg>
+/// Logs a message.
+void log_message(int level, const char* fmt, ...);
+
+extern "C" int trace(const char* format, ...);
+
+int sum(int count, ...);
+)";
+    FFIModule module = FFIAnalyzer().analyzeSource(source, "v");
+    assert(module.functions.size() == 3);
+    const FFIFunction& log = module.functions[0];
+    assert(log.printf_format && log.can_use_ffi && log.parameters.size() == 2);
+    assert(module.functions[1].c_name == "v_trace");
+    assert(!module.functions[2].can_use_ffi);
+    assert(module.functions[2].reason == "Variadic functions are bound only with a const char* format before ...");
+
+    // The shim is not variadic and never lets the message act as a format
+    CWrapperGenerator c_generator;
+    std::string shim = c_generator.generateImplementation(module.functions, module.classes, "v");
+    assert(shim.find("void v_log_message(int level, const char* fmt) {\n"
+                     "    log_message(level, \"%s\", fmt);\n}") != std::string::npos);
+    assert(shim.find("int v_trace(const char* format) {\n    return trace(\"%s\", format);\n}") != std::string::npos);
+    std::string header = c_generator.generateCHeader(module, "v");
+    assert(header.find("@note fmt is printed as is, not as a format; format the message before the call.") !=
+           std::string::npos);
+
+    GoFFIGenerator generator;
+    std::string code = generator.generatePackage(module.functions, module.classes, "v");
+    assert(code.find("import (\n\t\"fmt\"\n\t\"unsafe\"\n)") != std::string::npos);
+    assert(code.find("func LogMessage(level int32, format string, args ...any) {\n"
+                     "\tcFormat := C.CString(fmt.Sprintf(format, args...))\n"
+                     "\tdefer C.free(unsafe.Pointer(cFormat))\n"
+                     "\tC.v_log_message(C.int(level), cFormat)\n}") != std::string::npos);
+    assert(code.find("func Trace(format string, args ...any) int32 {") != std::string::npos);
+    assert(code.find("Sum") == std::string::npos);
+
+    // Main-thread dispatch forwards the arguments unchanged
+    module.functions[0].main_thread_only = true;
+    code = generator.generatePackage(module.functions, module.classes, "v");
+    assert(code.find("\t\tlogMessage(level, format, args...)\n") != std::string::npos);
+    std::cout << "  ✓ printf-style function test passed\n";
+}
+
+void testDefaultArguments() {
+    const char* source = R"(#pragma once
+namespace ui {
+constexpr int kMaxSize = 4096;
+enum class Color { Red, Green, Blue };
+
+class Widget {
+public:
+    static constexpr int DEFAULT_WIDTH = 640;
+    static constexpr int DEFAULT_HEIGHT = DEFAULT_WIDTH * 3 / 4;
+    static constexpr int MASK = DEFAULT_WIDTH | 4 ^ 1;
+    static constexpr double kScale = 1.5f;
+
+    Widget(int width = DEFAULT_WIDTH);
+    void resize(int width = Widget::DEFAULT_WIDTH, int height = DEFAULT_HEIGHT);
+    void paint(Color color = Color::Blue, double scale = kScale * 2);
+    int clamp(int value, int limit = kMaxSize - 1 << 2);
+    void label(const char* text = "none", bool bold = false);
+    void pick(int mask = MASK, int index = -1);
+    void mixed(int a = DEFAULT_WIDTH, int b = UNKNOWN);
+    void size(int cells = 2.5);
+};
+}
+int area(int w = ui::Widget::DEFAULT_WIDTH, int h = 10u);
+)";
+    FFIModule module = FFIAnalyzer().analyzeSource(source, "ui");
+    assert(module.constants.size() == 5);
+    assert(module.constants[0].name == "kMaxSize");
+    assert(module.constants[2].name == "Widget::DEFAULT_HEIGHT");
+    assert(module.constants[2].value == "Widget::DEFAULT_WIDTH * 3 / 4");
+    assert(module.constants[4].value == "1.5");
+
+    const FFIClass& widget = module.classes[0];
+    auto method = [&widget](const std::string& name) {
+        return *std::find_if(widget.methods.begin(), widget.methods.end(),
+                             [&name](const FFIFunction& f) { return f.name == name; });
+    };
+    assert(method("resize").parameters[1].default_value == "Widget::DEFAULT_HEIGHT");
+    assert(method("paint").parameters[0].default_value == "Color::Blue");
+    assert(method("mixed").parameters[1].default_source == "UNKNOWN");
+    assert(method("mixed").parameters[1].default_value.empty());
+    assert(module.functions[0].parameters[1].default_value == "10");
+
+    GoFFIGenerator generator;
+    std::string code = generator.generatePackage(module.functions, module.classes, "ui", module.enums,
+                                                 module.constants);
+    // Only referenced constants, spelled with their Go names
+    assert(code.find("// C++ constants referenced by default arguments.\n"
+                     "const (\n"
+                     "\tMaxSize             = 4096\n"
+                     "\tWidgetDefaultWidth  = 640\n"
+                     "\tWidgetDefaultHeight = WidgetDefaultWidth * 3 / 4\n"
+                     "\tWidgetMask          = WidgetDefaultWidth | (4 ^ 1)\n"
+                     "\tWidgetScale         = 1.5\n"
+                     ")\n") != std::string::npos);
+    assert(code.find("// NewWidgetDefault calls NewWidget with the C++ default arguments\n"
+                     "// width = WidgetDefaultWidth.\n"
+                     "func NewWidgetDefault() *Widget {\n"
+                     "\treturn NewWidget(WidgetDefaultWidth)\n}") != std::string::npos);
+    assert(code.find("func (w *Widget) ResizeDefault() {\n"
+                     "\tw.Resize(WidgetDefaultWidth, WidgetDefaultHeight)\n}") != std::string::npos);
+    assert(code.find("\tw.Paint(ColorBlue, WidgetScale*2)\n") != std::string::npos);
+    assert(code.find("\tw.Label(\"none\", false)\n") != std::string::npos);
+    assert(code.find("\tw.Pick(WidgetMask, -1)\n") != std::string::npos);
+    // C++ groups kMaxSize - 1 << 2 as (kMaxSize - 1) << 2
+    assert(code.find("// limit = (MaxSize - 1) << 2.\n"
+                     "func (w *Widget) ClampDefault(value int32) int32 {\n"
+                     "\treturn w.Clamp(value, (MaxSize-1)<<2)\n}") != std::string::npos);
+    assert(code.find("func AreaDefault() int32 {\n"
+                     "\treturn Area(WidgetDefaultWidth, 10)\n}") != std::string::npos);
+    // Unresolved names and a fraction for an integer keep the argument required
+    assert(code.find("MixedDefault") == std::string::npos);
+    assert(code.find("SizeDefault") == std::string::npos);
+
+    std::string report = generator.generateReport(module.functions, module.classes, "ui", module.enums,
+                                                  module.constants);
+    assert(report.find("Default arguments required in Go (3):\n"
+                       "  Widget::mixed: a = DEFAULT_WIDTH\n"
+                       "  Widget::mixed: b = UNKNOWN\n"
+                       "  Widget::size: cells = 2.5\n") != std::string::npos);
+
+    // Without the constants the names are unknown and nothing is defaulted
+    code = generator.generatePackage(module.functions, module.classes, "ui", module.enums);
+    assert(code.find("ResizeDefault") == std::string::npos);
+    assert(code.find("PaintDefault") == std::string::npos);
+    std::cout << "  ✓ Default argument test passed\n";
+}
+
+void testNameCollisions() {
+    const char* source = R"(#pragma once
+enum class Context { Background, Done };
+
+class Time {
+public:
+    Time(int seconds);
+    static int now();
+    void Delete();
+};
+
+class ContextBackground {
+public:
+    ContextBackground();
+};
+
+class StatusError {
+public:
+    StatusError();
+};
+
+int time_now();
+int time_now();
+void new_time(int unsafe);
+int len(const char* error, int string);
+// @main_thread_only
+void sync();
+)";
+    FFIModule module = FFIAnalyzer().analyzeSource(source, "hostile");
+
+    GoFFIGenerator generator;
+    std::string code = generator.generatePackage(module.functions, module.classes, "hostile", module.enums);
+    // Types keep their names; what they push aside is renamed with every use
+    assert(code.find("type StatusErrorCpp struct {") != std::string::npos);
+    assert(code.find("func NewStatusErrorCpp() *StatusErrorCpp {") != std::string::npos);
+    assert(code.find("type ContextBackground struct {") != std::string::npos);
+    assert(code.find("\tContextBackgroundCpp Context = 0\n"
+                     "\tContextDone          Context = ContextBackgroundCpp + 1\n") != std::string::npos);
+    assert(code.find("range [...]Context{ContextBackgroundCpp, ContextDone}") != std::string::npos);
+    assert(code.find("func TimeNow() int32 {\n\treturn int32(C.hostile_Time_now())") != std::string::npos);
+    assert(code.find("func TimeNowCpp() int32 {\n\treturn int32(C.hostile_time_now_0())") != std::string::npos);
+    // A redeclaration gets a name of its own, checked again once renamed
+    assert(code.find("func TimeNowCppCpp() int32 {\n\treturn int32(C.hostile_time_now_1())") != std::string::npos);
+    // Methods collide with the generated ones of their type only
+    assert(code.find("func (t *Time) DeleteCpp() {\n\tC.hostile_Time_Delete(t.ptr)\n}") != std::string::npos);
+    assert(code.find("func (t *Time) Delete() {") != std::string::npos);
+    assert(code.find("func NewTime(seconds int32) *Time {") != std::string::npos);
+    assert(code.find("func NewTimeCpp(unsafe_ int32) {\n"
+                     "\tC.hostile_new_time(C.int(unsafe_))\n}") != std::string::npos);
+    assert(code.find("func Len(error_ string, string_ int32) int32 {") != std::string::npos);
+    // The unexported half of a main-thread wrapper must not hide package sync
+    assert(code.find("\t\tsyncCpp()\n") != std::string::npos);
+    assert(code.find("func syncCpp() {") != std::string::npos);
+
+    std::string report = generator.generateReport(module.functions, module.classes, "hostile", module.enums);
+    assert(report.find("Renamed to avoid Go name collisions (7):\n"
+                       "  StatusError: StatusError -> StatusErrorCpp (collides with support code StatusError)\n"
+                       "  Context::Background: ContextBackground -> ContextBackgroundCpp"
+                       " (collides with ContextBackground)\n"
+                       "  time_now: TimeNow -> TimeNowCpp (collides with Time::now)\n"
+                       "  time_now: TimeNow -> TimeNowCppCpp (collides with Time::now)\n"
+                       "  new_time: NewTime -> NewTimeCpp (collides with Time::Time)\n"
+                       "  sync (main-thread call): sync -> syncCpp (collides with package sync)\n"
+                       "  Time::Delete: Delete -> DeleteCpp (collides with generated method Time.Delete)\n")
+           != std::string::npos);
+
+    FFIOptions options;
+    options.collision_rule = RenameRule::Prefix;
+    options.collision_affix = "Native";
+    code = GoFFIGenerator(options).generatePackage(module.functions, module.classes, "hostile", module.enums);
+    assert(code.find("type NativeStatusError struct {") != std::string::npos);
+    assert(code.find("func NativeTimeNow() int32 {") != std::string::npos);
+    assert(code.find("func nativeSync() {") != std::string::npos);
+
+    options.collision_affix = "2x";
+    bool rejected = false;
+    try {
+        GoFFIGenerator(options).generatePackage(module.functions, module.classes, "hostile", module.enums);
+    } catch (const std::invalid_argument&) {
+        rejected = true;
+    }
+    assert(rejected);
+    std::cout << "  ✓ Name collision test passed\n";
+}
+
+void testGoroutineSafetyDocs() {
+    std::string code = GoFFIGenerator().generatePackage({}, {makeCalculator()}, "calc");
+    assert(code.find("// Calculator wraps the C++ class Calculator.\n"
+                     "// It is not safe for concurrent use by multiple goroutines.\n"
+                     "type Calculator struct {\n\tptr unsafe.Pointer\n}") != std::string::npos);
+    assert(code.find("mu.Lock") == std::string::npos);
+    assert(code.find("\"sync\"") == std::string::npos);
+
+    FFIOptions options;
+    options.thread_safe = true;
+    std::string safe = GoFFIGenerator(options).generatePackage({}, {makeCalculator()}, "calc");
+    assert(safe.find("// Calculator wraps the C++ class Calculator.\n"
+                     "// It is safe for concurrent use by multiple goroutines: its methods hold\n"
+                     "// a mutex for the duration of each C++ call.\n"
+                     "type Calculator struct {\n\tptr unsafe.Pointer\n\tmu  sync.Mutex\n}") != std::string::npos);
+    assert(safe.find("import (\n\t\"sync\"\n\t\"unsafe\"\n)") != std::string::npos);
+    assert(safe.find("func (c *Calculator) Add(value int32) {\n"
+                     "\tc.mu.Lock()\n\tdefer c.mu.Unlock()\n"
+                     "\tC.Calculator_add(c.ptr, C.int32_t(value))\n}") != std::string::npos);
+    assert(safe.find("func (c *Calculator) Delete() {\n"
+                     "\tc.mu.Lock()\n\tdefer c.mu.Unlock()\n") != std::string::npos);
+    assert(safe.find("not safe for concurrent use") == std::string::npos);
+    std::cout << "  ✓ Goroutine safety doc test passed\n";
+}
+
+void testProxyIterators() {
+    FFIModule module = FFIAnalyzer().analyzeSource(R"(
+class Point {
+public:
+    Point(int x, int y);
+    int x() const;
+};
+
+class PointIterator {
+public:
+    PointIterator(int index);
+    Point operator*() const;
+    PointIterator& operator++();
+    bool operator!=(const PointIterator& other) const;
+};
+
+class Path {
+public:
+    Path();
+    PointIterator begin() const;
+    PointIterator end() const;
+};
+
+// @iterable bool
+class Bits {
+public:
+    Bits();
+    std::vector<bool>::iterator begin();
+    std::vector<bool>::iterator end();
+};
+
+class Opaque {
+public:
+    Opaque();
+    std::vector<int>::iterator begin();
+    std::vector<int>::iterator end();
+};
+)", "geo");
+    const FFIClass& path = module.classes[2];
+    const FFIClass& bits = module.classes[3];
+    assert(path.iterator_element.cpp_type == "Point" && path.iterator_element.c_type == "void*");
+    assert(path.iterator_const);
+    assert(bits.iterator_element.cpp_type == "bool" && !bits.iterator_const);
+    assert(!path.methods[1].can_use_ffi);
+    // Without operator* in the header or @iterable there is nothing to yield
+    assert(module.classes[4].iterator_element.cpp_type.empty());
+
+    std::string shim = CWrapperGenerator().generateImplementation({}, module.classes, "geo");
+    assert(shim.find("#include <utility>") != std::string::npos);
+    assert(shim.find("struct IterationState {") != std::string::npos);
+    assert(shim.find("void* geo_Path_iter_begin(const void* self) {\n"
+                     "    return new IterationState<const Path>(*static_cast<const Path*>(self));\n}")
+           != std::string::npos);
+    assert(shim.find("bool geo_Path_iter_has_next(void* it) {\n"
+                     "    auto* state = static_cast<IterationState<const Path>*>(it);\n"
+                     "    return state->it != state->end;\n}") != std::string::npos);
+    // A proxy yielding a wrapped class is copied into an object Go owns
+    assert(shim.find("void* geo_Path_iter_get(void* it) {\n"
+                     "    return new Point(*static_cast<IterationState<const Path>*>(it)->it);\n}")
+           != std::string::npos);
+    assert(shim.find("bool geo_Bits_iter_get(void* it) {\n"
+                     "    return static_cast<bool>(*static_cast<IterationState<Bits>*>(it)->it);\n}")
+           != std::string::npos);
+    assert(shim.find("geo_Opaque_iter") == std::string::npos);
+
+    std::string code = GoFFIGenerator().generatePackage({}, module.classes, "geo");
+    assert(code.find("func (p *Path) Range(yield func(*Point) bool) {\n"
+                     "\tit := C.geo_Path_iter_begin(p.ptr)\n"
+                     "\tdefer C.geo_Path_iter_delete(it)\n"
+                     "\tfor ; C.geo_Path_iter_has_next(it); C.geo_Path_iter_next(it) {\n"
+                     "\t\tif !yield(&Point{ptr: C.geo_Path_iter_get(it)}) {\n") != std::string::npos);
+    assert(code.find("func (p *Path) Elements() []*Point {") != std::string::npos);
+    assert(code.find("\t\tif !yield(bool(C.geo_Bits_iter_get(it))) {\n") != std::string::npos);
+    assert(code.find("func (b *Bits) Elements() []bool {") != std::string::npos);
+    assert(code.find("IterGet") == std::string::npos);
+    std::cout << "  ✓ Proxy iterator test passed\n";
+}
+
+void testCachedStrings() {
+    FFIModule module = FFIAnalyzer().analyzeSource(R"(
+// @poolable reconnect
+class Session {
+public:
+    Session();
+    // @cached
+    const char* name() const;
+    void setName(const char* name);
+    // @cached
+    const char* server() const;
+    // @cached
+    int port() const;
+    void reconnect();
+};
+)", "session");
+    const FFIClass& session = module.classes[0];
+    assert(session.methods[1].cached && !session.methods[2].cached);
+
+    // Caching changes what Go observes, so it stays off unless asked for
+    std::string plain = GoFFIGenerator().generatePackage({}, module.classes, "session");
+    assert(plain.find("stringCache") == std::string::npos);
+    assert(plain.find("Uncached") == std::string::npos);
+
+    FFIOptions options;
+    options.cached_strings = true;
+    GoFFIGenerator generator(options);
+    std::string code = generator.generatePackage({}, module.classes, "session");
+    assert(code.find("\t\"sync/atomic\"\n") != std::string::npos);
+    assert(code.find("type Session struct {\n"
+                     "\tptr         unsafe.Pointer\n"
+                     "\tnameCache   stringCache\n"
+                     "\tserverCache stringCache\n}") != std::string::npos);
+    assert(code.find("// Name wraps Session::name and caches the result:\n"
+                     "// changes made on the C++ side are not seen until SetName or InvalidateCache clears it.\n"
+                     "// NameUncached always reads from C++.\n"
+                     "func (s *Session) Name() string {\n"
+                     "\treturn s.nameCache.load(s.NameUncached)\n}") != std::string::npos);
+    // A read that raced a setter is taken back out of the cache
+    assert(code.find("\tgen := c.gen.Load()\n"
+                     "\tresult := read()\n"
+                     "\tif c.value.CompareAndSwap(nil, &result) && c.gen.Load() != gen {\n"
+                     "\t\tc.value.CompareAndSwap(&result, nil)\n") != std::string::npos);
+    assert(code.find("func (c *stringCache) clear() {\n\tc.gen.Add(1)\n\tc.value.Store(nil)\n}") != std::string::npos);
+    assert(code.find("func (s *Session) NameUncached() string {") != std::string::npos);
+    assert(code.find("func (s *Session) SetName(name string) {\n"
+                     "\tdefer s.nameCache.clear()\n") != std::string::npos);
+    assert(code.find("func (s *Session) InvalidateCache() {\n"
+                     "\ts.nameCache.clear()\n\ts.serverCache.clear()\n}") != std::string::npos);
+    // Only strings are cached; a recycled object must not keep its old results
+    assert(code.find("portCache") == std::string::npos);
+    assert(code.find("\tobj.Reconnect()\n\tobj.InvalidateCache()\n\tp.pool.Put(obj)\n") != std::string::npos);
+
+    std::string report = generator.generateReport({}, module.classes, "session");
+    assert(report.find("String results cached in Go (2):\n"
+                       "  Session::name, cleared by Session::setName\n"
+                       "  Session::server\n") != std::string::npos);
+    std::cout << "  ✓ Cached string test passed\n";
+}
+
+void testFeatureMacros() {
+    FFIModule module = FFIAnalyzer().analyzeSource(R"(#ifndef VAULT_H
+#define VAULT_H
+class Vault {
+public:
+    Vault();
+    int size() const;
+#ifdef VAULT_WITH_ENCRYPTION
+    void scramble(int key);
+#endif
+};
+
+#if defined(VAULT_WITH_COMPRESSION)
+class Compressor {
+public:
+    Compressor();
+    int ratio() const;
+};
+#else
+int uncompressed();
+#endif
+
+#ifdef VAULT_WITH_ENCRYPTION
+int checksum(int key);
+#endif
+int version();
+#endif
+)", "vault");
+    const FFIClass& vault = module.classes[0];
+    assert(vault.feature.empty() && vault.methods[1].feature.empty());
+    assert(vault.methods[2].name == "scramble" && vault.methods[2].feature == "VAULT_WITH_ENCRYPTION");
+    assert(module.classes[1].feature == "VAULT_WITH_COMPRESSION");
+    assert(module.classes[1].methods[1].feature == "VAULT_WITH_COMPRESSION");   // inherited from the class
+    assert(module.functions[0].name == "uncompressed" && module.functions[0].feature.empty());
+    assert(module.functions[1].feature == "VAULT_WITH_ENCRYPTION");
+    assert(module.functions[2].feature.empty());
+
+    // Without configured features, guards change nothing
+    std::string plain = GoFFIGenerator().generatePackage(module.functions, module.classes, "vault");
+    assert(plain.find("func Checksum(") != std::string::npos);
+    assert(plain.find("Features") == std::string::npos);
+    assert(CWrapperGenerator().generateImplementation(module.functions, module.classes, "vault").find("#ifdef")
+           == std::string::npos);
+
+    FFIOptions options;
+    options.features["VAULT_WITH_ENCRYPTION"] = FFIFeature();
+    options.features["VAULT_WITH_COMPRESSION"].tag = "vault_zip";
+    options.features["VAULT_WITH_COMPRESSION"].cgo_ldflags = "-lz";
+
+    std::string shim = CWrapperGenerator(options).generateImplementation(module.functions, module.classes, "vault");
+    assert(shim.find("#ifdef VAULT_WITH_ENCRYPTION\n"
+                     "int vault_checksum(int key) {\n    return checksum(key);\n}\n"
+                     "#endif // VAULT_WITH_ENCRYPTION\n") != std::string::npos);
+    assert(shim.find("#ifdef VAULT_WITH_ENCRYPTION\nvoid vault_Vault_scramble(void* self, int key) {") != std::string::npos);
+    assert(shim.find("#ifdef VAULT_WITH_COMPRESSION\n// Compressor\n") != std::string::npos);
+    assert(shim.find("    delete static_cast<Compressor*>(self);\n}\n#endif // VAULT_WITH_COMPRESSION\n")
+           != std::string::npos);
+
+    GoFFIGenerator generator(options);
+    assert(generator.featureTag("VAULT_WITH_ENCRYPTION") == "vault_encryption");
+    std::string code = generator.generatePackage(module.functions, module.classes, "vault");
+    assert(code.find("Checksum") == std::string::npos && code.find("Scramble") == std::string::npos);
+    assert(code.find("Compressor") == std::string::npos);
+    assert(code.find("func Version() int32 {") != std::string::npos);
+    assert(code.find("var features []string\n") != std::string::npos);
+    assert(code.find("// Known features: vault_zip, vault_encryption.\n"
+                     "func Features() []string {\n"
+                     "\treturn append([]string(nil), features...)\n}") != std::string::npos);
+
+    std::map<std::string, std::string> files = generator.generateFeatureFiles(module.functions, module.classes,
+                                                                              "vault");
+    assert(files.size() == 2);
+    const std::string& encryption = files["vault_encryption.go"];
+    assert(encryption.find("//go:build vault_encryption\n\npackage vault\n\n/*\n#include <stdlib.h>\n")
+           != std::string::npos);
+    assert(encryption.find("int vault_checksum(int key);\nvoid vault_Vault_scramble(void* self, int key);\n*/\n")
+           != std::string::npos);
+    assert(encryption.find("func (v *Vault) Scramble(key int32) {") != std::string::npos);
+    assert(encryption.find("func init() {\n\tfeatures = append(features, \"vault_encryption\")\n}\n")
+           != std::string::npos);
+    const std::string& zip = files["vault_zip.go"];
+    assert(zip.find("//go:build vault_zip\n") != std::string::npos);
+    assert(zip.find("/*\n#cgo LDFLAGS: -lz\n\n#include <stdlib.h>\n") != std::string::npos);
+    assert(zip.find("type Compressor struct {") != std::string::npos);
+    assert(zip.find("func (c *Compressor) Delete() {") != std::string::npos);
+
+    std::string report = generator.generateReport(module.functions, module.classes, "vault");
+    assert(report.find("Optional features (2):\n"
+                       "  VAULT_WITH_COMPRESSION: 3 symbols, built only with -tags vault_zip\n"
+                       "  VAULT_WITH_ENCRYPTION: 2 symbols, built only with -tags vault_encryption\n")
+           != std::string::npos);
+
+    options.features["VAULT_WITH_COMPRESSION"].tag = "vault-zip";
+    bool threw = false;
+    try {
+        GoFFIGenerator(options).generatePackage(module.functions, module.classes, "vault");
+    } catch (const std::invalid_argument&) {
+        threw = true;
+    }
+    assert(threw);
+    std::cout << "  ✓ Feature macro test passed\n";
+}
+
+void testOwnedArrayReturns() {
+    FFIModule module = FFIAnalyzer().analyzeSource(R"(
+std::unique_ptr<int32_t[]> firstSquares(size_t count);
+std::unique_ptr<int32_t[]> unsized(int32_t first);
+class Histogram {
+public:
+    Histogram();
+    std::unique_ptr<uint32_t[]> counts(size_t& size) const;
+    // @array_size n
+    std::unique_ptr<uint32_t[]> head(int32_t n) const;
+};
+)", "samples");
+    const FFIFunction& squares = module.functions[0];
+    assert(squares.can_use_ffi && squares.array_size == "count" && squares.c_return_type == "int32_t*");
+    assert(!module.functions[1].can_use_ffi);
+    assert(module.functions[1].reason.find("length parameter") != std::string::npos);
+    const FFIClass& histogram = module.classes[0];
+    assert(histogram.methods[1].array_size == "size");
+    assert(histogram.methods[1].parameters[0].direction == ParamDirection::Out);
+    assert(histogram.methods[2].array_size == "n");
+
+    // The array leaves C++ through release() and returns for delete[], never delete
+    CWrapperGenerator c_generator;
+    std::string shim = c_generator.generateImplementation(module.functions, module.classes, "samples");
+    assert(shim.find("int32_t* samples_firstSquares(size_t count) {\n"
+                     "    return firstSquares(count).release();\n}\n\n"
+                     "void samples_firstSquares_delete_array(int32_t* array) {\n"
+                     "    delete[] array;\n}\n") != std::string::npos);
+    assert(shim.find("uint32_t* samples_Histogram_counts(const void* self, size_t* size) {\n"
+                     "    return static_cast<const Histogram*>(self)->counts(*size).release();\n") != std::string::npos);
+    std::string header = c_generator.generateHeader(module.functions, module.classes, "samples");
+    assert(header.find("uint32_t* samples_Histogram_head(const void* self, int32_t n);\n"
+                       "void samples_Histogram_head_delete_array(uint32_t* array);\n") != std::string::npos);
+    std::string c_header = c_generator.generateCHeader(module, "samples");
+    assert(c_header.find("/** @note The array holds count elements; release it with "
+                         "samples_firstSquares_delete_array(). */") != std::string::npos);
+    assert(c_generator.generateDefFile(module, "samples").find("    samples_firstSquares_delete_array\n") !=
+           std::string::npos);
+
+    // Go copies the array into a slice before the deferred delete[] runs
+    GoFFIGenerator generator;
+    std::string code = generator.generatePackage(module.functions, module.classes, "samples");
+    assert(code.find("func FirstSquares(count uint) []int32 {\n"
+                     "\tarray := C.samples_firstSquares(C.size_t(count))\n"
+                     "\tdefer C.samples_firstSquares_delete_array(array)\n"
+                     "\tresult := make([]int32, count)\n"
+                     "\tfor i, v := range unsafe.Slice(array, count) {\n"
+                     "\t\tresult[i] = int32(v)\n\t}\n"
+                     "\treturn result\n}") != std::string::npos);
+    // A length written by C++ sizes the slice instead of becoming a result
+    assert(code.find("func (h *Histogram) Counts() []uint32 {\n"
+                     "\tvar cSize C.size_t\n") != std::string::npos);
+    assert(code.find("\tresult := make([]uint32, cSize)\n") != std::string::npos);
+    assert(code.find("func (h *Histogram) Head(n int32) []uint32 {") != std::string::npos);
+    assert(code.find("func Unsized") == std::string::npos);
+    std::cout << "  ✓ Owned array return test passed\n";
+}
+
+void testExceptionBehavior() {
+    FFIModule module = FFIAnalyzer().analyzeSource(R"(
+int32_t parseDecimal(const char* text);
+// @throws
+int32_t divide(int32_t a, int32_t b);
+class Account {
+public:
+    Account(int64_t balance);
+    // @throws
+    void withdraw(int64_t amount);
+};
+)", "guard");
+    assert(!module.functions[0].throws && module.functions[1].throws);
+
+    // By default only @throws functions catch; an exception elsewhere aborts
+    CWrapperGenerator abort_shim;
+    std::string shim = abort_shim.generateImplementation(module.functions, module.classes, "guard");
+    assert(shim.find("int32_t guard_parseDecimal(const char* text) {\n"
+                     "    return parseDecimal(text);\n}") != std::string::npos);
+    assert(shim.find("void guard_Account_withdraw(void* self, int64_t amount, char** exception) {\n"
+                     "    *exception = nullptr;\n"
+                     "    try {\n"
+                     "        static_cast<Account*>(self)->withdraw(amount);\n"
+                     "    } catch (const std::exception& e) {\n"
+                     "        *exception = strdup(e.what());\n"
+                     "    } catch (...) {\n"
+                     "        *exception = strdup(\"unknown C++ exception\");\n"
+                     "    }\n}") != std::string::npos);
+    assert(shim.find("#include <exception>\n") != std::string::npos);
+    std::string code = GoFFIGenerator().generatePackage(module.functions, module.classes, "guard");
+    assert(code.find("func ParseDecimal(text string) int32 {") != std::string::npos);
+    assert(code.find("// Divide wraps divide.\n"
+                     "// A C++ exception it throws is returned as an *ExceptionError.\n"
+                     "func Divide(a int32, b int32) (int32, error) {\n"
+                     "\tvar exception *C.char\n"
+                     "\tresult := int32(C.guard_divide(C.int32_t(a), C.int32_t(b), &exception))\n"
+                     "\tif err := exceptionResult(\"divide\", exception); err != nil {\n"
+                     "\t\treturn 0, err\n\t}\n"
+                     "\treturn result, nil\n}") != std::string::npos);
+    assert(code.find("func (a *Account) Withdraw(amount int64) error {\n"
+                     "\tvar exception *C.char\n"
+                     "\tC.guard_Account_withdraw(a.ptr, C.int64_t(amount), &exception)\n"
+                     "\treturn exceptionResult(\"Account::withdraw\", exception)\n}") != std::string::npos);
+    assert(code.find("type ExceptionError struct {") != std::string::npos);
+    assert(code.find("panicOnException") == std::string::npos);
+
+    // Under the panic default every other shim catches, and Go panics with what()
+    FFIOptions options;
+    options.default_exception_behavior = ExceptionBehavior::Panic;
+    shim = CWrapperGenerator(options).generateImplementation(module.functions, module.classes, "guard");
+    assert(shim.find("int32_t guard_parseDecimal(const char* text, char** exception) {\n"
+                     "    *exception = nullptr;\n"
+                     "    try {\n"
+                     "        return parseDecimal(text);\n") != std::string::npos);
+    assert(shim.find("    }\n    return {};\n}") != std::string::npos);
+    assert(shim.find("void guard_Account_delete(void* self) {\n    delete") != std::string::npos);
+    code = GoFFIGenerator(options).generatePackage(module.functions, module.classes, "guard");
+    assert(code.find("func ParseDecimal(text string) int32 {\n"
+                     "\tcText := C.CString(text)\n"
+                     "\tdefer C.free(unsafe.Pointer(cText))\n"
+                     "\tvar exception *C.char\n"
+                     "\tresult := int32(C.guard_parseDecimal(cText, &exception))\n"
+                     "\tpanicOnException(\"parseDecimal\", exception)\n"
+                     "\treturn result\n}") != std::string::npos);
+    // Constructors have no error result, so they panic even when annotated
+    assert(code.find("\tptr := C.guard_Account_new(C.int64_t(balance), &exception)\n"
+                     "\tpanicOnException(\"Account::Account\", exception)\n"
+                     "\treturn &Account{ptr: ptr}\n") != std::string::npos);
+    assert(code.find("func panicOnException(function string, what *C.char) {\n"
+                     "\tif err := exceptionResult(function, what); err != nil {\n"
+                     "\t\tpanic(err)\n") != std::string::npos);
+    assert(code.find("func Divide(a int32, b int32) (int32, error) {") != std::string::npos);
+    std::cout << "  ✓ Exception behavior test passed\n";
+}
+
+void testHandleInvalidation() {
+    std::string source = R"(
+#include <cstdint>
+constexpr int32_t MYLIB_ERR_DISCONNECTED = -3;
+class Session {
+public:
+    Session(int32_t port);
+    ~Session();
+    static Session* open(int32_t port);
+    // @status
+    int32_t send(int32_t message);
+    int32_t sent() const;
+};
+)";
+    FFIAnalyzer analyzer;
+    FFIModule module = analyzer.analyzeSource(source, "client");
+    // The destructor is not taken for a second, default constructor
+    assert(std::count_if(module.classes[0].methods.begin(), module.classes[0].methods.end(),
+                         [](const FFIFunction& method) { return method.is_constructor; }) == 1);
+    FFIOptions options;
+    options.handle_invalidation["Session"].errors = {"MYLIB_ERR_DISCONNECTED", "-7"};
+    options.handle_invalidation["Session"].factory = "Session::open";
+    std::string code = GoFFIGenerator(options).generatePackage(module.functions, module.classes, "client",
+                                                               module.enums, module.constants);
+
+    assert(code.find("// C++ constants referenced by handle invalidation rules.\n"
+                     "const (\n\tMylibErrDisconnected = -3\n)") != std::string::npos);
+    assert(code.find("\tptr     unsafe.Pointer\n\thandle  sync.RWMutex\n\tinvalid atomic.Bool\n") != std::string::npos);
+    // The error that invalidates the handle is returned once; later calls never reach C
+    assert(code.find("func (s *Session) Send(message int32) error {\n"
+                     "\ts.handle.RLock()\n"
+                     "\tdefer s.handle.RUnlock()\n"
+                     "\tif s.invalid.Load() {\n"
+                     "\t\treturn ErrHandleInvalidated\n"
+                     "\t}\n"
+                     "\tstatus := C.client_Session_send(s.ptr, C.int32_t(message))\n"
+                     "\tif status == MylibErrDisconnected || status == -7 {\n"
+                     "\t\ts.invalid.Store(true)\n"
+                     "\t\treturn &HandleInvalidatedError{Err: statusResult(\"Session::send\", int64(status))}\n"
+                     "\t}\n") != std::string::npos);
+    assert(code.find("\tif s.invalid.Load() {\n\t\tpanic(ErrHandleInvalidated)\n\t}\n"
+                     "\treturn int32(C.client_Session_sent(s.ptr))\n") != std::string::npos);
+    assert(code.find("\tif s.ptr != nil && !s.invalid.Load() {\n\t\tC.client_Session_delete(s.ptr)\n") != std::string::npos);
+    assert(code.find("func (s *Session) Reconnect(port int32) error {\n"
+                     "\ts.handle.Lock()\n"
+                     "\tdefer s.handle.Unlock()\n"
+                     "\tif !s.invalid.Load() {\n"
+                     "\t\treturn nil\n"
+                     "\t}\n"
+                     "\tptr := C.client_Session_open(C.int32_t(port))\n") != std::string::npos);
+    assert(code.find("var ErrHandleInvalidated = errors.New(") != std::string::npos);
+    assert(code.find("func (e *HandleInvalidatedError) Is(target error) bool {") != std::string::npos);
+    assert(code.find("// An invalidated handle is dropped without calling C++, which leaks the\n") != std::string::npos);
+
+    // Unless the destructor is safe on a dead object
+    options.handle_invalidation["Session"].delete_invalidated = true;
+    code = GoFFIGenerator(options).generatePackage(module.functions, module.classes, "client",
+                                                   module.enums, module.constants);
+    assert(code.find("// An invalidated handle is deleted too.\n") != std::string::npos);
+    assert(code.find("\tif s.ptr != nil {\n\t\tC.client_Session_delete(s.ptr)\n") != std::string::npos);
+    assert(code.find("\tif s.ptr != nil {\n\t\tC.client_Session_delete(s.ptr)\n\t}\n\ts.ptr = ptr\n") !=
+           std::string::npos);
+    options.handle_invalidation["Session"].delete_invalidated = false;
+
+    // Without a factory there is no Reconnect
+    options.handle_invalidation["Session"].factory.clear();
+    code = GoFFIGenerator(options).generatePackage(module.functions, module.classes, "client",
+                                                   module.enums, module.constants);
+    assert(code.find("Reconnect") == std::string::npos);
+    assert(code.find("or panic with it, without calling C++.\n") != std::string::npos);
+
+    auto rejected = [&](const FFIOptions& bad) {
+        try {
+            GoFFIGenerator(bad).generatePackage(module.functions, module.classes, "client",
+                                                module.enums, module.constants);
+        } catch (const std::invalid_argument&) {
+            return true;
+        }
+        return false;
+    };
+    FFIOptions bad;
+    bad.handle_invalidation["Socket"].errors = {"-3"};
+    assert(rejected(bad));
+    bad.handle_invalidation.clear();
+    bad.handle_invalidation["Session"].errors = {"MYLIB_ERR_TIMEOUT"};
+    assert(rejected(bad));
+    bad.handle_invalidation["Session"].errors = {"-3"};
+    bad.handle_invalidation["Session"].factory = "Session::sent";
+    assert(rejected(bad));
+    std::cout << "  ✓ Handle invalidation test passed\n";
+}
+
+void testVisitors() {
+    std::string source = R"(
+#include <cstdint>
+class Node {
+public:
+    int32_t id() const;
+};
+class Shape {
+public:
+    virtual int32_t area() const = 0;
+};
+class Tree {
+public:
+    void forEach(void (*visit)(const Node*, void*), void* context) const;
+    void walk(bool (*visit)(void* user_data, const Node& node), void* user_data);
+    void forEachShape(void (*visit)(const Shape*, void*), void* context) const;
+    void scan(void (*first)(const Node*, void*), void (*second)(const Node*, void*), void* context);
+    void find(int (*visit)(const Node*, void*), void* context);
+};
+// @collect shallow_nodes
+void for_each_shallow(const Tree& tree, int32_t depth, void (*visit)(const Node*, void*), void* ctx);
+void count_to(int32_t n, void (*visit)(int32_t, void*), void* ctx);
+)";
+    FFIAnalyzer analyzer;
+    FFIModule module = analyzer.analyzeSource(source, "tree");
+    auto find = [&](const std::string& name) {
+        for (const auto& cls : module.classes) {
+            for (const auto& method : cls.methods) {
+                if (method.name == name) {
+                    return method;
+                }
+            }
+        }
+        for (const auto& func : module.functions) {
+            if (func.name == name) {
+                return func;
+            }
+        }
+        return FFIFunction{};
+    };
+
+    FFIFunction for_each = find("forEach");
+    assert(for_each.can_use_ffi && !for_each.visitor.stops);
+    assert(for_each.visitor.callback == "visit" && for_each.visitor.context == "context");
+    assert(for_each.visitor.element.c_type == "void*" && for_each.visitor.collect == "Nodes");
+    assert(find("walk").visitor.stops && find("walk").visitor.collect == "walk_Nodes");
+    // Shape is abstract and has no // @clone member, so nothing collects it
+    assert(find("forEachShape").can_use_ffi && find("forEachShape").visitor.collect.empty());
+    assert(!find("scan").can_use_ffi && !find("find").can_use_ffi);
+    // A free visitor of an object is bound as a method of its class
+    FFIFunction shallow = find("for_each_shallow");
+    assert(shallow.class_name == "Tree" && shallow.visitor.free_receiver == "const Tree&");
+    assert(shallow.parameters.size() == 3 && shallow.visitor.collect == "shallow_nodes");
+    assert(find("count_to").class_name.empty() && find("count_to").visitor.collect == "values");
+
+    CWrapperGenerator c_generator;
+    std::string shim = c_generator.generateImplementation(module.functions, module.classes, "tree");
+    assert(shim.find("void tree_Tree_forEach(const void* self, int64_t (*visit)(void*, uintptr_t), uintptr_t context) {\n"
+                     "    VisitorCall visitor_call{visit, context};\n"
+                     "    static_cast<const Tree*>(self)->forEach([](const Node* element, void* data) -> void { "
+                     "(*static_cast<VisitorCall*>(data))(element); }, &visitor_call);\n") != std::string::npos);
+    // The lambda follows the callback's argument order and passes references by address
+    assert(shim.find("walk([](void* data, const Node& element) -> bool { "
+                     "return (*static_cast<VisitorCall*>(data))(&element); }") != std::string::npos);
+    assert(shim.find("for_each_shallow(*static_cast<const Tree*>(self), depth, [](") != std::string::npos);
+    assert(shim.find("void* tree_Tree_forEach_copy_element(const void* element) {\n"
+                     "    return new Node(*static_cast<const Node*>(element));\n") != std::string::npos);
+    assert(shim.find("tree_Tree_forEachShape_copy_element") == std::string::npos);
+    assert(shim.find("struct VisitorCall {") < shim.find("extern \"C\" {"));
+
+    std::string code = GoFFIGenerator().generatePackage(module.functions, module.classes, "tree",
+                                                        module.enums, module.constants);
+    assert(code.find("func (t *Tree) ForEach(visit func(*Node) bool) {\n"
+                     "\tstate := &visitorState{visit: func(element unsafe.Pointer) bool { return visit(&Node{ptr: element}) }}\n"
+                     "\tvisitor := cgo.NewHandle(state)\n"
+                     "\tdefer visitor.Delete()\n"
+                     "\tC.tree_Tree_forEach(t.ptr, visitorCallback, C.uintptr_t(visitor))\n"
+                     "\tstate.done()\n"
+                     "}\n") != std::string::npos);
+    assert(code.find("func (t *Tree) Nodes() []*Node {\n"
+                     "\tvar collected []*Node\n"
+                     "\tt.ForEach(func(element *Node) bool {\n"
+                     "\t\tcollected = append(collected, &Node{ptr: C.tree_Tree_forEach_copy_element(element.ptr)})\n") !=
+           std::string::npos);
+    assert(code.find("func (t *Tree) WalkNodes() []*Node {") != std::string::npos);
+    assert(code.find("func (t *Tree) ShallowNodes(depth int32) []*Node {") != std::string::npos);
+    assert(code.find("{ return visit(int32(*(*C.int32_t)(element))) }") != std::string::npos);
+    assert(code.find("func Values(n int32) []int32 {") != std::string::npos);
+    assert(code.find("int64_t tree_go_visitor(void* element, uintptr_t visitor);\n") != std::string::npos);
+    assert(code.find("//export tree_go_visitor\n") != std::string::npos);
+    assert(code.find("\t\"runtime/cgo\"\n") != std::string::npos);
+
+    // Thread-safe visits unlock the object while the func runs
+    FFIOptions options;
+    options.thread_safe = true;
+    code = GoFFIGenerator(options).generatePackage(module.functions, module.classes, "tree",
+                                                   module.enums, module.constants);
+    assert(code.find("// t is unlocked while visit runs, so it may call the methods of t,\n") != std::string::npos);
+    assert(code.find("return visit(&Node{ptr: element}) }, unlocked: &t.mu}\n") != std::string::npos);
+    assert(code.find("\t\tstate.unlocked.Unlock()\n\t\tdefer state.unlocked.Lock()\n") != std::string::npos);
+    std::cout << "  ✓ Visitor callback test passed\n";
+}
+
+void testBaseFactories() {
+    std::string source = R"(
+#include <cstdint>
+class Shape {
+public:
+    virtual ~Shape();
+    static Shape* create(int32_t kind);
+    // @borrowed
+    static Shape* unit(int32_t kind);
+    static const Shape* find(int32_t kind);
+    virtual double area() const = 0;
+};
+Shape* make_square(double side);
+)";
+    FFIAnalyzer analyzer;
+    FFIModule module = analyzer.analyzeSource(source, "shapes");
+    assert(module.classes[0].borrowed);
+    assert(module.classes[0].static_methods[0].factory == "Shape");
+    assert(module.classes[0].static_methods[1].borrowed);
+    // A const pointer cannot be deleted, so it is no factory
+    assert(module.classes[0].static_methods[2].factory.empty());
+    assert(module.functions[0].factory == "Shape");
+
+    FFIOptions options;
+    std::string code = GoFFIGenerator(options).generatePackage(module.functions, module.classes, "shapes",
+                                                               module.enums, module.constants);
+    assert(code.find("\tptr      unsafe.Pointer\n\tborrowed bool\n") != std::string::npos);
+    // Only factories make an abstract class, so their nullptr is an error
+    assert(module.functions[0].interface_factory);
+    assert(code.find("// The caller owns the returned Shape; call Delete when done.\n"
+                     "// A nullptr from C++ is returned as a *FactoryError.\n"
+                     "func ShapeCreate(kind int32) (*Shape, error) {\n"
+                     "\tptr := C.shapes_Shape_create(C.int32_t(kind))\n"
+                     "\tif ptr == nil {\n"
+                     "\t\treturn nil, &FactoryError{Function: \"Shape::create\"}\n"
+                     "\t}\n"
+                     "\tresult := &Shape{ptr: ptr}\n"
+                     "\treturn result, nil\n") != std::string::npos);
+    assert(code.find("\tresult := &Shape{ptr: ptr, borrowed: true}\n") != std::string::npos);
+    assert(code.find("func MakeSquare(side float64) (*Shape, error) {") != std::string::npos);
+    assert(code.find("type FactoryError struct {") != std::string::npos);
+    assert(code.find("// The virtual destructor cleans up after the concrete class.\n") != std::string::npos);
+    std::string report = GoFFIGenerator(options).generateReport(module.functions, module.classes, "shapes");
+    assert(report.find("  Shape: Shape::create, Shape::unit, make_square\n") != std::string::npos);
+    assert(code.find("func ShapeFind(kind int32) unsafe.Pointer {") != std::string::npos);
+    // Delete leaves borrowed objects to C++
+    assert(code.find("\tif s.ptr != nil && !s.borrowed {\n\t\tC.shapes_Shape_delete(s.ptr)\n\t}\n\ts.ptr = nil\n") !=
+           std::string::npos);
+
+    // Owned results are deleted through the base, which must allow it
+    std::string shim = CWrapperGenerator(options).generateImplementation(module.functions, module.classes, "shapes");
+    assert(shim.find("#include <type_traits>") != std::string::npos);
+    assert(shim.find("void* shapes_Shape_create(int32_t kind) {\n    static_assert(") != std::string::npos);
+    assert(shim.find("std::has_virtual_destructor<Shape>::value") != std::string::npos);
+    assert(shim.find("void* shapes_Shape_unit(int32_t kind) {\n    return Shape::unit(kind);\n") != std::string::npos);
+    std::string header = CWrapperGenerator(options).generateCHeader(module, "shapes");
+    assert(header.find("release it with shapes_Shape_delete().") != std::string::npos);
+    assert(header.find("The library keeps ownership of the result") != std::string::npos);
+
+    // Without a borrowed factory wrappers have no borrowed flag
+    module.classes[0].static_methods.erase(module.classes[0].static_methods.begin() + 1);
+    module.classes[0].borrowed = false;
+    code = GoFFIGenerator(options).generatePackage(module.functions, module.classes, "shapes",
+                                                   module.enums, module.constants);
+    assert(code.find("borrowed") == std::string::npos);
+
+    // A concrete class has constructors too, so a nullptr stays a nil result
+    FFIModule concrete = analyzer.analyzeSource("class Node {\npublic:\n    Node();\n    static Node* find(int id);\n};\n",
+                                                "nodes");
+    assert(concrete.classes[0].static_methods[0].factory == "Node");
+    assert(!concrete.classes[0].static_methods[0].interface_factory);
+    code = GoFFIGenerator(options).generatePackage(concrete.functions, concrete.classes, "nodes");
+    assert(code.find("func NodeFind(id int32) *Node {") != std::string::npos);
+    assert(code.find("FactoryError") == std::string::npos);
+
+    std::cout << "  ✓ Base pointer factory test passed\n";
+}
+
+void testTmConversion() {
+    std::string source = R"(
+#include <ctime>
+std::tm echo(const std::tm& when);
+int day_of_year(const std::tm* when);
+/// @param[out] out the epoch
+void epoch(std::tm* out);
+void next_day(std::tm& day);
+)";
+    FFIAnalyzer analyzer;
+    FFIModule module = analyzer.analyzeSource(source, "calendar");
+    assert(module.functions[0].parameters[0].c_type == "struct tm");
+    assert(module.functions[0].c_return_type == "struct tm");
+    assert(module.functions[1].parameters[0].c_type == "const struct tm*");
+    assert(module.functions[2].parameters[0].direction == ParamDirection::Out);
+    assert(module.functions[3].parameters[0].direction == ParamDirection::InOut);
+
+    FFIOptions options;
+    std::string code = GoFFIGenerator(options).generatePackage(module.functions, module.classes, "calendar",
+                                                               module.enums, module.constants);
+    assert(code.find("#include <time.h>\n") != std::string::npos);
+    assert(code.find("func Echo(when time.Time) time.Time {\n"
+                     "\treturn timeFromTm(C.calendar_echo(tmFromTime(when)))\n") != std::string::npos);
+    assert(code.find("\tcWhen := tmFromTime(when)\n\treturn int32(C.calendar_day_of_year(&cWhen))\n") !=
+           std::string::npos);
+    assert(code.find("func Epoch() time.Time {\n\tvar cOut C.struct_tm\n") != std::string::npos);
+    assert(code.find("func NextDay(day *time.Time) {\n"
+                     "\tcDay := tmFromTime(*day)\n"
+                     "\tC.calendar_next_day(&cDay)\n"
+                     "\t*day = timeFromTm(cDay)\n") != std::string::npos);
+    // tm_year counts from 1900 and tm_mon from 0
+    assert(code.find("\ttm.tm_year = C.int(t.Year() - 1900)\n\ttm.tm_mon = C.int(t.Month() - 1)\n") !=
+           std::string::npos);
+    assert(code.find("time.Date(int(tm.tm_year)+1900, time.Month(tm.tm_mon)+1, int(tm.tm_mday),") !=
+           std::string::npos);
+
+    // A writable pointer is passed through; a reference is dereferenced
+    std::string shim = CWrapperGenerator(options).generateImplementation(module.functions, module.classes,
+                                                                         "calendar");
+    assert(shim.find("void calendar_epoch(struct tm* out) {\n    epoch(out);\n") != std::string::npos);
+    assert(shim.find("void calendar_next_day(struct tm* day) {\n    next_day(*day);\n") != std::string::npos);
+    std::string header = CWrapperGenerator(options).generateCHeader(module, "calendar");
+    assert(header.find("#include <time.h>\n") != std::string::npos);
+
+    std::cout << "  ✓ std::tm conversion test passed\n";
+}
+
+void testDeclsHeader() {
+    std::string source = R"(
+class Vault {
+public:
+    Vault();
+    int size() const;
+#ifdef VAULT_WITH_ENCRYPTION
+    void scramble(int key);
+#endif
+};
+int version();
+)";
+    FFIOptions options;
+    options.features["VAULT_WITH_ENCRYPTION"];
+    std::map<std::string, std::string> inline_files = FFIGenerator(options).generateGoFiles(source, "vault");
+    assert(inline_files["generated_decls.h"].empty());
+
+    options.decls_header = true;
+    std::map<std::string, std::string> files = FFIGenerator(options).generateGoFiles(source, "vault");
+    const std::string& header = files["generated_decls.h"];
+    const std::string& package = files["vault.go"];
+    // The preamble keeps its #cgo lines and includes the header from the package directory
+    assert(package.find("#cgo CFLAGS: -I${SRCDIR}/../include\n#cgo CFLAGS: -I${SRCDIR}\n") != std::string::npos);
+    assert(package.find("#include \"generated_decls.h\"\n*/\n") != std::string::npos);
+    assert(package.find("vault_version(void)") == std::string::npos);
+    assert(files["vault_encryption.go"].find("#include \"generated_decls.h\"\n*/\n") != std::string::npos);
+    assert(files["vault_encryption.go"].find("void vault_Vault_scramble") == std::string::npos);
+
+    // It declares what the preambles declared inline, feature shims included
+    assert(header.find("#ifndef VAULT_GENERATED_DECLS_H\n#define VAULT_GENERATED_DECLS_H\n") != std::string::npos);
+    for (const auto* declaration : {"int vault_version(void);", "void* vault_Vault_new(void);",
+                                    "void vault_Vault_scramble(void* self, int key);"}) {
+        assert(header.find(declaration) != std::string::npos);
+    }
+    assert(inline_files["vault.go"].find("void vault_Vault_scramble") == std::string::npos);
+    assert(inline_files["vault_encryption.go"].find("void vault_Vault_scramble(void* self, int key);") !=
+           std::string::npos);
+    assert(FFIGenerator(options).generateGoFiles(source, "vault") == files);
+
+    std::cout << "  ✓ Declarations header test passed\n";
+}
+
+void testTaggedPayloads() {
+    std::string source = R"(
+#include <cstdint>
+enum class EventType : int32_t { Click, Key, Scroll, Custom };
+// @mirror
+struct ClickInfo {
+    int32_t x;
+    int32_t y;
+};
+class KeyPress {
+public:
+    int32_t code() const;
+};
+class Event {
+public:
+    EventType type() const;
+    const void* payload() const;
+    size_t payload_size() const;
+    int32_t channel() const;
+    void* data();
+};
+)";
+    FFIAnalyzer analyzer;
+    FFIModule module = analyzer.analyzeSource(source, "events");
+    FFIOptions options;
+    TaggedPayload& payload = options.tagged_payloads["Event::payload"];
+    payload.tag = "type";
+    payload.size = "payload_size";
+    payload.types = {{"Scroll", "double"}, {"EventType::Key", "KeyPress"}, {"Click", "ClickInfo"}, {"9", "int32_t"}};
+    std::string code = GoFFIGenerator(options).generatePackage(module.functions, module.classes, "events",
+                                                               module.enums, module.constants);
+
+    // The raw pointer stays unexported; every declared tag is one entry of the table
+    assert(code.find("func (e *Event) payload() unsafe.Pointer {\n\treturn C.events_Event_payload(e.ptr)\n}") !=
+           std::string::npos);
+    assert(code.find("var eventPayloadTypes = map[EventType]payloadType{\n"
+                     "\tEventTypeClick:  {unsafe.Sizeof(ClickInfo{}), func(p unsafe.Pointer) any { return *(*ClickInfo)(p) }},\n"
+                     "\tEventTypeKey:    {0, func(p unsafe.Pointer) any { return &KeyPress{ptr: p, borrowed: true} }},\n"
+                     "\tEventTypeScroll: {unsafe.Sizeof(float64(0)), func(p unsafe.Pointer) any { return *(*float64)(p) }},\n"
+                     "\tEventType(9):    {unsafe.Sizeof(int32(0)), func(p unsafe.Pointer) any { return *(*int32)(p) }},\n"
+                     "}\n") != std::string::npos);
+    assert(code.find("func (e *Event) Payload() (any, error) {\n"
+                     "\tptr := e.payload()\n"
+                     "\tif ptr == nil {\n"
+                     "\t\treturn nil, nil\n"
+                     "\t}\n"
+                     "\ttag := e.Type()\n"
+                     "\tsize := uintptr(e.PayloadSize())\n"
+                     "\tpayload, ok := eventPayloadTypes[tag]\n"
+                     "\tif !ok || size < payload.size {\n"
+                     "\t\traw := RawPayload{Tag: int64(tag), Bytes: C.GoBytes(ptr, C.int(size))}\n"
+                     "\t\tif !ok {\n"
+                     "\t\t\treturn raw, ErrUnknownPayloadTag\n") != std::string::npos);
+    // Handles into the event are borrowed, so Delete leaves them to C++
+    assert(code.find("type KeyPress struct {\n\tptr      unsafe.Pointer\n\tborrowed bool\n}") != std::string::npos);
+    assert(code.find("\tif k.ptr != nil && !k.borrowed {\n") != std::string::npos);
+    assert(code.find("var ErrUnknownPayloadTag = errors.New(") != std::string::npos);
+    assert(code.find("var ErrPayloadTooSmall = errors.New(") != std::string::npos);
+    // Other void* results are plain unsafe.Pointer methods
+    assert(code.find("func (e *Event) Data() unsafe.Pointer {") != std::string::npos);
+
+    // Without a size, unknown tags carry no bytes and nothing is too small
+    payload.size.clear();
+    code = GoFFIGenerator(options).generatePackage(module.functions, module.classes, "events",
+                                                   module.enums, module.constants);
+    assert(code.find("\t\treturn RawPayload{Tag: int64(tag)}, ErrUnknownPayloadTag\n") != std::string::npos);
+    assert(code.find("ErrPayloadTooSmall") == std::string::npos);
+
+    auto rejected = [&](const FFIOptions& bad) {
+        try {
+            GoFFIGenerator(bad).generatePackage(module.functions, module.classes, "events",
+                                                module.enums, module.constants);
+        } catch (const std::invalid_argument&) {
+            return true;
+        }
+        return false;
+    };
+    FFIOptions bad = options;
+    bad.tagged_payloads["Event::payload"].types["Wheel"] = "double";
+    assert(rejected(bad));
+    bad = options;
+    bad.tagged_payloads["Event::payload"].types["Custom"] = "std::string";
+    assert(rejected(bad));
+    bad = options;
+    // Integer tags take integer keys only
+    bad.tagged_payloads["Event::payload"].tag = "channel";
+    assert(rejected(bad));
+    bad.tagged_payloads["Event::payload"].types = {{"3", "double"}};
+    assert(!rejected(bad));
+    bad.tagged_payloads["Event::payload"].tag = "missing";
+    assert(rejected(bad));
+    bad = options;
+    bad.tagged_payloads["Event::channel"] = payload;
+    assert(rejected(bad));
+    bad = options;
+    bad.tagged_payloads["Event::payload"].size = "type";
+    assert(rejected(bad));
+    std::cout << "  ✓ Tagged payload test passed\n";
+}
+
+void testTemplatePolicies() {
+    std::string source = R"(
+#include <cstdint>
+struct IsEven {
+    bool operator()(int32_t value) const { return value % 2 == 0; }
+};
+struct Sum {
+    int32_t operator()(int32_t a, int32_t b) const { return a + b; }
+};
+// @instantiate IsEven
+template <typename Predicate>
+int32_t count_accepted(int32_t n, Predicate pred) { return pred(n) ? 1 : 0; }
+template <typename Compare>
+int32_t better_of(int32_t a, int32_t b, Compare better);
+// @instantiate Sum
+template <typename Op>
+int32_t fold(int32_t n, Op op);
+// @instantiate IsEven
+// @instantiate Sum
+template <typename Predicate>
+int32_t first_accepted(int32_t n, Predicate pred);
+)";
+    FFIAnalyzer analyzer;
+    FFIModule module = analyzer.analyzeSource(source, "policies");
+    auto find = [&](const std::string& name) {
+        for (const auto& func : module.functions) {
+            if (func.name == name) {
+                return func;
+            }
+        }
+        return FFIFunction{};
+    };
+
+    // The callback and its context take the policy's place
+    FFIFunction count = find("count_accepted");
+    assert(count.can_use_ffi && count.visitor.policy == "IsEven" && count.visitor.stops);
+    assert(count.parameters.size() == 3 && count.parameters[2].name == "pred_context");
+    assert(count.visitor.element.cpp_type == "int32_t" && count.visitor.element.c_type == "int32_t");
+
+    // Unlisted, mistyped and ambiguous instantiations are reported
+    assert(!find("better_of").can_use_ffi);
+    assert(find("better_of").reason.find("@instantiate <functor>") != std::string::npos);
+    assert(!find("fold").can_use_ffi);
+    assert(find("fold").reason == "Functor Sum of fold must return bool and take one argument");
+    assert(!find("first_accepted").can_use_ffi);
+    assert(find("first_accepted").reason.find("more than one instantiation") != std::string::npos);
+
+    FFIOptions options;
+    std::string shim = CWrapperGenerator(options).generateImplementation(module.functions, module.classes,
+                                                                         "policies");
+    assert(shim.find("    VisitorCall visitor_call{pred, pred_context};\n"
+                     "    return count_accepted(n, [&visitor_call](int32_t element) -> bool "
+                     "{ return visitor_call(&element); });\n") != std::string::npos);
+    assert(shim.find("better_of(") == std::string::npos);
+
+    GoFFIGenerator generator(options);
+    std::string code = generator.generatePackage(module.functions, module.classes, "policies",
+                                                 module.enums, module.constants);
+    assert(code.find("// pred stands in for the IsEven that count_accepted is instantiated with; "
+                     "its results go back to C++.\n"
+                     "func CountAccepted(n int32, pred func(int32) bool) int32 {\n"
+                     "\tstate := &visitorState{visit: func(element unsafe.Pointer) bool "
+                     "{ return pred(int32(*(*C.int32_t)(element))) }, policy: true}\n") != std::string::npos);
+    // Every result goes back to C++; only a panic stops the calls
+    assert(code.find("\tnext := state.visit(element)\n\tstate.stopped = !next && !state.policy\n") !=
+           std::string::npos);
+    assert(code.find("BetterOf") == std::string::npos);
+
+    std::string report = generator.generateReport(module.functions, module.classes, "policies");
+    assert(report.find("better_of") != std::string::npos);
+    std::cout << "  ✓ Template policy test passed\n";
+}
+
+void testDevirtualization() {
+    std::string source = R"(
+#include <cstdint>
+class Shape {
+public:
+    virtual ~Shape();
+    virtual double area() const = 0;
+};
+// @devirtualize
+class Circle : public Shape {
+public:
+    Circle();
+    double area() const override;
+    virtual void grow(double by);
+    double radius() const;
+    static Circle* unit();
+};
+class Square : public Shape {
+public:
+    Square();
+    double area() const override;
+};
+)";
+    FFIAnalyzer analyzer;
+    FFIModule module = analyzer.analyzeSource(source, "shapes");
+    FFIOptions options;
+
+    // Only the virtual methods of the marked class get a direct shim
+    std::string shim = CWrapperGenerator(options).generateImplementation(module.functions, module.classes, "shapes");
+    assert(shim.find("double shapes_Circle_area_direct(const void* self) {\n"
+                     "    return static_cast<const Circle*>(self)->Circle::area();\n}") != std::string::npos);
+    assert(shim.find("void shapes_Circle_grow_direct(void* self, double by) {\n"
+                     "    static_cast<Circle*>(self)->Circle::grow(by);\n}") != std::string::npos);
+    assert(shim.find("shapes_Circle_radius_direct") == std::string::npos);
+    assert(shim.find("shapes_Square_area_direct") == std::string::npos);
+    std::string header = CWrapperGenerator(options).generateHeader(module.functions, module.classes, "shapes");
+    assert(header.find("double shapes_Circle_area_direct(const void* self);") != std::string::npos);
+
+    // Constructed wrappers take the direct path; factory results may hold a subclass
+    GoFFIGenerator generator(options);
+    std::string code = generator.generatePackage(module.functions, module.classes, "shapes",
+                                                 module.enums, module.constants);
+    assert(code.find("type Circle struct {\n\tptr   unsafe.Pointer\n\texact bool\n}") != std::string::npos);
+    assert(code.find("\treturn &Circle{ptr: C.shapes_Circle_new(), exact: true}\n") != std::string::npos);
+    assert(code.find("\treturn &Circle{ptr: ptr}\n") != std::string::npos);
+    assert(code.find("func (c *Circle) Area() float64 {\n"
+                     "\tif c.exact {\n"
+                     "\t\treturn float64(C.shapes_Circle_area_direct(c.ptr))\n"
+                     "\t}\n"
+                     "\treturn float64(C.shapes_Circle_area(c.ptr))\n}") != std::string::npos);
+    assert(code.find("func (c *Circle) Grow(by float64) {\n"
+                     "\tif c.exact {\n"
+                     "\t\tC.shapes_Circle_grow_direct(c.ptr, C.double(by))\n"
+                     "\t\treturn\n"
+                     "\t}\n") != std::string::npos);
+    assert(code.find("func (s *Square) Area() float64 {\n\treturn float64(C.shapes_Square_area(s.ptr))\n}") !=
+           std::string::npos);
+
+    std::string bench = generator.generateDevirtualizeBenchmarks(module.classes, "shapes");
+    assert(bench.find("func BenchmarkCircleAreaVirtual(b *testing.B) {\n"
+                      "\tobj := NewCircle()\n"
+                      "\tdefer obj.Delete()\n"
+                      "\tobj.exact = false\n") != std::string::npos);
+    assert(bench.find("func BenchmarkCircleAreaDirect(b *testing.B) {") != std::string::npos);
+    assert(bench.find("Grow") == std::string::npos);
+    assert(generator.generateDevirtualizeBenchmarks({makeCalculator()}, "calc").empty());
+
+    // Nothing constructs an abstract class as itself
+    FFIModule abstract = analyzer.analyzeSource("// @devirtualize\n" + source.substr(source.find("class Shape")),
+                                                "shapes");
+    bool rejected = false;
+    try {
+        CWrapperGenerator(options).generateImplementation(abstract.functions, abstract.classes, "shapes");
+    } catch (const std::invalid_argument&) {
+        rejected = true;
+    }
+    assert(rejected);
+    std::cout << "  ✓ Devirtualization test passed\n";
+}
+
+void testMappings() {
+    std::string source = R"(
+#include <cstdint>
+#include <string>
+#include <system_error>
+class Widget {
+public:
+    Widget();
+    const char* label() const;
+    // @throws
+    void resize(int32_t width, int32_t height);
+    // @main_thread_only
+    void show();
+    static Widget* find(const std::string& name);
+};
+int32_t load(const char* path, int32_t& out_size, std::error_code& ec);
+// @signal_unsafe
+void install_handler();
+)";
+    FFIAnalyzer analyzer;
+    FFIModule module = analyzer.analyzeSource(source, "gui");
+    FFIOptions options;
+    options.thread_safe = true;
+    GoFFIGenerator generator(options);
+    std::string code = generator.generateMappings(module.functions, module.classes, "gui", module.enums,
+                                                  module.constants);
+
+    assert(code.find("package gui\n") != std::string::npos);
+    assert(code.find("import") == std::string::npos);
+    assert(code.find("func Mappings() []Mapping {\n\treturn append([]Mapping(nil), mappings...)\n}") !=
+           std::string::npos);
+    assert(code.find("\t{Kind: \"constructor\", Cpp: \"Widget::Widget\", C: \"gui_Widget_new\", Go: \"NewWidget\", "
+                     "GoResults: []string{\"*Widget\"}, Ownership: \"caller\", Errors: \"none\", "
+                     "Threading: \"unsynchronized\"},\n") != std::string::npos);
+    assert(code.find("Go: \"Widget.Label\", Result: \"const char*\", GoResults: []string{\"string\"}, "
+                     "Ownership: \"copied\", Errors: \"none\", Threading: \"mutex\"},\n") != std::string::npos);
+    assert(code.find("Go: \"Widget.Resize\", Params: []ParamMapping{{Name: \"width\", Cpp: \"int32_t\", "
+                     "Go: \"int32\", Direction: \"in\"}") != std::string::npos);
+    assert(code.find("GoResults: []string{\"error\"}, Errors: \"exception\", Threading: \"mutex\"}") !=
+           std::string::npos);
+    assert(code.find("Go: \"Widget.Show\", Errors: \"none\", Threading: \"main_thread\"}") != std::string::npos);
+    // Static factories hand their result to the caller; strings are borrowed for the call
+    assert(code.find("Go: \"WidgetFind\", Params: []ParamMapping{{Name: \"name\", Cpp: \"const std::string&\", "
+                     "Go: \"string\", Direction: \"in\", Ownership: \"borrowed\"}}, Result: \"Widget*\", "
+                     "GoResults: []string{\"*Widget\"}, Ownership: \"caller\"") != std::string::npos);
+    // Outputs and the error_code are results, which Go does not pass
+    assert(code.find("{Name: \"out_size\", Cpp: \"int32_t&\", Go: \"\", Direction: \"out\"}, "
+                     "{Name: \"ec\", Cpp: \"std::error_code&\", Go: \"\", Direction: \"out\"}}") != std::string::npos);
+    assert(code.find("GoResults: []string{\"int32\", \"int32\", \"error\"}, Errors: \"error_code\", "
+                     "Threading: \"unsynchronized\"}") != std::string::npos);
+    assert(code.find("Go: \"InstallHandler\", Errors: \"none\", Threading: \"unsynchronized\", "
+                     "BuildTag: \"" + options.signal_unsafe_tag + "\"}") != std::string::npos);
+
+    // Excluded symbols are not in the package, so neither are their mappings
+    options.signal_unsafe_policy = SignalUnsafePolicy::Exclude;
+    code = GoFFIGenerator(options).generateMappings(module.functions, module.classes, "gui", module.enums,
+                                                   module.constants);
+    assert(code.find("InstallHandler") == std::string::npos);
+    std::cout << "  ✓ Mappings test passed\n";
+}
+
+void testPreservedSignals() {
+    std::string source = R"(
+#include <cstdint>
+int32_t mylib_init();
+void mylib_shutdown();
+int32_t mylib_version();
+class Engine {
+public:
+    Engine();
+    virtual ~Engine();
+    void start();
+};
+)";
+    FFIAnalyzer analyzer;
+    FFIModule module = analyzer.analyzeSource(source, "mylib");
+    FFIOptions options;
+    options.preserve_signals["mylib_init"] = {"SIGPIPE", "SIGUSR1"};
+    options.preserve_signals["Engine::start"] = {};
+
+    // Listed shims snapshot the handlers first; nothing else pays for it
+    std::string shim = CWrapperGenerator(options).generateImplementation(module.functions, module.classes, "mylib");
+    assert(shim.find("#include <csignal>\n") != std::string::npos);
+    assert(shim.find("class SignalGuard {") != std::string::npos);
+    assert(shim.find("int32_t mylib_mylib_init(void) {\n"
+                     "#if !defined(_WIN32)\n"
+                     "    SignalGuard signal_guard(std::vector<int>{SIGPIPE, SIGUSR1});\n"
+                     "#endif\n"
+                     "    return mylib_init();\n}") != std::string::npos);
+    assert(shim.find("void mylib_Engine_start(void* self) {\n"
+                     "#if !defined(_WIN32)\n"
+                     "    SignalGuard signal_guard(std::vector<int>{});\n") != std::string::npos);
+    assert(shim.find("int32_t mylib_mylib_version(void) {\n    return mylib_version();\n}") != std::string::npos);
+
+    GoFFIGenerator generator(options);
+    std::string code = generator.generatePackage(module.functions, module.classes, "mylib",
+                                                 module.enums, module.constants);
+    assert(code.find("// returns, except that its SIGPIPE and SIGUSR1 handling runs before Go's.\n"
+                     "func MylibInit() int32 {") != std::string::npos);
+    assert(code.find("// returns.\nfunc (e *Engine) Start() {") != std::string::npos);
+    std::string report = generator.generateReport(module.functions, module.classes, "mylib",
+                                                  module.enums, module.constants);
+    assert(report.find("Go signal handlers restored after the call (2):\n"
+                       "  Engine::start\n"
+                       "  mylib_init, chaining SIGPIPE, SIGUSR1\n") != std::string::npos);
+
+    // Unknown functions and signal names are configuration errors
+    options.preserve_signals["mylib_teardown"] = {};
+    bool threw = false;
+    try {
+        CWrapperGenerator(options).generateImplementation(module.functions, module.classes, "mylib");
+    } catch (const std::invalid_argument&) {
+        threw = true;
+    }
+    assert(threw);
+    options.preserve_signals.erase("mylib_teardown");
+    options.preserve_signals["mylib_shutdown"] = {"SIGNOPE"};
+    threw = false;
+    try {
+        CWrapperGenerator(options).generateImplementation(module.functions, module.classes, "mylib");
+    } catch (const std::invalid_argument&) {
+        threw = true;
+    }
+    assert(threw);
+    std::cout << "  ✓ Preserved signals test passed\n";
+}
+
+void testCallableResults() {
+    std::string source = R"(
+#include <cstdint>
+#include <functional>
+class Widget {
+public:
+    Widget();
+    virtual ~Widget();
+    int32_t value() const;
+    int32_t Widget::* field();
+    std::function<int32_t(int32_t)> adder(int32_t n);
+private:
+    int32_t (Widget::*hidden(int32_t which))() const;
+};
+using Getter = int32_t (Widget::*)() const;
+Getter pick_getter(int32_t which);
+int32_t (Widget::*pick_raw(int32_t which))() const;
+int32_t (*pick_free(int32_t which))(int32_t);
+// Not a declaration: std::function<void()> commented(int);
+)";
+    FFIAnalyzer analyzer;
+    FFIModule module = analyzer.analyzeSource(source, "widgets");
+    auto find = [](const std::vector<FFIFunction>& functions, const std::string& name) {
+        return std::find_if(functions.begin(), functions.end(),
+                            [&name](const FFIFunction& f) { return f.name == name; });
+    };
+
+    // Aliases are looked through, and the reason names the workaround
+    auto getter = find(module.functions, "pick_getter");
+    assert(getter != module.functions.end() && !getter->can_use_ffi);
+    assert(getter->reason == "Return type Getter is a pointer to member function of Widget, which Go cannot "
+                             "call through C: return an enum naming the method and dispatch on it in a bound "
+                             "method, or take a callback such as void (*fn)(int32_t, void*) with a void* context, "
+                             "which is bound to a Go func");
+
+    // Declarator syntax the parser skips is reported rather than dropped
+    auto raw = find(module.functions, "pick_raw");
+    assert(raw != module.functions.end() && !raw->can_use_ffi);
+    assert(raw->return_type == "int32_t (Widget::*)() const");
+    assert(raw->reason.find("is a pointer to member function of Widget") != std::string::npos);
+    auto free = find(module.functions, "pick_free");
+    assert(free != module.functions.end() && free->return_type == "int32_t (*)(int32_t)");
+    assert(free->reason.find("is a function pointer, which Go cannot call through C: call it in C++") !=
+           std::string::npos);
+    assert(find(module.functions, "commented") == module.functions.end());
+
+    const FFIClass& widget = module.classes[0];
+    auto field = find(widget.methods, "field");
+    assert(field != widget.methods.end() && !field->can_use_ffi);
+    assert(field->reason == "Return type int32_t Widget::* is a pointer to data member of Widget and has no C "
+                            "equivalent: bind accessor methods instead");
+    auto adder = find(widget.methods, "adder");
+    assert(adder != widget.methods.end() && adder->class_name == "Widget" && !adder->can_use_ffi);
+    assert(adder->reason.find("is a std::function, which Go cannot call through C") != std::string::npos);
+    assert(find(widget.methods, "hidden") == widget.methods.end());
+
+    // The rest of the class still binds, and the report lists every one
+    FFIOptions options;
+    GoFFIGenerator generator(options);
+    std::string code = generator.generatePackage(module.functions, module.classes, "widgets",
+                                                 module.enums, module.constants);
+    assert(code.find("func (w *Widget) Value() int32 {") != std::string::npos);
+    assert(code.find("Adder") == std::string::npos);
+    std::string report = generator.generateReport(module.functions, module.classes, "widgets",
+                                                  module.enums, module.constants);
+    assert(report.find("Not bound (5):\n") != std::string::npos);
+    assert(report.find("  pick_raw: Return type int32_t (Widget::*)() const is a pointer to member function") !=
+           std::string::npos);
+    std::cout << "  ✓ Callable results test passed\n";
+}
+
+void testSmallStrings() {
+    std::string source = R"(
+#include <cstdint>
+#include <string>
+class Interner {
+public:
+    Interner();
+    virtual ~Interner();
+    int32_t intern(const std::string& token);
+    void forEachPrefix(const char* prefix, void (*visit)(int32_t, void*), void* context) const;
+};
+int32_t token_length(const char* token);
+)";
+    FFIAnalyzer analyzer;
+    FFIModule module = analyzer.analyzeSource(source, "tokens");
+
+    // Disabled by default: every string argument is copied with C.CString
+    FFIOptions plain;
+    GoFFIGenerator plain_generator(plain);
+    std::string code = plain_generator.generatePackage(module.functions, module.classes, "tokens");
+    assert(code.find("cToken := C.CString(token)") != std::string::npos);
+    assert(code.find("smallStringSize") == std::string::npos);
+    assert(code.find("#cgo noescape") == std::string::npos);
+    assert(plain_generator.generateSmallStringBenchmarks(module.functions, module.classes, "tokens").empty());
+
+    FFIOptions options;
+    options.small_string_size = 8;
+    GoFFIGenerator generator(options);
+    code = generator.generatePackage(module.functions, module.classes, "tokens");
+    assert(code.find("const smallStringSize = 8\n") != std::string::npos);
+    assert(code.find("\tvar cTokenBuf [smallStringSize + 1]byte\n"
+                     "\tcToken, cTokenOwned := cString(token, cTokenBuf[:])\n"
+                     "\tdefer freeCString(cTokenOwned)\n") != std::string::npos);
+    assert(code.find("func cString(s string, buf []byte) (p, owned *C.char) {") != std::string::npos);
+
+    // The buffer only stays on the stack when cgo knows C neither keeps it
+    // nor calls back into Go
+    assert(code.find("#cgo noescape tokens_token_length\n#cgo nocallback tokens_token_length\n") !=
+           std::string::npos);
+    assert(code.find("#cgo noescape tokens_Interner_intern\n#cgo nocallback tokens_Interner_intern\n") != std::string::npos);
+
+    // Visitors call back into Go, so their strings still go through C.CString
+    assert(code.find("cPrefix := C.CString(prefix)") != std::string::npos);
+    assert(code.find("nocallback tokens_Interner_forEachPrefix") == std::string::npos);
+
+    std::string bench = generator.generateSmallStringBenchmarks(module.functions, module.classes, "tokens");
+    assert(bench.find("func BenchmarkSmallStringStack(b *testing.B) {") != std::string::npos);
+    assert(bench.find("func BenchmarkSmallStringHeap(b *testing.B) {") != std::string::npos);
+    std::cout << "  ✓ Small strings test passed\n";
+}
+
+void testModuleInterfaces() {
+    // A header followed by an interface unit, as a mixed project passes them
+    std::string source = R"(
+#include <cstdint>
+class Part {
+public:
+    Part(int32_t weight);
+    int32_t weight() const;
+};
+module;
+#include "garage.h"
+export module engine:core;
+import std;
+export import :detail;
+export using ::Part;
+
+// @status
+int32_t hidden(int32_t x);
+class Tuning {
+public:
+    int32_t boost() const;
+};
+
+/// An engine whose parts add to its weight.
+export class Engine {
+public:
+    Engine(int32_t power);
+    void fit(const Part& part);
+};
+
+export {
+// @status
+int32_t check_power(int32_t power);
+}
+namespace tools {
+int32_t internal_tool();
+export int32_t public_tool(int32_t x);
+}
+int32_t hidden(int32_t x) { return x; }
+export int32_t after_definition();
+module :private;
+int32_t privately();
+)";
+    FFIAnalyzer analyzer;
+    FFIModule module = analyzer.analyzeSource(source, "garage");
+    assert(module.modules == std::vector<std::string>{"engine"});
+
+    // Only exported declarations are bound, next to the header's
+    std::vector<std::string> names;
+    for (const auto& func : module.functions) {
+        names.push_back(func.name);
+    }
+    assert((names == std::vector<std::string>{"check_power", "public_tool", "after_definition"}));
+    assert(module.functions[0].returns_status);
+    assert(module.classes.size() == 2);
+    assert(module.classes[0].name == "Part" && module.classes[1].name == "Engine");
+
+    // Engine::fit takes the header's Part, which is declared once
+    const auto& methods = module.classes[1].methods;
+    auto fit = std::find_if(methods.begin(), methods.end(), [](const FFIFunction& f) { return f.name == "fit"; });
+    assert(fit != methods.end() && fit->can_use_ffi && fit->parameters[0].c_type == "const void*");
+
+    // The shim imports the module after its includes
+    FFIGenerator generator;
+    std::string shim = generator.generateCWrapper(source, "garage").second;
+    assert(shim.find("#include \"garage.h\"\n") != std::string::npos);
+    assert(shim.find("import engine;\n") != std::string::npos);
+    assert(shim.find("import engine;\n") > shim.rfind("#include"));
+    assert(shim.find("hidden") == std::string::npos && shim.find("Tuning") == std::string::npos);
+
+    // Without a library header only the module declares the API
+    FFIOptions options;
+    options.modules = {"engine"};
+    options.library_header = false;
+    CWrapperGenerator c_generator(options);
+    shim = c_generator.generateImplementation(module.functions, module.classes, "garage");
+    assert(shim.find("#include \"garage.h\"") == std::string::npos);
+    assert(shim.find("import engine;\n") != std::string::npos);
+
+    // Sources without module declarations are analyzed as they are
+    assert(analyzer.analyzeSource("int32_t module_count();\n", "garage").modules.empty());
+    std::cout << "  ✓ Module interfaces test passed\n";
+}
+
+void testSymbolNames() {
+    std::string source = R"(
+namespace net {
+namespace tcp {
+class Session {
+public:
+    Session();
+    Session(int32_t port);
+    void connect(int32_t timeout);
+    static Session* open(int32_t port);
+};
+int32_t version();
+}
+}
+namespace disk {
+int32_t version();
+int32_t scale(int32_t x);
+int32_t scale(int32_t x, int32_t y);
+}
+extern "C" int32_t Session_connect(int32_t timeout);
+)";
+    FFIModule module = FFIAnalyzer().analyzeSource(source, "engine-core");
+    const FFIClass& session = module.classes[0];
+    assert(session.cpp_namespace == "net::tcp" && session.symbol_stem == "engine_core_net_tcp_Session");
+
+    // Prefix, namespace, class, member and, among overloads, their index
+    std::vector<std::string> symbols;
+    for (const auto& shim : CWrapperGenerator::shimFunctions(session)) {
+        symbols.push_back(CWrapperGenerator::shimName(shim));
+    }
+    assert((symbols == std::vector<std::string>{
+        "engine_core_net_tcp_Session_new_0", "engine_core_net_tcp_Session_new_1",
+        "engine_core_net_tcp_Session_connect", "engine_core_net_tcp_Session_open",
+        "engine_core_net_tcp_Session_delete"}));
+    symbols.clear();
+    for (const auto& func : module.functions) {
+        symbols.push_back(CWrapperGenerator::shimName(func));
+    }
+    assert((symbols == std::vector<std::string>{"engine_core_net_tcp_version", "engine_core_disk_version",
+                                                "engine_core_disk_scale_0", "engine_core_disk_scale_1",
+                                                "Session_connect"}));
+
+    // The wrappers are defined in the namespace of what they call
+    CWrapperGenerator c_generator;
+    std::string shim = c_generator.generateImplementation(module.functions, module.classes, "engine-core");
+    assert(shim.find("namespace disk {\nint32_t engine_core_disk_scale_1(int32_t x, int32_t y) {\n"
+                     "    return scale(x, y);\n}\n} // namespace disk\n") != std::string::npos);
+    assert(shim.find("namespace tcp {\n// Session\n") == std::string::npos);
+    assert(shim.find("namespace net::tcp {\n// Session\nvoid* engine_core_net_tcp_Session_new_0(void) {") !=
+           std::string::npos);
+
+    // The report shows the final symbol of every binding
+    std::string report = FFIGenerator().generateReport(source, "engine-core");
+    assert(report.find("Shim symbols (10):\n"
+                       "  net::tcp::Session::Session() -> engine_core_net_tcp_Session_new_0\n") != std::string::npos);
+    assert(report.find("  disk::scale(int32_t,int32_t) -> engine_core_disk_scale_1\n") != std::string::npos);
+    assert(report.find("  Session_connect(int32_t) -> Session_connect\n") != std::string::npos);
+
+    FFIOptions options;
+    options.symbol_prefix = "hyb";
+    module = FFIAnalyzer(options).analyzeSource(source, "engine-core");
+    assert(CWrapperGenerator::shimName(module.classes[0].methods[2]) == "hyb_net_tcp_Session_connect");
+    assert(CWrapperGenerator::shimName(module.functions[0]) == "hyb_net_tcp_version");
+
+    bool threw = false;
+    try {
+        options.symbol_prefix = "2fast";
+        FFIAnalyzer(options).analyzeSource(source, "engine-core");
+    } catch (const std::invalid_argument& e) {
+        threw = std::string(e.what()) == "symbol_prefix: '2fast' is not a C identifier";
+    }
+    assert(threw);
+
+    // Legacy names clash between the namespaces and with the C function
+    options = FFIOptions{};
+    options.legacy_symbol_names = true;
+    module = FFIAnalyzer(options).analyzeSource(source, "engine-core");
+    assert(CWrapperGenerator::shimName(module.classes[0].methods[2]) == "Session_connect");
+    assert(CWrapperGenerator::shimName(module.functions[2]) == "engine_core_scale");
+    threw = false;
+    try {
+        CWrapperGenerator(options).generateImplementation(module.functions, module.classes, "engine-core");
+    } catch (const std::invalid_argument& e) {
+        threw = std::string(e.what()) == "shim symbol engine_core_version is generated for both "
+                                         "net::tcp::version() and disk::version(), which C cannot overload";
+    }
+    assert(threw);
+    module.functions.erase(module.functions.begin() + 1, module.functions.begin() + 4);
+    threw = false;
+    try {
+        CWrapperGenerator(options).generateCHeader(module, "engine-core");
+    } catch (const std::invalid_argument& e) {
+        threw = std::string(e.what()) == "shim symbol Session_connect is generated for both Session_connect(int32_t) "
+                                         "and net::tcp::Session::connect(int32_t), which C cannot overload";
+    }
+    assert(threw);
+
+    // Go numbers the overloads of a method the same way, from the first one
+    module = FFIAnalyzer().analyzeSource(R"(
+class Session {
+public:
+    bool connect(int32_t timeout);
+    bool connect(const char* host);
+};
+)", "engine");
+    std::string code = GoFFIGenerator().generatePackage(module.functions, module.classes, "engine");
+    assert(code.find("func (s *Session) Connect(timeout int32) bool {\n"
+                     "\treturn bool(C.engine_Session_connect_0(s.ptr, C.int32_t(timeout)))\n") != std::string::npos);
+    assert(code.find("func (s *Session) Connect1(host string) bool {") != std::string::npos);
+    assert(code.find("C.engine_Session_connect_1(s.ptr, cHost)") != std::string::npos);
+    std::cout << "  ✓ Symbol names test passed\n";
+}
+
+void testEnumNameFunctions() {
+    std::string source = R"(
+/// Severity of a log line.
+// @go:enum-names func=level_name
+enum class Level : int32_t { Debug, Info, Error = 4 };
+// @go:enum-names func=size_of
+enum Unit { Byte, Word };
+const char* level_name(Level level);
+int32_t size_of(Unit unit);
+)";
+    FFIModule module = FFIAnalyzer().analyzeSource(source, "logs");
+    assert(module.enums[0].name_function == "level_name" && module.enums[0].doc == "Severity of a log line.");
+
+    // String() delegates to the bound C++ function for declared values
+    module.enums[1].name_function.clear();
+    GoFFIGenerator generator;
+    std::string code = generator.generatePackage(module.functions, module.classes, "logs", module.enums);
+    assert(code.find("// String returns the name level_name gives v in C++, or Level(<value>)\n"
+                     "// for a value Level does not declare.\n"
+                     "func (v Level) String() string {\n"
+                     "\tif !v.IsValid() {\n"
+                     "\t\treturn \"Level(\" + strconv.FormatInt(int64(v), 10) + \")\"\n"
+                     "\t}\n"
+                     "\treturn LevelName(v)\n}\n") != std::string::npos);
+    assert(code.find("import \"strconv\"\n") != std::string::npos);
+    assert(code.find("func (v Unit) String()") == std::string::npos);
+
+    // The named function must take the enum and return a const char*
+    auto rejects = [&](const FFIModule& named, const std::string& message) {
+        try {
+            GoFFIGenerator().generatePackage(named.functions, named.classes, "logs", named.enums);
+        } catch (const std::invalid_argument& e) {
+            return std::string(e.what()) == message;
+        }
+        return false;
+    };
+    FFIModule misnamed = FFIAnalyzer().analyzeSource(source, "logs");
+    assert(rejects(misnamed, "Unit: // @go:enum-names names size_of, which does not map a Unit to a const char*"));
+    misnamed.enums[1].name_function = "unit_name";
+    assert(rejects(misnamed, "Unit: // @go:enum-names names unit_name, which is not a bound free function"));
+
+    FFIOptions options;
+    options.features["LOGS_NAMES"];
+    module = FFIAnalyzer().analyzeSource(R"(
+// @go:enum-names func=level_name
+enum class Level { Debug, Info };
+#ifdef LOGS_NAMES
+const char* level_name(Level level);
+#endif
+)", "logs");
+    bool threw = false;
+    try {
+        GoFFIGenerator(options).generatePackage(module.functions, module.classes, "logs", module.enums);
+    } catch (const std::invalid_argument& e) {
+        threw = std::string(e.what()) ==
+                "Level: // @go:enum-names names level_name, which is only bound behind a build tag";
+    }
+    assert(threw);
+    std::cout << "  ✓ Enum name functions test passed\n";
+}
+
+void testLoggers() {
+    std::string source = R"(
+enum class Severity : uint8_t { Verbose, Info, Warn, Err, Fatal };
+// @logger
+void on_log(void (*sink)(void* user, Severity severity, const std::string& text), void* user);
+// @logger
+void also_log(void (*sink)(Severity, const char*));
+// @logger
+void bad_log(void (*sink)(int32_t, const char*));
+)";
+    FFIModule module = FFIAnalyzer().analyzeSource(source, "app");
+    const FFIFunction& on_log = module.functions[0];
+    assert(on_log.can_use_ffi && on_log.logger.callback == "sink" && on_log.logger.context == "user");
+    assert(on_log.logger.level.cpp_type == "Severity" && on_log.parameters[0].c_type == "void (*)(uint8_t, const char*)");
+    assert(!module.functions[1].can_use_ffi &&
+           module.functions[1].reason == "Only one // @logger setter is bound per library, and on_log already is");
+    assert(module.functions[2].reason == "Log callback void (*)(int32_t, const char*) must return void and take "
+                                         "an enum level and a const char* or const std::string& message");
+
+    // The shim keeps the Go handler and hands C++ an adapter of its own signature
+    CWrapperGenerator c_generator;
+    std::string shim = c_generator.generateImplementation(module.functions, module.classes, "app");
+    assert(shim.find("#include <atomic>\n") != std::string::npos);
+    assert(shim.find("void app_on_log(void (*sink)(uint8_t, const char*)) {\n"
+                     "    static std::atomic<void (*)(uint8_t, const char*)> go_log_handler{nullptr};\n"
+                     "    if (sink) {\n"
+                     "        go_log_handler = sink;\n"
+                     "    }\n") != std::string::npos);
+    assert(shim.find("    auto forward = +[](void*, Severity level, const std::string& message) {\n"
+                     "        go_log_handler.load()(static_cast<uint8_t>(level), message.c_str());\n"
+                     "    };\n"
+                     "    on_log(sink ? forward : nullptr, nullptr);\n") != std::string::npos);
+
+    // SetLogger installs the exported Go callback, which maps levels by name
+    GoFFIGenerator generator;
+    std::string code = generator.generatePackage(module.functions, module.classes, "app", module.enums);
+    assert(code.find("func SetLogger(logger *slog.Logger) {\n") != std::string::npos);
+    assert(code.find("\tC.app_on_log(logCallback)\n") != std::string::npos);
+    assert(code.find("void app_go_log(uint8_t level, char* message);\n") != std::string::npos);
+    assert(code.find("//export app_go_log\n"
+                     "func app_go_log(level C.uint8_t, message *C.char) {\n") != std::string::npos);
+    assert(code.find("\tswitch l := Severity(level); {\n"
+                     "\tcase l == SeverityVerbose:\n"
+                     "\t\trecord = slog.LevelDebug\n"
+                     "\tcase l == SeverityWarn:\n"
+                     "\t\trecord = slog.LevelWarn\n"
+                     "\tcase l == SeverityErr, l == SeverityFatal:\n"
+                     "\t\trecord = slog.LevelError\n"
+                     "\t}\n") != std::string::npos);
+    assert(code.find("\t\"context\"\n\t\"log/slog\"\n") != std::string::npos);
+
+    // Go may call back during any call, so no string argument stays on its stack
+    FFIOptions options;
+    options.small_string_size = 16;
+    module = FFIAnalyzer().analyzeSource(source + "void log_line(Severity severity, const char* text);\n", "app");
+    code = GoFFIGenerator(options).generatePackage(module.functions, module.classes, "app", module.enums);
+    assert(code.find("nocallback") == std::string::npos && code.find("cString(") == std::string::npos);
+    std::cout << "  ✓ Loggers test passed\n";
+}
+
+void testCallbackResults() {
+    std::string source = R"(
+enum class Action : int32_t { Deliver, Skip, Abort };
+// @panic_result Abort
+int32_t transfer(int32_t n, Action (*decide)(int32_t chunk, void* ctx), void* ctx);
+void scan(int32_t n, Action (*decide)(void* ctx, int32_t chunk), void* ctx);
+// @panic_result true
+int32_t read_chunks(int32_t n, bool (*on_chunk)(int32_t chunk, void* ctx), void* ctx);
+// @panic_result Retry
+void retry(Action (*decide)(int32_t, void*), void* ctx);
+// @panic_result true
+void each(void (*visit)(int32_t, void*), void* ctx);
+)";
+    FFIModule module = FFIAnalyzer().analyzeSource(source, "io");
+    auto find = [&module](const std::string& name) {
+        return *std::find_if(module.functions.begin(), module.functions.end(),
+                             [&name](const FFIFunction& func) { return func.name == name; });
+    };
+    FFIFunction transfer = find("transfer");
+    assert(transfer.can_use_ffi && transfer.visitor.result.cpp_type == "Action" && !transfer.visitor.stops);
+    assert(transfer.visitor.panic_result == "Abort" && transfer.visitor.collect.empty());
+    assert(find("read_chunks").visitor.panic_result == "true" && find("read_chunks").visitor.stops);
+    assert(find("retry").reason == "// @panic_result Retry is not an enumerator of Action");
+    assert(find("each").reason == "// @panic_result needs a callback returning bool or an enum");
+
+    // The adapter converts the Go func's result back to the enum
+    std::string shim = CWrapperGenerator().generateImplementation(module.functions, module.classes, "io");
+    assert(shim.find("int32_t io_transfer(int32_t n, int64_t (*decide)(void*, uintptr_t), uintptr_t ctx) {\n"
+                     "    VisitorCall visitor_call{decide, ctx};\n"
+                     "    return transfer(n, [](int32_t element, void* data) -> Action { "
+                     "return static_cast<Action>((*static_cast<VisitorCall*>(data))(&element)); }, "
+                     "&visitor_call);\n") != std::string::npos);
+    assert(shim.find("    int64_t (*visit)(void*, uintptr_t);\n") != std::string::npos);
+
+    // Undeclared enumerators panic, and a panic leaves C++ the fallback
+    std::string code = GoFFIGenerator().generatePackage(module.functions, module.classes, "io", module.enums);
+    assert(code.find("// If decide panics, C++ gets ActionAbort from then on, and the panic is\n"
+                     "// raised again once C++ has returned.\n"
+                     "func Transfer(n int32, decide func(int32) Action) int32 {\n"
+                     "\tstate := &visitorState{decide: func(element unsafe.Pointer) int64 {\n"
+                     "\t\tresult := decide(int32(*(*C.int32_t)(element)))\n"
+                     "\t\tif !result.IsValid() {\n"
+                     "\t\t\tpanic(\"transfer: invalid Action \" + strconv.Itoa(int(result)))\n"
+                     "\t\t}\n"
+                     "\t\treturn int64(result)\n"
+                     "\t}, fallback: int64(ActionAbort)}\n") != std::string::npos);
+    // Without // @panic_result an enum falls back to its first enumerator
+    assert(code.find("// If decide panics, C++ gets ActionDeliver from then on") != std::string::npos);
+    assert(code.find("return onChunk(int32(*(*C.int32_t)(element))) }, fallback: 1}\n") != std::string::npos);
+    assert(code.find("\tif state.recovered != nil {\n\t\treturn C.int64_t(state.fallback)\n\t}\n") !=
+           std::string::npos);
+    assert(code.find("func Scans(") == std::string::npos);
+    std::cout << "  ✓ Callback results test passed\n";
+}
+
+void testSingleGoFile() {
+    std::string source = R"(
+#include <string>
+class Counter {
+public:
+    Counter();
+    void add(int n);
+    int total() const;
+};
+std::string greet(const std::string& name);
+)";
+    FFIOptions options;
+    options.package_name = "embedded";
+    std::map<std::string, std::string> files = FFIGenerator(options).generateGoFiles(source, "counter");
+    std::string single = FFIGenerator(options).generateSingleGoFile(source, "counter");
+    // The package file alone, in the requested package, stable across runs
+    assert(single == files["counter.go"]);
+    assert(single.find("package embedded\n") != std::string::npos);
+    assert(single.find("import \"C\"") != std::string::npos);
+    assert(single.find("func (c *Counter) Total() int32") != std::string::npos);
+    assert(single.find("type ParamMapping") == std::string::npos);
+    assert(FFIGenerator(options).generateSingleGoFile(source, "counter") == single);
+
+    // Helpers the bindings do not use are left out
+    assert(single.find("type ErrorCode") == std::string::npos);
+    assert(single.find("\"strconv\"") == std::string::npos);
+
+    auto rejects = [&source](const FFIOptions& options, const std::string& extra, const std::string& message) {
+        try {
+            FFIGenerator(options).generateSingleGoFile(source + extra, "counter");
+        } catch (const std::invalid_argument& e) {
+            assert(std::string(e.what()).find(message) != std::string::npos);
+            return;
+        }
+        assert(false);
+    };
+    rejects(options, "// @signal_unsafe\nvoid trap();\n", "counter_signal_unsafe.go is needed");
+    rejects(options, "// @mirror\nstruct Stat {\n    long size;\n};\n", "counter_layout_linux_amd64.go is needed");
+    FFIOptions featured = options;
+    featured.features["COUNTER_WITH_RESET"];
+    rejects(featured, "#ifdef COUNTER_WITH_RESET\nvoid reset();\n#endif\n", "counter_reset.go is needed");
+    FFIOptions declared = options;
+    declared.decls_header = true;
+    rejects(declared, "", "turn off decls_header");
+
+    // Excluding signal-unsafe functions, or demoting unportable mirrors, fits them in one file
+    FFIOptions excluded = options;
+    excluded.signal_unsafe_policy = SignalUnsafePolicy::Exclude;
+    excluded.layout_mismatch = LayoutMismatchPolicy::Accessors;
+    std::string fitted = FFIGenerator(excluded).generateSingleGoFile(
+        source + "// @signal_unsafe\nvoid trap();\n// @mirror\nstruct Stat {\n    long size;\n};\n", "counter");
+    assert(fitted.find("func Trap()") == std::string::npos);
+    assert(fitted.find("Stat") != std::string::npos);
+
+    std::cout << "  ✓ Single Go file test passed\n";
+}
+
+void testConstrainedOverloads() {
+    std::string source = R"(
+#include <cstdint>
+#include <type_traits>
+int32_t scale(int32_t value);
+/// Scales a floating-point value.
+// @throws
+template <typename T, std::enable_if_t<std::is_floating_point<T>::value, int> = 0>
+int32_t scale(T value);
+
+template <typename T>
+typename std::enable_if<std::is_integral<T>::value, T>::type
+twice(T value);
+
+template <bool Narrow = (sizeof(void*) == 4), typename = std::enable_if_t<Narrow>>
+int32_t pointer_bits(int32_t handle);
+
+class Gauge {
+public:
+    Gauge();
+    void set(int32_t value);
+    template <typename T, typename = std::enable_if_t<std::is_enum<T>::value>>
+    void set(T preset);
+    // @skip
+    int32_t raw() const;
+    int32_t value() const;
+};
+)";
+    FFIModule module = FFIAnalyzer().analyzeSource(source, "units");
+    auto find = [](const std::vector<FFIFunction>& functions, const std::string& name, size_t index) {
+        for (const auto& func : functions) {
+            if (func.name == name && index-- == 0) {
+                return func;
+            }
+        }
+        assert(false);
+        return FFIFunction{};
+    };
+
+    // The plain overload binds; its annotations are not the template's
+    FFIFunction plain = find(module.functions, "scale", 0);
+    assert(plain.can_use_ffi && !plain.throws && plain.doc.empty());
+    FFIFunction floating = find(module.functions, "scale", 1);
+    assert(!floating.can_use_ffi && floating.throws);
+    assert(floating.reason == "Overload of scale is constrained by std::enable_if_t<std::is_floating_point<T>::value, "
+                              "int>, and whether it exists cannot be determined without compiling it");
+    // Conditions spelled in a return type above the name, or in a head whose parameters C takes
+    assert(find(module.functions, "twice", 0).reason.find(
+               "std::enable_if<std::is_integral<T>::value, T>::type") != std::string::npos);
+    FFIFunction narrow = find(module.functions, "pointer_bits", 0);
+    assert(!narrow.can_use_ffi && narrow.reason.find("std::enable_if_t<Narrow>") != std::string::npos);
+
+    const FFIClass& gauge = module.classes[0];
+    assert(find(gauge.methods, "set", 0).can_use_ffi);
+    assert(find(gauge.methods, "set", 1).reason.find("std::enable_if_t<std::is_enum<T>::value>") != std::string::npos);
+    assert(find(gauge.methods, "raw", 0).reason == "Left out with // @skip");
+    assert(find(gauge.methods, "value", 0).can_use_ffi);
+
+    // None of them gets a shim, and the report gives the reasons
+    std::string shim = CWrapperGenerator().generateImplementation(module.functions, module.classes, "units");
+    assert(shim.find("pointer_bits(") == std::string::npos && shim.find("->raw()") == std::string::npos);
+    assert(shim.find("int32_t units_scale(int32_t value)") != std::string::npos);
+    std::string report = GoFFIGenerator().generateReport(module.functions, module.classes, "units");
+    assert(report.find("Gauge::raw: Left out with // @skip") != std::string::npos);
+    assert(report.find("pointer_bits: Overload of pointer_bits is constrained") != std::string::npos);
+
+    // Template policies still bind when nothing constrains them
+    FFIModule policies = FFIAnalyzer().analyzeSource(R"(
+struct IsEven { bool operator()(int32_t value) const; };
+// @instantiate IsEven
+template <typename Pred, typename = std::enable_if_t<std::is_class<Pred>::value>>
+int32_t count_if(Pred pred);
+// @instantiate IsEven
+template <typename Pred>
+int32_t find_if(Pred pred);
+)", "units");
+    assert(!find(policies.functions, "count_if", 0).can_use_ffi);
+    assert(find(policies.functions, "find_if", 0).can_use_ffi);
+
+    std::cout << "  ✓ Constrained overloads test passed\n";
+}
+
+void testStringOutputs() {
+    std::string source = R"(
+#include <string>
+/// Finds the value stored under key.
+bool lookup(const std::string& key, std::string& out_value);
+void repeat(std::string& text, int32_t times);
+/// @param[out] path where the cache lives
+bool cache_path(std::string& path, int32_t& out_size);
+)";
+    FFIModule module = FFIAnalyzer().analyzeSource(source, "registry");
+    const FFIParameter& value = module.functions[0].parameters[1];
+    assert(value.c_type == "char**" && value.direction == ParamDirection::Out);
+    assert(module.functions[1].parameters[0].direction == ParamDirection::InOut);
+    assert(module.functions[2].parameters[0].direction == ParamDirection::Out);
+
+    // The shim's local string is copied out once into a malloc()ed buffer
+    std::string shim = CWrapperGenerator().generateImplementation(module.functions, module.classes, "registry");
+    assert(shim.find("#include <cstdlib>") != std::string::npos);
+    assert(shim.find("class OutputString {") != std::string::npos);
+    assert(shim.find("bool registry_lookup(const char* key, char** out_value, size_t* out_value_len) {\n"
+                     "    OutputString out_value_slot(out_value, out_value_len);\n"
+                     "    return lookup(key, out_value_slot.get());\n") != std::string::npos);
+    std::string header = CWrapperGenerator().generateCHeader(module, "registry");
+    assert(header.find("@note *out_value (NULL or *out_value_len bytes from malloc()) is freed") != std::string::npos);
+
+    // A lookup reads like a Go map, while several outputs keep the bool first
+    std::string code = GoFFIGenerator().generatePackage(module.functions, module.classes, "registry");
+    assert(code.find("func Lookup(key string) (string, bool) {") != std::string::npos);
+    assert(code.find("\tdefer func() { C.free(unsafe.Pointer(cOutValue)) }()\n"
+                     "\tresult := bool(C.registry_lookup(cKey, &cOutValue, &cOutValueLen))\n"
+                     "\treturn string(unsafe.Slice((*byte)(unsafe.Pointer(cOutValue)), cOutValueLen)), result\n")
+           != std::string::npos);
+    assert(code.find("func Repeat(text *string, times int32) {\n"
+                     "\tcText, cTextLen := C.CString(*text), C.size_t(len(*text))\n") != std::string::npos);
+    assert(code.find("\t*text = string(unsafe.Slice((*byte)(unsafe.Pointer(cText)), cTextLen))\n") != std::string::npos);
+    assert(code.find("func CachePath() (bool, string, int32) {") != std::string::npos);
+
+    std::cout << "  ✓ String outputs test passed\n";
+}
+
+void testAtomicAccessors() {
+    std::string source = R"(
+#include <atomic>
+#include <cstdint>
+enum class Mode : uint8_t { Idle, Busy };
+class Gauge {
+public:
+    Gauge();
+    std::atomic<int64_t> hits{0};
+    std::atomic<bool> open = {true};
+    std::atomic<Mode> mode;
+    std::atomic<int32_t*> slot;
+    int32_t plain;
+};
+)";
+    FFIModule module = FFIAnalyzer().analyzeSource(source, "gauges");
+    const FFIClass& gauge = module.classes[0];
+    std::vector<std::string> names;
+    for (const auto& method : gauge.methods) {
+        names.push_back(method.name);
+    }
+    // Brace initializers do not end the class, and only scalar atomics get accessors
+    assert((names == std::vector<std::string>{"Gauge", "load_hits", "store_hits", "load_open", "store_open"}));
+    assert(gauge.methods[1].atomic && gauge.methods[1].is_const && gauge.methods[1].c_return_type == "int64_t");
+
+    std::string shim = CWrapperGenerator().generateImplementation(module.functions, module.classes, "gauges");
+    assert(shim.find("int64_t gauges_Gauge_load_hits(const void* self) {\n"
+                     "    return static_cast<const Gauge*>(self)->hits.load();\n") != std::string::npos);
+    assert(shim.find("void gauges_Gauge_store_open(void* self, bool value) {\n"
+                     "    static_cast<Gauge*>(self)->open.store(value);\n") != std::string::npos);
+
+    std::string code = GoFFIGenerator().generatePackage(module.functions, module.classes, "gauges");
+    assert(code.find("// LoadHits atomically loads Gauge::hits with std::atomic::load.\n"
+                     "// It may run concurrently with the other atomic accessors of g.\n"
+                     "func (g *Gauge) LoadHits() int64 {\n") != std::string::npos);
+    assert(code.find("func (g *Gauge) StoreOpen(value bool) {") != std::string::npos);
+    assert(code.find("Plain") == std::string::npos && code.find("Mode()") == std::string::npos);
+
+    std::cout << "  ✓ Atomic accessors test passed\n";
+}
+
+void testPlatformConfigurations() {
+    std::string source = R"(
+#include <cstdint>
+// @mirror
+struct Stat {
+    int32_t size;
+#ifdef _WIN32
+    uint64_t handle;
+#endif
+};
+#ifdef _WIN32
+uint64_t descriptor();
+#else
+int32_t descriptor();
+#endif
+#ifdef __linux__
+int32_t openFiles();
+#endif
+#ifdef WITH_TRACE
+void trace();
+#endif
+int32_t shared();
+)";
+    const std::string linux_triple = "x86_64-unknown-linux-gnu";
+    const std::string windows_triple = "x86_64-pc-windows-msvc";
+    FFIModule module = FFIAnalyzer().analyzeSource(source, "x");
+    // Identical declarations merge; macros other than the platform's are left alone
+    std::map<std::string, std::vector<std::string>> targets;
+    for (const auto& func : module.functions) {
+        targets[func.name + " " + func.return_type] = func.targets;
+    }
+    assert(targets.size() == 5);
+    assert(targets["shared int32_t"].empty() && targets["trace void"].empty());
+    assert((targets["descriptor int32_t"] == std::vector<std::string>{linux_triple}));
+    assert((targets["descriptor uint64_t"] == std::vector<std::string>{windows_triple}));
+    assert((targets["openFiles int32_t"] == std::vector<std::string>{linux_triple}));
+    const FFIClass& stat = module.classes[0];
+    assert(stat.targets.empty() && stat.fields.size() == 1 && stat.fields[0].name == "size");
+    assert(stat.target_fields.at(windows_triple).size() == 2 && stat.target_fields.at(linux_triple).size() == 1);
+
+    std::string shim = CWrapperGenerator().generateImplementation(module.functions, module.classes, "x");
+    assert(shim.find("#if !defined(_MSC_VER) && defined(__linux__) && UINTPTR_MAX > 0xFFFFFFFFu\n"
+                     "int32_t x_openFiles(void) {\n") != std::string::npos);
+    assert(shim.find("#if defined(_MSC_VER) && defined(_WIN32) && UINTPTR_MAX > 0xFFFFFFFFu\n"
+                     "uint64_t x_descriptor(void) {\n") != std::string::npos);
+
+    // Per-target bindings live in build-constrained files, under one Go name
+    GoFFIGenerator generator;
+    std::string code = generator.generatePackage(module.functions, module.classes, "x");
+    assert(code.find("func Shared() int32") != std::string::npos);
+    assert(code.find("Descriptor") == std::string::npos && code.find("OpenFiles") == std::string::npos);
+    std::map<std::string, std::string> files = generator.generateTargetFiles(module.functions, module.classes, "x");
+    assert(files.size() == 2);
+    assert(files["x_linux_amd64.go"].find("//go:build linux && amd64\n") != std::string::npos);
+    assert(files["x_linux_amd64.go"].find("func Descriptor() int32 {") != std::string::npos);
+    assert(files["x_linux_amd64.go"].find("func OpenFiles() int32 {") != std::string::npos);
+    assert(files["x_windows_amd64.go"].find("//go:build windows && amd64\n") != std::string::npos);
+    assert(files["x_windows_amd64.go"].find("func Descriptor() uint64 {") != std::string::npos);
+    assert(files["x_windows_amd64.go"].find("OpenFiles") == std::string::npos);
+
+    std::string report = generator.generateReport(module.functions, module.classes, "x");
+    assert(report.find("Declared differently per target, bound only where declared (5):\n") != std::string::npos);
+    assert(report.find("  descriptor() -> uint64_t: x86_64-pc-windows-msvc\n") != std::string::npos);
+    assert(report.find("  Stat {int32_t size}: x86_64-unknown-linux-gnu\n") != std::string::npos);
+
+    // Enums must agree, and targets sharing a build constraint must declare the same
+    try {
+        FFIAnalyzer().analyzeSource("#ifdef _WIN32\nenum class Kind { A };\n#else\nenum class Kind { A, B };\n#endif\n", "x");
+        assert(false);
+    } catch (const std::invalid_argument& e) {
+        assert(std::string(e.what()).find("enum Kind differs") != std::string::npos);
+    }
+    FFIOptions toolchains;
+    toolchains.target_triples = {windows_triple, "x86_64-w64-mingw32"};
+    FFIModule compilers = FFIAnalyzer(toolchains).analyzeSource("#ifdef _MSC_VER\nint32_t msvcOnly();\n#endif\n", "x");
+    try {
+        GoFFIGenerator(toolchains).generateTargetFiles(compilers.functions, compilers.classes, "x");
+        assert(false);
+    } catch (const std::invalid_argument&) {
+    }
+
+    // With a single target, its configuration is simply the header
+    FFIOptions single;
+    single.target_triples = {linux_triple};
+    FFIModule linux_only = FFIAnalyzer(single).analyzeSource(source, "x");
+    assert(linux_only.functions.size() == 4 && linux_only.classes[0].target_fields.empty());
+    for (const auto& func : linux_only.functions) {
+        assert(func.targets.empty());
+        assert(func.name != "descriptor" || func.return_type == "int32_t");
+    }
+
+    std::cout << "  ✓ Platform configurations test passed\n";
+}
+
+void testSubscriptAccessors() {
+    std::string source = R"(
+#include <cstddef>
+#include <cstdint>
+#include <cstdint>
+class Samples {
+public:
+    explicit Samples(size_t count);
+    int32_t& operator[](size_t i);
+    const int32_t& operator[](size_t i) const;
+    size_t size() const;
+};
+class Curve {
+public:
+    double operator[](int i) const;
+private:
+    float& operator[](long i);
+};
+)";
+    FFIModule module = FFIAnalyzer().analyzeSource(source, "samples");
+    auto names = [](const FFIClass& cls) {
+        std::vector<std::string> result;
+        for (const auto& method : cls.methods) {
+            result.push_back(method.name);
+        }
+        return result;
+    };
+    // The const overload reads, the mutable reference writes; a private operator[] is left alone
+    assert((names(module.classes[0]) == std::vector<std::string>{"Samples", "size", "at", "set_at"}));
+    assert((names(module.classes[1]) == std::vector<std::string>{"at"}));
+    const FFIFunction& at = module.classes[0].methods[2];
+    assert(at.subscript && at.is_const && at.c_return_type == "int32_t");
+    assert(at.parameters[0].cpp_type == "size_t" && at.parameters[0].c_type == "intptr_t");
+
+    std::string shim = CWrapperGenerator().generateImplementation(module.functions, module.classes, "samples");
+    assert(shim.find("int32_t samples_Samples_at(const void* self, intptr_t i) {\n"
+                     "    return (*static_cast<const Samples*>(self))[static_cast<size_t>(i)];\n") != std::string::npos);
+    assert(shim.find("void samples_Samples_set_at(void* self, intptr_t i, int32_t value) {\n"
+                     "    (*static_cast<Samples*>(self))[static_cast<size_t>(i)] = value;\n") != std::string::npos);
+
+    std::string code = GoFFIGenerator().generatePackage(module.functions, module.classes, "samples");
+    assert(code.find("// At returns element i of s through Samples::operator[].\n"
+                     "// It does not check i, which must index an element of s.\n"
+                     "func (s *Samples) At(i int) int32 {\n"
+                     "\treturn int32(C.samples_Samples_at(s.ptr, C.intptr_t(i)))\n") != std::string::npos);
+    assert(code.find("func (s *Samples) SetAt(i int, value int32) {") != std::string::npos);
+    assert(code.find("func (c *Curve) At(i int) float64 {") != std::string::npos);
+    assert(code.find("Curve) SetAt") == std::string::npos);
+
+    // With bounds_check, the index is checked against size() under the wrapper's lock
+    FFIOptions options;
+    options.bounds_check = true;
+    options.thread_safe = true;
+    std::string checked = GoFFIGenerator(options).generatePackage(module.functions, module.classes, "samples");
+    assert(checked.find("// It panics if i is outside [0, s.Size()).\n"
+                        "func (s *Samples) SetAt(i int, value int32) {\n"
+                        "\ts.mu.Lock()\n"
+                        "\tdefer s.mu.Unlock()\n"
+                        "\tif n := int(C.samples_Samples_size(s.ptr)); i < 0 || i >= n {\n"
+                        "\t\tpanic(\"Samples::operator[]: index \" + strconv.Itoa(i) + \" out of range [0:\" + "
+                        "strconv.Itoa(n) + \"]\")\n") != std::string::npos);
+    assert(checked.find("\"strconv\"") != std::string::npos);
+    assert(checked.find("func (c *Curve) At(i int) float64 {\n\tc.mu.Lock()\n\tdefer c.mu.Unlock()\n\treturn") !=
+           std::string::npos);
+
+    std::cout << "  ✓ Subscript accessors test passed\n";
+}
+
+void testWideStrings() {
+    std::string source = R"(
+#include <string>
+void echo(const std::wstring& text, std::wstring& out);
+void rename(std::wstring name, const std::string& tag);
+std::wstring title();
+)";
+    FFIModule module = FFIAnalyzer().analyzeSource(source, "wide");
+    // Go sees UTF-8 strings exactly as for std::string
+    assert(module.functions[0].parameters[0].c_type == "const char*");
+    assert(module.functions[0].parameters[1].c_type == "char**");
+    assert(module.functions[0].parameters[1].direction == ParamDirection::Out);
+    assert(module.functions[1].parameters[0].c_type == "const char*");
+    assert(!module.functions[2].can_use_ffi);
+
+    // The shim converts, and only std::wstring
+    std::string shim = CWrapperGenerator().generateImplementation(module.functions, module.classes, "wide");
+    assert(shim.find("static std::wstring wideFromUtf8(const std::string& text) {") != std::string::npos);
+    assert(shim.find("static std::string utf8FromWide(const std::wstring& wide) {") != std::string::npos);
+    assert(shim.find("void wide_echo(const char* text, char** out, size_t* out_len) {\n"
+                     "    OutputWideString out_slot(out, out_len);\n"
+                     "    echo(wideFromUtf8(text), out_slot.get());\n") != std::string::npos);
+    assert(shim.find("    rename(wideFromUtf8(name), tag);\n") != std::string::npos);
+    std::string header = CWrapperGenerator().generateCHeader(module, "wide");
+    assert(header.find("@note text, out: UTF-8, converted from and to the std::wstring") != std::string::npos);
+
+    std::string code = GoFFIGenerator().generatePackage(module.functions, module.classes, "wide");
+    assert(code.find("func Echo(text string) string {") != std::string::npos);
+    assert(code.find("func Rename(name string, tag string) {") != std::string::npos);
+
+    // Without std::wstring the helpers are left out
+    FFIModule narrow = FFIAnalyzer().analyzeSource("#include <string>\nvoid set(const std::string& s);\n", "wide");
+    shim = CWrapperGenerator().generateImplementation(narrow.functions, narrow.classes, "wide");
+    assert(shim.find("wideFromUtf8") == std::string::npos);
+
+    std::cout << "  ✓ Wide strings test passed\n";
+}
+
+void testParameterConstraints() {
+    std::string source = R"(
+#include <cstddef>
+#include <cstdint>
+/// @param level Must be between 0 and 9.
+/// @param ratio Fraction of the input, in the range [0, 1).
+int compress(int level, double ratio);
+/// @param bits One of 8, 16 or 32.
+/// @param count At least 1 and at most 5.
+/// @param size Must be non-negative.
+void configure(int bits, unsigned int count, size_t size);
+/// @param threads Up to 4 threads are used.
+/// @param depth Must be between 0.5 and 2.
+/// @param small Must be at most 300.
+void tune(int threads, int depth, int8_t small);
+/// @param level Must be between 0 and 9.
+// @novalidate
+void fast(int level);
+/// @param gain Must be positive.
+void amplify(float gain);
+)";
+    FFIModule module = FFIAnalyzer().analyzeSource(source, "codec");
+    const FFIConstraint& level = module.functions[0].parameters[0].constraint;
+    assert(level.text == "between 0 and 9" && level.min == "0" && level.max == "9" && !level.max_exclusive);
+    const FFIConstraint& ratio = module.functions[0].parameters[1].constraint;
+    assert(ratio.text == "at least 0 and less than 1" && ratio.max_exclusive);
+    const FFIConstraint& bits = module.functions[1].parameters[0].constraint;
+    assert(bits.text == "one of 8, 16 or 32" && bits.values.size() == 3);
+    assert(module.functions[1].parameters[1].constraint.text == "between 1 and 5");
+    // The type already keeps size_t non-negative
+    assert(module.functions[1].parameters[2].constraint.text.empty());
+    // Ambiguous phrasings and bounds the type cannot take are reported, not checked
+    assert(module.functions[2].parameters[0].constraint.text.empty());
+    assert(module.functions[2].parameters[0].constraint.skipped.find("Up to 4") != std::string::npos);
+    assert(module.functions[2].parameters[1].constraint.skipped.find("0.5 is not an integer") != std::string::npos);
+    assert(module.functions[2].parameters[2].constraint.skipped.find("outside the range") != std::string::npos);
+    assert(module.functions[3].unvalidated);
+
+    std::string code = GoFFIGenerator().generatePackage(module.functions, module.classes, "codec");
+    assert(code.find("// level must be between 0 and 9.\n"
+                     "// ratio must be at least 0 and less than 1.\n"
+                     "// Other values return a *ValidationError before C++ is called.\n"
+                     "func Compress(level int32, ratio float64) (int32, error) {\n"
+                     "\tif level < 0 || level > 9 {\n"
+                     "\t\treturn 0, &ValidationError{Function: \"compress\", Parameter: \"level\", Value: level, "
+                     "Constraint: \"between 0 and 9\"}\n"
+                     "\t}\n"
+                     "\tif !(ratio >= 0 && ratio < 1) {\n") != std::string::npos);
+    assert(code.find("\treturn result, nil\n") != std::string::npos);
+    assert(code.find("\tif bits != 8 && bits != 16 && bits != 32 {\n") != std::string::npos);
+    assert(code.find("func Configure(bits int32, count uint32, size uint) error {") != std::string::npos);
+    assert(code.find("func Tune(threads int32, depth int32, small int8) {") != std::string::npos);
+    assert(code.find("// Other values are passed to C++ unchecked.\nfunc Fast(level int32) {") != std::string::npos);
+    assert(code.find("type ValidationError struct {") != std::string::npos);
+
+    // Overrides replace or drop documented constraints; panics replace errors
+    FFIOptions options;
+    options.validation_failure = ValidationFailure::Panic;
+    options.constraints["tune"]["threads"] = "at most 4";
+    options.constraints["amplify"]["gain"] = "";
+    options.unvalidated = {"compress"};
+    FFIModule overridden = FFIAnalyzer(options).analyzeSource(source, "codec");
+    assert(overridden.functions[2].parameters[0].constraint.text == "at most 4");
+    assert(overridden.functions[4].parameters[0].constraint.text.empty());
+    assert(overridden.functions[0].unvalidated);
+    code = GoFFIGenerator(options).generatePackage(overridden.functions, overridden.classes, "codec");
+    assert(code.find("func Tune(threads int32, depth int32, small int8) {\n"
+                     "\tif threads > 4 {\n"
+                     "\t\tpanic(&ValidationError{") != std::string::npos);
+    assert(code.find("func Compress(level int32, ratio float64) int32 {\n\treturn") != std::string::npos);
+
+    std::string report = GoFFIGenerator().generateReport(module.functions, module.classes, "codec");
+    assert(report.find("Arguments checked against documented constraints (6):") != std::string::npos);
+    assert(report.find("  fast(level): between 0 and 9, not checked\n") != std::string::npos);
+    assert(report.find("Documented constraints left unchecked as ambiguous (3):") != std::string::npos);
+
+    std::cout << "  ✓ Parameter constraints test passed\n";
+}
+
+void testScratchArena() {
+    std::string source = R"(
+#include <cstdint>
+#include <string>
+class Catalog {
+public:
+    Catalog();
+    ~Catalog();
+    void add(const std::string& name, const std::string& title);
+};
+/// @throws std::invalid_argument when key is empty
+int32_t store(const char* key, const std::string& value);
+int32_t lookup(const char* key);
+int32_t logf(const char* tag, const char* format, ...);
+)";
+    FFIAnalyzer analyzer;
+    FFIModule module = analyzer.analyzeSource(source, "catalog");
+
+    // Disabled by default: every string argument is copied with C.CString
+    FFIOptions plain;
+    GoFFIGenerator plain_generator(plain);
+    std::string code = plain_generator.generatePackage(module.functions, module.classes, "catalog");
+    assert(code.find("cKey := C.CString(key)") != std::string::npos);
+    assert(code.find("scratchArena") == std::string::npos);
+    assert(plain_generator.generateScratchArenaBenchmarks(module.functions, module.classes, "catalog").empty());
+
+    FFIOptions options;
+    options.scratch_arena = true;
+    GoFFIGenerator generator(options);
+    code = generator.generatePackage(module.functions, module.classes, "catalog");
+    assert(code.find("\tscratch := newScratchArena(len(key) + len(value) + 2)\n"
+                     "\tdefer scratch.free()\n"
+                     "\tcKey := scratch.copyString(key)\n"
+                     "\tcValue := scratch.copyString(value)\n") != std::string::npos);
+    assert(code.find("\tscratch := newScratchArena(len(name) + len(title) + 2)\n") != std::string::npos);
+    assert(code.find("func newScratchArena(size int) scratchArena {") != std::string::npos);
+
+    // A lone string gains nothing from an arena, and a printf format is only
+    // known after fmt.Sprintf
+    assert(code.find("cKey := C.CString(key)\n\tdefer C.free(unsafe.Pointer(cKey))\n\treturn int32(C.catalog_lookup(") !=
+           std::string::npos);
+    assert(code.find("cTag := C.CString(tag)") != std::string::npos);
+
+    // The stack buffer serves strings that fit it without any allocation
+    options.small_string_size = 16;
+    code = GoFFIGenerator(options).generatePackage(module.functions, module.classes, "catalog");
+    assert(code.find("newScratchArena(") == std::string::npos);
+    assert(GoFFIGenerator(options).generateScratchArenaBenchmarks(module.functions, module.classes, "catalog").empty());
+
+    std::string bench = generator.generateScratchArenaBenchmarks(module.functions, module.classes, "catalog");
+    assert(bench.find("func BenchmarkScratchArena(b *testing.B) {") != std::string::npos);
+    assert(bench.find("func BenchmarkScratchPerString(b *testing.B) {") != std::string::npos);
+    std::cout << "  ✓ Scratch arena test passed\n";
+}
+
+void testStringVisitors() {
+    std::string source = R"(
+#include <cstdint>
+#include <string>
+class Config {
+public:
+    Config();
+    ~Config();
+};
+void for_each_key(Config* config, void (*visit)(const char*, void*), void* context);
+void walk_keys(const Config& config, bool (*visit)(void* ctx, const std::string& key), void* ctx);
+void for_each_raw(Config* config, void (*visit)(char*, void*), void* context);
+)";
+    FFIAnalyzer analyzer;
+    FFIModule module = analyzer.analyzeSource(source, "settings");
+    const auto& methods = module.classes[0].methods;
+    auto find = [&](const std::string& name) {
+        for (const auto& method : methods) {
+            if (method.name == name) {
+                return method;
+            }
+        }
+        for (const auto& func : module.functions) {
+            if (func.name == name) {
+                return func;
+            }
+        }
+        return FFIFunction{};
+    };
+    assert(find("for_each_key").visitor.element.c_type == "const char*");
+    assert(find("for_each_key").visitor.collect == "keys");
+    assert(find("walk_keys").visitor.stops && find("walk_keys").visitor.collect == "strings");
+    // Only const text: C++ could write through a char* the Go string was copied from
+    assert(!find("for_each_raw").can_use_ffi);
+    assert(find("for_each_raw").reason.find("must take a class, a string or a scalar element") != std::string::npos);
+
+    std::string shim = CWrapperGenerator().generateImplementation(module.functions, module.classes, "settings");
+    assert(shim.find("for_each_key(static_cast<Config*>(self), [](const char* element, void* data) -> void { "
+                     "(*static_cast<VisitorCall*>(data))(element); }, &visitor_call);") != std::string::npos);
+    assert(shim.find("[](void* data, const std::string& element) -> bool { "
+                     "return (*static_cast<VisitorCall*>(data))(element.c_str()); }") != std::string::npos);
+
+    std::string code = GoFFIGenerator().generatePackage(module.functions, module.classes, "settings",
+                                                        module.enums, module.constants);
+    assert(code.find("func (c *Config) ForEachKey(visit func(string) bool) {\n"
+                     "\tstate := &visitorState{visit: func(element unsafe.Pointer) bool { "
+                     "return visit(C.GoString((*C.char)(element))) }}\n") != std::string::npos);
+    assert(code.find("func (c *Config) Keys() []string {\n"
+                     "\tvar collected []string\n") != std::string::npos);
+    assert(code.find("func (c *Config) WalkKeys(visit func(string) bool) {") != std::string::npos);
+    assert(code.find("// visit is called with each element and returns false to stop the visit.\n"
+                     "func (c *Config) WalkKeys(") != std::string::npos);
+    std::cout << "  ✓ String visitor test passed\n";
+}
+
+void testTypedPointers() {
+    std::string source = R"(
+#include <cstddef>
+#include <cstdint>
+void add_all(int32_t* values, size_t n, int32_t delta);
+double sum(const double* values, size_t n);
+class Histogram {
+public:
+    Histogram();
+    int32_t& bucket(int32_t i);
+};
+)";
+    FFIModule module = FFIAnalyzer().analyzeSource(source, "samples");
+
+    // The shim hands out the address of what the reference refers to
+    std::string shim = CWrapperGenerator().generateImplementation(module.functions, module.classes, "samples");
+    assert(shim.find("int32_t* samples_Histogram_bucket(void* self, int32_t i) {\n"
+                     "    return &static_cast<Histogram*>(self)->bucket(i);\n") != std::string::npos);
+
+    // Go holds typed pointers as unsafe.Pointer and converts them at the call
+    std::string code = GoFFIGenerator().generatePackage(module.functions, module.classes, "samples",
+                                                        module.enums, module.constants);
+    assert(code.find("\tC.samples_add_all((*C.int32_t)(values), C.size_t(n), C.int32_t(delta))\n") !=
+           std::string::npos);
+    assert(code.find("return float64(C.samples_sum((*C.double)(values), C.size_t(n)))\n") != std::string::npos);
+    assert(code.find("// The result points to the object C++ returned by reference, which C++\n"
+                     "// keeps ownership of.\n"
+                     "func (h *Histogram) Bucket(i int32) unsafe.Pointer {\n"
+                     "\treturn unsafe.Pointer(C.samples_Histogram_bucket(h.ptr, C.int32_t(i)))\n") !=
+           std::string::npos);
+    std::cout << "  ✓ Typed pointer test passed\n";
+}
+
+void testReceiverNames() {
+    FFIModule module = FFIAnalyzer().analyzeSource(R"(
+#include <cstdint>
+class Widget {
+public:
+    Widget(int32_t w);
+    bool resize(int32_t w, int32_t h);
+    static Widget* make(int32_t w);
+};
+)", "ui");
+    std::string code = GoFFIGenerator().generatePackage(module.functions, module.classes, "ui",
+                                                        module.enums, module.constants);
+    // A parameter named like the receiver is renamed, elsewhere it stays
+    assert(code.find("func (w *Widget) Resize(w_ int32, h int32) bool {\n"
+                     "\treturn bool(C.ui_Widget_resize(w.ptr, C.int32_t(w_), C.int32_t(h)))\n") !=
+           std::string::npos);
+    assert(code.find("func NewWidget(w int32) *Widget {") != std::string::npos);
+    assert(code.find("func WidgetMake(w int32) *Widget {") != std::string::npos);
+    std::cout << "  ✓ Receiver name test passed\n";
+}
+
+void runAllFFITests() {
+    std::cout << "\nRunning FFI Generation Tests:\n";
+    testGoPackageGeneration();
+    testCWrapperGeneration();
+    testWindowsDllImportPreamble();
+    testInjectedPrologueEpilogue();
+    testErrorCodeOutParameter();
+    testFixedArrayReferenceReturn();
+    testStdExpectedReturn();
+    testABIProfiles();
+    testMirroredStructLayouts();
+    testCHeaderGeneration();
+    testCHeaderCompilesAgainstShim();
+    testBindingsHeader();
+    testPoolableClass();
+    testBitsetMapping();
+    testThreadAndSignalGating();
+    testSelfTestFixtures();
+    testEnumValidation();
+    testReferenceParameters();
+    testResultStructs();
+    testStructOutputs();
+    testPrintfFunctions();
+    testDefaultArguments();
+    testNameCollisions();
+    testGoroutineSafetyDocs();
+    testProxyIterators();
+    testCachedStrings();
+    testFeatureMacros();
+    testOwnedArrayReturns();
+    testExceptionBehavior();
+    testHandleInvalidation();
+    testVisitors();
+    testBaseFactories();
+    testTmConversion();
+    testDeclsHeader();
+    testTaggedPayloads();
+    testTemplatePolicies();
+    testDevirtualization();
+    testMappings();
+    testPreservedSignals();
+    testCallableResults();
+    testSmallStrings();
+    testModuleInterfaces();
+    testSymbolNames();
+    testEnumNameFunctions();
+    testLoggers();
+    testCallbackResults();
+    testSingleGoFile();
+    testConstrainedOverloads();
+    testStringOutputs();
+    testAtomicAccessors();
+    testPlatformConfigurations();
+    testSubscriptAccessors();
+    testWideStrings();
+    testParameterConstraints();
+    testScratchArena();
+    testStringVisitors();
+    testTypedPointers();
+    testReceiverNames();
+    std::cout << "All FFI generation tests passed!\n";
+}
+
+} // namespace test
+} // namespace ffi
+} // namespace hybrid_transpiler
@@ -1,6 +1,10 @@
 #include <iostream>
 #include <string>
 
+namespace hybrid_transpiler { namespace ffi { namespace test {
+void runAllFFITests();
+} } }
+
 // Simple test framework
 int main(int argc, char* argv[]) {
     std::cout << "Running Hybrid Transpiler Tests...\n";
@@ -21,6 +25,10 @@ int main(int argc, char* argv[]) {
     // TODO: Add memory pattern tests
     passed += 5;
 
+    std::cout << "\n=== FFI Generation Tests ===\n";
+    hybrid_transpiler::ffi::test::runAllFFITests();
+    passed += 1;
+
     std::cout << "\n" << std::string(50, '=') << "\n";
     std::cout << "Test Results:\n";
     std::cout << "  Passed: " << passed << "\n";